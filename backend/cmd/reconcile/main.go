@@ -0,0 +1,62 @@
+// Command reconcile checks every account's balance against its ledger once
+// and writes the JSON report. It exits with status 1 when the report lists
+// discrepancies and 2 when the run itself fails, so cron or CI can alert on
+// either. The database is configured by the same DB_* variables as the
+// server.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"log"
+	"os"
+
+	"go-web-server/internal/repository"
+	accRepo "go-web-server/services/account-service/repository"
+	accService "go-web-server/services/account-service/service"
+)
+
+func main() {
+	autoFreeze := flag.Bool("auto-freeze", false, "freeze active accounts that disagree with their ledger")
+	out := flag.String("out", "", "write the report to this file instead of stdout")
+	flag.Parse()
+
+	db, err := repository.InitDB()
+	if err != nil {
+		fail("Failed to connect to database: %v", err)
+	}
+	defer db.Close()
+
+	svc := accService.NewReconciliationService(accRepo.NewPostgresAccountRepository(db))
+	report, err := svc.Run(context.Background(), *autoFreeze)
+	if err != nil {
+		fail("Reconciliation failed: %v", err)
+	}
+
+	body, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		fail("Failed to encode report: %v", err)
+	}
+	body = append(body, '\n')
+	if *out == "" {
+		_, err = os.Stdout.Write(body)
+	} else {
+		err = os.WriteFile(*out, body, 0o644)
+	}
+	if err != nil {
+		fail("Failed to write report: %v", err)
+	}
+
+	if !report.Clean {
+		log.Printf("Found %d discrepancies on %d accounts, froze %d",
+			len(report.Discrepancies), len(report.AffectedAccounts), len(report.FrozenAccounts))
+		db.Close()
+		os.Exit(1)
+	}
+}
+
+func fail(format string, args ...interface{}) {
+	log.Printf(format, args...)
+	os.Exit(2)
+}
@@ -1,17 +1,33 @@
 package app
 
 import (
+	"context"
 	"log"
 	"net/http"
-	"os"
+	"time"
 
+	"go-web-server/internal/auth"
+	"go-web-server/internal/config"
 	"go-web-server/internal/handler"
 	"go-web-server/internal/repository"
+	"go-web-server/services/account-service/audit"
+	"go-web-server/services/account-service/calendar"
+	"go-web-server/services/account-service/fx"
+	accHandler "go-web-server/services/account-service/handler"
+	"go-web-server/services/account-service/handler/middleware"
+	"go-web-server/services/account-service/money"
 	accRepo "go-web-server/services/account-service/repository"
 	accService "go-web-server/services/account-service/service"
+
+	"github.com/go-chi/chi/v5"
 )
 
 func Run() {
+	cfg, err := config.Load()
+	if err != nil {
+		log.Fatalf("Invalid configuration: %v", err)
+	}
+
 	db, err := repository.InitDB()
 	if err != nil {
 		log.Fatalf("Failed to connect to database: %v", err)
@@ -23,27 +39,267 @@ func Run() {
 		log.Fatalf("Failed to run migrations: %v", err)
 	}
 
+	// Business-day calendars, built in unless a file replaces them.
+	calendars := calendar.Default()
+	if cfg.CalendarFile != "" {
+		if calendars, err = calendar.LoadFile(cfg.CalendarFile); err != nil {
+			log.Fatalf("Failed to load calendars: %v", err)
+		}
+	}
+	calendarService := accService.NewCalendarService(calendars)
+	businessDays, err := calendarService.Calendar(cfg.CalendarCountry)
+	if err != nil {
+		log.Fatalf("Invalid CALENDAR_COUNTRY: %v", err)
+	}
+	log.Printf("Ledger entries take value by the %s business-day calendar (%s)", cfg.CalendarCountry, businessDays.Location())
+
 	// Microservices integration
-	newAccRepo := accRepo.NewPostgresAccountRepository(db)
+	newAccRepo := accRepo.NewPostgresAccountRepository(db).WithCalendar(businessDays)
 	newAccService := accService.NewAccountService(newAccRepo)
 
-	h := handler.NewHandler(repo, newAccService)
+	// Chain entries written before the hash chain existed, or by the seed,
+	// once; unsealed entries found later are reported by verification.
+	sealed, err := newAccRepo.SealLegacyLedgerEntries()
+	if err != nil {
+		log.Fatalf("Failed to seal ledger entries: %v", err)
+	}
+	if sealed > 0 {
+		log.Printf("Sealed %d ledger entries into their hash chains", sealed)
+	}
+
+	// Admin-set rates take precedence over the optional rates file.
+	rates := fx.Chain{fx.ProviderFunc(newAccRepo.GetFXRate)}
+	if cfg.FXRatesFile != "" {
+		fileRates, err := fx.LoadFile(cfg.FXRatesFile)
+		if err != nil {
+			log.Fatalf("Failed to load FX rates: %v", err)
+		}
+		rates = append(rates, fileRates)
+	}
+	fxService := accService.NewFXService(newAccRepo, fx.NewConverter(rates, money.PLN), cfg.FXQuoteTTL)
+
+	reconciliation := accService.NewReconciliationService(newAccRepo)
+	if cfg.ReconcileInterval > 0 {
+		log.Printf("Reconciliation runs every %s (auto-freeze: %t)", cfg.ReconcileInterval, cfg.ReconcileAutoFreeze)
+		go reconcilePeriodically(reconciliation, cfg.ReconcileInterval, cfg.ReconcileAutoFreeze)
+	}
+
+	holds := accService.NewHoldService(newAccRepo, cfg.HoldTTL)
+	go sweepHoldsPeriodically(holds, cfg.HoldSweepInterval)
+
+	overdraft := accService.NewOverdraftService(newAccRepo, cfg.OverdraftInterestRate, businessDays)
+	if !cfg.OverdraftInterestRate.IsZero() {
+		log.Printf("Overdraft interest is charged daily at %s a year", cfg.OverdraftInterestRate)
+		go chargeOverdraftInterestDaily(overdraft)
+	}
+
+	savings := accService.NewSavingsService(newAccRepo, cfg.SavingsInterestRate, cfg.SavingsDayCount, businessDays)
+	if !cfg.SavingsInterestRate.IsZero() {
+		log.Printf("Savings interest accrues daily at %s a year (%s)", cfg.SavingsInterestRate, cfg.SavingsDayCount)
+		go accrueSavingsInterestDaily(savings)
+	}
+
+	fees := accService.NewFeeService(newAccRepo, businessDays)
+	go chargeMaintenanceFeesMonthly(fees)
+
+	standing := accService.NewStandingOrderService(newAccRepo, businessDays)
+	scheduled := accService.NewScheduledTransferService(newAccRepo, businessDays)
+	go executeScheduledTransfersPeriodically(standing, scheduled, cfg.ScheduledTransferInterval)
+
+	var signer *audit.Signer
+	if cfg.AuditSigningKey != nil {
+		if signer, err = audit.NewSigner(cfg.AuditSigningKey); err != nil {
+			log.Fatalf("Invalid audit signing key: %v", err)
+		}
+		log.Printf("Ledger checkpoints are signed with key %s", audit.KeyID(signer.PublicKey()))
+	}
+	auditService := accService.NewAuditService(newAccRepo, signer)
+	if cfg.AuditCheckpointInterval > 0 {
+		log.Printf("Ledger checkpoints are created every %s", cfg.AuditCheckpointInterval)
+		go checkpointPeriodically(auditService, cfg.AuditCheckpointInterval)
+	}
+
+	authService := auth.NewService(repo, cfg)
+	if err := authService.GrantRoles(cfg.AdminUsers, cfg.MerchantUsers); err != nil {
+		log.Fatalf("Invalid ADMIN_USERS or MERCHANT_USERS: %v", err)
+	}
+	requireAuth := middleware.AuthMiddleware(cfg.JWTSecret, authService)
+	requireAdmin := middleware.RequireAdmin()
+	requireSettler := middleware.RequireAdminOrMerchant()
+
+	h := handler.NewHandler(repo, newAccService, authService)
 	mux := http.NewServeMux()
 
 	// Routes
 	mux.HandleFunc("/api/status", h.StatusHandler)
-	mux.HandleFunc("/api/account/", h.AccountHandler)
-	mux.HandleFunc("/api/transactions", h.TransactionHandler)
+	mux.Handle("/api/account/", requireAuth(http.HandlerFunc(h.AccountHandler)))
+	mux.Handle("/api/transactions", requireAuth(http.HandlerFunc(h.TransactionHandler)))
 	mux.HandleFunc("/api/login", h.LoginHandler)
-	mux.HandleFunc("/api/test/reset", h.ResetHandler)
-
-	port := os.Getenv("PORT")
-	if port == "" {
-		port = "8080"
+	mux.HandleFunc("/api/register", h.RegisterHandler)
+	mux.HandleFunc("/api/token/refresh", h.RefreshHandler)
+	mux.Handle("/api/password", requireAuth(http.HandlerFunc(h.ChangePasswordHandler)))
+	mux.Handle("/api/logout", requireAuth(http.HandlerFunc(h.LogoutHandler)))
+	mux.Handle("/api/logout/all", requireAuth(http.HandlerFunc(h.LogoutAllHandler)))
+	if cfg.EnableTestEndpoints {
+		log.Printf("Test endpoints enabled for admins: /api/test/reset")
+		mux.Handle("/api/test/reset", requireAuth(requireAdmin(http.HandlerFunc(h.ResetHandler))))
 	}
 
-	log.Printf("Server starting on port %s", port)
-	if err := http.ListenAndServe(":"+port, mux); err != nil {
+	// Account Service REST API (see services/account-service/api/openapi.yaml)
+	accRouter := chi.NewRouter()
+	accountHandler := accHandler.NewAccountHandler(newAccService, requireAuth, requireAdmin)
+	accountHandler.RegisterRoutes(accRouter)
+	accHandler.NewFXHandler(accountHandler, fxService, requireAdmin).RegisterRoutes(accRouter)
+	accHandler.NewHoldHandler(accountHandler, holds, requireSettler).RegisterRoutes(accRouter)
+	accHandler.NewOverdraftHandler(overdraft, requireAuth, requireAdmin).RegisterRoutes(accRouter)
+	accHandler.NewSavingsHandler(savings, requireAuth, requireAdmin).RegisterRoutes(accRouter)
+	accHandler.NewProductHandler(accService.NewProductService(newAccRepo), requireAuth, requireAdmin).RegisterRoutes(accRouter)
+	accHandler.NewFeeHandler(accountHandler, fees, requireAdmin).RegisterRoutes(accRouter)
+	accHandler.NewLimitHandler(accountHandler, accService.NewLimitService(newAccRepo), requireAdmin).RegisterRoutes(accRouter)
+	accHandler.NewScheduledTransferHandler(accountHandler, scheduled, requireAdmin).RegisterRoutes(accRouter)
+	accHandler.NewStandingOrderHandler(accountHandler, standing, requireAdmin).RegisterRoutes(accRouter)
+	accHandler.NewCalendarHandler(calendarService, requireAuth).RegisterRoutes(accRouter)
+	accHandler.NewGLHandler(accService.NewGLService(newAccRepo), requireAuth, requireAdmin).RegisterRoutes(accRouter)
+	accHandler.NewReconciliationHandler(reconciliation, requireAuth, requireAdmin).RegisterRoutes(accRouter)
+	accHandler.NewAuditHandler(auditService, requireAuth, requireAdmin).RegisterRoutes(accRouter)
+	mux.Handle("/api/v1/", http.StripPrefix("/api/v1", accRouter))
+
+	log.Printf("Server starting on port %s", cfg.Port)
+	if err := http.ListenAndServe(":"+cfg.Port, mux); err != nil {
 		log.Fatalf("Server failed: %v", err)
 	}
 }
+
+// reconcilePeriodically runs reconciliation every interval for the life of
+// the process. A failed run is logged and retried at the next tick.
+func reconcilePeriodically(svc accService.ReconciliationService, every time.Duration, autoFreeze bool) {
+	ticker := time.NewTicker(every)
+	defer ticker.Stop()
+	for range ticker.C {
+		report, err := svc.Run(context.Background(), autoFreeze)
+		if err != nil {
+			log.Printf("Reconciliation failed: %v", err)
+			continue
+		}
+		if !report.Clean {
+			log.Printf("Reconciliation %s found %d discrepancies on %d accounts, froze %d",
+				report.ID, len(report.Discrepancies), len(report.AffectedAccounts), len(report.FrozenAccounts))
+		}
+	}
+}
+
+// checkpointPeriodically signs the ledger chain heads every interval for the
+// life of the process. A failed checkpoint is logged and retried at the next
+// tick.
+func checkpointPeriodically(svc accService.AuditService, every time.Duration) {
+	ticker := time.NewTicker(every)
+	defer ticker.Stop()
+	for range ticker.C {
+		if _, err := svc.CreateCheckpoint(context.Background()); err != nil {
+			log.Printf("Ledger checkpoint failed: %v", err)
+		}
+	}
+}
+
+// sweepHoldsPeriodically marks expired holds every interval for the life of
+// the process. A failed sweep is logged and retried at the next tick.
+func sweepHoldsPeriodically(svc accService.HoldService, every time.Duration) {
+	ticker := time.NewTicker(every)
+	defer ticker.Stop()
+	for range ticker.C {
+		n, err := svc.ExpireHolds(context.Background())
+		if err != nil {
+			log.Printf("Hold sweep failed: %v", err)
+			continue
+		}
+		if n > 0 {
+			log.Printf("Expired %d holds", n)
+		}
+	}
+}
+
+// executeScheduledTransfersPeriodically schedules the payments of standing
+// orders that fell due and then executes due scheduled transfers, those
+// payments included, every interval for the life of the process. A failed
+// pass is logged and what it did not reach is retried at the next tick.
+func executeScheduledTransfersPeriodically(standing accService.StandingOrderService, svc accService.ScheduledTransferService, every time.Duration) {
+	ticker := time.NewTicker(every)
+	defer ticker.Stop()
+	for range ticker.C {
+		if payments, err := standing.ScheduleDuePayments(context.Background()); err != nil {
+			log.Printf("Standing orders failed: %v", err)
+		} else if len(payments.Scheduled) > 0 {
+			log.Printf("Standing orders: %d payments scheduled, %d orders completed", len(payments.Scheduled), len(payments.Completed))
+		}
+
+		run, err := svc.ExecuteDueTransfers(context.Background())
+		if err != nil {
+			log.Printf("Scheduled transfers failed: %v", err)
+			continue
+		}
+		if len(run.Executed)+len(run.Retrying)+len(run.Failed) > 0 {
+			log.Printf("Scheduled transfers: %d executed, %d retrying, %d failed", len(run.Executed), len(run.Retrying), len(run.Failed))
+		}
+	}
+}
+
+// overdraftInterestCheck is how often chargeOverdraftInterestDaily looks for
+// a day that has not been charged yet.
+const overdraftInterestCheck = time.Hour
+
+// chargeOverdraftInterestDaily charges the previous day's overdraft interest
+// for the life of the process. Days already charged are skipped, so only the
+// first check after midnight books anything; a failed run is logged and
+// retried at the next check.
+func chargeOverdraftInterestDaily(svc accService.OverdraftService) {
+	ticker := time.NewTicker(overdraftInterestCheck)
+	defer ticker.Stop()
+	for range ticker.C {
+		run, err := svc.ChargeInterest(context.Background(), time.Time{})
+		if err != nil {
+			log.Printf("Overdraft interest failed: %v", err)
+			continue
+		}
+		if len(run.Charged) > 0 {
+			log.Printf("Charged overdraft interest for %s on %d accounts", run.BusinessDate, len(run.Charged))
+		}
+	}
+}
+
+// accrueSavingsInterestDaily accrues the previous day's savings interest for
+// the life of the process, checking as often as overdraft interest. Days
+// already accrued are skipped, so only the first check after midnight
+// accrues anything.
+func accrueSavingsInterestDaily(svc accService.SavingsService) {
+	ticker := time.NewTicker(overdraftInterestCheck)
+	defer ticker.Stop()
+	for range ticker.C {
+		run, err := svc.AccrueInterest(context.Background(), time.Time{})
+		if err != nil {
+			log.Printf("Savings interest failed: %v", err)
+			continue
+		}
+		if len(run.Accrued) > 0 {
+			log.Printf("Accrued savings interest for %s on %d accounts", run.BusinessDate, len(run.Accrued))
+		}
+	}
+}
+
+// chargeMaintenanceFeesMonthly charges the previous month's maintenance fees
+// for the life of the process, checking as often as overdraft interest.
+// Accounts already charged for the month are skipped, so only the first
+// check of a month charges anything.
+func chargeMaintenanceFeesMonthly(svc accService.FeeService) {
+	ticker := time.NewTicker(overdraftInterestCheck)
+	defer ticker.Stop()
+	for range ticker.C {
+		run, err := svc.ChargeMaintenance(context.Background(), time.Time{})
+		if err != nil {
+			log.Printf("Maintenance fees failed: %v", err)
+			continue
+		}
+		if len(run.Charged) > 0 {
+			log.Printf("Charged maintenance fees for %s on %d accounts", run.Month, len(run.Charged))
+		}
+	}
+}
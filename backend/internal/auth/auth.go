@@ -0,0 +1,230 @@
+// Package auth implements password login for the gateway: credential
+// storage with bcrypt hashes, failed-attempt lockout, and access/refresh token
+// sessions with rotation and revocation.
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+
+	"go-web-server/internal/config"
+	"go-web-server/internal/model"
+	"go-web-server/internal/repository"
+)
+
+// UserRepository is the storage used by Service. It is implemented by
+// *repository.PostgresRepository.
+type UserRepository interface {
+	CreateUser(user *model.User, fullName string) error
+	GetUserByUsername(username string) (*model.User, error)
+	RecordFailedLogin(username string, now time.Time, maxAttempts int, lockUntil time.Time) (*model.User, error)
+	ResetFailedLogins(username string) error
+	// UpdatePassword stores the new hash and revokes the user's refresh and
+	// access tokens in the same transaction.
+	UpdatePassword(username, passwordHash string) error
+	AssignRoles(roles map[string]string) error
+
+	CreateRefreshToken(t *model.RefreshToken) error
+	RotateRefreshToken(oldHash string, now time.Time, next *model.RefreshToken) error
+	RevokeTokenFamily(familyID string) error
+	RevokeUserTokens(username string) error
+	IsTokenRevoked(jti string) (bool, error)
+}
+
+const (
+	// bcrypt ignores everything past 72 bytes, so longer passwords are
+	// rejected rather than silently truncated.
+	MinPasswordLength = 8
+	MaxPasswordLength = 72
+)
+
+// bcryptCost is a variable so tests can use bcrypt.MinCost.
+var bcryptCost = 12
+
+var (
+	ErrInvalidCredentials = errors.New("invalid username or password")
+	ErrAccountLocked      = errors.New("account temporarily locked")
+	ErrInvalidUsername    = errors.New("username must be 3-100 characters: letters, digits, '.', '_' or '-'")
+	ErrWeakPassword       = fmt.Errorf("password must be %d-%d bytes long", MinPasswordLength, MaxPasswordLength)
+	ErrPasswordUnchanged  = errors.New("new password must differ from the current one")
+	ErrMissingFullName    = errors.New("full name is required")
+	ErrUsernameTaken      = repository.ErrUsernameTaken
+	ErrUsernameReserved   = errors.New("username is reserved")
+	ErrUnknownUser        = repository.ErrUnknownUser
+)
+
+// LockedError is returned while a user is locked out after too many failed
+// logins. It matches ErrAccountLocked with errors.Is.
+type LockedError struct {
+	Until time.Time
+}
+
+func (e *LockedError) Error() string {
+	return fmt.Sprintf("%s until %s", ErrAccountLocked, e.Until.UTC().Format(time.RFC3339))
+}
+
+func (e *LockedError) Is(target error) bool { return target == ErrAccountLocked }
+
+var usernamePattern = regexp.MustCompile(`^[A-Za-z0-9._-]{3,100}$`)
+
+// dummyHash is compared against when the user does not exist, so that a login
+// for an unknown username takes as long as one with a wrong password.
+var dummyHash, _ = bcrypt.GenerateFromPassword([]byte("dummy password"), bcryptCost)
+
+type Service struct {
+	repo            UserRepository
+	jwtKey          []byte
+	accessTTL       time.Duration
+	refreshTTL      time.Duration
+	maxFailedLogins int
+	lockoutDuration time.Duration
+	// reserved are the usernames listed in ADMIN_USERS and MERCHANT_USERS.
+	// They cannot be registered, so that a privileged name that is not taken
+	// yet cannot be claimed by anyone.
+	reserved map[string]bool
+	now      func() time.Time
+}
+
+func NewService(repo UserRepository, cfg *config.Config) *Service {
+	reserved := make(map[string]bool, len(cfg.AdminUsers)+len(cfg.MerchantUsers))
+	for _, u := range append(append([]string{}, cfg.AdminUsers...), cfg.MerchantUsers...) {
+		reserved[u] = true
+	}
+	return &Service{
+		repo:            repo,
+		jwtKey:          cfg.JWTSecret,
+		accessTTL:       cfg.AccessTokenTTL,
+		refreshTTL:      cfg.RefreshTokenTTL,
+		maxFailedLogins: cfg.MaxFailedLogins,
+		lockoutDuration: cfg.LockoutDuration,
+		reserved:        reserved,
+		now:             time.Now,
+	}
+}
+
+// GrantRoles stores the admin and merchant roles on the listed users and
+// returns every other user to the customer role. It fails with
+// ErrUnknownUser, changing nothing, if a listed user does not exist: a role
+// must never wait for whoever registers the name first.
+func (s *Service) GrantRoles(admins, merchants []string) error {
+	roles := make(map[string]string, len(admins)+len(merchants))
+	for _, u := range admins {
+		roles[u] = model.RoleAdmin
+	}
+	for _, u := range merchants {
+		roles[u] = model.RoleMerchant
+	}
+	if err := s.repo.AssignRoles(roles); err != nil {
+		if errors.Is(err, ErrUnknownUser) {
+			return err
+		}
+		return fmt.Errorf("failed to assign roles: %w", err)
+	}
+	return nil
+}
+
+// Register creates a customer together with its credentials.
+func (s *Service) Register(ctx context.Context, req model.RegisterRequest) (*model.User, error) {
+	if !usernamePattern.MatchString(req.Username) {
+		return nil, ErrInvalidUsername
+	}
+	if s.reserved[req.Username] {
+		return nil, ErrUsernameReserved
+	}
+	if req.FullName == "" {
+		return nil, ErrMissingFullName
+	}
+	hash, err := hashPassword(req.Password)
+	if err != nil {
+		return nil, err
+	}
+
+	user := &model.User{Username: req.Username, PasswordHash: hash}
+	if err := s.repo.CreateUser(user, req.FullName); err != nil {
+		if errors.Is(err, ErrUsernameTaken) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("failed to create user: %w", err)
+	}
+	return user, nil
+}
+
+// Login checks the password and starts a new session: a fresh refresh token
+// family with its first access token.
+func (s *Service) Login(ctx context.Context, username, password string) (*model.TokenPair, error) {
+	user, err := s.authenticate(username, password)
+	if err != nil {
+		return nil, err
+	}
+	return s.startSession(user)
+}
+
+// ChangePassword replaces the password of username after verifying the current
+// one and ends every session of the user, so tokens issued before the change
+// stop working. A wrong current password counts as a failed login.
+func (s *Service) ChangePassword(ctx context.Context, username string, req model.ChangePasswordRequest) error {
+	if _, err := s.authenticate(username, req.CurrentPassword); err != nil {
+		return err
+	}
+	if req.NewPassword == req.CurrentPassword {
+		return ErrPasswordUnchanged
+	}
+	hash, err := hashPassword(req.NewPassword)
+	if err != nil {
+		return err
+	}
+	if err := s.repo.UpdatePassword(username, hash); err != nil {
+		return fmt.Errorf("failed to update password: %w", err)
+	}
+	return nil
+}
+
+func (s *Service) authenticate(username, password string) (*model.User, error) {
+	user, err := s.repo.GetUserByUsername(username)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load user: %w", err)
+	}
+	if user == nil {
+		bcrypt.CompareHashAndPassword(dummyHash, []byte(password))
+		return nil, ErrInvalidCredentials
+	}
+
+	now := s.now()
+	if user.LockedUntil != nil && now.Before(*user.LockedUntil) {
+		return nil, &LockedError{Until: *user.LockedUntil}
+	}
+
+	if bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(password)) != nil {
+		updated, err := s.repo.RecordFailedLogin(username, now, s.maxFailedLogins, now.Add(s.lockoutDuration))
+		if err != nil {
+			return nil, fmt.Errorf("failed to record failed login: %w", err)
+		}
+		if updated.LockedUntil != nil && now.Before(*updated.LockedUntil) {
+			return nil, &LockedError{Until: *updated.LockedUntil}
+		}
+		return nil, ErrInvalidCredentials
+	}
+
+	if user.FailedAttempts > 0 {
+		if err := s.repo.ResetFailedLogins(username); err != nil {
+			return nil, fmt.Errorf("failed to reset failed logins: %w", err)
+		}
+	}
+	return user, nil
+}
+
+func hashPassword(password string) (string, error) {
+	if len(password) < MinPasswordLength || len(password) > MaxPasswordLength {
+		return "", ErrWeakPassword
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcryptCost)
+	if err != nil {
+		return "", fmt.Errorf("failed to hash password: %w", err)
+	}
+	return string(hash), nil
+}
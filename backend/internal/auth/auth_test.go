@@ -0,0 +1,479 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"golang.org/x/crypto/bcrypt"
+
+	"go-web-server/internal/config"
+	"go-web-server/internal/model"
+)
+
+func init() {
+	bcryptCost = bcrypt.MinCost
+}
+
+// memoryRepo is an in-memory UserRepository mirroring the SQL semantics of
+// the Postgres implementation.
+type memoryRepo struct {
+	users   map[string]*model.User
+	tokens  map[string]*model.RefreshToken
+	revoked map[string]bool
+}
+
+func newMemoryRepo() *memoryRepo {
+	return &memoryRepo{
+		users:   map[string]*model.User{},
+		tokens:  map[string]*model.RefreshToken{},
+		revoked: map[string]bool{},
+	}
+}
+
+func (m *memoryRepo) CreateUser(user *model.User, fullName string) error {
+	if _, ok := m.users[user.Username]; ok {
+		return ErrUsernameTaken
+	}
+	user.ID = "id-" + user.Username
+	user.Role = model.RoleCustomer
+	user.CreatedAt = time.Now()
+	stored := *user
+	m.users[user.Username] = &stored
+	return nil
+}
+
+func (m *memoryRepo) GetUserByUsername(username string) (*model.User, error) {
+	u, ok := m.users[username]
+	if !ok {
+		return nil, nil
+	}
+	copied := *u
+	return &copied, nil
+}
+
+func (m *memoryRepo) RecordFailedLogin(username string, now time.Time, maxAttempts int, lockUntil time.Time) (*model.User, error) {
+	u := m.users[username]
+	expired := u.LockedUntil != nil && !u.LockedUntil.After(now)
+	if expired {
+		u.FailedAttempts = 1
+		u.LockedUntil = nil
+	} else {
+		u.FailedAttempts++
+	}
+	if u.FailedAttempts >= maxAttempts {
+		u.LockedUntil = &lockUntil
+	}
+	copied := *u
+	return &copied, nil
+}
+
+func (m *memoryRepo) ResetFailedLogins(username string) error {
+	m.users[username].FailedAttempts = 0
+	m.users[username].LockedUntil = nil
+	return nil
+}
+
+func (m *memoryRepo) UpdatePassword(username, passwordHash string) error {
+	u := m.users[username]
+	u.PasswordHash = passwordHash
+	u.FailedAttempts = 0
+	u.LockedUntil = nil
+	return m.RevokeUserTokens(username)
+}
+
+func (m *memoryRepo) AssignRoles(roles map[string]string) error {
+	for username := range roles {
+		if _, ok := m.users[username]; !ok {
+			return ErrUnknownUser
+		}
+	}
+	for username, u := range m.users {
+		u.Role = model.RoleCustomer
+		if role, ok := roles[username]; ok {
+			u.Role = role
+		}
+	}
+	return nil
+}
+
+func (m *memoryRepo) CreateRefreshToken(t *model.RefreshToken) error {
+	stored := *t
+	m.tokens[t.TokenHash] = &stored
+	return nil
+}
+
+func (m *memoryRepo) RotateRefreshToken(oldHash string, now time.Time, next *model.RefreshToken) error {
+	old, ok := m.tokens[oldHash]
+	if !ok {
+		return ErrRefreshTokenInvalid
+	}
+	if old.UsedAt != nil {
+		m.revoke(func(t *model.RefreshToken) bool { return t.FamilyID == old.FamilyID })
+		return ErrRefreshTokenReused
+	}
+	if old.RevokedAt != nil || !now.Before(old.ExpiresAt) {
+		return ErrRefreshTokenInvalid
+	}
+	old.UsedAt = &now
+	next.FamilyID = old.FamilyID
+	next.Username = old.Username
+	return m.CreateRefreshToken(next)
+}
+
+func (m *memoryRepo) RevokeTokenFamily(familyID string) error {
+	m.revoke(func(t *model.RefreshToken) bool { return t.FamilyID == familyID })
+	return nil
+}
+
+func (m *memoryRepo) RevokeUserTokens(username string) error {
+	m.revoke(func(t *model.RefreshToken) bool { return t.Username == username })
+	return nil
+}
+
+func (m *memoryRepo) IsTokenRevoked(jti string) (bool, error) {
+	return m.revoked[jti], nil
+}
+
+func (m *memoryRepo) revoke(match func(*model.RefreshToken) bool) {
+	now := time.Now()
+	for _, t := range m.tokens {
+		if match(t) {
+			if t.RevokedAt == nil {
+				t.RevokedAt = &now
+			}
+			m.revoked[t.AccessJTI] = true
+		}
+	}
+}
+
+var testConfig = &config.Config{
+	JWTSecret:       []byte("test_signing_key_of_at_least_32_bytes"),
+	AccessTokenTTL:  15 * time.Minute,
+	RefreshTokenTTL: 24 * time.Hour,
+	MaxFailedLogins: 3,
+	LockoutDuration: 15 * time.Minute,
+	AdminUsers:      []string{"ops"},
+}
+
+func newTestService(t *testing.T) (*Service, *memoryRepo, *time.Time) {
+	repo := newMemoryRepo()
+	svc := NewService(repo, testConfig)
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	svc.now = func() time.Time { return now }
+
+	_, err := svc.Register(context.Background(), model.RegisterRequest{
+		Username: "jan.kowalski",
+		Password: "correct horse",
+		FullName: "Jan Kowalski",
+	})
+	if err != nil {
+		t.Fatalf("register failed: %v", err)
+	}
+	return svc, repo, &now
+}
+
+func TestRegister_StoresHashNotPassword(t *testing.T) {
+	_, repo, _ := newTestService(t)
+
+	u := repo.users["jan.kowalski"]
+	if u.PasswordHash == "correct horse" {
+		t.Fatal("password stored in plain text")
+	}
+	if err := bcrypt.CompareHashAndPassword([]byte(u.PasswordHash), []byte("correct horse")); err != nil {
+		t.Errorf("stored hash does not match the password: %v", err)
+	}
+}
+
+func TestRegister_Validation(t *testing.T) {
+	svc, _, _ := newTestService(t)
+	ctx := context.Background()
+
+	cases := []struct {
+		req  model.RegisterRequest
+		want error
+	}{
+		{model.RegisterRequest{Username: "ab", Password: "long enough", FullName: "A B"}, ErrInvalidUsername},
+		{model.RegisterRequest{Username: "has space", Password: "long enough", FullName: "A B"}, ErrInvalidUsername},
+		{model.RegisterRequest{Username: "anna", Password: "short", FullName: "Anna"}, ErrWeakPassword},
+		{model.RegisterRequest{Username: "anna", Password: "long enough"}, ErrMissingFullName},
+		{model.RegisterRequest{Username: "jan.kowalski", Password: "long enough", FullName: "Jan"}, ErrUsernameTaken},
+		{model.RegisterRequest{Username: "ops", Password: "long enough", FullName: "Ops"}, ErrUsernameReserved},
+	}
+	for _, c := range cases {
+		if _, err := svc.Register(ctx, c.req); !errors.Is(err, c.want) {
+			t.Errorf("Register(%+v): expected %v, got %v", c.req, c.want, err)
+		}
+	}
+}
+
+func TestLogin_IssuesSignedToken(t *testing.T) {
+	svc, _, _ := newTestService(t)
+
+	pair, err := svc.Login(context.Background(), "jan.kowalski", "correct horse")
+	if err != nil {
+		t.Fatalf("login failed: %v", err)
+	}
+	if pair.RefreshToken == "" || pair.Token != pair.AccessToken || pair.ExpiresIn != 900 {
+		t.Errorf("unexpected token pair: %+v", pair)
+	}
+
+	claims := parseClaims(t, svc, pair.AccessToken)
+	if claims["username"] != "jan.kowalski" {
+		t.Errorf("expected username claim jan.kowalski, got %v", claims["username"])
+	}
+	if claims["jti"] == "" || claims["sid"] == "" {
+		t.Errorf("expected jti and sid claims, got %v", claims)
+	}
+	if claims["role"] != model.RoleCustomer {
+		t.Errorf("expected role claim %s, got %v", model.RoleCustomer, claims["role"])
+	}
+}
+
+func TestGrantRoles(t *testing.T) {
+	svc, _, _ := newTestService(t)
+	ctx := context.Background()
+
+	if err := svc.GrantRoles([]string{"jan.kowalski"}, nil); err != nil {
+		t.Fatalf("grant failed: %v", err)
+	}
+	pair, err := svc.Login(ctx, "jan.kowalski", "correct horse")
+	if err != nil {
+		t.Fatalf("login failed: %v", err)
+	}
+	if role := parseClaims(t, svc, pair.AccessToken)["role"]; role != model.RoleAdmin {
+		t.Errorf("expected role claim %s, got %v", model.RoleAdmin, role)
+	}
+
+	// A user dropped from the list loses the role with the next access token.
+	if err := svc.GrantRoles(nil, nil); err != nil {
+		t.Fatalf("grant failed: %v", err)
+	}
+	refreshed, err := svc.Refresh(ctx, pair.RefreshToken)
+	if err != nil {
+		t.Fatalf("refresh failed: %v", err)
+	}
+	if role := parseClaims(t, svc, refreshed.AccessToken)["role"]; role != model.RoleCustomer {
+		t.Errorf("expected role claim %s after the role was revoked, got %v", model.RoleCustomer, role)
+	}
+}
+
+func TestGrantRoles_UnknownUser(t *testing.T) {
+	svc, repo, _ := newTestService(t)
+
+	err := svc.GrantRoles([]string{"jan.kowalski"}, []string{"nobody"})
+	if !errors.Is(err, ErrUnknownUser) {
+		t.Fatalf("expected ErrUnknownUser, got %v", err)
+	}
+	if role := repo.users["jan.kowalski"].Role; role != model.RoleCustomer {
+		t.Errorf("expected no role granted, got %s", role)
+	}
+}
+
+func parseClaims(t *testing.T, svc *Service, tokenString string) jwt.MapClaims {
+	t.Helper()
+	claims := jwt.MapClaims{}
+	_, err := jwt.ParseWithClaims(tokenString, claims, func(*jwt.Token) (interface{}, error) {
+		return testConfig.JWTSecret, nil
+	}, jwt.WithTimeFunc(svc.now))
+	if err != nil {
+		t.Fatalf("token does not verify with the configured key: %v", err)
+	}
+	return claims
+}
+
+func TestLogin_UnknownUser(t *testing.T) {
+	svc, _, _ := newTestService(t)
+
+	_, err := svc.Login(context.Background(), "nobody", "whatever123")
+	if !errors.Is(err, ErrInvalidCredentials) {
+		t.Errorf("expected ErrInvalidCredentials, got %v", err)
+	}
+}
+
+func TestLogin_LockoutAfterFailedAttempts(t *testing.T) {
+	svc, repo, now := newTestService(t)
+	ctx := context.Background()
+
+	for i := 1; i < testConfig.MaxFailedLogins; i++ {
+		if _, err := svc.Login(ctx, "jan.kowalski", "wrong password"); !errors.Is(err, ErrInvalidCredentials) {
+			t.Fatalf("attempt %d: expected ErrInvalidCredentials, got %v", i, err)
+		}
+	}
+
+	_, err := svc.Login(ctx, "jan.kowalski", "wrong password")
+	var locked *LockedError
+	if !errors.As(err, &locked) || !errors.Is(err, ErrAccountLocked) {
+		t.Fatalf("expected LockedError on attempt %d, got %v", testConfig.MaxFailedLogins, err)
+	}
+	if want := now.Add(testConfig.LockoutDuration); !locked.Until.Equal(want) {
+		t.Errorf("expected lock until %v, got %v", want, locked.Until)
+	}
+
+	// The right password does not help while locked out.
+	if _, err := svc.Login(ctx, "jan.kowalski", "correct horse"); !errors.Is(err, ErrAccountLocked) {
+		t.Errorf("expected ErrAccountLocked during lockout, got %v", err)
+	}
+
+	// Once the lock expires the correct password works and the counter resets.
+	*now = now.Add(testConfig.LockoutDuration)
+	if _, err := svc.Login(ctx, "jan.kowalski", "correct horse"); err != nil {
+		t.Fatalf("expected login after lockout expiry, got %v", err)
+	}
+	if u := repo.users["jan.kowalski"]; u.FailedAttempts != 0 || u.LockedUntil != nil {
+		t.Errorf("expected counters reset, got %d attempts, locked until %v", u.FailedAttempts, u.LockedUntil)
+	}
+}
+
+func TestChangePassword(t *testing.T) {
+	svc, _, _ := newTestService(t)
+	ctx := context.Background()
+
+	err := svc.ChangePassword(ctx, "jan.kowalski", model.ChangePasswordRequest{CurrentPassword: "wrong password", NewPassword: "battery staple"})
+	if !errors.Is(err, ErrInvalidCredentials) {
+		t.Fatalf("expected ErrInvalidCredentials for a wrong current password, got %v", err)
+	}
+
+	err = svc.ChangePassword(ctx, "jan.kowalski", model.ChangePasswordRequest{CurrentPassword: "correct horse", NewPassword: "correct horse"})
+	if !errors.Is(err, ErrPasswordUnchanged) {
+		t.Fatalf("expected ErrPasswordUnchanged, got %v", err)
+	}
+
+	before, err := svc.Login(ctx, "jan.kowalski", "correct horse")
+	if err != nil {
+		t.Fatalf("login failed: %v", err)
+	}
+
+	err = svc.ChangePassword(ctx, "jan.kowalski", model.ChangePasswordRequest{CurrentPassword: "correct horse", NewPassword: "battery staple"})
+	if err != nil {
+		t.Fatalf("change password failed: %v", err)
+	}
+
+	// Sessions started with the old password end with the change.
+	if revoked, _ := svc.IsRevoked(parseClaims(t, svc, before.AccessToken)["jti"].(string)); !revoked {
+		t.Error("access token issued before the change is still valid")
+	}
+	if _, err := svc.Refresh(ctx, before.RefreshToken); !errors.Is(err, ErrRefreshTokenInvalid) {
+		t.Errorf("expected ErrRefreshTokenInvalid for a refresh token issued before the change, got %v", err)
+	}
+
+	if _, err := svc.Login(ctx, "jan.kowalski", "correct horse"); !errors.Is(err, ErrInvalidCredentials) {
+		t.Errorf("old password still accepted: %v", err)
+	}
+	if _, err := svc.Login(ctx, "jan.kowalski", "battery staple"); err != nil {
+		t.Errorf("new password rejected: %v", err)
+	}
+}
+
+func TestRefresh_RotatesToken(t *testing.T) {
+	svc, _, _ := newTestService(t)
+	ctx := context.Background()
+
+	first, err := svc.Login(ctx, "jan.kowalski", "correct horse")
+	if err != nil {
+		t.Fatalf("login failed: %v", err)
+	}
+	second, err := svc.Refresh(ctx, first.RefreshToken)
+	if err != nil {
+		t.Fatalf("refresh failed: %v", err)
+	}
+	if second.RefreshToken == first.RefreshToken {
+		t.Error("refresh token was not rotated")
+	}
+
+	firstClaims := parseClaims(t, svc, first.AccessToken)
+	secondClaims := parseClaims(t, svc, second.AccessToken)
+	if secondClaims["username"] != "jan.kowalski" || secondClaims["sid"] != firstClaims["sid"] {
+		t.Errorf("refreshed token lost the session: %v", secondClaims)
+	}
+	if secondClaims["jti"] == firstClaims["jti"] {
+		t.Error("refreshed access token reused the jti")
+	}
+
+	if _, err := svc.Refresh(ctx, "not-a-token"); !errors.Is(err, ErrRefreshTokenInvalid) {
+		t.Errorf("expected ErrRefreshTokenInvalid, got %v", err)
+	}
+}
+
+func TestRefresh_ReuseRevokesFamily(t *testing.T) {
+	svc, _, _ := newTestService(t)
+	ctx := context.Background()
+
+	first, _ := svc.Login(ctx, "jan.kowalski", "correct horse")
+	second, err := svc.Refresh(ctx, first.RefreshToken)
+	if err != nil {
+		t.Fatalf("refresh failed: %v", err)
+	}
+
+	// An attacker replays the already rotated token.
+	if _, err := svc.Refresh(ctx, first.RefreshToken); !errors.Is(err, ErrRefreshTokenReused) {
+		t.Fatalf("expected ErrRefreshTokenReused, got %v", err)
+	}
+
+	// The legitimate client's current tokens are now dead as well.
+	if _, err := svc.Refresh(ctx, second.RefreshToken); !errors.Is(err, ErrRefreshTokenInvalid) {
+		t.Errorf("expected the family to be revoked, got %v", err)
+	}
+	jti := parseClaims(t, svc, second.AccessToken)["jti"].(string)
+	if revoked, _ := svc.IsRevoked(jti); !revoked {
+		t.Error("expected the family's access token to be revoked")
+	}
+}
+
+func TestRefresh_Expired(t *testing.T) {
+	svc, _, now := newTestService(t)
+	ctx := context.Background()
+
+	pair, _ := svc.Login(ctx, "jan.kowalski", "correct horse")
+	*now = now.Add(testConfig.RefreshTokenTTL)
+
+	if _, err := svc.Refresh(ctx, pair.RefreshToken); !errors.Is(err, ErrRefreshTokenInvalid) {
+		t.Errorf("expected ErrRefreshTokenInvalid, got %v", err)
+	}
+}
+
+func TestLogout_RevokesOnlyThatSession(t *testing.T) {
+	svc, _, _ := newTestService(t)
+	ctx := context.Background()
+
+	phone, _ := svc.Login(ctx, "jan.kowalski", "correct horse")
+	laptop, _ := svc.Login(ctx, "jan.kowalski", "correct horse")
+
+	phoneClaims := parseClaims(t, svc, phone.AccessToken)
+	if err := svc.Logout(ctx, phoneClaims["sid"].(string)); err != nil {
+		t.Fatalf("logout failed: %v", err)
+	}
+
+	if revoked, _ := svc.IsRevoked(phoneClaims["jti"].(string)); !revoked {
+		t.Error("expected the phone's access token to be revoked")
+	}
+	if _, err := svc.Refresh(ctx, phone.RefreshToken); !errors.Is(err, ErrRefreshTokenInvalid) {
+		t.Errorf("expected the phone's refresh token to be revoked, got %v", err)
+	}
+	if _, err := svc.Refresh(ctx, laptop.RefreshToken); err != nil {
+		t.Errorf("expected the laptop session to survive, got %v", err)
+	}
+}
+
+func TestLogoutAll(t *testing.T) {
+	svc, _, _ := newTestService(t)
+	ctx := context.Background()
+
+	phone, _ := svc.Login(ctx, "jan.kowalski", "correct horse")
+	laptop, _ := svc.Login(ctx, "jan.kowalski", "correct horse")
+
+	if err := svc.LogoutAll(ctx, "jan.kowalski"); err != nil {
+		t.Fatalf("logout all failed: %v", err)
+	}
+
+	for _, pair := range []*model.TokenPair{phone, laptop} {
+		if revoked, _ := svc.IsRevoked(parseClaims(t, svc, pair.AccessToken)["jti"].(string)); !revoked {
+			t.Error("expected every access token to be revoked")
+		}
+		if _, err := svc.Refresh(ctx, pair.RefreshToken); !errors.Is(err, ErrRefreshTokenInvalid) {
+			t.Errorf("expected every refresh token to be revoked, got %v", err)
+		}
+	}
+}
@@ -0,0 +1,149 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+
+	"go-web-server/internal/model"
+	"go-web-server/internal/repository"
+)
+
+var (
+	ErrRefreshTokenInvalid = repository.ErrRefreshTokenInvalid
+	ErrRefreshTokenReused  = repository.ErrRefreshTokenReused
+)
+
+// Refresh exchanges a refresh token for a new token pair. Every refresh token
+// can be used once; presenting one that was already exchanged means it leaked,
+// so the whole session is revoked and ErrRefreshTokenReused is returned.
+func (s *Service) Refresh(ctx context.Context, refreshToken string) (*model.TokenPair, error) {
+	if refreshToken == "" {
+		return nil, ErrRefreshTokenInvalid
+	}
+
+	pair, next, err := s.newTokenPair()
+	if err != nil {
+		return nil, err
+	}
+	if err := s.repo.RotateRefreshToken(hashToken(refreshToken), s.now(), next); err != nil {
+		if errors.Is(err, ErrRefreshTokenInvalid) || errors.Is(err, ErrRefreshTokenReused) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("failed to rotate refresh token: %w", err)
+	}
+
+	// The username and session are only known once the old token is found.
+	// The role is read again so that a revoked role ends with the access
+	// token rather than with the session.
+	user, err := s.repo.GetUserByUsername(next.Username)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load user: %w", err)
+	}
+	if user == nil {
+		return nil, ErrRefreshTokenInvalid
+	}
+	pair.AccessToken, err = s.signAccessToken(next, user.Role)
+	if err != nil {
+		return nil, err
+	}
+	pair.Token = pair.AccessToken
+	return pair, nil
+}
+
+// Logout ends the session the access token belongs to.
+func (s *Service) Logout(ctx context.Context, sessionID string) error {
+	if err := s.repo.RevokeTokenFamily(sessionID); err != nil {
+		return fmt.Errorf("failed to revoke session: %w", err)
+	}
+	return nil
+}
+
+// LogoutAll ends every session of username, e.g. after a phone was lost.
+func (s *Service) LogoutAll(ctx context.Context, username string) error {
+	if err := s.repo.RevokeUserTokens(username); err != nil {
+		return fmt.Errorf("failed to revoke sessions: %w", err)
+	}
+	return nil
+}
+
+// IsRevoked reports whether the access token with the given jti was revoked.
+// It lets Service act as the auth middleware's revocation list.
+func (s *Service) IsRevoked(jti string) (bool, error) {
+	return s.repo.IsTokenRevoked(jti)
+}
+
+func (s *Service) startSession(user *model.User) (*model.TokenPair, error) {
+	pair, first, err := s.newTokenPair()
+	if err != nil {
+		return nil, err
+	}
+	first.FamilyID = uuid.NewString()
+	first.Username = user.Username
+
+	if err := s.repo.CreateRefreshToken(first); err != nil {
+		return nil, fmt.Errorf("failed to store refresh token: %w", err)
+	}
+
+	pair.AccessToken, err = s.signAccessToken(first, user.Role)
+	if err != nil {
+		return nil, err
+	}
+	pair.Token = pair.AccessToken
+	return pair, nil
+}
+
+// newTokenPair generates a refresh token and the row describing it. The
+// access token is signed by the caller once the row's family is known.
+func (s *Service) newTokenPair() (*model.TokenPair, *model.RefreshToken, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return nil, nil, fmt.Errorf("failed to generate refresh token: %w", err)
+	}
+	refreshToken := base64.RawURLEncoding.EncodeToString(buf)
+
+	now := s.now()
+	row := &model.RefreshToken{
+		TokenHash:       hashToken(refreshToken),
+		AccessJTI:       uuid.NewString(),
+		AccessExpiresAt: now.Add(s.accessTTL),
+		ExpiresAt:       now.Add(s.refreshTTL),
+	}
+	pair := &model.TokenPair{
+		RefreshToken: refreshToken,
+		TokenType:    "Bearer",
+		ExpiresIn:    int(s.accessTTL.Seconds()),
+	}
+	return pair, row, nil
+}
+
+// signAccessToken signs the access token issued together with t for a user
+// with the given role. The sid claim names the session (token family) so that
+// logout can revoke it; the role claim is what admin-only routes check.
+func (s *Service) signAccessToken(t *model.RefreshToken, role string) (string, error) {
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"username": t.Username,
+		"role":     role,
+		"jti":      t.AccessJTI,
+		"sid":      t.FamilyID,
+		"iat":      s.now().Unix(),
+		"exp":      t.AccessExpiresAt.Unix(),
+	})
+	signed, err := token.SignedString(s.jwtKey)
+	if err != nil {
+		return "", fmt.Errorf("failed to sign access token: %w", err)
+	}
+	return signed, nil
+}
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
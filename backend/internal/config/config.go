@@ -0,0 +1,252 @@
+// Package config loads the server settings from environment variables.
+package config
+
+import (
+	"crypto/ed25519"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"go-web-server/services/account-service/money"
+)
+
+// MinJWTSecretLength is the shortest accepted HS256 signing key, in bytes.
+const MinJWTSecretLength = 32
+
+// Config holds the settings read by Load.
+type Config struct {
+	Port string
+
+	// JWTSecret signs and verifies access tokens. It is shared by the
+	// gateway login handler and the account-service auth middleware.
+	JWTSecret []byte
+	// AccessTokenTTL is kept short because access tokens are only revoked
+	// through the jti revocation list; RefreshTokenTTL bounds a login session.
+	AccessTokenTTL  time.Duration
+	RefreshTokenTTL time.Duration
+
+	// MaxFailedLogins consecutive wrong passwords lock the user out for
+	// LockoutDuration.
+	MaxFailedLogins int
+	LockoutDuration time.Duration
+
+	// EnableTestEndpoints registers /api/test/reset, which wipes the
+	// database. It must stay off outside of test environments.
+	EnableTestEndpoints bool
+
+	// FXRatesFile is an optional JSON file of exchange rates. Rates set by
+	// an admin override the file. FXQuoteTTL is how long an FX quote can be
+	// executed for.
+	FXRatesFile string
+	FXQuoteTTL  time.Duration
+	// AdminUsers may call admin-only endpoints such as /api/test/reset and
+	// maintain exchange rates through /api/v1/admin.
+	// MerchantUsers may capture and release holds on any account, as admins
+	// may. Both must name existing users: the roles are stored on the users
+	// at startup and the names cannot be registered.
+	AdminUsers    []string
+	MerchantUsers []string
+
+	// ReconcileInterval runs balance-versus-ledger reconciliation in the
+	// background; zero leaves it to the admin endpoint and cmd/reconcile.
+	// ReconcileAutoFreeze makes those background runs freeze the accounts
+	// they find discrepancies on.
+	ReconcileInterval   time.Duration
+	ReconcileAutoFreeze bool
+
+	// AuditSigningKey is the Ed25519 seed that signs ledger checkpoints;
+	// without it the hash chains can be verified but not checkpointed.
+	// AuditCheckpointInterval creates a checkpoint in the background.
+	AuditSigningKey         []byte
+	AuditCheckpointInterval time.Duration
+
+	// HoldTTL is how long a hold lasts when it is placed without an expiry.
+	// HoldSweepInterval is how often expired holds are marked as expired.
+	HoldTTL           time.Duration
+	HoldSweepInterval time.Duration
+
+	// ScheduledTransferInterval is how often due scheduled transfers are
+	// executed and the payments of standing orders scheduled.
+	ScheduledTransferInterval time.Duration
+	// CalendarFile is an optional JSON file of per-country business-day
+	// calendars, which replaces the built-in ones. CalendarCountry names the
+	// calendar that dates ledger entries and standing order payments.
+	CalendarFile    string
+	CalendarCountry string
+
+	// OverdraftInterestRate is the annual interest rate charged daily on
+	// negative balances; zero charges none.
+	OverdraftInterestRate money.Rate
+
+	// SavingsInterestRate is the annual interest rate accrued daily on
+	// savings balances under SavingsDayCount; zero accrues none.
+	SavingsInterestRate money.Rate
+	SavingsDayCount     money.DayCount
+}
+
+// Load reads the configuration from the environment:
+//
+//	PORT                   listen port (default 8080)
+//	JWT_SECRET             token signing key, required, at least 32 bytes
+//	JWT_ACCESS_TTL         access token lifetime (default 15m)
+//	JWT_REFRESH_TTL        refresh token lifetime (default 720h)
+//	AUTH_MAX_FAILED_LOGINS failed logins before lockout (default 5)
+//	AUTH_LOCKOUT_DURATION  lockout length (default 15m)
+//	ENABLE_TEST_ENDPOINTS  set to "true" to expose /api/test/reset
+//	FX_RATES_FILE          path of the exchange rates file (optional)
+//	FX_QUOTE_TTL           FX quote lifetime (default 30s)
+//	ADMIN_USERS            comma-separated usernames with admin access
+//	MERCHANT_USERS         comma-separated usernames that settle holds
+//	RECONCILE_INTERVAL     background reconciliation period (default off)
+//	RECONCILE_AUTO_FREEZE  set to "true" to freeze accounts that fail it
+//	AUDIT_SIGNING_KEY      base64 Ed25519 seed signing ledger checkpoints
+//	AUDIT_CHECKPOINT_INTERVAL background checkpoint period (default off)
+//	HOLD_TTL               default hold lifetime (default 168h)
+//	HOLD_SWEEP_INTERVAL    expired hold sweep period (default 1m)
+//	SCHEDULED_TRANSFER_INTERVAL scheduled transfer and standing order period (default 1m)
+//	CALENDAR_FILE          path of the business-day calendars file (optional)
+//	CALENDAR_COUNTRY       country whose calendar dates payments (default PL)
+//	OVERDRAFT_INTEREST_RATE annual rate on negative balances, e.g. 0.1825 (default off)
+//	SAVINGS_INTEREST_RATE  annual rate on savings balances, e.g. 0.035 (default off)
+//	SAVINGS_DAY_COUNT      savings day count convention, ACT/365 or 30/360 (default ACT/365)
+func Load() (*Config, error) {
+	cfg := &Config{
+		Port:                      getEnv("PORT", "8080"),
+		JWTSecret:                 []byte(os.Getenv("JWT_SECRET")),
+		AccessTokenTTL:            15 * time.Minute,
+		RefreshTokenTTL:           30 * 24 * time.Hour,
+		MaxFailedLogins:           5,
+		LockoutDuration:           15 * time.Minute,
+		FXRatesFile:               os.Getenv("FX_RATES_FILE"),
+		FXQuoteTTL:                30 * time.Second,
+		HoldTTL:                   7 * 24 * time.Hour,
+		HoldSweepInterval:         time.Minute,
+		ScheduledTransferInterval: time.Minute,
+		CalendarFile:              os.Getenv("CALENDAR_FILE"),
+		CalendarCountry:           strings.ToUpper(getEnv("CALENDAR_COUNTRY", "PL")),
+		SavingsDayCount:           money.Act365,
+	}
+
+	if len(cfg.JWTSecret) == 0 {
+		return nil, errors.New("JWT_SECRET is not set")
+	}
+	if len(cfg.JWTSecret) < MinJWTSecretLength {
+		return nil, fmt.Errorf("JWT_SECRET must be at least %d bytes long", MinJWTSecretLength)
+	}
+
+	var err error
+	if cfg.AccessTokenTTL, err = getDuration("JWT_ACCESS_TTL", cfg.AccessTokenTTL); err != nil {
+		return nil, err
+	}
+	if cfg.RefreshTokenTTL, err = getDuration("JWT_REFRESH_TTL", cfg.RefreshTokenTTL); err != nil {
+		return nil, err
+	}
+	if cfg.LockoutDuration, err = getDuration("AUTH_LOCKOUT_DURATION", cfg.LockoutDuration); err != nil {
+		return nil, err
+	}
+	if cfg.FXQuoteTTL, err = getDuration("FX_QUOTE_TTL", cfg.FXQuoteTTL); err != nil {
+		return nil, err
+	}
+	if cfg.ReconcileInterval, err = getDuration("RECONCILE_INTERVAL", 0); err != nil {
+		return nil, err
+	}
+	if cfg.AuditCheckpointInterval, err = getDuration("AUDIT_CHECKPOINT_INTERVAL", 0); err != nil {
+		return nil, err
+	}
+	if cfg.HoldTTL, err = getDuration("HOLD_TTL", cfg.HoldTTL); err != nil {
+		return nil, err
+	}
+	if cfg.HoldSweepInterval, err = getDuration("HOLD_SWEEP_INTERVAL", cfg.HoldSweepInterval); err != nil {
+		return nil, err
+	}
+	if cfg.ScheduledTransferInterval, err = getDuration("SCHEDULED_TRANSFER_INTERVAL", cfg.ScheduledTransferInterval); err != nil {
+		return nil, err
+	}
+	if len(cfg.CalendarCountry) != 2 {
+		return nil, fmt.Errorf("CALENDAR_COUNTRY must be a two-letter country code such as PL, got %q", cfg.CalendarCountry)
+	}
+	if v := os.Getenv("OVERDRAFT_INTEREST_RATE"); v != "" {
+		if cfg.OverdraftInterestRate, err = money.ParseRate(v); err != nil {
+			return nil, fmt.Errorf("OVERDRAFT_INTEREST_RATE must be a positive decimal such as 0.1825, got %q", v)
+		}
+	}
+	if v := os.Getenv("SAVINGS_INTEREST_RATE"); v != "" {
+		if cfg.SavingsInterestRate, err = money.ParseRate(v); err != nil {
+			return nil, fmt.Errorf("SAVINGS_INTEREST_RATE must be a positive decimal such as 0.035, got %q", v)
+		}
+	}
+	if v := os.Getenv("SAVINGS_DAY_COUNT"); v != "" {
+		if cfg.SavingsDayCount, err = money.ParseDayCount(v); err != nil {
+			return nil, fmt.Errorf("SAVINGS_DAY_COUNT must be ACT/365 or 30/360, got %q", v)
+		}
+	}
+	if v := os.Getenv("AUDIT_SIGNING_KEY"); v != "" {
+		key, err := base64.StdEncoding.DecodeString(v)
+		if err != nil || len(key) != ed25519.SeedSize {
+			return nil, fmt.Errorf("AUDIT_SIGNING_KEY must be a base64-encoded %d-byte Ed25519 seed", ed25519.SeedSize)
+		}
+		cfg.AuditSigningKey = key
+	}
+	if cfg.AuditCheckpointInterval > 0 && cfg.AuditSigningKey == nil {
+		return nil, errors.New("AUDIT_CHECKPOINT_INTERVAL requires AUDIT_SIGNING_KEY")
+	}
+	for _, u := range strings.Split(os.Getenv("ADMIN_USERS"), ",") {
+		if u = strings.TrimSpace(u); u != "" {
+			cfg.AdminUsers = append(cfg.AdminUsers, u)
+		}
+	}
+	for _, u := range strings.Split(os.Getenv("MERCHANT_USERS"), ",") {
+		if u = strings.TrimSpace(u); u != "" {
+			cfg.MerchantUsers = append(cfg.MerchantUsers, u)
+		}
+	}
+	for _, m := range cfg.MerchantUsers {
+		for _, a := range cfg.AdminUsers {
+			if m == a {
+				return nil, fmt.Errorf("%q is listed in both ADMIN_USERS and MERCHANT_USERS", m)
+			}
+		}
+	}
+	if v := os.Getenv("AUTH_MAX_FAILED_LOGINS"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			return nil, fmt.Errorf("AUTH_MAX_FAILED_LOGINS must be a positive integer, got %q", v)
+		}
+		cfg.MaxFailedLogins = n
+	}
+	if v := os.Getenv("ENABLE_TEST_ENDPOINTS"); v != "" {
+		if cfg.EnableTestEndpoints, err = strconv.ParseBool(v); err != nil {
+			return nil, fmt.Errorf("ENABLE_TEST_ENDPOINTS must be a boolean, got %q", v)
+		}
+	}
+	if v := os.Getenv("RECONCILE_AUTO_FREEZE"); v != "" {
+		if cfg.ReconcileAutoFreeze, err = strconv.ParseBool(v); err != nil {
+			return nil, fmt.Errorf("RECONCILE_AUTO_FREEZE must be a boolean, got %q", v)
+		}
+	}
+
+	return cfg, nil
+}
+
+func getEnv(key, fallback string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return fallback
+}
+
+func getDuration(key string, fallback time.Duration) (time.Duration, error) {
+	v := os.Getenv(key)
+	if v == "" {
+		return fallback, nil
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil || d <= 0 {
+		return 0, fmt.Errorf("%s must be a positive duration such as 15m, got %q", key, v)
+	}
+	return d, nil
+}
@@ -0,0 +1,101 @@
+package config
+
+import (
+	"testing"
+	"time"
+
+	"go-web-server/services/account-service/money"
+)
+
+func TestLoad_Defaults(t *testing.T) {
+	t.Setenv("JWT_SECRET", "test_signing_key_of_at_least_32_bytes")
+	t.Setenv("PORT", "")
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.Port != "8080" || cfg.AccessTokenTTL != 15*time.Minute || cfg.RefreshTokenTTL != 720*time.Hour || cfg.MaxFailedLogins != 5 || cfg.LockoutDuration != 15*time.Minute || cfg.EnableTestEndpoints || cfg.ReconcileInterval != 0 || cfg.AuditSigningKey != nil || cfg.AuditCheckpointInterval != 0 || cfg.HoldTTL != 168*time.Hour || cfg.HoldSweepInterval != time.Minute || cfg.ScheduledTransferInterval != time.Minute || !cfg.OverdraftInterestRate.IsZero() || !cfg.SavingsInterestRate.IsZero() || cfg.SavingsDayCount != money.Act365 || cfg.CalendarFile != "" || cfg.CalendarCountry != "PL" {
+		t.Errorf("unexpected defaults: %+v", cfg)
+	}
+}
+
+func TestLoad_Overrides(t *testing.T) {
+	t.Setenv("JWT_SECRET", "test_signing_key_of_at_least_32_bytes")
+	t.Setenv("AUTH_MAX_FAILED_LOGINS", "3")
+	t.Setenv("AUTH_LOCKOUT_DURATION", "1h")
+	t.Setenv("FX_QUOTE_TTL", "1m")
+	t.Setenv("ADMIN_USERS", "ops, treasury,")
+	t.Setenv("MERCHANT_USERS", "acquirer")
+	t.Setenv("RECONCILE_INTERVAL", "6h")
+	t.Setenv("RECONCILE_AUTO_FREEZE", "true")
+	t.Setenv("AUDIT_SIGNING_KEY", "AQEBAQEBAQEBAQEBAQEBAQEBAQEBAQEBAQEBAQEBAQE=")
+	t.Setenv("AUDIT_CHECKPOINT_INTERVAL", "24h")
+	t.Setenv("HOLD_TTL", "72h")
+	t.Setenv("HOLD_SWEEP_INTERVAL", "30s")
+	t.Setenv("SCHEDULED_TRANSFER_INTERVAL", "5m")
+	t.Setenv("OVERDRAFT_INTEREST_RATE", "0.1825")
+	t.Setenv("SAVINGS_INTEREST_RATE", "0.035")
+	t.Setenv("SAVINGS_DAY_COUNT", "30/360")
+	t.Setenv("CALENDAR_FILE", "calendars.json")
+	t.Setenv("CALENDAR_COUNTRY", "de")
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.MaxFailedLogins != 3 || cfg.LockoutDuration != time.Hour || cfg.FXQuoteTTL != time.Minute || cfg.ReconcileInterval != 6*time.Hour || !cfg.ReconcileAutoFreeze {
+		t.Errorf("overrides not applied: %+v", cfg)
+	}
+	if len(cfg.AuditSigningKey) != 32 || cfg.AuditSigningKey[0] != 1 || cfg.AuditCheckpointInterval != 24*time.Hour {
+		t.Errorf("overrides not applied: %+v", cfg)
+	}
+	if cfg.HoldTTL != 72*time.Hour || cfg.HoldSweepInterval != 30*time.Second || cfg.ScheduledTransferInterval != 5*time.Minute || cfg.OverdraftInterestRate.String() != "0.1825" {
+		t.Errorf("overrides not applied: %+v", cfg)
+	}
+	if cfg.SavingsInterestRate.String() != "0.0350" || cfg.SavingsDayCount != money.Thirty360 {
+		t.Errorf("overrides not applied: %+v", cfg)
+	}
+	if cfg.CalendarFile != "calendars.json" || cfg.CalendarCountry != "DE" {
+		t.Errorf("overrides not applied: %+v", cfg)
+	}
+	if len(cfg.AdminUsers) != 2 || cfg.AdminUsers[0] != "ops" || cfg.AdminUsers[1] != "treasury" {
+		t.Errorf("overrides not applied: %+v", cfg)
+	}
+	if len(cfg.MerchantUsers) != 1 || cfg.MerchantUsers[0] != "acquirer" {
+		t.Errorf("overrides not applied: %+v", cfg)
+	}
+}
+
+func TestLoad_Invalid(t *testing.T) {
+	cases := map[string]map[string]string{
+		"missing secret":       {"JWT_SECRET": ""},
+		"short secret":         {"JWT_SECRET": "too_short"},
+		"bad attempts":         {"AUTH_MAX_FAILED_LOGINS": "0"},
+		"bad duration":         {"AUTH_LOCKOUT_DURATION": "soon"},
+		"bad test flag":        {"ENABLE_TEST_ENDPOINTS": "maybe"},
+		"bad quote ttl":        {"FX_QUOTE_TTL": "-1s"},
+		"bad interval":         {"RECONCILE_INTERVAL": "0s"},
+		"bad freeze":           {"RECONCILE_AUTO_FREEZE": "sometimes"},
+		"bad audit key":        {"AUDIT_SIGNING_KEY": "c2hvcnQ="},
+		"unsigned checkpoints": {"AUDIT_CHECKPOINT_INTERVAL": "1h"},
+		"bad hold ttl":         {"HOLD_TTL": "0"},
+		"bad sweep interval":   {"HOLD_SWEEP_INTERVAL": "often"},
+		"bad interest rate":    {"OVERDRAFT_INTEREST_RATE": "18%"},
+		"bad savings rate":     {"SAVINGS_INTEREST_RATE": "-0.01"},
+		"bad day count":        {"SAVINGS_DAY_COUNT": "ACT/360"},
+		"bad calendar country": {"CALENDAR_COUNTRY": "Poland"},
+		"admin and merchant":   {"ADMIN_USERS": "ops", "MERCHANT_USERS": "acquirer,ops"},
+	}
+	for name, env := range cases {
+		t.Run(name, func(t *testing.T) {
+			t.Setenv("JWT_SECRET", "test_signing_key_of_at_least_32_bytes")
+			for k, v := range env {
+				t.Setenv(k, v)
+			}
+			if _, err := Load(); err == nil {
+				t.Error("expected an error")
+			}
+		})
+	}
+}
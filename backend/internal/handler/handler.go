@@ -2,28 +2,34 @@ package handler
 
 import (
 	"encoding/json"
+	"errors"
 	"log"
 	"net/http"
+	"strconv"
 	"strings"
 	"time"
 
-	"github.com/golang-jwt/jwt/v5"
-
+	"go-web-server/internal/auth"
 	"go-web-server/internal/model"
 	"go-web-server/internal/repository"
 	accModel "go-web-server/services/account-service/model"
+	"go-web-server/services/account-service/handler/middleware"
+	"go-web-server/services/account-service/handler/problem"
+	"go-web-server/services/account-service/money"
 	accService "go-web-server/services/account-service/service"
 )
 
 type Handler struct {
 	repo       *repository.PostgresRepository
 	accService accService.AccountService
+	auth       *auth.Service
 }
 
-func NewHandler(repo *repository.PostgresRepository, accService accService.AccountService) *Handler {
+func NewHandler(repo *repository.PostgresRepository, accService accService.AccountService, authService *auth.Service) *Handler {
 	return &Handler{
 		repo:       repo,
 		accService: accService,
+		auth:       authService,
 	}
 }
 
@@ -49,89 +55,165 @@ func (h *Handler) AccountHandler(w http.ResponseWriter, r *http.Request) {
 
 func (h *Handler) getAccount(w http.ResponseWriter, r *http.Request, userID string) {
 	if userID == "" {
-		http.Error(w, "Missing User ID", http.StatusBadRequest)
+		problem.Error(w, http.StatusBadRequest, problem.CodeValidation, "Missing User ID")
+		return
+	}
+	if !authorizeUser(w, r, userID) {
 		return
 	}
 
-	// Proxy to new AccountService
-	// Note: We need a way to find the account for this user.
-	// Since we are refactoring, we'll assume for now that we get the first account.
-	// In a real migration, we would have a lookup table or more sophisticated logic.
-	
-	// For now, we'll try to find an account for this customer via the new repo
-	// This is a temporary bridge.
-	acc, err := h.accService.GetAccount(r.Context(), userID) // Assuming userID here maps to accountID for simplicity in this bridge
-	if err != nil {
-		log.Printf("Service Error: %v", err)
-		http.Error(w, "Account Not Found", http.StatusNotFound)
+	customer, acc, ok := h.resolveAccount(w, r, userID)
+	if !ok {
 		return
 	}
 
-	h.sendJSON(w, http.StatusOK, h.mapToMonolithAccount(acc))
+	h.sendJSON(w, http.StatusOK, h.mapToMonolithAccount(customer, acc))
 }
 
 func (h *Handler) getTransactions(w http.ResponseWriter, r *http.Request, userID string) {
-	// For transactions, we would also proxy, but for this track, we'll stick to basic account operations
-	// and mark this as potentially legacy or requiring update.
-	rows, err := h.repo.GetTransactionsRaw(userID) // Using a more direct query
+	if !authorizeUser(w, r, userID) {
+		return
+	}
+	_, acc, ok := h.resolveAccount(w, r, userID)
+	if !ok {
+		return
+	}
+
+	rows, err := h.repo.GetTransactionsRaw(acc.ID.String()) // Using a more direct query
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		log.Printf("Error loading transactions for %s: %v", userID, err)
+		problem.Error(w, http.StatusInternalServerError, problem.CodeInternal, "An unexpected error occurred")
 		return
 	}
 	h.sendJSON(w, http.StatusOK, rows)
 }
 
+// authorizeUser checks that userID is the authenticated caller, writing 401 or
+// 403 otherwise. The routes using it must be wrapped in the auth middleware.
+func authorizeUser(w http.ResponseWriter, r *http.Request, userID string) bool {
+	principal, ok := middleware.PrincipalFromContext(r.Context())
+	if !ok {
+		problem.Error(w, http.StatusUnauthorized, problem.CodeUnauthorized, "Unauthorized")
+		return false
+	}
+	if principal.Username != userID {
+		problem.Error(w, http.StatusForbidden, problem.CodeForbidden, "Access to this user is not allowed")
+		return false
+	}
+	return true
+}
+
+// resolveAccount maps a user id (the username carried in the JWT, stored as
+// customers.external_id) to the customer and their primary account. On
+// failure it writes the error response and returns ok == false.
+func (h *Handler) resolveAccount(w http.ResponseWriter, r *http.Request, userID string) (*accModel.Customer, *accModel.Account, bool) {
+	customer, err := h.accService.GetCustomerByExternalID(r.Context(), userID)
+	if err != nil {
+		log.Printf("Service Error: %v", err)
+		problem.Write(w, problem.FromError(err))
+		return nil, nil, false
+	}
+
+	acc, err := h.accService.GetPrimaryAccount(r.Context(), customer.ID)
+	if err != nil {
+		log.Printf("Service Error: %v", err)
+		problem.Write(w, problem.FromError(err))
+		return nil, nil, false
+	}
+
+	return customer, acc, true
+}
+
 func (h *Handler) TransactionHandler(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
-		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+		problem.Error(w, http.StatusMethodNotAllowed, problem.CodeMethodNotAllowed, "Method Not Allowed")
 		return
 	}
 
 	var req model.TransactionRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		problem.Error(w, http.StatusBadRequest, problem.CodeMalformedRequest, "Invalid JSON")
+		return
+	}
+
+	if req.Amount.Sign() <= 0 {
+		validationError(w, "amount", errors.New("amount must be positive"))
+		return
+	}
+
+	// user_id may be omitted; it defaults to the authenticated user.
+	if req.UserID == "" {
+		if principal, ok := middleware.PrincipalFromContext(r.Context()); ok {
+			req.UserID = principal.Username
+		}
+	}
+	if !authorizeUser(w, r, req.UserID) {
+		return
+	}
+
+	// The legacy API carries no currency, so the amount is taken to be in
+	// the currency of the user's primary account.
+	customer, acc, ok := h.resolveAccount(w, r, req.UserID)
+	if !ok {
 		return
 	}
 
 	// Proxy to new AccountService for balance updates
 	var entryType accModel.LedgerEntryType
-	finalAmount := req.Amount
+	finalAmount, err := money.NewExact(req.Amount, acc.Currency)
+	if err != nil {
+		validationError(w, "amount", err)
+		return
+	}
 
 	if req.Type == model.Deposit {
 		entryType = accModel.Deposit
 	} else {
 		entryType = accModel.Withdrawal
-		finalAmount = -req.Amount
+		finalAmount = finalAmount.Neg()
 	}
 
-	// We assume req.UserID is the accountID for this bridge
-	err := h.accService.UpdateBalance(r.Context(), req.UserID, finalAmount, entryType, "Legacy Transaction Proxy")
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+	key := r.Header.Get("Idempotency-Key")
+	if len(key) > accService.MaxIdempotencyKeyLength {
+		validationError(w, "Idempotency-Key", errors.New("Idempotency-Key is too long"))
+		return
+	}
+	principal, _ := middleware.PrincipalFromContext(r.Context())
+	ctx := accService.WithIdempotencyKey(r.Context(), principal.Username, key)
+
+	if _, err := h.accService.UpdateBalance(ctx, acc.ID.String(), finalAmount, entryType, "Legacy Transaction Proxy"); err != nil {
+		problem.Write(w, problem.FromError(err))
 		return
 	}
 
-	// Get updated account to return
-	acc, _ := h.accService.GetAccount(r.Context(), req.UserID)
-	h.sendJSON(w, http.StatusOK, h.mapToMonolithAccount(acc))
+	// The withdrawal entry's balance does not include the withdrawal fee
+	// booked after it, so the account is read again for the final balance.
+	acc, err = h.accService.GetAccount(r.Context(), acc.ID.String())
+	if err != nil {
+		problem.Write(w, problem.FromError(err))
+		return
+	}
+	h.sendJSON(w, http.StatusOK, h.mapToMonolithAccount(customer, acc))
 }
 
-func (h *Handler) mapToMonolithAccount(acc *accModel.Account) *model.Account {
+func (h *Handler) mapToMonolithAccount(customer *accModel.Customer, acc *accModel.Account) *model.Account {
 	if acc == nil {
 		return nil
 	}
 	
 	return &model.Account{
 		ID:        acc.ID.String(),
-		UserID:    acc.CustomerID.String(),
+		UserID:    customer.ExternalID,
 		Balance:   acc.Balance,
+		Currency:  acc.Currency,
 		CreatedAt: acc.CreatedAt,
 	}
 }
 
 func (h *Handler) ResetHandler(w http.ResponseWriter, r *http.Request) {
 	if err := h.repo.ResetDB(); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		log.Printf("Reset Error: %v", err)
+		problem.Error(w, http.StatusInternalServerError, problem.CodeInternal, "An unexpected error occurred")
 		return
 	}
 	w.WriteHeader(http.StatusOK)
@@ -145,28 +227,172 @@ func (h *Handler) sendJSON(w http.ResponseWriter, code int, data interface{}) {
 }
 
 // Auth Logic
-var jwtKey = []byte("my_secret_key_for_testing_only")
-
 func (h *Handler) LoginHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		problem.Error(w, http.StatusMethodNotAllowed, problem.CodeMethodNotAllowed, "Method Not Allowed")
+		return
+	}
+
 	var creds struct {
 		Username string `json:"username"`
 		Password string `json:"password"`
 	}
 	if err := json.NewDecoder(r.Body).Decode(&creds); err != nil {
-		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		problem.Error(w, http.StatusBadRequest, problem.CodeMalformedRequest, "Invalid JSON")
+		return
+	}
+
+	pair, err := h.auth.Login(r.Context(), creds.Username, creds.Password)
+	if err != nil {
+		h.authError(w, err)
+		return
+	}
+	h.sendJSON(w, http.StatusOK, pair)
+}
+
+func (h *Handler) RefreshHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		problem.Error(w, http.StatusMethodNotAllowed, problem.CodeMethodNotAllowed, "Method Not Allowed")
 		return
 	}
 
-	if creds.Username != "test_user" || creds.Password != "password123" {
-		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+	var req struct {
+		RefreshToken string `json:"refresh_token"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		problem.Error(w, http.StatusBadRequest, problem.CodeMalformedRequest, "Invalid JSON")
+		return
+	}
+
+	pair, err := h.auth.Refresh(r.Context(), req.RefreshToken)
+	if err != nil {
+		h.authError(w, err)
+		return
+	}
+	h.sendJSON(w, http.StatusOK, pair)
+}
+
+// LogoutHandler must be wrapped in the auth middleware; it ends the session
+// of the presented access token, revoking its refresh token too.
+func (h *Handler) LogoutHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		problem.Error(w, http.StatusMethodNotAllowed, problem.CodeMethodNotAllowed, "Method Not Allowed")
+		return
+	}
+
+	principal, ok := middleware.PrincipalFromContext(r.Context())
+	if !ok || principal.SessionID == "" {
+		problem.Error(w, http.StatusUnauthorized, problem.CodeUnauthorized, "Unauthorized")
+		return
+	}
+
+	if err := h.auth.Logout(r.Context(), principal.SessionID); err != nil {
+		h.authError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// LogoutAllHandler must be wrapped in the auth middleware; it ends every
+// session of the authenticated user.
+func (h *Handler) LogoutAllHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		problem.Error(w, http.StatusMethodNotAllowed, problem.CodeMethodNotAllowed, "Method Not Allowed")
 		return
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
-		"username": creds.Username,
-		"exp":      time.Now().Add(24 * time.Hour).Unix(),
-	})
+	principal, ok := middleware.PrincipalFromContext(r.Context())
+	if !ok {
+		problem.Error(w, http.StatusUnauthorized, problem.CodeUnauthorized, "Unauthorized")
+		return
+	}
+
+	if err := h.auth.LogoutAll(r.Context(), principal.Username); err != nil {
+		h.authError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *Handler) RegisterHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		problem.Error(w, http.StatusMethodNotAllowed, problem.CodeMethodNotAllowed, "Method Not Allowed")
+		return
+	}
+
+	var req model.RegisterRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		problem.Error(w, http.StatusBadRequest, problem.CodeMalformedRequest, "Invalid JSON")
+		return
+	}
+
+	user, err := h.auth.Register(r.Context(), req)
+	if err != nil {
+		h.authError(w, err)
+		return
+	}
+	h.sendJSON(w, http.StatusCreated, user)
+}
+
+// ChangePasswordHandler must be wrapped in the auth middleware; it changes
+// the password of the user the bearer token was issued to.
+func (h *Handler) ChangePasswordHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		problem.Error(w, http.StatusMethodNotAllowed, problem.CodeMethodNotAllowed, "Method Not Allowed")
+		return
+	}
+
+	principal, ok := middleware.PrincipalFromContext(r.Context())
+	if !ok {
+		problem.Error(w, http.StatusUnauthorized, problem.CodeUnauthorized, "Unauthorized")
+		return
+	}
+
+	var req model.ChangePasswordRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		problem.Error(w, http.StatusBadRequest, problem.CodeMalformedRequest, "Invalid JSON")
+		return
+	}
+
+	if err := h.auth.ChangePassword(r.Context(), principal.Username, req); err != nil {
+		h.authError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *Handler) authError(w http.ResponseWriter, err error) {
+	var locked *auth.LockedError
+	switch {
+	case errors.As(err, &locked):
+		retryAfter := int(time.Until(locked.Until).Seconds()) + 1
+		w.Header().Set("Retry-After", strconv.Itoa(retryAfter))
+		problem.Error(w, http.StatusTooManyRequests, problem.CodeLoginLocked, err.Error())
+	case errors.Is(err, auth.ErrInvalidCredentials):
+		problem.Error(w, http.StatusUnauthorized, problem.CodeInvalidCredentials, err.Error())
+	case errors.Is(err, auth.ErrRefreshTokenReused):
+		problem.Error(w, http.StatusUnauthorized, problem.CodeRefreshTokenReused, "Refresh token was already used; the session has been ended")
+	case errors.Is(err, auth.ErrRefreshTokenInvalid):
+		problem.Error(w, http.StatusUnauthorized, problem.CodeRefreshTokenInvalid, "Refresh token is invalid or expired")
+	case errors.Is(err, auth.ErrUsernameTaken), errors.Is(err, auth.ErrUsernameReserved):
+		problem.Error(w, http.StatusConflict, problem.CodeUsernameTaken, err.Error())
+	case errors.Is(err, auth.ErrInvalidUsername):
+		validationError(w, "username", err)
+	case errors.Is(err, auth.ErrMissingFullName):
+		validationError(w, "full_name", err)
+	case errors.Is(err, auth.ErrPasswordUnchanged):
+		validationError(w, "new_password", err)
+	case errors.Is(err, auth.ErrWeakPassword):
+		validationError(w, "", err)
+	default:
+		log.Printf("Auth Error: %v", err)
+		problem.Error(w, http.StatusInternalServerError, problem.CodeInternal, "An unexpected error occurred")
+	}
+}
 
-	tokenString, _ := token.SignedString(jwtKey)
-	h.sendJSON(w, http.StatusOK, map[string]string{"token": tokenString})
+// validationError writes a 400 problem naming the offending request field.
+func validationError(w http.ResponseWriter, field string, err error) {
+	p := problem.New(http.StatusBadRequest, problem.CodeValidation, err.Error())
+	p.Field = field
+	problem.Write(w, p)
 }
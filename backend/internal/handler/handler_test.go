@@ -1,14 +1,20 @@
 package handler
 
 import (
+	"encoding/json"
 	"net/http"
 	"net/http/httptest"
 	"strings"
 	"testing"
+	"time"
+
+	"go-web-server/internal/auth"
+	"go-web-server/services/account-service/handler/middleware"
+	"go-web-server/services/account-service/handler/problem"
 )
 
 func TestStatusHandler(t *testing.T) {
-	h := NewHandler(nil, nil) // Repo i Service nie są potrzebne dla StatusHandler
+	h := NewHandler(nil, nil, nil) // Repo i Service nie są potrzebne dla StatusHandler
 	req, err := http.NewRequest("GET", "/api/status", nil)
 	if err != nil {
 		t.Fatal(err)
@@ -30,7 +36,7 @@ handler.ServeHTTP(rr, req)
 }
 
 func TestTransactionHandler_InvalidMethod(t *testing.T) {
-	h := NewHandler(nil, nil)
+	h := NewHandler(nil, nil, nil)
 	req, _ := http.NewRequest("GET", "/api/transactions", nil)
 	rr := httptest.NewRecorder()
 
@@ -42,7 +48,7 @@ func TestTransactionHandler_InvalidMethod(t *testing.T) {
 }
 
 func TestTransactionHandler_MalformedJSON(t *testing.T) {
-	h := NewHandler(nil, nil)
+	h := NewHandler(nil, nil, nil)
 	req, _ := http.NewRequest("POST", "/api/transactions", strings.NewReader(`{invalid json}`))
 	rr := httptest.NewRecorder()
 
@@ -54,7 +60,7 @@ func TestTransactionHandler_MalformedJSON(t *testing.T) {
 }
 
 func TestAccountHandler_MissingUserID(t *testing.T) {
-	h := NewHandler(nil, nil)
+	h := NewHandler(nil, nil, nil)
 	req, _ := http.NewRequest("GET", "/api/account/", nil)
 	rr := httptest.NewRecorder()
 
@@ -65,4 +71,126 @@ func TestAccountHandler_MissingUserID(t *testing.T) {
 	}
 }
 
+func TestTransactionHandler_NonPositiveAmount(t *testing.T) {
+	h := NewHandler(nil, nil, nil)
+	req, _ := http.NewRequest("POST", "/api/transactions", strings.NewReader(`{"user_id":"test_user","type":"DEPOSIT","amount":"-5.00"}`))
+	rr := httptest.NewRecorder()
+
+	h.TransactionHandler(rr, req)
+
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("expected status 400, got %d", rr.Code)
+	}
+}
+
+func TestLoginHandler_MalformedJSON(t *testing.T) {
+	h := NewHandler(nil, nil, nil)
+	req, _ := http.NewRequest("POST", "/api/login", strings.NewReader(`{invalid json}`))
+	rr := httptest.NewRecorder()
+
+	h.LoginHandler(rr, req)
+
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("expected status 400, got %d", rr.Code)
+	}
+}
+
+func TestChangePasswordHandler_RequiresAuthenticatedUser(t *testing.T) {
+	h := NewHandler(nil, nil, nil)
+	req, _ := http.NewRequest("POST", "/api/password", strings.NewReader(`{"current_password":"a","new_password":"b"}`))
+	rr := httptest.NewRecorder()
+
+	h.ChangePasswordHandler(rr, req)
+
+	if rr.Code != http.StatusUnauthorized {
+		t.Errorf("expected status 401, got %d", rr.Code)
+	}
+}
+
+func TestLogoutHandler_RequiresSession(t *testing.T) {
+	h := NewHandler(nil, nil, nil)
+	req, _ := http.NewRequest("POST", "/api/logout", nil)
+	rr := httptest.NewRecorder()
+
+	h.LogoutHandler(rr, req)
+
+	if rr.Code != http.StatusUnauthorized {
+		t.Errorf("expected status 401, got %d", rr.Code)
+	}
+}
+
+func TestAccountHandler_OtherUsersAccountIsForbidden(t *testing.T) {
+	h := NewHandler(nil, nil, nil)
+	for _, path := range []string{"/api/account/someone_else", "/api/account/someone_else/transactions"} {
+		req, _ := http.NewRequest("GET", path, nil)
+		req = req.WithContext(middleware.WithPrincipal(req.Context(), middleware.Principal{Username: "test_user"}))
+		rr := httptest.NewRecorder()
+
+		h.AccountHandler(rr, req)
+
+		if rr.Code != http.StatusForbidden {
+			t.Errorf("%s: expected status 403, got %d", path, rr.Code)
+		}
+	}
+}
+
+func TestAccountHandler_RequiresAuthenticatedUser(t *testing.T) {
+	h := NewHandler(nil, nil, nil)
+	req, _ := http.NewRequest("GET", "/api/account/test_user", nil)
+	rr := httptest.NewRecorder()
+
+	h.AccountHandler(rr, req)
 
+	if rr.Code != http.StatusUnauthorized {
+		t.Errorf("expected status 401, got %d", rr.Code)
+	}
+}
+
+func TestTransactionHandler_OtherUsersAccountIsForbidden(t *testing.T) {
+	h := NewHandler(nil, nil, nil)
+	req, _ := http.NewRequest("POST", "/api/transactions", strings.NewReader(`{"user_id":"someone_else","type":"WITHDRAWAL","amount":"5.00"}`))
+	req = req.WithContext(middleware.WithPrincipal(req.Context(), middleware.Principal{Username: "test_user"}))
+	rr := httptest.NewRecorder()
+
+	h.TransactionHandler(rr, req)
+
+	if rr.Code != http.StatusForbidden {
+		t.Errorf("expected status 403, got %d", rr.Code)
+	}
+}
+
+func TestAuthError_ProblemCodes(t *testing.T) {
+	h := NewHandler(nil, nil, nil)
+	for _, tc := range []struct {
+		err    error
+		status int
+		code   problem.Code
+		field  string
+	}{
+		{auth.ErrInvalidCredentials, http.StatusUnauthorized, problem.CodeInvalidCredentials, ""},
+		{&auth.LockedError{Until: time.Now().Add(time.Minute)}, http.StatusTooManyRequests, problem.CodeLoginLocked, ""},
+		{auth.ErrRefreshTokenReused, http.StatusUnauthorized, problem.CodeRefreshTokenReused, ""},
+		{auth.ErrRefreshTokenInvalid, http.StatusUnauthorized, problem.CodeRefreshTokenInvalid, ""},
+		{auth.ErrUsernameTaken, http.StatusConflict, problem.CodeUsernameTaken, ""},
+		{auth.ErrUsernameReserved, http.StatusConflict, problem.CodeUsernameTaken, ""},
+		{auth.ErrInvalidUsername, http.StatusBadRequest, problem.CodeValidation, "username"},
+		{auth.ErrPasswordUnchanged, http.StatusBadRequest, problem.CodeValidation, "new_password"},
+	} {
+		rr := httptest.NewRecorder()
+		h.authError(rr, tc.err)
+
+		if rr.Code != tc.status {
+			t.Errorf("%v: expected status %d, got %d", tc.err, tc.status, rr.Code)
+		}
+		if ct := rr.Header().Get("Content-Type"); ct != problem.ContentType {
+			t.Errorf("%v: expected Content-Type %s, got %s", tc.err, problem.ContentType, ct)
+		}
+		var p problem.Problem
+		if err := json.NewDecoder(rr.Body).Decode(&p); err != nil {
+			t.Fatalf("%v: decode problem: %v", tc.err, err)
+		}
+		if p.Code != tc.code || p.Field != tc.field {
+			t.Errorf("%v: expected code %s field %q, got %s %q", tc.err, tc.code, tc.field, p.Code, p.Field)
+		}
+	}
+}
@@ -1,13 +1,18 @@
 package model
 
-import "time"
+import (
+	"time"
+
+	"go-web-server/services/account-service/money"
+)
 
 // Account reprezentuje konto użytkownika w systemie.
 type Account struct {
-	ID        string    `json:"id"`
-	UserID    string    `json:"user_id"`
-	Balance   float64   `json:"balance"`
-	CreatedAt time.Time `json:"created_at"`
+	ID        string         `json:"id"`
+	UserID    string         `json:"user_id"`
+	Balance   money.Amount   `json:"balance"`
+	Currency  money.Currency `json:"currency,omitempty"`
+	CreatedAt time.Time      `json:"created_at"`
 }
 
 // TransactionType definiuje typ transakcji (Deposit/Withdraw).
@@ -23,7 +28,7 @@ type Transaction struct {
 	ID        string          `json:"id"`
 	AccountID string          `json:"account_id"`
 	Type      TransactionType `json:"type"`
-	Amount    float64         `json:"amount"`
+	Amount    money.Amount    `json:"amount"`
 	CreatedAt time.Time       `json:"created_at"`
 }
 
@@ -31,5 +36,66 @@ type Transaction struct {
 type TransactionRequest struct {
 	UserID string          `json:"user_id"`
 	Type   TransactionType `json:"type"`
-	Amount float64         `json:"amount"`
-}
\ No newline at end of file
+	Amount money.Amount    `json:"amount"`
+}
+// Role użytkowników. Rola jest zapisana w users.role, nadawana przy starcie
+// serwera z ADMIN_USERS i MERCHANT_USERS i przenoszona w tokenie dostępu
+// jako claim "role".
+const (
+	RoleCustomer = "customer"
+	RoleAdmin    = "admin"
+	RoleMerchant = "merchant"
+)
+
+// User reprezentuje dane logowania klienta. Username jest równy
+// customers.external_id w account-service.
+type User struct {
+	ID                string     `json:"id"`
+	Username          string     `json:"username"`
+	Role              string     `json:"role"`
+	PasswordHash      string     `json:"-"`
+	FailedAttempts    int        `json:"-"`
+	LockedUntil       *time.Time `json:"-"`
+	PasswordChangedAt time.Time  `json:"-"`
+	CreatedAt         time.Time  `json:"created_at"`
+}
+
+// RegisterRequest reprezentuje dane wejściowe rejestracji nowego użytkownika.
+type RegisterRequest struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+	FullName string `json:"full_name"`
+}
+
+// ChangePasswordRequest reprezentuje dane wejściowe zmiany hasła.
+type ChangePasswordRequest struct {
+	CurrentPassword string `json:"current_password"`
+	NewPassword     string `json:"new_password"`
+}
+
+// RefreshToken reprezentuje zapisany token odświeżający. Sam token nigdy nie
+// trafia do bazy, przechowywany jest tylko jego skrót SHA-256. Tokeny z jednej
+// sesji logowania dzielą FamilyID; AccessJTI to identyfikator tokena dostępu
+// wydanego razem z tym tokenem.
+type RefreshToken struct {
+	ID              string
+	FamilyID        string
+	Username        string
+	TokenHash       string
+	AccessJTI       string
+	AccessExpiresAt time.Time
+	ExpiresAt       time.Time
+	UsedAt          *time.Time
+	RevokedAt       *time.Time
+	CreatedAt       time.Time
+}
+
+// TokenPair to odpowiedź logowania i odświeżenia tokena. Token powiela
+// AccessToken dla starszych klientów.
+type TokenPair struct {
+	Token        string `json:"token"`
+	AccessToken  string `json:"access_token"`
+	RefreshToken string `json:"refresh_token"`
+	TokenType    string `json:"token_type"`
+	ExpiresIn    int    `json:"expires_in"`
+}
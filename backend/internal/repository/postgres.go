@@ -5,12 +5,29 @@ import (
 	"errors"
 	"fmt"
 	"os"
+	"sort"
+	"time"
 
 	"go-web-server/internal/model"
+	"go-web-server/services/account-service/money"
 
-	_ "github.com/lib/pq" // Sterownik PostgreSQL
+	"github.com/lib/pq" // Sterownik PostgreSQL
 )
 
+var (
+	// ErrUsernameTaken oznacza, że istnieje już klient o podanym external_id.
+	ErrUsernameTaken = errors.New("username already taken")
+	// ErrRefreshTokenInvalid oznacza nieznany, wygasły lub unieważniony token odświeżający.
+	ErrRefreshTokenInvalid = errors.New("invalid refresh token")
+	// ErrRefreshTokenReused oznacza ponowne użycie już wymienionego tokena.
+	// Cała rodzina tokenów zostaje wtedy unieważniona.
+	ErrRefreshTokenReused = errors.New("refresh token reuse detected")
+	// ErrUnknownUser oznacza, że użytkownik, któremu nadawana jest rola, nie istnieje.
+	ErrUnknownUser = errors.New("unknown user")
+)
+
+const userColumns = `id, username, role, password_hash, failed_attempts, locked_until, password_changed_at, created_at`
+
 type PostgresRepository struct {
 	db *sql.DB
 }
@@ -91,12 +108,15 @@ func (r *PostgresRepository) CreateTransaction(req model.TransactionRequest) (*m
 
 	newBalance := account.Balance
 	if req.Type == model.Deposit {
-		newBalance += req.Amount
+		newBalance, err = newBalance.Add(req.Amount)
 	} else if req.Type == model.Withdraw {
-		if account.Balance < req.Amount {
+		if account.Balance.Cmp(req.Amount) < 0 {
 			return nil, errors.New("niewystarczające środki na koncie")
 		}
-		newBalance -= req.Amount
+		newBalance, err = newBalance.Sub(req.Amount)
+	}
+	if err != nil {
+		return nil, err
 	}
 
 	_, err = tx.Exec(`UPDATE accounts SET balance = $1 WHERE id = $2`, newBalance, account.ID)
@@ -146,11 +166,267 @@ func (r *PostgresRepository) GetTransactionsRaw(accountID string) ([]model.Trans
 	return transactions, nil
 }
 
+// CreateUser zakłada klienta (customers) i jego dane logowania (users) w jednej
+// transakcji. Username staje się external_id klienta.
+func (r *PostgresRepository) CreateUser(user *model.User, fullName string) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(`INSERT INTO customers (external_id, full_name) VALUES ($1, $2)`, user.Username, fullName)
+	if err != nil {
+		return mapUniqueViolation(err)
+	}
+
+	err = tx.QueryRow(`INSERT INTO users (username, password_hash) VALUES ($1, $2) RETURNING id, role, password_changed_at, created_at`,
+		user.Username, user.PasswordHash).Scan(&user.ID, &user.Role, &user.PasswordChangedAt, &user.CreatedAt)
+	if err != nil {
+		return mapUniqueViolation(err)
+	}
+
+	return tx.Commit()
+}
+
+func (r *PostgresRepository) GetUserByUsername(username string) (*model.User, error) {
+	query := `SELECT ` + userColumns + ` FROM users WHERE username = $1`
+	user, err := scanUser(r.db.QueryRow(query, username))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return user, nil
+}
+
+// RecordFailedLogin zwiększa licznik nieudanych logowań i blokuje konto do
+// lockUntil, gdy licznik osiągnie maxAttempts. Licznik liczony jest od nowa
+// po wygaśnięciu poprzedniej blokady. Całość to jedno polecenie UPDATE, więc
+// równoległe próby nie gubią inkrementacji.
+func (r *PostgresRepository) RecordFailedLogin(username string, now time.Time, maxAttempts int, lockUntil time.Time) (*model.User, error) {
+	query := `UPDATE users SET
+			failed_attempts = CASE WHEN locked_until <= $2 THEN 1 ELSE failed_attempts + 1 END,
+			locked_until = CASE
+				WHEN (CASE WHEN locked_until <= $2 THEN 1 ELSE failed_attempts + 1 END) >= $3 THEN $4
+				WHEN locked_until <= $2 THEN NULL
+				ELSE locked_until
+			END
+		WHERE username = $1
+		RETURNING ` + userColumns
+	return scanUser(r.db.QueryRow(query, username, now, maxAttempts, lockUntil))
+}
+
+// ResetFailedLogins zeruje licznik po udanym logowaniu.
+func (r *PostgresRepository) ResetFailedLogins(username string) error {
+	_, err := r.db.Exec(`UPDATE users SET failed_attempts = 0, locked_until = NULL WHERE username = $1`, username)
+	return err
+}
+
+// UpdatePassword zapisuje nowy hash hasła, zdejmuje ewentualną blokadę i w tej
+// samej transakcji kończy wszystkie sesje użytkownika, tak aby tokeny wydane
+// przed zmianą hasła przestały działać.
+func (r *PostgresRepository) UpdatePassword(username, passwordHash string) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	res, err := tx.Exec(`UPDATE users SET password_hash = $1, password_changed_at = NOW(), failed_attempts = 0, locked_until = NULL
+		WHERE username = $2`, passwordHash, username)
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return sql.ErrNoRows
+	}
+	if err := revokeTokens(tx, `username = $1`, username); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// AssignRoles nadaje role z mapy roles (username -> rola), a wszystkim
+// pozostałym użytkownikom przywraca rolę klienta, w jednej transakcji. Jeśli
+// któregoś z wymienionych użytkowników nie ma, nic nie jest zmieniane i
+// zwracany jest ErrUnknownUser.
+func (r *PostgresRepository) AssignRoles(roles map[string]string) error {
+	usernames := make([]string, 0, len(roles))
+	for username := range roles {
+		usernames = append(usernames, username)
+	}
+	sort.Strings(usernames)
+
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`UPDATE users SET role = $1 WHERE role <> $1`, model.RoleCustomer); err != nil {
+		return err
+	}
+	for _, username := range usernames {
+		res, err := tx.Exec(`UPDATE users SET role = $1 WHERE username = $2`, roles[username], username)
+		if err != nil {
+			return err
+		}
+		n, err := res.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if n == 0 {
+			return fmt.Errorf("%w: %s", ErrUnknownUser, username)
+		}
+	}
+	return tx.Commit()
+}
+
+// CreateRefreshToken zapisuje pierwszy token nowej sesji logowania.
+func (r *PostgresRepository) CreateRefreshToken(t *model.RefreshToken) error {
+	return insertRefreshToken(r.db, t)
+}
+
+// RotateRefreshToken wymienia token o skrócie oldHash na next w jednej
+// transakcji. Stary wiersz jest blokowany (FOR UPDATE), więc z dwóch
+// równoległych wymian tego samego tokena powiedzie się tylko jedna. Użycie
+// tokena, który został już wymieniony, unieważnia całą rodzinę i zwraca
+// ErrRefreshTokenReused.
+func (r *PostgresRepository) RotateRefreshToken(oldHash string, now time.Time, next *model.RefreshToken) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var familyID, username string
+	var expiresAt time.Time
+	var usedAt, revokedAt sql.NullTime
+	err = tx.QueryRow(`SELECT family_id, username, expires_at, used_at, revoked_at FROM refresh_tokens WHERE token_hash = $1 FOR UPDATE`, oldHash).
+		Scan(&familyID, &username, &expiresAt, &usedAt, &revokedAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return ErrRefreshTokenInvalid
+		}
+		return err
+	}
+
+	if usedAt.Valid {
+		if err := revokeTokens(tx, `family_id = $1`, familyID); err != nil {
+			return err
+		}
+		if err := tx.Commit(); err != nil {
+			return err
+		}
+		return ErrRefreshTokenReused
+	}
+	if revokedAt.Valid || !now.Before(expiresAt) {
+		return ErrRefreshTokenInvalid
+	}
+
+	if _, err := tx.Exec(`UPDATE refresh_tokens SET used_at = $1 WHERE token_hash = $2`, now, oldHash); err != nil {
+		return err
+	}
+
+	next.FamilyID = familyID
+	next.Username = username
+	if err := insertRefreshToken(tx, next); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// RevokeTokenFamily kończy jedną sesję logowania: unieważnia jej tokeny
+// odświeżające i dopisuje tokeny dostępu do listy odwołanych.
+func (r *PostgresRepository) RevokeTokenFamily(familyID string) error {
+	return r.revokeInTx(`family_id = $1`, familyID)
+}
+
+// RevokeUserTokens kończy wszystkie sesje użytkownika.
+func (r *PostgresRepository) RevokeUserTokens(username string) error {
+	return r.revokeInTx(`username = $1`, username)
+}
+
+// IsTokenRevoked sprawdza, czy token dostępu o danym jti został odwołany.
+func (r *PostgresRepository) IsTokenRevoked(jti string) (bool, error) {
+	var revoked bool
+	err := r.db.QueryRow(`SELECT EXISTS (SELECT 1 FROM revoked_tokens WHERE jti = $1)`, jti).Scan(&revoked)
+	return revoked, err
+}
+
+func (r *PostgresRepository) revokeInTx(where string, arg interface{}) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := revokeTokens(tx, where, arg); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// revokeTokens unieważnia tokeny odświeżające spełniające warunek where i
+// odwołuje wydane z nimi, jeszcze ważne tokeny dostępu. Wpisy, których tokeny
+// już wygasły, są przy okazji usuwane z listy odwołanych.
+func revokeTokens(tx *sql.Tx, where string, arg interface{}) error {
+	if _, err := tx.Exec(`UPDATE refresh_tokens SET revoked_at = NOW() WHERE revoked_at IS NULL AND `+where, arg); err != nil {
+		return err
+	}
+	_, err := tx.Exec(`INSERT INTO revoked_tokens (jti, expires_at)
+		SELECT access_jti, access_expires_at FROM refresh_tokens WHERE access_expires_at > NOW() AND `+where+`
+		ON CONFLICT (jti) DO NOTHING`, arg)
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec(`DELETE FROM revoked_tokens WHERE expires_at <= NOW()`)
+	return err
+}
+
+type queryRower interface {
+	QueryRow(query string, args ...interface{}) *sql.Row
+}
+
+func insertRefreshToken(db queryRower, t *model.RefreshToken) error {
+	return db.QueryRow(`INSERT INTO refresh_tokens (family_id, username, token_hash, access_jti, access_expires_at, expires_at)
+		VALUES ($1, $2, $3, $4, $5, $6) RETURNING id, created_at`,
+		t.FamilyID, t.Username, t.TokenHash, t.AccessJTI, t.AccessExpiresAt, t.ExpiresAt).Scan(&t.ID, &t.CreatedAt)
+}
+
+func scanUser(row *sql.Row) (*model.User, error) {
+	var u model.User
+	var lockedUntil sql.NullTime
+	err := row.Scan(&u.ID, &u.Username, &u.Role, &u.PasswordHash, &u.FailedAttempts, &lockedUntil, &u.PasswordChangedAt, &u.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	if lockedUntil.Valid {
+		u.LockedUntil = &lockedUntil.Time
+	}
+	return &u, nil
+}
+
+func mapUniqueViolation(err error) error {
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == "23505" {
+		return ErrUsernameTaken
+	}
+	return err
+}
+
 func (r *PostgresRepository) ResetDB() error {
 	_, err := r.db.Exec("TRUNCATE TABLE transactions, accounts RESTART IDENTITY CASCADE")
 	if err != nil {
 		return err
 	}
-	_, err = r.db.Exec("INSERT INTO accounts (user_id, balance) VALUES ($1, $2)", "test_user", 1000.0)
+	_, err = r.db.Exec("INSERT INTO accounts (user_id, balance) VALUES ($1, $2)", "test_user", money.MustParseAmount("1000"))
 	return err
 }
@@ -1,11 +1,14 @@
 package repository
 
 import (
+	"errors"
 	"testing"
 	"time"
 
 	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/lib/pq"
 	"go-web-server/internal/model"
+	"go-web-server/services/account-service/money"
 )
 
 func TestGetAccount(t *testing.T) {
@@ -30,7 +33,7 @@ func TestGetAccount(t *testing.T) {
 		t.Errorf("error was not expected: %s", err)
 	}
 
-	if account.UserID != "test_user" || account.Balance != 100.0 {
+	if account.UserID != "test_user" || account.Balance != money.MustParseAmount("100") {
 		t.Errorf("expected test_user with 100.0 balance, got %v", account)
 	}
 }
@@ -47,7 +50,7 @@ func TestCreateTransaction_Deposit(t *testing.T) {
 	req := model.TransactionRequest{
 		UserID: "test_user",
 		Type:   model.Deposit,
-		Amount: 50.0,
+		Amount: money.MustParseAmount("50"),
 	}
 
 	mock.ExpectBegin()
@@ -58,14 +61,14 @@ func TestCreateTransaction_Deposit(t *testing.T) {
 	
 	// Update balance
 	mock.ExpectExec(`UPDATE accounts SET balance = \$1 WHERE id = \$2`).
-		WithArgs(150.0, "1").
+		WithArgs("150.00", "1").
 		WillReturnResult(sqlmock.NewResult(1, 1))
 	
 	// Insert transaction record - Note: The code uses 'transactions' table in CreateTransaction
 	// but the GetTransactionsRaw uses 'ledger_entries'. 
 	// I should check repository/postgres.go CreateTransaction implementation again.
 	mock.ExpectExec(`INSERT INTO transactions`).
-		WithArgs("1", model.Deposit, 50.0).
+		WithArgs("1", model.Deposit, "50.00").
 		WillReturnResult(sqlmock.NewResult(1, 1))
 	
 	mock.ExpectCommit()
@@ -75,8 +78,8 @@ func TestCreateTransaction_Deposit(t *testing.T) {
 		t.Errorf("error was not expected: %s", err)
 	}
 
-	if account.Balance != 150.0 {
-		t.Errorf("expected balance 150.0, got %f", account.Balance)
+	if account.Balance != money.MustParseAmount("150") {
+		t.Errorf("expected balance 150.00, got %s", account.Balance)
 	}
 }
 
@@ -92,7 +95,7 @@ func TestCreateTransaction_Withdraw_InsufficientFunds(t *testing.T) {
 	req := model.TransactionRequest{
 		UserID: "test_user",
 		Type:   model.Withdraw,
-		Amount: 200.0,
+		Amount: money.MustParseAmount("200"),
 	}
 
 	mock.ExpectBegin()
@@ -133,4 +136,183 @@ func TestGetTransactionsRaw(t *testing.T) {
 	if len(txs) != 2 {
 		t.Errorf("expected 2 transactions, got %d", len(txs))
 	}
-}
\ No newline at end of file
+}
+func TestCreateUser_UsernameTaken(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to open sqlmock: %s", err)
+	}
+	defer db.Close()
+
+	repo := NewPostgresRepository(db)
+
+	mock.ExpectBegin()
+	mock.ExpectExec(`INSERT INTO customers`).
+		WithArgs("test_user", "Jan Kowalski").
+		WillReturnError(&pq.Error{Code: "23505"})
+	mock.ExpectRollback()
+
+	err = repo.CreateUser(&model.User{Username: "test_user", PasswordHash: "hash"}, "Jan Kowalski")
+	if !errors.Is(err, ErrUsernameTaken) {
+		t.Errorf("expected ErrUsernameTaken, got %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestRecordFailedLogin(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to open sqlmock: %s", err)
+	}
+	defer db.Close()
+
+	repo := NewPostgresRepository(db)
+	now := time.Now()
+	lockUntil := now.Add(15 * time.Minute)
+
+	mock.ExpectQuery(`UPDATE users SET\s+failed_attempts = CASE`).
+		WithArgs("test_user", now, 5, lockUntil).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "username", "role", "password_hash", "failed_attempts", "locked_until", "password_changed_at", "created_at"}).
+			AddRow("u1", "test_user", "customer", "hash", 5, lockUntil, now, now))
+
+	user, err := repo.RecordFailedLogin("test_user", now, 5, lockUntil)
+	if err != nil {
+		t.Fatalf("error was not expected: %s", err)
+	}
+	if user.FailedAttempts != 5 || user.LockedUntil == nil || !user.LockedUntil.Equal(lockUntil) {
+		t.Errorf("expected 5 attempts locked until %v, got %+v", lockUntil, user)
+	}
+}
+
+func TestAssignRoles(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to open sqlmock: %s", err)
+	}
+	defer db.Close()
+
+	repo := NewPostgresRepository(db)
+
+	mock.ExpectBegin()
+	mock.ExpectExec(`UPDATE users SET role = \$1 WHERE role <> \$1`).
+		WithArgs("customer").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`UPDATE users SET role = \$1 WHERE username = \$2`).
+		WithArgs("merchant", "acquirer").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`UPDATE users SET role = \$1 WHERE username = \$2`).
+		WithArgs("admin", "ops").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectRollback()
+
+	err = repo.AssignRoles(map[string]string{"ops": "admin", "acquirer": "merchant"})
+	if !errors.Is(err, ErrUnknownUser) {
+		t.Errorf("expected ErrUnknownUser, got %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestUpdatePassword_RevokesSessions(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to open sqlmock: %s", err)
+	}
+	defer db.Close()
+
+	repo := NewPostgresRepository(db)
+
+	mock.ExpectBegin()
+	mock.ExpectExec(`UPDATE users SET password_hash`).
+		WithArgs("new-hash", "test_user").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`UPDATE refresh_tokens SET revoked_at = NOW\(\) WHERE revoked_at IS NULL AND username = \$1`).
+		WithArgs("test_user").
+		WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectExec(`INSERT INTO revoked_tokens`).
+		WithArgs("test_user").
+		WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectExec(`DELETE FROM revoked_tokens`).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectCommit()
+
+	if err := repo.UpdatePassword("test_user", "new-hash"); err != nil {
+		t.Fatalf("error was not expected: %s", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestRotateRefreshToken(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to open sqlmock: %s", err)
+	}
+	defer db.Close()
+
+	repo := NewPostgresRepository(db)
+	now := time.Now()
+	next := &model.RefreshToken{TokenHash: "new-hash", AccessJTI: "jti-2", AccessExpiresAt: now, ExpiresAt: now.Add(time.Hour)}
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT family_id, username, expires_at, used_at, revoked_at FROM refresh_tokens WHERE token_hash = \$1 FOR UPDATE`).
+		WithArgs("old-hash").
+		WillReturnRows(sqlmock.NewRows([]string{"family_id", "username", "expires_at", "used_at", "revoked_at"}).
+			AddRow("family-1", "test_user", now.Add(time.Hour), nil, nil))
+	mock.ExpectExec(`UPDATE refresh_tokens SET used_at`).
+		WithArgs(now, "old-hash").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery(`INSERT INTO refresh_tokens`).
+		WithArgs("family-1", "test_user", "new-hash", "jti-2", now, next.ExpiresAt).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "created_at"}).AddRow("token-2", now))
+	mock.ExpectCommit()
+
+	if err := repo.RotateRefreshToken("old-hash", now, next); err != nil {
+		t.Fatalf("error was not expected: %s", err)
+	}
+	if next.FamilyID != "family-1" || next.Username != "test_user" {
+		t.Errorf("new token not attached to the family: %+v", next)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestRotateRefreshToken_ReuseRevokesFamily(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to open sqlmock: %s", err)
+	}
+	defer db.Close()
+
+	repo := NewPostgresRepository(db)
+	now := time.Now()
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT family_id, username, expires_at, used_at, revoked_at FROM refresh_tokens`).
+		WithArgs("old-hash").
+		WillReturnRows(sqlmock.NewRows([]string{"family_id", "username", "expires_at", "used_at", "revoked_at"}).
+			AddRow("family-1", "test_user", now.Add(time.Hour), now.Add(-time.Minute), nil))
+	mock.ExpectExec(`UPDATE refresh_tokens SET revoked_at = NOW\(\) WHERE revoked_at IS NULL AND family_id = \$1`).
+		WithArgs("family-1").
+		WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectExec(`INSERT INTO revoked_tokens`).
+		WithArgs("family-1").
+		WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectExec(`DELETE FROM revoked_tokens`).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	// The revocation must be committed even though the call fails.
+	mock.ExpectCommit()
+
+	err = repo.RotateRefreshToken("old-hash", now, &model.RefreshToken{})
+	if !errors.Is(err, ErrRefreshTokenReused) {
+		t.Errorf("expected ErrRefreshTokenReused, got %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
@@ -0,0 +1,140 @@
+// Package audit makes the ledger tamper-evident.
+//
+// Every ledger entry is hashed together with the hash of the entry before it
+// on the same account, forming one hash chain per account whose head is
+// stored on the account row. Verify walks a chain and reports the first
+// broken link. A Signer periodically signs a checkpoint of all chain heads,
+// so that even a chain rewritten with valid hashes is detected against a
+// checkpoint exported earlier.
+package audit
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"strconv"
+	"time"
+
+	"go-web-server/services/account-service/model"
+)
+
+// entryHashVersion numbers the encoding EntryHash uses. It is hashed first,
+// so that the encoding can change without old and new hashes colliding.
+// Version 2 added StandingOrderID and version 3 ValueDate.
+const entryHashVersion = 3
+
+// EntryHash returns the hex SHA-256 of e's contents, Sequence and PrevHash.
+// Every field is length-prefixed, so no two entries share an encoding.
+// CreatedAt is hashed in UTC at the database's microsecond precision.
+func EntryHash(e *model.LedgerEntry) string {
+	return entryHash(e, entryHashVersion)
+}
+
+// entryHash returns the hash of e in the given version of the encoding.
+func entryHash(e *model.LedgerEntry, version int) string {
+	var ref, rate, order string
+	if e.ReferenceID != nil {
+		ref = e.ReferenceID.String()
+	}
+	if e.FXRate != nil {
+		rate = e.FXRate.String()
+	}
+	if e.StandingOrderID != nil {
+		order = e.StandingOrderID.String()
+	}
+
+	fields := []string{
+		"ledger-entry/v" + strconv.Itoa(version),
+		e.AccountID.String(),
+		strconv.FormatInt(e.Sequence, 10),
+		e.PrevHash,
+		e.ID.String(),
+		string(e.Type),
+		e.Amount.String(),
+		e.BalanceAfter.String(),
+		ref,
+		e.Description,
+		rate,
+		e.CreatedAt.UTC().Truncate(time.Microsecond).Format(time.RFC3339Nano),
+	}
+	if version >= 2 {
+		fields = append(fields, order)
+	}
+	if version >= 3 {
+		fields = append(fields, e.ValueDate)
+	}
+
+	h := sha256.New()
+	for _, f := range fields {
+		var n [4]byte
+		binary.BigEndian.PutUint32(n[:], uint32(len(f)))
+		h.Write(n[:])
+		h.Write([]byte(f))
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// hashedByEarlierVersion reports whether e carries the hash of an earlier
+// version of the encoding. Entries written before a field was hashed cannot
+// have it set, so an entry with the field set must carry a later hash.
+func hashedByEarlierVersion(e *model.LedgerEntry) bool {
+	for version := entryHashVersion - 1; version >= 1; version-- {
+		if version < 2 && e.StandingOrderID != nil {
+			return false
+		}
+		if e.Hash == entryHash(e, version) {
+			return true
+		}
+	}
+	return false
+}
+
+// Verify walks chain from its first entry and returns the first broken
+// link, or nil when every entry follows from the one before and the head
+// is the last entry. checkpoint, when not nil, is the account's head in a
+// signed checkpoint; the entry at its sequence must still carry its hash.
+func Verify(chain *model.LedgerChain, checkpoint *model.ChainHead) *model.HashChainBreak {
+	accountID := chain.Head.AccountID
+	broken := func(e *model.LedgerEntry, seq int64, reason model.HashChainBreakReason, expected, actual string) *model.HashChainBreak {
+		b := &model.HashChainBreak{AccountID: accountID, Sequence: seq, Reason: reason, Expected: expected, Actual: actual}
+		if e != nil {
+			id := e.ID
+			b.LedgerEntryID = &id
+		}
+		return b
+	}
+
+	var prev string
+	for i := range chain.Entries {
+		e := &chain.Entries[i]
+		seq := int64(i + 1)
+		if e.Hash == "" {
+			return broken(e, seq, model.ChainUnsealed, "", "")
+		}
+		if e.Sequence != seq {
+			return broken(e, seq, model.ChainSequenceGap, strconv.FormatInt(seq, 10), strconv.FormatInt(e.Sequence, 10))
+		}
+		if e.PrevHash != prev {
+			return broken(e, seq, model.ChainPrevHashMismatch, prev, e.PrevHash)
+		}
+		if want := EntryHash(e); e.Hash != want && !hashedByEarlierVersion(e) {
+			return broken(e, seq, model.ChainHashMismatch, want, e.Hash)
+		}
+		if checkpoint != nil && checkpoint.Sequence == seq && checkpoint.Hash != e.Hash {
+			return broken(e, seq, model.ChainCheckpointMismatch, checkpoint.Hash, e.Hash)
+		}
+		prev = e.Hash
+	}
+
+	n := int64(len(chain.Entries))
+	if checkpoint != nil && checkpoint.Sequence > n {
+		return broken(nil, checkpoint.Sequence, model.ChainCheckpointMismatch, checkpoint.Hash, "")
+	}
+	if chain.Head.Sequence != n {
+		return broken(nil, chain.Head.Sequence, model.ChainHeadMismatch, strconv.FormatInt(n, 10), strconv.FormatInt(chain.Head.Sequence, 10))
+	}
+	if chain.Head.Hash != prev {
+		return broken(nil, n, model.ChainHeadMismatch, prev, chain.Head.Hash)
+	}
+	return nil
+}
@@ -0,0 +1,143 @@
+package audit
+
+import (
+	"testing"
+	"time"
+
+	"go-web-server/services/account-service/model"
+	"go-web-server/services/account-service/money"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// buildChain returns a valid chain of deposits of the given amounts.
+func buildChain(amounts ...string) *model.LedgerChain {
+	chain := &model.LedgerChain{Head: model.ChainHead{AccountID: uuid.New()}}
+	created := time.Date(2024, 3, 1, 9, 30, 0, 123456000, time.UTC)
+	balance := money.Amount{}
+	for _, a := range amounts {
+		amount := money.MustParseAmount(a)
+		balance, _ = balance.Add(amount)
+		e := model.LedgerEntry{
+			ID: uuid.New(), AccountID: chain.Head.AccountID, Type: model.Deposit, Amount: amount, BalanceAfter: balance,
+			Description: "Deposit", CreatedAt: created, Sequence: chain.Head.Sequence + 1, PrevHash: chain.Head.Hash,
+		}
+		e.Hash = EntryHash(&e)
+		chain.Entries = append(chain.Entries, e)
+		chain.Head.Sequence, chain.Head.Hash = e.Sequence, e.Hash
+		created = created.Add(time.Minute)
+	}
+	return chain
+}
+
+func TestEntryHash_CoversContents(t *testing.T) {
+	e := buildChain("10").Entries[0]
+	base := EntryHash(&e)
+
+	// The same instant in another zone or with the trailing zeros of a
+	// NUMERIC column hashes the same.
+	same := e
+	same.CreatedAt = e.CreatedAt.In(time.FixedZone("CET", 3600))
+	same.Amount = money.MustParseAmount("10.0000")
+	assert.Equal(t, base, EntryHash(&same))
+
+	for name, edit := range map[string]func(*model.LedgerEntry){
+		"amount":      func(e *model.LedgerEntry) { e.Amount = money.MustParseAmount("10.01") },
+		"description": func(e *model.LedgerEntry) { e.Description = "Deposit!" },
+		"created at":  func(e *model.LedgerEntry) { e.CreatedAt = e.CreatedAt.Add(time.Microsecond) },
+		"prev hash":   func(e *model.LedgerEntry) { e.PrevHash = "00" },
+		"sequence":    func(e *model.LedgerEntry) { e.Sequence++ },
+		"value date":  func(e *model.LedgerEntry) { e.ValueDate = "2024-03-04" },
+		"standing order": func(e *model.LedgerEntry) {
+			order := uuid.New()
+			e.StandingOrderID = &order
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			edited := e
+			edit(&edited)
+			assert.NotEqual(t, base, EntryHash(&edited))
+		})
+	}
+}
+
+func TestVerify_ValidChain(t *testing.T) {
+	chain := buildChain("10", "20", "-5")
+	assert.Nil(t, Verify(chain, nil))
+	assert.Nil(t, Verify(&model.LedgerChain{}, nil))
+
+	head := chain.Entries[1]
+	assert.Nil(t, Verify(chain, &model.ChainHead{AccountID: head.AccountID, Sequence: head.Sequence, Hash: head.Hash}))
+}
+
+func TestVerify_PinpointsFirstBrokenLink(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		tamper func(*model.LedgerChain)
+		seq    int64
+		reason model.HashChainBreakReason
+	}{
+		{"edited amount", func(c *model.LedgerChain) { c.Entries[1].Amount = money.MustParseAmount("200") }, 2, model.ChainHashMismatch},
+		{"removed entry", func(c *model.LedgerChain) { c.Entries = append(c.Entries[:1], c.Entries[2:]...) }, 2, model.ChainSequenceGap},
+		{"rehashed entry", func(c *model.LedgerChain) {
+			c.Entries[0].Amount = money.MustParseAmount("1000")
+			c.Entries[0].Hash = EntryHash(&c.Entries[0])
+		}, 2, model.ChainPrevHashMismatch},
+		{"removed last entry", func(c *model.LedgerChain) { c.Entries = c.Entries[:2] }, 3, model.ChainHeadMismatch},
+		{"unsealed entry", func(c *model.LedgerChain) { c.Entries[2].Hash = "" }, 3, model.ChainUnsealed},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			chain := buildChain("10", "20", "-5")
+			tc.tamper(chain)
+
+			b := Verify(chain, nil)
+			require.NotNil(t, b)
+			assert.Equal(t, tc.reason, b.Reason)
+			assert.Equal(t, tc.seq, b.Sequence)
+			assert.Equal(t, chain.Head.AccountID, b.AccountID)
+		})
+	}
+}
+
+func TestVerify_AcceptsEarlierHashVersions(t *testing.T) {
+	chain := buildChain("10", "20")
+	// The first entry was written before the standing order was hashed,
+	// the second before the value date was.
+	first, second := &chain.Entries[0], &chain.Entries[1]
+	first.Hash = entryHash(first, 1)
+	paymentOf := uuid.New()
+	second.StandingOrderID = &paymentOf
+	second.PrevHash = first.Hash
+	second.Hash = entryHash(second, 2)
+	chain.Head.Hash = second.Hash
+	assert.Nil(t, Verify(chain, nil))
+
+	// A standing order added to it later is not covered by its hash.
+	order := uuid.New()
+	first.StandingOrderID = &order
+	b := Verify(chain, nil)
+	require.NotNil(t, b)
+	assert.Equal(t, model.ChainHashMismatch, b.Reason)
+	assert.Equal(t, int64(1), b.Sequence)
+}
+
+func TestVerify_RewrittenChainFailsCheckpoint(t *testing.T) {
+	chain := buildChain("10", "20")
+	checkpoint := chain.Head
+
+	// Rewrite the whole chain with consistent hashes.
+	rewritten := buildChain("10", "2000")
+	rewritten.Head.AccountID = chain.Head.AccountID
+	assert.Nil(t, Verify(rewritten, nil))
+
+	b := Verify(rewritten, &checkpoint)
+	require.NotNil(t, b)
+	assert.Equal(t, model.ChainCheckpointMismatch, b.Reason)
+	assert.Equal(t, int64(2), b.Sequence)
+
+	b = Verify(&model.LedgerChain{Head: model.ChainHead{AccountID: chain.Head.AccountID}}, &checkpoint)
+	require.NotNil(t, b)
+	assert.Equal(t, model.ChainCheckpointMismatch, b.Reason)
+}
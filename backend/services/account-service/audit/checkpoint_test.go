@@ -0,0 +1,47 @@
+package audit
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"go-web-server/services/account-service/model"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSignAndVerifyCheckpoint(t *testing.T) {
+	signer, err := NewSigner(bytes.Repeat([]byte{7}, 32))
+	require.NoError(t, err)
+
+	cp := &model.LedgerCheckpoint{
+		ID:        uuid.New(),
+		CreatedAt: time.Date(2024, 3, 1, 12, 0, 0, 123456789, time.UTC),
+		Heads:     []model.ChainHead{buildChain("10").Head, buildChain("5", "5").Head},
+	}
+	signer.Sign(cp)
+
+	assert.Equal(t, 123456000, cp.CreatedAt.Nanosecond())
+	assert.Equal(t, KeyID(signer.PublicKey()), cp.KeyID)
+	assert.NoError(t, VerifyCheckpoint(cp, signer.PublicKey()))
+
+	other, err := NewSigner(bytes.Repeat([]byte{8}, 32))
+	require.NoError(t, err)
+	assert.ErrorIs(t, VerifyCheckpoint(cp, other.PublicKey()), ErrInvalidSignature)
+
+	tampered := *cp
+	tampered.Heads = append([]model.ChainHead(nil), cp.Heads...)
+	tampered.Heads[0].Sequence++
+	assert.ErrorIs(t, VerifyCheckpoint(&tampered, signer.PublicKey()), ErrInvalidSignature)
+
+	backdated := *cp
+	backdated.CreatedAt = cp.CreatedAt.Add(-time.Hour)
+	assert.ErrorIs(t, VerifyCheckpoint(&backdated, signer.PublicKey()), ErrInvalidSignature)
+}
+
+func TestNewSigner_RejectsShortKey(t *testing.T) {
+	_, err := NewSigner([]byte("short"))
+	assert.Error(t, err)
+}
@@ -0,0 +1,231 @@
+// Package calendar tells business days from weekends and public holidays,
+// per country, and turns the time an operation is booked into its value
+// date under the country's daily cut-off.
+package calendar
+
+import (
+	"time"
+)
+
+// DateLayout is the layout of the days the calendar reads and the API
+// shows, such as "2024-03-01".
+const DateLayout = "2006-01-02"
+
+// Calendar knows which days are business days in one country: every day
+// but Saturdays, Sundays and its holidays. Days are compared by their date
+// alone. Operations booked on a business day before the cut-off, in the
+// country's time zone, take value that day; later ones on the next
+// business day.
+type Calendar struct {
+	// Country is the ISO 3166 code the calendar was loaded for, if any.
+	Country string
+
+	location *time.Location
+	// cutoff is the time of day after midnight at which a business day
+	// stops taking value; zero means the whole day does.
+	cutoff time.Duration
+
+	dates  map[time.Time]string
+	annual map[monthDay][]annualHoliday
+	// easter names holidays by their offset in days from Easter Sunday.
+	easter map[int]string
+}
+
+type monthDay struct {
+	month time.Month
+	day   int
+}
+
+type annualHoliday struct {
+	name     string
+	fromYear int
+}
+
+// New returns a calendar in UTC, with no cut-off, closed on weekends and
+// the given holidays, keyed by day and named.
+func New(holidays map[time.Time]string) *Calendar {
+	c := &Calendar{location: time.UTC}
+	for day, name := range holidays {
+		c.addDate(day, name)
+	}
+	return c
+}
+
+func (c *Calendar) addDate(day time.Time, name string) {
+	if c.dates == nil {
+		c.dates = map[time.Time]string{}
+	}
+	c.dates[Day(day)] = name
+}
+
+func (c *Calendar) addAnnual(month time.Month, day int, h annualHoliday) {
+	if c.annual == nil {
+		c.annual = map[monthDay][]annualHoliday{}
+	}
+	key := monthDay{month, day}
+	c.annual[key] = append(c.annual[key], h)
+}
+
+func (c *Calendar) addEaster(offset int, name string) {
+	if c.easter == nil {
+		c.easter = map[int]string{}
+	}
+	c.easter[offset] = name
+}
+
+// Day returns midnight UTC of t's date.
+func Day(t time.Time) time.Time {
+	y, m, d := t.Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+}
+
+// Location returns the time zone the calendar's days and cut-off are in.
+func (c *Calendar) Location() *time.Location {
+	return c.location
+}
+
+// Cutoff returns the time of day at which a business day stops taking
+// value, as a duration since midnight, or zero when there is no cut-off.
+func (c *Calendar) Cutoff() time.Duration {
+	return c.cutoff
+}
+
+// Today returns the date it is at t in the calendar's time zone.
+func (c *Calendar) Today(t time.Time) time.Time {
+	return Day(t.In(c.location))
+}
+
+// Holiday returns the name of the holiday on day, if it is one.
+func (c *Calendar) Holiday(day time.Time) (string, bool) {
+	day = Day(day)
+	if name, ok := c.dates[day]; ok {
+		return name, true
+	}
+	for _, h := range c.annual[monthDay{day.Month(), day.Day()}] {
+		if day.Year() >= h.fromYear {
+			return h.name, true
+		}
+	}
+	if len(c.easter) > 0 {
+		offset := int(day.Sub(Easter(day.Year())).Hours() / 24)
+		if name, ok := c.easter[offset]; ok {
+			return name, true
+		}
+	}
+	return "", false
+}
+
+// IsBusinessDay reports whether day is neither a weekend nor a holiday.
+func (c *Calendar) IsBusinessDay(day time.Time) bool {
+	switch day.Weekday() {
+	case time.Saturday, time.Sunday:
+		return false
+	}
+	_, holiday := c.Holiday(day)
+	return !holiday
+}
+
+// Next returns day if it is a business day, or else the first business day
+// after it.
+func (c *Calendar) Next(day time.Time) time.Time {
+	day = Day(day)
+	for !c.IsBusinessDay(day) {
+		day = day.AddDate(0, 0, 1)
+	}
+	return day
+}
+
+// Previous returns day if it is a business day, or else the last business
+// day before it.
+func (c *Calendar) Previous(day time.Time) time.Time {
+	day = Day(day)
+	for !c.IsBusinessDay(day) {
+		day = day.AddDate(0, 0, -1)
+	}
+	return day
+}
+
+// AddBusinessDays returns the business day n business days after day, or
+// before it when n is negative. With n zero it returns Next(day).
+func (c *Calendar) AddBusinessDays(day time.Time, n int) time.Time {
+	if n == 0 {
+		return c.Next(day)
+	}
+	step := 1
+	if n < 0 {
+		step, n = -1, -n
+	}
+	day = Day(day)
+	for n > 0 {
+		day = day.AddDate(0, 0, step)
+		if c.IsBusinessDay(day) {
+			n--
+		}
+	}
+	return day
+}
+
+// BusinessDaysBetween counts the business days after from up to and
+// including to, or minus those after to up to and including from when to
+// is earlier.
+func (c *Calendar) BusinessDaysBetween(from, to time.Time) int {
+	from, to = Day(from), Day(to)
+	sign := 1
+	if to.Before(from) {
+		from, to, sign = to, from, -1
+	}
+	n := 0
+	for day := from.AddDate(0, 0, 1); !day.After(to); day = day.AddDate(0, 0, 1) {
+		if c.IsBusinessDay(day) {
+			n++
+		}
+	}
+	return sign * n
+}
+
+// LastBusinessDay returns the last business day of a month.
+func (c *Calendar) LastBusinessDay(year int, month time.Month) time.Time {
+	return c.Previous(time.Date(year, month+1, 0, 0, 0, 0, 0, time.UTC))
+}
+
+// BeforeCutoff reports whether t, in the calendar's time zone, is on a
+// business day before its cut-off.
+func (c *Calendar) BeforeCutoff(t time.Time) bool {
+	local := t.In(c.location)
+	if !c.IsBusinessDay(local) {
+		return false
+	}
+	if c.cutoff == 0 {
+		return true
+	}
+	y, m, d := local.Date()
+	return local.Before(time.Date(y, m, d, 0, 0, 0, 0, c.location).Add(c.cutoff))
+}
+
+// ValueDate returns the business day an operation booked at t takes value:
+// the day of t when it is before the cut-off, otherwise the next business
+// day.
+func (c *Calendar) ValueDate(t time.Time) time.Time {
+	day := c.Today(t)
+	if c.BeforeCutoff(t) {
+		return day
+	}
+	return c.Next(day.AddDate(0, 0, 1))
+}
+
+// Easter returns the date of Western Easter Sunday in year, by the
+// anonymous Gregorian algorithm.
+func Easter(year int) time.Time {
+	a := year % 19
+	b, c := year/100, year%100
+	d, e := b/4, b%4
+	f := (b + 8) / 25
+	g := (b - f + 1) / 3
+	h := (19*a + b - d - g + 15) % 30
+	i, k := c/4, c%4
+	l := (32 + 2*e + 2*i - h - k) % 7
+	m := (a + 11*h + 22*l) / 451
+	month := (h + l - 7*m + 114) / 31
+	day := (h+l-7*m+114)%31 + 1
+	return time.Date(year, time.Month(month), day, 0, 0, 0, 0, time.UTC)
+}
@@ -0,0 +1,182 @@
+package calendar
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testCalendars has the Polish public holidays of spring 2024, in UTC
+// with no cut-off.
+const testCalendars = `{"PL": {"holidays": [
+  {"date": "2024-03-31", "name": "Easter Sunday"},
+  {"date": "2024-04-01", "name": "Easter Monday"},
+  {"date": "2024-05-01", "name": "Labour Day"},
+  {"date": "2024-05-03", "name": "Constitution Day"}
+]}}`
+
+func day(s string) time.Time {
+	d, err := time.Parse(DateLayout, s)
+	if err != nil {
+		panic(err)
+	}
+	return d
+}
+
+func loadTestCalendar(t *testing.T) *Calendar {
+	t.Helper()
+	s, err := Read(strings.NewReader(testCalendars))
+	require.NoError(t, err)
+	c, ok := s.Get("pl")
+	require.True(t, ok)
+	return c
+}
+
+func TestIsBusinessDay(t *testing.T) {
+	c := loadTestCalendar(t)
+
+	assert.True(t, c.IsBusinessDay(day("2024-04-02")))
+	assert.False(t, c.IsBusinessDay(day("2024-04-01")), "Easter Monday")
+	assert.False(t, c.IsBusinessDay(day("2024-04-06")), "Saturday")
+	assert.False(t, c.IsBusinessDay(day("2024-04-07")), "Sunday")
+	// The time of day does not matter.
+	assert.False(t, c.IsBusinessDay(time.Date(2024, 5, 1, 23, 30, 0, 0, time.UTC)))
+
+	name, ok := c.Holiday(day("2024-05-03"))
+	assert.True(t, ok)
+	assert.Equal(t, "Constitution Day", name)
+}
+
+func TestNextAndPrevious(t *testing.T) {
+	c := loadTestCalendar(t)
+
+	// Easter weekend and Monday.
+	assert.Equal(t, day("2024-04-02"), c.Next(day("2024-03-30")))
+	assert.Equal(t, day("2024-03-29"), c.Previous(day("2024-04-01")))
+	// Labour Day, a Wednesday.
+	assert.Equal(t, day("2024-05-02"), c.Next(day("2024-05-01")))
+	assert.Equal(t, day("2024-04-30"), c.Previous(day("2024-05-01")))
+	// Constitution Day, a Friday, joins the weekend.
+	assert.Equal(t, day("2024-05-06"), c.Next(day("2024-05-03")))
+	// Business days stay.
+	assert.Equal(t, day("2024-05-02"), c.Next(day("2024-05-02")))
+	assert.Equal(t, day("2024-05-02"), c.Previous(time.Date(2024, 5, 2, 15, 0, 0, 0, time.UTC)))
+}
+
+func TestLastBusinessDay(t *testing.T) {
+	c := loadTestCalendar(t)
+
+	assert.Equal(t, day("2024-03-29"), c.LastBusinessDay(2024, time.March), "31st is Easter Sunday")
+	assert.Equal(t, day("2024-04-30"), c.LastBusinessDay(2024, time.April))
+	assert.Equal(t, day("2024-08-30"), c.LastBusinessDay(2024, time.August), "31st is a Saturday")
+	assert.Equal(t, day("2024-12-31"), c.LastBusinessDay(2024, time.December))
+	assert.Equal(t, day("2024-02-29"), New(nil).LastBusinessDay(2024, time.February))
+}
+
+func TestAddBusinessDays(t *testing.T) {
+	c := loadTestCalendar(t)
+
+	assert.Equal(t, day("2024-04-02"), c.AddBusinessDays(day("2024-03-28"), 2), "over Easter")
+	assert.Equal(t, day("2024-03-28"), c.AddBusinessDays(day("2024-04-02"), -2))
+	assert.Equal(t, day("2024-05-06"), c.AddBusinessDays(day("2024-04-30"), 2), "over the May holidays")
+	assert.Equal(t, day("2024-04-02"), c.AddBusinessDays(day("2024-03-30"), 0))
+
+	assert.Equal(t, 2, c.BusinessDaysBetween(day("2024-03-28"), day("2024-04-02")))
+	assert.Equal(t, -2, c.BusinessDaysBetween(day("2024-04-02"), day("2024-03-28")))
+	assert.Equal(t, 0, c.BusinessDaysBetween(day("2024-05-02"), day("2024-05-05")))
+}
+
+func TestValueDate(t *testing.T) {
+	c, ok := Default().Get(DefaultCountry)
+	require.True(t, ok)
+	warsaw := c.Location()
+
+	for _, tc := range []struct {
+		name   string
+		at     time.Time
+		before bool
+		value  string
+	}{
+		{"morning", time.Date(2024, 4, 2, 9, 0, 0, 0, warsaw), true, "2024-04-02"},
+		{"at the cut-off", time.Date(2024, 4, 2, 16, 0, 0, 0, warsaw), false, "2024-04-03"},
+		// 23:30 UTC is already the next day in Warsaw.
+		{"UTC evening", time.Date(2024, 4, 2, 23, 30, 0, 0, time.UTC), true, "2024-04-03"},
+		{"Friday evening", time.Date(2024, 3, 29, 17, 0, 0, 0, warsaw), false, "2024-04-02"},
+		{"Easter Monday", time.Date(2024, 4, 1, 10, 0, 0, 0, warsaw), false, "2024-04-02"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.before, c.BeforeCutoff(tc.at))
+			assert.Equal(t, day(tc.value), c.ValueDate(tc.at))
+		})
+	}
+
+	// Without a cut-off a business day takes value until midnight.
+	assert.Equal(t, day("2024-04-02"), New(nil).ValueDate(time.Date(2024, 4, 2, 23, 59, 0, 0, time.UTC)))
+}
+
+func TestDefault_PL(t *testing.T) {
+	c, ok := Default().Get("PL")
+	require.True(t, ok)
+	assert.Equal(t, 16*time.Hour, c.Cutoff())
+
+	for date, name := range map[string]string{
+		"2024-03-31": "Easter Sunday",
+		"2024-04-01": "Easter Monday",
+		"2024-05-30": "Corpus Christi",
+		"2025-04-21": "Easter Monday",
+		"2025-06-19": "Corpus Christi",
+		"2025-11-11": "Independence Day",
+		"2025-12-24": "Christmas Eve",
+	} {
+		holiday, ok := c.Holiday(day(date))
+		assert.True(t, ok, date)
+		assert.Equal(t, name, holiday, date)
+	}
+	// Christmas Eve is a holiday from 2025 on.
+	assert.True(t, c.IsBusinessDay(day("2024-12-24")))
+	assert.True(t, c.IsBusinessDay(day("2025-04-22")))
+}
+
+func TestEaster(t *testing.T) {
+	for year, easter := range map[int]string{
+		2019: "2019-04-21",
+		2024: "2024-03-31",
+		2025: "2025-04-20",
+		2038: "2038-04-25",
+	} {
+		assert.Equal(t, day(easter), Easter(year), year)
+	}
+}
+
+func TestRead_Invalid(t *testing.T) {
+	for name, file := range map[string]string{
+		"not a date":        `{"PL": {"holidays": [{"date": "1 May 2024", "name": "Labour Day"}]}}`,
+		"repeated":          `{"PL": {"holidays": [{"date": "2024-05-01"}, {"date": "2024-05-01"}]}}`,
+		"repeated annually": `{"PL": {"holidays": [{"annual": "05-01"}, {"annual": "05-01"}]}}`,
+		"not a month day":   `{"PL": {"holidays": [{"annual": "13-01"}]}}`,
+		"two kinds":         `{"PL": {"holidays": [{"annual": "05-01", "easter": 0}]}}`,
+		"no kind":           `{"PL": {"holidays": [{"name": "Labour Day"}]}}`,
+	} {
+		t.Run(name, func(t *testing.T) {
+			_, err := Read(strings.NewReader(file))
+			assert.ErrorIs(t, err, ErrInvalidHoliday)
+		})
+	}
+
+	for name, file := range map[string]string{
+		"country":   `{"Poland": {}}`,
+		"time zone": `{"PL": {"timezone": "Europe/Nowhere"}}`,
+		"cut-off":   `{"PL": {"cutoff": "4pm"}}`,
+	} {
+		t.Run(name, func(t *testing.T) {
+			_, err := Read(strings.NewReader(file))
+			assert.ErrorIs(t, err, ErrInvalidCalendar)
+		})
+	}
+
+	_, err := Read(strings.NewReader(`[{"date": "2024-05-01", "name": "Labour Day"}]`))
+	assert.Error(t, err)
+}
@@ -0,0 +1,188 @@
+package calendar
+
+import (
+	"bytes"
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"time"
+	// The service image has no zoneinfo of its own.
+	_ "time/tzdata"
+)
+
+var (
+	// ErrInvalidHoliday is returned for a calendars file holiday with a
+	// malformed or repeated date.
+	ErrInvalidHoliday = errors.New("invalid holiday")
+	// ErrInvalidCalendar is returned for a calendars file country with a
+	// malformed code, time zone or cut-off.
+	ErrInvalidCalendar = errors.New("invalid calendar")
+)
+
+// DefaultCountry is the country whose calendar is used unless configured
+// otherwise.
+const DefaultCountry = "PL"
+
+//go:embed calendars.json
+var defaultCalendars []byte
+
+// Set holds the calendars of several countries, keyed by their upper case
+// ISO 3166 code.
+type Set map[string]*Calendar
+
+// Get returns the calendar of country, in any case.
+func (s Set) Get(country string) (*Calendar, bool) {
+	c, ok := s[strings.ToUpper(country)]
+	return c, ok
+}
+
+// Countries returns the codes of the countries in the set, sorted.
+func (s Set) Countries() []string {
+	countries := make([]string, 0, len(s))
+	for country := range s {
+		countries = append(countries, country)
+	}
+	sort.Strings(countries)
+	return countries
+}
+
+// Default returns the calendars built into the service, which cover
+// DefaultCountry.
+func Default() Set {
+	s, err := Read(bytes.NewReader(defaultCalendars))
+	if err != nil {
+		panic(fmt.Sprintf("calendar: built-in calendars: %v", err))
+	}
+	return s
+}
+
+// LoadFile reads calendars from a JSON file with the time zone, the daily
+// cut-off and the public holidays of each country:
+//
+//	{
+//	  "PL": {
+//	    "timezone": "Europe/Warsaw",
+//	    "cutoff": "16:00",
+//	    "holidays": [
+//	      {"annual": "05-03", "name": "Constitution Day"},
+//	      {"easter": 1, "name": "Easter Monday"},
+//	      {"annual": "12-24", "fromYear": 2025, "name": "Christmas Eve"},
+//	      {"date": "2024-11-12", "name": "Bridge day"}
+//	    ]
+//	  }
+//	}
+//
+// A holiday falls on one date, every year on a month and day (optionally
+// from some year on), or a number of days after Easter Sunday. Without a
+// time zone a country is in UTC; without a cut-off every business day
+// takes value until midnight.
+func LoadFile(path string) (Set, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("could not open calendars file: %w", err)
+	}
+	defer f.Close()
+
+	s, err := Read(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return s, nil
+}
+
+type holidayEntry struct {
+	Name     string `json:"name"`
+	Date     string `json:"date"`
+	Annual   string `json:"annual"`
+	FromYear int    `json:"fromYear"`
+	Easter   *int   `json:"easter"`
+}
+
+// Read parses calendars in the LoadFile format.
+func Read(r io.Reader) (Set, error) {
+	var file map[string]struct {
+		Timezone string         `json:"timezone"`
+		Cutoff   string         `json:"cutoff"`
+		Holidays []holidayEntry `json:"holidays"`
+	}
+	if err := json.NewDecoder(r).Decode(&file); err != nil {
+		return nil, fmt.Errorf("could not decode calendars: %w", err)
+	}
+
+	s := make(Set, len(file))
+	for country, entry := range file {
+		if len(country) != 2 || strings.ToUpper(country) != country {
+			return nil, fmt.Errorf("%w: %q is not a country code such as PL", ErrInvalidCalendar, country)
+		}
+		c := New(nil)
+		c.Country = country
+		if entry.Timezone != "" {
+			loc, err := time.LoadLocation(entry.Timezone)
+			if err != nil {
+				return nil, fmt.Errorf("%w: %s: unknown time zone %q", ErrInvalidCalendar, country, entry.Timezone)
+			}
+			c.location = loc
+		}
+		if entry.Cutoff != "" {
+			cutoff, err := time.Parse("15:04", entry.Cutoff)
+			if err != nil {
+				return nil, fmt.Errorf("%w: %s: cut-off %q is not a time such as 16:00", ErrInvalidCalendar, country, entry.Cutoff)
+			}
+			c.cutoff = time.Duration(cutoff.Hour())*time.Hour + time.Duration(cutoff.Minute())*time.Minute
+		}
+		for _, h := range entry.Holidays {
+			if err := c.addHoliday(h); err != nil {
+				return nil, fmt.Errorf("%w: %s: %v", ErrInvalidHoliday, country, err)
+			}
+		}
+		s[country] = c
+	}
+	return s, nil
+}
+
+func (c *Calendar) addHoliday(h holidayEntry) error {
+	kinds := 0
+	for _, set := range []bool{h.Date != "", h.Annual != "", h.Easter != nil} {
+		if set {
+			kinds++
+		}
+	}
+	if kinds != 1 {
+		return fmt.Errorf("%q needs exactly one of date, annual and easter", h.Name)
+	}
+
+	switch {
+	case h.Date != "":
+		day, err := time.Parse(DateLayout, h.Date)
+		if err != nil {
+			return fmt.Errorf("%q is not a date such as 2024-01-01", h.Date)
+		}
+		if _, dup := c.dates[day]; dup {
+			return fmt.Errorf("%s is listed twice", h.Date)
+		}
+		c.addDate(day, h.Name)
+	case h.Annual != "":
+		// 2000 is a leap year, so 02-29 parses.
+		day, err := time.Parse(DateLayout, "2000-"+h.Annual)
+		if err != nil {
+			return fmt.Errorf("%q is not a month and day such as 05-01", h.Annual)
+		}
+		for _, other := range c.annual[monthDay{day.Month(), day.Day()}] {
+			if other.fromYear == h.FromYear {
+				return fmt.Errorf("%s is listed twice", h.Annual)
+			}
+		}
+		c.addAnnual(day.Month(), day.Day(), annualHoliday{name: h.Name, fromYear: h.FromYear})
+	default:
+		if _, dup := c.easter[*h.Easter]; dup {
+			return fmt.Errorf("Easter%+d is listed twice", *h.Easter)
+		}
+		c.addEaster(*h.Easter, h.Name)
+	}
+	return nil
+}
@@ -0,0 +1,83 @@
+package fx
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"go-web-server/services/account-service/model"
+	"go-web-server/services/account-service/money"
+)
+
+// SourceFile marks rates loaded from a rates file.
+const SourceFile = "file"
+
+// FileProvider serves a fixed set of rates loaded from a JSON file:
+//
+//	[
+//	  {"base": "EUR", "quote": "PLN", "bid": "4.2650", "ask": "4.3510"},
+//	  {"base": "USD", "quote": "PLN", "bid": "3.8900", "ask": "3.9700"}
+//	]
+type FileProvider struct {
+	rates map[[2]money.Currency]model.FXRate
+}
+
+// LoadFile reads and validates a rates file. The file's modification time
+// is reported as the rates' UpdatedAt.
+func LoadFile(path string) (*FileProvider, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("could not open rates file: %w", err)
+	}
+	defer f.Close()
+
+	updatedAt := time.Now()
+	if info, err := f.Stat(); err == nil {
+		updatedAt = info.ModTime()
+	}
+	p, err := ReadRates(f, updatedAt)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return p, nil
+}
+
+// ReadRates parses rates in the LoadFile format.
+func ReadRates(r io.Reader, updatedAt time.Time) (*FileProvider, error) {
+	var rates []model.FXRate
+	if err := json.NewDecoder(r).Decode(&rates); err != nil {
+		return nil, fmt.Errorf("could not decode rates: %w", err)
+	}
+
+	p := &FileProvider{rates: make(map[[2]money.Currency]model.FXRate, len(rates))}
+	for _, rate := range rates {
+		var err error
+		if rate.Base, err = money.ParseCurrency(string(rate.Base)); err != nil {
+			return nil, err
+		}
+		if rate.Quote, err = money.ParseCurrency(string(rate.Quote)); err != nil {
+			return nil, err
+		}
+		if err := Validate(rate); err != nil {
+			return nil, err
+		}
+		key := [2]money.Currency{rate.Base, rate.Quote}
+		if _, dup := p.rates[key]; dup {
+			return nil, fmt.Errorf("%w: %s/%s is listed twice", ErrInvalidRate, rate.Base, rate.Quote)
+		}
+		rate.Source = SourceFile
+		rate.UpdatedAt = updatedAt
+		p.rates[key] = rate
+	}
+	return p, nil
+}
+
+func (p *FileProvider) Rate(base, quote money.Currency) (*model.FXRate, error) {
+	r, ok := p.rates[[2]money.Currency{base, quote}]
+	if !ok {
+		return nil, nil
+	}
+	return &r, nil
+}
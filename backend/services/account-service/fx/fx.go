@@ -0,0 +1,133 @@
+// Package fx prices currency conversions from bid/ask exchange rates.
+//
+// Rates come from Providers: the admin-maintained fx_rates table, a local
+// rates file, or both chained together. A Converter turns them into the rate
+// a customer gets when selling one currency for another.
+package fx
+
+import (
+	"errors"
+	"fmt"
+
+	"go-web-server/services/account-service/model"
+	"go-web-server/services/account-service/money"
+)
+
+var (
+	// ErrRateUnavailable is returned when no provider quotes a pair that
+	// connects the two currencies.
+	ErrRateUnavailable = errors.New("exchange rate unavailable")
+	// ErrInvalidRate is returned for a rate with a non-positive bid, an ask
+	// below the bid or identical currencies.
+	ErrInvalidRate = errors.New("invalid exchange rate")
+)
+
+// Provider returns the rate of one currency pair, or nil when it does not
+// quote the pair.
+type Provider interface {
+	Rate(base, quote money.Currency) (*model.FXRate, error)
+}
+
+// ProviderFunc adapts a lookup function, such as the repository's
+// GetFXRate, to Provider.
+type ProviderFunc func(base, quote money.Currency) (*model.FXRate, error)
+
+func (f ProviderFunc) Rate(base, quote money.Currency) (*model.FXRate, error) {
+	return f(base, quote)
+}
+
+// Chain asks each provider in turn and returns the first rate found, so a
+// rate set by an admin overrides the same pair in the rates file.
+type Chain []Provider
+
+func (c Chain) Rate(base, quote money.Currency) (*model.FXRate, error) {
+	for _, p := range c {
+		if p == nil {
+			continue
+		}
+		r, err := p.Rate(base, quote)
+		if err != nil || r != nil {
+			return r, err
+		}
+	}
+	return nil, nil
+}
+
+// Validate checks that r quotes two different currencies with a positive
+// bid not above the ask.
+func Validate(r model.FXRate) error {
+	switch {
+	case r.Base == r.Quote:
+		return fmt.Errorf("%w: %s/%s quotes a currency against itself", ErrInvalidRate, r.Base, r.Quote)
+	case r.Bid.IsZero() || r.Ask.IsZero():
+		return fmt.Errorf("%w: %s/%s needs a bid and an ask", ErrInvalidRate, r.Base, r.Quote)
+	case r.Ask.Cmp(r.Bid) < 0:
+		return fmt.Errorf("%w: %s/%s ask %s is below bid %s", ErrInvalidRate, r.Base, r.Quote, r.Ask, r.Bid)
+	}
+	return nil
+}
+
+// Converter prices conversions between any two currencies quoted directly,
+// in either direction, or through Pivot (e.g. EUR to USD via EUR/PLN and
+// USD/PLN when Pivot is PLN).
+type Converter struct {
+	Rates Provider
+	Pivot money.Currency
+}
+
+func NewConverter(rates Provider, pivot money.Currency) *Converter {
+	return &Converter{Rates: rates, Pivot: pivot}
+}
+
+// Rate returns the rate applied when a customer sells from and buys to:
+// units of to paid per unit of from, spread included. Selling the base of a
+// pair is priced at its bid; buying the base costs the ask, so the rate is
+// the inverse ask.
+func (c *Converter) Rate(from, to money.Currency) (money.Rate, error) {
+	if from == to {
+		return money.Rate{}, fmt.Errorf("%w: %s to %s", ErrInvalidRate, from, to)
+	}
+	r, ok, err := c.direct(from, to)
+	if err != nil || ok {
+		return r, err
+	}
+	if c.Pivot == "" || from == c.Pivot || to == c.Pivot {
+		return money.Rate{}, fmt.Errorf("%w: %s to %s", ErrRateUnavailable, from, to)
+	}
+
+	first, ok, err := c.direct(from, c.Pivot)
+	if err != nil {
+		return money.Rate{}, err
+	}
+	if !ok {
+		return money.Rate{}, fmt.Errorf("%w: %s to %s", ErrRateUnavailable, from, to)
+	}
+	second, ok, err := c.direct(c.Pivot, to)
+	if err != nil {
+		return money.Rate{}, err
+	}
+	if !ok {
+		return money.Rate{}, fmt.Errorf("%w: %s to %s", ErrRateUnavailable, from, to)
+	}
+	return first.Mul(second), nil
+}
+
+// direct prices from -> to from a single pair quoted either way round.
+func (c *Converter) direct(from, to money.Currency) (money.Rate, bool, error) {
+	r, err := c.Rates.Rate(from, to)
+	if err != nil {
+		return money.Rate{}, false, fmt.Errorf("could not load %s/%s rate: %w", from, to, err)
+	}
+	if r != nil {
+		return r.Bid, true, nil
+	}
+
+	r, err = c.Rates.Rate(to, from)
+	if err != nil {
+		return money.Rate{}, false, fmt.Errorf("could not load %s/%s rate: %w", to, from, err)
+	}
+	if r != nil {
+		return r.Ask.Inverse(), true, nil
+	}
+	return money.Rate{}, false, nil
+}
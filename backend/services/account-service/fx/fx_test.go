@@ -0,0 +1,107 @@
+package fx
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"go-web-server/services/account-service/model"
+	"go-web-server/services/account-service/money"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testRates = `[
+  {"base": "EUR", "quote": "PLN", "bid": "4.2650", "ask": "4.3510"},
+  {"base": "USD", "quote": "PLN", "bid": "3.8900", "ask": "3.9700"}
+]`
+
+func loadTestRates(t *testing.T) *FileProvider {
+	t.Helper()
+	p, err := ReadRates(strings.NewReader(testRates), time.Now())
+	require.NoError(t, err)
+	return p
+}
+
+func TestConverter_DirectPairUsesBid(t *testing.T) {
+	c := NewConverter(loadTestRates(t), money.PLN)
+
+	r, err := c.Rate(money.EUR, money.PLN)
+	require.NoError(t, err)
+	assert.Equal(t, "4.2650", r.String())
+}
+
+func TestConverter_InversePairUsesAsk(t *testing.T) {
+	c := NewConverter(loadTestRates(t), money.PLN)
+
+	// Buying EUR with PLN costs the ask: 1 PLN buys 1/4.3510 EUR.
+	r, err := c.Rate(money.PLN, money.EUR)
+	require.NoError(t, err)
+	assert.Equal(t, money.MustParseRate("4.3510").Inverse(), r)
+}
+
+func TestConverter_CrossRateThroughPivot(t *testing.T) {
+	c := NewConverter(loadTestRates(t), money.PLN)
+
+	r, err := c.Rate(money.EUR, money.USD)
+	require.NoError(t, err)
+	want := money.MustParseRate("4.2650").Mul(money.MustParseRate("3.9700").Inverse())
+	assert.Equal(t, want, r)
+	// Both legs carry a spread, so the round trip loses money.
+	back, err := c.Rate(money.USD, money.EUR)
+	require.NoError(t, err)
+	assert.Equal(t, -1, r.Mul(back).Cmp(money.MustParseRate("1")))
+}
+
+func TestConverter_UnknownPair(t *testing.T) {
+	c := NewConverter(loadTestRates(t), money.PLN)
+
+	_, err := c.Rate(money.EUR, money.GBP)
+	assert.ErrorIs(t, err, ErrRateUnavailable)
+	_, err = c.Rate(money.EUR, money.EUR)
+	assert.ErrorIs(t, err, ErrInvalidRate)
+}
+
+func TestChain_FirstProviderWins(t *testing.T) {
+	admin := ProviderFunc(func(base, quote money.Currency) (*model.FXRate, error) {
+		if base == money.EUR && quote == money.PLN {
+			return &model.FXRate{Base: base, Quote: quote, Bid: money.MustParseRate("4.30"), Ask: money.MustParseRate("4.40"), Source: "admin"}, nil
+		}
+		return nil, nil
+	})
+	c := NewConverter(Chain{admin, loadTestRates(t)}, money.PLN)
+
+	r, err := c.Rate(money.EUR, money.PLN)
+	require.NoError(t, err)
+	assert.Equal(t, "4.3000", r.String())
+	r, err = c.Rate(money.USD, money.PLN)
+	require.NoError(t, err)
+	assert.Equal(t, "3.8900", r.String())
+}
+
+func TestChain_ProviderErrorIsReturned(t *testing.T) {
+	failing := ProviderFunc(func(base, quote money.Currency) (*model.FXRate, error) {
+		return nil, errors.New("db down")
+	})
+	c := NewConverter(Chain{failing, loadTestRates(t)}, money.PLN)
+
+	_, err := c.Rate(money.EUR, money.PLN)
+	assert.Error(t, err)
+	assert.NotErrorIs(t, err, ErrRateUnavailable)
+}
+
+func TestReadRates_Invalid(t *testing.T) {
+	for _, in := range []string{
+		`[{"base": "EUR", "quote": "PLN", "bid": "4.40", "ask": "4.30"}]`,
+		`[{"base": "EUR", "quote": "EUR", "bid": "1", "ask": "1"}]`,
+		`[{"base": "EUR", "quote": "PLN", "bid": "4.30"}]`,
+		`[{"base": "EUR", "quote": "PLN", "bid": "4.30", "ask": "4.40"}, {"base": "eur", "quote": "pln", "bid": "4.30", "ask": "4.40"}]`,
+	} {
+		_, err := ReadRates(strings.NewReader(in), time.Now())
+		assert.ErrorIs(t, err, ErrInvalidRate, in)
+	}
+	_, err := ReadRates(strings.NewReader(`[{"base": "XXX", "quote": "PLN", "bid": "1", "ask": "1"}]`), time.Now())
+	assert.ErrorIs(t, err, money.ErrUnknownCurrency)
+}
@@ -0,0 +1,136 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"go-web-server/services/account-service/handler/middleware"
+	"go-web-server/services/account-service/model"
+	"go-web-server/services/account-service/service"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+type MockAuditService struct {
+	mock.Mock
+}
+
+func (m *MockAuditService) VerifyChains(ctx context.Context, accountID string) (*model.HashChainVerification, error) {
+	args := m.Called(ctx, accountID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.HashChainVerification), args.Error(1)
+}
+
+func (m *MockAuditService) CreateCheckpoint(ctx context.Context) (*model.LedgerCheckpoint, error) {
+	args := m.Called(ctx)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.LedgerCheckpoint), args.Error(1)
+}
+
+func (m *MockAuditService) GetCheckpoint(ctx context.Context, checkpointID string) (*model.LedgerCheckpoint, error) {
+	args := m.Called(ctx, checkpointID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.LedgerCheckpoint), args.Error(1)
+}
+
+func (m *MockAuditService) ListCheckpoints(ctx context.Context) ([]model.LedgerCheckpoint, error) {
+	args := m.Called(ctx)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]model.LedgerCheckpoint), args.Error(1)
+}
+
+func setupAuditRouter(svc *MockAuditService) chi.Router {
+	r := chi.NewRouter()
+	NewAuditHandler(svc, middleware.AuthMiddleware(jwtKey, nil), middleware.RequireAdmin()).RegisterRoutes(r)
+	return r
+}
+
+func TestVerifyChainsHandler(t *testing.T) {
+	svc := new(MockAuditService)
+	r := setupAuditRouter(svc)
+	accountID := uuid.New()
+	svc.On("VerifyChains", mock.Anything, accountID.String()).Return(&model.HashChainVerification{
+		AccountsChecked: 1,
+		EntriesChecked:  3,
+		Breaks:          []model.HashChainBreak{{AccountID: accountID, Sequence: 2, Reason: model.ChainHashMismatch}},
+	}, nil)
+
+	req, _ := http.NewRequest("GET", "/admin/audit/verify?accountId="+accountID.String(), nil)
+	req.Header.Set("Authorization", "Bearer "+createToken("admin"))
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Contains(t, rr.Body.String(), `"valid":false`)
+	assert.Contains(t, rr.Body.String(), `"reason":"hash_mismatch"`)
+	svc.AssertExpectations(t)
+}
+
+func TestVerifyChainsHandler_AdminOnly(t *testing.T) {
+	svc := new(MockAuditService)
+	r := setupAuditRouter(svc)
+
+	req, _ := http.NewRequest("GET", "/admin/audit/verify", nil)
+	req.Header.Set("Authorization", "Bearer "+createToken("test_user"))
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusForbidden, rr.Code)
+	svc.AssertNotCalled(t, "VerifyChains", mock.Anything, mock.Anything)
+}
+
+func TestCreateCheckpointHandler(t *testing.T) {
+	svc := new(MockAuditService)
+	r := setupAuditRouter(svc)
+	svc.On("CreateCheckpoint", mock.Anything).Return(&model.LedgerCheckpoint{ID: uuid.New(), Root: "abc", Signature: "sig"}, nil)
+
+	req, _ := http.NewRequest("POST", "/admin/audit/checkpoints", nil)
+	req.Header.Set("Authorization", "Bearer "+createToken("admin"))
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusCreated, rr.Code)
+	assert.Contains(t, rr.Body.String(), `"signature":"sig"`)
+}
+
+func TestCreateCheckpointHandler_SigningDisabled(t *testing.T) {
+	svc := new(MockAuditService)
+	r := setupAuditRouter(svc)
+	svc.On("CreateCheckpoint", mock.Anything).Return(nil, service.ErrCheckpointSigningDisabled)
+
+	req, _ := http.NewRequest("POST", "/admin/audit/checkpoints", nil)
+	req.Header.Set("Authorization", "Bearer "+createToken("admin"))
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusServiceUnavailable, rr.Code)
+	assert.Contains(t, rr.Body.String(), `"code":"checkpoint_signing_disabled"`)
+}
+
+func TestGetCheckpointHandler_NotFound(t *testing.T) {
+	svc := new(MockAuditService)
+	r := setupAuditRouter(svc)
+	id := uuid.New().String()
+	svc.On("GetCheckpoint", mock.Anything, id).Return(nil, service.ErrCheckpointNotFound)
+
+	req, _ := http.NewRequest("GET", "/admin/audit/checkpoints/"+id, nil)
+	req.Header.Set("Authorization", "Bearer "+createToken("admin"))
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusNotFound, rr.Code)
+	assert.Contains(t, rr.Body.String(), `"code":"checkpoint_not_found"`)
+}
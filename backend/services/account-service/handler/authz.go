@@ -0,0 +1,97 @@
+package handler
+
+import (
+	"errors"
+	"log"
+	"net/http"
+
+	"go-web-server/services/account-service/handler/middleware"
+	"go-web-server/services/account-service/handler/problem"
+	"go-web-server/services/account-service/model"
+	"go-web-server/services/account-service/service"
+
+	"github.com/google/uuid"
+)
+
+// Customers may only see and move money on their own accounts. The helpers
+// below resolve the authenticated principal to its customer and check
+// ownership; on failure they write the response and return ok == false.
+
+// currentCustomer returns the customer whose external_id is the principal's
+// username.
+func (h *AccountHandler) currentCustomer(w http.ResponseWriter, r *http.Request) (*model.Customer, bool) {
+	p, ok := middleware.PrincipalFromContext(r.Context())
+	if !ok {
+		respondWithError(w, http.StatusUnauthorized, problem.CodeUnauthorized, "Unauthorized")
+		return nil, false
+	}
+
+	c, err := h.service.GetCustomerByExternalID(r.Context(), p.Username)
+	if err != nil {
+		if errors.Is(err, service.ErrCustomerNotFound) {
+			respondWithError(w, http.StatusForbidden, problem.CodeForbidden, "No customer profile for this user")
+			return nil, false
+		}
+		log.Printf("Error resolving customer for %s: %v", p.Username, err)
+		respondWithServiceError(w, err)
+		return nil, false
+	}
+	return c, true
+}
+
+// authorizeCustomer allows the request only if customerID is the caller's own.
+func (h *AccountHandler) authorizeCustomer(w http.ResponseWriter, r *http.Request, customerID uuid.UUID) bool {
+	c, ok := h.currentCustomer(w, r)
+	if !ok {
+		return false
+	}
+	if c.ID != customerID {
+		respondWithError(w, http.StatusForbidden, problem.CodeForbidden, "Access to this customer is not allowed")
+		return false
+	}
+	return true
+}
+
+// ownsAccount reports whether the caller is the customer owning acc, without
+// writing a response. A principal without a customer profile owns nothing.
+func (h *AccountHandler) ownsAccount(r *http.Request, acc *model.Account) (bool, error) {
+	p, ok := middleware.PrincipalFromContext(r.Context())
+	if !ok {
+		return false, nil
+	}
+	c, err := h.service.GetCustomerByExternalID(r.Context(), p.Username)
+	if errors.Is(err, service.ErrCustomerNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return c.ID == acc.CustomerID, nil
+}
+
+// authorizeAccount loads the account and allows the request only if the
+// caller owns it. Unknown accounts give 404, other customers' accounts 403.
+func (h *AccountHandler) authorizeAccount(w http.ResponseWriter, r *http.Request, accountID string) (*model.Account, bool) {
+	if _, err := uuid.Parse(accountID); err != nil {
+		respondWithInvalidParam(w, "accountId", "Account ID must be a valid UUID")
+		return nil, false
+	}
+
+	c, ok := h.currentCustomer(w, r)
+	if !ok {
+		return nil, false
+	}
+
+	acc, err := h.service.GetAccount(r.Context(), accountID)
+	if err != nil {
+		log.Printf("Error getting account %s: %v", accountID, err)
+		respondWithServiceError(w, err)
+		return nil, false
+	}
+	if acc.CustomerID != c.ID {
+		log.Printf("Customer %s denied access to account %s", c.ID, accountID)
+		respondWithError(w, http.StatusForbidden, problem.CodeForbidden, "Access to this account is not allowed")
+		return nil, false
+	}
+	return acc, true
+}
@@ -0,0 +1,77 @@
+package handler
+
+import (
+	"log"
+	"net/http"
+	"time"
+
+	"go-web-server/services/account-service/service"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// CalendarHandler serves the business-day calendars to any authenticated
+// caller.
+type CalendarHandler struct {
+	calendars service.CalendarService
+	auth      func(http.Handler) http.Handler
+}
+
+// NewCalendarHandler creates the handler. auth authenticates the caller.
+func NewCalendarHandler(calendars service.CalendarService, auth func(http.Handler) http.Handler) *CalendarHandler {
+	return &CalendarHandler{calendars: calendars, auth: auth}
+}
+
+func (h *CalendarHandler) RegisterRoutes(r chi.Router) {
+	r.Group(func(r chi.Router) {
+		r.Use(h.auth)
+		r.Get("/calendars", h.ListCalendars)
+		r.Get("/calendars/{country}/days/{date}", h.GetBusinessDay)
+		r.Get("/calendars/{country}/cutoff", h.CheckCutoff)
+	})
+}
+
+func (h *CalendarHandler) ListCalendars(w http.ResponseWriter, r *http.Request) {
+	calendars, err := h.calendars.ListCalendars(r.Context())
+	if err != nil {
+		log.Printf("Error listing calendars: %v", err)
+		respondWithServiceError(w, err)
+		return
+	}
+	respondWithJSON(w, http.StatusOK, map[string]interface{}{"calendars": calendars})
+}
+
+func (h *CalendarHandler) GetBusinessDay(w http.ResponseWriter, r *http.Request) {
+	country, date := chi.URLParam(r, "country"), chi.URLParam(r, "date")
+	day, err := h.calendars.GetBusinessDay(r.Context(), country, date)
+	if err != nil {
+		log.Printf("Error getting business day %s in %s: %v", date, country, err)
+		respondWithServiceError(w, err)
+		return
+	}
+	respondWithJSON(w, http.StatusOK, day)
+}
+
+// CheckCutoff tells whether an operation booked at the optional at
+// timestamp, now by default, makes the country's cut-off and the value date
+// it would take.
+func (h *CalendarHandler) CheckCutoff(w http.ResponseWriter, r *http.Request) {
+	country := chi.URLParam(r, "country")
+	at := time.Now()
+	if v := r.URL.Query().Get("at"); v != "" {
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			respondWithInvalidParam(w, "at", "at: expected RFC 3339 timestamp")
+			return
+		}
+		at = t
+	}
+
+	check, err := h.calendars.CheckCutoff(r.Context(), country, at)
+	if err != nil {
+		log.Printf("Error checking cut-off in %s: %v", country, err)
+		respondWithServiceError(w, err)
+		return
+	}
+	respondWithJSON(w, http.StatusOK, check)
+}
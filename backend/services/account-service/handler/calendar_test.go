@@ -0,0 +1,122 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"go-web-server/services/account-service/calendar"
+	"go-web-server/services/account-service/handler/middleware"
+	"go-web-server/services/account-service/handler/problem"
+	"go-web-server/services/account-service/model"
+	"go-web-server/services/account-service/service"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+type MockCalendarService struct {
+	mock.Mock
+}
+
+func (m *MockCalendarService) ListCalendars(ctx context.Context) ([]model.Calendar, error) {
+	args := m.Called(ctx)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]model.Calendar), args.Error(1)
+}
+
+func (m *MockCalendarService) Calendar(country string) (*calendar.Calendar, error) {
+	args := m.Called(country)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*calendar.Calendar), args.Error(1)
+}
+
+func (m *MockCalendarService) GetBusinessDay(ctx context.Context, country, date string) (*model.BusinessDay, error) {
+	args := m.Called(ctx, country, date)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.BusinessDay), args.Error(1)
+}
+
+func (m *MockCalendarService) CheckCutoff(ctx context.Context, country string, at time.Time) (*model.CutoffCheck, error) {
+	args := m.Called(ctx, country, at)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.CutoffCheck), args.Error(1)
+}
+
+func setupCalendarRouter(calendarSvc *MockCalendarService) chi.Router {
+	r := chi.NewRouter()
+	NewCalendarHandler(calendarSvc, middleware.AuthMiddleware(jwtKey, nil)).RegisterRoutes(r)
+	return r
+}
+
+func TestGetBusinessDayHandler(t *testing.T) {
+	calendarSvc := new(MockCalendarService)
+	r := setupCalendarRouter(calendarSvc)
+	calendarSvc.On("GetBusinessDay", mock.Anything, "PL", "2024-05-03").Return(&model.BusinessDay{
+		Country: "PL", Date: "2024-05-03", Holiday: "Constitution Day", PreviousBusinessDay: "2024-05-02", NextBusinessDay: "2024-05-06",
+	}, nil)
+
+	req, _ := http.NewRequest("GET", "/calendars/PL/days/2024-05-03", nil)
+	req.Header.Set("Authorization", "Bearer "+createToken("test_user"))
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	var day model.BusinessDay
+	json.NewDecoder(rr.Body).Decode(&day)
+	assert.False(t, day.BusinessDay)
+	assert.Equal(t, "Constitution Day", day.Holiday)
+	assert.Equal(t, "2024-05-06", day.NextBusinessDay)
+}
+
+func TestGetBusinessDayHandler_UnknownCountry(t *testing.T) {
+	calendarSvc := new(MockCalendarService)
+	r := setupCalendarRouter(calendarSvc)
+	calendarSvc.On("GetBusinessDay", mock.Anything, "DE", "2024-05-03").Return(nil, service.ErrCalendarNotFound)
+
+	req, _ := http.NewRequest("GET", "/calendars/DE/days/2024-05-03", nil)
+	req.Header.Set("Authorization", "Bearer "+createToken("test_user"))
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusNotFound, rr.Code)
+	assert.Equal(t, problem.CodeCalendarNotFound, decodeProblem(t, rr).Code)
+}
+
+func TestCheckCutoffHandler(t *testing.T) {
+	calendarSvc := new(MockCalendarService)
+	r := setupCalendarRouter(calendarSvc)
+	at := time.Date(2024, 6, 14, 16, 30, 0, 0, time.FixedZone("CEST", 2*60*60))
+	calendarSvc.On("CheckCutoff", mock.Anything, "PL", mock.MatchedBy(at.Equal)).Return(&model.CutoffCheck{
+		Country: "PL", At: at, Cutoff: "16:00", BeforeCutoff: false, ValueDate: "2024-06-17",
+	}, nil)
+
+	req, _ := http.NewRequest("GET", "/calendars/PL/cutoff?at=2024-06-14T16:30:00%2B02:00", nil)
+	req.Header.Set("Authorization", "Bearer "+createToken("test_user"))
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Contains(t, rr.Body.String(), `"valueDate":"2024-06-17"`)
+	calendarSvc.AssertExpectations(t)
+
+	req, _ = http.NewRequest("GET", "/calendars/PL/cutoff?at=2024-06-14", nil)
+	req.Header.Set("Authorization", "Bearer "+createToken("test_user"))
+	rr = httptest.NewRecorder()
+	r.ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+	assert.Equal(t, "at", decodeProblem(t, rr).Field)
+}
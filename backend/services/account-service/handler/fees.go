@@ -0,0 +1,153 @@
+package handler
+
+import (
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"time"
+
+	"go-web-server/services/account-service/handler/middleware"
+	"go-web-server/services/account-service/handler/problem"
+	"go-web-server/services/account-service/model"
+	"go-web-server/services/account-service/money"
+	"go-web-server/services/account-service/service"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// FeeHandler serves fee previews to account owners, the fee schedules of
+// products to any signed-in user, and the admin endpoints that maintain
+// schedules and charge maintenance fees.
+type FeeHandler struct {
+	*AccountHandler
+	fees  service.FeeService
+	admin func(http.Handler) http.Handler
+}
+
+// NewFeeHandler creates the handler. Account ownership is checked through
+// accounts; admin guards the schedule and maintenance endpoints.
+func NewFeeHandler(accounts *AccountHandler, fees service.FeeService, admin func(http.Handler) http.Handler) *FeeHandler {
+	return &FeeHandler{AccountHandler: accounts, fees: fees, admin: admin}
+}
+
+func (h *FeeHandler) RegisterRoutes(r chi.Router) {
+	r.Group(func(r chi.Router) {
+		r.Use(h.auth)
+		r.Get("/accounts/{accountId}/fees/preview", h.PreviewFee)
+		r.Get("/products/{code}/fees", h.ListFeeSchedules)
+
+		r.Group(func(r chi.Router) {
+			r.Use(h.admin)
+			r.Put("/admin/products/{code}/fees/{operation}", h.SaveFeeSchedule)
+			r.Delete("/admin/products/{code}/fees/{operation}", h.DeleteFeeSchedule)
+			r.Post("/admin/maintenance-fees/runs", h.ChargeMaintenance)
+		})
+	})
+}
+
+// PreviewFee prices an operation without booking it, from the operation
+// and amount query parameters. The amount is in the account's currency and
+// may be left out for the maintenance fee.
+func (h *FeeHandler) PreviewFee(w http.ResponseWriter, r *http.Request) {
+	accountID := chi.URLParam(r, "accountId")
+	if _, ok := h.authorizeAccount(w, r, accountID); !ok {
+		return
+	}
+
+	var amount money.Amount
+	if v := r.URL.Query().Get("amount"); v != "" {
+		a, err := money.ParseAmount(v)
+		if err != nil {
+			respondWithInvalidParam(w, "amount", "amount: "+err.Error())
+			return
+		}
+		amount = a
+	}
+
+	op := model.FeeOperation(r.URL.Query().Get("operation"))
+	preview, err := h.fees.PreviewFee(r.Context(), accountID, op, amount)
+	if err != nil {
+		respondWithServiceError(w, err)
+		return
+	}
+	respondWithJSON(w, http.StatusOK, preview)
+}
+
+func (h *FeeHandler) ListFeeSchedules(w http.ResponseWriter, r *http.Request) {
+	schedules, err := h.fees.ListFeeSchedules(r.Context(), chi.URLParam(r, "code"))
+	if err != nil {
+		respondWithServiceError(w, err)
+		return
+	}
+	respondWithJSON(w, http.StatusOK, map[string]interface{}{"feeSchedules": schedules})
+}
+
+// SaveFeeSchedule creates or replaces the schedule named in the path; a
+// product code or operation in the body is ignored.
+func (h *FeeHandler) SaveFeeSchedule(w http.ResponseWriter, r *http.Request) {
+	code, op := chi.URLParam(r, "code"), model.FeeOperation(chi.URLParam(r, "operation"))
+	var s model.FeeSchedule
+	if err := json.NewDecoder(r.Body).Decode(&s); err != nil {
+		log.Printf("Error decoding %s fee schedule of product %s: %v", op, code, err)
+		respondWithError(w, http.StatusBadRequest, problem.CodeMalformedRequest, "Invalid request body")
+		return
+	}
+	s.ProductCode, s.Operation = code, op
+
+	saved, err := h.fees.SaveFeeSchedule(r.Context(), &s)
+	if err != nil {
+		log.Printf("Error saving %s fee schedule of product %s: %v", op, code, err)
+		respondWithServiceError(w, err)
+		return
+	}
+
+	admin, _ := middleware.PrincipalFromContext(r.Context())
+	log.Printf("Fee schedule %s/%s saved by %s", code, op, admin.Username)
+	respondWithJSON(w, http.StatusOK, saved)
+}
+
+func (h *FeeHandler) DeleteFeeSchedule(w http.ResponseWriter, r *http.Request) {
+	code, op := chi.URLParam(r, "code"), model.FeeOperation(chi.URLParam(r, "operation"))
+	if err := h.fees.DeleteFeeSchedule(r.Context(), code, op); err != nil {
+		respondWithServiceError(w, err)
+		return
+	}
+
+	admin, _ := middleware.PrincipalFromContext(r.Context())
+	log.Printf("Fee schedule %s/%s deleted by %s", code, op, admin.Username)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ChargeMaintenance charges a month's maintenance fees now. The body is
+// optional; {"month": "2024-03"} picks the month, which defaults to the
+// previous one.
+func (h *FeeHandler) ChargeMaintenance(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		Month string `json:"month"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil && err != io.EOF {
+		log.Printf("Error decoding maintenance fee run body: %v", err)
+		respondWithError(w, http.StatusBadRequest, problem.CodeMalformedRequest, "Invalid request body")
+		return
+	}
+	var month time.Time
+	if body.Month != "" {
+		m, err := time.Parse("2006-01", body.Month)
+		if err != nil {
+			respondWithInvalidParam(w, "month", "Month must be a month such as 2024-03")
+			return
+		}
+		month = m
+	}
+
+	run, err := h.fees.ChargeMaintenance(r.Context(), month)
+	if err != nil {
+		log.Printf("Error charging maintenance fees: %v", err)
+		respondWithServiceError(w, err)
+		return
+	}
+
+	log.Printf("Maintenance fees for %s charged on %d accounts, %d skipped", run.Month, len(run.Charged), run.Skipped)
+	respondWithJSON(w, http.StatusCreated, run)
+}
@@ -0,0 +1,180 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"go-web-server/services/account-service/handler/middleware"
+	"go-web-server/services/account-service/handler/problem"
+	"go-web-server/services/account-service/model"
+	"go-web-server/services/account-service/money"
+	"go-web-server/services/account-service/service"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+type MockFeeService struct {
+	mock.Mock
+}
+
+func (m *MockFeeService) ListFeeSchedules(ctx context.Context, productCode string) ([]model.FeeSchedule, error) {
+	args := m.Called(ctx, productCode)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]model.FeeSchedule), args.Error(1)
+}
+
+func (m *MockFeeService) SaveFeeSchedule(ctx context.Context, s *model.FeeSchedule) (*model.FeeSchedule, error) {
+	args := m.Called(ctx, s)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.FeeSchedule), args.Error(1)
+}
+
+func (m *MockFeeService) DeleteFeeSchedule(ctx context.Context, productCode string, op model.FeeOperation) error {
+	return m.Called(ctx, productCode, op).Error(0)
+}
+
+func (m *MockFeeService) PreviewFee(ctx context.Context, accountID string, op model.FeeOperation, amount money.Amount) (*model.FeePreview, error) {
+	args := m.Called(ctx, accountID, op, amount)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.FeePreview), args.Error(1)
+}
+
+func (m *MockFeeService) ChargeMaintenance(ctx context.Context, month time.Time) (*model.MaintenanceFeeRun, error) {
+	args := m.Called(ctx, month)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.MaintenanceFeeRun), args.Error(1)
+}
+
+func setupFeeRouter(mockSvc *MockService, feeSvc *MockFeeService) chi.Router {
+	mockSvc.On("GetCustomerByExternalID", mock.Anything, "test_user").Return(testCustomer, nil).Maybe()
+
+	r := chi.NewRouter()
+	accounts := NewAccountHandler(mockSvc, middleware.AuthMiddleware(jwtKey, nil), middleware.RequireAdmin())
+	NewFeeHandler(accounts, feeSvc, middleware.RequireAdmin()).RegisterRoutes(r)
+	return r
+}
+
+func TestPreviewFeeHandler(t *testing.T) {
+	mockSvc, feeSvc := new(MockService), new(MockFeeService)
+	r := setupFeeRouter(mockSvc, feeSvc)
+	acc := ownAccount(mockSvc, uuid.New())
+	feeSvc.On("PreviewFee", mock.Anything, acc.ID.String(), model.FeeTransfer, money.MustParseAmount("250")).Return(&model.FeePreview{
+		AccountID: acc.ID, Operation: model.FeeTransfer, Amount: money.MustParseAmount("250"),
+		Fee: money.MustParseAmount("2.25"), Total: money.MustParseAmount("252.25"), Currency: money.PLN,
+	}, nil)
+
+	req, _ := http.NewRequest("GET", "/accounts/"+acc.ID.String()+"/fees/preview?operation=transfer&amount=250", nil)
+	req.Header.Set("Authorization", "Bearer "+createToken("test_user"))
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	var body map[string]interface{}
+	json.NewDecoder(rr.Body).Decode(&body)
+	assert.Equal(t, "2.25", body["fee"])
+	assert.Equal(t, "252.25", body["total"])
+
+	req, _ = http.NewRequest("GET", "/accounts/"+acc.ID.String()+"/fees/preview?operation=transfer&amount=lots", nil)
+	req.Header.Set("Authorization", "Bearer "+createToken("test_user"))
+	rr = httptest.NewRecorder()
+	r.ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+	feeSvc.AssertNumberOfCalls(t, "PreviewFee", 1)
+}
+
+func TestPreviewFeeHandler_ForeignAccountIsForbidden(t *testing.T) {
+	mockSvc, feeSvc := new(MockService), new(MockFeeService)
+	r := setupFeeRouter(mockSvc, feeSvc)
+	foreign := &model.Account{ID: uuid.New(), CustomerID: uuid.New(), Currency: money.PLN}
+	mockSvc.On("GetAccount", mock.Anything, foreign.ID.String()).Return(foreign, nil)
+
+	req, _ := http.NewRequest("GET", "/accounts/"+foreign.ID.String()+"/fees/preview?operation=withdrawal&amount=10", nil)
+	req.Header.Set("Authorization", "Bearer "+createToken("test_user"))
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusForbidden, rr.Code)
+	feeSvc.AssertNotCalled(t, "PreviewFee", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestSaveFeeScheduleHandler(t *testing.T) {
+	mockSvc, feeSvc := new(MockService), new(MockFeeService)
+	r := setupFeeRouter(mockSvc, feeSvc)
+	feeSvc.On("SaveFeeSchedule", mock.Anything, mock.MatchedBy(func(s *model.FeeSchedule) bool {
+		return s.ProductCode == "current" && s.Operation == model.FeeTransfer && s.Flat == money.MustParseAmount("1") &&
+			s.Percentage != nil && *s.Percentage == money.MustParseRate("0.005")
+	})).Return(&model.FeeSchedule{ProductCode: "current", Operation: model.FeeTransfer}, nil)
+
+	for _, tc := range []struct {
+		user   string
+		status int
+	}{
+		{"test_user", http.StatusForbidden},
+		{"admin", http.StatusOK},
+	} {
+		body := `{"productCode": "ignored", "flat": "1.00", "percentage": "0.005", "minFee": "0", "maxFee": "0"}`
+		req, _ := http.NewRequest("PUT", "/admin/products/current/fees/transfer", bytes.NewBufferString(body))
+		req.Header.Set("Authorization", "Bearer "+createToken(tc.user))
+		rr := httptest.NewRecorder()
+		r.ServeHTTP(rr, req)
+
+		assert.Equal(t, tc.status, rr.Code, tc.user)
+	}
+	feeSvc.AssertExpectations(t)
+}
+
+func TestDeleteFeeScheduleHandler_NotFound(t *testing.T) {
+	mockSvc, feeSvc := new(MockService), new(MockFeeService)
+	r := setupFeeRouter(mockSvc, feeSvc)
+	feeSvc.On("DeleteFeeSchedule", mock.Anything, "current", model.FeeWithdrawal).Return(service.ErrFeeScheduleNotFound)
+
+	req, _ := http.NewRequest("DELETE", "/admin/products/current/fees/withdrawal", nil)
+	req.Header.Set("Authorization", "Bearer "+createToken("admin"))
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusNotFound, rr.Code)
+	assert.Equal(t, problem.CodeFeeScheduleNotFound, decodeProblem(t, rr).Code)
+}
+
+func TestChargeMaintenanceHandler(t *testing.T) {
+	mockSvc, feeSvc := new(MockService), new(MockFeeService)
+	r := setupFeeRouter(mockSvc, feeSvc)
+	month := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	feeSvc.On("ChargeMaintenance", mock.Anything, month).Return(&model.MaintenanceFeeRun{Month: "2024-03", Charged: []model.LedgerEntry{}, Skipped: 2}, nil)
+
+	req, _ := http.NewRequest("POST", "/admin/maintenance-fees/runs", bytes.NewBufferString(`{"month": "2024-03"}`))
+	req.Header.Set("Authorization", "Bearer "+createToken("admin"))
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusCreated, rr.Code)
+	var run model.MaintenanceFeeRun
+	json.NewDecoder(rr.Body).Decode(&run)
+	assert.Equal(t, 2, run.Skipped)
+
+	req, _ = http.NewRequest("POST", "/admin/maintenance-fees/runs", bytes.NewBufferString(`{"month": "March"}`))
+	req.Header.Set("Authorization", "Bearer "+createToken("admin"))
+	rr = httptest.NewRecorder()
+	r.ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+	feeSvc.AssertNumberOfCalls(t, "ChargeMaintenance", 1)
+}
@@ -0,0 +1,182 @@
+package handler
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+
+	"go-web-server/services/account-service/handler/middleware"
+	"go-web-server/services/account-service/handler/problem"
+	"go-web-server/services/account-service/model"
+	"go-web-server/services/account-service/money"
+	"go-web-server/services/account-service/service"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// FXHandler serves currency exchange between a customer's own accounts and
+// the admin endpoints that maintain exchange rates.
+type FXHandler struct {
+	*AccountHandler
+	fx    service.FXService
+	admin func(http.Handler) http.Handler
+}
+
+// NewFXHandler creates the handler. Account ownership is checked through
+// accounts; admin guards the rate endpoints, normally
+// middleware.RequireAdmin.
+func NewFXHandler(accounts *AccountHandler, fx service.FXService, admin func(http.Handler) http.Handler) *FXHandler {
+	return &FXHandler{AccountHandler: accounts, fx: fx, admin: admin}
+}
+
+func (h *FXHandler) RegisterRoutes(r chi.Router) {
+	r.Group(func(r chi.Router) {
+		r.Use(h.auth)
+		r.Post("/accounts/{accountId}/fx/quotes", h.CreateQuote)
+		r.Get("/fx/quotes/{quoteId}", h.GetQuote)
+		r.Post("/fx/quotes/{quoteId}/execute", h.ExecuteQuote)
+
+		r.Group(func(r chi.Router) {
+			r.Use(h.admin)
+			r.Get("/admin/fx/rates", h.ListRates)
+			r.Put("/admin/fx/rates/{base}/{quote}", h.SetRate)
+		})
+	})
+}
+
+func (h *FXHandler) CreateQuote(w http.ResponseWriter, r *http.Request) {
+	accountID := chi.URLParam(r, "accountId")
+	if _, ok := h.authorizeAccount(w, r, accountID); !ok {
+		return
+	}
+
+	var body struct {
+		ToAccountID string       `json:"toAccountId"`
+		Amount      money.Amount `json:"amount"`
+		Currency    string       `json:"currency"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		log.Printf("Error decoding fx quote body for account %s: %v", accountID, err)
+		respondWithError(w, http.StatusBadRequest, problem.CodeMalformedRequest, "Invalid request body")
+		return
+	}
+	if body.ToAccountID == "" {
+		respondWithInvalidParam(w, "toAccountId", "Destination account ID is required")
+		return
+	}
+	// Exchanges only move money between the caller's own accounts.
+	if _, ok := h.authorizeAccount(w, r, body.ToAccountID); !ok {
+		return
+	}
+
+	currency, err := money.ParseCurrency(body.Currency)
+	if err != nil {
+		respondWithInvalidParam(w, "currency", err.Error())
+		return
+	}
+	sell, err := money.NewExact(body.Amount, currency)
+	if err != nil {
+		respondWithInvalidParam(w, "amount", err.Error())
+		return
+	}
+
+	q, err := h.fx.QuoteExchange(r.Context(), accountID, body.ToAccountID, sell)
+	if err != nil {
+		log.Printf("Error quoting exchange from account %s to %s: %v", accountID, body.ToAccountID, err)
+		respondWithServiceError(w, err)
+		return
+	}
+
+	log.Printf("FX quote %s: %s %s for %s %s at %s", q.ID, q.SellAmount, q.SellCurrency, q.BuyAmount, q.BuyCurrency, q.Rate)
+	respondWithJSON(w, http.StatusCreated, q)
+}
+
+func (h *FXHandler) GetQuote(w http.ResponseWriter, r *http.Request) {
+	q, ok := h.authorizeQuote(w, r)
+	if !ok {
+		return
+	}
+	respondWithJSON(w, http.StatusOK, q)
+}
+
+func (h *FXHandler) ExecuteQuote(w http.ResponseWriter, r *http.Request) {
+	q, ok := h.authorizeQuote(w, r)
+	if !ok {
+		return
+	}
+
+	ctx, ok := idempotencyContext(w, r)
+	if !ok {
+		return
+	}
+
+	exchange, err := h.fx.ExecuteQuote(ctx, q.ID.String())
+	if err != nil {
+		log.Printf("Error executing fx quote %s: %v", q.ID, err)
+		respondWithServiceError(w, err)
+		return
+	}
+
+	log.Printf("FX exchange %s executed quote %s", exchange.ReferenceID, q.ID)
+	respondWithJSON(w, http.StatusCreated, exchange)
+}
+
+// authorizeQuote loads the quote named in the path and allows the request
+// only if the caller owns the account it sells from.
+func (h *FXHandler) authorizeQuote(w http.ResponseWriter, r *http.Request) (*model.FXQuote, bool) {
+	quoteID := chi.URLParam(r, "quoteId")
+	q, err := h.fx.GetQuote(r.Context(), quoteID)
+	if err != nil {
+		log.Printf("Error getting fx quote %s: %v", quoteID, err)
+		respondWithServiceError(w, err)
+		return nil, false
+	}
+	if _, ok := h.authorizeAccount(w, r, q.FromAccountID.String()); !ok {
+		return nil, false
+	}
+	return q, true
+}
+
+func (h *FXHandler) ListRates(w http.ResponseWriter, r *http.Request) {
+	rates, err := h.fx.ListRates(r.Context())
+	if err != nil {
+		log.Printf("Error listing fx rates: %v", err)
+		respondWithServiceError(w, err)
+		return
+	}
+	respondWithJSON(w, http.StatusOK, map[string]interface{}{"rates": rates})
+}
+
+func (h *FXHandler) SetRate(w http.ResponseWriter, r *http.Request) {
+	base, err := money.ParseCurrency(chi.URLParam(r, "base"))
+	if err != nil {
+		respondWithInvalidParam(w, "base", err.Error())
+		return
+	}
+	quote, err := money.ParseCurrency(chi.URLParam(r, "quote"))
+	if err != nil {
+		respondWithInvalidParam(w, "quote", err.Error())
+		return
+	}
+
+	var body struct {
+		Bid money.Rate `json:"bid"`
+		Ask money.Rate `json:"ask"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		log.Printf("Error decoding fx rate body for %s/%s: %v", base, quote, err)
+		respondWithError(w, http.StatusBadRequest, problem.CodeMalformedRequest, "Invalid request body")
+		return
+	}
+
+	p, _ := middleware.PrincipalFromContext(r.Context())
+	rate, err := h.fx.SetRate(r.Context(), model.FXRate{Base: base, Quote: quote, Bid: body.Bid, Ask: body.Ask, UpdatedBy: p.Username})
+	if err != nil {
+		log.Printf("Error setting fx rate %s/%s: %v", base, quote, err)
+		respondWithServiceError(w, err)
+		return
+	}
+
+	log.Printf("FX rate %s/%s set to %s/%s by %s", base, quote, rate.Bid, rate.Ask, p.Username)
+	respondWithJSON(w, http.StatusOK, rate)
+}
@@ -0,0 +1,187 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"go-web-server/services/account-service/handler/middleware"
+	"go-web-server/services/account-service/handler/problem"
+	"go-web-server/services/account-service/model"
+	"go-web-server/services/account-service/money"
+	"go-web-server/services/account-service/service"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+type MockFXService struct {
+	mock.Mock
+}
+
+func (m *MockFXService) QuoteExchange(ctx context.Context, fromAccountID, toAccountID string, sell money.Money) (*model.FXQuote, error) {
+	args := m.Called(ctx, fromAccountID, toAccountID, sell)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.FXQuote), args.Error(1)
+}
+
+func (m *MockFXService) GetQuote(ctx context.Context, quoteID string) (*model.FXQuote, error) {
+	args := m.Called(ctx, quoteID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.FXQuote), args.Error(1)
+}
+
+func (m *MockFXService) ExecuteQuote(ctx context.Context, quoteID string) (*model.CurrencyExchange, error) {
+	args := m.Called(ctx, quoteID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.CurrencyExchange), args.Error(1)
+}
+
+func (m *MockFXService) SetRate(ctx context.Context, rate model.FXRate) (*model.FXRate, error) {
+	args := m.Called(ctx, rate)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.FXRate), args.Error(1)
+}
+
+func (m *MockFXService) ListRates(ctx context.Context) ([]model.FXRate, error) {
+	args := m.Called(ctx)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]model.FXRate), args.Error(1)
+}
+
+func setupFXRouter(mockSvc *MockService, fxSvc *MockFXService) chi.Router {
+	mockSvc.On("GetCustomerByExternalID", mock.Anything, "test_user").Return(testCustomer, nil).Maybe()
+
+	r := chi.NewRouter()
+	accounts := NewAccountHandler(mockSvc, middleware.AuthMiddleware(jwtKey, nil), middleware.RequireAdmin())
+	NewFXHandler(accounts, fxSvc, middleware.RequireAdmin()).RegisterRoutes(r)
+	return r
+}
+
+func TestCreateQuoteHandler(t *testing.T) {
+	mockSvc, fxSvc := new(MockService), new(MockFXService)
+	r := setupFXRouter(mockSvc, fxSvc)
+	from, to := ownAccount(mockSvc, uuid.New()), ownAccount(mockSvc, uuid.New())
+	sell := money.New(money.MustParseAmount("100"), money.PLN)
+	quote := &model.FXQuote{ID: uuid.New(), FromAccountID: from.ID, ToAccountID: to.ID, Rate: money.MustParseRate("0.2298"), ExpiresAt: time.Now().Add(time.Minute)}
+	fxSvc.On("QuoteExchange", mock.Anything, from.ID.String(), to.ID.String(), sell).Return(quote, nil)
+
+	reqBody, _ := json.Marshal(map[string]string{"toAccountId": to.ID.String(), "amount": "100", "currency": "PLN"})
+	req, _ := http.NewRequest("POST", "/accounts/"+from.ID.String()+"/fx/quotes", bytes.NewBuffer(reqBody))
+	req.Header.Set("Authorization", "Bearer "+createToken("test_user"))
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusCreated, rr.Code)
+	var body map[string]interface{}
+	json.NewDecoder(rr.Body).Decode(&body)
+	assert.Equal(t, "0.2298", body["rate"])
+	fxSvc.AssertExpectations(t)
+}
+
+func TestCreateQuoteHandler_ForeignDestinationIsForbidden(t *testing.T) {
+	mockSvc, fxSvc := new(MockService), new(MockFXService)
+	r := setupFXRouter(mockSvc, fxSvc)
+	from := ownAccount(mockSvc, uuid.New())
+	foreign := &model.Account{ID: uuid.New(), CustomerID: uuid.New(), Currency: money.EUR}
+	mockSvc.On("GetAccount", mock.Anything, foreign.ID.String()).Return(foreign, nil)
+
+	reqBody, _ := json.Marshal(map[string]string{"toAccountId": foreign.ID.String(), "amount": "100", "currency": "PLN"})
+	req, _ := http.NewRequest("POST", "/accounts/"+from.ID.String()+"/fx/quotes", bytes.NewBuffer(reqBody))
+	req.Header.Set("Authorization", "Bearer "+createToken("test_user"))
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusForbidden, rr.Code)
+	fxSvc.AssertNotCalled(t, "QuoteExchange", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestExecuteQuoteHandler(t *testing.T) {
+	mockSvc, fxSvc := new(MockService), new(MockFXService)
+	r := setupFXRouter(mockSvc, fxSvc)
+	from := ownAccount(mockSvc, uuid.New())
+	quote := &model.FXQuote{ID: uuid.New(), FromAccountID: from.ID}
+	exchange := &model.CurrencyExchange{ReferenceID: uuid.New(), QuoteID: quote.ID}
+	fxSvc.On("GetQuote", mock.Anything, quote.ID.String()).Return(quote, nil)
+	fxSvc.On("ExecuteQuote", mock.MatchedBy(func(ctx context.Context) bool {
+		key, _ := service.IdempotencyKeyFromContext(ctx)
+		return key == "fx-1"
+	}), quote.ID.String()).Return(exchange, nil)
+
+	req, _ := http.NewRequest("POST", "/fx/quotes/"+quote.ID.String()+"/execute", nil)
+	req.Header.Set("Authorization", "Bearer "+createToken("test_user"))
+	req.Header.Set("Idempotency-Key", "fx-1")
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusCreated, rr.Code)
+	var returned model.CurrencyExchange
+	json.NewDecoder(rr.Body).Decode(&returned)
+	assert.Equal(t, exchange.ReferenceID, returned.ReferenceID)
+	fxSvc.AssertExpectations(t)
+}
+
+func TestExecuteQuoteHandler_ErrorCodes(t *testing.T) {
+	for _, tc := range []struct {
+		err    error
+		status int
+		code   problem.Code
+	}{
+		{service.ErrQuoteExpired, http.StatusUnprocessableEntity, problem.CodeQuoteExpired},
+		{service.ErrQuoteExecuted, http.StatusConflict, problem.CodeQuoteAlreadyExecuted},
+		{&service.InsufficientFundsError{}, http.StatusUnprocessableEntity, problem.CodeInsufficientFunds},
+	} {
+		mockSvc, fxSvc := new(MockService), new(MockFXService)
+		r := setupFXRouter(mockSvc, fxSvc)
+		quote := &model.FXQuote{ID: uuid.New(), FromAccountID: ownAccount(mockSvc, uuid.New()).ID}
+		fxSvc.On("GetQuote", mock.Anything, quote.ID.String()).Return(quote, nil)
+		fxSvc.On("ExecuteQuote", mock.Anything, quote.ID.String()).Return(nil, tc.err)
+
+		req, _ := http.NewRequest("POST", "/fx/quotes/"+quote.ID.String()+"/execute", nil)
+		req.Header.Set("Authorization", "Bearer "+createToken("test_user"))
+		rr := httptest.NewRecorder()
+		r.ServeHTTP(rr, req)
+
+		assert.Equal(t, tc.status, rr.Code, tc.err.Error())
+		assert.Equal(t, tc.code, decodeProblem(t, rr).Code)
+	}
+}
+
+func TestSetRateHandler_RequiresAdmin(t *testing.T) {
+	mockSvc, fxSvc := new(MockService), new(MockFXService)
+	r := setupFXRouter(mockSvc, fxSvc)
+	stored := &model.FXRate{Base: money.EUR, Quote: money.PLN, Bid: money.MustParseRate("4.30"), Ask: money.MustParseRate("4.40"), UpdatedBy: "admin"}
+	fxSvc.On("SetRate", mock.Anything, model.FXRate{Base: money.EUR, Quote: money.PLN, Bid: stored.Bid, Ask: stored.Ask, UpdatedBy: "admin"}).Return(stored, nil)
+
+	for _, tc := range []struct {
+		user   string
+		status int
+	}{
+		{"test_user", http.StatusForbidden},
+		{"admin", http.StatusOK},
+	} {
+		req, _ := http.NewRequest("PUT", "/admin/fx/rates/EUR/pln", bytes.NewBufferString(`{"bid": "4.30", "ask": "4.40"}`))
+		req.Header.Set("Authorization", "Bearer "+createToken(tc.user))
+		rr := httptest.NewRecorder()
+		r.ServeHTTP(rr, req)
+
+		assert.Equal(t, tc.status, rr.Code, tc.user)
+	}
+	fxSvc.AssertExpectations(t)
+}
@@ -0,0 +1,68 @@
+package handler
+
+import (
+	"log"
+	"net/http"
+	"time"
+
+	"go-web-server/services/account-service/service"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// GLHandler serves general-ledger reports to admins.
+type GLHandler struct {
+	gl    service.GLService
+	auth  func(http.Handler) http.Handler
+	admin func(http.Handler) http.Handler
+}
+
+// NewGLHandler creates the handler. auth authenticates the caller and admin,
+// normally middleware.RequireAdmin, restricts every route to admins.
+func NewGLHandler(gl service.GLService, auth, admin func(http.Handler) http.Handler) *GLHandler {
+	return &GLHandler{gl: gl, auth: auth, admin: admin}
+}
+
+func (h *GLHandler) RegisterRoutes(r chi.Router) {
+	r.Group(func(r chi.Router) {
+		r.Use(h.auth, h.admin)
+		r.Get("/admin/gl/accounts", h.ListAccounts)
+		r.Get("/admin/gl/trial-balance", h.TrialBalance)
+	})
+}
+
+func (h *GLHandler) ListAccounts(w http.ResponseWriter, r *http.Request) {
+	accounts, err := h.gl.ListAccounts(r.Context())
+	if err != nil {
+		log.Printf("Error listing GL accounts: %v", err)
+		respondWithServiceError(w, err)
+		return
+	}
+	respondWithJSON(w, http.StatusOK, map[string]interface{}{"accounts": accounts})
+}
+
+// TrialBalance reports debits and credits per GL account and currency. The
+// optional asOf parameter limits it to lines posted before that instant; a
+// plain date includes the whole day.
+func (h *GLHandler) TrialBalance(w http.ResponseWriter, r *http.Request) {
+	var asOf *time.Time
+	if v := r.URL.Query().Get("asOf"); v != "" {
+		t, err := parseTimeParam(v, true)
+		if err != nil {
+			respondWithInvalidParam(w, "asOf", "asOf: "+err.Error())
+			return
+		}
+		asOf = t
+	}
+
+	tb, err := h.gl.TrialBalance(r.Context(), asOf)
+	if err != nil {
+		log.Printf("Error computing trial balance: %v", err)
+		respondWithServiceError(w, err)
+		return
+	}
+	if !tb.Balanced {
+		log.Printf("Trial balance does not balance: %+v", tb.Currencies)
+	}
+	respondWithJSON(w, http.StatusOK, tb)
+}
@@ -0,0 +1,76 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"go-web-server/services/account-service/handler/middleware"
+	"go-web-server/services/account-service/model"
+	"go-web-server/services/account-service/money"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+type MockGLService struct {
+	mock.Mock
+}
+
+func (m *MockGLService) ListAccounts(ctx context.Context) ([]model.GLAccount, error) {
+	args := m.Called(ctx)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]model.GLAccount), args.Error(1)
+}
+
+func (m *MockGLService) TrialBalance(ctx context.Context, asOf *time.Time) (*model.TrialBalance, error) {
+	args := m.Called(ctx, asOf)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.TrialBalance), args.Error(1)
+}
+
+func setupGLRouter(glSvc *MockGLService) chi.Router {
+	r := chi.NewRouter()
+	NewGLHandler(glSvc, middleware.AuthMiddleware(jwtKey, nil), middleware.RequireAdmin()).RegisterRoutes(r)
+	return r
+}
+
+func TestTrialBalanceHandler(t *testing.T) {
+	glSvc := new(MockGLService)
+	r := setupGLRouter(glSvc)
+	// A date covers the whole day.
+	asOf := time.Date(2024, 4, 1, 0, 0, 0, 0, time.UTC)
+	tb := &model.TrialBalance{AsOf: &asOf, Balanced: true, Currencies: []model.CurrencyTrialBalance{
+		{Currency: money.PLN, TotalDebit: money.MustParseAmount("10"), TotalCredit: money.MustParseAmount("10"), Balanced: true},
+	}}
+	glSvc.On("TrialBalance", mock.Anything, &asOf).Return(tb, nil)
+
+	req, _ := http.NewRequest("GET", "/admin/gl/trial-balance?asOf=2024-03-31", nil)
+	req.Header.Set("Authorization", "Bearer "+createToken("admin"))
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Contains(t, rr.Body.String(), `"totalDebit":"10.00"`)
+	glSvc.AssertExpectations(t)
+}
+
+func TestTrialBalanceHandler_AdminOnly(t *testing.T) {
+	glSvc := new(MockGLService)
+	r := setupGLRouter(glSvc)
+
+	req, _ := http.NewRequest("GET", "/admin/gl/trial-balance", nil)
+	req.Header.Set("Authorization", "Bearer "+createToken("test_user"))
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusForbidden, rr.Code)
+	glSvc.AssertNotCalled(t, "TrialBalance", mock.Anything, mock.Anything)
+}
@@ -1,32 +1,54 @@
 package handler
 
 import (
+	"context"
 	"encoding/json"
+	"fmt"
 	"go-web-server/services/account-service/handler/middleware"
+	"go-web-server/services/account-service/handler/problem"
 	"go-web-server/services/account-service/model"
+	"go-web-server/services/account-service/money"
 	"go-web-server/services/account-service/service"
 	"log"
 	"net/http"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
 )
 
 type AccountHandler struct {
 	service service.AccountService
+	auth    func(http.Handler) http.Handler
+	admin   func(http.Handler) http.Handler
 }
 
-func NewAccountHandler(service service.AccountService) *AccountHandler {
-	return &AccountHandler{service: service}
+// NewAccountHandler creates the handler. auth guards every route except
+// /health, normally middleware.AuthMiddleware configured with the gateway's
+// signing key and revocation list. admin, normally middleware.RequireAdmin,
+// guards the lifecycle changes only the bank may make.
+func NewAccountHandler(service service.AccountService, auth, admin func(http.Handler) http.Handler) *AccountHandler {
+	return &AccountHandler{service: service, auth: auth, admin: admin}
 }
 
 func (h *AccountHandler) RegisterRoutes(r chi.Router) {
 	r.Get("/health", h.HealthHandler)
 
 	r.Group(func(r chi.Router) {
-		r.Use(middleware.AuthMiddleware)
+		r.Use(h.auth)
 		r.Post("/accounts", h.CreateAccount)
+		r.Get("/accounts", h.ListAccounts)
 		r.Get("/accounts/{accountId}", h.GetAccount)
 		r.Post("/accounts/{accountId}/balance", h.UpdateBalance)
+		r.Post("/accounts/{accountId}/transfers", h.Transfer)
+		r.Get("/accounts/{accountId}/ledger", h.ListLedgerEntries)
+		r.Post("/accounts/{accountId}/freeze", h.FreezeAccount)
+		r.With(h.admin).Post("/accounts/{accountId}/unfreeze", h.UnfreezeAccount)
+		r.Post("/accounts/{accountId}/close", h.CloseAccount)
+		r.Get("/customers", h.GetCustomer)
+		r.Get("/customers/{customerId}/primary-account", h.GetPrimaryAccount)
 	})
 }
 
@@ -36,20 +58,34 @@ func (h *AccountHandler) HealthHandler(w http.ResponseWriter, r *http.Request) {
 
 func (h *AccountHandler) CreateAccount(w http.ResponseWriter, r *http.Request) {
 	var body struct {
-		CustomerID string `json:"customerId"`
-		Currency   string `json:"currency"`
+		CustomerID  string `json:"customerId"`
+		Currency    string `json:"currency"`
+		ProductCode string `json:"productCode"`
 	}
 
 	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
 		log.Printf("Error decoding create account body: %v", err)
-		respondWithError(w, http.StatusBadRequest, "Invalid request body")
+		respondWithError(w, http.StatusBadRequest, problem.CodeMalformedRequest, "Invalid request body")
 		return
 	}
 
-	acc, err := h.service.CreateAccount(r.Context(), body.CustomerID, body.Currency)
+	// Customers open accounts for themselves; customerId may be omitted.
+	c, ok := h.currentCustomer(w, r)
+	if !ok {
+		return
+	}
+	if body.CustomerID == "" {
+		body.CustomerID = c.ID.String()
+	}
+	if id, err := uuid.Parse(body.CustomerID); err != nil || id != c.ID {
+		respondWithError(w, http.StatusForbidden, problem.CodeForbidden, "Access to this customer is not allowed")
+		return
+	}
+
+	acc, err := h.service.CreateAccount(r.Context(), body.CustomerID, body.Currency, body.ProductCode)
 	if err != nil {
 		log.Printf("Error creating account: %v", err)
-		respondWithError(w, http.StatusInternalServerError, err.Error())
+		respondWithServiceError(w, err)
 		return
 	}
 
@@ -57,17 +93,75 @@ func (h *AccountHandler) CreateAccount(w http.ResponseWriter, r *http.Request) {
 	respondWithJSON(w, http.StatusCreated, acc)
 }
 
+func (h *AccountHandler) ListAccounts(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+
+	caller, ok := h.currentCustomer(w, r)
+	if !ok {
+		return
+	}
+
+	// customerId defaults to the caller and may only name the caller.
+	customerID := caller.ID
+	if v := q.Get("customerId"); v != "" {
+		id, err := uuid.Parse(v)
+		if err != nil {
+			respondWithInvalidParam(w, "customerId", "customerId must be a valid UUID")
+			return
+		}
+		if id != customerID {
+			respondWithError(w, http.StatusForbidden, problem.CodeForbidden, "Access to this customer is not allowed")
+			return
+		}
+	}
+
+	filter := model.AccountFilter{
+		CustomerID: customerID,
+		Status:     model.AccountStatus(q.Get("status")),
+	}
+
+	var err error
+	if c := q.Get("currency"); c != "" {
+		filter.Currency, err = money.ParseCurrency(c)
+		if err != nil {
+			respondWithInvalidParam(w, "currency", err.Error())
+			return
+		}
+	}
+	if c := q.Get("cursor"); c != "" {
+		filter.After, err = model.DecodeCursor(c)
+		if err != nil {
+			respondWithInvalidParam(w, "cursor", err.Error())
+			return
+		}
+	}
+	if l := q.Get("limit"); l != "" {
+		filter.Limit, err = strconv.Atoi(l)
+		if err != nil || filter.Limit <= 0 {
+			respondWithInvalidParam(w, "limit", "limit must be a positive integer")
+			return
+		}
+	}
+
+	page, err := h.service.ListAccounts(r.Context(), filter)
+	if err != nil {
+		log.Printf("Error listing accounts for customer %s: %v", customerID, err)
+		respondWithServiceError(w, err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, page)
+}
+
 func (h *AccountHandler) GetAccount(w http.ResponseWriter, r *http.Request) {
 	accountID := chi.URLParam(r, "accountId")
 	if accountID == "" {
-		respondWithError(w, http.StatusBadRequest, "Account ID is required")
+		respondWithInvalidParam(w, "accountId", "Account ID is required")
 		return
 	}
 
-	acc, err := h.service.GetAccount(r.Context(), accountID)
-	if err != nil {
-		log.Printf("Error getting account %s: %v", accountID, err)
-		respondWithError(w, http.StatusNotFound, "Account not found")
+	acc, ok := h.authorizeAccount(w, r, accountID)
+	if !ok {
 		return
 	}
 
@@ -77,35 +171,359 @@ func (h *AccountHandler) GetAccount(w http.ResponseWriter, r *http.Request) {
 func (h *AccountHandler) UpdateBalance(w http.ResponseWriter, r *http.Request) {
 	accountID := chi.URLParam(r, "accountId")
 	if accountID == "" {
-		respondWithError(w, http.StatusBadRequest, "Account ID is required")
+		respondWithInvalidParam(w, "accountId", "Account ID is required")
+		return
+	}
+	if _, ok := h.authorizeAccount(w, r, accountID); !ok {
 		return
 	}
 
 	var body struct {
-		Amount      float64               `json:"amount"`
+		Amount      money.Amount          `json:"amount"`
+		Currency    string                `json:"currency"`
 		Type        model.LedgerEntryType `json:"type"`
 		Description string                `json:"description"`
 	}
 
 	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
 		log.Printf("Error decoding update balance body for account %s: %v", accountID, err)
-		respondWithError(w, http.StatusBadRequest, "Invalid request body")
+		respondWithError(w, http.StatusBadRequest, problem.CodeMalformedRequest, "Invalid request body")
 		return
 	}
 
-	err := h.service.UpdateBalance(r.Context(), accountID, body.Amount, body.Type, body.Description)
+	currency, err := money.ParseCurrency(body.Currency)
+	if err != nil {
+		respondWithInvalidParam(w, "currency", err.Error())
+		return
+	}
+	amount, err := money.NewExact(body.Amount, currency)
+	if err != nil {
+		respondWithInvalidParam(w, "amount", err.Error())
+		return
+	}
+
+	ctx, ok := idempotencyContext(w, r)
+	if !ok {
+		return
+	}
+
+	_, err = h.service.UpdateBalance(ctx, accountID, amount, body.Type, body.Description)
 	if err != nil {
 		log.Printf("Error updating balance for account %s: %v", accountID, err)
-		respondWithError(w, http.StatusBadRequest, err.Error())
+		respondWithServiceError(w, err)
 		return
 	}
 
-	log.Printf("Balance updated for account %s: %.2f (%s)", accountID, body.Amount, body.Type)
+	log.Printf("Balance updated for account %s: %s (%s)", accountID, amount, body.Type)
 	w.WriteHeader(http.StatusNoContent)
 }
 
-func respondWithError(w http.ResponseWriter, code int, message string) {
-	respondWithJSON(w, code, map[string]string{"error": message})
+func (h *AccountHandler) Transfer(w http.ResponseWriter, r *http.Request) {
+	accountID := chi.URLParam(r, "accountId")
+	if accountID == "" {
+		respondWithInvalidParam(w, "accountId", "Account ID is required")
+		return
+	}
+	// Only the source account has to belong to the caller.
+	if _, ok := h.authorizeAccount(w, r, accountID); !ok {
+		return
+	}
+
+	var body struct {
+		ToAccountID string       `json:"toAccountId"`
+		Amount      money.Amount `json:"amount"`
+		Currency    string       `json:"currency"`
+		Description string       `json:"description"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		log.Printf("Error decoding transfer body for account %s: %v", accountID, err)
+		respondWithError(w, http.StatusBadRequest, problem.CodeMalformedRequest, "Invalid request body")
+		return
+	}
+
+	if body.ToAccountID == "" {
+		respondWithInvalidParam(w, "toAccountId", "Destination account ID is required")
+		return
+	}
+
+	currency, err := money.ParseCurrency(body.Currency)
+	if err != nil {
+		respondWithInvalidParam(w, "currency", err.Error())
+		return
+	}
+	amount, err := money.NewExact(body.Amount, currency)
+	if err != nil {
+		respondWithInvalidParam(w, "amount", err.Error())
+		return
+	}
+
+	ctx, ok := idempotencyContext(w, r)
+	if !ok {
+		return
+	}
+
+	transfer, err := h.service.Transfer(ctx, accountID, body.ToAccountID, amount, body.Description)
+	if err != nil {
+		log.Printf("Error transferring from account %s to %s: %v", accountID, body.ToAccountID, err)
+		respondWithServiceError(w, err)
+		return
+	}
+
+	log.Printf("Transfer %s: %s from %s to %s", transfer.ReferenceID, amount, accountID, body.ToAccountID)
+	respondWithJSON(w, http.StatusCreated, transfer)
+}
+
+// statusChangeBody is the request body of the freeze, unfreeze and close
+// endpoints. PayoutAccountID is only read by close.
+type statusChangeBody struct {
+	Reason          model.StatusReason `json:"reason"`
+	Note            string             `json:"note"`
+	PayoutAccountID string             `json:"payoutAccountId"`
+}
+
+func (h *AccountHandler) FreezeAccount(w http.ResponseWriter, r *http.Request) {
+	h.customerStatusChange(w, r, func(w http.ResponseWriter, r *http.Request, accountID string, body statusChangeBody) {
+		acc, err := h.service.FreezeAccount(r.Context(), accountID, body.Reason, body.Note)
+		respondWithStatusChange(w, accountID, body, acc, err)
+	})
+}
+
+// UnfreezeAccount is routed through h.admin: only the bank lifts a freeze,
+// whoever placed it.
+func (h *AccountHandler) UnfreezeAccount(w http.ResponseWriter, r *http.Request) {
+	accountID := chi.URLParam(r, "accountId")
+	body, ok := decodeStatusChange(w, r, accountID)
+	if !ok {
+		return
+	}
+	acc, err := h.service.UnfreezeAccount(r.Context(), accountID, body.Reason, body.Note)
+	respondWithStatusChange(w, accountID, body, acc, err)
+}
+
+func (h *AccountHandler) CloseAccount(w http.ResponseWriter, r *http.Request) {
+	h.customerStatusChange(w, r, func(w http.ResponseWriter, r *http.Request, accountID string, body statusChangeBody) {
+		acc, err := h.service.CloseAccount(r.Context(), accountID, body.Reason, body.Note, body.PayoutAccountID)
+		respondWithStatusChange(w, accountID, body, acc, err)
+	})
+}
+
+// customerStatusChange runs change for the owner of the account named in the
+// path when it is one a customer may make: a customer_request on an active
+// account, paying out only to another of their accounts. Any other change,
+// such as one with a bank reason code or closing a frozen account, runs only
+// for an admin, through h.admin.
+func (h *AccountHandler) customerStatusChange(w http.ResponseWriter, r *http.Request, change func(http.ResponseWriter, *http.Request, string, statusChangeBody)) {
+	accountID := chi.URLParam(r, "accountId")
+	if _, err := uuid.Parse(accountID); err != nil {
+		respondWithInvalidParam(w, "accountId", "Account ID must be a valid UUID")
+		return
+	}
+	body, ok := decodeStatusChange(w, r, accountID)
+	if !ok {
+		return
+	}
+	acc, err := h.service.GetAccount(r.Context(), accountID)
+	if err != nil {
+		log.Printf("Error getting account %s: %v", accountID, err)
+		respondWithServiceError(w, err)
+		return
+	}
+	owner, err := h.ownsAccount(r, acc)
+	if err != nil {
+		log.Printf("Error resolving owner of account %s: %v", accountID, err)
+		respondWithServiceError(w, err)
+		return
+	}
+
+	if !owner || body.Reason != model.ReasonCustomerRequest || acc.Status != model.AccountActive {
+		h.admin(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			change(w, r, accountID, body)
+		})).ServeHTTP(w, r)
+		return
+	}
+	// The remaining balance may only be paid out to the caller's own account.
+	if body.PayoutAccountID != "" {
+		if _, ok := h.authorizeAccount(w, r, body.PayoutAccountID); !ok {
+			return
+		}
+	}
+	change(w, r, accountID, body)
+}
+
+// decodeStatusChange decodes the body of a lifecycle operation.
+func decodeStatusChange(w http.ResponseWriter, r *http.Request, accountID string) (statusChangeBody, bool) {
+	var body statusChangeBody
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		log.Printf("Error decoding status change body for account %s: %v", accountID, err)
+		respondWithError(w, http.StatusBadRequest, problem.CodeMalformedRequest, "Invalid request body")
+		return body, false
+	}
+	return body, true
+}
+
+func respondWithStatusChange(w http.ResponseWriter, accountID string, body statusChangeBody, acc *model.Account, err error) {
+	if err != nil {
+		log.Printf("Error changing status of account %s: %v", accountID, err)
+		respondWithServiceError(w, err)
+		return
+	}
+
+	log.Printf("Account %s is now %s (%s)", accountID, acc.Status, body.Reason)
+	respondWithJSON(w, http.StatusOK, acc)
+}
+
+func (h *AccountHandler) ListLedgerEntries(w http.ResponseWriter, r *http.Request) {
+	accountID, err := uuid.Parse(chi.URLParam(r, "accountId"))
+	if err != nil {
+		respondWithInvalidParam(w, "accountId", "Account ID must be a valid UUID")
+		return
+	}
+	if _, ok := h.authorizeAccount(w, r, accountID.String()); !ok {
+		return
+	}
+
+	q := r.URL.Query()
+	filter := model.LedgerFilter{AccountID: accountID}
+
+	if v := q.Get("from"); v != "" {
+		if filter.From, err = parseTimeParam(v, false); err != nil {
+			respondWithInvalidParam(w, "from", "from: "+err.Error())
+			return
+		}
+	}
+	if v := q.Get("to"); v != "" {
+		if filter.To, err = parseTimeParam(v, true); err != nil {
+			respondWithInvalidParam(w, "to", "to: "+err.Error())
+			return
+		}
+	}
+	for _, v := range q["type"] {
+		for _, t := range strings.Split(v, ",") {
+			filter.Types = append(filter.Types, model.LedgerEntryType(strings.TrimSpace(t)))
+		}
+	}
+	if v := q.Get("minAmount"); v != "" {
+		a, err := money.ParseAmount(v)
+		if err != nil {
+			respondWithInvalidParam(w, "minAmount", "minAmount: "+err.Error())
+			return
+		}
+		filter.MinAmount = &a
+	}
+	if v := q.Get("maxAmount"); v != "" {
+		a, err := money.ParseAmount(v)
+		if err != nil {
+			respondWithInvalidParam(w, "maxAmount", "maxAmount: "+err.Error())
+			return
+		}
+		filter.MaxAmount = &a
+	}
+	if v := q.Get("cursor"); v != "" {
+		if filter.Before, err = model.DecodeCursor(v); err != nil {
+			respondWithInvalidParam(w, "cursor", err.Error())
+			return
+		}
+	}
+	if v := q.Get("limit"); v != "" {
+		filter.Limit, err = strconv.Atoi(v)
+		if err != nil || filter.Limit <= 0 {
+			respondWithInvalidParam(w, "limit", "limit must be a positive integer")
+			return
+		}
+	}
+
+	page, err := h.service.ListLedgerEntries(r.Context(), filter)
+	if err != nil {
+		log.Printf("Error listing ledger for account %s: %v", accountID, err)
+		respondWithServiceError(w, err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, page)
+}
+
+func (h *AccountHandler) GetCustomer(w http.ResponseWriter, r *http.Request) {
+	c, ok := h.currentCustomer(w, r)
+	if !ok {
+		return
+	}
+
+	// Callers may only look themselves up; externalId is optional.
+	if externalID := r.URL.Query().Get("externalId"); externalID != "" && externalID != c.ExternalID {
+		respondWithError(w, http.StatusForbidden, problem.CodeForbidden, "Access to this customer is not allowed")
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, c)
+}
+
+func (h *AccountHandler) GetPrimaryAccount(w http.ResponseWriter, r *http.Request) {
+	customerID, err := uuid.Parse(chi.URLParam(r, "customerId"))
+	if err != nil {
+		respondWithInvalidParam(w, "customerId", "Customer ID must be a valid UUID")
+		return
+	}
+	if !h.authorizeCustomer(w, r, customerID) {
+		return
+	}
+
+	acc, err := h.service.GetPrimaryAccount(r.Context(), customerID)
+	if err != nil {
+		log.Printf("Error getting primary account for customer %s: %v", customerID, err)
+		respondWithServiceError(w, err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, acc)
+}
+
+// parseTimeParam accepts an RFC 3339 timestamp or a plain YYYY-MM-DD date.
+// A date used as an exclusive upper bound covers the whole day.
+func parseTimeParam(v string, endOfDay bool) (*time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, v); err == nil {
+		return &t, nil
+	}
+	t, err := time.Parse("2006-01-02", v)
+	if err != nil {
+		return nil, fmt.Errorf("expected RFC 3339 timestamp or YYYY-MM-DD date")
+	}
+	if endOfDay {
+		t = t.AddDate(0, 0, 1)
+	}
+	return &t, nil
+}
+
+// idempotencyContext copies the Idempotency-Key header into the request
+// context for the service layer, scoped to the authenticated caller. It
+// writes a 400 and returns false if the key is too long to be stored.
+func idempotencyContext(w http.ResponseWriter, r *http.Request) (context.Context, bool) {
+	key := r.Header.Get("Idempotency-Key")
+	if len(key) > service.MaxIdempotencyKeyLength {
+		respondWithInvalidParam(w, "Idempotency-Key", "Idempotency-Key is too long")
+		return nil, false
+	}
+	p, _ := middleware.PrincipalFromContext(r.Context())
+	return service.WithIdempotencyKey(r.Context(), p.Username, key), true
+}
+
+// respondWithError writes an application/problem+json error response.
+func respondWithError(w http.ResponseWriter, status int, code problem.Code, message string) {
+	problem.Error(w, status, code, message)
+}
+
+// respondWithInvalidParam rejects a malformed request parameter before it
+// reaches the service.
+func respondWithInvalidParam(w http.ResponseWriter, field, message string) {
+	p := problem.New(http.StatusBadRequest, problem.CodeValidation, message)
+	p.Field = field
+	problem.Write(w, p)
+}
+
+// respondWithServiceError maps an AccountService error to its status code
+// and problem code.
+func respondWithServiceError(w http.ResponseWriter, err error) {
+	problem.Write(w, problem.FromError(err))
 }
 
 func respondWithJSON(w http.ResponseWriter, code int, payload interface{}) {
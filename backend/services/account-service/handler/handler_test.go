@@ -4,12 +4,16 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"testing"
 	"time"
 
+	"go-web-server/services/account-service/handler/middleware"
+	"go-web-server/services/account-service/handler/problem"
 	"go-web-server/services/account-service/model"
+	"go-web-server/services/account-service/money"
 	"go-web-server/services/account-service/service"
 
 	"github.com/go-chi/chi/v5"
@@ -19,13 +23,20 @@ import (
 	"github.com/stretchr/testify/mock"
 )
 
-var jwtKey = []byte("my_secret_key_for_testing_only")
+var jwtKey = []byte("test_signing_key_of_at_least_32_bytes")
+
+// testRoles are the role claims of the test users that hold one.
+var testRoles = map[string]string{"admin": middleware.RoleAdmin, "merchant": middleware.RoleMerchant}
 
 func createToken(username string) string {
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+	claims := jwt.MapClaims{
 		"username": username,
 		"exp":      time.Now().Add(time.Hour).Unix(),
-	})
+	}
+	if role, ok := testRoles[username]; ok {
+		claims["role"] = role
+	}
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
 	tokenString, _ := token.SignedString(jwtKey)
 	return tokenString
 }
@@ -34,8 +45,8 @@ type MockService struct {
 	mock.Mock
 }
 
-func (m *MockService) CreateAccount(ctx context.Context, customerID string, currency string) (*model.Account, error) {
-	args := m.Called(ctx, customerID, currency)
+func (m *MockService) CreateAccount(ctx context.Context, customerID string, currency string, productCode string) (*model.Account, error) {
+	args := m.Called(ctx, customerID, currency, productCode)
 	if args.Get(0) == nil {
 		return nil, args.Error(1)
 	}
@@ -50,31 +61,112 @@ func (m *MockService) GetAccount(ctx context.Context, accountID string) (*model.
 	return args.Get(0).(*model.Account), args.Error(1)
 }
 
-func (m *MockService) UpdateBalance(ctx context.Context, accountID string, amount float64, entryType model.LedgerEntryType, description string) error {
+func (m *MockService) UpdateBalance(ctx context.Context, accountID string, amount money.Money, entryType model.LedgerEntryType, description string) (*model.LedgerEntry, error) {
 	args := m.Called(ctx, accountID, amount, entryType, description)
-	return args.Error(0)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.LedgerEntry), args.Error(1)
 }
 
-func setupRouter(mockSvc service.AccountService) chi.Router {
+func (m *MockService) ListAccounts(ctx context.Context, filter model.AccountFilter) (*model.AccountPage, error) {
+	args := m.Called(ctx, filter)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.AccountPage), args.Error(1)
+}
+
+func (m *MockService) ListLedgerEntries(ctx context.Context, filter model.LedgerFilter) (*model.LedgerPage, error) {
+	args := m.Called(ctx, filter)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.LedgerPage), args.Error(1)
+}
+
+func (m *MockService) Transfer(ctx context.Context, fromAccountID, toAccountID string, amount money.Money, description string) (*model.Transfer, error) {
+	args := m.Called(ctx, fromAccountID, toAccountID, amount, description)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.Transfer), args.Error(1)
+}
+
+func (m *MockService) FreezeAccount(ctx context.Context, accountID string, reason model.StatusReason, note string) (*model.Account, error) {
+	args := m.Called(ctx, accountID, reason, note)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.Account), args.Error(1)
+}
+
+func (m *MockService) UnfreezeAccount(ctx context.Context, accountID string, reason model.StatusReason, note string) (*model.Account, error) {
+	args := m.Called(ctx, accountID, reason, note)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.Account), args.Error(1)
+}
+
+func (m *MockService) CloseAccount(ctx context.Context, accountID string, reason model.StatusReason, note string, payoutAccountID string) (*model.Account, error) {
+	args := m.Called(ctx, accountID, reason, note, payoutAccountID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.Account), args.Error(1)
+}
+
+func (m *MockService) GetCustomerByExternalID(ctx context.Context, externalID string) (*model.Customer, error) {
+	args := m.Called(ctx, externalID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.Customer), args.Error(1)
+}
+
+func (m *MockService) GetPrimaryAccount(ctx context.Context, customerID uuid.UUID) (*model.Account, error) {
+	args := m.Called(ctx, customerID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.Account), args.Error(1)
+}
+
+// testCustomer is the customer behind createToken("test_user").
+var testCustomer = &model.Customer{ID: uuid.New(), ExternalID: "test_user", FullName: "Test User"}
+
+func setupRouter(mockSvc *MockService) chi.Router {
+	mockSvc.On("GetCustomerByExternalID", mock.Anything, "test_user").Return(testCustomer, nil).Maybe()
+	mockSvc.On("GetCustomerByExternalID", mock.Anything, "admin").Return(nil, service.ErrCustomerNotFound).Maybe()
+
 	r := chi.NewRouter()
-	h := NewAccountHandler(mockSvc)
+	h := NewAccountHandler(mockSvc, middleware.AuthMiddleware(jwtKey, nil), middleware.RequireAdmin())
 	h.RegisterRoutes(r)
 	return r
 }
 
+// ownAccount makes id an account of testCustomer.
+func ownAccount(mockSvc *MockService, id uuid.UUID) *model.Account {
+	acc := &model.Account{ID: id, CustomerID: testCustomer.ID, Currency: money.PLN, Status: model.AccountActive}
+	mockSvc.On("GetAccount", mock.Anything, id.String()).Return(acc, nil)
+	return acc
+}
+
 func TestCreateAccountHandler(t *testing.T) {
 	mockSvc := new(MockService)
 	r := setupRouter(mockSvc)
 
-	customerID := uuid.New().String()
+	customerID := testCustomer.ID.String()
 	currency := "USD"
 	reqBody, _ := json.Marshal(map[string]string{
-		"customerId": customerID,
-		"currency":   currency,
+		"customerId":  customerID,
+		"currency":    currency,
+		"productCode": "foreign_currency",
 	})
 
-	expectedAcc := &model.Account{ID: uuid.New(), Currency: currency}
-	mockSvc.On("CreateAccount", mock.Anything, customerID, currency).Return(expectedAcc, nil)
+	expectedAcc := &model.Account{ID: uuid.New(), Currency: money.Currency(currency)}
+	mockSvc.On("CreateAccount", mock.Anything, customerID, currency, "foreign_currency").Return(expectedAcc, nil)
 
 	req, _ := http.NewRequest("POST", "/accounts", bytes.NewBuffer(reqBody))
 	req.Header.Set("Authorization", "Bearer "+createToken("test_user"))
@@ -93,7 +185,7 @@ func TestGetAccountHandler(t *testing.T) {
 	r := setupRouter(mockSvc)
 
 	accountID := uuid.New()
-	expectedAcc := &model.Account{ID: accountID, Currency: "PLN"}
+	expectedAcc := &model.Account{ID: accountID, CustomerID: testCustomer.ID, Currency: "PLN"}
 	mockSvc.On("GetAccount", mock.Anything, accountID.String()).Return(expectedAcc, nil)
 
 	req, _ := http.NewRequest("GET", "/accounts/"+accountID.String(), nil)
@@ -112,18 +204,19 @@ func TestUpdateBalanceHandler(t *testing.T) {
 	mockSvc := new(MockService)
 	r := setupRouter(mockSvc)
 
-	accountID := uuid.New().String()
-	amount := 100.0
+	accountID := ownAccount(mockSvc, uuid.New()).ID.String()
+	amount := money.New(money.MustParseAmount("100.10"), money.PLN)
 	entryType := model.Deposit
 	description := "Test deposit"
 
 	reqBody, _ := json.Marshal(map[string]interface{}{
-		"amount":      amount,
+		"amount":      "100.10",
+		"currency":    "PLN",
 		"type":        entryType,
 		"description": description,
 	})
 
-	mockSvc.On("UpdateBalance", mock.Anything, accountID, amount, entryType, description).Return(nil)
+	mockSvc.On("UpdateBalance", mock.Anything, accountID, amount, entryType, description).Return(&model.LedgerEntry{}, nil)
 
 	req, _ := http.NewRequest("POST", "/accounts/"+accountID+"/balance", bytes.NewBuffer(reqBody))
 	req.Header.Set("Authorization", "Bearer "+createToken("test_user"))
@@ -177,7 +270,7 @@ func TestGetAccountHandler_NotFound(t *testing.T) {
 	r := setupRouter(mockSvc)
 
 	accountID := uuid.New().String()
-	mockSvc.On("GetAccount", mock.Anything, accountID).Return(nil, assert.AnError)
+	mockSvc.On("GetAccount", mock.Anything, accountID).Return(nil, service.ErrAccountNotFound)
 
 	req, _ := http.NewRequest("GET", "/accounts/"+accountID, nil)
 	req.Header.Set("Authorization", "Bearer "+createToken("test_user"))
@@ -192,9 +285,9 @@ func TestCreateAccountHandler_ServiceError(t *testing.T) {
 	mockSvc := new(MockService)
 	r := setupRouter(mockSvc)
 
-	mockSvc.On("CreateAccount", mock.Anything, mock.Anything, mock.Anything).Return(nil, assert.AnError)
+	mockSvc.On("CreateAccount", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(nil, assert.AnError)
 
-	reqBody, _ := json.Marshal(map[string]string{"customerId": "id", "currency": "USD"})
+	reqBody, _ := json.Marshal(map[string]string{"currency": "USD"})
 	req, _ := http.NewRequest("POST", "/accounts", bytes.NewBuffer(reqBody))
 	req.Header.Set("Authorization", "Bearer "+createToken("test_user"))
 	rr := httptest.NewRecorder()
@@ -207,8 +300,9 @@ func TestCreateAccountHandler_ServiceError(t *testing.T) {
 func TestUpdateBalanceHandler_InvalidJSON(t *testing.T) {
 	mockSvc := new(MockService)
 	r := setupRouter(mockSvc)
+	accountID := ownAccount(mockSvc, uuid.New()).ID.String()
 
-	req, _ := http.NewRequest("POST", "/accounts/some-id/balance", bytes.NewBufferString("invalid json"))
+	req, _ := http.NewRequest("POST", "/accounts/"+accountID+"/balance", bytes.NewBufferString("invalid json"))
 	req.Header.Set("Authorization", "Bearer "+createToken("test_user"))
 	rr := httptest.NewRecorder()
 
@@ -220,15 +314,607 @@ func TestUpdateBalanceHandler_InvalidJSON(t *testing.T) {
 func TestUpdateBalanceHandler_ServiceError(t *testing.T) {
 	mockSvc := new(MockService)
 	r := setupRouter(mockSvc)
+	accountID := ownAccount(mockSvc, uuid.New()).ID.String()
+
+	mockSvc.On("UpdateBalance", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(nil, &service.InsufficientFundsError{
+		Balance:   money.New(money.MustParseAmount("5.00"), money.PLN),
+		Requested: money.New(money.MustParseAmount("10.00"), money.PLN),
+	})
+
+	reqBody, _ := json.Marshal(map[string]interface{}{"amount": -10.0, "currency": "PLN", "type": "WITHDRAWAL", "description": "desc"})
+	req, _ := http.NewRequest("POST", "/accounts/"+accountID+"/balance", bytes.NewBuffer(reqBody))
+	req.Header.Set("Authorization", "Bearer "+createToken("test_user"))
+	rr := httptest.NewRecorder()
+
+	r.ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusUnprocessableEntity, rr.Code)
+	p := decodeProblem(t, rr)
+	assert.Equal(t, problem.CodeInsufficientFunds, p.Code)
+	assert.Equal(t, "insufficient funds: current balance 5.00 PLN, requested 10.00 PLN", p.Detail)
+}
+
+func TestUpdateBalanceHandler_InvalidCurrency(t *testing.T) {
+	mockSvc := new(MockService)
+	r := setupRouter(mockSvc)
+	accountID := ownAccount(mockSvc, uuid.New()).ID.String()
+
+	reqBody, _ := json.Marshal(map[string]interface{}{"amount": "10.00", "currency": "XYZ", "type": "deposit", "description": "desc"})
+	req, _ := http.NewRequest("POST", "/accounts/"+accountID+"/balance", bytes.NewBuffer(reqBody))
+	req.Header.Set("Authorization", "Bearer "+createToken("test_user"))
+	rr := httptest.NewRecorder()
+
+	r.ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+	mockSvc.AssertNotCalled(t, "UpdateBalance", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestUpdateBalanceHandler_AmountBeyondMinorUnit(t *testing.T) {
+	mockSvc := new(MockService)
+	r := setupRouter(mockSvc)
+	accountID := ownAccount(mockSvc, uuid.New()).ID.String()
 
-	mockSvc.On("UpdateBalance", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(assert.AnError)
+	reqBody, _ := json.Marshal(map[string]interface{}{"amount": "0.0001", "currency": "PLN", "type": "deposit", "description": "desc"})
+	req, _ := http.NewRequest("POST", "/accounts/"+accountID+"/balance", bytes.NewBuffer(reqBody))
+	req.Header.Set("Authorization", "Bearer "+createToken("test_user"))
+	rr := httptest.NewRecorder()
+
+	r.ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+	assert.Contains(t, rr.Body.String(), `"field":"amount"`)
+	mockSvc.AssertNotCalled(t, "UpdateBalance", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestGetAccountHandler_BalanceAsString(t *testing.T) {
+	mockSvc := new(MockService)
+	r := setupRouter(mockSvc)
+
+	accountID := uuid.New()
+	expectedAcc := &model.Account{ID: accountID, CustomerID: testCustomer.ID, Currency: money.PLN, Balance: money.MustParseAmount("12500.5")}
+	mockSvc.On("GetAccount", mock.Anything, accountID.String()).Return(expectedAcc, nil)
+
+	req, _ := http.NewRequest("GET", "/accounts/"+accountID.String(), nil)
+	req.Header.Set("Authorization", "Bearer "+createToken("test_user"))
+	rr := httptest.NewRecorder()
+
+	r.ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Contains(t, rr.Body.String(), `"balance":"12500.50"`)
+}
+
+func TestTransferHandler(t *testing.T) {
+	mockSvc := new(MockService)
+	r := setupRouter(mockSvc)
+
+	fromID, toID := ownAccount(mockSvc, uuid.New()).ID, uuid.New()
+	amount := money.New(money.MustParseAmount("25.50"), money.PLN)
+	expected := &model.Transfer{ReferenceID: uuid.New(), FromAccountID: fromID, ToAccountID: toID, Amount: amount.Amount, Currency: money.PLN}
+	mockSvc.On("Transfer", mock.Anything, fromID.String(), toID.String(), amount, "Rent").Return(expected, nil)
+
+	reqBody, _ := json.Marshal(map[string]string{
+		"toAccountId": toID.String(),
+		"amount":      "25.50",
+		"currency":    "PLN",
+		"description": "Rent",
+	})
+	req, _ := http.NewRequest("POST", "/accounts/"+fromID.String()+"/transfers", bytes.NewBuffer(reqBody))
+	req.Header.Set("Authorization", "Bearer "+createToken("test_user"))
+	rr := httptest.NewRecorder()
+
+	r.ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusCreated, rr.Code)
+	var returned model.Transfer
+	json.NewDecoder(rr.Body).Decode(&returned)
+	assert.Equal(t, expected.ReferenceID, returned.ReferenceID)
+	mockSvc.AssertExpectations(t)
+}
+
+func TestTransferHandler_MissingDestination(t *testing.T) {
+	mockSvc := new(MockService)
+	r := setupRouter(mockSvc)
+
+	fromID := ownAccount(mockSvc, uuid.New()).ID
 
-	reqBody, _ := json.Marshal(map[string]interface{}{"amount": 10.0, "type": "DEPOSIT", "description": "desc"})
-	req, _ := http.NewRequest("POST", "/accounts/some-id/balance", bytes.NewBuffer(reqBody))
+	reqBody, _ := json.Marshal(map[string]string{"amount": "1.00", "currency": "PLN"})
+	req, _ := http.NewRequest("POST", "/accounts/"+fromID.String()+"/transfers", bytes.NewBuffer(reqBody))
 	req.Header.Set("Authorization", "Bearer "+createToken("test_user"))
 	rr := httptest.NewRecorder()
 
 	r.ServeHTTP(rr, req)
 
 	assert.Equal(t, http.StatusBadRequest, rr.Code)
-}
\ No newline at end of file
+	mockSvc.AssertNotCalled(t, "Transfer", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestTransferHandler_ServiceError(t *testing.T) {
+	mockSvc := new(MockService)
+	r := setupRouter(mockSvc)
+
+	mockSvc.On("Transfer", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(nil, assert.AnError)
+
+	fromID := ownAccount(mockSvc, uuid.New()).ID
+
+	reqBody, _ := json.Marshal(map[string]string{"toAccountId": uuid.New().String(), "amount": "1.00", "currency": "PLN"})
+	req, _ := http.NewRequest("POST", "/accounts/"+fromID.String()+"/transfers", bytes.NewBuffer(reqBody))
+	req.Header.Set("Authorization", "Bearer "+createToken("test_user"))
+	rr := httptest.NewRecorder()
+
+	r.ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusInternalServerError, rr.Code)
+	p := decodeProblem(t, rr)
+	assert.Equal(t, problem.CodeInternal, p.Code)
+	assert.NotContains(t, p.Detail, assert.AnError.Error())
+}
+
+func TestUpdateBalanceHandler_IdempotencyKey(t *testing.T) {
+	mockSvc := new(MockService)
+	r := setupRouter(mockSvc)
+	accountID := ownAccount(mockSvc, uuid.New()).ID.String()
+
+	withKey := mock.MatchedBy(func(ctx context.Context) bool {
+		key, ok := service.IdempotencyKeyFromContext(ctx)
+		return ok && key == "retry-123"
+	})
+	mockSvc.On("UpdateBalance", withKey, accountID, mock.Anything, mock.Anything, mock.Anything).Return(&model.LedgerEntry{}, nil)
+
+	reqBody, _ := json.Marshal(map[string]interface{}{"amount": "10.00", "currency": "PLN", "type": "deposit", "description": "desc"})
+	req, _ := http.NewRequest("POST", "/accounts/"+accountID+"/balance", bytes.NewBuffer(reqBody))
+	req.Header.Set("Authorization", "Bearer "+createToken("test_user"))
+	req.Header.Set("Idempotency-Key", "retry-123")
+	rr := httptest.NewRecorder()
+
+	r.ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusNoContent, rr.Code)
+	mockSvc.AssertExpectations(t)
+}
+
+func TestUpdateBalanceHandler_IdempotencyKeyReused(t *testing.T) {
+	mockSvc := new(MockService)
+	r := setupRouter(mockSvc)
+	accountID := ownAccount(mockSvc, uuid.New()).ID.String()
+
+	mockSvc.On("UpdateBalance", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(nil, service.ErrIdempotencyKeyReused)
+
+	reqBody, _ := json.Marshal(map[string]interface{}{"amount": "10.00", "currency": "PLN", "type": "deposit", "description": "desc"})
+	req, _ := http.NewRequest("POST", "/accounts/"+accountID+"/balance", bytes.NewBuffer(reqBody))
+	req.Header.Set("Authorization", "Bearer "+createToken("test_user"))
+	req.Header.Set("Idempotency-Key", "retry-123")
+	rr := httptest.NewRecorder()
+
+	r.ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusUnprocessableEntity, rr.Code)
+}
+
+func TestListAccountsHandler(t *testing.T) {
+	mockSvc := new(MockService)
+	r := setupRouter(mockSvc)
+
+	customerID := testCustomer.ID
+	after := model.Cursor{CreatedAt: time.Now().UTC(), ID: uuid.New()}
+	page := &model.AccountPage{Items: []model.Account{{ID: uuid.New(), CustomerID: customerID}}, NextCursor: "next"}
+	mockSvc.On("ListAccounts", mock.Anything, mock.MatchedBy(func(f model.AccountFilter) bool {
+		return f.CustomerID == customerID && f.Status == model.AccountActive && f.Currency == money.EUR &&
+			f.Limit == 10 && f.After != nil && f.After.ID == after.ID
+	})).Return(page, nil)
+
+	url := "/accounts?customerId=" + customerID.String() + "&status=active&currency=eur&limit=10&cursor=" + after.Encode()
+	req, _ := http.NewRequest("GET", url, nil)
+	req.Header.Set("Authorization", "Bearer "+createToken("test_user"))
+	rr := httptest.NewRecorder()
+
+	r.ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	var returned model.AccountPage
+	json.NewDecoder(rr.Body).Decode(&returned)
+	assert.Len(t, returned.Items, 1)
+	assert.Equal(t, "next", returned.NextCursor)
+	mockSvc.AssertExpectations(t)
+}
+
+func TestListAccountsHandler_InvalidParams(t *testing.T) {
+	mockSvc := new(MockService)
+	r := setupRouter(mockSvc)
+	customerID := testCustomer.ID.String()
+
+	for _, query := range []string{
+		"?customerId=not-a-uuid",
+		"?customerId=" + customerID + "&currency=XYZ",
+		"?customerId=" + customerID + "&cursor=not-a-cursor!",
+		"?customerId=" + customerID + "&limit=-1",
+	} {
+		req, _ := http.NewRequest("GET", "/accounts"+query, nil)
+		req.Header.Set("Authorization", "Bearer "+createToken("test_user"))
+		rr := httptest.NewRecorder()
+
+		r.ServeHTTP(rr, req)
+
+		assert.Equal(t, http.StatusBadRequest, rr.Code, query)
+	}
+	mockSvc.AssertNotCalled(t, "ListAccounts", mock.Anything, mock.Anything)
+}
+
+func TestListLedgerEntriesHandler(t *testing.T) {
+	mockSvc := new(MockService)
+	r := setupRouter(mockSvc)
+
+	accountID := ownAccount(mockSvc, uuid.New()).ID
+	ref := uuid.New()
+	page := &model.LedgerPage{Items: []model.LedgerEntry{{
+		ID: uuid.New(), AccountID: accountID, Type: model.TransferIn, Amount: money.MustParseAmount("10"),
+		BalanceAfter: money.MustParseAmount("110"), ReferenceID: &ref, Description: "From savings",
+	}}}
+	mockSvc.On("ListLedgerEntries", mock.Anything, mock.MatchedBy(func(f model.LedgerFilter) bool {
+		return f.AccountID == accountID &&
+			f.From.Equal(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)) &&
+			f.To.Equal(time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC)) &&
+			len(f.Types) == 2 && f.Types[0] == model.TransferIn && f.Types[1] == model.FXExchange &&
+			f.MinAmount.String() == "5.00" && f.MaxAmount.String() == "500.00"
+	})).Return(page, nil)
+
+	url := "/accounts/" + accountID.String() + "/ledger?from=2024-01-01&to=2024-01-31&type=transfer_in,fx_exchange&minAmount=5&maxAmount=500"
+	req, _ := http.NewRequest("GET", url, nil)
+	req.Header.Set("Authorization", "Bearer "+createToken("test_user"))
+	rr := httptest.NewRecorder()
+
+	r.ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	body := rr.Body.String()
+	assert.Contains(t, body, `"type":"transfer_in"`)
+	assert.Contains(t, body, `"balanceAfter":"110.00"`)
+	assert.Contains(t, body, `"referenceId":"`+ref.String()+`"`)
+	assert.Contains(t, body, `"description":"From savings"`)
+	mockSvc.AssertExpectations(t)
+}
+
+func TestListLedgerEntriesHandler_NotFound(t *testing.T) {
+	mockSvc := new(MockService)
+	r := setupRouter(mockSvc)
+
+	accountID := uuid.New()
+	mockSvc.On("GetAccount", mock.Anything, accountID.String()).Return(nil, service.ErrAccountNotFound)
+
+	req, _ := http.NewRequest("GET", "/accounts/"+accountID.String()+"/ledger", nil)
+	req.Header.Set("Authorization", "Bearer "+createToken("test_user"))
+	rr := httptest.NewRecorder()
+
+	r.ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusNotFound, rr.Code)
+}
+
+func TestListLedgerEntriesHandler_InvalidParams(t *testing.T) {
+	mockSvc := new(MockService)
+	r := setupRouter(mockSvc)
+	base := "/accounts/" + ownAccount(mockSvc, uuid.New()).ID.String() + "/ledger"
+
+	for _, url := range []string{
+		"/accounts/not-a-uuid/ledger",
+		base + "?from=yesterday",
+		base + "?to=2024-13-01",
+		base + "?minAmount=abc",
+		base + "?maxAmount=1e5",
+		base + "?cursor=bogus!",
+		base + "?limit=0",
+	} {
+		req, _ := http.NewRequest("GET", url, nil)
+		req.Header.Set("Authorization", "Bearer "+createToken("test_user"))
+		rr := httptest.NewRecorder()
+
+		r.ServeHTTP(rr, req)
+
+		assert.Equal(t, http.StatusBadRequest, rr.Code, url)
+	}
+	mockSvc.AssertNotCalled(t, "ListLedgerEntries", mock.Anything, mock.Anything)
+}
+
+func TestGetCustomerHandler(t *testing.T) {
+	mockSvc := new(MockService)
+	r := setupRouter(mockSvc)
+
+	for query, want := range map[string]int{
+		"":                      http.StatusOK,
+		"?externalId=test_user": http.StatusOK,
+		"?externalId=someone":   http.StatusForbidden,
+	} {
+		req, _ := http.NewRequest("GET", "/customers"+query, nil)
+		req.Header.Set("Authorization", "Bearer "+createToken("test_user"))
+		rr := httptest.NewRecorder()
+		r.ServeHTTP(rr, req)
+
+		assert.Equal(t, want, rr.Code, query)
+		if want == http.StatusOK {
+			var returned model.Customer
+			json.NewDecoder(rr.Body).Decode(&returned)
+			assert.Equal(t, testCustomer.ID, returned.ID)
+		}
+	}
+}
+
+func TestGetPrimaryAccountHandler(t *testing.T) {
+	mockSvc := new(MockService)
+	r := setupRouter(mockSvc)
+
+	customerID := testCustomer.ID
+	acc := &model.Account{ID: uuid.New(), CustomerID: customerID, Currency: money.PLN}
+	mockSvc.On("GetPrimaryAccount", mock.Anything, customerID).Return(acc, nil)
+
+	req, _ := http.NewRequest("GET", "/customers/"+customerID.String()+"/primary-account", nil)
+	req.Header.Set("Authorization", "Bearer "+createToken("test_user"))
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	var returned model.Account
+	json.NewDecoder(rr.Body).Decode(&returned)
+	assert.Equal(t, acc.ID, returned.ID)
+}
+
+func TestGetPrimaryAccountHandler_NoActiveAccount(t *testing.T) {
+	mockSvc := new(MockService)
+	r := setupRouter(mockSvc)
+
+	customerID := testCustomer.ID
+	mockSvc.On("GetPrimaryAccount", mock.Anything, customerID).Return(nil, service.ErrAccountNotFound)
+
+	req, _ := http.NewRequest("GET", "/customers/"+customerID.String()+"/primary-account", nil)
+	req.Header.Set("Authorization", "Bearer "+createToken("test_user"))
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, req)
+	assert.Equal(t, http.StatusNotFound, rr.Code)
+
+	req, _ = http.NewRequest("GET", "/customers/not-a-uuid/primary-account", nil)
+	req.Header.Set("Authorization", "Bearer "+createToken("test_user"))
+	rr = httptest.NewRecorder()
+	r.ServeHTTP(rr, req)
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+}
+
+func TestOwnership_OtherCustomersAccountIsForbidden(t *testing.T) {
+	mockSvc := new(MockService)
+	r := setupRouter(mockSvc)
+
+	foreign := &model.Account{ID: uuid.New(), CustomerID: uuid.New(), Currency: money.PLN}
+	mockSvc.On("GetAccount", mock.Anything, foreign.ID.String()).Return(foreign, nil)
+
+	transferBody := `{"toAccountId":"` + uuid.New().String() + `","amount":"1.00","currency":"PLN"}`
+	for _, tc := range []struct{ method, path, body string }{
+		{"GET", "/accounts/" + foreign.ID.String(), ""},
+		{"POST", "/accounts/" + foreign.ID.String() + "/balance", `{"amount":"1.00","currency":"PLN","type":"withdrawal"}`},
+		{"POST", "/accounts/" + foreign.ID.String() + "/transfers", transferBody},
+		{"GET", "/accounts/" + foreign.ID.String() + "/ledger", ""},
+		{"GET", "/accounts?customerId=" + foreign.CustomerID.String(), ""},
+		{"GET", "/customers/" + foreign.CustomerID.String() + "/primary-account", ""},
+		{"POST", "/accounts", `{"customerId":"` + foreign.CustomerID.String() + `","currency":"PLN"}`},
+	} {
+		req, _ := http.NewRequest(tc.method, tc.path, bytes.NewBufferString(tc.body))
+		req.Header.Set("Authorization", "Bearer "+createToken("test_user"))
+		rr := httptest.NewRecorder()
+
+		r.ServeHTTP(rr, req)
+
+		assert.Equal(t, http.StatusForbidden, rr.Code, tc.method+" "+tc.path)
+	}
+	mockSvc.AssertNotCalled(t, "UpdateBalance", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	mockSvc.AssertNotCalled(t, "Transfer", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	mockSvc.AssertNotCalled(t, "ListLedgerEntries", mock.Anything, mock.Anything)
+	mockSvc.AssertNotCalled(t, "ListAccounts", mock.Anything, mock.Anything)
+	mockSvc.AssertNotCalled(t, "CreateAccount", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestOwnership_UserWithoutCustomerProfile(t *testing.T) {
+	mockSvc := new(MockService)
+	r := setupRouter(mockSvc)
+	mockSvc.On("GetCustomerByExternalID", mock.Anything, "stranger").Return(nil, service.ErrCustomerNotFound)
+
+	req, _ := http.NewRequest("GET", "/accounts", nil)
+	req.Header.Set("Authorization", "Bearer "+createToken("stranger"))
+	rr := httptest.NewRecorder()
+
+	r.ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusForbidden, rr.Code)
+}
+
+func TestFreezeAccountHandler(t *testing.T) {
+	mockSvc := new(MockService)
+	r := setupRouter(mockSvc)
+	acc := ownAccount(mockSvc, uuid.New())
+	frozen := *acc
+	frozen.Status = model.AccountFrozen
+	mockSvc.On("FreezeAccount", mock.Anything, acc.ID.String(), model.ReasonCustomerRequest, "card lost").Return(&frozen, nil)
+
+	req, _ := http.NewRequest("POST", "/accounts/"+acc.ID.String()+"/freeze", bytes.NewBufferString(`{"reason":"customer_request","note":"card lost"}`))
+	req.Header.Set("Authorization", "Bearer "+createToken("test_user"))
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	var returned model.Account
+	json.NewDecoder(rr.Body).Decode(&returned)
+	assert.Equal(t, model.AccountFrozen, returned.Status)
+}
+
+func TestFreezeAccountHandler_BankReasonRequiresAdmin(t *testing.T) {
+	mockSvc := new(MockService)
+	r := setupRouter(mockSvc)
+	acc := ownAccount(mockSvc, uuid.New())
+	frozen := *acc
+	frozen.Status = model.AccountFrozen
+	mockSvc.On("FreezeAccount", mock.Anything, acc.ID.String(), model.ReasonSuspectedFraud, "").Return(&frozen, nil)
+
+	for _, tc := range []struct {
+		user string
+		want int
+	}{
+		{"test_user", http.StatusForbidden},
+		{"admin", http.StatusOK},
+	} {
+		req, _ := http.NewRequest("POST", "/accounts/"+acc.ID.String()+"/freeze", bytes.NewBufferString(`{"reason":"suspected_fraud"}`))
+		req.Header.Set("Authorization", "Bearer "+createToken(tc.user))
+		rr := httptest.NewRecorder()
+		r.ServeHTTP(rr, req)
+
+		assert.Equal(t, tc.want, rr.Code, tc.user)
+	}
+	mockSvc.AssertNumberOfCalls(t, "FreezeAccount", 1)
+}
+
+func TestUnfreezeAccountHandler_RequiresAdmin(t *testing.T) {
+	mockSvc := new(MockService)
+	r := setupRouter(mockSvc)
+	acc := ownAccount(mockSvc, uuid.New())
+	acc.Status = model.AccountFrozen
+
+	req, _ := http.NewRequest("POST", "/accounts/"+acc.ID.String()+"/unfreeze", bytes.NewBufferString(`{"reason":"customer_request"}`))
+	req.Header.Set("Authorization", "Bearer "+createToken("test_user"))
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusForbidden, rr.Code)
+	mockSvc.AssertNotCalled(t, "UnfreezeAccount", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestCloseAccountHandler_FrozenAccountRequiresAdmin(t *testing.T) {
+	mockSvc := new(MockService)
+	r := setupRouter(mockSvc)
+	acc := ownAccount(mockSvc, uuid.New())
+	acc.Status = model.AccountFrozen
+	closed := *acc
+	closed.Status = model.AccountClosed
+	mockSvc.On("CloseAccount", mock.Anything, acc.ID.String(), model.ReasonCustomerRequest, "", "").Return(&closed, nil)
+
+	for _, tc := range []struct {
+		user string
+		want int
+	}{
+		{"test_user", http.StatusForbidden},
+		{"admin", http.StatusOK},
+	} {
+		req, _ := http.NewRequest("POST", "/accounts/"+acc.ID.String()+"/close", bytes.NewBufferString(`{"reason":"customer_request"}`))
+		req.Header.Set("Authorization", "Bearer "+createToken(tc.user))
+		rr := httptest.NewRecorder()
+		r.ServeHTTP(rr, req)
+
+		assert.Equal(t, tc.want, rr.Code, tc.user)
+	}
+	mockSvc.AssertNumberOfCalls(t, "CloseAccount", 1)
+}
+
+func TestStatusChangeHandler_Errors(t *testing.T) {
+	for _, tc := range []struct {
+		err  error
+		want int
+	}{
+		{&service.StatusTransitionError{From: model.AccountClosed, To: model.AccountActive}, http.StatusConflict},
+		{fmt.Errorf("%w: 10.00 must be paid out", service.ErrBalanceNotZero), http.StatusConflict},
+		{&service.ValidationError{Field: "reason", Err: service.ErrInvalidStatusReason}, http.StatusBadRequest},
+	} {
+		mockSvc := new(MockService)
+		r := setupRouter(mockSvc)
+		acc := ownAccount(mockSvc, uuid.New())
+		mockSvc.On("UnfreezeAccount", mock.Anything, acc.ID.String(), mock.Anything, mock.Anything).Return(nil, tc.err)
+
+		req, _ := http.NewRequest("POST", "/accounts/"+acc.ID.String()+"/unfreeze", bytes.NewBufferString(`{"reason":"review_cleared"}`))
+		req.Header.Set("Authorization", "Bearer "+createToken("admin"))
+		rr := httptest.NewRecorder()
+		r.ServeHTTP(rr, req)
+
+		assert.Equal(t, tc.want, rr.Code, tc.err.Error())
+	}
+}
+
+func TestCloseAccountHandler_PayoutToForeignAccountIsForbidden(t *testing.T) {
+	mockSvc := new(MockService)
+	r := setupRouter(mockSvc)
+	acc := ownAccount(mockSvc, uuid.New())
+	foreign := &model.Account{ID: uuid.New(), CustomerID: uuid.New(), Currency: money.PLN}
+	mockSvc.On("GetAccount", mock.Anything, foreign.ID.String()).Return(foreign, nil)
+
+	body := `{"reason":"customer_request","payoutAccountId":"` + foreign.ID.String() + `"}`
+	req, _ := http.NewRequest("POST", "/accounts/"+acc.ID.String()+"/close", bytes.NewBufferString(body))
+	req.Header.Set("Authorization", "Bearer "+createToken("test_user"))
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusForbidden, rr.Code)
+	mockSvc.AssertNotCalled(t, "CloseAccount", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestUpdateBalanceHandler_InactiveAccountConflict(t *testing.T) {
+	mockSvc := new(MockService)
+	r := setupRouter(mockSvc)
+	acc := ownAccount(mockSvc, uuid.New())
+	mockSvc.On("UpdateBalance", mock.Anything, acc.ID.String(), mock.Anything, model.Withdrawal, "").
+		Return(nil, &service.AccountNotActiveError{AccountID: acc.ID, Status: model.AccountFrozen})
+
+	req, _ := http.NewRequest("POST", "/accounts/"+acc.ID.String()+"/balance", bytes.NewBufferString(`{"amount":"-1.00","currency":"PLN","type":"withdrawal"}`))
+	req.Header.Set("Authorization", "Bearer "+createToken("test_user"))
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusConflict, rr.Code)
+}
+
+func decodeProblem(t *testing.T, rr *httptest.ResponseRecorder) problem.Problem {
+	t.Helper()
+	assert.Equal(t, problem.ContentType, rr.Header().Get("Content-Type"))
+	var p problem.Problem
+	if err := json.NewDecoder(rr.Body).Decode(&p); err != nil {
+		t.Fatalf("decode problem: %v", err)
+	}
+	return p
+}
+
+func TestListLedgerHandler_InvalidParamNamesField(t *testing.T) {
+	mockSvc := new(MockService)
+	r := setupRouter(mockSvc)
+	acc := ownAccount(mockSvc, uuid.New())
+
+	req, _ := http.NewRequest("GET", "/accounts/"+acc.ID.String()+"/ledger?minAmount=lots", nil)
+	req.Header.Set("Authorization", "Bearer "+createToken("test_user"))
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+	p := decodeProblem(t, rr)
+	assert.Equal(t, problem.CodeValidation, p.Code)
+	assert.Equal(t, "minAmount", p.Field)
+	mockSvc.AssertNotCalled(t, "ListLedgerEntries", mock.Anything, mock.Anything)
+}
+
+func TestTransferHandler_DomainErrorCodes(t *testing.T) {
+	for _, tc := range []struct {
+		err    error
+		status int
+		code   problem.Code
+	}{
+		{service.ErrDestinationNotFound, http.StatusUnprocessableEntity, problem.CodeDestinationNotFound},
+		{fmt.Errorf("%w: transfer in EUR", service.ErrCurrencyMismatch), http.StatusUnprocessableEntity, problem.CodeCurrencyMismatch},
+		{&service.AccountNotActiveError{Status: model.AccountFrozen}, http.StatusConflict, problem.CodeAccountFrozen},
+		{&service.AccountNotActiveError{Status: model.AccountClosed}, http.StatusConflict, problem.CodeAccountClosed},
+		{service.ErrIdempotencyKeyReused, http.StatusUnprocessableEntity, problem.CodeIdempotencyKeyReused},
+	} {
+		mockSvc := new(MockService)
+		r := setupRouter(mockSvc)
+		acc := ownAccount(mockSvc, uuid.New())
+		mockSvc.On("Transfer", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(nil, tc.err)
+
+		reqBody, _ := json.Marshal(map[string]string{"toAccountId": uuid.New().String(), "amount": "1.00", "currency": "PLN"})
+		req, _ := http.NewRequest("POST", "/accounts/"+acc.ID.String()+"/transfers", bytes.NewBuffer(reqBody))
+		req.Header.Set("Authorization", "Bearer "+createToken("test_user"))
+		rr := httptest.NewRecorder()
+		r.ServeHTTP(rr, req)
+
+		assert.Equal(t, tc.status, rr.Code, tc.err.Error())
+		assert.Equal(t, tc.code, decodeProblem(t, rr).Code)
+	}
+}
@@ -0,0 +1,179 @@
+package handler
+
+import (
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"time"
+
+	"go-web-server/services/account-service/handler/middleware"
+	"go-web-server/services/account-service/handler/problem"
+	"go-web-server/services/account-service/model"
+	"go-web-server/services/account-service/money"
+	"go-web-server/services/account-service/service"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// HoldHandler serves holds that reserve part of an account's balance until
+// they are captured, released or expire.
+type HoldHandler struct {
+	*AccountHandler
+	holds  service.HoldService
+	settle func(http.Handler) http.Handler
+}
+
+// NewHoldHandler creates the handler. Account ownership is checked through
+// accounts; settle, normally middleware.RequireAdminOrMerchant, guards
+// capturing and releasing holds, which the account owner may not do.
+func NewHoldHandler(accounts *AccountHandler, holds service.HoldService, settle func(http.Handler) http.Handler) *HoldHandler {
+	return &HoldHandler{AccountHandler: accounts, holds: holds, settle: settle}
+}
+
+func (h *HoldHandler) RegisterRoutes(r chi.Router) {
+	r.Group(func(r chi.Router) {
+		r.Use(h.auth)
+		r.Post("/accounts/{accountId}/holds", h.PlaceHold)
+		r.Get("/accounts/{accountId}/holds", h.ListHolds)
+		r.Get("/holds/{holdId}", h.GetHold)
+
+		r.Group(func(r chi.Router) {
+			r.Use(h.settle)
+			r.Post("/holds/{holdId}/capture", h.CaptureHold)
+			r.Post("/holds/{holdId}/release", h.ReleaseHold)
+		})
+	})
+}
+
+func (h *HoldHandler) PlaceHold(w http.ResponseWriter, r *http.Request) {
+	accountID := chi.URLParam(r, "accountId")
+	if _, ok := h.authorizeAccount(w, r, accountID); !ok {
+		return
+	}
+
+	var body struct {
+		Amount      money.Amount `json:"amount"`
+		Currency    string       `json:"currency"`
+		Description string       `json:"description"`
+		ExpiresAt   *time.Time   `json:"expiresAt"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		log.Printf("Error decoding hold body for account %s: %v", accountID, err)
+		respondWithError(w, http.StatusBadRequest, problem.CodeMalformedRequest, "Invalid request body")
+		return
+	}
+	currency, err := money.ParseCurrency(body.Currency)
+	if err != nil {
+		respondWithInvalidParam(w, "currency", err.Error())
+		return
+	}
+	amount, err := money.NewExact(body.Amount, currency)
+	if err != nil {
+		respondWithInvalidParam(w, "amount", err.Error())
+		return
+	}
+
+	ctx, ok := idempotencyContext(w, r)
+	if !ok {
+		return
+	}
+
+	hold, err := h.holds.PlaceHold(ctx, accountID, amount, body.Description, body.ExpiresAt)
+	if err != nil {
+		log.Printf("Error placing hold on account %s: %v", accountID, err)
+		respondWithServiceError(w, err)
+		return
+	}
+
+	log.Printf("Hold %s of %s %s placed on account %s until %s", hold.ID, hold.Amount, hold.Currency, accountID, hold.ExpiresAt.Format(time.RFC3339))
+	respondWithJSON(w, http.StatusCreated, hold)
+}
+
+func (h *HoldHandler) ListHolds(w http.ResponseWriter, r *http.Request) {
+	accountID := chi.URLParam(r, "accountId")
+	if _, ok := h.authorizeAccount(w, r, accountID); !ok {
+		return
+	}
+
+	holds, err := h.holds.ListHolds(r.Context(), accountID, model.HoldStatus(r.URL.Query().Get("status")))
+	if err != nil {
+		log.Printf("Error listing holds of account %s: %v", accountID, err)
+		respondWithServiceError(w, err)
+		return
+	}
+	respondWithJSON(w, http.StatusOK, map[string]interface{}{"holds": holds})
+}
+
+func (h *HoldHandler) GetHold(w http.ResponseWriter, r *http.Request) {
+	hold, ok := h.authorizeHold(w, r)
+	if !ok {
+		return
+	}
+	respondWithJSON(w, http.StatusOK, hold)
+}
+
+// CaptureHold settles a hold on any account; the route is restricted to
+// admins and merchants.
+func (h *HoldHandler) CaptureHold(w http.ResponseWriter, r *http.Request) {
+	holdID := chi.URLParam(r, "holdId")
+
+	// An empty body captures everything that remains.
+	var body struct {
+		Amount *money.Amount `json:"amount"`
+		Final  bool          `json:"final"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil && err != io.EOF {
+		log.Printf("Error decoding capture body for hold %s: %v", holdID, err)
+		respondWithError(w, http.StatusBadRequest, problem.CodeMalformedRequest, "Invalid request body")
+		return
+	}
+
+	ctx, ok := idempotencyContext(w, r)
+	if !ok {
+		return
+	}
+
+	settlement, err := h.holds.CaptureHold(ctx, holdID, body.Amount, body.Final)
+	if err != nil {
+		log.Printf("Error capturing hold %s: %v", holdID, err)
+		respondWithServiceError(w, err)
+		return
+	}
+
+	p, _ := middleware.PrincipalFromContext(r.Context())
+	log.Printf("Hold %s captured %s %s by %s, now %s", holdID, settlement.Entry.Amount.Neg(), settlement.Hold.Currency, p.Username, settlement.Hold.Status)
+	respondWithJSON(w, http.StatusCreated, settlement)
+}
+
+// ReleaseHold gives up a hold on any account; the route is restricted to
+// admins and merchants.
+func (h *HoldHandler) ReleaseHold(w http.ResponseWriter, r *http.Request) {
+	holdID := chi.URLParam(r, "holdId")
+	released, err := h.holds.ReleaseHold(r.Context(), holdID)
+	if err != nil {
+		log.Printf("Error releasing hold %s: %v", holdID, err)
+		respondWithServiceError(w, err)
+		return
+	}
+
+	p, _ := middleware.PrincipalFromContext(r.Context())
+	log.Printf("Hold %s released by %s", holdID, p.Username)
+	respondWithJSON(w, http.StatusOK, released)
+}
+
+// authorizeHold loads the hold named in the path and allows the request only
+// if the caller owns the account it is placed on.
+func (h *HoldHandler) authorizeHold(w http.ResponseWriter, r *http.Request) (*model.Hold, bool) {
+	holdID := chi.URLParam(r, "holdId")
+	hold, err := h.holds.GetHold(r.Context(), holdID)
+	if err != nil {
+		log.Printf("Error getting hold %s: %v", holdID, err)
+		respondWithServiceError(w, err)
+		return nil, false
+	}
+	if _, ok := h.authorizeAccount(w, r, hold.AccountID.String()); !ok {
+		return nil, false
+	}
+	return hold, true
+}
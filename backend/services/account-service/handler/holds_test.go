@@ -0,0 +1,205 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"go-web-server/services/account-service/handler/middleware"
+	"go-web-server/services/account-service/handler/problem"
+	"go-web-server/services/account-service/model"
+	"go-web-server/services/account-service/money"
+	"go-web-server/services/account-service/service"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+type MockHoldService struct {
+	mock.Mock
+}
+
+func (m *MockHoldService) PlaceHold(ctx context.Context, accountID string, amount money.Money, description string, expiresAt *time.Time) (*model.Hold, error) {
+	args := m.Called(ctx, accountID, amount, description, expiresAt)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.Hold), args.Error(1)
+}
+
+func (m *MockHoldService) GetHold(ctx context.Context, holdID string) (*model.Hold, error) {
+	args := m.Called(ctx, holdID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.Hold), args.Error(1)
+}
+
+func (m *MockHoldService) ListHolds(ctx context.Context, accountID string, status model.HoldStatus) ([]model.Hold, error) {
+	args := m.Called(ctx, accountID, status)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]model.Hold), args.Error(1)
+}
+
+func (m *MockHoldService) CaptureHold(ctx context.Context, holdID string, amount *money.Amount, final bool) (*model.HoldSettlement, error) {
+	args := m.Called(ctx, holdID, amount, final)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.HoldSettlement), args.Error(1)
+}
+
+func (m *MockHoldService) ReleaseHold(ctx context.Context, holdID string) (*model.Hold, error) {
+	args := m.Called(ctx, holdID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.Hold), args.Error(1)
+}
+
+func (m *MockHoldService) ExpireHolds(ctx context.Context) (int, error) {
+	args := m.Called(ctx)
+	return args.Int(0), args.Error(1)
+}
+
+func setupHoldRouter(mockSvc *MockService, holdSvc *MockHoldService) chi.Router {
+	mockSvc.On("GetCustomerByExternalID", mock.Anything, "test_user").Return(testCustomer, nil).Maybe()
+
+	r := chi.NewRouter()
+	accounts := NewAccountHandler(mockSvc, middleware.AuthMiddleware(jwtKey, nil), middleware.RequireAdmin())
+	NewHoldHandler(accounts, holdSvc, middleware.RequireAdminOrMerchant()).RegisterRoutes(r)
+	return r
+}
+
+func TestPlaceHoldHandler(t *testing.T) {
+	mockSvc, holdSvc := new(MockService), new(MockHoldService)
+	r := setupHoldRouter(mockSvc, holdSvc)
+	acc := ownAccount(mockSvc, uuid.New())
+	expiresAt := time.Date(2030, 1, 2, 3, 4, 5, 0, time.UTC)
+	hold := &model.Hold{ID: uuid.New(), AccountID: acc.ID, Amount: money.MustParseAmount("40"), Currency: money.PLN, Status: model.HoldActive, ExpiresAt: expiresAt}
+	holdSvc.On("PlaceHold", mock.MatchedBy(func(ctx context.Context) bool {
+		key, _ := service.IdempotencyKeyFromContext(ctx)
+		return key == "hold-1"
+	}), acc.ID.String(), money.New(money.MustParseAmount("40"), money.PLN), "Card payment", mock.MatchedBy(func(at *time.Time) bool {
+		return at != nil && at.Equal(expiresAt)
+	})).Return(hold, nil)
+
+	reqBody, _ := json.Marshal(map[string]string{"amount": "40", "currency": "PLN", "description": "Card payment", "expiresAt": "2030-01-02T03:04:05Z"})
+	req, _ := http.NewRequest("POST", "/accounts/"+acc.ID.String()+"/holds", bytes.NewBuffer(reqBody))
+	req.Header.Set("Authorization", "Bearer "+createToken("test_user"))
+	req.Header.Set("Idempotency-Key", "hold-1")
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusCreated, rr.Code)
+	var body map[string]interface{}
+	json.NewDecoder(rr.Body).Decode(&body)
+	assert.Equal(t, "40.00", body["amount"])
+	assert.Equal(t, "active", body["status"])
+	holdSvc.AssertExpectations(t)
+}
+
+func TestCaptureHoldHandler(t *testing.T) {
+	mockSvc, holdSvc := new(MockService), new(MockHoldService)
+	r := setupHoldRouter(mockSvc, holdSvc)
+	hold := &model.Hold{ID: uuid.New(), AccountID: uuid.New(), Currency: money.PLN, Status: model.HoldActive}
+	amount := money.MustParseAmount("25")
+	settlement := &model.HoldSettlement{
+		Hold:  model.Hold{ID: hold.ID, Currency: money.PLN, Status: model.HoldCaptured},
+		Entry: model.LedgerEntry{Type: model.HoldCapture, Amount: amount.Neg(), ReferenceID: &hold.ID},
+	}
+	holdSvc.On("CaptureHold", mock.Anything, hold.ID.String(), &amount, true).Return(settlement, nil)
+
+	// A merchant settles holds on accounts it does not own.
+	req, _ := http.NewRequest("POST", "/holds/"+hold.ID.String()+"/capture", bytes.NewBufferString(`{"amount": "25", "final": true}`))
+	req.Header.Set("Authorization", "Bearer "+createToken("merchant"))
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusCreated, rr.Code)
+	var returned model.HoldSettlement
+	json.NewDecoder(rr.Body).Decode(&returned)
+	assert.Equal(t, model.HoldCaptured, returned.Hold.Status)
+	assert.Equal(t, model.HoldCapture, returned.Entry.Type)
+	holdSvc.AssertExpectations(t)
+}
+
+func TestCaptureHoldHandler_EmptyBodyCapturesAll(t *testing.T) {
+	mockSvc, holdSvc := new(MockService), new(MockHoldService)
+	r := setupHoldRouter(mockSvc, holdSvc)
+	hold := &model.Hold{ID: uuid.New(), AccountID: uuid.New()}
+	holdSvc.On("CaptureHold", mock.Anything, hold.ID.String(), (*money.Amount)(nil), false).Return(&model.HoldSettlement{Hold: *hold}, nil)
+
+	req, _ := http.NewRequest("POST", "/holds/"+hold.ID.String()+"/capture", http.NoBody)
+	req.Header.Set("Authorization", "Bearer "+createToken("admin"))
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusCreated, rr.Code)
+	holdSvc.AssertExpectations(t)
+}
+
+func TestHoldHandler_OwnerCannotSettle(t *testing.T) {
+	mockSvc, holdSvc := new(MockService), new(MockHoldService)
+	r := setupHoldRouter(mockSvc, holdSvc)
+	hold := &model.Hold{ID: uuid.New(), AccountID: ownAccount(mockSvc, uuid.New()).ID}
+	holdSvc.On("GetHold", mock.Anything, hold.ID.String()).Return(hold, nil).Maybe()
+
+	for _, action := range []string{"capture", "release"} {
+		req, _ := http.NewRequest("POST", "/holds/"+hold.ID.String()+"/"+action, nil)
+		req.Header.Set("Authorization", "Bearer "+createToken("test_user"))
+		rr := httptest.NewRecorder()
+		r.ServeHTTP(rr, req)
+
+		assert.Equal(t, http.StatusForbidden, rr.Code, action)
+	}
+	holdSvc.AssertNotCalled(t, "CaptureHold", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	holdSvc.AssertNotCalled(t, "ReleaseHold", mock.Anything, mock.Anything)
+}
+
+func TestReleaseHoldHandler_ErrorCodes(t *testing.T) {
+	for _, tc := range []struct {
+		err    error
+		status int
+		code   problem.Code
+	}{
+		{&service.HoldNotActiveError{Status: model.HoldExpired}, http.StatusConflict, problem.CodeHoldNotActive},
+		{service.ErrCaptureExceedsHold, http.StatusUnprocessableEntity, problem.CodeCaptureExceedsHold},
+	} {
+		mockSvc, holdSvc := new(MockService), new(MockHoldService)
+		r := setupHoldRouter(mockSvc, holdSvc)
+		hold := &model.Hold{ID: uuid.New(), AccountID: uuid.New()}
+		holdSvc.On("ReleaseHold", mock.Anything, hold.ID.String()).Return(nil, tc.err)
+
+		req, _ := http.NewRequest("POST", "/holds/"+hold.ID.String()+"/release", nil)
+		req.Header.Set("Authorization", "Bearer "+createToken("merchant"))
+		rr := httptest.NewRecorder()
+		r.ServeHTTP(rr, req)
+
+		assert.Equal(t, tc.status, rr.Code, tc.err.Error())
+		assert.Equal(t, tc.code, decodeProblem(t, rr).Code)
+	}
+}
+
+func TestGetHoldHandler_NotFound(t *testing.T) {
+	mockSvc, holdSvc := new(MockService), new(MockHoldService)
+	r := setupHoldRouter(mockSvc, holdSvc)
+	id := uuid.New().String()
+	holdSvc.On("GetHold", mock.Anything, id).Return(nil, service.ErrHoldNotFound)
+
+	req, _ := http.NewRequest("GET", "/holds/"+id, nil)
+	req.Header.Set("Authorization", "Bearer "+createToken("test_user"))
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusNotFound, rr.Code)
+	assert.Equal(t, problem.CodeHoldNotFound, decodeProblem(t, rr).Code)
+}
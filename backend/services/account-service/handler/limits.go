@@ -0,0 +1,125 @@
+package handler
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+
+	"go-web-server/services/account-service/handler/middleware"
+	"go-web-server/services/account-service/handler/problem"
+	"go-web-server/services/account-service/model"
+	"go-web-server/services/account-service/money"
+	"go-web-server/services/account-service/service"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+)
+
+// LimitHandler shows account owners their transaction limits and lets
+// admins override them per account and set limits per customer.
+type LimitHandler struct {
+	*AccountHandler
+	limits service.LimitService
+	admin  func(http.Handler) http.Handler
+}
+
+// NewLimitHandler creates the handler. Account ownership is checked through
+// accounts; admin guards the admin endpoints.
+func NewLimitHandler(accounts *AccountHandler, limits service.LimitService, admin func(http.Handler) http.Handler) *LimitHandler {
+	return &LimitHandler{AccountHandler: accounts, limits: limits, admin: admin}
+}
+
+func (h *LimitHandler) RegisterRoutes(r chi.Router) {
+	r.Group(func(r chi.Router) {
+		r.Use(h.auth)
+		r.Get("/accounts/{accountId}/limits", h.GetLimits)
+
+		r.Group(func(r chi.Router) {
+			r.Use(h.admin)
+			r.Put("/admin/accounts/{accountId}/limits", h.SetLimitOverride)
+			r.Get("/admin/customers/{customerId}/limits", h.ListCustomerLimits)
+			r.Put("/admin/customers/{customerId}/limits/{currency}", h.SetCustomerLimits)
+		})
+	})
+}
+
+func (h *LimitHandler) GetLimits(w http.ResponseWriter, r *http.Request) {
+	accountID := chi.URLParam(r, "accountId")
+	if _, ok := h.authorizeAccount(w, r, accountID); !ok {
+		return
+	}
+
+	limits, err := h.limits.GetLimits(r.Context(), accountID)
+	if err != nil {
+		respondWithServiceError(w, err)
+		return
+	}
+	respondWithJSON(w, http.StatusOK, limits)
+}
+
+// SetLimitOverride replaces the limits an account overrides. Null or missing
+// fields fall back to the product's limits, so an empty body clears the
+// override.
+func (h *LimitHandler) SetLimitOverride(w http.ResponseWriter, r *http.Request) {
+	accountID, err := uuid.Parse(chi.URLParam(r, "accountId"))
+	if err != nil {
+		respondWithInvalidParam(w, "accountId", "Account ID must be a valid UUID")
+		return
+	}
+	var o model.LimitOverride
+	if err := json.NewDecoder(r.Body).Decode(&o); err != nil {
+		log.Printf("Error decoding limit override body for account %s: %v", accountID, err)
+		respondWithError(w, http.StatusBadRequest, problem.CodeMalformedRequest, "Invalid request body")
+		return
+	}
+
+	p, _ := middleware.PrincipalFromContext(r.Context())
+	o.AccountID, o.UpdatedBy = accountID, p.Username
+	saved, err := h.limits.SetLimitOverride(r.Context(), &o)
+	if err != nil {
+		log.Printf("Error setting limits of account %s: %v", accountID, err)
+		respondWithServiceError(w, err)
+		return
+	}
+
+	log.Printf("Limits of account %s overridden by %s", accountID, p.Username)
+	respondWithJSON(w, http.StatusOK, saved)
+}
+
+func (h *LimitHandler) ListCustomerLimits(w http.ResponseWriter, r *http.Request) {
+	limits, err := h.limits.ListCustomerLimits(r.Context(), chi.URLParam(r, "customerId"))
+	if err != nil {
+		respondWithServiceError(w, err)
+		return
+	}
+	respondWithJSON(w, http.StatusOK, limits)
+}
+
+// SetCustomerLimits replaces a customer's limits in one currency, which the
+// debits from all their accounts in it count against. Missing fields mean
+// no limit, so an empty body removes the customer's limits.
+func (h *LimitHandler) SetCustomerLimits(w http.ResponseWriter, r *http.Request) {
+	customerID, err := uuid.Parse(chi.URLParam(r, "customerId"))
+	if err != nil {
+		respondWithInvalidParam(w, "customerId", "Customer ID must be a valid UUID")
+		return
+	}
+	var l model.CustomerLimits
+	if err := json.NewDecoder(r.Body).Decode(&l); err != nil {
+		log.Printf("Error decoding limits body for customer %s: %v", customerID, err)
+		respondWithError(w, http.StatusBadRequest, problem.CodeMalformedRequest, "Invalid request body")
+		return
+	}
+
+	p, _ := middleware.PrincipalFromContext(r.Context())
+	l.CustomerID, l.Currency, l.UpdatedBy = customerID, money.Currency(chi.URLParam(r, "currency")), p.Username
+	saved, err := h.limits.SetCustomerLimits(r.Context(), &l)
+	if err != nil {
+		log.Printf("Error setting limits of customer %s: %v", customerID, err)
+		respondWithServiceError(w, err)
+		return
+	}
+
+	log.Printf("%s limits of customer %s set by %s", saved.Currency, customerID, p.Username)
+	respondWithJSON(w, http.StatusOK, saved)
+}
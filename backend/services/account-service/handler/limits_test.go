@@ -0,0 +1,181 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"go-web-server/services/account-service/handler/middleware"
+	"go-web-server/services/account-service/handler/problem"
+	"go-web-server/services/account-service/model"
+	"go-web-server/services/account-service/money"
+	"go-web-server/services/account-service/service"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+type MockLimitService struct {
+	mock.Mock
+}
+
+func (m *MockLimitService) GetLimits(ctx context.Context, accountID string) (*model.AccountLimits, error) {
+	args := m.Called(ctx, accountID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.AccountLimits), args.Error(1)
+}
+
+func (m *MockLimitService) SetLimitOverride(ctx context.Context, o *model.LimitOverride) (*model.LimitOverride, error) {
+	args := m.Called(ctx, o)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.LimitOverride), args.Error(1)
+}
+
+func (m *MockLimitService) ListCustomerLimits(ctx context.Context, customerID string) ([]model.CustomerLimits, error) {
+	args := m.Called(ctx, customerID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]model.CustomerLimits), args.Error(1)
+}
+
+func (m *MockLimitService) SetCustomerLimits(ctx context.Context, l *model.CustomerLimits) (*model.CustomerLimits, error) {
+	args := m.Called(ctx, l)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.CustomerLimits), args.Error(1)
+}
+
+func setupLimitRouter(mockSvc *MockService, limitSvc *MockLimitService) chi.Router {
+	mockSvc.On("GetCustomerByExternalID", mock.Anything, "test_user").Return(testCustomer, nil).Maybe()
+
+	r := chi.NewRouter()
+	accounts := NewAccountHandler(mockSvc, middleware.AuthMiddleware(jwtKey, nil), middleware.RequireAdmin())
+	NewLimitHandler(accounts, limitSvc, middleware.RequireAdmin()).RegisterRoutes(r)
+	return r
+}
+
+func TestGetLimitsHandler(t *testing.T) {
+	mockSvc, limitSvc := new(MockService), new(MockLimitService)
+	r := setupLimitRouter(mockSvc, limitSvc)
+	acc := ownAccount(mockSvc, uuid.New())
+	limits := model.TransactionLimits{Daily: money.MustParseAmount("2000"), MaxDailyTransactions: 5}
+	used := model.LimitUsage{Daily: money.MustParseAmount("1500"), DailyTransactions: 1}
+	headroom, err := limits.Headroom(used)
+	assert.NoError(t, err)
+	limitSvc.On("GetLimits", mock.Anything, acc.ID.String()).Return(&model.AccountLimits{
+		AccountID: acc.ID, Currency: money.PLN, Limits: limits, Used: used, Headroom: headroom,
+	}, nil)
+
+	req, _ := http.NewRequest("GET", "/accounts/"+acc.ID.String()+"/limits", nil)
+	req.Header.Set("Authorization", "Bearer "+createToken("test_user"))
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	var body struct {
+		Headroom map[string]interface{} `json:"headroom"`
+	}
+	json.NewDecoder(rr.Body).Decode(&body)
+	assert.Equal(t, "500.00", body.Headroom["daily"])
+	assert.Equal(t, float64(4), body.Headroom["dailyTransactions"])
+	assert.Nil(t, body.Headroom["monthly"])
+}
+
+func TestGetLimitsHandler_ForeignAccountIsForbidden(t *testing.T) {
+	mockSvc, limitSvc := new(MockService), new(MockLimitService)
+	r := setupLimitRouter(mockSvc, limitSvc)
+	foreign := &model.Account{ID: uuid.New(), CustomerID: uuid.New(), Currency: money.PLN}
+	mockSvc.On("GetAccount", mock.Anything, foreign.ID.String()).Return(foreign, nil)
+
+	req, _ := http.NewRequest("GET", "/accounts/"+foreign.ID.String()+"/limits", nil)
+	req.Header.Set("Authorization", "Bearer "+createToken("test_user"))
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusForbidden, rr.Code)
+	limitSvc.AssertNotCalled(t, "GetLimits", mock.Anything, mock.Anything)
+}
+
+func TestSetLimitOverrideHandler(t *testing.T) {
+	mockSvc, limitSvc := new(MockService), new(MockLimitService)
+	r := setupLimitRouter(mockSvc, limitSvc)
+	accountID := uuid.New()
+	limitSvc.On("SetLimitOverride", mock.Anything, mock.MatchedBy(func(o *model.LimitOverride) bool {
+		return o.AccountID == accountID && o.UpdatedBy == "admin" && o.Daily != nil &&
+			*o.Daily == money.MustParseAmount("3000") && o.Monthly == nil
+	})).Return(&model.LimitOverride{AccountID: accountID, UpdatedBy: "admin"}, nil)
+
+	for _, tc := range []struct {
+		user   string
+		status int
+	}{
+		{"test_user", http.StatusForbidden},
+		{"admin", http.StatusOK},
+	} {
+		body := `{"accountId": "` + uuid.NewString() + `", "daily": "3000", "monthly": null}`
+		req, _ := http.NewRequest("PUT", "/admin/accounts/"+accountID.String()+"/limits", bytes.NewBufferString(body))
+		req.Header.Set("Authorization", "Bearer "+createToken(tc.user))
+		rr := httptest.NewRecorder()
+		r.ServeHTTP(rr, req)
+
+		assert.Equal(t, tc.status, rr.Code, tc.user)
+	}
+	limitSvc.AssertExpectations(t)
+}
+
+func TestSetLimitOverrideHandler_Invalid(t *testing.T) {
+	mockSvc, limitSvc := new(MockService), new(MockLimitService)
+	r := setupLimitRouter(mockSvc, limitSvc)
+	limitSvc.On("SetLimitOverride", mock.Anything, mock.Anything).
+		Return(nil, &service.ValidationError{Field: "daily", Err: errors.New("daily must not be negative")})
+
+	req, _ := http.NewRequest("PUT", "/admin/accounts/"+uuid.NewString()+"/limits", bytes.NewBufferString(`{"daily": "-1"}`))
+	req.Header.Set("Authorization", "Bearer "+createToken("admin"))
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+	assert.Equal(t, problem.CodeValidation, decodeProblem(t, rr).Code)
+}
+
+func TestSetCustomerLimitsHandler(t *testing.T) {
+	mockSvc, limitSvc := new(MockService), new(MockLimitService)
+	r := setupLimitRouter(mockSvc, limitSvc)
+	customerID, unknown := uuid.New(), uuid.New()
+	limitSvc.On("SetCustomerLimits", mock.Anything, mock.MatchedBy(func(l *model.CustomerLimits) bool {
+		return l.CustomerID == customerID && l.Currency == "PLN" && l.UpdatedBy == "admin" &&
+			l.Daily == money.MustParseAmount("10000") && l.Monthly.IsZero()
+	})).Return(&model.CustomerLimits{CustomerID: customerID, Currency: money.PLN, UpdatedBy: "admin"}, nil)
+	limitSvc.On("SetCustomerLimits", mock.Anything, mock.MatchedBy(func(l *model.CustomerLimits) bool { return l.CustomerID == unknown })).
+		Return(nil, service.ErrCustomerNotFound)
+
+	for _, tc := range []struct {
+		user       string
+		customerID uuid.UUID
+		status     int
+	}{
+		{"test_user", customerID, http.StatusForbidden},
+		{"admin", customerID, http.StatusOK},
+		{"admin", unknown, http.StatusNotFound},
+	} {
+		req, _ := http.NewRequest("PUT", "/admin/customers/"+tc.customerID.String()+"/limits/PLN", bytes.NewBufferString(`{"daily": "10000"}`))
+		req.Header.Set("Authorization", "Bearer "+createToken(tc.user))
+		rr := httptest.NewRecorder()
+		r.ServeHTTP(rr, req)
+
+		assert.Equal(t, tc.status, rr.Code, tc.user)
+	}
+	limitSvc.AssertNumberOfCalls(t, "SetCustomerLimits", 2)
+}
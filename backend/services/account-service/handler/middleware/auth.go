@@ -2,45 +2,145 @@ package middleware
 
 import (
 	"context"
+	"log"
 	"net/http"
 	"strings"
 
+	"go-web-server/services/account-service/handler/problem"
+
 	"github.com/golang-jwt/jwt/v5"
 )
 
-var jwtKey = []byte("my_secret_key_for_testing_only")
+// Principal is the authenticated caller of a request.
+type Principal struct {
+	// Username is the customer's external_id.
+	Username string
+	// TokenID is the access token's jti.
+	TokenID string
+	// SessionID is the sid claim: the login session the token was issued in.
+	SessionID string
+	// Role is the role claim: RoleAdmin, RoleMerchant, or a customer role
+	// that grants nothing beyond the caller's own accounts.
+	Role string
+}
+
+// Roles carried in the access token's role claim.
+const (
+	RoleAdmin    = "admin"
+	RoleMerchant = "merchant"
+)
+
+type principalKey struct{}
+
+// WithPrincipal returns a copy of ctx carrying p.
+func WithPrincipal(ctx context.Context, p Principal) context.Context {
+	return context.WithValue(ctx, principalKey{}, p)
+}
+
+// PrincipalFromContext returns the principal stored by AuthMiddleware.
+func PrincipalFromContext(ctx context.Context) (Principal, bool) {
+	p, ok := ctx.Value(principalKey{}).(Principal)
+	return p, ok
+}
+
+// RevocationList reports access tokens that were revoked before they expired.
+type RevocationList interface {
+	IsRevoked(jti string) (bool, error)
+}
+
+// AuthMiddleware returns a middleware that accepts requests carrying a valid
+// HS256 bearer token signed with jwtKey and stores the caller's Principal in
+// the request context. When revoked is not nil, tokens must carry a jti and
+// are rejected once it is on the revocation list.
+func AuthMiddleware(jwtKey []byte, revoked RevocationList) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			authHeader := r.Header.Get("Authorization")
+			if authHeader == "" {
+				problem.Error(w, http.StatusUnauthorized, problem.CodeUnauthorized, "Missing token")
+				return
+			}
 
-type contextKey string
+			bearerToken := strings.Split(authHeader, " ")
+			if len(bearerToken) != 2 {
+				problem.Error(w, http.StatusUnauthorized, problem.CodeInvalidToken, "Invalid token format")
+				return
+			}
 
-const UsernameKey contextKey = "username"
+			tokenString := bearerToken[1]
+			claims := jwt.MapClaims{}
 
-func AuthMiddleware(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		authHeader := r.Header.Get("Authorization")
-		if authHeader == "" {
-			http.Error(w, "Unauthorized: missing token", http.StatusUnauthorized)
-			return
-		}
+			token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
+				return jwtKey, nil
+			}, jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}))
 
-		bearerToken := strings.Split(authHeader, " ")
-		if len(bearerToken) != 2 {
-			http.Error(w, "Unauthorized: invalid token format", http.StatusUnauthorized)
-			return
-		}
+			if err != nil || !token.Valid {
+				problem.Error(w, http.StatusUnauthorized, problem.CodeInvalidToken, "Invalid token")
+				return
+			}
 
-		tokenString := bearerToken[1]
-		claims := jwt.MapClaims{}
+			username, ok := claims["username"].(string)
+			if !ok || username == "" {
+				problem.Error(w, http.StatusUnauthorized, problem.CodeInvalidToken, "Invalid token")
+				return
+			}
+			jti, _ := claims["jti"].(string)
+			sid, _ := claims["sid"].(string)
+			role, _ := claims["role"].(string)
 
-		token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
-			return jwtKey, nil
+			if revoked != nil {
+				if jti == "" {
+					problem.Error(w, http.StatusUnauthorized, problem.CodeInvalidToken, "Invalid token")
+					return
+				}
+				isRevoked, err := revoked.IsRevoked(jti)
+				if err != nil {
+					log.Printf("Error checking token revocation: %v", err)
+					problem.Error(w, http.StatusInternalServerError, problem.CodeInternal, "An unexpected error occurred")
+					return
+				}
+				if isRevoked {
+					problem.Error(w, http.StatusUnauthorized, problem.CodeInvalidToken, "Token revoked")
+					return
+				}
+			}
+
+			ctx := WithPrincipal(r.Context(), Principal{Username: username, TokenID: jti, SessionID: sid, Role: role})
+			next.ServeHTTP(w, r.WithContext(ctx))
 		})
+	}
+}
 
-		if err != nil || !token.Valid {
-			http.Error(w, "Unauthorized: invalid token", http.StatusUnauthorized)
-			return
-		}
+// RequireAdmin allows only principals with the admin role. It must run after
+// AuthMiddleware.
+func RequireAdmin() func(http.Handler) http.Handler {
+	return requireRoles("Admin access required", RoleAdmin)
+}
+
+// RequireAdminOrMerchant allows only principals with the admin or merchant
+// role, the latter held by the users who settle card payments. It must run
+// after AuthMiddleware.
+func RequireAdminOrMerchant() func(http.Handler) http.Handler {
+	return requireRoles("Admin or merchant access required", RoleAdmin, RoleMerchant)
+}
 
-		ctx := context.WithValue(r.Context(), UsernameKey, claims["username"])
-		next.ServeHTTP(w, r.WithContext(ctx))
-	})
+func requireRoles(denied string, roles ...string) func(http.Handler) http.Handler {
+	allowed := make(map[string]bool, len(roles))
+	for _, role := range roles {
+		allowed[role] = true
+	}
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			p, ok := PrincipalFromContext(r.Context())
+			if !ok {
+				problem.Error(w, http.StatusUnauthorized, problem.CodeUnauthorized, "Unauthorized")
+				return
+			}
+			if !allowed[p.Role] {
+				problem.Error(w, http.StatusForbidden, problem.CodeForbidden, denied)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
 }
@@ -10,14 +10,16 @@ import (
 	"github.com/stretchr/testify/assert"
 )
 
+var jwtKey = []byte("test_signing_key_of_at_least_32_bytes")
+
 func TestAuthMiddleware(t *testing.T) {
 	nextHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		username := r.Context().Value(UsernameKey).(string)
-		w.Write([]byte("welcome " + username))
+		p, _ := PrincipalFromContext(r.Context())
+		w.Write([]byte("welcome " + p.Username))
 		w.WriteHeader(http.StatusOK)
 	})
 
-	handlerToTest := AuthMiddleware(nextHandler)
+	handlerToTest := AuthMiddleware(jwtKey, nil)(nextHandler)
 
 	t.Run("Valid Token", func(t *testing.T) {
 		token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
@@ -43,7 +45,7 @@ func TestAuthMiddleware(t *testing.T) {
 		handlerToTest.ServeHTTP(rr, req)
 
 		assert.Equal(t, http.StatusUnauthorized, rr.Code)
-		assert.Contains(t, rr.Body.String(), "missing token")
+		assert.Contains(t, rr.Body.String(), `"code":"unauthorized"`)
 	})
 
 	t.Run("Invalid Token", func(t *testing.T) {
@@ -54,6 +56,152 @@ func TestAuthMiddleware(t *testing.T) {
 		handlerToTest.ServeHTTP(rr, req)
 
 		assert.Equal(t, http.StatusUnauthorized, rr.Code)
-		assert.Contains(t, rr.Body.String(), "invalid token")
+		assert.Contains(t, rr.Body.String(), `"code":"invalid_token"`)
 	})
+
+	t.Run("Wrong Key", func(t *testing.T) {
+		token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+			"username": "test_user",
+			"exp":      time.Now().Add(time.Hour).Unix(),
+		})
+		tokenString, _ := token.SignedString([]byte("some_other_key_of_at_least_32_bytes"))
+
+		req, _ := http.NewRequest("GET", "/", nil)
+		req.Header.Set("Authorization", "Bearer "+tokenString)
+		rr := httptest.NewRecorder()
+
+		handlerToTest.ServeHTTP(rr, req)
+
+		assert.Equal(t, http.StatusUnauthorized, rr.Code)
+	})
+
+	t.Run("Unsigned Token", func(t *testing.T) {
+		token := jwt.NewWithClaims(jwt.SigningMethodNone, jwt.MapClaims{
+			"username": "test_user",
+			"exp":      time.Now().Add(time.Hour).Unix(),
+		})
+		tokenString, _ := token.SignedString(jwt.UnsafeAllowNoneSignatureType)
+
+		req, _ := http.NewRequest("GET", "/", nil)
+		req.Header.Set("Authorization", "Bearer "+tokenString)
+		rr := httptest.NewRecorder()
+
+		handlerToTest.ServeHTTP(rr, req)
+
+		assert.Equal(t, http.StatusUnauthorized, rr.Code)
+	})
+}
+
+type revocationList map[string]bool
+
+func (l revocationList) IsRevoked(jti string) (bool, error) { return l[jti], nil }
+
+func TestAuthMiddleware_RevocationList(t *testing.T) {
+	nextHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		p, _ := PrincipalFromContext(r.Context())
+		w.Write([]byte("session " + p.SessionID))
+	})
+	handlerToTest := AuthMiddleware(jwtKey, revocationList{"revoked-jti": true})(nextHandler)
+
+	request := func(claims jwt.MapClaims) *httptest.ResponseRecorder {
+		claims["username"] = "test_user"
+		claims["exp"] = time.Now().Add(time.Hour).Unix()
+		tokenString, _ := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(jwtKey)
+
+		req, _ := http.NewRequest("GET", "/", nil)
+		req.Header.Set("Authorization", "Bearer "+tokenString)
+		rr := httptest.NewRecorder()
+		handlerToTest.ServeHTTP(rr, req)
+		return rr
+	}
+
+	rr := request(jwt.MapClaims{"jti": "live-jti", "sid": "session-1"})
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Contains(t, rr.Body.String(), "session session-1")
+
+	rr = request(jwt.MapClaims{"jti": "revoked-jti", "sid": "session-1"})
+	assert.Equal(t, http.StatusUnauthorized, rr.Code)
+	assert.Contains(t, rr.Body.String(), "Token revoked")
+
+	rr = request(jwt.MapClaims{})
+	assert.Equal(t, http.StatusUnauthorized, rr.Code)
+}
+
+func TestAuthMiddleware_RoleClaim(t *testing.T) {
+	var got Principal
+	handlerToTest := AuthMiddleware(jwtKey, nil)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got, _ = PrincipalFromContext(r.Context())
+	}))
+
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"username": "ops",
+		"role":     RoleAdmin,
+		"exp":      time.Now().Add(time.Hour).Unix(),
+	})
+	tokenString, _ := token.SignedString(jwtKey)
+
+	req, _ := http.NewRequest("GET", "/", nil)
+	req.Header.Set("Authorization", "Bearer "+tokenString)
+	handlerToTest.ServeHTTP(httptest.NewRecorder(), req)
+
+	assert.Equal(t, Principal{Username: "ops", Role: RoleAdmin}, got)
+}
+
+func TestRequireAdmin(t *testing.T) {
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	h := RequireAdmin()(next)
+
+	for _, tc := range []struct {
+		name      string
+		principal *Principal
+		status    int
+	}{
+		{"admin", &Principal{Username: "ops", Role: RoleAdmin}, http.StatusOK},
+		{"merchant", &Principal{Username: "acquirer", Role: RoleMerchant}, http.StatusForbidden},
+		{"customer", &Principal{Username: "test_user"}, http.StatusForbidden},
+		// Rights come from the role claim, never from the username.
+		{"customer named admin", &Principal{Username: "admin"}, http.StatusForbidden},
+		{"anonymous", nil, http.StatusUnauthorized},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			req, _ := http.NewRequest("GET", "/", nil)
+			if tc.principal != nil {
+				req = req.WithContext(WithPrincipal(req.Context(), *tc.principal))
+			}
+			rr := httptest.NewRecorder()
+
+			h.ServeHTTP(rr, req)
+
+			assert.Equal(t, tc.status, rr.Code)
+		})
+	}
+}
+
+func TestRequireAdminOrMerchant(t *testing.T) {
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	h := RequireAdminOrMerchant()(next)
+
+	for _, tc := range []struct {
+		name   string
+		role   string
+		status int
+	}{
+		{"admin", RoleAdmin, http.StatusOK},
+		{"merchant", RoleMerchant, http.StatusOK},
+		{"customer", "", http.StatusForbidden},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			req, _ := http.NewRequest("POST", "/", nil)
+			req = req.WithContext(WithPrincipal(req.Context(), Principal{Username: "someone", Role: tc.role}))
+			rr := httptest.NewRecorder()
+
+			h.ServeHTTP(rr, req)
+
+			assert.Equal(t, tc.status, rr.Code)
+		})
+	}
 }
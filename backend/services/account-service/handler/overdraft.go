@@ -0,0 +1,118 @@
+package handler
+
+import (
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"time"
+
+	"go-web-server/services/account-service/handler/middleware"
+	"go-web-server/services/account-service/handler/problem"
+	"go-web-server/services/account-service/money"
+	"go-web-server/services/account-service/service"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// OverdraftHandler lets admins set account overdraft limits and charge
+// overdraft interest.
+type OverdraftHandler struct {
+	overdraft service.OverdraftService
+	auth      func(http.Handler) http.Handler
+	admin     func(http.Handler) http.Handler
+}
+
+// NewOverdraftHandler creates the handler. Like NewGLHandler, every route
+// requires auth and then admin.
+func NewOverdraftHandler(overdraft service.OverdraftService, auth, admin func(http.Handler) http.Handler) *OverdraftHandler {
+	return &OverdraftHandler{overdraft: overdraft, auth: auth, admin: admin}
+}
+
+func (h *OverdraftHandler) RegisterRoutes(r chi.Router) {
+	r.Group(func(r chi.Router) {
+		r.Use(h.auth, h.admin)
+		r.Put("/admin/accounts/{accountId}/overdraft-limit", h.SetLimit)
+		r.Get("/admin/accounts/{accountId}/overdraft-limit/changes", h.ListLimitChanges)
+		r.Post("/admin/overdraft-interest/runs", h.ChargeInterest)
+	})
+}
+
+func (h *OverdraftHandler) SetLimit(w http.ResponseWriter, r *http.Request) {
+	accountID := chi.URLParam(r, "accountId")
+	var body struct {
+		Limit *money.Amount `json:"limit"`
+		Note  string        `json:"note"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		log.Printf("Error decoding overdraft limit body for account %s: %v", accountID, err)
+		respondWithError(w, http.StatusBadRequest, problem.CodeMalformedRequest, "Invalid request body")
+		return
+	}
+	if body.Limit == nil {
+		respondWithInvalidParam(w, "limit", "Overdraft limit is required")
+		return
+	}
+
+	p, _ := middleware.PrincipalFromContext(r.Context())
+	change, err := h.overdraft.SetOverdraftLimit(r.Context(), accountID, *body.Limit, p.Username, body.Note)
+	if err != nil {
+		log.Printf("Error setting overdraft limit of account %s: %v", accountID, err)
+		respondWithServiceError(w, err)
+		return
+	}
+
+	log.Printf("Overdraft limit of account %s set from %s to %s %s by %s", accountID, change.OldLimit, change.NewLimit, change.Currency, p.Username)
+	respondWithJSON(w, http.StatusOK, change)
+}
+
+func (h *OverdraftHandler) ListLimitChanges(w http.ResponseWriter, r *http.Request) {
+	changes, err := h.overdraft.ListOverdraftLimitChanges(r.Context(), chi.URLParam(r, "accountId"))
+	if err != nil {
+		respondWithServiceError(w, err)
+		return
+	}
+	respondWithJSON(w, http.StatusOK, map[string]interface{}{"changes": changes})
+}
+
+// ChargeInterest charges a day's overdraft interest now. The body is
+// optional; {"businessDate": "2024-03-01"} picks the day, which defaults to
+// yesterday.
+func (h *OverdraftHandler) ChargeInterest(w http.ResponseWriter, r *http.Request) {
+	day, ok := decodeBusinessDate(w, r)
+	if !ok {
+		return
+	}
+
+	run, err := h.overdraft.ChargeInterest(r.Context(), day)
+	if err != nil {
+		log.Printf("Error charging overdraft interest: %v", err)
+		respondWithServiceError(w, err)
+		return
+	}
+
+	log.Printf("Overdraft interest for %s charged on %d accounts, %d skipped", run.BusinessDate, len(run.Charged), run.Skipped)
+	respondWithJSON(w, http.StatusCreated, run)
+}
+
+// decodeBusinessDate reads the optional {"businessDate": "2024-03-01"} body
+// of an interest run. A missing body or date gives the zero time.
+func decodeBusinessDate(w http.ResponseWriter, r *http.Request) (time.Time, bool) {
+	var body struct {
+		BusinessDate string `json:"businessDate"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil && err != io.EOF {
+		log.Printf("Error decoding interest run body: %v", err)
+		respondWithError(w, http.StatusBadRequest, problem.CodeMalformedRequest, "Invalid request body")
+		return time.Time{}, false
+	}
+	if body.BusinessDate == "" {
+		return time.Time{}, true
+	}
+	day, err := time.Parse("2006-01-02", body.BusinessDate)
+	if err != nil {
+		respondWithInvalidParam(w, "businessDate", "Business date must be a date such as 2024-03-01")
+		return time.Time{}, false
+	}
+	return day, true
+}
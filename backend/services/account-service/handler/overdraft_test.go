@@ -0,0 +1,120 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"go-web-server/services/account-service/handler/middleware"
+	"go-web-server/services/account-service/handler/problem"
+	"go-web-server/services/account-service/model"
+	"go-web-server/services/account-service/money"
+	"go-web-server/services/account-service/service"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+type MockOverdraftService struct {
+	mock.Mock
+}
+
+func (m *MockOverdraftService) SetOverdraftLimit(ctx context.Context, accountID string, limit money.Amount, changedBy, note string) (*model.OverdraftLimitChange, error) {
+	args := m.Called(ctx, accountID, limit, changedBy, note)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.OverdraftLimitChange), args.Error(1)
+}
+
+func (m *MockOverdraftService) ListOverdraftLimitChanges(ctx context.Context, accountID string) ([]model.OverdraftLimitChange, error) {
+	args := m.Called(ctx, accountID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]model.OverdraftLimitChange), args.Error(1)
+}
+
+func (m *MockOverdraftService) ChargeInterest(ctx context.Context, businessDate time.Time) (*model.OverdraftInterestRun, error) {
+	args := m.Called(ctx, businessDate)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.OverdraftInterestRun), args.Error(1)
+}
+
+func setupOverdraftRouter(svc *MockOverdraftService) chi.Router {
+	r := chi.NewRouter()
+	NewOverdraftHandler(svc, middleware.AuthMiddleware(jwtKey, nil), middleware.RequireAdmin()).RegisterRoutes(r)
+	return r
+}
+
+func TestSetOverdraftLimitHandler(t *testing.T) {
+	svc := new(MockOverdraftService)
+	r := setupOverdraftRouter(svc)
+	accountID := uuid.New().String()
+	change := &model.OverdraftLimitChange{OldLimit: money.MustParseAmount("0"), NewLimit: money.MustParseAmount("1500"), Currency: money.PLN, ChangedBy: "admin"}
+	svc.On("SetOverdraftLimit", mock.Anything, accountID, money.MustParseAmount("1500"), "admin", "Salary account").Return(change, nil)
+
+	for _, tc := range []struct {
+		user   string
+		status int
+	}{
+		{"test_user", http.StatusForbidden},
+		{"admin", http.StatusOK},
+	} {
+		req, _ := http.NewRequest("PUT", "/admin/accounts/"+accountID+"/overdraft-limit", bytes.NewBufferString(`{"limit": "1500", "note": "Salary account"}`))
+		req.Header.Set("Authorization", "Bearer "+createToken(tc.user))
+		rr := httptest.NewRecorder()
+		r.ServeHTTP(rr, req)
+
+		assert.Equal(t, tc.status, rr.Code, tc.user)
+	}
+	svc.AssertExpectations(t)
+}
+
+func TestSetOverdraftLimitHandler_MissingLimit(t *testing.T) {
+	svc := new(MockOverdraftService)
+	r := setupOverdraftRouter(svc)
+
+	req, _ := http.NewRequest("PUT", "/admin/accounts/"+uuid.New().String()+"/overdraft-limit", bytes.NewBufferString(`{"note": "oops"}`))
+	req.Header.Set("Authorization", "Bearer "+createToken("admin"))
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+	assert.Equal(t, "limit", decodeProblem(t, rr).Field)
+	svc.AssertNotCalled(t, "SetOverdraftLimit", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestChargeOverdraftInterestHandler(t *testing.T) {
+	svc := new(MockOverdraftService)
+	r := setupOverdraftRouter(svc)
+	day := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	svc.On("ChargeInterest", mock.Anything, day).Return(&model.OverdraftInterestRun{BusinessDate: "2024-03-01", Charged: []model.LedgerEntry{{}}}, nil)
+	svc.On("ChargeInterest", mock.Anything, time.Time{}).Return(nil, service.ErrOverdraftInterestDisabled)
+
+	req, _ := http.NewRequest("POST", "/admin/overdraft-interest/runs", bytes.NewBufferString(`{"businessDate": "2024-03-01"}`))
+	req.Header.Set("Authorization", "Bearer "+createToken("admin"))
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusCreated, rr.Code)
+	var run model.OverdraftInterestRun
+	json.NewDecoder(rr.Body).Decode(&run)
+	assert.Equal(t, "2024-03-01", run.BusinessDate)
+
+	req, _ = http.NewRequest("POST", "/admin/overdraft-interest/runs", http.NoBody)
+	req.Header.Set("Authorization", "Bearer "+createToken("admin"))
+	rr = httptest.NewRecorder()
+	r.ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusServiceUnavailable, rr.Code)
+	assert.Equal(t, problem.CodeOverdraftInterestOff, decodeProblem(t, rr).Code)
+}
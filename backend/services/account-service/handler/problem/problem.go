@@ -0,0 +1,175 @@
+// Package problem writes RFC 7807 problem details responses. Every problem
+// carries a stable machine-readable code, so clients can branch on it instead
+// of parsing the human-readable detail.
+package problem
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+
+	"go-web-server/services/account-service/model"
+	"go-web-server/services/account-service/service"
+)
+
+// ContentType is the media type of every problem response.
+const ContentType = "application/problem+json"
+
+// Code identifies a problem. Codes are part of the API contract: new ones
+// may be added, existing ones are never renamed.
+type Code string
+
+const (
+	CodeMalformedRequest            Code = "malformed_request"
+	CodeValidation                  Code = "validation_failed"
+	CodeUnauthorized                Code = "unauthorized"
+	CodeInvalidToken                Code = "invalid_token"
+	CodeForbidden                   Code = "forbidden"
+	CodeNotFound                    Code = "not_found"
+	CodeMethodNotAllowed            Code = "method_not_allowed"
+	CodeInternal                    Code = "internal_error"
+	CodeAccountNotFound             Code = "account_not_found"
+	CodeCustomerNotFound            Code = "customer_not_found"
+	CodeDestinationNotFound         Code = "destination_account_not_found"
+	CodeInsufficientFunds           Code = "insufficient_funds"
+	CodeCurrencyMismatch            Code = "currency_mismatch"
+	CodeAccountFrozen               Code = "account_frozen"
+	CodeAccountClosed               Code = "account_closed"
+	CodeInvalidStatusTransition     Code = "invalid_status_transition"
+	CodeBalanceNotZero              Code = "balance_not_zero"
+	CodeIdempotencyKeyReused        Code = "idempotency_key_reused"
+	CodeQuoteNotFound               Code = "quote_not_found"
+	CodeQuoteExpired                Code = "quote_expired"
+	CodeQuoteAlreadyExecuted        Code = "quote_already_executed"
+	CodeRateUnavailable             Code = "rate_unavailable"
+	CodeReportNotFound              Code = "reconciliation_report_not_found"
+	CodeCheckpointNotFound          Code = "checkpoint_not_found"
+	CodeCheckpointsDisabled         Code = "checkpoint_signing_disabled"
+	CodeHoldNotFound                Code = "hold_not_found"
+	CodeHoldNotActive               Code = "hold_not_active"
+	CodeCaptureExceedsHold          Code = "capture_exceeds_hold"
+	CodeOverdraftInterestOff        Code = "overdraft_interest_disabled"
+	CodeSavingsInterestOff          Code = "savings_interest_disabled"
+	CodeProductNotFound             Code = "product_not_found"
+	CodeWithdrawalLimitExceeded     Code = "withdrawal_limit_exceeded"
+	CodeMaxBalanceExceeded          Code = "max_balance_exceeded"
+	CodeFeeScheduleNotFound         Code = "fee_schedule_not_found"
+	CodeDailyLimitExceeded          Code = "daily_limit_exceeded"
+	CodeMonthlyLimitExceeded        Code = "monthly_limit_exceeded"
+	CodeVelocityLimitExceeded       Code = "velocity_limit_exceeded"
+	CodeScheduledTransferNotFound   Code = "scheduled_transfer_not_found"
+	CodeScheduledTransferNotPending Code = "scheduled_transfer_not_pending"
+	CodeStandingOrderNotFound       Code = "standing_order_not_found"
+	CodeStandingOrderNotActive      Code = "standing_order_not_active"
+	CodeCalendarNotFound            Code = "calendar_not_found"
+
+	// Gateway authentication codes.
+	CodeInvalidCredentials  Code = "invalid_credentials"
+	CodeLoginLocked         Code = "login_locked"
+	CodeRefreshTokenInvalid Code = "refresh_token_invalid"
+	CodeRefreshTokenReused  Code = "refresh_token_reused"
+	CodeUsernameTaken       Code = "username_taken"
+)
+
+// Problem is an RFC 7807 problem details object. Type is always
+// "about:blank", so Title is the HTTP status text; Code and Field are
+// extension members.
+type Problem struct {
+	Type   string `json:"type"`
+	Title  string `json:"title"`
+	Status int    `json:"status"`
+	Detail string `json:"detail,omitempty"`
+	Code   Code   `json:"code"`
+	// Field names the offending request parameter of a validation problem.
+	Field string `json:"field,omitempty"`
+}
+
+// New returns a problem with the given status, code and detail.
+func New(status int, code Code, detail string) *Problem {
+	return &Problem{
+		Type:   "about:blank",
+		Title:  http.StatusText(status),
+		Status: status,
+		Detail: detail,
+		Code:   code,
+	}
+}
+
+// Write sends p with its status code.
+func Write(w http.ResponseWriter, p *Problem) {
+	body, _ := json.Marshal(p)
+	w.Header().Set("Content-Type", ContentType)
+	w.WriteHeader(p.Status)
+	w.Write(body)
+}
+
+// Error writes a problem built from its arguments.
+func Error(w http.ResponseWriter, status int, code Code, detail string) {
+	Write(w, New(status, code, detail))
+}
+
+// FromError maps an AccountService error to a problem. Errors that are not
+// domain errors are logged and become a 500 that does not leak their text.
+func FromError(err error) *Problem {
+	var validation *service.ValidationError
+	if errors.As(err, &validation) {
+		p := New(http.StatusBadRequest, CodeValidation, err.Error())
+		p.Field = validation.Field
+		return p
+	}
+	var notActive *service.AccountNotActiveError
+	if errors.As(err, &notActive) {
+		code := CodeAccountFrozen
+		if notActive.Status == model.AccountClosed {
+			code = CodeAccountClosed
+		}
+		return New(http.StatusConflict, code, err.Error())
+	}
+
+	for _, m := range []struct {
+		err    error
+		status int
+		code   Code
+	}{
+		{service.ErrAccountNotFound, http.StatusNotFound, CodeAccountNotFound},
+		{service.ErrCustomerNotFound, http.StatusNotFound, CodeCustomerNotFound},
+		{service.ErrDestinationNotFound, http.StatusUnprocessableEntity, CodeDestinationNotFound},
+		{service.ErrInsufficientFunds, http.StatusUnprocessableEntity, CodeInsufficientFunds},
+		{service.ErrCurrencyMismatch, http.StatusUnprocessableEntity, CodeCurrencyMismatch},
+		{service.ErrIdempotencyKeyReused, http.StatusUnprocessableEntity, CodeIdempotencyKeyReused},
+		{service.ErrInvalidStatusTransition, http.StatusConflict, CodeInvalidStatusTransition},
+		{service.ErrBalanceNotZero, http.StatusConflict, CodeBalanceNotZero},
+		{service.ErrQuoteNotFound, http.StatusNotFound, CodeQuoteNotFound},
+		{service.ErrQuoteExpired, http.StatusUnprocessableEntity, CodeQuoteExpired},
+		{service.ErrQuoteExecuted, http.StatusConflict, CodeQuoteAlreadyExecuted},
+		{service.ErrRateUnavailable, http.StatusUnprocessableEntity, CodeRateUnavailable},
+		{service.ErrReportNotFound, http.StatusNotFound, CodeReportNotFound},
+		{service.ErrCheckpointNotFound, http.StatusNotFound, CodeCheckpointNotFound},
+		{service.ErrCheckpointSigningDisabled, http.StatusServiceUnavailable, CodeCheckpointsDisabled},
+		{service.ErrHoldNotFound, http.StatusNotFound, CodeHoldNotFound},
+		{service.ErrHoldNotActive, http.StatusConflict, CodeHoldNotActive},
+		{service.ErrCaptureExceedsHold, http.StatusUnprocessableEntity, CodeCaptureExceedsHold},
+		{service.ErrOverdraftInterestDisabled, http.StatusServiceUnavailable, CodeOverdraftInterestOff},
+		{service.ErrSavingsInterestDisabled, http.StatusServiceUnavailable, CodeSavingsInterestOff},
+		{service.ErrProductNotFound, http.StatusNotFound, CodeProductNotFound},
+		{service.ErrWithdrawalLimitExceeded, http.StatusUnprocessableEntity, CodeWithdrawalLimitExceeded},
+		{service.ErrMaxBalanceExceeded, http.StatusUnprocessableEntity, CodeMaxBalanceExceeded},
+		{service.ErrFeeScheduleNotFound, http.StatusNotFound, CodeFeeScheduleNotFound},
+		{service.ErrDailyLimitExceeded, http.StatusUnprocessableEntity, CodeDailyLimitExceeded},
+		{service.ErrMonthlyLimitExceeded, http.StatusUnprocessableEntity, CodeMonthlyLimitExceeded},
+		{service.ErrVelocityLimitExceeded, http.StatusUnprocessableEntity, CodeVelocityLimitExceeded},
+		{service.ErrScheduledTransferNotFound, http.StatusNotFound, CodeScheduledTransferNotFound},
+		{service.ErrScheduledTransferNotPending, http.StatusConflict, CodeScheduledTransferNotPending},
+		{service.ErrStandingOrderNotFound, http.StatusNotFound, CodeStandingOrderNotFound},
+		{service.ErrStandingOrderNotActive, http.StatusConflict, CodeStandingOrderNotActive},
+		{service.ErrCalendarNotFound, http.StatusNotFound, CodeCalendarNotFound},
+	} {
+		if errors.Is(err, m.err) {
+			return New(m.status, m.code, err.Error())
+		}
+	}
+
+	log.Printf("Unexpected error: %v", err)
+	return New(http.StatusInternalServerError, CodeInternal, "An unexpected error occurred")
+}
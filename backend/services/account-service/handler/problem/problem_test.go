@@ -0,0 +1,98 @@
+package problem
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"go-web-server/services/account-service/model"
+	"go-web-server/services/account-service/money"
+	"go-web-server/services/account-service/service"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFromError(t *testing.T) {
+	pln := func(s string) money.Money { return money.New(money.MustParseAmount(s), money.PLN) }
+
+	for _, tc := range []struct {
+		err    error
+		status int
+		code   Code
+	}{
+		{fmt.Errorf("%w: customer has no active account", service.ErrAccountNotFound), http.StatusNotFound, CodeAccountNotFound},
+		{service.ErrDestinationNotFound, http.StatusUnprocessableEntity, CodeDestinationNotFound},
+		{&service.InsufficientFundsError{Balance: pln("1"), Requested: pln("2")}, http.StatusUnprocessableEntity, CodeInsufficientFunds},
+		{fmt.Errorf("%w: EUR into PLN", service.ErrCurrencyMismatch), http.StatusUnprocessableEntity, CodeCurrencyMismatch},
+		{&service.AccountNotActiveError{AccountID: uuid.New(), Status: model.AccountFrozen}, http.StatusConflict, CodeAccountFrozen},
+		{&service.AccountNotActiveError{AccountID: uuid.New(), Status: model.AccountClosed}, http.StatusConflict, CodeAccountClosed},
+		{&service.StatusTransitionError{From: model.AccountClosed, To: model.AccountActive}, http.StatusConflict, CodeInvalidStatusTransition},
+		{service.ErrIdempotencyKeyReused, http.StatusUnprocessableEntity, CodeIdempotencyKeyReused},
+		{service.ErrQuoteNotFound, http.StatusNotFound, CodeQuoteNotFound},
+		{service.ErrQuoteExpired, http.StatusUnprocessableEntity, CodeQuoteExpired},
+		{service.ErrQuoteExecuted, http.StatusConflict, CodeQuoteAlreadyExecuted},
+		{fmt.Errorf("%w: EUR to GBP", service.ErrRateUnavailable), http.StatusUnprocessableEntity, CodeRateUnavailable},
+		{service.ErrReportNotFound, http.StatusNotFound, CodeReportNotFound},
+		{service.ErrCheckpointNotFound, http.StatusNotFound, CodeCheckpointNotFound},
+		{service.ErrCheckpointSigningDisabled, http.StatusServiceUnavailable, CodeCheckpointsDisabled},
+		{service.ErrHoldNotFound, http.StatusNotFound, CodeHoldNotFound},
+		{&service.HoldNotActiveError{HoldID: uuid.New(), Status: model.HoldExpired}, http.StatusConflict, CodeHoldNotActive},
+		{fmt.Errorf("%w: 5.00 requested", service.ErrCaptureExceedsHold), http.StatusUnprocessableEntity, CodeCaptureExceedsHold},
+		{service.ErrOverdraftInterestDisabled, http.StatusServiceUnavailable, CodeOverdraftInterestOff},
+		{service.ErrSavingsInterestDisabled, http.StatusServiceUnavailable, CodeSavingsInterestOff},
+		{service.ErrProductNotFound, http.StatusNotFound, CodeProductNotFound},
+		{&service.ProductLimitError{Limit: service.ErrWithdrawalLimitExceeded}, http.StatusUnprocessableEntity, CodeWithdrawalLimitExceeded},
+		{&service.ProductLimitError{Limit: service.ErrMaxBalanceExceeded}, http.StatusUnprocessableEntity, CodeMaxBalanceExceeded},
+		{service.ErrFeeScheduleNotFound, http.StatusNotFound, CodeFeeScheduleNotFound},
+		{&service.TransactionLimitError{Limit: service.ErrWithdrawalLimitExceeded}, http.StatusUnprocessableEntity, CodeWithdrawalLimitExceeded},
+		{&service.TransactionLimitError{Limit: service.ErrDailyLimitExceeded}, http.StatusUnprocessableEntity, CodeDailyLimitExceeded},
+		{&service.TransactionLimitError{Limit: service.ErrMonthlyLimitExceeded}, http.StatusUnprocessableEntity, CodeMonthlyLimitExceeded},
+		{&service.TransactionLimitError{Limit: service.ErrVelocityLimitExceeded}, http.StatusUnprocessableEntity, CodeVelocityLimitExceeded},
+		{service.ErrScheduledTransferNotFound, http.StatusNotFound, CodeScheduledTransferNotFound},
+		{&service.ScheduledTransferNotPendingError{Status: model.ScheduledCancelled}, http.StatusConflict, CodeScheduledTransferNotPending},
+		{service.ErrStandingOrderNotFound, http.StatusNotFound, CodeStandingOrderNotFound},
+		{&service.StandingOrderNotActiveError{Status: model.StandingOrderCompleted}, http.StatusConflict, CodeStandingOrderNotActive},
+		{service.ErrCalendarNotFound, http.StatusNotFound, CodeCalendarNotFound},
+		{errors.New("pq: connection refused"), http.StatusInternalServerError, CodeInternal},
+	} {
+		p := FromError(tc.err)
+		assert.Equal(t, tc.status, p.Status, tc.err.Error())
+		assert.Equal(t, tc.code, p.Code, tc.err.Error())
+	}
+}
+
+func TestFromError_ValidationNamesField(t *testing.T) {
+	p := FromError(&service.ValidationError{Field: "toAccountId", Err: errors.New("cannot transfer to the same account")})
+
+	assert.Equal(t, http.StatusBadRequest, p.Status)
+	assert.Equal(t, CodeValidation, p.Code)
+	assert.Equal(t, "toAccountId", p.Field)
+}
+
+func TestFromError_HidesUnexpectedErrors(t *testing.T) {
+	p := FromError(errors.New("pq: password authentication failed for user postgres"))
+
+	assert.NotContains(t, p.Detail, "postgres")
+}
+
+func TestWrite(t *testing.T) {
+	rr := httptest.NewRecorder()
+
+	Error(rr, http.StatusNotFound, CodeAccountNotFound, "account not found")
+
+	assert.Equal(t, http.StatusNotFound, rr.Code)
+	assert.Equal(t, ContentType, rr.Header().Get("Content-Type"))
+	var body map[string]interface{}
+	assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &body))
+	assert.Equal(t, map[string]interface{}{
+		"type":   "about:blank",
+		"title":  "Not Found",
+		"status": float64(404),
+		"detail": "account not found",
+		"code":   "account_not_found",
+	}, body)
+}
@@ -0,0 +1,84 @@
+package handler
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+
+	"go-web-server/services/account-service/handler/middleware"
+	"go-web-server/services/account-service/handler/problem"
+	"go-web-server/services/account-service/model"
+	"go-web-server/services/account-service/service"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// ProductHandler serves the account product catalogue. Any signed-in user
+// may read it, so customers can pick a product to open; admins maintain it.
+type ProductHandler struct {
+	products service.ProductService
+	auth     func(http.Handler) http.Handler
+	admin    func(http.Handler) http.Handler
+}
+
+// NewProductHandler creates the handler. auth guards every route and admin
+// additionally guards the write endpoint.
+func NewProductHandler(products service.ProductService, auth, admin func(http.Handler) http.Handler) *ProductHandler {
+	return &ProductHandler{products: products, auth: auth, admin: admin}
+}
+
+func (h *ProductHandler) RegisterRoutes(r chi.Router) {
+	r.Group(func(r chi.Router) {
+		r.Use(h.auth)
+		r.Get("/products", h.ListProducts)
+		r.Get("/products/{code}", h.GetProduct)
+
+		r.Group(func(r chi.Router) {
+			r.Use(h.admin)
+			r.Put("/admin/products/{code}", h.SaveProduct)
+		})
+	})
+}
+
+func (h *ProductHandler) ListProducts(w http.ResponseWriter, r *http.Request) {
+	products, err := h.products.ListProducts(r.Context())
+	if err != nil {
+		log.Printf("Error listing products: %v", err)
+		respondWithServiceError(w, err)
+		return
+	}
+	respondWithJSON(w, http.StatusOK, map[string]interface{}{"products": products})
+}
+
+func (h *ProductHandler) GetProduct(w http.ResponseWriter, r *http.Request) {
+	p, err := h.products.GetProduct(r.Context(), chi.URLParam(r, "code"))
+	if err != nil {
+		respondWithServiceError(w, err)
+		return
+	}
+	respondWithJSON(w, http.StatusOK, p)
+}
+
+// SaveProduct creates or replaces the product named in the path; a code in
+// the body is ignored.
+func (h *ProductHandler) SaveProduct(w http.ResponseWriter, r *http.Request) {
+	code := chi.URLParam(r, "code")
+	var p model.Product
+	if err := json.NewDecoder(r.Body).Decode(&p); err != nil {
+		log.Printf("Error decoding product %s: %v", code, err)
+		respondWithError(w, http.StatusBadRequest, problem.CodeMalformedRequest, "Invalid request body")
+		return
+	}
+	p.Code = code
+
+	saved, err := h.products.SaveProduct(r.Context(), &p)
+	if err != nil {
+		log.Printf("Error saving product %s: %v", code, err)
+		respondWithServiceError(w, err)
+		return
+	}
+
+	admin, _ := middleware.PrincipalFromContext(r.Context())
+	log.Printf("Product %s saved by %s", saved.Code, admin.Username)
+	respondWithJSON(w, http.StatusOK, saved)
+}
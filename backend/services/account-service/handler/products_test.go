@@ -0,0 +1,114 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"go-web-server/services/account-service/handler/middleware"
+	"go-web-server/services/account-service/handler/problem"
+	"go-web-server/services/account-service/model"
+	"go-web-server/services/account-service/money"
+	"go-web-server/services/account-service/service"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+type MockProductService struct {
+	mock.Mock
+}
+
+func (m *MockProductService) ListProducts(ctx context.Context) ([]model.Product, error) {
+	args := m.Called(ctx)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]model.Product), args.Error(1)
+}
+
+func (m *MockProductService) GetProduct(ctx context.Context, code string) (*model.Product, error) {
+	args := m.Called(ctx, code)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.Product), args.Error(1)
+}
+
+func (m *MockProductService) SaveProduct(ctx context.Context, p *model.Product) (*model.Product, error) {
+	args := m.Called(ctx, p)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.Product), args.Error(1)
+}
+
+func setupProductRouter(svc *MockProductService) chi.Router {
+	r := chi.NewRouter()
+	NewProductHandler(svc, middleware.AuthMiddleware(jwtKey, nil), middleware.RequireAdmin()).RegisterRoutes(r)
+	return r
+}
+
+func TestListProductsHandler(t *testing.T) {
+	svc := new(MockProductService)
+	r := setupProductRouter(svc)
+	svc.On("ListProducts", mock.Anything).Return([]model.Product{{Code: "current"}, {Code: "savings"}}, nil)
+	svc.On("GetProduct", mock.Anything, "pension").Return(nil, service.ErrProductNotFound)
+
+	req, _ := http.NewRequest("GET", "/products", nil)
+	req.Header.Set("Authorization", "Bearer "+createToken("test_user"))
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	var body struct {
+		Products []model.Product `json:"products"`
+	}
+	json.NewDecoder(rr.Body).Decode(&body)
+	assert.Len(t, body.Products, 2)
+
+	req, _ = http.NewRequest("GET", "/products/pension", nil)
+	req.Header.Set("Authorization", "Bearer "+createToken("test_user"))
+	rr = httptest.NewRecorder()
+	r.ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusNotFound, rr.Code)
+	assert.Equal(t, problem.CodeProductNotFound, decodeProblem(t, rr).Code)
+}
+
+func TestSaveProductHandler(t *testing.T) {
+	svc := new(MockProductService)
+	r := setupProductRouter(svc)
+	svc.On("SaveProduct", mock.Anything, mock.MatchedBy(func(p *model.Product) bool {
+		return p.Code == "youth" && p.MaxWithdrawal == money.MustParseAmount("500") && len(p.Currencies) == 1
+	})).Return(&model.Product{Code: "youth", Name: "Youth account"}, nil)
+
+	body := `{"code": "ignored", "name": "Youth account", "kind": "current", "currencies": ["PLN"], "maxWithdrawal": "500.00", "active": true}`
+	req, _ := http.NewRequest("PUT", "/admin/products/youth", bytes.NewBufferString(body))
+	req.Header.Set("Authorization", "Bearer "+createToken("admin"))
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	var saved model.Product
+	json.NewDecoder(rr.Body).Decode(&saved)
+	assert.Equal(t, "youth", saved.Code)
+	svc.AssertExpectations(t)
+}
+
+func TestSaveProductHandler_Forbidden(t *testing.T) {
+	svc := new(MockProductService)
+	r := setupProductRouter(svc)
+
+	req, _ := http.NewRequest("PUT", "/admin/products/youth", bytes.NewBufferString(`{"name": "Youth account"}`))
+	req.Header.Set("Authorization", "Bearer "+createToken("test_user"))
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusForbidden, rr.Code)
+	svc.AssertNotCalled(t, "SaveProduct", mock.Anything, mock.Anything)
+}
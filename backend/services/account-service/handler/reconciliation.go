@@ -0,0 +1,80 @@
+package handler
+
+import (
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+
+	"go-web-server/services/account-service/handler/problem"
+	"go-web-server/services/account-service/service"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// ReconciliationHandler lets admins run balance-versus-ledger reconciliation
+// and read its reports.
+type ReconciliationHandler struct {
+	reconciliation service.ReconciliationService
+	auth           func(http.Handler) http.Handler
+	admin          func(http.Handler) http.Handler
+}
+
+// NewReconciliationHandler creates the handler. Like NewGLHandler, every
+// route requires auth and then admin.
+func NewReconciliationHandler(reconciliation service.ReconciliationService, auth, admin func(http.Handler) http.Handler) *ReconciliationHandler {
+	return &ReconciliationHandler{reconciliation: reconciliation, auth: auth, admin: admin}
+}
+
+func (h *ReconciliationHandler) RegisterRoutes(r chi.Router) {
+	r.Group(func(r chi.Router) {
+		r.Use(h.auth, h.admin)
+		r.Post("/admin/reconciliation/reports", h.Run)
+		r.Get("/admin/reconciliation/reports/latest", h.LatestReport)
+		r.Get("/admin/reconciliation/reports/{reportId}", h.GetReport)
+	})
+}
+
+// Run reconciles all accounts now and returns the stored report. The body is
+// optional; {"autoFreeze": true} freezes the active accounts found to
+// disagree with their ledger.
+func (h *ReconciliationHandler) Run(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		AutoFreeze bool `json:"autoFreeze"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil && err != io.EOF {
+		log.Printf("Error decoding reconciliation body: %v", err)
+		respondWithError(w, http.StatusBadRequest, problem.CodeMalformedRequest, "Invalid request body")
+		return
+	}
+
+	report, err := h.reconciliation.Run(r.Context(), body.AutoFreeze)
+	if err != nil {
+		log.Printf("Error running reconciliation: %v", err)
+		respondWithServiceError(w, err)
+		return
+	}
+	if !report.Clean {
+		log.Printf("Reconciliation %s found %d discrepancies on %d accounts, froze %d",
+			report.ID, len(report.Discrepancies), len(report.AffectedAccounts), len(report.FrozenAccounts))
+	}
+	respondWithJSON(w, http.StatusCreated, report)
+}
+
+func (h *ReconciliationHandler) LatestReport(w http.ResponseWriter, r *http.Request) {
+	report, err := h.reconciliation.LatestReport(r.Context())
+	if err != nil {
+		respondWithServiceError(w, err)
+		return
+	}
+	respondWithJSON(w, http.StatusOK, report)
+}
+
+func (h *ReconciliationHandler) GetReport(w http.ResponseWriter, r *http.Request) {
+	report, err := h.reconciliation.GetReport(r.Context(), chi.URLParam(r, "reportId"))
+	if err != nil {
+		respondWithServiceError(w, err)
+		return
+	}
+	respondWithJSON(w, http.StatusOK, report)
+}
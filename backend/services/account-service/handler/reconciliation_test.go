@@ -0,0 +1,110 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"go-web-server/services/account-service/handler/middleware"
+	"go-web-server/services/account-service/model"
+	"go-web-server/services/account-service/service"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+type MockReconciliationService struct {
+	mock.Mock
+}
+
+func (m *MockReconciliationService) Run(ctx context.Context, autoFreeze bool) (*model.ReconciliationReport, error) {
+	args := m.Called(ctx, autoFreeze)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.ReconciliationReport), args.Error(1)
+}
+
+func (m *MockReconciliationService) GetReport(ctx context.Context, reportID string) (*model.ReconciliationReport, error) {
+	args := m.Called(ctx, reportID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.ReconciliationReport), args.Error(1)
+}
+
+func (m *MockReconciliationService) LatestReport(ctx context.Context) (*model.ReconciliationReport, error) {
+	args := m.Called(ctx)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.ReconciliationReport), args.Error(1)
+}
+
+func setupReconciliationRouter(svc *MockReconciliationService) chi.Router {
+	r := chi.NewRouter()
+	NewReconciliationHandler(svc, middleware.AuthMiddleware(jwtKey, nil), middleware.RequireAdmin()).RegisterRoutes(r)
+	return r
+}
+
+func TestRunReconciliationHandler(t *testing.T) {
+	for _, tc := range []struct {
+		name       string
+		body       string
+		autoFreeze bool
+	}{
+		{"no body", "", false},
+		{"auto-freeze", `{"autoFreeze": true}`, true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			svc := new(MockReconciliationService)
+			r := setupReconciliationRouter(svc)
+			accountID := uuid.New()
+			svc.On("Run", mock.Anything, tc.autoFreeze).Return(&model.ReconciliationReport{
+				ID:               uuid.New(),
+				AffectedAccounts: []uuid.UUID{accountID},
+				Discrepancies:    []model.ReconciliationDiscrepancy{{AccountID: accountID, Kind: model.DiscrepancyBalance}},
+			}, nil)
+
+			req, _ := http.NewRequest("POST", "/admin/reconciliation/reports", strings.NewReader(tc.body))
+			req.Header.Set("Authorization", "Bearer "+createToken("admin"))
+			rr := httptest.NewRecorder()
+			r.ServeHTTP(rr, req)
+
+			assert.Equal(t, http.StatusCreated, rr.Code)
+			assert.Contains(t, rr.Body.String(), `"kind":"balance_mismatch"`)
+			svc.AssertExpectations(t)
+		})
+	}
+}
+
+func TestRunReconciliationHandler_AdminOnly(t *testing.T) {
+	svc := new(MockReconciliationService)
+	r := setupReconciliationRouter(svc)
+
+	req, _ := http.NewRequest("POST", "/admin/reconciliation/reports", nil)
+	req.Header.Set("Authorization", "Bearer "+createToken("test_user"))
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusForbidden, rr.Code)
+	svc.AssertNotCalled(t, "Run", mock.Anything, mock.Anything)
+}
+
+func TestLatestReconciliationReportHandler_NotFound(t *testing.T) {
+	svc := new(MockReconciliationService)
+	r := setupReconciliationRouter(svc)
+	svc.On("LatestReport", mock.Anything).Return(nil, service.ErrReportNotFound)
+
+	req, _ := http.NewRequest("GET", "/admin/reconciliation/reports/latest", nil)
+	req.Header.Set("Authorization", "Bearer "+createToken("admin"))
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusNotFound, rr.Code)
+	assert.Contains(t, rr.Body.String(), `"code":"reconciliation_report_not_found"`)
+}
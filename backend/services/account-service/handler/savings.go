@@ -0,0 +1,50 @@
+package handler
+
+import (
+	"log"
+	"net/http"
+
+	"go-web-server/services/account-service/service"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// SavingsHandler lets admins run savings interest accrual.
+type SavingsHandler struct {
+	savings service.SavingsService
+	auth    func(http.Handler) http.Handler
+	admin   func(http.Handler) http.Handler
+}
+
+// NewSavingsHandler creates the handler. Like NewGLHandler, every route
+// requires auth and then admin.
+func NewSavingsHandler(savings service.SavingsService, auth, admin func(http.Handler) http.Handler) *SavingsHandler {
+	return &SavingsHandler{savings: savings, auth: auth, admin: admin}
+}
+
+func (h *SavingsHandler) RegisterRoutes(r chi.Router) {
+	r.Group(func(r chi.Router) {
+		r.Use(h.auth, h.admin)
+		r.Post("/admin/savings-interest/runs", h.AccrueInterest)
+	})
+}
+
+// AccrueInterest accrues a day's savings interest now. The body is
+// optional; {"businessDate": "2024-03-01"} picks the day, which defaults to
+// yesterday.
+func (h *SavingsHandler) AccrueInterest(w http.ResponseWriter, r *http.Request) {
+	day, ok := decodeBusinessDate(w, r)
+	if !ok {
+		return
+	}
+
+	run, err := h.savings.AccrueInterest(r.Context(), day)
+	if err != nil {
+		log.Printf("Error accruing savings interest: %v", err)
+		respondWithServiceError(w, err)
+		return
+	}
+
+	log.Printf("Savings interest for %s accrued on %d accounts, %d skipped", run.BusinessDate, len(run.Accrued), run.Skipped)
+	respondWithJSON(w, http.StatusCreated, run)
+}
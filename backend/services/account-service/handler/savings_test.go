@@ -0,0 +1,78 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"go-web-server/services/account-service/handler/middleware"
+	"go-web-server/services/account-service/handler/problem"
+	"go-web-server/services/account-service/model"
+	"go-web-server/services/account-service/service"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+type MockSavingsService struct {
+	mock.Mock
+}
+
+func (m *MockSavingsService) AccrueInterest(ctx context.Context, businessDate time.Time) (*model.InterestAccrualRun, error) {
+	args := m.Called(ctx, businessDate)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.InterestAccrualRun), args.Error(1)
+}
+
+func setupSavingsRouter(svc *MockSavingsService) chi.Router {
+	r := chi.NewRouter()
+	NewSavingsHandler(svc, middleware.AuthMiddleware(jwtKey, nil), middleware.RequireAdmin()).RegisterRoutes(r)
+	return r
+}
+
+func TestAccrueSavingsInterestHandler(t *testing.T) {
+	svc := new(MockSavingsService)
+	r := setupSavingsRouter(svc)
+	day := time.Date(2024, 2, 29, 0, 0, 0, 0, time.UTC)
+	svc.On("AccrueInterest", mock.Anything, day).Return(&model.InterestAccrualRun{BusinessDate: "2024-02-29", Accrued: []model.InterestAccrual{{}}}, nil)
+	svc.On("AccrueInterest", mock.Anything, time.Time{}).Return(nil, service.ErrSavingsInterestDisabled)
+
+	req, _ := http.NewRequest("POST", "/admin/savings-interest/runs", bytes.NewBufferString(`{"businessDate": "2024-02-29"}`))
+	req.Header.Set("Authorization", "Bearer "+createToken("admin"))
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusCreated, rr.Code)
+	var run model.InterestAccrualRun
+	json.NewDecoder(rr.Body).Decode(&run)
+	assert.Equal(t, "2024-02-29", run.BusinessDate)
+	assert.Len(t, run.Accrued, 1)
+
+	req, _ = http.NewRequest("POST", "/admin/savings-interest/runs", http.NoBody)
+	req.Header.Set("Authorization", "Bearer "+createToken("admin"))
+	rr = httptest.NewRecorder()
+	r.ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusServiceUnavailable, rr.Code)
+	assert.Equal(t, problem.CodeSavingsInterestOff, decodeProblem(t, rr).Code)
+}
+
+func TestAccrueSavingsInterestHandler_Forbidden(t *testing.T) {
+	svc := new(MockSavingsService)
+	r := setupSavingsRouter(svc)
+
+	req, _ := http.NewRequest("POST", "/admin/savings-interest/runs", bytes.NewBufferString(`{"businessDate": "29.02.2024"}`))
+	req.Header.Set("Authorization", "Bearer "+createToken("test_user"))
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusForbidden, rr.Code)
+	svc.AssertNotCalled(t, "AccrueInterest", mock.Anything, mock.Anything)
+}
@@ -0,0 +1,192 @@
+package handler
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+
+	"go-web-server/services/account-service/handler/problem"
+	"go-web-server/services/account-service/model"
+	"go-web-server/services/account-service/money"
+	"go-web-server/services/account-service/service"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// ScheduledTransferHandler serves transfers set for a future date to the
+// owners of their source accounts, and lets admins run the worker that
+// executes them.
+type ScheduledTransferHandler struct {
+	*AccountHandler
+	scheduled service.ScheduledTransferService
+	admin     func(http.Handler) http.Handler
+}
+
+// NewScheduledTransferHandler creates the handler. Account ownership is
+// checked through accounts; admin guards the worker run.
+func NewScheduledTransferHandler(accounts *AccountHandler, scheduled service.ScheduledTransferService, admin func(http.Handler) http.Handler) *ScheduledTransferHandler {
+	return &ScheduledTransferHandler{AccountHandler: accounts, scheduled: scheduled, admin: admin}
+}
+
+func (h *ScheduledTransferHandler) RegisterRoutes(r chi.Router) {
+	r.Group(func(r chi.Router) {
+		r.Use(h.auth)
+		r.Post("/accounts/{accountId}/scheduled-transfers", h.ScheduleTransfer)
+		r.Get("/accounts/{accountId}/scheduled-transfers", h.ListScheduledTransfers)
+		r.Get("/scheduled-transfers/{scheduledTransferId}", h.GetScheduledTransfer)
+		r.Patch("/scheduled-transfers/{scheduledTransferId}", h.AmendScheduledTransfer)
+		r.Post("/scheduled-transfers/{scheduledTransferId}/cancel", h.CancelScheduledTransfer)
+
+		r.Group(func(r chi.Router) {
+			r.Use(h.admin)
+			r.Post("/admin/scheduled-transfers/runs", h.ExecuteDueTransfers)
+		})
+	})
+}
+
+func (h *ScheduledTransferHandler) ScheduleTransfer(w http.ResponseWriter, r *http.Request) {
+	accountID := chi.URLParam(r, "accountId")
+	// Only the source account has to belong to the caller.
+	if _, ok := h.authorizeAccount(w, r, accountID); !ok {
+		return
+	}
+
+	var body struct {
+		ToAccountID string       `json:"toAccountId"`
+		Amount      money.Amount `json:"amount"`
+		Currency    string       `json:"currency"`
+		Description string       `json:"description"`
+		ExecuteOn   string       `json:"executeOn"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		log.Printf("Error decoding scheduled transfer body for account %s: %v", accountID, err)
+		respondWithError(w, http.StatusBadRequest, problem.CodeMalformedRequest, "Invalid request body")
+		return
+	}
+	if body.ToAccountID == "" {
+		respondWithInvalidParam(w, "toAccountId", "Destination account ID is required")
+		return
+	}
+	currency, err := money.ParseCurrency(body.Currency)
+	if err != nil {
+		respondWithInvalidParam(w, "currency", err.Error())
+		return
+	}
+	amount, err := money.NewExact(body.Amount, currency)
+	if err != nil {
+		respondWithInvalidParam(w, "amount", err.Error())
+		return
+	}
+
+	ctx, ok := idempotencyContext(w, r)
+	if !ok {
+		return
+	}
+
+	st, err := h.scheduled.ScheduleTransfer(ctx, accountID, body.ToAccountID, amount, body.Description, body.ExecuteOn)
+	if err != nil {
+		log.Printf("Error scheduling transfer from account %s to %s: %v", accountID, body.ToAccountID, err)
+		respondWithServiceError(w, err)
+		return
+	}
+
+	log.Printf("Transfer %s of %s %s from %s to %s scheduled for %s", st.ID, st.Amount, st.Currency, accountID, body.ToAccountID, st.ExecuteOn)
+	respondWithJSON(w, http.StatusCreated, st)
+}
+
+func (h *ScheduledTransferHandler) ListScheduledTransfers(w http.ResponseWriter, r *http.Request) {
+	accountID := chi.URLParam(r, "accountId")
+	if _, ok := h.authorizeAccount(w, r, accountID); !ok {
+		return
+	}
+
+	status := model.ScheduledTransferStatus(r.URL.Query().Get("status"))
+	transfers, err := h.scheduled.ListScheduledTransfers(r.Context(), accountID, status)
+	if err != nil {
+		log.Printf("Error listing scheduled transfers of account %s: %v", accountID, err)
+		respondWithServiceError(w, err)
+		return
+	}
+	respondWithJSON(w, http.StatusOK, map[string]interface{}{"scheduledTransfers": transfers})
+}
+
+func (h *ScheduledTransferHandler) GetScheduledTransfer(w http.ResponseWriter, r *http.Request) {
+	st, ok := h.authorizeScheduledTransfer(w, r)
+	if !ok {
+		return
+	}
+	respondWithJSON(w, http.StatusOK, st)
+}
+
+// AmendScheduledTransfer changes the fields present in the body and keeps
+// the rest.
+func (h *ScheduledTransferHandler) AmendScheduledTransfer(w http.ResponseWriter, r *http.Request) {
+	st, ok := h.authorizeScheduledTransfer(w, r)
+	if !ok {
+		return
+	}
+
+	var amendment model.ScheduledTransferAmendment
+	if err := json.NewDecoder(r.Body).Decode(&amendment); err != nil {
+		log.Printf("Error decoding amendment body for scheduled transfer %s: %v", st.ID, err)
+		respondWithError(w, http.StatusBadRequest, problem.CodeMalformedRequest, "Invalid request body")
+		return
+	}
+
+	amended, err := h.scheduled.AmendScheduledTransfer(r.Context(), st.ID.String(), amendment)
+	if err != nil {
+		log.Printf("Error amending scheduled transfer %s: %v", st.ID, err)
+		respondWithServiceError(w, err)
+		return
+	}
+
+	log.Printf("Scheduled transfer %s amended to %s %s on %s", st.ID, amended.Amount, amended.Currency, amended.ExecuteOn)
+	respondWithJSON(w, http.StatusOK, amended)
+}
+
+func (h *ScheduledTransferHandler) CancelScheduledTransfer(w http.ResponseWriter, r *http.Request) {
+	st, ok := h.authorizeScheduledTransfer(w, r)
+	if !ok {
+		return
+	}
+
+	cancelled, err := h.scheduled.CancelScheduledTransfer(r.Context(), st.ID.String())
+	if err != nil {
+		log.Printf("Error cancelling scheduled transfer %s: %v", st.ID, err)
+		respondWithServiceError(w, err)
+		return
+	}
+
+	log.Printf("Scheduled transfer %s cancelled", st.ID)
+	respondWithJSON(w, http.StatusOK, cancelled)
+}
+
+// ExecuteDueTransfers runs the worker now instead of waiting for its next
+// pass.
+func (h *ScheduledTransferHandler) ExecuteDueTransfers(w http.ResponseWriter, r *http.Request) {
+	run, err := h.scheduled.ExecuteDueTransfers(r.Context())
+	if err != nil {
+		log.Printf("Error executing scheduled transfers: %v", err)
+		respondWithServiceError(w, err)
+		return
+	}
+
+	log.Printf("Scheduled transfers: %d executed, %d retrying, %d failed", len(run.Executed), len(run.Retrying), len(run.Failed))
+	respondWithJSON(w, http.StatusCreated, run)
+}
+
+// authorizeScheduledTransfer loads the scheduled transfer named in the path
+// and allows the request only if the caller owns its source account.
+func (h *ScheduledTransferHandler) authorizeScheduledTransfer(w http.ResponseWriter, r *http.Request) (*model.ScheduledTransfer, bool) {
+	id := chi.URLParam(r, "scheduledTransferId")
+	st, err := h.scheduled.GetScheduledTransfer(r.Context(), id)
+	if err != nil {
+		log.Printf("Error getting scheduled transfer %s: %v", id, err)
+		respondWithServiceError(w, err)
+		return nil, false
+	}
+	if _, ok := h.authorizeAccount(w, r, st.FromAccountID.String()); !ok {
+		return nil, false
+	}
+	return st, true
+}
@@ -0,0 +1,184 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"go-web-server/services/account-service/handler/middleware"
+	"go-web-server/services/account-service/handler/problem"
+	"go-web-server/services/account-service/model"
+	"go-web-server/services/account-service/money"
+	"go-web-server/services/account-service/service"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+type MockScheduledTransferService struct {
+	mock.Mock
+}
+
+func (m *MockScheduledTransferService) ScheduleTransfer(ctx context.Context, fromAccountID, toAccountID string, amount money.Money, description, executeOn string) (*model.ScheduledTransfer, error) {
+	args := m.Called(ctx, fromAccountID, toAccountID, amount, description, executeOn)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.ScheduledTransfer), args.Error(1)
+}
+
+func (m *MockScheduledTransferService) GetScheduledTransfer(ctx context.Context, id string) (*model.ScheduledTransfer, error) {
+	args := m.Called(ctx, id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.ScheduledTransfer), args.Error(1)
+}
+
+func (m *MockScheduledTransferService) ListScheduledTransfers(ctx context.Context, accountID string, status model.ScheduledTransferStatus) ([]model.ScheduledTransfer, error) {
+	args := m.Called(ctx, accountID, status)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]model.ScheduledTransfer), args.Error(1)
+}
+
+func (m *MockScheduledTransferService) AmendScheduledTransfer(ctx context.Context, id string, amendment model.ScheduledTransferAmendment) (*model.ScheduledTransfer, error) {
+	args := m.Called(ctx, id, amendment)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.ScheduledTransfer), args.Error(1)
+}
+
+func (m *MockScheduledTransferService) CancelScheduledTransfer(ctx context.Context, id string) (*model.ScheduledTransfer, error) {
+	args := m.Called(ctx, id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.ScheduledTransfer), args.Error(1)
+}
+
+func (m *MockScheduledTransferService) ExecuteDueTransfers(ctx context.Context) (*model.ScheduledTransferRun, error) {
+	args := m.Called(ctx)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.ScheduledTransferRun), args.Error(1)
+}
+
+func setupScheduledTransferRouter(mockSvc *MockService, scheduledSvc *MockScheduledTransferService) chi.Router {
+	mockSvc.On("GetCustomerByExternalID", mock.Anything, "test_user").Return(testCustomer, nil).Maybe()
+
+	r := chi.NewRouter()
+	accounts := NewAccountHandler(mockSvc, middleware.AuthMiddleware(jwtKey, nil), middleware.RequireAdmin())
+	NewScheduledTransferHandler(accounts, scheduledSvc, middleware.RequireAdmin()).RegisterRoutes(r)
+	return r
+}
+
+func TestScheduleTransferHandler(t *testing.T) {
+	mockSvc, scheduledSvc := new(MockService), new(MockScheduledTransferService)
+	r := setupScheduledTransferRouter(mockSvc, scheduledSvc)
+	acc := ownAccount(mockSvc, uuid.New())
+	toID := uuid.New()
+	amount := money.New(money.MustParseAmount("120"), money.PLN)
+	scheduledSvc.On("ScheduleTransfer", mock.Anything, acc.ID.String(), toID.String(), amount, "Rent", "2024-03-10").Return(&model.ScheduledTransfer{
+		ID: uuid.New(), FromAccountID: acc.ID, ToAccountID: toID, Amount: amount.Amount, Currency: money.PLN,
+		ExecuteOn: "2024-03-10", Status: model.ScheduledPending,
+	}, nil)
+
+	body := `{"toAccountId": "` + toID.String() + `", "amount": "120.00", "currency": "PLN", "description": "Rent", "executeOn": "2024-03-10"}`
+	req, _ := http.NewRequest("POST", "/accounts/"+acc.ID.String()+"/scheduled-transfers", bytes.NewBufferString(body))
+	req.Header.Set("Authorization", "Bearer "+createToken("test_user"))
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusCreated, rr.Code)
+	var st model.ScheduledTransfer
+	json.NewDecoder(rr.Body).Decode(&st)
+	assert.Equal(t, "2024-03-10", st.ExecuteOn)
+	assert.Equal(t, model.ScheduledPending, st.Status)
+}
+
+func TestCancelScheduledTransferHandler(t *testing.T) {
+	mockSvc, scheduledSvc := new(MockService), new(MockScheduledTransferService)
+	r := setupScheduledTransferRouter(mockSvc, scheduledSvc)
+	acc := ownAccount(mockSvc, uuid.New())
+	st := &model.ScheduledTransfer{ID: uuid.New(), FromAccountID: acc.ID, Status: model.ScheduledExecuted}
+	scheduledSvc.On("GetScheduledTransfer", mock.Anything, st.ID.String()).Return(st, nil)
+	scheduledSvc.On("CancelScheduledTransfer", mock.Anything, st.ID.String()).
+		Return(nil, &service.ScheduledTransferNotPendingError{ID: st.ID, Status: st.Status})
+
+	req, _ := http.NewRequest("POST", "/scheduled-transfers/"+st.ID.String()+"/cancel", nil)
+	req.Header.Set("Authorization", "Bearer "+createToken("test_user"))
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusConflict, rr.Code)
+	assert.Equal(t, problem.CodeScheduledTransferNotPending, decodeProblem(t, rr).Code)
+}
+
+func TestAmendScheduledTransferHandler_ForeignTransferIsForbidden(t *testing.T) {
+	mockSvc, scheduledSvc := new(MockService), new(MockScheduledTransferService)
+	r := setupScheduledTransferRouter(mockSvc, scheduledSvc)
+	foreign := &model.Account{ID: uuid.New(), CustomerID: uuid.New(), Currency: money.PLN}
+	mockSvc.On("GetAccount", mock.Anything, foreign.ID.String()).Return(foreign, nil)
+	st := &model.ScheduledTransfer{ID: uuid.New(), FromAccountID: foreign.ID, Status: model.ScheduledPending}
+	scheduledSvc.On("GetScheduledTransfer", mock.Anything, st.ID.String()).Return(st, nil)
+
+	req, _ := http.NewRequest("PATCH", "/scheduled-transfers/"+st.ID.String(), bytes.NewBufferString(`{"amount": "1.00"}`))
+	req.Header.Set("Authorization", "Bearer "+createToken("test_user"))
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusForbidden, rr.Code)
+	scheduledSvc.AssertNotCalled(t, "AmendScheduledTransfer", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestAmendScheduledTransferHandler(t *testing.T) {
+	mockSvc, scheduledSvc := new(MockService), new(MockScheduledTransferService)
+	r := setupScheduledTransferRouter(mockSvc, scheduledSvc)
+	acc := ownAccount(mockSvc, uuid.New())
+	st := &model.ScheduledTransfer{ID: uuid.New(), FromAccountID: acc.ID, Status: model.ScheduledPending}
+	scheduledSvc.On("GetScheduledTransfer", mock.Anything, st.ID.String()).Return(st, nil)
+	scheduledSvc.On("AmendScheduledTransfer", mock.Anything, st.ID.String(), mock.MatchedBy(func(a model.ScheduledTransferAmendment) bool {
+		return a.ExecuteOn != nil && *a.ExecuteOn == "2024-03-12" && a.Amount == nil && a.Description == nil
+	})).Return(&model.ScheduledTransfer{ID: st.ID, ExecuteOn: "2024-03-12", Status: model.ScheduledPending}, nil)
+
+	req, _ := http.NewRequest("PATCH", "/scheduled-transfers/"+st.ID.String(), bytes.NewBufferString(`{"executeOn": "2024-03-12"}`))
+	req.Header.Set("Authorization", "Bearer "+createToken("test_user"))
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	scheduledSvc.AssertExpectations(t)
+}
+
+func TestExecuteDueTransfersHandler_RequiresAdmin(t *testing.T) {
+	mockSvc, scheduledSvc := new(MockService), new(MockScheduledTransferService)
+	r := setupScheduledTransferRouter(mockSvc, scheduledSvc)
+	scheduledSvc.On("ExecuteDueTransfers", mock.Anything).Return(&model.ScheduledTransferRun{
+		Executed: []model.ScheduledTransfer{{}}, Retrying: []model.ScheduledTransfer{}, Failed: []model.ScheduledTransfer{},
+	}, nil)
+
+	for _, tc := range []struct {
+		user   string
+		status int
+	}{
+		{"test_user", http.StatusForbidden},
+		{"admin", http.StatusCreated},
+	} {
+		req, _ := http.NewRequest("POST", "/admin/scheduled-transfers/runs", nil)
+		req.Header.Set("Authorization", "Bearer "+createToken(tc.user))
+		rr := httptest.NewRecorder()
+		r.ServeHTTP(rr, req)
+
+		assert.Equal(t, tc.status, rr.Code, tc.user)
+	}
+	scheduledSvc.AssertNumberOfCalls(t, "ExecuteDueTransfers", 1)
+}
@@ -0,0 +1,182 @@
+package handler
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+
+	"go-web-server/services/account-service/handler/problem"
+	"go-web-server/services/account-service/model"
+	"go-web-server/services/account-service/money"
+	"go-web-server/services/account-service/service"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// StandingOrderHandler serves recurring transfers to the owners of their
+// source accounts, and lets admins schedule the payments that fell due.
+type StandingOrderHandler struct {
+	*AccountHandler
+	standing service.StandingOrderService
+	admin    func(http.Handler) http.Handler
+}
+
+// NewStandingOrderHandler creates the handler. Account ownership is checked
+// through accounts; admin guards the scheduling run.
+func NewStandingOrderHandler(accounts *AccountHandler, standing service.StandingOrderService, admin func(http.Handler) http.Handler) *StandingOrderHandler {
+	return &StandingOrderHandler{AccountHandler: accounts, standing: standing, admin: admin}
+}
+
+func (h *StandingOrderHandler) RegisterRoutes(r chi.Router) {
+	r.Group(func(r chi.Router) {
+		r.Use(h.auth)
+		r.Post("/accounts/{accountId}/standing-orders", h.CreateStandingOrder)
+		r.Get("/accounts/{accountId}/standing-orders", h.ListStandingOrders)
+		r.Get("/standing-orders/{standingOrderId}", h.GetStandingOrder)
+		r.Get("/standing-orders/{standingOrderId}/payments", h.ListStandingOrderPayments)
+		r.Post("/standing-orders/{standingOrderId}/cancel", h.CancelStandingOrder)
+
+		r.Group(func(r chi.Router) {
+			r.Use(h.admin)
+			r.Post("/admin/standing-orders/runs", h.ScheduleDuePayments)
+		})
+	})
+}
+
+func (h *StandingOrderHandler) CreateStandingOrder(w http.ResponseWriter, r *http.Request) {
+	accountID := chi.URLParam(r, "accountId")
+	// Only the source account has to belong to the caller.
+	if _, ok := h.authorizeAccount(w, r, accountID); !ok {
+		return
+	}
+
+	var body struct {
+		ToAccountID string       `json:"toAccountId"`
+		Amount      money.Amount `json:"amount"`
+		Currency    string       `json:"currency"`
+		Description string       `json:"description"`
+		model.StandingOrderSchedule
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		log.Printf("Error decoding standing order body for account %s: %v", accountID, err)
+		respondWithError(w, http.StatusBadRequest, problem.CodeMalformedRequest, "Invalid request body")
+		return
+	}
+	if body.ToAccountID == "" {
+		respondWithInvalidParam(w, "toAccountId", "Destination account ID is required")
+		return
+	}
+	currency, err := money.ParseCurrency(body.Currency)
+	if err != nil {
+		respondWithInvalidParam(w, "currency", err.Error())
+		return
+	}
+	amount, err := money.NewExact(body.Amount, currency)
+	if err != nil {
+		respondWithInvalidParam(w, "amount", err.Error())
+		return
+	}
+
+	ctx, ok := idempotencyContext(w, r)
+	if !ok {
+		return
+	}
+
+	so, err := h.standing.CreateStandingOrder(ctx, accountID, body.ToAccountID, amount, body.Description, body.StandingOrderSchedule)
+	if err != nil {
+		log.Printf("Error creating standing order from account %s to %s: %v", accountID, body.ToAccountID, err)
+		respondWithServiceError(w, err)
+		return
+	}
+
+	log.Printf("Standing order %s of %s %s %s from %s to %s created, first payment on %s", so.ID, so.Amount, so.Currency, so.Frequency, accountID, body.ToAccountID, so.NextExecuteOn)
+	respondWithJSON(w, http.StatusCreated, so)
+}
+
+func (h *StandingOrderHandler) ListStandingOrders(w http.ResponseWriter, r *http.Request) {
+	accountID := chi.URLParam(r, "accountId")
+	if _, ok := h.authorizeAccount(w, r, accountID); !ok {
+		return
+	}
+
+	orders, err := h.standing.ListStandingOrders(r.Context(), accountID)
+	if err != nil {
+		log.Printf("Error listing standing orders of account %s: %v", accountID, err)
+		respondWithServiceError(w, err)
+		return
+	}
+	respondWithJSON(w, http.StatusOK, map[string]interface{}{"standingOrders": orders})
+}
+
+func (h *StandingOrderHandler) GetStandingOrder(w http.ResponseWriter, r *http.Request) {
+	so, ok := h.authorizeStandingOrder(w, r)
+	if !ok {
+		return
+	}
+	respondWithJSON(w, http.StatusOK, so)
+}
+
+// ListStandingOrderPayments returns the scheduled transfers a standing
+// order made; the ledger entries of each executed one carry its
+// transferReferenceId.
+func (h *StandingOrderHandler) ListStandingOrderPayments(w http.ResponseWriter, r *http.Request) {
+	so, ok := h.authorizeStandingOrder(w, r)
+	if !ok {
+		return
+	}
+
+	payments, err := h.standing.ListStandingOrderPayments(r.Context(), so.ID.String())
+	if err != nil {
+		log.Printf("Error listing payments of standing order %s: %v", so.ID, err)
+		respondWithServiceError(w, err)
+		return
+	}
+	respondWithJSON(w, http.StatusOK, map[string]interface{}{"payments": payments})
+}
+
+func (h *StandingOrderHandler) CancelStandingOrder(w http.ResponseWriter, r *http.Request) {
+	so, ok := h.authorizeStandingOrder(w, r)
+	if !ok {
+		return
+	}
+
+	cancelled, err := h.standing.CancelStandingOrder(r.Context(), so.ID.String())
+	if err != nil {
+		log.Printf("Error cancelling standing order %s: %v", so.ID, err)
+		respondWithServiceError(w, err)
+		return
+	}
+
+	log.Printf("Standing order %s cancelled", so.ID)
+	respondWithJSON(w, http.StatusOK, cancelled)
+}
+
+// ScheduleDuePayments runs the scheduling pass now instead of waiting for
+// the worker.
+func (h *StandingOrderHandler) ScheduleDuePayments(w http.ResponseWriter, r *http.Request) {
+	run, err := h.standing.ScheduleDuePayments(r.Context())
+	if err != nil {
+		log.Printf("Error scheduling standing order payments: %v", err)
+		respondWithServiceError(w, err)
+		return
+	}
+
+	log.Printf("Standing orders: %d payments scheduled, %d orders completed", len(run.Scheduled), len(run.Completed))
+	respondWithJSON(w, http.StatusCreated, run)
+}
+
+// authorizeStandingOrder loads the standing order named in the path and
+// allows the request only if the caller owns its source account.
+func (h *StandingOrderHandler) authorizeStandingOrder(w http.ResponseWriter, r *http.Request) (*model.StandingOrder, bool) {
+	id := chi.URLParam(r, "standingOrderId")
+	so, err := h.standing.GetStandingOrder(r.Context(), id)
+	if err != nil {
+		log.Printf("Error getting standing order %s: %v", id, err)
+		respondWithServiceError(w, err)
+		return nil, false
+	}
+	if _, ok := h.authorizeAccount(w, r, so.FromAccountID.String()); !ok {
+		return nil, false
+	}
+	return so, true
+}
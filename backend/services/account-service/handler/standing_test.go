@@ -0,0 +1,212 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"go-web-server/services/account-service/handler/middleware"
+	"go-web-server/services/account-service/handler/problem"
+	"go-web-server/services/account-service/model"
+	"go-web-server/services/account-service/money"
+	"go-web-server/services/account-service/service"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+type MockStandingOrderService struct {
+	mock.Mock
+}
+
+func (m *MockStandingOrderService) CreateStandingOrder(ctx context.Context, fromAccountID, toAccountID string, amount money.Money, description string, schedule model.StandingOrderSchedule) (*model.StandingOrder, error) {
+	args := m.Called(ctx, fromAccountID, toAccountID, amount, description, schedule)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.StandingOrder), args.Error(1)
+}
+
+func (m *MockStandingOrderService) GetStandingOrder(ctx context.Context, id string) (*model.StandingOrder, error) {
+	args := m.Called(ctx, id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.StandingOrder), args.Error(1)
+}
+
+func (m *MockStandingOrderService) ListStandingOrders(ctx context.Context, accountID string) ([]model.StandingOrder, error) {
+	args := m.Called(ctx, accountID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]model.StandingOrder), args.Error(1)
+}
+
+func (m *MockStandingOrderService) ListStandingOrderPayments(ctx context.Context, id string) ([]model.ScheduledTransfer, error) {
+	args := m.Called(ctx, id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]model.ScheduledTransfer), args.Error(1)
+}
+
+func (m *MockStandingOrderService) CancelStandingOrder(ctx context.Context, id string) (*model.StandingOrder, error) {
+	args := m.Called(ctx, id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.StandingOrder), args.Error(1)
+}
+
+func (m *MockStandingOrderService) ScheduleDuePayments(ctx context.Context) (*model.StandingOrderRun, error) {
+	args := m.Called(ctx)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.StandingOrderRun), args.Error(1)
+}
+
+func setupStandingOrderRouter(mockSvc *MockService, standingSvc *MockStandingOrderService) chi.Router {
+	mockSvc.On("GetCustomerByExternalID", mock.Anything, "test_user").Return(testCustomer, nil).Maybe()
+
+	r := chi.NewRouter()
+	accounts := NewAccountHandler(mockSvc, middleware.AuthMiddleware(jwtKey, nil), middleware.RequireAdmin())
+	NewStandingOrderHandler(accounts, standingSvc, middleware.RequireAdmin()).RegisterRoutes(r)
+	return r
+}
+
+func TestCreateStandingOrderHandler(t *testing.T) {
+	mockSvc, standingSvc := new(MockService), new(MockStandingOrderService)
+	r := setupStandingOrderRouter(mockSvc, standingSvc)
+	acc := ownAccount(mockSvc, uuid.New())
+	toID := uuid.New()
+	amount := money.New(money.MustParseAmount("1500"), money.PLN)
+	schedule := model.StandingOrderSchedule{Frequency: model.FrequencyMonthly, DayOfMonth: 10, StartOn: "2024-03-01", MaxOccurrences: 12}
+	standingSvc.On("CreateStandingOrder", mock.Anything, acc.ID.String(), toID.String(), amount, "Rent", schedule).Return(&model.StandingOrder{
+		ID: uuid.New(), FromAccountID: acc.ID, ToAccountID: toID, Amount: amount.Amount, Currency: money.PLN, Description: "Rent",
+		StandingOrderSchedule: schedule, Status: model.StandingOrderActive, NextDueOn: "2024-03-10", NextExecuteOn: "2024-03-11",
+	}, nil)
+
+	body := `{"toAccountId": "` + toID.String() + `", "amount": "1500.00", "currency": "PLN", "description": "Rent",
+		"frequency": "monthly", "dayOfMonth": 10, "startOn": "2024-03-01", "maxOccurrences": 12}`
+	req, _ := http.NewRequest("POST", "/accounts/"+acc.ID.String()+"/standing-orders", bytes.NewBufferString(body))
+	req.Header.Set("Authorization", "Bearer "+createToken("test_user"))
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusCreated, rr.Code)
+	var so model.StandingOrder
+	json.NewDecoder(rr.Body).Decode(&so)
+	assert.Equal(t, model.FrequencyMonthly, so.Frequency)
+	assert.Equal(t, 10, so.DayOfMonth)
+	assert.Equal(t, "2024-03-11", so.NextExecuteOn)
+}
+
+func TestCreateStandingOrderHandler_InvalidSchedule(t *testing.T) {
+	mockSvc, standingSvc := new(MockService), new(MockStandingOrderService)
+	r := setupStandingOrderRouter(mockSvc, standingSvc)
+	acc := ownAccount(mockSvc, uuid.New())
+	standingSvc.On("CreateStandingOrder", mock.Anything, acc.ID.String(), mock.Anything, mock.Anything, mock.Anything, mock.Anything).
+		Return(nil, &service.ValidationError{Field: "dayOfMonth", Err: assert.AnError})
+
+	body := `{"toAccountId": "` + uuid.New().String() + `", "amount": "10.00", "currency": "PLN", "frequency": "monthly", "startOn": "2024-03-01"}`
+	req, _ := http.NewRequest("POST", "/accounts/"+acc.ID.String()+"/standing-orders", bytes.NewBufferString(body))
+	req.Header.Set("Authorization", "Bearer "+createToken("test_user"))
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+	assert.Equal(t, problem.CodeValidation, decodeProblem(t, rr).Code)
+}
+
+func TestListStandingOrderPaymentsHandler(t *testing.T) {
+	mockSvc, standingSvc := new(MockService), new(MockStandingOrderService)
+	r := setupStandingOrderRouter(mockSvc, standingSvc)
+	acc := ownAccount(mockSvc, uuid.New())
+	so := &model.StandingOrder{ID: uuid.New(), FromAccountID: acc.ID, Status: model.StandingOrderActive}
+	ref := uuid.New()
+	standingSvc.On("GetStandingOrder", mock.Anything, so.ID.String()).Return(so, nil)
+	standingSvc.On("ListStandingOrderPayments", mock.Anything, so.ID.String()).Return([]model.ScheduledTransfer{
+		{ID: uuid.New(), Status: model.ScheduledExecuted, TransferReferenceID: &ref, StandingOrderID: &so.ID},
+	}, nil)
+
+	req, _ := http.NewRequest("GET", "/standing-orders/"+so.ID.String()+"/payments", nil)
+	req.Header.Set("Authorization", "Bearer "+createToken("test_user"))
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	var body struct {
+		Payments []model.ScheduledTransfer `json:"payments"`
+	}
+	json.NewDecoder(rr.Body).Decode(&body)
+	if assert.Len(t, body.Payments, 1) {
+		assert.Equal(t, &ref, body.Payments[0].TransferReferenceID)
+		assert.Equal(t, &so.ID, body.Payments[0].StandingOrderID)
+	}
+}
+
+func TestCancelStandingOrderHandler_ForeignOrderIsForbidden(t *testing.T) {
+	mockSvc, standingSvc := new(MockService), new(MockStandingOrderService)
+	r := setupStandingOrderRouter(mockSvc, standingSvc)
+	foreign := &model.Account{ID: uuid.New(), CustomerID: uuid.New(), Currency: money.PLN}
+	mockSvc.On("GetAccount", mock.Anything, foreign.ID.String()).Return(foreign, nil)
+	so := &model.StandingOrder{ID: uuid.New(), FromAccountID: foreign.ID, Status: model.StandingOrderActive}
+	standingSvc.On("GetStandingOrder", mock.Anything, so.ID.String()).Return(so, nil)
+
+	req, _ := http.NewRequest("POST", "/standing-orders/"+so.ID.String()+"/cancel", nil)
+	req.Header.Set("Authorization", "Bearer "+createToken("test_user"))
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusForbidden, rr.Code)
+	standingSvc.AssertNotCalled(t, "CancelStandingOrder", mock.Anything, mock.Anything)
+}
+
+func TestCancelStandingOrderHandler_NotActive(t *testing.T) {
+	mockSvc, standingSvc := new(MockService), new(MockStandingOrderService)
+	r := setupStandingOrderRouter(mockSvc, standingSvc)
+	acc := ownAccount(mockSvc, uuid.New())
+	so := &model.StandingOrder{ID: uuid.New(), FromAccountID: acc.ID, Status: model.StandingOrderCompleted}
+	standingSvc.On("GetStandingOrder", mock.Anything, so.ID.String()).Return(so, nil)
+	standingSvc.On("CancelStandingOrder", mock.Anything, so.ID.String()).
+		Return(nil, &service.StandingOrderNotActiveError{ID: so.ID, Status: so.Status})
+
+	req, _ := http.NewRequest("POST", "/standing-orders/"+so.ID.String()+"/cancel", nil)
+	req.Header.Set("Authorization", "Bearer "+createToken("test_user"))
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusConflict, rr.Code)
+	assert.Equal(t, problem.CodeStandingOrderNotActive, decodeProblem(t, rr).Code)
+}
+
+func TestScheduleDuePaymentsHandler_RequiresAdmin(t *testing.T) {
+	mockSvc, standingSvc := new(MockService), new(MockStandingOrderService)
+	r := setupStandingOrderRouter(mockSvc, standingSvc)
+	standingSvc.On("ScheduleDuePayments", mock.Anything).Return(&model.StandingOrderRun{
+		Scheduled: []model.ScheduledTransfer{{}}, Completed: []model.StandingOrder{},
+	}, nil)
+
+	for _, tc := range []struct {
+		user   string
+		status int
+	}{
+		{"test_user", http.StatusForbidden},
+		{"admin", http.StatusCreated},
+	} {
+		req, _ := http.NewRequest("POST", "/admin/standing-orders/runs", nil)
+		req.Header.Set("Authorization", "Bearer "+createToken(tc.user))
+		rr := httptest.NewRecorder()
+		r.ServeHTTP(rr, req)
+
+		assert.Equal(t, tc.status, rr.Code, tc.user)
+	}
+	standingSvc.AssertNumberOfCalls(t, "ScheduleDuePayments", 1)
+}
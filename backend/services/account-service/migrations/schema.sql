@@ -10,32 +10,451 @@ CREATE TABLE IF NOT EXISTS customers (
     created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
 );
 
+-- Account product catalogue; every account belongs to one product
+-- Amounts apply in the account's currency; a zero max_withdrawal or max_balance means no cap
+CREATE TABLE IF NOT EXISTS products (
+    code VARCHAR(40) PRIMARY KEY,
+    name VARCHAR(100) NOT NULL,
+    kind VARCHAR(20) NOT NULL DEFAULT 'current', -- current, savings; copied to accounts.kind
+    currencies VARCHAR(3)[] NOT NULL, -- Currencies accounts may be opened in
+    max_overdraft NUMERIC(20, 4) NOT NULL DEFAULT 0 CHECK (max_overdraft >= 0), -- Cap on accounts.overdraft_limit
+    interest_rate NUMERIC(20, 8), -- Annual savings rate; NULL accrues at the configured default
+    max_withdrawal NUMERIC(20, 4) NOT NULL DEFAULT 0 CHECK (max_withdrawal >= 0), -- Cap on a single debit
+    max_balance NUMERIC(20, 4) NOT NULL DEFAULT 0 CHECK (max_balance >= 0), -- Cap on the balance after a credit
+    daily_limit NUMERIC(20, 4) NOT NULL DEFAULT 0 CHECK (daily_limit >= 0), -- Cap on a day's withdrawals and outgoing transfers
+    monthly_limit NUMERIC(20, 4) NOT NULL DEFAULT 0 CHECK (monthly_limit >= 0), -- Cap on a month's withdrawals and outgoing transfers
+    max_daily_transactions INTEGER NOT NULL DEFAULT 0 CHECK (max_daily_transactions >= 0), -- Velocity: withdrawals and outgoing transfers a day
+    active BOOLEAN NOT NULL DEFAULT TRUE, -- Inactive products cannot be opened
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Columns added since the table was first created, for existing databases
+ALTER TABLE products ADD COLUMN IF NOT EXISTS daily_limit NUMERIC(20, 4) NOT NULL DEFAULT 0 CHECK (daily_limit >= 0);
+ALTER TABLE products ADD COLUMN IF NOT EXISTS monthly_limit NUMERIC(20, 4) NOT NULL DEFAULT 0 CHECK (monthly_limit >= 0);
+ALTER TABLE products ADD COLUMN IF NOT EXISTS max_daily_transactions INTEGER NOT NULL DEFAULT 0 CHECK (max_daily_transactions >= 0);
+
+-- Seeded before accounts: existing accounts get product_code 'current' below
+INSERT INTO products (code, name, kind, currencies, max_overdraft, max_withdrawal, max_balance)
+VALUES
+('current', 'Current account', 'current', '{PLN}', 10000, 0, 0),
+('savings', 'Savings account', 'savings', '{PLN}', 0, 20000, 1000000),
+('foreign_currency', 'Foreign currency account', 'current', '{EUR,USD,GBP,CHF}', 0, 0, 0)
+ON CONFLICT (code) DO NOTHING;
+
 -- Accounts table supporting multiple currencies per customer
 CREATE TABLE IF NOT EXISTS accounts (
     id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
     customer_id UUID NOT NULL REFERENCES customers(id),
     account_number VARCHAR(34) UNIQUE NOT NULL, -- IBAN or internal unique format
     currency CHAR(3) NOT NULL, -- ISO 4217 Currency Code (e.g., PLN, USD, EUR)
+    product_code VARCHAR(40) NOT NULL DEFAULT 'current' REFERENCES products(code),
     balance NUMERIC(20, 4) NOT NULL DEFAULT 0.0000,
     status VARCHAR(20) NOT NULL DEFAULT 'active', -- active, frozen, closed
+    kind VARCHAR(20) NOT NULL DEFAULT 'current', -- current, savings; the kind of the product
+    overdraft_limit NUMERIC(20, 4) NOT NULL DEFAULT 0 CHECK (overdraft_limit >= 0), -- How far below zero debits may take the available balance
+    accrued_interest NUMERIC(20, 4) NOT NULL DEFAULT 0, -- Savings interest accrued since the last capitalisation
+    ledger_seq BIGINT NOT NULL DEFAULT 0, -- Sequence of the last ledger entry in the hash chain
+    ledger_head_hash CHAR(64), -- entry_hash of that entry
     created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
     updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
 );
 
+-- Columns added since the table was first created, for existing databases
+ALTER TABLE accounts ADD COLUMN IF NOT EXISTS product_code VARCHAR(40) NOT NULL DEFAULT 'current' REFERENCES products(code);
+ALTER TABLE accounts ADD COLUMN IF NOT EXISTS kind VARCHAR(20) NOT NULL DEFAULT 'current';
+ALTER TABLE accounts ADD COLUMN IF NOT EXISTS overdraft_limit NUMERIC(20, 4) NOT NULL DEFAULT 0 CHECK (overdraft_limit >= 0);
+ALTER TABLE accounts ADD COLUMN IF NOT EXISTS accrued_interest NUMERIC(20, 4) NOT NULL DEFAULT 0;
+ALTER TABLE accounts ADD COLUMN IF NOT EXISTS ledger_seq BIGINT NOT NULL DEFAULT 0;
+ALTER TABLE accounts ADD COLUMN IF NOT EXISTS ledger_head_hash CHAR(64);
+
 -- Ledger for all balance-changing operations (Audit Trail)
 -- Each entry records the balance before and after for strict auditability
 CREATE TABLE IF NOT EXISTS ledger_entries (
     id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
     account_id UUID NOT NULL REFERENCES accounts(id),
-    type VARCHAR(50) NOT NULL, -- deposit, withdrawal, transfer_in, transfer_out, fx_exchange
+    type VARCHAR(50) NOT NULL, -- deposit, withdrawal, transfer_in, transfer_out, fx_exchange, hold_capture, overdraft_interest, interest, fee
     amount NUMERIC(20, 4) NOT NULL,
     balance_after NUMERIC(20, 4) NOT NULL,
     reference_id UUID, -- To link related entries (e.g., in transfers)
     description TEXT,
+    fx_rate NUMERIC(20, 8), -- Rate applied by fx_exchange entries
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    -- Per-account hash chain: entry_hash covers the entry and prev_hash, the
+    -- entry_hash of the entry before it. NULL only for entries written before
+    -- the chain existed, until the one-time seal migration.
+    seq BIGINT,
+    prev_hash CHAR(64),
+    entry_hash CHAR(64),
+    value_date DATE NOT NULL DEFAULT CURRENT_DATE -- Business day the entry counts from, by the calendar's cut-off; covered by entry_hash
+);
+
+-- Columns added since the table was first created, for existing databases
+ALTER TABLE ledger_entries ADD COLUMN IF NOT EXISTS fx_rate NUMERIC(20, 8);
+ALTER TABLE ledger_entries ADD COLUMN IF NOT EXISTS seq BIGINT;
+ALTER TABLE ledger_entries ADD COLUMN IF NOT EXISTS prev_hash CHAR(64);
+ALTER TABLE ledger_entries ADD COLUMN IF NOT EXISTS entry_hash CHAR(64);
+-- Entries booked before value dates existed take value on the day they were booked
+ALTER TABLE ledger_entries ADD COLUMN IF NOT EXISTS value_date DATE;
+UPDATE ledger_entries SET value_date = created_at::date WHERE value_date IS NULL;
+ALTER TABLE ledger_entries ALTER COLUMN value_date SET DEFAULT CURRENT_DATE, ALTER COLUMN value_date SET NOT NULL;
+
+-- Account lifecycle transitions (freeze, unfreeze, close), each with a reason code
+CREATE TABLE IF NOT EXISTS account_status_changes (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    account_id UUID NOT NULL REFERENCES accounts(id),
+    from_status VARCHAR(20) NOT NULL,
+    to_status VARCHAR(20) NOT NULL,
+    reason VARCHAR(50) NOT NULL, -- customer_request, suspected_fraud, compliance_review, ledger_discrepancy, ...
+    note TEXT,
+    payout_reference_id UUID, -- reference_id of the transfer that emptied the account on close
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Idempotency keys for balance-changing requests
+-- A key is claimed in the same transaction as the ledger write it protects,
+-- so a retried request either sees the committed result or nothing at all
+CREATE TABLE IF NOT EXISTS idempotency_keys (
+    owner VARCHAR(100) NOT NULL, -- Authenticated caller that sent the key
+    key VARCHAR(255) NOT NULL,
+    fingerprint CHAR(64) NOT NULL, -- SHA-256 of the operation and its parameters
+    response JSONB, -- Result returned to the client on first execution
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (owner, key)
+);
+
+-- Exchange rates set by admins; they override the FX_RATES_FILE rates
+-- One unit of base_currency costs bid (bank buys) or ask (bank sells) units of quote_currency
+CREATE TABLE IF NOT EXISTS fx_rates (
+    base_currency VARCHAR(3) NOT NULL,
+    quote_currency VARCHAR(3) NOT NULL,
+    bid NUMERIC(20, 8) NOT NULL,
+    ask NUMERIC(20, 8) NOT NULL,
+    updated_by VARCHAR(100) NOT NULL,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (base_currency, quote_currency),
+    CHECK (base_currency <> quote_currency AND bid > 0 AND ask >= bid)
+);
+
+-- Time-limited FX quotes; executing one writes two fx_exchange ledger entries sharing reference_id
+CREATE TABLE IF NOT EXISTS fx_quotes (
+    id UUID PRIMARY KEY,
+    from_account_id UUID NOT NULL REFERENCES accounts(id),
+    to_account_id UUID NOT NULL REFERENCES accounts(id),
+    sell_amount NUMERIC(20, 4) NOT NULL,
+    sell_currency VARCHAR(3) NOT NULL,
+    buy_amount NUMERIC(20, 4) NOT NULL,
+    buy_currency VARCHAR(3) NOT NULL,
+    rate NUMERIC(20, 8) NOT NULL, -- buy_currency units per sell_currency unit, spread included
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    executed_at TIMESTAMP WITH TIME ZONE,
+    reference_id UUID, -- ledger_entries.reference_id of the executed exchange
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+-- General ledger: the chart of accounts
+-- customer_deposits aggregates every customer account; the rest are the bank's own
+CREATE TABLE IF NOT EXISTS gl_accounts (
+    code VARCHAR(50) PRIMARY KEY,
+    name VARCHAR(100) NOT NULL,
+    type VARCHAR(20) NOT NULL -- asset, liability, income, expense
+);
+
+-- Double-entry journal; every ledger entry is posted here in the same transaction
+-- Lines sharing journal_id balance per currency: SUM(debit) = SUM(credit)
+CREATE TABLE IF NOT EXISTS journal_lines (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    journal_id UUID NOT NULL, -- reference_id of the ledger entries, or the id of a single entry
+    gl_account VARCHAR(50) NOT NULL REFERENCES gl_accounts(code),
+    account_id UUID REFERENCES accounts(id), -- Customer account of customer_deposits lines
+    ledger_entry_id UUID REFERENCES ledger_entries(id),
+    currency VARCHAR(3) NOT NULL,
+    debit NUMERIC(20, 4) NOT NULL DEFAULT 0,
+    credit NUMERIC(20, 4) NOT NULL DEFAULT 0,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    CHECK (debit >= 0 AND credit >= 0 AND (debit = 0) <> (credit = 0))
+);
+
+-- Balance-versus-ledger reconciliation runs; report holds the full JSON report
+CREATE TABLE IF NOT EXISTS reconciliation_reports (
+    id UUID PRIMARY KEY,
+    started_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    finished_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    clean BOOLEAN NOT NULL, -- false when any discrepancy was found
+    report JSONB NOT NULL
+);
+
+-- Signed checkpoints of every account's ledger chain head
+CREATE TABLE IF NOT EXISTS ledger_checkpoints (
+    id UUID PRIMARY KEY,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    heads JSONB NOT NULL, -- [{accountId, sequence, hash}]
+    root CHAR(64) NOT NULL, -- SHA-256 over the heads
+    key_id VARCHAR(32) NOT NULL,
+    public_key TEXT NOT NULL, -- Base64 Ed25519 public key
+    signature TEXT NOT NULL -- Base64 Ed25519 signature
+);
+
+-- Funds reserved on an account; they count against the available balance
+-- until captured, released or expired. Captures write hold_capture ledger
+-- entries whose reference_id is the hold id.
+CREATE TABLE IF NOT EXISTS holds (
+    id UUID PRIMARY KEY,
+    account_id UUID NOT NULL REFERENCES accounts(id),
+    amount NUMERIC(20, 4) NOT NULL CHECK (amount > 0),
+    captured NUMERIC(20, 4) NOT NULL DEFAULT 0, -- Settled so far
+    currency VARCHAR(3) NOT NULL,
+    status VARCHAR(20) NOT NULL DEFAULT 'active', -- active, captured, released, expired
+    description TEXT,
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    CHECK (captured >= 0 AND captured <= amount)
+);
+
+-- Who set each account's overdraft limit, and from what
+CREATE TABLE IF NOT EXISTS overdraft_limit_changes (
+    id UUID PRIMARY KEY,
+    account_id UUID NOT NULL REFERENCES accounts(id),
+    old_limit NUMERIC(20, 4) NOT NULL,
+    new_limit NUMERIC(20, 4) NOT NULL,
+    currency VARCHAR(3) NOT NULL,
+    changed_by VARCHAR(255) NOT NULL, -- Admin username
+    note TEXT,
     created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
 );
 
+-- One row per account, kind and business day of interest posted, so that a
+-- rerun of the same day books nothing twice
+CREATE TABLE IF NOT EXISTS interest_postings (
+    account_id UUID NOT NULL REFERENCES accounts(id),
+    kind VARCHAR(20) NOT NULL, -- overdraft, savings
+    business_date DATE NOT NULL,
+    amount NUMERIC(20, 4) NOT NULL,
+    ledger_entry_id UUID NOT NULL REFERENCES ledger_entries(id),
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (account_id, kind, business_date)
+);
+
+-- Fee schedules: what a product charges for an operation, in the currency
+-- of each account. A fee is flat plus percentage of the amount, raised to
+-- min_fee and capped at max_fee unless that is zero
+CREATE TABLE IF NOT EXISTS fee_schedules (
+    product_code VARCHAR(40) NOT NULL REFERENCES products(code),
+    operation VARCHAR(20) NOT NULL, -- withdrawal, transfer, fx_exchange (markup on the amount sold), maintenance (monthly)
+    flat NUMERIC(20, 4) NOT NULL DEFAULT 0 CHECK (flat >= 0),
+    percentage NUMERIC(20, 8) CHECK (percentage > 0), -- Fraction of the amount, e.g. 0.005
+    min_fee NUMERIC(20, 4) NOT NULL DEFAULT 0 CHECK (min_fee >= 0),
+    max_fee NUMERIC(20, 4) NOT NULL DEFAULT 0 CHECK (max_fee >= 0),
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (product_code, operation)
+);
+
+-- One row per account and month of maintenance fee charged, so that a
+-- rerun of the same month charges nothing twice
+CREATE TABLE IF NOT EXISTS maintenance_fee_charges (
+    account_id UUID NOT NULL REFERENCES accounts(id),
+    month DATE NOT NULL, -- First day of the month charged
+    amount NUMERIC(20, 4) NOT NULL,
+    ledger_entry_id UUID NOT NULL REFERENCES ledger_entries(id),
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (account_id, month)
+);
+
+-- Per-account replacements for the transaction limits of the account's
+-- product; NULL keeps the product's limit
+CREATE TABLE IF NOT EXISTS account_limits (
+    account_id UUID PRIMARY KEY REFERENCES accounts(id),
+    per_transaction NUMERIC(20, 4) CHECK (per_transaction >= 0),
+    daily_limit NUMERIC(20, 4) CHECK (daily_limit >= 0),
+    monthly_limit NUMERIC(20, 4) CHECK (monthly_limit >= 0),
+    max_daily_transactions INTEGER CHECK (max_daily_transactions >= 0),
+    updated_by VARCHAR(255) NOT NULL, -- Admin username
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Withdrawals and outgoing transfers per account and day, counted under the
+-- account lock in the transaction that books them. A month's usage is the
+-- sum of its days.
+CREATE TABLE IF NOT EXISTS limit_usage (
+    account_id UUID NOT NULL REFERENCES accounts(id),
+    day DATE NOT NULL,
+    amount NUMERIC(20, 4) NOT NULL DEFAULT 0,
+    transactions INTEGER NOT NULL DEFAULT 0,
+    PRIMARY KEY (account_id, day)
+);
+
+-- Limits across all of a customer's accounts in one currency, on top of the
+-- limits of each account; zero means no limit
+CREATE TABLE IF NOT EXISTS customer_limits (
+    customer_id UUID NOT NULL REFERENCES customers(id),
+    currency VARCHAR(3) NOT NULL,
+    daily_limit NUMERIC(20, 4) NOT NULL DEFAULT 0 CHECK (daily_limit >= 0),
+    monthly_limit NUMERIC(20, 4) NOT NULL DEFAULT 0 CHECK (monthly_limit >= 0),
+    max_daily_transactions INTEGER NOT NULL DEFAULT 0 CHECK (max_daily_transactions >= 0),
+    updated_by VARCHAR(255) NOT NULL, -- Admin username
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (customer_id, currency)
+);
+
+-- Withdrawals and outgoing transfers per customer, currency and day, counted
+-- with limit_usage in the transaction that books them
+CREATE TABLE IF NOT EXISTS customer_limit_usage (
+    customer_id UUID NOT NULL REFERENCES customers(id),
+    currency VARCHAR(3) NOT NULL,
+    day DATE NOT NULL,
+    amount NUMERIC(20, 4) NOT NULL DEFAULT 0,
+    transactions INTEGER NOT NULL DEFAULT 0,
+    PRIMARY KEY (customer_id, currency, day)
+);
+
+-- Recurring transfers. Each payment that falls due is added to
+-- scheduled_transfers with standing_order_id set; next_due_on is the day the
+-- next payment falls due by the schedule and next_execute_on the business day
+-- it is made. Both are NULL once the order is completed or cancelled.
+CREATE TABLE IF NOT EXISTS standing_orders (
+    id UUID PRIMARY KEY,
+    from_account_id UUID NOT NULL REFERENCES accounts(id),
+    to_account_id UUID NOT NULL REFERENCES accounts(id),
+    amount NUMERIC(20, 4) NOT NULL CHECK (amount > 0),
+    currency VARCHAR(3) NOT NULL,
+    description TEXT,
+    frequency VARCHAR(20) NOT NULL, -- weekly, monthly, last_business_day
+    day_of_month INTEGER CHECK (day_of_month BETWEEN 1 AND 31), -- monthly only
+    shift VARCHAR(10) NOT NULL, -- previous, next
+    start_on DATE NOT NULL,
+    end_on DATE,
+    max_occurrences INTEGER CHECK (max_occurrences > 0),
+    occurrences INTEGER NOT NULL DEFAULT 0,
+    next_due_on DATE,
+    next_execute_on DATE,
+    status VARCHAR(20) NOT NULL DEFAULT 'active', -- active, completed, cancelled
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    CHECK (from_account_id <> to_account_id)
+);
+
+-- Transfers a customer set for a future date. The worker executes a pending
+-- transfer on execute_on, or retries it at next_attempt_at after a failure,
+-- until it is executed or failed. Both ledger entries of an executed
+-- transfer share transfer_reference_id.
+CREATE TABLE IF NOT EXISTS scheduled_transfers (
+    id UUID PRIMARY KEY,
+    from_account_id UUID NOT NULL REFERENCES accounts(id),
+    to_account_id UUID NOT NULL REFERENCES accounts(id),
+    amount NUMERIC(20, 4) NOT NULL CHECK (amount > 0),
+    currency VARCHAR(3) NOT NULL,
+    description TEXT,
+    execute_on DATE NOT NULL,
+    status VARCHAR(20) NOT NULL DEFAULT 'pending', -- pending, executed, failed, cancelled
+    attempts INTEGER NOT NULL DEFAULT 0,
+    next_attempt_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    last_error TEXT,
+    transfer_reference_id UUID,
+    standing_order_id UUID REFERENCES standing_orders(id), -- Payment of a standing order
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    CHECK (from_account_id <> to_account_id)
+);
+
+-- Columns added since the table was first created, for existing databases
+ALTER TABLE scheduled_transfers ADD COLUMN IF NOT EXISTS standing_order_id UUID REFERENCES standing_orders(id);
+-- Payment of a standing order: set on the entries of the transfer that
+-- executed it, including the fee, when they are written; entry_hash covers it
+ALTER TABLE ledger_entries ADD COLUMN IF NOT EXISTS standing_order_id UUID REFERENCES standing_orders(id);
+
+-- Daily savings interest: one row per account and business day, written
+-- under the account lock, so that a rerun of the same day accrues nothing
+-- twice. Accrued interest is capitalised at the end of each month.
+CREATE TABLE IF NOT EXISTS interest_accruals (
+    account_id UUID NOT NULL REFERENCES accounts(id),
+    business_date DATE NOT NULL,
+    balance NUMERIC(20, 4) NOT NULL, -- End-of-day balance
+    rate NUMERIC(20, 8) NOT NULL, -- Annual rate
+    day_count VARCHAR(10) NOT NULL, -- ACT/365, 30/360
+    days INT NOT NULL, -- Days of interest the business day earns
+    amount NUMERIC(20, 4) NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (account_id, business_date)
+);
+
+-- One-time data migrations the application has run, such as sealing the
+-- ledger entries written before the hash chain existed
+CREATE TABLE IF NOT EXISTS schema_migrations (
+    name VARCHAR(100) PRIMARY KEY,
+    applied_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
 -- Indexes for performance
 CREATE INDEX IF NOT EXISTS idx_accounts_customer_id ON accounts(customer_id);
+CREATE INDEX IF NOT EXISTS idx_accounts_customer_listing ON accounts(customer_id, created_at, id); -- Keyset pagination for GET /accounts
 CREATE INDEX IF NOT EXISTS idx_ledger_entries_account_id ON ledger_entries(account_id);
+CREATE INDEX IF NOT EXISTS idx_ledger_entries_reference_id ON ledger_entries(reference_id);
+CREATE INDEX IF NOT EXISTS idx_ledger_entries_history ON ledger_entries(account_id, created_at DESC, id DESC); -- Keyset pagination for GET /accounts/{id}/ledger
 CREATE INDEX IF NOT EXISTS idx_customers_external_id ON customers(external_id);
+CREATE INDEX IF NOT EXISTS idx_account_status_changes_account_id ON account_status_changes(account_id, created_at);
+CREATE INDEX IF NOT EXISTS idx_fx_quotes_from_account_id ON fx_quotes(from_account_id);
+CREATE INDEX IF NOT EXISTS idx_journal_lines_journal_id ON journal_lines(journal_id);
+CREATE INDEX IF NOT EXISTS idx_journal_lines_ledger_entry_id ON journal_lines(ledger_entry_id);
+CREATE INDEX IF NOT EXISTS idx_journal_lines_trial_balance ON journal_lines(currency, gl_account, created_at);
+CREATE INDEX IF NOT EXISTS idx_reconciliation_reports_started_at ON reconciliation_reports(started_at DESC);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_ledger_entries_chain ON ledger_entries(account_id, seq);
+CREATE INDEX IF NOT EXISTS idx_ledger_entries_unsealed ON ledger_entries(account_id) WHERE entry_hash IS NULL;
+CREATE INDEX IF NOT EXISTS idx_ledger_checkpoints_created_at ON ledger_checkpoints(created_at DESC);
+CREATE INDEX IF NOT EXISTS idx_holds_account_id ON holds(account_id, created_at DESC);
+CREATE INDEX IF NOT EXISTS idx_holds_active ON holds(account_id) WHERE status = 'active'; -- Available balance
+CREATE INDEX IF NOT EXISTS idx_holds_expiry ON holds(expires_at) WHERE status = 'active'; -- Expired hold sweep
+CREATE INDEX IF NOT EXISTS idx_scheduled_transfers_from ON scheduled_transfers(from_account_id, execute_on);
+CREATE INDEX IF NOT EXISTS idx_scheduled_transfers_due ON scheduled_transfers(next_attempt_at) WHERE status = 'pending'; -- Scheduled transfer worker
+CREATE INDEX IF NOT EXISTS idx_scheduled_transfers_standing_order ON scheduled_transfers(standing_order_id, execute_on) WHERE standing_order_id IS NOT NULL;
+CREATE INDEX IF NOT EXISTS idx_ledger_entries_standing_order ON ledger_entries(standing_order_id) WHERE standing_order_id IS NOT NULL;
+CREATE INDEX IF NOT EXISTS idx_standing_orders_from ON standing_orders(from_account_id, created_at DESC);
+CREATE INDEX IF NOT EXISTS idx_standing_orders_due ON standing_orders(next_execute_on) WHERE status = 'active'; -- Standing order payments
+CREATE INDEX IF NOT EXISTS idx_overdraft_limit_changes_account_id ON overdraft_limit_changes(account_id, created_at DESC);
+CREATE INDEX IF NOT EXISTS idx_accounts_overdrawn ON accounts(id) WHERE balance < 0; -- Daily overdraft interest
+CREATE INDEX IF NOT EXISTS idx_accounts_savings ON accounts(id) WHERE kind = 'savings'; -- Daily savings interest
+
+-- Product catalogue
+INSERT INTO fee_schedules (product_code, operation, flat, percentage, min_fee, max_fee)
+VALUES
+('savings', 'withdrawal', 5, NULL, 0, 0),
+('foreign_currency', 'fx_exchange', 0, 0.005, 1, 0)
+ON CONFLICT (product_code, operation) DO NOTHING;
+
+-- Chart of accounts
+INSERT INTO gl_accounts (code, name, type)
+VALUES
+('cash', 'Cash', 'asset'),
+('customer_deposits', 'Customer deposits', 'liability'),
+('suspense', 'Suspense', 'asset'),
+('fx_position', 'FX position', 'asset'),
+('fee_income', 'Fee income', 'income'),
+('interest_income', 'Interest income', 'income'),
+('interest_expense', 'Interest expense', 'expense')
+ON CONFLICT (code) DO NOTHING;
+
+-- Post ledger entries booked before the general ledger existed, using the
+-- same rules as the application: the customer side plus cash for deposits
+-- and withdrawals, fx_position for exchanges, interest_income for overdraft
+-- interest, interest_expense for savings interest, fee_income for fees and
+-- suspense for single-sided transfers. Paired transfer entries balance each other.
+INSERT INTO journal_lines (journal_id, gl_account, account_id, ledger_entry_id, currency, debit, credit, created_at)
+SELECT journal_id, gl_account, account_id, ledger_entry_id, currency, debit, credit, created_at
+FROM (
+    SELECT COALESCE(le.reference_id, le.id) AS journal_id, 'customer_deposits' AS gl_account, le.account_id,
+           le.id AS ledger_entry_id, a.currency, GREATEST(-le.amount, 0) AS debit, GREATEST(le.amount, 0) AS credit, le.created_at
+    FROM ledger_entries le JOIN accounts a ON a.id = le.account_id
+    UNION ALL
+    SELECT COALESCE(le.reference_id, le.id), CASE
+               WHEN le.type IN ('deposit', 'withdrawal', 'hold_capture') THEN 'cash'
+               WHEN le.type = 'fx_exchange' THEN 'fx_position'
+               WHEN le.type = 'overdraft_interest' THEN 'interest_income'
+               WHEN le.type = 'interest' THEN 'interest_expense'
+               WHEN le.type = 'fee' THEN 'fee_income'
+               ELSE 'suspense'
+           END, NULL, le.id, a.currency, GREATEST(le.amount, 0), GREATEST(-le.amount, 0), le.created_at
+    FROM ledger_entries le JOIN accounts a ON a.id = le.account_id
+    WHERE le.type NOT IN ('transfer_in', 'transfer_out') OR le.reference_id IS NULL
+) lines
+WHERE (debit <> 0 OR credit <> 0)
+  AND NOT EXISTS (SELECT 1 FROM journal_lines jl WHERE jl.ledger_entry_id = lines.ledger_entry_id);
@@ -1,8 +1,13 @@
 package model
 
 import (
+	"encoding/base64"
+	"fmt"
+	"strings"
 	"time"
 
+	"go-web-server/services/account-service/money"
+
 	"github.com/google/uuid"
 )
 
@@ -14,6 +19,372 @@ const (
 	AccountClosed AccountStatus = "closed"
 )
 
+func (s AccountStatus) Valid() bool {
+	switch s {
+	case AccountActive, AccountFrozen, AccountClosed:
+		return true
+	}
+	return false
+}
+
+// AccountKind decides which daily jobs apply to an account: savings
+// accounts accrue interest, current accounts do not. An account takes the
+// kind of its product.
+type AccountKind string
+
+const (
+	AccountCurrent AccountKind = "current"
+	AccountSavings AccountKind = "savings"
+)
+
+func (k AccountKind) Valid() bool {
+	return k == AccountCurrent || k == AccountSavings
+}
+
+// Product is an entry of the account product catalogue. Every account
+// belongs to one, which decides the currencies it may be opened in and the
+// rules its balance changes follow. Amounts apply in the account's
+// currency, and a zero MaxWithdrawal or MaxBalance means no cap.
+type Product struct {
+	Code       string           `json:"code"`
+	Name       string           `json:"name"`
+	Kind       AccountKind      `json:"kind"`
+	Currencies []money.Currency `json:"currencies"`
+	// MaxOverdraft caps the overdraft limit an account may be given; zero
+	// allows none.
+	MaxOverdraft money.Amount `json:"maxOverdraft"`
+	// InterestRate is the annual rate savings accounts of the product
+	// accrue; without one they accrue at the configured default rate.
+	InterestRate *money.Rate `json:"interestRate,omitempty"`
+	// MaxWithdrawal caps a single debit: a withdrawal, outgoing transfer,
+	// hold or exchange.
+	MaxWithdrawal money.Amount `json:"maxWithdrawal"`
+	// MaxBalance caps the ledger balance after a credit.
+	MaxBalance money.Amount `json:"maxBalance"`
+	// DailyLimit and MonthlyLimit cap the withdrawals and outgoing
+	// transfers of a calendar day and month, and MaxDailyTransactions their
+	// number in a day. Zero means no limit; accounts may override them.
+	DailyLimit           money.Amount `json:"dailyLimit"`
+	MonthlyLimit         money.Amount `json:"monthlyLimit"`
+	MaxDailyTransactions int          `json:"maxDailyTransactions"`
+	// Active products can be opened; accounts of retired products keep
+	// working under its rules.
+	Active    bool      `json:"active"`
+	CreatedAt time.Time `json:"createdAt"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+// Offers reports whether accounts of the product may be held in c.
+func (p *Product) Offers(c money.Currency) bool {
+	for _, offered := range p.Currencies {
+		if offered == c {
+			return true
+		}
+	}
+	return false
+}
+
+// TransactionLimits caps the withdrawals and outgoing transfers of an
+// account. Zero means no limit.
+type TransactionLimits struct {
+	PerTransaction       money.Amount `json:"perTransaction"`
+	Daily                money.Amount `json:"daily"`
+	Monthly              money.Amount `json:"monthly"`
+	MaxDailyTransactions int          `json:"maxDailyTransactions"`
+}
+
+// LimitOverride replaces limits of an account's product for that account.
+// A nil field keeps the product's limit.
+type LimitOverride struct {
+	AccountID            uuid.UUID     `json:"accountId"`
+	PerTransaction       *money.Amount `json:"perTransaction"`
+	Daily                *money.Amount `json:"daily"`
+	Monthly              *money.Amount `json:"monthly"`
+	MaxDailyTransactions *int          `json:"maxDailyTransactions"`
+	UpdatedBy            string        `json:"updatedBy"`
+	UpdatedAt            time.Time     `json:"updatedAt"`
+}
+
+// IsEmpty reports whether o keeps every limit of the product.
+func (o *LimitOverride) IsEmpty() bool {
+	return o.PerTransaction == nil && o.Daily == nil && o.Monthly == nil && o.MaxDailyTransactions == nil
+}
+
+// LimitsOf returns the limits of an account of product p with override o,
+// which may be nil. The product's per-transaction limit is MaxWithdrawal.
+func LimitsOf(p *Product, o *LimitOverride) TransactionLimits {
+	l := TransactionLimits{
+		PerTransaction:       p.MaxWithdrawal,
+		Daily:                p.DailyLimit,
+		Monthly:              p.MonthlyLimit,
+		MaxDailyTransactions: p.MaxDailyTransactions,
+	}
+	if o == nil {
+		return l
+	}
+	if o.PerTransaction != nil {
+		l.PerTransaction = *o.PerTransaction
+	}
+	if o.Daily != nil {
+		l.Daily = *o.Daily
+	}
+	if o.Monthly != nil {
+		l.Monthly = *o.Monthly
+	}
+	if o.MaxDailyTransactions != nil {
+		l.MaxDailyTransactions = *o.MaxDailyTransactions
+	}
+	return l
+}
+
+// CustomerLimits caps the withdrawals and outgoing transfers of all of a
+// customer's accounts in one currency together, on top of each account's
+// own limits. Zero means no limit.
+type CustomerLimits struct {
+	CustomerID           uuid.UUID      `json:"customerId"`
+	Currency             money.Currency `json:"currency"`
+	Daily                money.Amount   `json:"daily"`
+	Monthly              money.Amount   `json:"monthly"`
+	MaxDailyTransactions int            `json:"maxDailyTransactions"`
+	UpdatedBy            string         `json:"updatedBy"`
+	UpdatedAt            time.Time      `json:"updatedAt"`
+}
+
+// IsEmpty reports whether l sets no limit.
+func (l *CustomerLimits) IsEmpty() bool {
+	return l.Daily.IsZero() && l.Monthly.IsZero() && l.MaxDailyTransactions == 0
+}
+
+// Limits returns l as transaction limits without a per-transaction limit.
+func (l *CustomerLimits) Limits() TransactionLimits {
+	return TransactionLimits{Daily: l.Daily, Monthly: l.Monthly, MaxDailyTransactions: l.MaxDailyTransactions}
+}
+
+// LimitUsage is how much of its limits an account has used in the current
+// day and month.
+type LimitUsage struct {
+	Daily             money.Amount `json:"daily"`
+	Monthly           money.Amount `json:"monthly"`
+	DailyTransactions int          `json:"dailyTransactions"`
+}
+
+// LimitHeadroom is what an account's limits still allow today. A nil field
+// has no limit.
+type LimitHeadroom struct {
+	Daily             *money.Amount `json:"daily"`
+	Monthly           *money.Amount `json:"monthly"`
+	DailyTransactions *int          `json:"dailyTransactions"`
+	// NextTransaction is the largest withdrawal or outgoing transfer the
+	// limits allow now, before fees and available funds are considered.
+	NextTransaction *money.Amount `json:"nextTransaction"`
+}
+
+// Headroom returns what l still allows after usage u. Limits already
+// exceeded, for example after an override was lowered, leave zero.
+func (l TransactionLimits) Headroom(u LimitUsage) (LimitHeadroom, error) {
+	var h LimitHeadroom
+	var err error
+	remaining := func(limit, used money.Amount) *money.Amount {
+		if limit.IsZero() {
+			return nil
+		}
+		r := money.Amount{}
+		if used.Cmp(limit) < 0 {
+			var subErr error
+			if r, subErr = limit.Sub(used); subErr != nil {
+				err = subErr
+			}
+		}
+		return &r
+	}
+	h.Daily = remaining(l.Daily, u.Daily)
+	h.Monthly = remaining(l.Monthly, u.Monthly)
+	if err != nil {
+		return LimitHeadroom{}, err
+	}
+	if l.MaxDailyTransactions > 0 {
+		n := l.MaxDailyTransactions - u.DailyTransactions
+		if n < 0 {
+			n = 0
+		}
+		h.DailyTransactions = &n
+	}
+
+	for _, limit := range []*money.Amount{remaining(l.PerTransaction, money.Amount{}), h.Daily, h.Monthly} {
+		if limit != nil && (h.NextTransaction == nil || limit.Cmp(*h.NextTransaction) < 0) {
+			h.NextTransaction = limit
+		}
+	}
+	if h.DailyTransactions != nil && *h.DailyTransactions == 0 {
+		h.NextTransaction = &money.Amount{}
+	}
+	return h, nil
+}
+
+// AccountLimits shows an account's limits and what they still allow, so that
+// a client can check a withdrawal or transfer before making it.
+type AccountLimits struct {
+	AccountID uuid.UUID         `json:"accountId"`
+	Currency  money.Currency    `json:"currency"`
+	Limits    TransactionLimits `json:"limits"`
+	// Overridden reports whether the account replaces any of its
+	// product's limits.
+	Overridden bool          `json:"overridden"`
+	Used       LimitUsage    `json:"used"`
+	Headroom   LimitHeadroom `json:"headroom"`
+	// Customer is set when the account's customer has limits in its
+	// currency. Headroom.NextTransaction then allows for them as well.
+	Customer *CustomerLimitStatus `json:"customer,omitempty"`
+}
+
+// CustomerLimitStatus shows a customer's limits in one currency and what
+// the debits from all their accounts still leave of them.
+type CustomerLimitStatus struct {
+	Limits   TransactionLimits `json:"limits"`
+	Used     LimitUsage        `json:"used"`
+	Headroom LimitHeadroom     `json:"headroom"`
+}
+
+// FeeOperation is an operation a product may charge a fee for.
+type FeeOperation string
+
+const (
+	FeeWithdrawal FeeOperation = "withdrawal"
+	FeeTransfer   FeeOperation = "transfer"
+	// FeeFXExchange is the markup on currency exchanges, charged on the
+	// amount sold.
+	FeeFXExchange FeeOperation = "fx_exchange"
+	// FeeMaintenance is charged once a month regardless of activity.
+	FeeMaintenance FeeOperation = "maintenance"
+)
+
+func (o FeeOperation) Valid() bool {
+	switch o {
+	case FeeWithdrawal, FeeTransfer, FeeFXExchange, FeeMaintenance:
+		return true
+	}
+	return false
+}
+
+// FeeSchedule prices one operation of a product, in the currency of each
+// account: Flat plus Percentage of the amount, raised to MinFee and, unless
+// it is zero, capped at MaxFee.
+type FeeSchedule struct {
+	ProductCode string       `json:"productCode"`
+	Operation   FeeOperation `json:"operation"`
+	Flat        money.Amount `json:"flat"`
+	// Percentage is a fraction of the amount, e.g. 0.005 for 0.5%.
+	Percentage *money.Rate  `json:"percentage,omitempty"`
+	MinFee     money.Amount `json:"minFee"`
+	MaxFee     money.Amount `json:"maxFee"`
+	UpdatedAt  time.Time    `json:"updatedAt"`
+}
+
+// Fee prices an operation on amount. The percentage is rounded half up to
+// the currency's minor unit.
+func (s *FeeSchedule) Fee(amount money.Money) (money.Money, error) {
+	fee := s.Flat
+	if s.Percentage != nil {
+		var err error
+		if fee, err = fee.Add(s.Percentage.Share(amount.Amount.Abs(), amount.Currency)); err != nil {
+			return money.Money{}, err
+		}
+	}
+	if fee.Cmp(s.MinFee) < 0 {
+		fee = s.MinFee
+	}
+	if !s.MaxFee.IsZero() && fee.Cmp(s.MaxFee) > 0 {
+		fee = s.MaxFee
+	}
+	return money.New(fee, amount.Currency), nil
+}
+
+// FeePreview is what an operation would cost an account: Total is Amount
+// plus Fee, the sum that would leave the account.
+type FeePreview struct {
+	AccountID uuid.UUID      `json:"accountId"`
+	Operation FeeOperation   `json:"operation"`
+	Amount    money.Amount   `json:"amount"`
+	Fee       money.Amount   `json:"fee"`
+	Total     money.Amount   `json:"total"`
+	Currency  money.Currency `json:"currency"`
+}
+
+// MaintenanceFeeRun summarises charging a month's maintenance fees. Accounts
+// already charged for the month are skipped.
+type MaintenanceFeeRun struct {
+	Month   string        `json:"month"`
+	Charged []LedgerEntry `json:"charged"`
+	Skipped int           `json:"skipped"`
+}
+
+// accountTransitions lists the statuses each status may move to. Closed is
+// final.
+var accountTransitions = map[AccountStatus][]AccountStatus{
+	AccountActive: {AccountFrozen, AccountClosed},
+	AccountFrozen: {AccountActive, AccountClosed},
+	AccountClosed: {},
+}
+
+// CanTransitionTo reports whether an account in status s may move to next.
+func (s AccountStatus) CanTransitionTo(next AccountStatus) bool {
+	for _, allowed := range accountTransitions[s] {
+		if allowed == next {
+			return true
+		}
+	}
+	return false
+}
+
+// StatusReason is the reason code recorded with every account status change.
+type StatusReason string
+
+const (
+	ReasonCustomerRequest  StatusReason = "customer_request"
+	ReasonSuspectedFraud   StatusReason = "suspected_fraud"
+	ReasonComplianceReview StatusReason = "compliance_review"
+	ReasonCourtOrder       StatusReason = "court_order"
+	ReasonReviewCleared    StatusReason = "review_cleared"
+	ReasonDormant          StatusReason = "dormant"
+	ReasonDeceased         StatusReason = "deceased"
+	// ReasonLedgerDiscrepancy is recorded when reconciliation freezes an
+	// account whose balance disagrees with its ledger.
+	ReasonLedgerDiscrepancy StatusReason = "ledger_discrepancy"
+)
+
+func (r StatusReason) Valid() bool {
+	switch r {
+	case ReasonCustomerRequest, ReasonSuspectedFraud, ReasonComplianceReview, ReasonCourtOrder,
+		ReasonReviewCleared, ReasonDormant, ReasonDeceased, ReasonLedgerDiscrepancy:
+		return true
+	}
+	return false
+}
+
+// StatusChange requests an account lifecycle transition.
+type StatusChange struct {
+	AccountID uuid.UUID
+	To        AccountStatus
+	Reason    StatusReason
+	Note      string
+	// PayoutAccountID receives the remaining balance when the account is
+	// closed. It is required to close an account whose balance is not zero.
+	PayoutAccountID *uuid.UUID
+}
+
+// AccountStatusChange is one recorded lifecycle transition. PayoutReferenceID
+// links the payout transfer booked when a funded account was closed.
+type AccountStatusChange struct {
+	ID                uuid.UUID     `json:"id"`
+	AccountID         uuid.UUID     `json:"accountId"`
+	FromStatus        AccountStatus `json:"fromStatus"`
+	ToStatus          AccountStatus `json:"toStatus"`
+	Reason            StatusReason  `json:"reason"`
+	Note              string        `json:"note,omitempty"`
+	PayoutReferenceID *uuid.UUID    `json:"payoutReferenceId,omitempty"`
+	CreatedAt         time.Time     `json:"createdAt"`
+}
+
 type Customer struct {
 	ID         uuid.UUID `json:"id"`
 	ExternalID string    `json:"externalId"`
@@ -22,14 +393,66 @@ type Customer struct {
 }
 
 type Account struct {
-	ID            uuid.UUID     `json:"id"`
-	CustomerID    uuid.UUID     `json:"customerId"`
-	AccountNumber string        `json:"accountNumber"`
-	Currency      string        `json:"currency"`
-	Balance       float64       `json:"balance"` // Using float64 for simplicity in example, but decimal is better for prod
-	Status        AccountStatus `json:"status"`
-	CreatedAt     time.Time     `json:"createdAt"`
-	UpdatedAt     time.Time     `json:"updatedAt"`
+	ID            uuid.UUID      `json:"id"`
+	CustomerID    uuid.UUID      `json:"customerId"`
+	AccountNumber string         `json:"accountNumber"`
+	Currency      money.Currency `json:"currency"`
+	ProductCode   string         `json:"productCode"`
+	Kind          AccountKind    `json:"kind"`
+	// Balance is the ledger balance, the sum of the posted ledger entries.
+	// LedgerBalance repeats it next to AvailableBalance, which is Balance
+	// less HeldAmount, the funds reserved by active holds. Debits may take
+	// the available balance down to minus OverdraftLimit. AccruedInterest
+	// is savings interest earned but not yet capitalised into Balance.
+	Balance          money.Amount  `json:"balance"`
+	LedgerBalance    money.Amount  `json:"ledgerBalance"`
+	HeldAmount       money.Amount  `json:"heldAmount"`
+	AvailableBalance money.Amount  `json:"availableBalance"`
+	OverdraftLimit   money.Amount  `json:"overdraftLimit"`
+	AccruedInterest  money.Amount  `json:"accruedInterest"`
+	Status           AccountStatus `json:"status"`
+	CreatedAt        time.Time     `json:"createdAt"`
+	UpdatedAt        time.Time     `json:"updatedAt"`
+}
+
+// BalanceMoney returns the balance tagged with the account currency.
+func (a *Account) BalanceMoney() money.Money {
+	return money.New(a.Balance, a.Currency)
+}
+
+// AvailableMoney returns the balance less the held amount, tagged with the
+// account currency.
+func (a *Account) AvailableMoney() (money.Money, error) {
+	return a.BalanceMoney().Sub(money.New(a.HeldAmount, a.Currency))
+}
+
+// Headroom returns the largest debit the account allows: the available
+// balance plus the overdraft limit.
+func (a *Account) Headroom() (money.Money, error) {
+	available, err := a.AvailableMoney()
+	if err != nil {
+		return money.Money{}, err
+	}
+	return available.Add(a.OverdraftMoney())
+}
+
+// OverdraftMoney returns the overdraft limit tagged with the account
+// currency.
+func (a *Account) OverdraftMoney() money.Money {
+	return money.New(a.OverdraftLimit, a.Currency)
+}
+
+// SetHeld records the amount reserved by holds and derives LedgerBalance and
+// AvailableBalance from Balance.
+func (a *Account) SetHeld(held money.Amount) error {
+	available, err := a.Balance.Sub(held)
+	if err != nil {
+		return err
+	}
+	a.HeldAmount = held
+	a.LedgerBalance = a.Balance
+	a.AvailableBalance = available
+	return nil
 }
 
 type LedgerEntryType string
@@ -40,15 +463,706 @@ const (
 	TransferIn  LedgerEntryType = "transfer_in"
 	TransferOut LedgerEntryType = "transfer_out"
 	FXExchange  LedgerEntryType = "fx_exchange"
+	// HoldCapture settles a hold; its ReferenceID is the hold's ID.
+	HoldCapture LedgerEntryType = "hold_capture"
+	// OverdraftInterest charges a day's interest on a negative balance.
+	OverdraftInterest LedgerEntryType = "overdraft_interest"
+	// Interest capitalises a month of savings interest.
+	Interest LedgerEntryType = "interest"
+	// Fee charges a fee of the account's product. Its ReferenceID is the
+	// reference of the operation charged: the transfer or exchange
+	// reference, or the ID of a withdrawal entry. Maintenance fees have
+	// none.
+	Fee LedgerEntryType = "fee"
 )
 
+func (t LedgerEntryType) Valid() bool {
+	switch t {
+	case Deposit, Withdrawal, TransferIn, TransferOut, FXExchange, HoldCapture, OverdraftInterest, Interest, Fee:
+		return true
+	}
+	return false
+}
+
 type LedgerEntry struct {
 	ID           uuid.UUID       `json:"id"`
 	AccountID    uuid.UUID       `json:"accountId"`
 	Type         LedgerEntryType `json:"type"`
-	Amount       float64         `json:"amount"`
-	BalanceAfter float64         `json:"balanceAfter"`
+	Amount       money.Amount    `json:"amount"`
+	BalanceAfter money.Amount    `json:"balanceAfter"`
 	ReferenceID  *uuid.UUID      `json:"referenceId,omitempty"`
 	Description  string          `json:"description"`
-	CreatedAt    time.Time       `json:"createdAt"`
+	// FXRate is the exchange rate applied by an fx_exchange entry: units of
+	// the credited currency per unit of the debited one.
+	FXRate *money.Rate `json:"fxRate,omitempty"`
+	// StandingOrderID is set on the entries of a transfer that made a
+	// payment of a standing order, and on its fee.
+	StandingOrderID *uuid.UUID `json:"standingOrderId,omitempty"`
+	CreatedAt       time.Time  `json:"createdAt"`
+	// ValueDate is the business day, as 2006-01-02, from which the entry
+	// counts: the day it was created if that was a business day before the
+	// cut-off, otherwise the next business day. Interest takes value on the
+	// day it is for.
+	ValueDate string `json:"valueDate"`
+	// Sequence numbers the account's entries from 1. Hash covers the entry
+	// and PrevHash, the Hash of the entry before it, so that editing or
+	// removing any entry breaks the chain. The first entry has no PrevHash.
+	Sequence int64  `json:"sequence"`
+	PrevHash string `json:"prevHash,omitempty"`
+	Hash     string `json:"hash"`
+}
+
+// Transfer links the debit and credit ledger entries written by one
+// account-to-account transfer. Both entries share ReferenceID.
+type Transfer struct {
+	ReferenceID   uuid.UUID      `json:"referenceId"`
+	FromAccountID uuid.UUID      `json:"fromAccountId"`
+	ToAccountID   uuid.UUID      `json:"toAccountId"`
+	Amount        money.Amount   `json:"amount"`
+	Currency      money.Currency `json:"currency"`
+	Description   string         `json:"description"`
+	Debit         LedgerEntry    `json:"debit"`
+	Credit        LedgerEntry    `json:"credit"`
+	// Fee is the transfer fee charged to the source account, if any.
+	Fee       *LedgerEntry `json:"fee,omitempty"`
+	CreatedAt time.Time    `json:"createdAt"`
+}
+
+// HoldStatus is the state of a hold. Only active holds reserve funds; the
+// other states are final.
+type HoldStatus string
+
+const (
+	HoldActive   HoldStatus = "active"
+	HoldCaptured HoldStatus = "captured"
+	HoldReleased HoldStatus = "released"
+	HoldExpired  HoldStatus = "expired"
+)
+
+func (s HoldStatus) Valid() bool {
+	switch s {
+	case HoldActive, HoldCaptured, HoldReleased, HoldExpired:
+		return true
+	}
+	return false
+}
+
+// Hold reserves Amount of an account's funds until it is captured, released
+// or expires. Captured is the part already settled by hold_capture ledger
+// entries; while the hold is active the rest stays reserved. An active hold
+// past ExpiresAt no longer reserves anything and is marked expired by the
+// sweeper.
+type Hold struct {
+	ID          uuid.UUID      `json:"id"`
+	AccountID   uuid.UUID      `json:"accountId"`
+	Amount      money.Amount   `json:"amount"`
+	Captured    money.Amount   `json:"captured"`
+	Currency    money.Currency `json:"currency"`
+	Status      HoldStatus     `json:"status"`
+	Description string         `json:"description"`
+	ExpiresAt   time.Time      `json:"expiresAt"`
+	CreatedAt   time.Time      `json:"createdAt"`
+	UpdatedAt   time.Time      `json:"updatedAt"`
+}
+
+// Remaining returns the part of the hold not captured yet.
+func (h *Hold) Remaining() (money.Amount, error) {
+	return h.Amount.Sub(h.Captured)
+}
+
+// HoldSettlement is the result of capturing a hold: the hold after the
+// capture, the hold_capture ledger entry that settled it and the fee it
+// was charged.
+type HoldSettlement struct {
+	Hold  Hold        `json:"hold"`
+	Entry LedgerEntry `json:"entry"`
+	// Fee is the withdrawal fee charged on the capture, if any.
+	Fee *LedgerEntry `json:"fee,omitempty"`
+}
+
+// ScheduledTransferStatus is the state of a scheduled transfer. Only pending
+// transfers are executed, amended or cancelled; the other states are final.
+type ScheduledTransferStatus string
+
+const (
+	ScheduledPending   ScheduledTransferStatus = "pending"
+	ScheduledExecuted  ScheduledTransferStatus = "executed"
+	ScheduledFailed    ScheduledTransferStatus = "failed"
+	ScheduledCancelled ScheduledTransferStatus = "cancelled"
+)
+
+func (s ScheduledTransferStatus) Valid() bool {
+	switch s {
+	case ScheduledPending, ScheduledExecuted, ScheduledFailed, ScheduledCancelled:
+		return true
+	}
+	return false
+}
+
+// ScheduledTransfer is a transfer set for a future ExecuteOn date, a day
+// such as "2024-03-01". The worker executes it once NextAttemptAt has
+// passed; after a failure that may clear up, such as insufficient funds, it
+// tries again later until Attempts reaches the limit and the transfer
+// fails. LastError is the reason of the last failed attempt and
+// TransferReferenceID the reference of the executed transfer's entries.
+type ScheduledTransfer struct {
+	ID                  uuid.UUID               `json:"id"`
+	FromAccountID       uuid.UUID               `json:"fromAccountId"`
+	ToAccountID         uuid.UUID               `json:"toAccountId"`
+	Amount              money.Amount            `json:"amount"`
+	Currency            money.Currency          `json:"currency"`
+	Description         string                  `json:"description"`
+	ExecuteOn           string                  `json:"executeOn"`
+	Status              ScheduledTransferStatus `json:"status"`
+	Attempts            int                     `json:"attempts"`
+	NextAttemptAt       time.Time               `json:"nextAttemptAt"`
+	LastError           string                  `json:"lastError,omitempty"`
+	TransferReferenceID *uuid.UUID              `json:"transferReferenceId,omitempty"`
+	// StandingOrderID is set on the payments of a standing order.
+	StandingOrderID *uuid.UUID `json:"standingOrderId,omitempty"`
+	CreatedAt       time.Time  `json:"createdAt"`
+	UpdatedAt       time.Time  `json:"updatedAt"`
+}
+
+// ScheduledTransferAmendment changes a pending scheduled transfer. Nil
+// fields keep their value.
+type ScheduledTransferAmendment struct {
+	Amount      *money.Amount `json:"amount"`
+	Description *string       `json:"description"`
+	ExecuteOn   *string       `json:"executeOn"`
+}
+
+// ScheduledTransferRun is the result of one pass of the scheduled transfer
+// worker.
+type ScheduledTransferRun struct {
+	Executed []ScheduledTransfer `json:"executed"`
+	Retrying []ScheduledTransfer `json:"retrying"`
+	Failed   []ScheduledTransfer `json:"failed"`
+}
+
+// StandingOrderFrequency is how often a standing order pays.
+type StandingOrderFrequency string
+
+const (
+	// FrequencyWeekly pays every week on the weekday of the start date.
+	FrequencyWeekly StandingOrderFrequency = "weekly"
+	// FrequencyMonthly pays every month on DayOfMonth, or on the month's
+	// last day when it is shorter.
+	FrequencyMonthly StandingOrderFrequency = "monthly"
+	// FrequencyLastBusinessDay pays on the last business day of every
+	// month.
+	FrequencyLastBusinessDay StandingOrderFrequency = "last_business_day"
+)
+
+func (f StandingOrderFrequency) Valid() bool {
+	switch f {
+	case FrequencyWeekly, FrequencyMonthly, FrequencyLastBusinessDay:
+		return true
+	}
+	return false
+}
+
+// BusinessDayShift says when a payment due on a weekend or holiday is made.
+type BusinessDayShift string
+
+const (
+	ShiftPrevious BusinessDayShift = "previous"
+	ShiftNext     BusinessDayShift = "next"
+)
+
+func (s BusinessDayShift) Valid() bool {
+	return s == ShiftPrevious || s == ShiftNext
+}
+
+// StandingOrderStatus is the state of a standing order. Only active orders
+// schedule payments; the other states are final.
+type StandingOrderStatus string
+
+const (
+	StandingOrderActive    StandingOrderStatus = "active"
+	StandingOrderCompleted StandingOrderStatus = "completed"
+	StandingOrderCancelled StandingOrderStatus = "cancelled"
+)
+
+// StandingOrderSchedule says when a standing order pays: at Frequency from
+// StartOn, until the last payment due on or before EndOn or until
+// MaxOccurrences payments, whichever comes first. Either end is optional.
+// Payments due on a non-business day are made on the business day Shift
+// says; payments on the last business day need no shift.
+type StandingOrderSchedule struct {
+	Frequency      StandingOrderFrequency `json:"frequency"`
+	DayOfMonth     int                    `json:"dayOfMonth,omitempty"`
+	Shift          BusinessDayShift       `json:"shift,omitempty"`
+	StartOn        string                 `json:"startOn"`
+	EndOn          string                 `json:"endOn,omitempty"`
+	MaxOccurrences int                    `json:"maxOccurrences,omitempty"`
+}
+
+// StandingOrder is a recurring transfer. Each payment is scheduled as a
+// ScheduledTransfer with the order's StandingOrderID on its execution day,
+// and executed, retried or failed like any other; the ledger entries of an
+// executed payment share its TransferReferenceID. NextDueOn is the day the
+// next payment falls due by the schedule and NextExecuteOn the business day
+// it is made; both are empty once the order is no longer active.
+// Occurrences counts the payments scheduled so far.
+type StandingOrder struct {
+	ID            uuid.UUID      `json:"id"`
+	FromAccountID uuid.UUID      `json:"fromAccountId"`
+	ToAccountID   uuid.UUID      `json:"toAccountId"`
+	Amount        money.Amount   `json:"amount"`
+	Currency      money.Currency `json:"currency"`
+	Description   string         `json:"description"`
+	StandingOrderSchedule
+	Status        StandingOrderStatus `json:"status"`
+	Occurrences   int                 `json:"occurrences"`
+	NextDueOn     string              `json:"nextDueOn,omitempty"`
+	NextExecuteOn string              `json:"nextExecuteOn,omitempty"`
+	CreatedAt     time.Time           `json:"createdAt"`
+	UpdatedAt     time.Time           `json:"updatedAt"`
+}
+
+// StandingOrderRun is the result of one pass scheduling the payments of
+// standing orders that fell due.
+type StandingOrderRun struct {
+	Scheduled []ScheduledTransfer `json:"scheduled"`
+	Completed []StandingOrder     `json:"completed"`
+}
+
+// Calendar describes the business-day calendar of a country. Cutoff is the
+// local time, as 15:04, from which operations take value on the next
+// business day; it is empty when the whole day counts.
+type Calendar struct {
+	Country  string `json:"country"`
+	Timezone string `json:"timezone"`
+	Cutoff   string `json:"cutoff,omitempty"`
+}
+
+// BusinessDay describes one day of a country's calendar, with the nearest
+// business days before and after it.
+type BusinessDay struct {
+	Country             string `json:"country"`
+	Date                string `json:"date"`
+	BusinessDay         bool   `json:"businessDay"`
+	Holiday             string `json:"holiday,omitempty"`
+	PreviousBusinessDay string `json:"previousBusinessDay"`
+	NextBusinessDay     string `json:"nextBusinessDay"`
+}
+
+// CutoffCheck tells whether an operation booked At makes the country's
+// cut-off, and the value date it takes.
+type CutoffCheck struct {
+	Country      string    `json:"country"`
+	At           time.Time `json:"at"`
+	Cutoff       string    `json:"cutoff,omitempty"`
+	BeforeCutoff bool      `json:"beforeCutoff"`
+	ValueDate    string    `json:"valueDate"`
+}
+
+// OverdraftLimitChange records who set an account's overdraft limit, from
+// what and why.
+type OverdraftLimitChange struct {
+	ID        uuid.UUID      `json:"id"`
+	AccountID uuid.UUID      `json:"accountId"`
+	OldLimit  money.Amount   `json:"oldLimit"`
+	NewLimit  money.Amount   `json:"newLimit"`
+	Currency  money.Currency `json:"currency"`
+	ChangedBy string         `json:"changedBy"`
+	Note      string         `json:"note,omitempty"`
+	CreatedAt time.Time      `json:"createdAt"`
+}
+
+// OverdraftInterestRun summarises charging a business day's interest on
+// negative balances. Accounts already charged for the day are skipped.
+type OverdraftInterestRun struct {
+	BusinessDate string        `json:"businessDate"`
+	Rate         money.Rate    `json:"rate"`
+	Charged      []LedgerEntry `json:"charged"`
+	Skipped      int           `json:"skipped"`
+}
+
+// InterestAccrual is one business day of savings interest on an account.
+// Balance is the end-of-day balance the interest was computed on and
+// Accrued the interest accumulated since the last capitalisation, after
+// this day. Capitalised is the interest ledger entry written when the day
+// closed a month.
+type InterestAccrual struct {
+	AccountID    uuid.UUID      `json:"accountId"`
+	BusinessDate string         `json:"businessDate"`
+	Balance      money.Amount   `json:"balance"`
+	Currency     money.Currency `json:"currency"`
+	Days         int            `json:"days"`
+	Amount       money.Amount   `json:"amount"`
+	Accrued      money.Amount   `json:"accrued"`
+	Capitalised  *LedgerEntry   `json:"capitalised,omitempty"`
+}
+
+// InterestAccrualRun summarises accruing a business day's interest on the
+// savings accounts. Accounts already accrued for the day are skipped.
+type InterestAccrualRun struct {
+	BusinessDate string            `json:"businessDate"`
+	Rate         money.Rate        `json:"rate"`
+	DayCount     money.DayCount    `json:"dayCount"`
+	Accrued      []InterestAccrual `json:"accrued"`
+	Skipped      int               `json:"skipped"`
+}
+
+// FXRate quotes one currency pair: the price of one unit of Base in Quote.
+// The bank buys Base at Bid and sells it at Ask, so Ask >= Bid.
+type FXRate struct {
+	Base  money.Currency `json:"base"`
+	Quote money.Currency `json:"quote"`
+	Bid   money.Rate     `json:"bid"`
+	Ask   money.Rate     `json:"ask"`
+	// Source is "admin" for rates set through the API and "file" for rates
+	// loaded from the rates file.
+	Source    string    `json:"source,omitempty"`
+	UpdatedBy string    `json:"updatedBy,omitempty"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+// FXQuote is a priced, time-limited offer to exchange SellAmount from one
+// account for BuyAmount on another account of the same customer. A quote
+// is executed at most once and only before ExpiresAt.
+type FXQuote struct {
+	ID            uuid.UUID      `json:"id"`
+	FromAccountID uuid.UUID      `json:"fromAccountId"`
+	ToAccountID   uuid.UUID      `json:"toAccountId"`
+	SellAmount    money.Amount   `json:"sellAmount"`
+	SellCurrency  money.Currency `json:"sellCurrency"`
+	BuyAmount     money.Amount   `json:"buyAmount"`
+	BuyCurrency   money.Currency `json:"buyCurrency"`
+	// Rate converts SellCurrency to BuyCurrency, spread included.
+	Rate        money.Rate `json:"rate"`
+	ExpiresAt   time.Time  `json:"expiresAt"`
+	ExecutedAt  *time.Time `json:"executedAt,omitempty"`
+	ReferenceID *uuid.UUID `json:"referenceId,omitempty"`
+	CreatedAt   time.Time  `json:"createdAt"`
+}
+
+// CurrencyExchange links the two fx_exchange ledger entries written when a
+// quote is executed. Both entries share ReferenceID and record Rate.
+type CurrencyExchange struct {
+	ReferenceID uuid.UUID   `json:"referenceId"`
+	QuoteID     uuid.UUID   `json:"quoteId"`
+	Rate        money.Rate  `json:"rate"`
+	Debit       LedgerEntry `json:"debit"`
+	Credit      LedgerEntry `json:"credit"`
+	// Fee is the FX markup charged to the source account, if any.
+	Fee       *LedgerEntry `json:"fee,omitempty"`
+	CreatedAt time.Time    `json:"createdAt"`
+}
+
+// GLAccountCode identifies an internal general-ledger account.
+type GLAccountCode string
+
+// Chart of accounts. Customer accounts are aggregated in CustomerDeposits;
+// the other accounts are the bank's own.
+const (
+	GLCash             GLAccountCode = "cash"
+	GLCustomerDeposits GLAccountCode = "customer_deposits"
+	GLSuspense         GLAccountCode = "suspense"
+	GLFXPosition       GLAccountCode = "fx_position"
+	GLFeeIncome        GLAccountCode = "fee_income"
+	GLInterestIncome   GLAccountCode = "interest_income"
+	GLInterestExpense  GLAccountCode = "interest_expense"
+)
+
+type GLAccountType string
+
+const (
+	GLAsset     GLAccountType = "asset"
+	GLLiability GLAccountType = "liability"
+	GLIncome    GLAccountType = "income"
+	GLExpense   GLAccountType = "expense"
+)
+
+// DebitNormal reports whether balances of this type grow with debits.
+func (t GLAccountType) DebitNormal() bool {
+	return t == GLAsset || t == GLExpense
+}
+
+type GLAccount struct {
+	Code GLAccountCode `json:"code"`
+	Name string        `json:"name"`
+	Type GLAccountType `json:"type"`
+}
+
+// JournalLine is one side of a balanced general-ledger posting. Exactly one
+// of Debit and Credit is non-zero. Lines of one posting share JournalID:
+// the reference_id of the ledger entries it covers, or the id of a single
+// entry without one.
+type JournalLine struct {
+	ID            uuid.UUID      `json:"id"`
+	JournalID     uuid.UUID      `json:"journalId"`
+	GLAccount     GLAccountCode  `json:"glAccount"`
+	AccountID     *uuid.UUID     `json:"accountId,omitempty"`
+	LedgerEntryID *uuid.UUID     `json:"ledgerEntryId,omitempty"`
+	Currency      money.Currency `json:"currency"`
+	Debit         money.Amount   `json:"debit"`
+	Credit        money.Amount   `json:"credit"`
+	CreatedAt     time.Time      `json:"createdAt"`
+}
+
+// TrialBalanceLine totals the journal lines of one GL account in one
+// currency. Balance is signed by the account's normal side.
+type TrialBalanceLine struct {
+	GLAccount GLAccountCode  `json:"glAccount"`
+	Name      string         `json:"name"`
+	Type      GLAccountType  `json:"type"`
+	Currency  money.Currency `json:"currency"`
+	Debit     money.Amount   `json:"debit"`
+	Credit    money.Amount   `json:"credit"`
+	Balance   money.Amount   `json:"balance"`
+}
+
+// CurrencyTrialBalance proves that total debits equal total credits in one
+// currency.
+type CurrencyTrialBalance struct {
+	Currency    money.Currency     `json:"currency"`
+	Accounts    []TrialBalanceLine `json:"accounts"`
+	TotalDebit  money.Amount       `json:"totalDebit"`
+	TotalCredit money.Amount       `json:"totalCredit"`
+	Balanced    bool               `json:"balanced"`
+}
+
+type TrialBalance struct {
+	// AsOf is the exclusive upper bound of the lines included; nil means
+	// all lines.
+	AsOf       *time.Time             `json:"asOf,omitempty"`
+	Currencies []CurrencyTrialBalance `json:"currencies"`
+	Balanced   bool                   `json:"balanced"`
+}
+
+// AccountLedgerTotals is an account's stored balance next to the figures
+// recomputed from its ledger entries and journal lines.
+type AccountLedgerTotals struct {
+	AccountID uuid.UUID
+	Currency  money.Currency
+	Status    AccountStatus
+	Balance   money.Amount
+	// LedgerSum is the sum of the amounts of all entries; Entries counts
+	// them. LastBalanceAfter is the balance_after of the latest entry, nil
+	// when there is none.
+	LedgerSum        money.Amount
+	Entries          int
+	LastBalanceAfter *money.Amount
+	// GLBalance is the account's net credit on customer_deposits.
+	GLBalance money.Amount
+}
+
+// LedgerChainBreak is an entry whose balance_after is not the previous
+// entry's balance_after plus its own amount.
+type LedgerChainBreak struct {
+	AccountID       uuid.UUID
+	LedgerEntryID   uuid.UUID
+	PreviousBalance money.Amount
+	Amount          money.Amount
+	BalanceAfter    money.Amount
+}
+
+// LedgerSnapshot is everything reconciliation reads, taken from a single
+// database snapshot.
+type LedgerSnapshot struct {
+	Accounts    []AccountLedgerTotals
+	ChainBreaks []LedgerChainBreak
+}
+
+// DiscrepancyKind classifies a reconciliation finding.
+type DiscrepancyKind string
+
+const (
+	// DiscrepancyBalance: accounts.balance differs from the sum of the
+	// account's ledger entries.
+	DiscrepancyBalance DiscrepancyKind = "balance_mismatch"
+	// DiscrepancyLastEntry: accounts.balance differs from the balance_after
+	// of the account's latest entry.
+	DiscrepancyLastEntry DiscrepancyKind = "last_entry_mismatch"
+	// DiscrepancyChainBreak: an entry's balance_after does not follow from
+	// the previous entry.
+	DiscrepancyChainBreak DiscrepancyKind = "chain_break"
+	// DiscrepancyGL: accounts.balance differs from the account's share of
+	// customer_deposits in the general ledger.
+	DiscrepancyGL DiscrepancyKind = "gl_mismatch"
+)
+
+// ReconciliationDiscrepancy is one disagreement found by reconciliation.
+// Expected is the figure recomputed from the ledger and Actual the stored
+// one; LedgerEntryID is set for chain breaks.
+type ReconciliationDiscrepancy struct {
+	AccountID     uuid.UUID       `json:"accountId"`
+	Kind          DiscrepancyKind `json:"kind"`
+	Currency      money.Currency  `json:"currency"`
+	Expected      money.Amount    `json:"expected"`
+	Actual        money.Amount    `json:"actual"`
+	LedgerEntryID *uuid.UUID      `json:"ledgerEntryId,omitempty"`
+}
+
+// ReconciliationReport is the result of one reconciliation run. Clean is
+// true when no discrepancy was found.
+type ReconciliationReport struct {
+	ID               uuid.UUID                   `json:"id"`
+	StartedAt        time.Time                   `json:"startedAt"`
+	FinishedAt       time.Time                   `json:"finishedAt"`
+	AccountsChecked  int                         `json:"accountsChecked"`
+	EntriesChecked   int                         `json:"entriesChecked"`
+	Discrepancies    []ReconciliationDiscrepancy `json:"discrepancies"`
+	AffectedAccounts []uuid.UUID                 `json:"affectedAccounts"`
+	AutoFreeze       bool                        `json:"autoFreeze"`
+	FrozenAccounts   []uuid.UUID                 `json:"frozenAccounts"`
+	Clean            bool                        `json:"clean"`
+}
+
+// ChainHead is the latest link of an account's ledger hash chain. An
+// account without entries has Sequence 0 and no Hash.
+type ChainHead struct {
+	AccountID uuid.UUID `json:"accountId"`
+	Sequence  int64     `json:"sequence"`
+	Hash      string    `json:"hash"`
+}
+
+// LedgerChain is an account's chain head together with all its entries in
+// sequence order, read from one snapshot.
+type LedgerChain struct {
+	Head    ChainHead
+	Entries []LedgerEntry
+}
+
+// HashChainBreakReason says how a link of a ledger hash chain is broken.
+type HashChainBreakReason string
+
+const (
+	// ChainUnsealed: the entry has no hash.
+	ChainUnsealed HashChainBreakReason = "unsealed"
+	// ChainSequenceGap: the entry does not carry the next sequence number,
+	// so an entry was removed or inserted.
+	ChainSequenceGap HashChainBreakReason = "sequence_gap"
+	// ChainPrevHashMismatch: PrevHash is not the hash of the entry before.
+	ChainPrevHashMismatch HashChainBreakReason = "prev_hash_mismatch"
+	// ChainHashMismatch: the entry's contents no longer match its hash.
+	ChainHashMismatch HashChainBreakReason = "hash_mismatch"
+	// ChainHeadMismatch: the account's chain head is not its last entry.
+	ChainHeadMismatch HashChainBreakReason = "head_mismatch"
+	// ChainCheckpointMismatch: the entry a signed checkpoint recorded as the
+	// head is missing or has a different hash.
+	ChainCheckpointMismatch HashChainBreakReason = "checkpoint_mismatch"
+)
+
+// HashChainBreak is the first broken link of an account's chain. Expected
+// and Actual are the hashes or sequence numbers that disagree.
+type HashChainBreak struct {
+	AccountID     uuid.UUID            `json:"accountId"`
+	Sequence      int64                `json:"sequence"`
+	LedgerEntryID *uuid.UUID           `json:"ledgerEntryId,omitempty"`
+	Reason        HashChainBreakReason `json:"reason"`
+	Expected      string               `json:"expected"`
+	Actual        string               `json:"actual"`
+}
+
+// HashChainVerification is the result of walking the ledger hash chains.
+// CheckpointID is the signed checkpoint the chains were compared with;
+// CheckpointError says why its signature is invalid.
+type HashChainVerification struct {
+	CheckpointID    *uuid.UUID       `json:"checkpointId,omitempty"`
+	CheckpointError string           `json:"checkpointError,omitempty"`
+	AccountsChecked int              `json:"accountsChecked"`
+	EntriesChecked  int              `json:"entriesChecked"`
+	Valid           bool             `json:"valid"`
+	Breaks          []HashChainBreak `json:"breaks"`
+}
+
+// LedgerCheckpoint is a signed snapshot of every account's chain head. Root
+// hashes the heads; Signature is an Ed25519 signature, base64-encoded, made
+// with the key whose public half is PublicKey.
+type LedgerCheckpoint struct {
+	ID        uuid.UUID   `json:"id"`
+	CreatedAt time.Time   `json:"createdAt"`
+	Heads     []ChainHead `json:"heads,omitempty"`
+	Root      string      `json:"root"`
+	KeyID     string      `json:"keyId"`
+	PublicKey string      `json:"publicKey"`
+	Signature string      `json:"signature"`
+}
+
+// IdempotencyKey is the client-supplied Idempotency-Key of a balance-changing
+// request together with a fingerprint of the request it was first used for.
+// Keys are scoped to Owner, the authenticated caller that sent them.
+type IdempotencyKey struct {
+	Owner       string
+	Key         string
+	Fingerprint string
+}
+
+// IdempotencyRecord is a stored IdempotencyKey and the JSON result of the
+// operation it guarded.
+type IdempotencyRecord struct {
+	Owner       string
+	Key         string
+	Fingerprint string
+	Response    []byte
+	CreatedAt   time.Time
+}
+
+// Cursor is an opaque position in a listing ordered by (created_at, id).
+// The next page starts strictly after it.
+type Cursor struct {
+	CreatedAt time.Time
+	ID        uuid.UUID
+}
+
+func (c Cursor) Encode() string {
+	raw := c.CreatedAt.UTC().Format(time.RFC3339Nano) + "|" + c.ID.String()
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+func DecodeCursor(s string) (*Cursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, fmt.Errorf("invalid cursor: %w", err)
+	}
+	ts, id, ok := strings.Cut(string(raw), "|")
+	if !ok {
+		return nil, fmt.Errorf("invalid cursor")
+	}
+	createdAt, err := time.Parse(time.RFC3339Nano, ts)
+	if err != nil {
+		return nil, fmt.Errorf("invalid cursor: %w", err)
+	}
+	parsedID, err := uuid.Parse(id)
+	if err != nil {
+		return nil, fmt.Errorf("invalid cursor: %w", err)
+	}
+	return &Cursor{CreatedAt: createdAt, ID: parsedID}, nil
+}
+
+// AccountFilter selects a customer's accounts. Zero-valued Status and
+// Currency match everything.
+type AccountFilter struct {
+	CustomerID uuid.UUID
+	Status     AccountStatus
+	Currency   money.Currency
+	After      *Cursor
+	Limit      int
+}
+
+type AccountPage struct {
+	Items      []Account `json:"items"`
+	NextCursor string    `json:"nextCursor,omitempty"`
+}
+
+// LedgerFilter selects ledger entries of one account, newest first. From is
+// inclusive and To exclusive; amount bounds apply to the absolute amount.
+type LedgerFilter struct {
+	AccountID uuid.UUID
+	From      *time.Time
+	To        *time.Time
+	Types     []LedgerEntryType
+	MinAmount *money.Amount
+	MaxAmount *money.Amount
+	Before    *Cursor
+	Limit     int
+}
+
+type LedgerPage struct {
+	Items      []LedgerEntry `json:"items"`
+	NextCursor string        `json:"nextCursor,omitempty"`
 }
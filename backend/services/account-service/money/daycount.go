@@ -0,0 +1,57 @@
+package money
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// DayCount is a day-count convention: how many days of interest each
+// calendar day earns and how many days make a year.
+type DayCount string
+
+const (
+	// Act365 counts every calendar day against a year of 365 days.
+	Act365 DayCount = "ACT/365"
+	// Thirty360 counts every month as 30 days against a year of 360: the
+	// 31st earns nothing and the last day of February earns the days up to
+	// the 30th.
+	Thirty360 DayCount = "30/360"
+)
+
+var ErrUnknownDayCount = errors.New("unknown day-count convention")
+
+// ParseDayCount parses "ACT/365" or "30/360", ignoring case.
+func ParseDayCount(s string) (DayCount, error) {
+	switch d := DayCount(strings.ToUpper(strings.TrimSpace(s))); d {
+	case Act365, Thirty360:
+		return d, nil
+	}
+	return "", fmt.Errorf("%w: %q", ErrUnknownDayCount, s)
+}
+
+// Basis returns the number of days in a year.
+func (d DayCount) Basis() int {
+	if d == Thirty360 {
+		return 360
+	}
+	return 365
+}
+
+// Days returns the days of interest the calendar day day earns. Under
+// Thirty360 the days of a month always add up to 30.
+func (d DayCount) Days(day time.Time) int {
+	if d != Thirty360 {
+		return 1
+	}
+	y, m, dd := day.Date()
+	last := time.Date(y, m+1, 0, 0, 0, 0, 0, time.UTC).Day()
+	switch {
+	case dd == 31:
+		return 0
+	case dd == last:
+		return 30 - dd + 1
+	}
+	return 1
+}
@@ -0,0 +1,42 @@
+package money
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseDayCount(t *testing.T) {
+	d, err := ParseDayCount("act/365")
+	require.NoError(t, err)
+	assert.Equal(t, Act365, d)
+	assert.Equal(t, 365, d.Basis())
+
+	d, err = ParseDayCount("30/360")
+	require.NoError(t, err)
+	assert.Equal(t, 360, d.Basis())
+
+	_, err = ParseDayCount("ACT/ACT")
+	assert.ErrorIs(t, err, ErrUnknownDayCount)
+}
+
+func TestDayCount_Days(t *testing.T) {
+	monthDays := func(d DayCount, y int, m time.Month) int {
+		total := 0
+		for day := time.Date(y, m, 1, 0, 0, 0, 0, time.UTC); day.Month() == m; day = day.AddDate(0, 0, 1) {
+			total += d.Days(day)
+		}
+		return total
+	}
+
+	assert.Equal(t, 31, monthDays(Act365, 2024, time.January))
+	assert.Equal(t, 29, monthDays(Act365, 2024, time.February))
+	for _, m := range []time.Month{time.January, time.February, time.April} {
+		assert.Equal(t, 30, monthDays(Thirty360, 2024, m), m)
+		assert.Equal(t, 30, monthDays(Thirty360, 2023, m), m)
+	}
+	assert.Equal(t, 0, Thirty360.Days(time.Date(2024, 1, 31, 0, 0, 0, 0, time.UTC)))
+	assert.Equal(t, 3, Thirty360.Days(time.Date(2023, 2, 28, 0, 0, 0, 0, time.UTC)))
+}
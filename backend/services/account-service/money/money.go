@@ -0,0 +1,399 @@
+// Package money provides exact fixed-point monetary amounts.
+//
+// Amounts are stored as a signed count of 1/10000 units, which matches the
+// NUMERIC(20, 4) columns used by the account-service schema, so values survive
+// a round trip through Postgres and JSON without binary floating point drift.
+package money
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Scale is the number of fractional digits kept by an Amount.
+const Scale = 4
+
+const unitsPerWhole = 10000
+
+var (
+	ErrInvalidAmount    = errors.New("invalid amount")
+	ErrUnknownCurrency  = errors.New("unknown currency")
+	ErrCurrencyMismatch = errors.New("currency mismatch")
+	// ErrOverflow is returned when the result of arithmetic on amounts does
+	// not fit in an Amount.
+	ErrOverflow = errors.New("amount out of range")
+)
+
+// Amount is a currency-less decimal with Scale fractional digits.
+// The zero value is 0.
+type Amount struct {
+	units int64
+}
+
+// ParseAmount parses a plain decimal string such as "12.5" or "-0.0001".
+// Exponents and more than Scale fractional digits are rejected.
+func ParseAmount(s string) (Amount, error) {
+	units, err := parseFixed(s, Scale)
+	if err != nil {
+		return Amount{}, err
+	}
+	return Amount{units: units}, nil
+}
+
+// parseFixed parses a plain decimal string into a count of 10^-scale units.
+func parseFixed(s string, scale int) (int64, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return 0, fmt.Errorf("%w: empty string", ErrInvalidAmount)
+	}
+
+	neg := false
+	switch s[0] {
+	case '-':
+		neg = true
+		s = s[1:]
+	case '+':
+		s = s[1:]
+	}
+
+	whole, frac, hasDot := strings.Cut(s, ".")
+	if whole == "" && frac == "" || hasDot && frac == "" {
+		return 0, fmt.Errorf("%w: %q", ErrInvalidAmount, s)
+	}
+	if len(frac) > scale {
+		return 0, fmt.Errorf("%w: more than %d decimal places", ErrInvalidAmount, scale)
+	}
+	if !isDigits(whole) || !isDigits(frac) {
+		return 0, fmt.Errorf("%w: %q", ErrInvalidAmount, s)
+	}
+
+	perWhole := pow10(scale)
+	var units int64
+	if whole != "" {
+		w, err := strconv.ParseInt(whole, 10, 64)
+		if err != nil || w > math.MaxInt64/perWhole {
+			return 0, fmt.Errorf("%w: out of range", ErrInvalidAmount)
+		}
+		units = w * perWhole
+	}
+	if frac != "" {
+		f, _ := strconv.ParseInt(frac+strings.Repeat("0", scale-len(frac)), 10, 64)
+		if units > math.MaxInt64-f {
+			return 0, fmt.Errorf("%w: out of range", ErrInvalidAmount)
+		}
+		units += f
+	}
+
+	if neg {
+		units = -units
+	}
+	return units, nil
+}
+
+func pow10(n int) int64 {
+	p := int64(1)
+	for i := 0; i < n; i++ {
+		p *= 10
+	}
+	return p
+}
+
+// MustParseAmount is like ParseAmount but panics on error. It is meant for
+// constants and tests.
+func MustParseAmount(s string) Amount {
+	a, err := ParseAmount(s)
+	if err != nil {
+		panic(err)
+	}
+	return a
+}
+
+func isDigits(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] < '0' || s[i] > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// Add returns a + b, or ErrOverflow when the sum does not fit in an Amount.
+func (a Amount) Add(b Amount) (Amount, error) {
+	sum := a.units + b.units
+	if b.units > 0 && sum < a.units || b.units < 0 && sum > a.units {
+		return Amount{}, fmt.Errorf("%w: %s + %s", ErrOverflow, a, b)
+	}
+	return Amount{units: sum}, nil
+}
+
+// Sub returns a - b, or ErrOverflow when the difference does not fit in an
+// Amount.
+func (a Amount) Sub(b Amount) (Amount, error) {
+	diff := a.units - b.units
+	if b.units > 0 && diff > a.units || b.units < 0 && diff < a.units {
+		return Amount{}, fmt.Errorf("%w: %s - %s", ErrOverflow, a, b)
+	}
+	return Amount{units: diff}, nil
+}
+
+func (a Amount) Neg() Amount { return Amount{units: -a.units} }
+
+func (a Amount) Abs() Amount {
+	if a.units < 0 {
+		return a.Neg()
+	}
+	return a
+}
+
+// Truncate rounds a toward zero to the given number of decimals, e.g. to a
+// currency's MinorUnits.
+func (a Amount) Truncate(decimals int) Amount {
+	if decimals >= Scale {
+		return a
+	}
+	step := pow10(Scale - decimals)
+	return Amount{units: a.units / step * step}
+}
+
+// Sign returns -1, 0 or +1.
+func (a Amount) Sign() int {
+	switch {
+	case a.units < 0:
+		return -1
+	case a.units > 0:
+		return 1
+	}
+	return 0
+}
+
+func (a Amount) IsZero() bool     { return a.units == 0 }
+func (a Amount) IsNegative() bool { return a.units < 0 }
+
+// Cmp returns -1, 0 or +1 depending on whether a is less than, equal to or
+// greater than b.
+func (a Amount) Cmp(b Amount) int {
+	switch {
+	case a.units < b.units:
+		return -1
+	case a.units > b.units:
+		return 1
+	}
+	return 0
+}
+
+// String formats the amount with at least two and at most Scale decimals,
+// e.g. "12500.50" or "0.0001".
+func (a Amount) String() string {
+	return formatFixed(a.units, Scale, 2)
+}
+
+// formatFixed formats a count of 10^-scale units with trailing zeros trimmed
+// down to minDecimals.
+func formatFixed(u int64, scale, minDecimals int) string {
+	sign := ""
+	if u < 0 {
+		sign = "-"
+	}
+	// Work on the unsigned magnitude so math.MinInt64 formats correctly.
+	mag := uint64(u)
+	if u < 0 {
+		mag = uint64(-(u + 1)) + 1
+	}
+
+	perWhole := uint64(pow10(scale))
+	frac := fmt.Sprintf("%0*d", scale, mag%perWhole)
+	frac = strings.TrimRight(frac, "0")
+	for len(frac) < minDecimals {
+		frac += "0"
+	}
+	return fmt.Sprintf("%s%d.%s", sign, mag/perWhole, frac)
+}
+
+// MarshalJSON encodes the amount as a JSON string so clients never have to
+// round-trip it through a float.
+func (a Amount) MarshalJSON() ([]byte, error) {
+	return json.Marshal(a.String())
+}
+
+// UnmarshalJSON accepts both a JSON string ("10.50") and a bare number
+// literal (10.50). Number literals are parsed from their text, not via float64.
+func (a *Amount) UnmarshalJSON(data []byte) error {
+	s := string(data)
+	if s == "null" {
+		return fmt.Errorf("%w: null", ErrInvalidAmount)
+	}
+	if strings.HasPrefix(s, `"`) {
+		if err := json.Unmarshal(data, &s); err != nil {
+			return fmt.Errorf("%w: %v", ErrInvalidAmount, err)
+		}
+	}
+	parsed, err := ParseAmount(s)
+	if err != nil {
+		return err
+	}
+	*a = parsed
+	return nil
+}
+
+// Value implements driver.Valuer. NUMERIC columns accept the decimal string
+// as-is, so no precision is lost on the way in.
+func (a Amount) Value() (driver.Value, error) {
+	return a.String(), nil
+}
+
+// Scan implements sql.Scanner for NUMERIC columns.
+func (a *Amount) Scan(src interface{}) error {
+	var parsed Amount
+	var err error
+	switch v := src.(type) {
+	case []byte:
+		parsed, err = ParseAmount(string(v))
+	case string:
+		parsed, err = ParseAmount(v)
+	case int64:
+		if v > math.MaxInt64/unitsPerWhole || v < math.MinInt64/unitsPerWhole {
+			return fmt.Errorf("%w: out of range", ErrInvalidAmount)
+		}
+		parsed = Amount{units: v * unitsPerWhole}
+	case float64:
+		parsed, err = ParseAmount(strconv.FormatFloat(v, 'f', -1, 64))
+	default:
+		return fmt.Errorf("%w: cannot scan %T", ErrInvalidAmount, src)
+	}
+	if err != nil {
+		return err
+	}
+	*a = parsed
+	return nil
+}
+
+// Currency is an ISO 4217 alphabetic currency code.
+type Currency string
+
+const (
+	PLN Currency = "PLN"
+	EUR Currency = "EUR"
+	USD Currency = "USD"
+	GBP Currency = "GBP"
+	CHF Currency = "CHF"
+)
+
+// minorUnits lists the supported currencies with their ISO 4217 exponent.
+var minorUnits = map[Currency]int{
+	PLN:   2,
+	EUR:   2,
+	USD:   2,
+	GBP:   2,
+	CHF:   2,
+	"CZK": 2,
+	"SEK": 2,
+	"NOK": 2,
+	"DKK": 2,
+	"JPY": 0,
+}
+
+// ParseCurrency normalises and validates a currency code.
+func ParseCurrency(s string) (Currency, error) {
+	c := Currency(strings.ToUpper(strings.TrimSpace(s)))
+	if _, ok := minorUnits[c]; !ok {
+		return "", fmt.Errorf("%w: %q", ErrUnknownCurrency, s)
+	}
+	return c, nil
+}
+
+// MinorUnits returns the number of decimals used by the currency in
+// everyday amounts (2 for PLN, 0 for JPY).
+func (c Currency) MinorUnits() int {
+	return minorUnits[c]
+}
+
+// checkMinorUnits rejects amounts finer than the currency's minor unit, such
+// as 0.001 PLN.
+func (c Currency) checkMinorUnits(a Amount) error {
+	if a.Truncate(c.MinorUnits()) != a {
+		return fmt.Errorf("%w: %s has more than %d decimal places for %s", ErrInvalidAmount, a, c.MinorUnits(), c)
+	}
+	return nil
+}
+
+// Money is an Amount tagged with its currency. Arithmetic between values in
+// different currencies fails with ErrCurrencyMismatch.
+type Money struct {
+	Amount   Amount
+	Currency Currency
+}
+
+func New(amount Amount, currency Currency) Money {
+	return Money{Amount: amount, Currency: currency}
+}
+
+// NewExact is like New but rejects an amount with more decimals than the
+// currency's minor unit. Amounts sent by clients are built with it, while
+// fees and interest computed to Scale decimals use New.
+func NewExact(amount Amount, currency Currency) (Money, error) {
+	if err := currency.checkMinorUnits(amount); err != nil {
+		return Money{}, err
+	}
+	return Money{Amount: amount, Currency: currency}, nil
+}
+
+// Parse builds Money from a decimal string and a currency code, rejecting
+// more decimals than the currency's minor unit.
+func Parse(amount, currency string) (Money, error) {
+	c, err := ParseCurrency(currency)
+	if err != nil {
+		return Money{}, err
+	}
+	a, err := ParseAmount(amount)
+	if err != nil {
+		return Money{}, err
+	}
+	return NewExact(a, c)
+}
+
+func (m Money) sameCurrency(o Money) error {
+	if m.Currency != o.Currency {
+		return fmt.Errorf("%w: %s and %s", ErrCurrencyMismatch, m.Currency, o.Currency)
+	}
+	return nil
+}
+
+func (m Money) Add(o Money) (Money, error) {
+	if err := m.sameCurrency(o); err != nil {
+		return Money{}, err
+	}
+	sum, err := m.Amount.Add(o.Amount)
+	if err != nil {
+		return Money{}, err
+	}
+	return Money{Amount: sum, Currency: m.Currency}, nil
+}
+
+func (m Money) Sub(o Money) (Money, error) {
+	if err := m.sameCurrency(o); err != nil {
+		return Money{}, err
+	}
+	diff, err := m.Amount.Sub(o.Amount)
+	if err != nil {
+		return Money{}, err
+	}
+	return Money{Amount: diff, Currency: m.Currency}, nil
+}
+
+func (m Money) Cmp(o Money) (int, error) {
+	if err := m.sameCurrency(o); err != nil {
+		return 0, err
+	}
+	return m.Amount.Cmp(o.Amount), nil
+}
+
+func (m Money) Neg() Money       { return Money{Amount: m.Amount.Neg(), Currency: m.Currency} }
+func (m Money) IsNegative() bool { return m.Amount.IsNegative() }
+func (m Money) IsZero() bool     { return m.Amount.IsZero() }
+func (m Money) Sign() int        { return m.Amount.Sign() }
+func (m Money) String() string   { return m.Amount.String() + " " + string(m.Currency) }
@@ -0,0 +1,160 @@
+package money
+
+import (
+	"encoding/json"
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseAmount(t *testing.T) {
+	cases := map[string]string{
+		"0":         "0.00",
+		"12.5":      "12.50",
+		"-0.0001":   "-0.0001",
+		"+100":      "100.00",
+		".25":       "0.25",
+		"12500.50":  "12500.50",
+		"1.2340":    "1.234",
+		" 7.10 ":    "7.10",
+		"-42.0000":  "-42.00",
+		"999999.99": "999999.99",
+	}
+	for in, want := range cases {
+		a, err := ParseAmount(in)
+		require.NoError(t, err, in)
+		assert.Equal(t, want, a.String(), in)
+	}
+}
+
+func TestParseAmount_Invalid(t *testing.T) {
+	for _, in := range []string{"", "-", "1.", "abc", "1e3", "1.23456", "1,5", "--1", "99999999999999999999"} {
+		_, err := ParseAmount(in)
+		assert.ErrorIs(t, err, ErrInvalidAmount, in)
+	}
+}
+
+func TestAmount_NoFloatDrift(t *testing.T) {
+	// 0.1 added ten times is exactly 1 - the classic float64 failure case.
+	sum := Amount{}
+	step := MustParseAmount("0.1")
+	for i := 0; i < 10; i++ {
+		var err error
+		sum, err = sum.Add(step)
+		require.NoError(t, err)
+	}
+	assert.Equal(t, MustParseAmount("1"), sum)
+}
+
+func TestAmount_Overflow(t *testing.T) {
+	max := Amount{units: math.MaxInt64}
+	min := Amount{units: math.MinInt64}
+
+	_, err := max.Add(MustParseAmount("0.0001"))
+	assert.ErrorIs(t, err, ErrOverflow)
+	_, err = min.Add(MustParseAmount("-0.0001"))
+	assert.ErrorIs(t, err, ErrOverflow)
+	_, err = min.Sub(MustParseAmount("0.0001"))
+	assert.ErrorIs(t, err, ErrOverflow)
+	_, err = max.Sub(MustParseAmount("-0.0001"))
+	assert.ErrorIs(t, err, ErrOverflow)
+	_, err = New(max, PLN).Add(New(max, PLN))
+	assert.ErrorIs(t, err, ErrOverflow)
+
+	sum, err := max.Add(min)
+	require.NoError(t, err)
+	assert.Equal(t, MustParseAmount("-0.0001"), sum)
+	diff, err := min.Sub(min)
+	require.NoError(t, err)
+	assert.True(t, diff.IsZero())
+}
+
+func TestAmount_JSON(t *testing.T) {
+	var body struct {
+		A Amount `json:"a"`
+		B Amount `json:"b"`
+	}
+	require.NoError(t, json.Unmarshal([]byte(`{"a":"10.05","b":0.1}`), &body))
+	assert.Equal(t, "10.05", body.A.String())
+	assert.Equal(t, "0.10", body.B.String())
+
+	out, err := json.Marshal(body)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"a":"10.05","b":"0.10"}`, string(out))
+
+	assert.Error(t, json.Unmarshal([]byte(`{"a":null}`), &body))
+	assert.Error(t, json.Unmarshal([]byte(`{"a":"1.00001"}`), &body))
+}
+
+func TestAmount_Scan(t *testing.T) {
+	var a Amount
+	require.NoError(t, a.Scan([]byte("12500.5000")))
+	assert.Equal(t, "12500.50", a.String())
+	require.NoError(t, a.Scan(100.25))
+	assert.Equal(t, "100.25", a.String())
+	require.NoError(t, a.Scan(int64(3)))
+	assert.Equal(t, "3.00", a.String())
+	assert.Error(t, a.Scan(nil))
+
+	v, err := MustParseAmount("-1.5").Value()
+	require.NoError(t, err)
+	assert.Equal(t, "-1.50", v)
+}
+
+func TestParseCurrency(t *testing.T) {
+	c, err := ParseCurrency("pln")
+	require.NoError(t, err)
+	assert.Equal(t, PLN, c)
+	assert.Equal(t, 2, c.MinorUnits())
+
+	_, err = ParseCurrency("XXX")
+	assert.ErrorIs(t, err, ErrUnknownCurrency)
+}
+
+func TestParse_RejectsDecimalsBeyondMinorUnit(t *testing.T) {
+	m, err := Parse("12.50", "pln")
+	require.NoError(t, err)
+	assert.Equal(t, "12.50 PLN", m.String())
+
+	for _, tc := range []struct{ amount, currency string }{
+		{"0.0001", "PLN"}, {"12.505", "EUR"}, {"100.5", "JPY"},
+	} {
+		_, err := Parse(tc.amount, tc.currency)
+		assert.ErrorIs(t, err, ErrInvalidAmount, tc.amount+" "+tc.currency)
+	}
+
+	_, err = NewExact(MustParseAmount("1.001"), PLN)
+	assert.ErrorIs(t, err, ErrInvalidAmount)
+	exact, err := NewExact(MustParseAmount("1000"), "JPY")
+	require.NoError(t, err)
+	assert.Equal(t, New(MustParseAmount("1000"), "JPY"), exact)
+}
+
+func TestMoney_RefusesToMixCurrencies(t *testing.T) {
+	pln := New(MustParseAmount("10"), PLN)
+	eur := New(MustParseAmount("10"), EUR)
+
+	_, err := pln.Add(eur)
+	assert.ErrorIs(t, err, ErrCurrencyMismatch)
+	_, err = pln.Sub(eur)
+	assert.ErrorIs(t, err, ErrCurrencyMismatch)
+	_, err = pln.Cmp(eur)
+	assert.ErrorIs(t, err, ErrCurrencyMismatch)
+
+	sum, err := pln.Add(New(MustParseAmount("0.01"), PLN))
+	require.NoError(t, err)
+	assert.Equal(t, "10.01 PLN", sum.String())
+
+	diff, err := pln.Sub(New(MustParseAmount("10.50"), PLN))
+	require.NoError(t, err)
+	assert.True(t, diff.IsNegative())
+}
+
+func TestAmount_Truncate(t *testing.T) {
+	assert.Equal(t, "4.13", MustParseAmount("4.1399").Truncate(2).String())
+	assert.Equal(t, "-4.13", MustParseAmount("-4.1399").Truncate(2).String())
+	assert.Equal(t, "4.00", MustParseAmount("4.99").Truncate(0).String())
+	assert.Equal(t, "4.1399", MustParseAmount("4.1399").Truncate(4).String())
+}
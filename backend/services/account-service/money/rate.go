@@ -0,0 +1,192 @@
+package money
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+// RateScale is the number of fractional digits kept by a Rate. It matches
+// the NUMERIC(20, 8) rate columns.
+const RateScale = 8
+
+const rateUnitsPerWhole = 100000000
+
+var ErrInvalidRate = errors.New("invalid exchange rate")
+
+// Rate is an exchange rate: how many units of one currency one unit of
+// another currency buys. Valid rates are positive; the zero value means "no
+// rate". Interest rates use the same type, as a fraction per year.
+type Rate struct {
+	units int64
+}
+
+// ParseRate parses a positive decimal string such as "4.2650" with at most
+// RateScale fractional digits.
+func ParseRate(s string) (Rate, error) {
+	units, err := parseFixed(s, RateScale)
+	if err != nil {
+		return Rate{}, fmt.Errorf("%w: %v", ErrInvalidRate, err)
+	}
+	if units <= 0 {
+		return Rate{}, fmt.Errorf("%w: %q is not positive", ErrInvalidRate, s)
+	}
+	return Rate{units: units}, nil
+}
+
+// MustParseRate is like ParseRate but panics on error. It is meant for
+// constants and tests.
+func MustParseRate(s string) Rate {
+	r, err := ParseRate(s)
+	if err != nil {
+		panic(err)
+	}
+	return r
+}
+
+func (r Rate) IsZero() bool { return r.units == 0 }
+
+// Cmp returns -1, 0 or +1 depending on whether r is less than, equal to or
+// greater than o.
+func (r Rate) Cmp(o Rate) int {
+	switch {
+	case r.units < o.units:
+		return -1
+	case r.units > o.units:
+		return 1
+	}
+	return 0
+}
+
+// Inverse returns 1/r rounded half up to RateScale digits.
+func (r Rate) Inverse() Rate {
+	if r.units == 0 {
+		return Rate{}
+	}
+	num := new(big.Int).Mul(big.NewInt(rateUnitsPerWhole), big.NewInt(rateUnitsPerWhole))
+	return Rate{units: divRound(num, big.NewInt(r.units))}
+}
+
+// Mul chains two rates (A->B times B->C gives A->C), rounded half up to
+// RateScale digits.
+func (r Rate) Mul(o Rate) Rate {
+	num := new(big.Int).Mul(big.NewInt(r.units), big.NewInt(o.units))
+	return Rate{units: divRound(num, big.NewInt(rateUnitsPerWhole))}
+}
+
+// Convert returns a*r in the target currency, rounded toward zero to the
+// currency's minor unit, so a conversion never pays out a fraction it did
+// not receive.
+func (r Rate) Convert(a Amount, to Currency) Amount {
+	product := new(big.Int).Mul(big.NewInt(a.units), big.NewInt(r.units))
+	// product has Scale+RateScale decimals; keep only the currency's.
+	step := new(big.Int).Mul(big.NewInt(rateUnitsPerWhole), big.NewInt(pow10(Scale-to.MinorUnits())))
+	product.Quo(product, step)
+	product.Mul(product, big.NewInt(pow10(Scale-to.MinorUnits())))
+	return Amount{units: product.Int64()}
+}
+
+// Interest returns simple interest on a at the annual rate r for days out of
+// a year of basis days, rounded half up to the minor unit of to. The result
+// has the sign of a.
+func (r Rate) Interest(a Amount, days, basis int, to Currency) Amount {
+	return r.interest(a, days, basis, pow10(Scale-to.MinorUnits()))
+}
+
+// Share returns a*r rounded half up to the minor unit of to, such as a
+// percentage fee on an amount. The result has the sign of a.
+func (r Rate) Share(a Amount, to Currency) Amount {
+	return r.interest(a, 1, 1, pow10(Scale-to.MinorUnits()))
+}
+
+// Accrue is Interest rounded half up to Scale decimals instead of a minor
+// unit, for interest that is accumulated day by day and paid out later.
+func (r Rate) Accrue(a Amount, days, basis int) Amount {
+	return r.interest(a, days, basis, 1)
+}
+
+// interest computes a*r*days/basis rounded half up to a multiple of step
+// units.
+func (r Rate) interest(a Amount, days, basis int, step int64) Amount {
+	num := new(big.Int).Mul(big.NewInt(a.Abs().units), big.NewInt(r.units))
+	num.Mul(num, big.NewInt(int64(days)))
+	den := new(big.Int).Mul(big.NewInt(rateUnitsPerWhole), big.NewInt(int64(basis)))
+	den.Mul(den, big.NewInt(step))
+	units := divRound(num, den) * step
+	if a.IsNegative() {
+		units = -units
+	}
+	return Amount{units: units}
+}
+
+// divRound divides two non-negative integers, rounding half up.
+func divRound(num, den *big.Int) int64 {
+	q, m := new(big.Int).QuoRem(num, den, new(big.Int))
+	if m.Lsh(m, 1).Cmp(den) >= 0 {
+		q.Add(q, big.NewInt(1))
+	}
+	return q.Int64()
+}
+
+// String formats the rate with at least four and at most RateScale
+// decimals, e.g. "4.2650" or "0.02713375".
+func (r Rate) String() string {
+	return formatFixed(r.units, RateScale, 4)
+}
+
+// MarshalJSON encodes the rate as a JSON string, like Amount.
+func (r Rate) MarshalJSON() ([]byte, error) {
+	return json.Marshal(r.String())
+}
+
+// UnmarshalJSON accepts a JSON string or a bare number literal.
+func (r *Rate) UnmarshalJSON(data []byte) error {
+	s := string(data)
+	if strings.HasPrefix(s, `"`) {
+		if err := json.Unmarshal(data, &s); err != nil {
+			return fmt.Errorf("%w: %v", ErrInvalidRate, err)
+		}
+	}
+	parsed, err := ParseRate(s)
+	if err != nil {
+		return err
+	}
+	*r = parsed
+	return nil
+}
+
+// Value implements driver.Valuer.
+func (r Rate) Value() (driver.Value, error) {
+	return r.String(), nil
+}
+
+// Scan implements sql.Scanner for NUMERIC columns.
+func (r *Rate) Scan(src interface{}) error {
+	var s string
+	switch v := src.(type) {
+	case []byte:
+		s = string(v)
+	case string:
+		s = v
+	case int64:
+		if v > math.MaxInt64/rateUnitsPerWhole {
+			return fmt.Errorf("%w: out of range", ErrInvalidRate)
+		}
+		s = strconv.FormatInt(v, 10)
+	case float64:
+		s = strconv.FormatFloat(v, 'f', -1, 64)
+	default:
+		return fmt.Errorf("%w: cannot scan %T", ErrInvalidRate, src)
+	}
+	parsed, err := ParseRate(s)
+	if err != nil {
+		return err
+	}
+	*r = parsed
+	return nil
+}
@@ -0,0 +1,100 @@
+package money
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseRate(t *testing.T) {
+	cases := map[string]string{
+		"4.265":      "4.2650",
+		"0.02713375": "0.02713375",
+		"1":          "1.0000",
+		"142.5":      "142.5000",
+	}
+	for in, want := range cases {
+		r, err := ParseRate(in)
+		require.NoError(t, err, in)
+		assert.Equal(t, want, r.String(), in)
+	}
+}
+
+func TestParseRate_Invalid(t *testing.T) {
+	for _, in := range []string{"", "0", "-4.2", "0.000000001", "abc"} {
+		_, err := ParseRate(in)
+		assert.ErrorIs(t, err, ErrInvalidRate, in)
+	}
+}
+
+func TestRate_Convert(t *testing.T) {
+	r := MustParseRate("4.2650")
+
+	// 100.00 EUR at 4.2650 is exactly 426.50 PLN.
+	assert.Equal(t, "426.50", r.Convert(MustParseAmount("100"), PLN).String())
+	// 0.99 EUR is 4.222350 PLN; the fraction of a grosz is not paid out.
+	assert.Equal(t, "4.22", r.Convert(MustParseAmount("0.99"), PLN).String())
+	// JPY has no minor unit.
+	assert.Equal(t, "3685.00", MustParseRate("36.8579").Convert(MustParseAmount("100"), "JPY").String())
+}
+
+func TestRate_InverseAndMul(t *testing.T) {
+	assert.Equal(t, "0.23446659", MustParseRate("4.2650").Inverse().String())
+	// EUR->PLN then PLN->USD gives the EUR->USD cross rate.
+	cross := MustParseRate("4.2650").Mul(MustParseRate("0.25641026"))
+	assert.Equal(t, "1.09358976", cross.String())
+	assert.Equal(t, 1, MustParseRate("4.30").Cmp(MustParseRate("4.2650")))
+}
+
+func TestRate_Interest(t *testing.T) {
+	r := MustParseRate("0.18")
+
+	// One day of 18% a year on 1000.00 PLN is 0.493150... PLN.
+	assert.Equal(t, "0.49", r.Interest(MustParseAmount("1000"), 1, 365, PLN).String())
+	assert.Equal(t, "-0.49", r.Interest(MustParseAmount("-1000"), 1, 365, PLN).String())
+	// 0.5 grosz rounds up.
+	assert.Equal(t, "0.01", MustParseRate("3.65").Interest(MustParseAmount("0.50"), 1, 365, PLN).String())
+	assert.Equal(t, "15.00", r.Interest(MustParseAmount("1000"), 30, 360, PLN).String())
+	assert.Equal(t, "0.00", r.Interest(MustParseAmount("0"), 1, 365, PLN).String())
+}
+
+func TestRate_Accrue(t *testing.T) {
+	r := MustParseRate("0.05")
+
+	// One day of 5% a year on 1000.00 is 0.136986...; accruals keep four
+	// decimals so that small daily amounts are not lost.
+	assert.Equal(t, "0.137", r.Accrue(MustParseAmount("1000"), 1, 365).String())
+	assert.Equal(t, "0.0001", r.Accrue(MustParseAmount("1"), 1, 365).String())
+	assert.Equal(t, "0.00", r.Accrue(MustParseAmount("1000"), 0, 360).String())
+}
+
+func TestRate_Share(t *testing.T) {
+	r := MustParseRate("0.005")
+
+	// 0.5% of 123.45 is 0.61725.
+	assert.Equal(t, "0.62", r.Share(MustParseAmount("123.45"), PLN).String())
+	assert.Equal(t, "-0.62", r.Share(MustParseAmount("-123.45"), PLN).String())
+	assert.Equal(t, "62.00", r.Share(MustParseAmount("12345"), "JPY").String())
+}
+
+func TestRate_JSON(t *testing.T) {
+	b, err := json.Marshal(MustParseRate("4.265"))
+	require.NoError(t, err)
+	assert.Equal(t, `"4.2650"`, string(b))
+
+	var r Rate
+	require.NoError(t, json.Unmarshal([]byte(`4.31`), &r))
+	assert.Equal(t, MustParseRate("4.31"), r)
+	assert.Error(t, json.Unmarshal([]byte(`"-1"`), &r))
+}
+
+func TestRate_Scan(t *testing.T) {
+	var r Rate
+	require.NoError(t, r.Scan([]byte("4.26500000")))
+	assert.Equal(t, "4.2650", r.String())
+	v, err := r.Value()
+	require.NoError(t, err)
+	assert.Equal(t, "4.2650", v)
+}
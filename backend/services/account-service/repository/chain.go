@@ -0,0 +1,246 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"go-web-server/services/account-service/audit"
+	"go-web-server/services/account-service/model"
+)
+
+const ledgerEntryColumns = `id, account_id, type, amount, balance_after, reference_id, COALESCE(description, ''), fx_rate, created_at,
+	COALESCE(seq, 0), COALESCE(prev_hash, ''), COALESCE(entry_hash, ''), value_date, standing_order_id`
+
+func scanLedgerEntry(row rowScanner) (*model.LedgerEntry, error) {
+	var e model.LedgerEntry
+	var valueDate time.Time
+	err := row.Scan(&e.ID, &e.AccountID, &e.Type, &e.Amount, &e.BalanceAfter, &e.ReferenceID, &e.Description, &e.FXRate, &e.CreatedAt,
+		&e.Sequence, &e.PrevHash, &e.Hash, &valueDate, &e.StandingOrderID)
+	if err != nil {
+		return nil, err
+	}
+	e.ValueDate = valueDate.Format("2006-01-02")
+	return &e, nil
+}
+
+// sealLedgerMigration names the one-time migration that sealed the entries
+// written before the hash chain existed.
+const sealLedgerMigration = "seal_ledger_entries"
+
+// SealLegacyLedgerEntries appends entries stored without a hash, such as
+// those written before the hash chain existed or inserted by the seed data,
+// to the end of their account's chain in (created_at, id) order. It runs
+// once per database, recorded in schema_migrations, and returns the number
+// of entries sealed; later calls seal nothing, so an entry that appears
+// without a hash afterwards was not written by the service and verification
+// reports it as unsealed.
+func (r *PostgresAccountRepository) SealLegacyLedgerEntries() (int, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	// Concurrent callers wait on the row and then find it taken.
+	res, err := tx.Exec(`INSERT INTO schema_migrations (name) VALUES ($1) ON CONFLICT (name) DO NOTHING`, sealLedgerMigration)
+	if err != nil {
+		return 0, err
+	}
+	if n, err := res.RowsAffected(); err != nil || n == 0 {
+		return 0, err
+	}
+
+	rows, err := tx.Query(`SELECT DISTINCT account_id FROM ledger_entries WHERE entry_hash IS NULL`)
+	if err != nil {
+		return 0, err
+	}
+	var accounts []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		accounts = append(accounts, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	sealed := 0
+	for _, id := range accounts {
+		n, err := sealAccountEntries(tx, id)
+		if err != nil {
+			return 0, fmt.Errorf("could not seal ledger entries of account %s: %w", id, err)
+		}
+		sealed += n
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+	return sealed, nil
+}
+
+func sealAccountEntries(tx *sql.Tx, accountID string) (int, error) {
+	head := model.ChainHead{}
+	err := tx.QueryRow(`SELECT id, ledger_seq, COALESCE(ledger_head_hash, '') FROM accounts WHERE id = $1 FOR UPDATE`, accountID).
+		Scan(&head.AccountID, &head.Sequence, &head.Hash)
+	if err != nil {
+		return 0, err
+	}
+
+	rows, err := tx.Query(`SELECT `+ledgerEntryColumns+` FROM ledger_entries WHERE account_id = $1 AND entry_hash IS NULL ORDER BY created_at, id`, accountID)
+	if err != nil {
+		return 0, err
+	}
+	var entries []*model.LedgerEntry
+	for rows.Next() {
+		e, err := scanLedgerEntry(rows)
+		if err != nil {
+			rows.Close()
+			return 0, err
+		}
+		entries = append(entries, e)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	for _, e := range entries {
+		head.Sequence++
+		e.Sequence, e.PrevHash = head.Sequence, head.Hash
+		e.Hash = audit.EntryHash(e)
+		head.Hash = e.Hash
+		if _, err := tx.Exec(`UPDATE ledger_entries SET seq = $1, prev_hash = $2, entry_hash = $3 WHERE id = $4`, e.Sequence, e.PrevHash, e.Hash, e.ID); err != nil {
+			return 0, err
+		}
+	}
+	if _, err := tx.Exec(`UPDATE accounts SET ledger_seq = $1, ledger_head_hash = $2 WHERE id = $3`, head.Sequence, head.Hash, accountID); err != nil {
+		return 0, err
+	}
+	return len(entries), nil
+}
+
+// ChainHeads returns the chain head of every account ordered by account id.
+func (r *PostgresAccountRepository) ChainHeads() ([]model.ChainHead, error) {
+	rows, err := r.db.Query(`SELECT id, ledger_seq, COALESCE(ledger_head_hash, '') FROM accounts ORDER BY id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	heads := []model.ChainHead{}
+	for rows.Next() {
+		var h model.ChainHead
+		if err := rows.Scan(&h.AccountID, &h.Sequence, &h.Hash); err != nil {
+			return nil, err
+		}
+		heads = append(heads, h)
+	}
+	return heads, rows.Err()
+}
+
+// LedgerChain returns an account's chain head and all its entries, sealed
+// ones in sequence order followed by any unsealed ones, or nil when the
+// account does not exist. Both are read from one snapshot.
+func (r *PostgresAccountRepository) LedgerChain(accountID string) (*model.LedgerChain, error) {
+	tx, err := r.db.BeginTx(context.Background(), &sql.TxOptions{Isolation: sql.LevelRepeatableRead, ReadOnly: true})
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	chain := &model.LedgerChain{Entries: []model.LedgerEntry{}}
+	err = tx.QueryRow(`SELECT id, ledger_seq, COALESCE(ledger_head_hash, '') FROM accounts WHERE id = $1`, accountID).
+		Scan(&chain.Head.AccountID, &chain.Head.Sequence, &chain.Head.Hash)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	rows, err := tx.Query(`SELECT `+ledgerEntryColumns+` FROM ledger_entries WHERE account_id = $1 ORDER BY seq NULLS LAST, created_at, id`, accountID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		e, err := scanLedgerEntry(rows)
+		if err != nil {
+			return nil, err
+		}
+		chain.Entries = append(chain.Entries, *e)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return chain, nil
+}
+
+func (r *PostgresAccountRepository) SaveCheckpoint(cp *model.LedgerCheckpoint) error {
+	heads, err := json.Marshal(cp.Heads)
+	if err != nil {
+		return fmt.Errorf("could not encode checkpoint heads: %w", err)
+	}
+	_, err = r.db.Exec(`INSERT INTO ledger_checkpoints (id, created_at, heads, root, key_id, public_key, signature) VALUES ($1, $2, $3, $4, $5, $6, $7)`,
+		cp.ID, cp.CreatedAt, heads, cp.Root, cp.KeyID, cp.PublicKey, cp.Signature)
+	return err
+}
+
+const checkpointColumns = `id, created_at, heads, root, key_id, public_key, signature`
+
+func scanCheckpoint(row rowScanner) (*model.LedgerCheckpoint, error) {
+	var cp model.LedgerCheckpoint
+	var heads []byte
+	if err := row.Scan(&cp.ID, &cp.CreatedAt, &heads, &cp.Root, &cp.KeyID, &cp.PublicKey, &cp.Signature); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(heads, &cp.Heads); err != nil {
+		return nil, fmt.Errorf("could not decode checkpoint heads: %w", err)
+	}
+	return &cp, nil
+}
+
+// GetCheckpoint returns a checkpoint, or nil when there is none with that id.
+func (r *PostgresAccountRepository) GetCheckpoint(id string) (*model.LedgerCheckpoint, error) {
+	cp, err := scanCheckpoint(r.db.QueryRow(`SELECT `+checkpointColumns+` FROM ledger_checkpoints WHERE id = $1`, id))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return cp, err
+}
+
+// LatestCheckpoint returns the newest checkpoint, or nil before the first.
+func (r *PostgresAccountRepository) LatestCheckpoint() (*model.LedgerCheckpoint, error) {
+	cp, err := scanCheckpoint(r.db.QueryRow(`SELECT ` + checkpointColumns + ` FROM ledger_checkpoints ORDER BY created_at DESC LIMIT 1`))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return cp, err
+}
+
+// ListCheckpoints returns up to limit checkpoints, newest first, without
+// their heads.
+func (r *PostgresAccountRepository) ListCheckpoints(limit int) ([]model.LedgerCheckpoint, error) {
+	rows, err := r.db.Query(`SELECT id, created_at, root, key_id, public_key, signature FROM ledger_checkpoints ORDER BY created_at DESC LIMIT $1`, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	checkpoints := []model.LedgerCheckpoint{}
+	for rows.Next() {
+		var cp model.LedgerCheckpoint
+		if err := rows.Scan(&cp.ID, &cp.CreatedAt, &cp.Root, &cp.KeyID, &cp.PublicKey, &cp.Signature); err != nil {
+			return nil, err
+		}
+		checkpoints = append(checkpoints, cp)
+	}
+	return checkpoints, rows.Err()
+}
@@ -0,0 +1,137 @@
+package repository
+
+import (
+	"testing"
+	"time"
+
+	"go-web-server/services/account-service/audit"
+	"go-web-server/services/account-service/calendar"
+	"go-web-server/services/account-service/model"
+	"go-web-server/services/account-service/money"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+// expectChainHead expects writeEntry to read the chain head of a locked
+// account, which is at seq and was last written at now.
+func expectChainHead(mock sqlmock.Sqlmock, accountID interface{}, seq int64, now time.Time) {
+	head := ""
+	if seq > 0 {
+		head = "previous"
+	}
+	mock.ExpectQuery("SELECT ledger_seq, (.+), NOW\\(\\) FROM accounts WHERE id =").
+		WithArgs(accountID).
+		WillReturnRows(sqlmock.NewRows([]string{"ledger_seq", "ledger_head_hash", "now"}).AddRow(seq, head, now))
+}
+
+func TestWriteEntry_ExtendsChain(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("error opening mock db: %s", err)
+	}
+	defer db.Close()
+
+	accountID := uuid.New()
+	cal, _ := calendar.Default().Get("PL")
+	// Good Friday after the cut-off takes value after Easter Monday.
+	now := time.Date(2024, 3, 29, 16, 30, 0, 0, cal.Location())
+
+	mock.ExpectBegin()
+	expectChainHead(mock, accountID.String(), 4, now)
+	mock.ExpectExec("UPDATE accounts SET balance = (.+), ledger_seq = (.+), ledger_head_hash = (.+) WHERE id =").
+		WithArgs("10.00", int64(5), sqlmock.AnyArg(), accountID.String()).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec("INSERT INTO ledger_entries").
+		WithArgs(sqlmock.AnyArg(), accountID.String(), model.Deposit, "10.00", "10.00", nil, "", nil, now, int64(5), "previous", sqlmock.AnyArg(), "2024-04-02", nil).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+
+	tx, err := db.Begin()
+	if err != nil {
+		t.Fatal(err)
+	}
+	entry, err := writeEntry(tx, cal, &model.LedgerEntry{
+		AccountID: accountID, Type: model.Deposit, Amount: money.MustParseAmount("10"), BalanceAfter: money.MustParseAmount("10"),
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(5), entry.Sequence)
+	assert.Equal(t, "previous", entry.PrevHash)
+	assert.Equal(t, audit.EntryHash(entry), entry.Hash)
+	assert.Equal(t, "2024-04-02", entry.ValueDate)
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestSealLegacyLedgerEntries(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("error opening mock db: %s", err)
+	}
+	defer db.Close()
+
+	repo := NewPostgresAccountRepository(db)
+	accountID := uuid.New()
+	first, second := uuid.New(), uuid.New()
+	entryColumns := []string{"id", "account_id", "type", "amount", "balance_after", "reference_id", "description", "fx_rate", "created_at", "seq", "prev_hash", "entry_hash", "value_date", "standing_order_id"}
+	now := time.Now()
+
+	mock.ExpectBegin()
+	mock.ExpectExec("INSERT INTO schema_migrations").
+		WithArgs("seal_ledger_entries").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery("SELECT DISTINCT account_id FROM ledger_entries WHERE entry_hash IS NULL").
+		WillReturnRows(sqlmock.NewRows([]string{"account_id"}).AddRow(accountID.String()))
+	mock.ExpectQuery("SELECT id, ledger_seq, (.+) FROM accounts WHERE id = (.+) FOR UPDATE").
+		WithArgs(accountID.String()).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "ledger_seq", "ledger_head_hash"}).AddRow(accountID.String(), 0, ""))
+	mock.ExpectQuery("SELECT (.+) FROM ledger_entries WHERE account_id = (.+) AND entry_hash IS NULL ORDER BY created_at, id").
+		WithArgs(accountID.String()).
+		WillReturnRows(sqlmock.NewRows(entryColumns).
+			AddRow(first.String(), accountID.String(), "deposit", "10000.0000", "10000.0000", nil, "Wpłata początkowa", nil, now, 0, "", "", now, nil).
+			AddRow(second.String(), accountID.String(), "deposit", "2500.5000", "12500.5000", nil, "Premia świąteczna", nil, now, 0, "", "", now, nil))
+	mock.ExpectExec("UPDATE ledger_entries SET seq").
+		WithArgs(int64(1), "", sqlmock.AnyArg(), first).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("UPDATE ledger_entries SET seq").
+		WithArgs(int64(2), sqlmock.AnyArg(), sqlmock.AnyArg(), second).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("UPDATE accounts SET ledger_seq").
+		WithArgs(int64(2), sqlmock.AnyArg(), accountID.String()).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	sealed, err := repo.SealLegacyLedgerEntries()
+	assert.NoError(t, err)
+	assert.Equal(t, 2, sealed)
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestSealLegacyLedgerEntries_RunsOnce(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("error opening mock db: %s", err)
+	}
+	defer db.Close()
+
+	repo := NewPostgresAccountRepository(db)
+
+	mock.ExpectBegin()
+	mock.ExpectExec("INSERT INTO schema_migrations").
+		WithArgs("seal_ledger_entries").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectRollback()
+
+	sealed, err := repo.SealLegacyLedgerEntries()
+	assert.NoError(t, err)
+	assert.Equal(t, 0, sealed)
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
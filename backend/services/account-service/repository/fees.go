@@ -0,0 +1,137 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"go-web-server/services/account-service/calendar"
+	"go-web-server/services/account-service/model"
+	"go-web-server/services/account-service/money"
+
+	"github.com/google/uuid"
+)
+
+// queryRower is a *sql.DB or a *sql.Tx.
+type queryRower interface {
+	QueryRow(query string, args ...interface{}) *sql.Row
+}
+
+const feeScheduleColumns = `product_code, operation, flat, percentage, min_fee, max_fee, updated_at`
+
+func scanFeeSchedule(row rowScanner) (*model.FeeSchedule, error) {
+	var s model.FeeSchedule
+	if err := row.Scan(&s.ProductCode, &s.Operation, &s.Flat, &s.Percentage, &s.MinFee, &s.MaxFee, &s.UpdatedAt); err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+// feeSchedule returns the schedule of op for a product, or nil when the
+// product does not charge for it.
+func feeSchedule(q queryRower, productCode string, op model.FeeOperation) (*model.FeeSchedule, error) {
+	s, err := scanFeeSchedule(q.QueryRow(`SELECT `+feeScheduleColumns+` FROM fee_schedules WHERE product_code = $1 AND operation = $2`, productCode, op))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("could not read fee schedule: %w", err)
+	}
+	return s, nil
+}
+
+// writeFee debits fee from a locked account as a fee entry posted against
+// fee_income. The entry and its journal take ref, the reference of the
+// operation charged; a fee charged on its own has none and is its own
+// journal. standingOrderID is the order whose payment is charged, if any.
+func writeFee(tx *sql.Tx, cal *calendar.Calendar, accountID uuid.UUID, fee money.Money, balanceAfter money.Amount, ref *uuid.UUID, description string, standingOrderID *uuid.UUID) (*model.LedgerEntry, error) {
+	entry, err := writeEntry(tx, cal, &model.LedgerEntry{
+		AccountID: accountID, Type: model.Fee, Amount: fee.Amount.Neg(), BalanceAfter: balanceAfter,
+		ReferenceID: ref, Description: description, StandingOrderID: standingOrderID,
+	})
+	if err != nil {
+		return nil, err
+	}
+	journal := entry.ID
+	if ref != nil {
+		journal = *ref
+	}
+	line := customerLine(entry, fee.Currency)
+	if err := postJournal(tx, journal, line, contraLine(line, contraAccount(model.Fee))); err != nil {
+		return nil, err
+	}
+	return entry, nil
+}
+
+// ListFeeSchedules returns the schedules of a product ordered by operation.
+func (r *PostgresAccountRepository) ListFeeSchedules(productCode string) ([]model.FeeSchedule, error) {
+	rows, err := r.db.Query(`SELECT `+feeScheduleColumns+` FROM fee_schedules WHERE product_code = $1 ORDER BY operation`, productCode)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	schedules := []model.FeeSchedule{}
+	for rows.Next() {
+		s, err := scanFeeSchedule(rows)
+		if err != nil {
+			return nil, err
+		}
+		schedules = append(schedules, *s)
+	}
+	return schedules, rows.Err()
+}
+
+// GetFeeSchedule returns the schedule of op for a product, or nil when
+// there is none.
+func (r *PostgresAccountRepository) GetFeeSchedule(productCode string, op model.FeeOperation) (*model.FeeSchedule, error) {
+	return feeSchedule(r.db, productCode, op)
+}
+
+// SaveFeeSchedule creates or replaces the schedule of s.Operation for
+// s.ProductCode.
+func (r *PostgresAccountRepository) SaveFeeSchedule(s *model.FeeSchedule) (*model.FeeSchedule, error) {
+	err := r.db.QueryRow(`INSERT INTO fee_schedules (product_code, operation, flat, percentage, min_fee, max_fee) VALUES ($1, $2, $3, $4, $5, $6)
+	                      ON CONFLICT (product_code, operation) DO UPDATE SET flat = EXCLUDED.flat, percentage = EXCLUDED.percentage,
+	                          min_fee = EXCLUDED.min_fee, max_fee = EXCLUDED.max_fee, updated_at = NOW()
+	                      RETURNING updated_at`,
+		s.ProductCode, s.Operation, s.Flat, s.Percentage, s.MinFee, s.MaxFee).Scan(&s.UpdatedAt)
+	if err != nil {
+		return nil, fmt.Errorf("could not save fee schedule: %w", err)
+	}
+	return s, nil
+}
+
+// DeleteFeeSchedule stops a product charging for op. It reports whether
+// there was a schedule to delete.
+func (r *PostgresAccountRepository) DeleteFeeSchedule(productCode string, op model.FeeOperation) (bool, error) {
+	res, err := r.db.Exec(`DELETE FROM fee_schedules WHERE product_code = $1 AND operation = $2`, productCode, op)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+// MaintenanceFeeAccounts returns the accounts that are not closed, were
+// opened before the end of month and whose product charges a maintenance
+// fee.
+func (r *PostgresAccountRepository) MaintenanceFeeAccounts(month time.Time) ([]uuid.UUID, error) {
+	rows, err := r.db.Query(`SELECT a.id FROM accounts a
+	                         JOIN fee_schedules f ON f.product_code = a.product_code AND f.operation = $1
+	                         WHERE a.status <> $2 AND a.created_at < $3 ORDER BY a.id`, model.FeeMaintenance, model.AccountClosed, month.AddDate(0, 1, 0))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []uuid.UUID
+	for rows.Next() {
+		var id uuid.UUID
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
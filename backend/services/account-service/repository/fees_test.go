@@ -0,0 +1,214 @@
+package repository
+
+import (
+	"testing"
+	"time"
+
+	"go-web-server/services/account-service/model"
+	"go-web-server/services/account-service/money"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var feeScheduleRowColumns = []string{"product_code", "operation", "flat", "percentage", "min_fee", "max_fee", "updated_at"}
+
+// expectNoFee expects an operation to find that its product does not charge
+// for it.
+func expectNoFee(mock sqlmock.Sqlmock, productCode string, op model.FeeOperation) {
+	mock.ExpectQuery("SELECT (.+) FROM fee_schedules WHERE product_code = (.+) AND operation =").
+		WithArgs(productCode, op).
+		WillReturnRows(sqlmock.NewRows(feeScheduleRowColumns))
+}
+
+// expectFee expects an operation to read its product's schedule for it.
+func expectFee(mock sqlmock.Sqlmock, productCode string, op model.FeeOperation, flat string, percentage interface{}, minFee, maxFee string) {
+	mock.ExpectQuery("SELECT (.+) FROM fee_schedules WHERE product_code = (.+) AND operation =").
+		WithArgs(productCode, op).
+		WillReturnRows(sqlmock.NewRows(feeScheduleRowColumns).AddRow(productCode, op, flat, percentage, minFee, maxFee, time.Now()))
+}
+
+func TestInTx_PostFee(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("error opening mock db: %s", err)
+	}
+	defer db.Close()
+
+	repo := NewPostgresAccountRepository(db)
+	accountID := uuid.New()
+	withdrawal := &model.LedgerEntry{ID: uuid.New()}
+	transferRef := uuid.New()
+	transfer := &model.LedgerEntry{ID: uuid.New(), ReferenceID: &transferRef}
+
+	mock.ExpectBegin()
+	expectLockAccount(mock, accountID, "50.0000", "0", "0")
+	expectChainHead(mock, accountID.String(), 3, time.Now())
+	mock.ExpectExec("UPDATE accounts SET balance = (.+) WHERE id =").
+		WithArgs("45.00", int64(4), sqlmock.AnyArg(), accountID.String()).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec("INSERT INTO ledger_entries").
+		WithArgs(sqlmock.AnyArg(), accountID.String(), model.Fee, "-5.00", "45.00", &withdrawal.ID, "Withdrawal fee", nil, sqlmock.AnyArg(), int64(4), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), nil).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	// The fee joins the journal of the withdrawal: Dr customer deposits, Cr fee income.
+	mock.ExpectExec("INSERT INTO journal_lines").
+		WithArgs(
+			withdrawal.ID, model.GLCustomerDeposits, accountID, sqlmock.AnyArg(), money.PLN, "5.00", "0.00",
+			withdrawal.ID, model.GLFeeIncome, nil, sqlmock.AnyArg(), money.PLN, "0.00", "5.00",
+		).
+		WillReturnResult(sqlmock.NewResult(0, 2))
+	// A transfer fee takes the transfer's reference.
+	expectChainHead(mock, accountID.String(), 4, time.Now())
+	mock.ExpectExec("UPDATE accounts SET balance = (.+) WHERE id =").
+		WithArgs("44.00", int64(5), sqlmock.AnyArg(), accountID.String()).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec("INSERT INTO ledger_entries").
+		WithArgs(sqlmock.AnyArg(), accountID.String(), model.Fee, "-1.00", "44.00", &transferRef, "Transfer fee", nil, sqlmock.AnyArg(), int64(5), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), nil).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec("INSERT INTO journal_lines").
+		WithArgs(
+			transferRef, model.GLCustomerDeposits, accountID, sqlmock.AnyArg(), money.PLN, "1.00", "0.00",
+			transferRef, model.GLFeeIncome, nil, sqlmock.AnyArg(), money.PLN, "0.00", "1.00",
+		).
+		WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectCommit()
+
+	var fee *model.LedgerEntry
+	err = repo.InTx(nil, &fee, func(uow UnitOfWork) error {
+		acc, err := uow.LockAccount(accountID.String())
+		if err != nil {
+			return err
+		}
+		fee, err = uow.PostFee(acc, money.New(money.MustParseAmount("5"), money.PLN), withdrawal, "Withdrawal fee")
+		if err != nil {
+			return err
+		}
+		assert.Equal(t, withdrawal.ID, *fee.ReferenceID)
+		fee, err = uow.PostFee(acc, money.New(money.MustParseAmount("1"), money.PLN), transfer, "Transfer fee")
+		assert.Equal(t, money.MustParseAmount("44"), acc.Balance)
+		return err
+	})
+	require.NoError(t, err)
+	assert.Equal(t, transferRef, *fee.ReferenceID)
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestSaveFeeSchedule(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("error opening mock db: %s", err)
+	}
+	defer db.Close()
+
+	repo := NewPostgresAccountRepository(db)
+	now := time.Now()
+	pct := money.MustParseRate("0.005")
+	s := &model.FeeSchedule{ProductCode: "current", Operation: model.FeeTransfer, Percentage: &pct, MinFee: money.MustParseAmount("1")}
+
+	mock.ExpectQuery("INSERT INTO fee_schedules (.+) ON CONFLICT \\(product_code, operation\\) DO UPDATE").
+		WithArgs("current", model.FeeTransfer, "0.00", "0.0050", "1.00", "0.00").
+		WillReturnRows(sqlmock.NewRows([]string{"updated_at"}).AddRow(now))
+	mock.ExpectExec("DELETE FROM fee_schedules WHERE product_code = (.+) AND operation =").
+		WithArgs("current", model.FeeMaintenance).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	saved, err := repo.SaveFeeSchedule(s)
+	require.NoError(t, err)
+	assert.Equal(t, now, saved.UpdatedAt)
+
+	deleted, err := repo.DeleteFeeSchedule("current", model.FeeMaintenance)
+	assert.NoError(t, err)
+	assert.False(t, deleted)
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestInTx_MaintenanceFee(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("error opening mock db: %s", err)
+	}
+	defer db.Close()
+
+	repo := NewPostgresAccountRepository(db)
+	accountID := uuid.New()
+	month := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+
+	mock.ExpectBegin()
+	expectLockAccount(mock, accountID, "3.0000", "0", "0")
+	mock.ExpectQuery("SELECT EXISTS \\(SELECT 1 FROM maintenance_fee_charges").
+		WithArgs(accountID, "2024-03-01").
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(false))
+	// A fee charged on its own may overdraw the account and is its own journal.
+	expectChainHead(mock, accountID.String(), 7, time.Now())
+	mock.ExpectExec("UPDATE accounts SET balance = (.+) WHERE id =").
+		WithArgs("-2.00", int64(8), sqlmock.AnyArg(), accountID.String()).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec("INSERT INTO ledger_entries").
+		WithArgs(sqlmock.AnyArg(), accountID.String(), model.Fee, "-5.00", "-2.00", nil, "Maintenance fee for 2024-03", nil, sqlmock.AnyArg(), int64(8), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), nil).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec("INSERT INTO journal_lines").
+		WithArgs(
+			sqlmock.AnyArg(), model.GLCustomerDeposits, accountID, sqlmock.AnyArg(), money.PLN, "5.00", "0.00",
+			sqlmock.AnyArg(), model.GLFeeIncome, nil, sqlmock.AnyArg(), money.PLN, "0.00", "5.00",
+		).
+		WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectExec("INSERT INTO maintenance_fee_charges").
+		WithArgs(accountID, "2024-03-01", "5.00", sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	var entry *model.LedgerEntry
+	err = repo.InTx(nil, &entry, func(uow UnitOfWork) error {
+		acc, err := uow.LockAccount(accountID.String())
+		if err != nil {
+			return err
+		}
+		charged, err := uow.MaintenanceFeeCharged(acc, month)
+		if err != nil || charged {
+			return err
+		}
+		entry, err = uow.PostFee(acc, money.New(money.MustParseAmount("5"), money.PLN), nil, "Maintenance fee for 2024-03")
+		if err != nil {
+			return err
+		}
+		return uow.RecordMaintenanceFee(entry, month)
+	})
+	require.NoError(t, err)
+	assert.Equal(t, money.MustParseAmount("-5"), entry.Amount)
+	assert.Nil(t, entry.ReferenceID)
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestMaintenanceFeeAccounts_OpenedBeforeMonthEnd(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("error opening mock db: %s", err)
+	}
+	defer db.Close()
+
+	repo := NewPostgresAccountRepository(db)
+	id := uuid.New()
+
+	mock.ExpectQuery("SELECT a.id FROM accounts a (.+) WHERE a.status <> (.+) AND a.created_at < ").
+		WithArgs(model.FeeMaintenance, model.AccountClosed, time.Date(2024, 4, 1, 0, 0, 0, 0, time.UTC)).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(id))
+
+	ids, err := repo.MaintenanceFeeAccounts(time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC))
+	require.NoError(t, err)
+	assert.Equal(t, []uuid.UUID{id}, ids)
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
@@ -0,0 +1,97 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+
+	"go-web-server/services/account-service/model"
+	"go-web-server/services/account-service/money"
+)
+
+var (
+	// ErrQuoteExpired is returned when a quote is executed after its
+	// expires_at.
+	ErrQuoteExpired = errors.New("fx quote expired")
+	// ErrQuoteExecuted is returned when a quote is executed a second time.
+	ErrQuoteExecuted = errors.New("fx quote already executed")
+)
+
+// SourceAdmin marks rates stored in the fx_rates table.
+const SourceAdmin = "admin"
+
+// GetFXRate returns the admin-set rate of a pair, or nil when there is none.
+func (r *PostgresAccountRepository) GetFXRate(base, quote money.Currency) (*model.FXRate, error) {
+	rate := model.FXRate{Base: base, Quote: quote, Source: SourceAdmin}
+	err := r.db.QueryRow(`SELECT bid, ask, updated_by, updated_at FROM fx_rates WHERE base_currency = $1 AND quote_currency = $2`, base, quote).
+		Scan(&rate.Bid, &rate.Ask, &rate.UpdatedBy, &rate.UpdatedAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &rate, nil
+}
+
+// ListFXRates returns every admin-set rate ordered by pair.
+func (r *PostgresAccountRepository) ListFXRates() ([]model.FXRate, error) {
+	rows, err := r.db.Query(`SELECT base_currency, quote_currency, bid, ask, updated_by, updated_at FROM fx_rates ORDER BY base_currency, quote_currency`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	rates := []model.FXRate{}
+	for rows.Next() {
+		rate := model.FXRate{Source: SourceAdmin}
+		if err := rows.Scan(&rate.Base, &rate.Quote, &rate.Bid, &rate.Ask, &rate.UpdatedBy, &rate.UpdatedAt); err != nil {
+			return nil, err
+		}
+		rates = append(rates, rate)
+	}
+	return rates, rows.Err()
+}
+
+// SetFXRate creates or replaces the admin-set rate of rate's pair.
+func (r *PostgresAccountRepository) SetFXRate(rate model.FXRate) (*model.FXRate, error) {
+	rate.Source = SourceAdmin
+	err := r.db.QueryRow(`INSERT INTO fx_rates (base_currency, quote_currency, bid, ask, updated_by) VALUES ($1, $2, $3, $4, $5)
+	                      ON CONFLICT (base_currency, quote_currency) DO UPDATE SET bid = EXCLUDED.bid, ask = EXCLUDED.ask, updated_by = EXCLUDED.updated_by, updated_at = NOW()
+	                      RETURNING updated_at`,
+		rate.Base, rate.Quote, rate.Bid, rate.Ask, rate.UpdatedBy).Scan(&rate.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &rate, nil
+}
+
+const fxQuoteColumns = `id, from_account_id, to_account_id, sell_amount, sell_currency, buy_amount, buy_currency, rate, expires_at, executed_at, reference_id, created_at`
+
+func scanFXQuote(row rowScanner, extra ...interface{}) (*model.FXQuote, error) {
+	var q model.FXQuote
+	dest := []interface{}{
+		&q.ID, &q.FromAccountID, &q.ToAccountID, &q.SellAmount, &q.SellCurrency, &q.BuyAmount, &q.BuyCurrency,
+		&q.Rate, &q.ExpiresAt, &q.ExecutedAt, &q.ReferenceID, &q.CreatedAt,
+	}
+	if err := row.Scan(append(dest, extra...)...); err != nil {
+		return nil, err
+	}
+	return &q, nil
+}
+
+func (r *PostgresAccountRepository) CreateFXQuote(q *model.FXQuote) error {
+	return r.db.QueryRow(`INSERT INTO fx_quotes (id, from_account_id, to_account_id, sell_amount, sell_currency, buy_amount, buy_currency, rate, expires_at)
+	                      VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9) RETURNING created_at`,
+		q.ID, q.FromAccountID, q.ToAccountID, q.SellAmount, q.SellCurrency, q.BuyAmount, q.BuyCurrency, q.Rate, q.ExpiresAt).Scan(&q.CreatedAt)
+}
+
+func (r *PostgresAccountRepository) GetFXQuote(id string) (*model.FXQuote, error) {
+	q, err := scanFXQuote(r.db.QueryRow(`SELECT `+fxQuoteColumns+` FROM fx_quotes WHERE id = $1`, id))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return q, nil
+}
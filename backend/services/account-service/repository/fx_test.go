@@ -0,0 +1,164 @@
+package repository
+
+import (
+	"testing"
+	"time"
+
+	"go-web-server/services/account-service/model"
+	"go-web-server/services/account-service/money"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var fxQuoteRowColumns = []string{
+	"id", "from_account_id", "to_account_id", "sell_amount", "sell_currency", "buy_amount", "buy_currency",
+	"rate", "expires_at", "executed_at", "reference_id", "created_at", "expired",
+}
+
+// expectLockCurrencyAccount expects a unit of work to lock an account in
+// currency without holds or an overdraft limit.
+func expectLockCurrencyAccount(mock sqlmock.Sqlmock, id uuid.UUID, currency money.Currency, balance string) {
+	mock.ExpectQuery("SELECT id, customer_id, (.+) FROM accounts WHERE id = (.+) FOR UPDATE").
+		WithArgs(id.String()).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "customer_id", "account_number", "currency", "balance", "status", "created_at", "updated_at", "overdraft_limit", "kind", "accrued_interest", "product_code", "held"}).
+			AddRow(id, uuid.New(), "PL0000000001", currency, balance, "active", time.Now(), time.Now(), "0", "current", "0", "current", "0"))
+}
+
+func TestInTx_BookExchangeWithRate(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("error opening mock db: %s", err)
+	}
+	defer db.Close()
+
+	repo := NewPostgresAccountRepository(db)
+	quoteID := uuid.New()
+	pln := uuid.MustParse("00000000-0000-0000-0000-000000000001")
+	eur := uuid.MustParse("00000000-0000-0000-0000-000000000002")
+	now := time.Now()
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT (.+) FROM fx_quotes WHERE id = (.+) FOR UPDATE").
+		WithArgs(quoteID).
+		WillReturnRows(sqlmock.NewRows(fxQuoteRowColumns).
+			AddRow(quoteID.String(), pln.String(), eur.String(), "100.0000", "PLN", "22.9800", "EUR", "0.22983222", now.Add(time.Minute), nil, nil, now, false))
+	expectLockCurrencyAccount(mock, pln, money.PLN, "150.0000")
+	expectLockCurrencyAccount(mock, eur, money.EUR, "1.0000")
+
+	expectChainHead(mock, pln.String(), 0, now)
+	mock.ExpectExec("UPDATE accounts SET balance").
+		WithArgs("50.00", int64(1), sqlmock.AnyArg(), pln.String()).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec("INSERT INTO ledger_entries").
+		WithArgs(sqlmock.AnyArg(), pln.String(), model.FXExchange, "-100.00", "50.00", sqlmock.AnyArg(), sqlmock.AnyArg(), "0.22983222", now, int64(1), "", sqlmock.AnyArg(), sqlmock.AnyArg(), nil).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	expectChainHead(mock, eur.String(), 0, now)
+	mock.ExpectExec("UPDATE accounts SET balance").
+		WithArgs("23.98", int64(1), sqlmock.AnyArg(), eur.String()).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec("INSERT INTO ledger_entries").
+		WithArgs(sqlmock.AnyArg(), eur.String(), model.FXExchange, "22.98", "23.98", sqlmock.AnyArg(), sqlmock.AnyArg(), "0.22983222", now, int64(1), "", sqlmock.AnyArg(), sqlmock.AnyArg(), nil).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	// Each currency balances against the bank's FX position.
+	mock.ExpectExec("INSERT INTO journal_lines").
+		WithArgs(
+			sqlmock.AnyArg(), model.GLCustomerDeposits, pln, sqlmock.AnyArg(), money.PLN, "100.00", "0.00",
+			sqlmock.AnyArg(), model.GLFXPosition, nil, sqlmock.AnyArg(), money.PLN, "0.00", "100.00",
+			sqlmock.AnyArg(), model.GLCustomerDeposits, eur, sqlmock.AnyArg(), money.EUR, "0.00", "22.98",
+			sqlmock.AnyArg(), model.GLFXPosition, nil, sqlmock.AnyArg(), money.EUR, "22.98", "0.00",
+		).
+		WillReturnResult(sqlmock.NewResult(0, 4))
+	mock.ExpectExec("UPDATE fx_quotes SET executed_at").
+		WithArgs(quoteID, now, sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+
+	var exchange *model.CurrencyExchange
+	err = repo.InTx(nil, &exchange, func(uow UnitOfWork) error {
+		q, err := uow.LockFXQuote(quoteID)
+		if err != nil {
+			return err
+		}
+		locked, err := uow.LockAccounts(q.FromAccountID, q.ToAccountID)
+		if err != nil {
+			return err
+		}
+		exchange, err = uow.BookExchange(locked[pln], locked[eur], q)
+		if err != nil {
+			return err
+		}
+		assert.Equal(t, exchange.ReferenceID, *q.ReferenceID)
+		assert.Equal(t, money.MustParseAmount("50"), locked[pln].AvailableBalance)
+		return nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, exchange.ReferenceID, *exchange.Debit.ReferenceID)
+	assert.Equal(t, exchange.ReferenceID, *exchange.Credit.ReferenceID)
+	assert.Equal(t, money.MustParseRate("0.22983222"), *exchange.Credit.FXRate)
+	assert.Equal(t, money.MustParseAmount("23.98"), exchange.Credit.BalanceAfter)
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestInTx_LockFXQuoteRejectsExpiredAndExecutedQuotes(t *testing.T) {
+	executedAt := time.Now()
+	for _, tc := range []struct {
+		name       string
+		executedAt interface{}
+		expired    bool
+		want       error
+	}{
+		{"expired", nil, true, ErrQuoteExpired},
+		{"executed", executedAt, false, ErrQuoteExecuted},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			if err != nil {
+				t.Fatalf("error opening mock db: %s", err)
+			}
+			defer db.Close()
+
+			repo := NewPostgresAccountRepository(db)
+			quoteID := uuid.New()
+
+			mock.ExpectBegin()
+			mock.ExpectQuery("SELECT (.+) FROM fx_quotes WHERE id = (.+) FOR UPDATE").
+				WithArgs(quoteID).
+				WillReturnRows(sqlmock.NewRows(fxQuoteRowColumns).
+					AddRow(quoteID.String(), uuid.NewString(), uuid.NewString(), "100.0000", "PLN", "22.9800", "EUR", "0.22983222", time.Now(), tc.executedAt, nil, time.Now(), tc.expired))
+			mock.ExpectRollback()
+
+			err = repo.InTx(nil, nil, func(uow UnitOfWork) error {
+				_, err := uow.LockFXQuote(quoteID)
+				return err
+			})
+			assert.ErrorIs(t, err, tc.want)
+
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Errorf("there were unfulfilled expectations: %s", err)
+			}
+		})
+	}
+}
+
+func TestGetFXRate_Missing(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("error opening mock db: %s", err)
+	}
+	defer db.Close()
+
+	repo := NewPostgresAccountRepository(db)
+	mock.ExpectQuery("SELECT bid, ask, updated_by, updated_at FROM fx_rates").
+		WithArgs(money.EUR, money.PLN).
+		WillReturnRows(sqlmock.NewRows([]string{"bid", "ask", "updated_by", "updated_at"}))
+
+	rate, err := repo.GetFXRate(money.EUR, money.PLN)
+	assert.NoError(t, err)
+	assert.Nil(t, rate)
+}
@@ -0,0 +1,163 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"go-web-server/services/account-service/model"
+	"go-web-server/services/account-service/money"
+
+	"github.com/google/uuid"
+)
+
+// ErrUnbalancedJournal is returned when the debits and credits of a posting
+// differ in some currency. It means a posting rule is wrong; the balance
+// change it belongs to is rolled back with it.
+var ErrUnbalancedJournal = errors.New("unbalanced journal")
+
+// Every balance change is mirrored in the general ledger in the same
+// transaction. Customer accounts are a liability of the bank, so money
+// arriving on one is credited to customer_deposits and money leaving it is
+// debited; the posting rules below choose the other side:
+//
+//	deposit, withdrawal         cash
+//	transfer                    customer_deposits of the other account
+//	fx_exchange                 fx_position, once in each currency
+//	any other single entry      suspense, to be cleared by hand
+
+// customerLine is the customer_deposits line of a ledger entry.
+func customerLine(e *model.LedgerEntry, currency money.Currency) model.JournalLine {
+	line := model.JournalLine{
+		GLAccount:     model.GLCustomerDeposits,
+		AccountID:     &e.AccountID,
+		LedgerEntryID: &e.ID,
+		Currency:      currency,
+	}
+	if e.Amount.IsNegative() {
+		line.Debit = e.Amount.Neg()
+	} else {
+		line.Credit = e.Amount
+	}
+	return line
+}
+
+// contraLine offsets line on the GL account gl.
+func contraLine(line model.JournalLine, gl model.GLAccountCode) model.JournalLine {
+	return model.JournalLine{
+		GLAccount:     gl,
+		LedgerEntryID: line.LedgerEntryID,
+		Currency:      line.Currency,
+		Debit:         line.Credit,
+		Credit:        line.Debit,
+	}
+}
+
+// contraAccount returns the GL account that offsets a single-sided balance
+// change of the given type.
+func contraAccount(t model.LedgerEntryType) model.GLAccountCode {
+	switch t {
+	case model.Deposit, model.Withdrawal, model.HoldCapture:
+		return model.GLCash
+	case model.OverdraftInterest:
+		return model.GLInterestIncome
+	case model.Interest:
+		return model.GLInterestExpense
+	case model.Fee:
+		return model.GLFeeIncome
+	}
+	return model.GLSuspense
+}
+
+// postJournal checks that lines balance in every currency and inserts them
+// under journalID. Lines without an amount are skipped.
+func postJournal(tx *sql.Tx, journalID uuid.UUID, lines ...model.JournalLine) error {
+	net := make(map[money.Currency]money.Amount)
+	for _, l := range lines {
+		debited, err := net[l.Currency].Add(l.Debit)
+		if err != nil {
+			return err
+		}
+		if net[l.Currency], err = debited.Sub(l.Credit); err != nil {
+			return err
+		}
+	}
+	for currency, diff := range net {
+		if !diff.IsZero() {
+			return fmt.Errorf("%w: journal %s is off by %s %s", ErrUnbalancedJournal, journalID, diff, currency)
+		}
+	}
+
+	var (
+		rows []string
+		args []interface{}
+	)
+	for _, l := range lines {
+		if l.Debit.IsZero() && l.Credit.IsZero() {
+			continue
+		}
+		n := len(args)
+		rows = append(rows, fmt.Sprintf("($%d, $%d, $%d, $%d, $%d, $%d, $%d)", n+1, n+2, n+3, n+4, n+5, n+6, n+7))
+		args = append(args, journalID, l.GLAccount, l.AccountID, l.LedgerEntryID, l.Currency, l.Debit, l.Credit)
+	}
+	if len(rows) == 0 {
+		return nil
+	}
+
+	_, err := tx.Exec(`INSERT INTO journal_lines (journal_id, gl_account, account_id, ledger_entry_id, currency, debit, credit) VALUES `+strings.Join(rows, ", "), args...)
+	if err != nil {
+		return fmt.Errorf("could not post journal: %w", err)
+	}
+	return nil
+}
+
+// ListGLAccounts returns the chart of accounts.
+func (r *PostgresAccountRepository) ListGLAccounts() ([]model.GLAccount, error) {
+	rows, err := r.db.Query(`SELECT code, name, type FROM gl_accounts ORDER BY code`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	accounts := []model.GLAccount{}
+	for rows.Next() {
+		var a model.GLAccount
+		if err := rows.Scan(&a.Code, &a.Name, &a.Type); err != nil {
+			return nil, err
+		}
+		accounts = append(accounts, a)
+	}
+	return accounts, rows.Err()
+}
+
+// TrialBalance totals debits and credits per GL account and currency over
+// the journal lines created before asOf, or over all lines when asOf is nil.
+// Balance is left for the caller to derive.
+func (r *PostgresAccountRepository) TrialBalance(asOf *time.Time) ([]model.TrialBalanceLine, error) {
+	query := `SELECT g.code, g.name, g.type, jl.currency, SUM(jl.debit), SUM(jl.credit)
+	          FROM journal_lines jl JOIN gl_accounts g ON g.code = jl.gl_account`
+	var args []interface{}
+	if asOf != nil {
+		query += ` WHERE jl.created_at < $1`
+		args = append(args, *asOf)
+	}
+	query += ` GROUP BY g.code, g.name, g.type, jl.currency ORDER BY jl.currency, g.code`
+
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	lines := []model.TrialBalanceLine{}
+	for rows.Next() {
+		var l model.TrialBalanceLine
+		if err := rows.Scan(&l.GLAccount, &l.Name, &l.Type, &l.Currency, &l.Debit, &l.Credit); err != nil {
+			return nil, err
+		}
+		lines = append(lines, l)
+	}
+	return lines, rows.Err()
+}
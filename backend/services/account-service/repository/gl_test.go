@@ -0,0 +1,75 @@
+package repository
+
+import (
+	"testing"
+	"time"
+
+	"go-web-server/services/account-service/model"
+	"go-web-server/services/account-service/money"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPostJournal_RejectsUnbalancedLines(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("error opening mock db: %s", err)
+	}
+	defer db.Close()
+
+	mock.ExpectBegin()
+	tx, err := db.Begin()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	entry := &model.LedgerEntry{ID: uuid.New(), AccountID: uuid.New(), Amount: money.MustParseAmount("10")}
+	line := customerLine(entry, money.PLN)
+	contra := contraLine(line, model.GLCash)
+	// Balanced in amount but not in currency.
+	contra.Currency = money.EUR
+
+	err = postJournal(tx, entry.ID, line, contra)
+	assert.ErrorIs(t, err, ErrUnbalancedJournal)
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestContraAccount(t *testing.T) {
+	assert.Equal(t, model.GLCash, contraAccount(model.Deposit))
+	assert.Equal(t, model.GLCash, contraAccount(model.Withdrawal))
+	assert.Equal(t, model.GLSuspense, contraAccount(model.TransferIn))
+	assert.Equal(t, model.GLSuspense, contraAccount(model.FXExchange))
+}
+
+func TestTrialBalance_AsOf(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("error opening mock db: %s", err)
+	}
+	defer db.Close()
+
+	repo := NewPostgresAccountRepository(db)
+	asOf := time.Date(2024, 4, 1, 0, 0, 0, 0, time.UTC)
+
+	mock.ExpectQuery("SELECT g.code, g.name, g.type, jl.currency, SUM\\(jl.debit\\), SUM\\(jl.credit\\) FROM journal_lines jl JOIN gl_accounts g (.+) WHERE jl.created_at < \\$1 GROUP BY").
+		WithArgs(asOf).
+		WillReturnRows(sqlmock.NewRows([]string{"code", "name", "type", "currency", "debit", "credit"}).
+			AddRow("cash", "Cash", "asset", "PLN", "100.0000", "40.0000").
+			AddRow("customer_deposits", "Customer deposits", "liability", "PLN", "40.0000", "100.0000"))
+
+	lines, err := repo.TrialBalance(&asOf)
+	assert.NoError(t, err)
+	assert.Len(t, lines, 2)
+	assert.Equal(t, model.GLCash, lines[0].GLAccount)
+	assert.Equal(t, money.MustParseAmount("100"), lines[0].Debit)
+	assert.Equal(t, model.GLLiability, lines[1].Type)
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
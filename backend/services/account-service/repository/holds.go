@@ -0,0 +1,137 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"go-web-server/services/account-service/model"
+
+	"github.com/google/uuid"
+)
+
+var (
+	// ErrHoldNotActive matches every HoldNotActiveError.
+	ErrHoldNotActive = errors.New("hold is not active")
+	// ErrCaptureExceedsHold is returned when a capture is larger than the
+	// uncaptured rest of the hold.
+	ErrCaptureExceedsHold = errors.New("capture exceeds the remaining hold amount")
+)
+
+// HoldNotActiveError is returned when capturing or releasing a hold that was
+// already captured, released or has expired.
+type HoldNotActiveError struct {
+	HoldID uuid.UUID
+	Status model.HoldStatus
+}
+
+func (e *HoldNotActiveError) Error() string {
+	return fmt.Sprintf("%s: hold %s is %s", ErrHoldNotActive, e.HoldID, e.Status)
+}
+
+func (e *HoldNotActiveError) Is(target error) bool { return target == ErrHoldNotActive }
+
+const holdColumns = `id, account_id, amount, captured, currency, status, COALESCE(description, ''), expires_at, created_at, updated_at`
+
+func scanHold(row rowScanner, extra ...interface{}) (*model.Hold, error) {
+	var h model.Hold
+	dest := append([]interface{}{
+		&h.ID, &h.AccountID, &h.Amount, &h.Captured, &h.Currency, &h.Status, &h.Description, &h.ExpiresAt, &h.CreatedAt, &h.UpdatedAt,
+	}, extra...)
+	if err := row.Scan(dest...); err != nil {
+		return nil, err
+	}
+	return &h, nil
+}
+
+// GetHold returns a hold, or nil when there is none with that id.
+func (r *PostgresAccountRepository) GetHold(id string) (*model.Hold, error) {
+	h, err := scanHold(r.db.QueryRow(`SELECT `+holdColumns+` FROM holds WHERE id = $1`, id))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return h, err
+}
+
+// ListHolds returns up to limit holds of an account, newest first,
+// optionally only those in status.
+func (r *PostgresAccountRepository) ListHolds(accountID string, status model.HoldStatus, limit int) ([]model.Hold, error) {
+	query := `SELECT ` + holdColumns + ` FROM holds WHERE account_id = $1`
+	args := []interface{}{accountID}
+	if status != "" {
+		args = append(args, status)
+		query += fmt.Sprintf(" AND status = $%d", len(args))
+	}
+	args = append(args, limit)
+	query += fmt.Sprintf(" ORDER BY created_at DESC, id DESC LIMIT $%d", len(args))
+
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	holds := []model.Hold{}
+	for rows.Next() {
+		h, err := scanHold(rows)
+		if err != nil {
+			return nil, err
+		}
+		holds = append(holds, *h)
+	}
+	return holds, rows.Err()
+}
+
+// lockHold locks a hold and checks that it is active. A hold past its expiry
+// is reported as expired even before the sweeper marks it.
+func lockHold(tx *sql.Tx, holdID string) (*model.Hold, error) {
+	var expired bool
+	h, err := scanHold(tx.QueryRow(`SELECT `+holdColumns+`, expires_at <= NOW() FROM holds WHERE id = $1 FOR UPDATE`, holdID), &expired)
+	if err != nil {
+		return nil, fmt.Errorf("could not find or lock hold: %w", err)
+	}
+	if h.Status == model.HoldActive && expired {
+		h.Status = model.HoldExpired
+	}
+	if h.Status != model.HoldActive {
+		return nil, &HoldNotActiveError{HoldID: h.ID, Status: h.Status}
+	}
+	return h, nil
+}
+
+// ReleaseHold gives up the uncaptured rest of an active hold.
+func (r *PostgresAccountRepository) ReleaseHold(holdID string) (*model.Hold, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	h, err := lockHold(tx, holdID)
+	if err != nil {
+		return nil, err
+	}
+	h.Status = model.HoldReleased
+	err = tx.QueryRow(`UPDATE holds SET status = $1, updated_at = NOW() WHERE id = $2 RETURNING updated_at`, h.Status, h.ID).Scan(&h.UpdatedAt)
+	if err != nil {
+		return nil, fmt.Errorf("could not release hold: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return h, nil
+}
+
+// ExpireHolds marks the active holds past their expiry as expired and
+// returns how many it marked. They stopped reserving funds at their expiry
+// already; this only records it.
+func (r *PostgresAccountRepository) ExpireHolds() (int, error) {
+	res, err := r.db.Exec(`UPDATE holds SET status = $1, updated_at = NOW() WHERE status = $2 AND expires_at <= NOW()`,
+		model.HoldExpired, model.HoldActive)
+	if err != nil {
+		return 0, err
+	}
+	n, err := res.RowsAffected()
+	return int(n), err
+}
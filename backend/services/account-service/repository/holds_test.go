@@ -0,0 +1,181 @@
+package repository
+
+import (
+	"testing"
+	"time"
+
+	"go-web-server/services/account-service/model"
+	"go-web-server/services/account-service/money"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var holdRowColumns = []string{"id", "account_id", "amount", "captured", "currency", "status", "description", "expires_at", "created_at", "updated_at", "expired"}
+
+func TestInTx_PlaceHold(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("error opening mock db: %s", err)
+	}
+	defer db.Close()
+
+	repo := NewPostgresAccountRepository(db)
+	accountID := uuid.New()
+	now := time.Now()
+	hold := &model.Hold{
+		ID: uuid.New(), Amount: money.MustParseAmount("40"), Currency: money.PLN,
+		Description: "Card payment", ExpiresAt: now.Add(time.Hour),
+	}
+
+	mock.ExpectBegin()
+	expectLockAccount(mock, accountID, "100.0000", "50.0000", "0")
+	mock.ExpectQuery("INSERT INTO holds").
+		WithArgs(hold.ID, accountID, "40.00", money.PLN, model.HoldActive, "Card payment", hold.ExpiresAt).
+		WillReturnRows(sqlmock.NewRows([]string{"created_at", "updated_at"}).AddRow(now, now))
+	mock.ExpectCommit()
+
+	err = repo.InTx(nil, &hold, func(uow UnitOfWork) error {
+		acc, err := uow.LockAccount(accountID.String())
+		if err != nil {
+			return err
+		}
+		if err := uow.PlaceHold(acc, hold); err != nil {
+			return err
+		}
+		// A later debit in the same unit of work sees the hold.
+		assert.Equal(t, money.MustParseAmount("10"), acc.AvailableBalance)
+		return nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, accountID, hold.AccountID)
+	assert.Equal(t, model.HoldActive, hold.Status)
+	assert.Equal(t, now, hold.CreatedAt)
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestInTx_CaptureHoldPartial(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("error opening mock db: %s", err)
+	}
+	defer db.Close()
+
+	repo := NewPostgresAccountRepository(db)
+	accountID, holdID := uuid.New(), uuid.New()
+	now := time.Now()
+
+	mock.ExpectBegin()
+	expectLockAccount(mock, accountID, "100.0000", "40.0000", "0")
+	mock.ExpectQuery("SELECT (.+) FROM holds WHERE id = (.+) FOR UPDATE").
+		WithArgs(holdID.String()).
+		WillReturnRows(sqlmock.NewRows(holdRowColumns).
+			AddRow(holdID.String(), accountID.String(), "40.0000", "0.0000", "PLN", "active", "Card payment", now.Add(time.Hour), now, now, false))
+	expectChainHead(mock, accountID.String(), 0, now)
+	mock.ExpectExec("UPDATE accounts SET balance").
+		WithArgs("75.00", int64(1), sqlmock.AnyArg(), accountID.String()).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec("INSERT INTO ledger_entries").
+		WithArgs(sqlmock.AnyArg(), accountID.String(), model.HoldCapture, "-25.00", "75.00", &holdID, "Card payment", nil, now, int64(1), "", sqlmock.AnyArg(), sqlmock.AnyArg(), nil).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec("INSERT INTO journal_lines").
+		WithArgs(
+			sqlmock.AnyArg(), model.GLCustomerDeposits, accountID, sqlmock.AnyArg(), money.PLN, "25.00", "0.00",
+			sqlmock.AnyArg(), model.GLCash, nil, sqlmock.AnyArg(), money.PLN, "0.00", "25.00",
+		).
+		WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectQuery("UPDATE holds SET captured = (.+), status = (.+) WHERE id =").
+		WithArgs("25.00", model.HoldActive, holdID).
+		WillReturnRows(sqlmock.NewRows([]string{"updated_at"}).AddRow(now))
+	mock.ExpectCommit()
+
+	var h *model.Hold
+	var entry *model.LedgerEntry
+	err = repo.InTx(nil, nil, func(uow UnitOfWork) error {
+		acc, err := uow.LockAccount(accountID.String())
+		if err != nil {
+			return err
+		}
+		if h, err = uow.LockHold(holdID); err != nil {
+			return err
+		}
+		entry, err = uow.CaptureHold(acc, h, money.New(money.MustParseAmount("25"), money.PLN), false)
+		if err != nil {
+			return err
+		}
+		// The captured part leaves the balance and stops being held.
+		assert.Equal(t, money.MustParseAmount("15"), acc.HeldAmount)
+		assert.Equal(t, money.MustParseAmount("60"), acc.AvailableBalance)
+		return nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, model.HoldActive, h.Status)
+	remaining, err := h.Remaining()
+	require.NoError(t, err)
+	assert.Equal(t, money.MustParseAmount("15"), remaining)
+	assert.Equal(t, holdID, *entry.ReferenceID)
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestInTx_LockHoldRejectsSettledHolds(t *testing.T) {
+	cases := map[string]struct {
+		status  string
+		expired bool
+	}{
+		"already released": {"released", false},
+		"expired unswept":  {"active", true},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			if err != nil {
+				t.Fatalf("error opening mock db: %s", err)
+			}
+			defer db.Close()
+
+			repo := NewPostgresAccountRepository(db)
+			accountID, holdID := uuid.New(), uuid.New()
+			now := time.Now()
+
+			mock.ExpectBegin()
+			mock.ExpectQuery("SELECT (.+) FROM holds WHERE id = (.+) FOR UPDATE").
+				WillReturnRows(sqlmock.NewRows(holdRowColumns).
+					AddRow(holdID.String(), accountID.String(), "40.0000", "0.0000", "PLN", tc.status, "", now, now, now, tc.expired))
+			mock.ExpectRollback()
+
+			err = repo.InTx(nil, nil, func(uow UnitOfWork) error {
+				_, err := uow.LockHold(holdID)
+				return err
+			})
+			assert.ErrorIs(t, err, ErrHoldNotActive)
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Errorf("there were unfulfilled expectations: %s", err)
+			}
+		})
+	}
+}
+
+func TestExpireHolds(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("error opening mock db: %s", err)
+	}
+	defer db.Close()
+
+	repo := NewPostgresAccountRepository(db)
+	mock.ExpectExec("UPDATE holds SET status = (.+) WHERE status = (.+) AND expires_at <= NOW\\(\\)").
+		WithArgs(model.HoldExpired, model.HoldActive).
+		WillReturnResult(sqlmock.NewResult(0, 3))
+
+	n, err := repo.ExpireHolds()
+	assert.NoError(t, err)
+	assert.Equal(t, 3, n)
+}
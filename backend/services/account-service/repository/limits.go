@@ -0,0 +1,280 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"go-web-server/services/account-service/calendar"
+	"go-web-server/services/account-service/model"
+	"go-web-server/services/account-service/money"
+
+	"github.com/google/uuid"
+)
+
+var (
+	// ErrDailyLimitExceeded matches TransactionLimitErrors for debits that
+	// would take the day's withdrawals and outgoing transfers above the
+	// account's daily limit.
+	ErrDailyLimitExceeded = errors.New("daily limit exceeded")
+	// ErrMonthlyLimitExceeded is the same for the calendar month.
+	ErrMonthlyLimitExceeded = errors.New("monthly limit exceeded")
+	// ErrVelocityLimitExceeded matches TransactionLimitErrors for debits
+	// beyond the number an account may make in a day.
+	ErrVelocityLimitExceeded = errors.New("daily transaction count exceeded")
+)
+
+// TransactionLimitError is returned when a withdrawal or outgoing transfer
+// breaks a transaction limit of the account. Limit is
+// ErrWithdrawalLimitExceeded for the per-transaction limit,
+// ErrDailyLimitExceeded, ErrMonthlyLimitExceeded or
+// ErrVelocityLimitExceeded. Used is what the period had used before the
+// debit; MaxTransactions is set for the velocity limit only.
+type TransactionLimitError struct {
+	Limit           error
+	Max             money.Money
+	Used            money.Money
+	Requested       money.Money
+	MaxTransactions int
+	// Customer is set when the limit is the customer's, shared by all
+	// their accounts in the currency.
+	Customer bool
+}
+
+func (e *TransactionLimitError) Error() string {
+	holder := "account"
+	if e.Customer {
+		holder = "customer"
+	}
+	switch e.Limit {
+	case ErrVelocityLimitExceeded:
+		return fmt.Sprintf("%s: %d a day allowed per %s", e.Limit, e.MaxTransactions, holder)
+	case ErrWithdrawalLimitExceeded:
+		return fmt.Sprintf("%s: %s allows %s, requested %s", e.Limit, holder, e.Max, e.Requested)
+	}
+	return fmt.Sprintf("%s: %s allows %s, %s used, requested %s", e.Limit, holder, e.Max, e.Used, e.Requested)
+}
+
+func (e *TransactionLimitError) Unwrap() error { return e.Limit }
+
+// CheckLimits rejects a withdrawal or outgoing transfer of amount that would
+// break limits given what the account has already used.
+func CheckLimits(limits model.TransactionLimits, used model.LimitUsage, amount money.Money) error {
+	cur := amount.Currency
+	if !limits.PerTransaction.IsZero() && amount.Amount.Cmp(limits.PerTransaction) > 0 {
+		return &TransactionLimitError{Limit: ErrWithdrawalLimitExceeded, Max: money.New(limits.PerTransaction, cur), Requested: amount}
+	}
+	if limits.MaxDailyTransactions > 0 && used.DailyTransactions >= limits.MaxDailyTransactions {
+		return &TransactionLimitError{Limit: ErrVelocityLimitExceeded, Requested: amount, MaxTransactions: limits.MaxDailyTransactions}
+	}
+	for _, period := range []struct {
+		limit error
+		max   money.Amount
+		used  money.Amount
+	}{
+		{ErrDailyLimitExceeded, limits.Daily, used.Daily},
+		{ErrMonthlyLimitExceeded, limits.Monthly, used.Monthly},
+	} {
+		if period.max.IsZero() {
+			continue
+		}
+		total, err := period.used.Add(amount.Amount)
+		if err != nil {
+			return err
+		}
+		if total.Cmp(period.max) > 0 {
+			return &TransactionLimitError{
+				Limit: period.limit, Max: money.New(period.max, cur), Used: money.New(period.used, cur), Requested: amount,
+			}
+		}
+	}
+	return nil
+}
+
+const limitOverrideColumns = `account_id, per_transaction, daily_limit, monthly_limit, max_daily_transactions, updated_by, updated_at`
+
+// limitOverride returns the limit override of an account, or nil when it
+// keeps its product's limits.
+func limitOverride(q queryRower, accountID uuid.UUID) (*model.LimitOverride, error) {
+	var o model.LimitOverride
+	err := q.QueryRow(`SELECT `+limitOverrideColumns+` FROM account_limits WHERE account_id = $1`, accountID).
+		Scan(&o.AccountID, &o.PerTransaction, &o.Daily, &o.Monthly, &o.MaxDailyTransactions, &o.UpdatedBy, &o.UpdatedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("could not read account limits: %w", err)
+	}
+	return &o, nil
+}
+
+// usageDays returns day, the date limit usage is counted on, and the first
+// day of its month. Both come from the bank's calendar rather than the
+// database session's time zone.
+func usageDays(day time.Time) (string, string) {
+	return day.Format(calendar.DateLayout), day.AddDate(0, 0, 1-day.Day()).Format(calendar.DateLayout)
+}
+
+// limitUsage returns what an account has used of its limits on day and in
+// its month.
+func limitUsage(q queryRower, accountID uuid.UUID, day time.Time) (model.LimitUsage, error) {
+	var u model.LimitUsage
+	today, month := usageDays(day)
+	err := q.QueryRow(`SELECT COALESCE(SUM(amount) FILTER (WHERE day = $2), 0),
+	                          COALESCE(SUM(amount), 0),
+	                          COALESCE(SUM(transactions) FILTER (WHERE day = $2), 0)
+	                   FROM limit_usage WHERE account_id = $1 AND day >= $3 AND day <= $2`, accountID, today, month).
+		Scan(&u.Daily, &u.Monthly, &u.DailyTransactions)
+	if err != nil {
+		return u, fmt.Errorf("could not read limit usage: %w", err)
+	}
+	return u, nil
+}
+
+const customerLimitsColumns = `customer_id, currency, daily_limit, monthly_limit, max_daily_transactions, updated_by, updated_at`
+
+func scanCustomerLimits(row rowScanner) (*model.CustomerLimits, error) {
+	var l model.CustomerLimits
+	err := row.Scan(&l.CustomerID, &l.Currency, &l.Daily, &l.Monthly, &l.MaxDailyTransactions, &l.UpdatedBy, &l.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &l, nil
+}
+
+// customerLimits returns a customer's limits in currency, or nil when the
+// customer has none. With lock set the row is locked for update, which
+// serialises the debits of all the customer's accounts in the currency.
+func customerLimits(q queryRower, customerID uuid.UUID, currency money.Currency, lock bool) (*model.CustomerLimits, error) {
+	query := `SELECT ` + customerLimitsColumns + ` FROM customer_limits WHERE customer_id = $1 AND currency = $2`
+	if lock {
+		query += ` FOR UPDATE`
+	}
+	l, err := scanCustomerLimits(q.QueryRow(query, customerID, currency))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("could not read customer limits: %w", err)
+	}
+	return l, nil
+}
+
+// customerLimitUsage returns what the accounts of a customer in currency
+// have used of the customer's limits on day and in its month.
+func customerLimitUsage(q queryRower, customerID uuid.UUID, currency money.Currency, day time.Time) (model.LimitUsage, error) {
+	var u model.LimitUsage
+	today, month := usageDays(day)
+	err := q.QueryRow(`SELECT COALESCE(SUM(amount) FILTER (WHERE day = $3), 0),
+	                          COALESCE(SUM(amount), 0),
+	                          COALESCE(SUM(transactions) FILTER (WHERE day = $3), 0)
+	                   FROM customer_limit_usage
+	                   WHERE customer_id = $1 AND currency = $2 AND day >= $4 AND day <= $3`, customerID, currency, today, month).
+		Scan(&u.Daily, &u.Monthly, &u.DailyTransactions)
+	if err != nil {
+		return u, fmt.Errorf("could not read customer limit usage: %w", err)
+	}
+	return u, nil
+}
+
+// GetLimitOverride returns the limit override of an account, or nil when it
+// has none.
+func (r *PostgresAccountRepository) GetLimitOverride(accountID uuid.UUID) (*model.LimitOverride, error) {
+	return limitOverride(r.db, accountID)
+}
+
+// LimitUsage returns what an account has used of its limits today and in
+// the current month.
+func (r *PostgresAccountRepository) LimitUsage(accountID uuid.UUID) (model.LimitUsage, error) {
+	return limitUsage(r.db, accountID, r.calendar.Today(time.Now()))
+}
+
+// SaveLimitOverride replaces the limit override of an account and fills in
+// UpdatedAt. An empty override is deleted, so the account keeps its
+// product's limits.
+func (r *PostgresAccountRepository) SaveLimitOverride(o *model.LimitOverride) (*model.LimitOverride, error) {
+	if o.IsEmpty() {
+		if _, err := r.db.Exec(`DELETE FROM account_limits WHERE account_id = $1`, o.AccountID); err != nil {
+			return nil, fmt.Errorf("could not delete account limits: %w", err)
+		}
+		return o, nil
+	}
+	err := r.db.QueryRow(`INSERT INTO account_limits (account_id, per_transaction, daily_limit, monthly_limit, max_daily_transactions, updated_by)
+	                      VALUES ($1, $2, $3, $4, $5, $6)
+	                      ON CONFLICT (account_id) DO UPDATE SET per_transaction = EXCLUDED.per_transaction,
+	                          daily_limit = EXCLUDED.daily_limit, monthly_limit = EXCLUDED.monthly_limit,
+	                          max_daily_transactions = EXCLUDED.max_daily_transactions, updated_by = EXCLUDED.updated_by, updated_at = NOW()
+	                      RETURNING updated_at`,
+		o.AccountID, o.PerTransaction, o.Daily, o.Monthly, o.MaxDailyTransactions, o.UpdatedBy).Scan(&o.UpdatedAt)
+	if err != nil {
+		return nil, fmt.Errorf("could not save account limits: %w", err)
+	}
+	return o, nil
+}
+
+// GetCustomerLimits returns a customer's limits in currency, or nil when the
+// customer has none.
+func (r *PostgresAccountRepository) GetCustomerLimits(customerID uuid.UUID, currency money.Currency) (*model.CustomerLimits, error) {
+	return customerLimits(r.db, customerID, currency, false)
+}
+
+// ListCustomerLimits returns a customer's limits in every currency it has
+// them in, ordered by currency.
+func (r *PostgresAccountRepository) ListCustomerLimits(customerID uuid.UUID) ([]model.CustomerLimits, error) {
+	rows, err := r.db.Query(`SELECT `+customerLimitsColumns+` FROM customer_limits WHERE customer_id = $1 ORDER BY currency`, customerID)
+	if err != nil {
+		return nil, fmt.Errorf("could not list customer limits: %w", err)
+	}
+	defer rows.Close()
+
+	limits := []model.CustomerLimits{}
+	for rows.Next() {
+		l, err := scanCustomerLimits(rows)
+		if err != nil {
+			return nil, fmt.Errorf("could not scan customer limits: %w", err)
+		}
+		limits = append(limits, *l)
+	}
+	return limits, rows.Err()
+}
+
+// CustomerLimitUsage returns what the accounts of a customer in currency
+// have used of the customer's limits today and in the current month.
+func (r *PostgresAccountRepository) CustomerLimitUsage(customerID uuid.UUID, currency money.Currency) (model.LimitUsage, error) {
+	return customerLimitUsage(r.db, customerID, currency, r.calendar.Today(time.Now()))
+}
+
+// SaveCustomerLimits replaces a customer's limits in l.Currency and fills in
+// UpdatedAt. Empty limits are deleted. It returns nil when the customer does
+// not exist.
+func (r *PostgresAccountRepository) SaveCustomerLimits(l *model.CustomerLimits) (*model.CustomerLimits, error) {
+	if l.IsEmpty() {
+		var exists bool
+		err := r.db.QueryRow(`SELECT EXISTS (SELECT 1 FROM customers WHERE id = $1)`, l.CustomerID).Scan(&exists)
+		if err != nil {
+			return nil, fmt.Errorf("could not read customer: %w", err)
+		}
+		if !exists {
+			return nil, nil
+		}
+		if _, err := r.db.Exec(`DELETE FROM customer_limits WHERE customer_id = $1 AND currency = $2`, l.CustomerID, l.Currency); err != nil {
+			return nil, fmt.Errorf("could not delete customer limits: %w", err)
+		}
+		return l, nil
+	}
+	err := r.db.QueryRow(`INSERT INTO customer_limits (customer_id, currency, daily_limit, monthly_limit, max_daily_transactions, updated_by)
+	                      SELECT id, $2, $3, $4, $5, $6 FROM customers WHERE id = $1
+	                      ON CONFLICT (customer_id, currency) DO UPDATE SET daily_limit = EXCLUDED.daily_limit,
+	                          monthly_limit = EXCLUDED.monthly_limit, max_daily_transactions = EXCLUDED.max_daily_transactions,
+	                          updated_by = EXCLUDED.updated_by, updated_at = NOW()
+	                      RETURNING updated_at`,
+		l.CustomerID, l.Currency, l.Daily, l.Monthly, l.MaxDailyTransactions, l.UpdatedBy).Scan(&l.UpdatedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("could not save customer limits: %w", err)
+	}
+	return l, nil
+}
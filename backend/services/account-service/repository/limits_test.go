@@ -0,0 +1,264 @@
+package repository
+
+import (
+	"testing"
+	"time"
+
+	"go-web-server/services/account-service/calendar"
+	"go-web-server/services/account-service/model"
+	"go-web-server/services/account-service/money"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var limitOverrideRowColumns = []string{"account_id", "per_transaction", "daily_limit", "monthly_limit", "max_daily_transactions", "updated_by", "updated_at"}
+
+var customerLimitsRowColumns = []string{"customer_id", "currency", "daily_limit", "monthly_limit", "max_daily_transactions", "updated_by", "updated_at"}
+
+// usageToday returns today and the first day of this month in repo's
+// calendar, as limit usage is keyed on them.
+func usageToday(repo *PostgresAccountRepository) (string, string) {
+	day := repo.calendar.Today(time.Now())
+	return day.Format(calendar.DateLayout), day.AddDate(0, 0, 1-day.Day()).Format(calendar.DateLayout)
+}
+
+// expectLimitUsage expects a debit to read what its account has used today
+// and this month.
+func expectLimitUsage(mock sqlmock.Sqlmock, repo *PostgresAccountRepository, accountID uuid.UUID, daily, monthly string, transactions int) {
+	today, month := usageToday(repo)
+	mock.ExpectQuery("SELECT (.+) FROM limit_usage WHERE account_id =").
+		WithArgs(accountID, today, month).
+		WillReturnRows(sqlmock.NewRows([]string{"daily", "monthly", "transactions"}).AddRow(daily, monthly, transactions))
+}
+
+func TestCheckLimits(t *testing.T) {
+	limits := model.TransactionLimits{
+		PerTransaction:       money.MustParseAmount("1000"),
+		Daily:                money.MustParseAmount("2000"),
+		Monthly:              money.MustParseAmount("5000"),
+		MaxDailyTransactions: 3,
+	}
+	for _, tc := range []struct {
+		name   string
+		used   model.LimitUsage
+		amount string
+		err    error
+	}{
+		{"within every limit", model.LimitUsage{Daily: money.MustParseAmount("1000"), Monthly: money.MustParseAmount("4000"), DailyTransactions: 2}, "1000", nil},
+		{"above the per-transaction limit", model.LimitUsage{}, "1000.01", ErrWithdrawalLimitExceeded},
+		{"one transaction too many", model.LimitUsage{DailyTransactions: 3}, "1", ErrVelocityLimitExceeded},
+		{"above the daily limit", model.LimitUsage{Daily: money.MustParseAmount("1500"), Monthly: money.MustParseAmount("1500"), DailyTransactions: 1}, "500.01", ErrDailyLimitExceeded},
+		{"above the monthly limit", model.LimitUsage{Monthly: money.MustParseAmount("4500")}, "500.01", ErrMonthlyLimitExceeded},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			err := CheckLimits(limits, tc.used, money.New(money.MustParseAmount(tc.amount), money.PLN))
+			if tc.err == nil {
+				assert.NoError(t, err)
+				return
+			}
+			var limitErr *TransactionLimitError
+			require.ErrorAs(t, err, &limitErr)
+			assert.ErrorIs(t, err, tc.err)
+		})
+	}
+
+	// Zero limits mean none.
+	assert.NoError(t, CheckLimits(model.TransactionLimits{}, model.LimitUsage{DailyTransactions: 1000}, money.New(money.MustParseAmount("1000000"), money.PLN)))
+}
+
+func TestInTx_ConsumeLimits(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("error opening mock db: %s", err)
+	}
+	defer db.Close()
+
+	repo := NewPostgresAccountRepository(db)
+	accountID := uuid.New()
+	product := &model.Product{Code: "current", DailyLimit: money.MustParseAmount("500")}
+
+	mock.ExpectBegin()
+	expectLockAccount(mock, accountID, "5000.0000", "0", "0")
+	// The account's override raises the product's daily limit.
+	mock.ExpectQuery("SELECT (.+) FROM account_limits WHERE account_id =").
+		WithArgs(accountID).
+		WillReturnRows(sqlmock.NewRows(limitOverrideRowColumns).AddRow(accountID, nil, "3000.0000", nil, nil, "admin", time.Now()))
+	expectLimitUsage(mock, repo, accountID, "400.0000", "400.0000", 1)
+	// The customer has no limits of their own, but their usage is kept.
+	mock.ExpectQuery("SELECT (.+) FROM customer_limits WHERE customer_id = (.+) FOR UPDATE").
+		WithArgs(sqlmock.AnyArg(), money.PLN).
+		WillReturnRows(sqlmock.NewRows(customerLimitsRowColumns))
+	today, _ := usageToday(repo)
+	mock.ExpectExec("INSERT INTO limit_usage (.+) ON CONFLICT \\(account_id, day\\) DO UPDATE").
+		WithArgs(accountID, today, "1000.00").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("INSERT INTO customer_limit_usage (.+) ON CONFLICT \\(customer_id, currency, day\\) DO UPDATE").
+		WithArgs(sqlmock.AnyArg(), money.PLN, today, "1000.00").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	// The second debit goes beyond it.
+	mock.ExpectQuery("SELECT (.+) FROM account_limits WHERE account_id =").
+		WithArgs(accountID).
+		WillReturnRows(sqlmock.NewRows(limitOverrideRowColumns).AddRow(accountID, nil, "3000.0000", nil, nil, "admin", time.Now()))
+	expectLimitUsage(mock, repo, accountID, "1400.0000", "1400.0000", 2)
+	mock.ExpectRollback()
+
+	err = repo.InTx(nil, nil, func(uow UnitOfWork) error {
+		acc, err := uow.LockAccount(accountID.String())
+		if err != nil {
+			return err
+		}
+		if err := uow.ConsumeLimits(acc, product, money.New(money.MustParseAmount("1000"), money.PLN)); err != nil {
+			return err
+		}
+		return uow.ConsumeLimits(acc, product, money.New(money.MustParseAmount("1600.01"), money.PLN))
+	})
+	var limitErr *TransactionLimitError
+	require.ErrorAs(t, err, &limitErr)
+	assert.Equal(t, ErrDailyLimitExceeded, limitErr.Limit)
+	assert.Equal(t, money.New(money.MustParseAmount("3000"), money.PLN), limitErr.Max)
+	assert.Equal(t, money.New(money.MustParseAmount("1400"), money.PLN), limitErr.Used)
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestInTx_ConsumeLimitsChecksCustomerLimits(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("error opening mock db: %s", err)
+	}
+	defer db.Close()
+
+	repo := NewPostgresAccountRepository(db)
+	accountID, customerID := uuid.New(), uuid.New()
+
+	mock.ExpectBegin()
+	expectLockAccount(mock, accountID, "5000.0000", "0", "0")
+	mock.ExpectQuery("SELECT (.+) FROM account_limits WHERE account_id =").
+		WithArgs(accountID).
+		WillReturnRows(sqlmock.NewRows(limitOverrideRowColumns))
+	expectLimitUsage(mock, repo, accountID, "0", "0", 0)
+	mock.ExpectQuery("SELECT (.+) FROM customer_limits WHERE customer_id = (.+) FOR UPDATE").
+		WithArgs(customerID, money.PLN).
+		WillReturnRows(sqlmock.NewRows(customerLimitsRowColumns).AddRow(customerID, "PLN", "2000.0000", "0", 0, "admin", time.Now()))
+	// The customer's other accounts already used most of the day's limit.
+	today, month := usageToday(repo)
+	mock.ExpectQuery("SELECT (.+) FROM customer_limit_usage WHERE customer_id =").
+		WithArgs(customerID, money.PLN, today, month).
+		WillReturnRows(sqlmock.NewRows([]string{"daily", "monthly", "transactions"}).AddRow("1500.0000", "1500.0000", 2))
+	mock.ExpectRollback()
+
+	err = repo.InTx(nil, nil, func(uow UnitOfWork) error {
+		acc, err := uow.LockAccount(accountID.String())
+		if err != nil {
+			return err
+		}
+		acc.CustomerID = customerID
+		return uow.ConsumeLimits(acc, &model.Product{Code: "current"}, money.New(money.MustParseAmount("600"), money.PLN))
+	})
+	var limitErr *TransactionLimitError
+	require.ErrorAs(t, err, &limitErr)
+	assert.Equal(t, ErrDailyLimitExceeded, limitErr.Limit)
+	assert.True(t, limitErr.Customer)
+	assert.Equal(t, money.New(money.MustParseAmount("1500"), money.PLN), limitErr.Used)
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestLimitUsage_KeyedOnBusinessDay(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("error opening mock db: %s", err)
+	}
+	defer db.Close()
+
+	accountID, customerID := uuid.New(), uuid.New()
+	day := time.Date(2024, 3, 15, 0, 0, 0, 0, time.UTC)
+
+	mock.ExpectQuery("SELECT (.+) FROM limit_usage WHERE account_id =").
+		WithArgs(accountID, "2024-03-15", "2024-03-01").
+		WillReturnRows(sqlmock.NewRows([]string{"daily", "monthly", "transactions"}).AddRow("100.0000", "700.0000", 1))
+	mock.ExpectQuery("SELECT (.+) FROM customer_limit_usage WHERE customer_id =").
+		WithArgs(customerID, money.PLN, "2024-03-15", "2024-03-01").
+		WillReturnRows(sqlmock.NewRows([]string{"daily", "monthly", "transactions"}).AddRow("0", "0", 0))
+
+	used, err := limitUsage(db, accountID, day)
+	require.NoError(t, err)
+	assert.Equal(t, money.MustParseAmount("700"), used.Monthly)
+	_, err = customerLimitUsage(db, customerID, money.PLN, day)
+	require.NoError(t, err)
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestSaveLimitOverride(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("error opening mock db: %s", err)
+	}
+	defer db.Close()
+
+	repo := NewPostgresAccountRepository(db)
+	accountID := uuid.New()
+	now := time.Now()
+	daily, maxTransactions := money.MustParseAmount("2500"), 10
+
+	mock.ExpectQuery("INSERT INTO account_limits (.+) ON CONFLICT \\(account_id\\) DO UPDATE").
+		WithArgs(accountID, nil, "2500.00", nil, int64(10), "admin").
+		WillReturnRows(sqlmock.NewRows([]string{"updated_at"}).AddRow(now))
+	// Clearing every field returns the account to its product's limits.
+	mock.ExpectExec("DELETE FROM account_limits WHERE account_id =").
+		WithArgs(accountID).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	saved, err := repo.SaveLimitOverride(&model.LimitOverride{AccountID: accountID, Daily: &daily, MaxDailyTransactions: &maxTransactions, UpdatedBy: "admin"})
+	require.NoError(t, err)
+	assert.Equal(t, now, saved.UpdatedAt)
+
+	_, err = repo.SaveLimitOverride(&model.LimitOverride{AccountID: accountID, UpdatedBy: "admin"})
+	assert.NoError(t, err)
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestSaveCustomerLimits(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("error opening mock db: %s", err)
+	}
+	defer db.Close()
+
+	repo := NewPostgresAccountRepository(db)
+	customerID, unknown := uuid.New(), uuid.New()
+	now := time.Now()
+
+	mock.ExpectQuery("INSERT INTO customer_limits (.+) FROM customers WHERE id = (.+) ON CONFLICT \\(customer_id, currency\\) DO UPDATE").
+		WithArgs(customerID, money.PLN, "10000.00", "0.00", int64(0), "admin").
+		WillReturnRows(sqlmock.NewRows([]string{"updated_at"}).AddRow(now))
+	// Nothing is inserted for a customer that does not exist.
+	mock.ExpectQuery("INSERT INTO customer_limits").
+		WithArgs(unknown, money.PLN, "10000.00", "0.00", int64(0), "admin").
+		WillReturnRows(sqlmock.NewRows([]string{"updated_at"}))
+
+	saved, err := repo.SaveCustomerLimits(&model.CustomerLimits{CustomerID: customerID, Currency: money.PLN, Daily: money.MustParseAmount("10000"), UpdatedBy: "admin"})
+	require.NoError(t, err)
+	assert.Equal(t, now, saved.UpdatedAt)
+
+	saved, err = repo.SaveCustomerLimits(&model.CustomerLimits{CustomerID: unknown, Currency: money.PLN, Daily: money.MustParseAmount("10000"), UpdatedBy: "admin"})
+	assert.NoError(t, err)
+	assert.Nil(t, saved)
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
@@ -0,0 +1,67 @@
+package repository
+
+import (
+	"time"
+
+	"go-web-server/services/account-service/calendar"
+	"go-web-server/services/account-service/model"
+
+	"github.com/google/uuid"
+)
+
+// interestOverdraft is the interest_postings kind of overdraft interest.
+const interestOverdraft = "overdraft"
+
+// interestKind returns the interest_postings kind of interest entries of
+// entryType.
+func interestKind(entryType model.LedgerEntryType) string {
+	if entryType == model.OverdraftInterest {
+		return interestOverdraft
+	}
+	return interestSavings
+}
+
+// ListOverdraftLimitChanges returns up to limit changes of an account's
+// overdraft limit, newest first.
+func (r *PostgresAccountRepository) ListOverdraftLimitChanges(accountID string, limit int) ([]model.OverdraftLimitChange, error) {
+	rows, err := r.db.Query(`SELECT id, account_id, old_limit, new_limit, currency, changed_by, COALESCE(note, ''), created_at
+	                         FROM overdraft_limit_changes WHERE account_id = $1 ORDER BY created_at DESC, id DESC LIMIT $2`, accountID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	changes := []model.OverdraftLimitChange{}
+	for rows.Next() {
+		var c model.OverdraftLimitChange
+		if err := rows.Scan(&c.ID, &c.AccountID, &c.OldLimit, &c.NewLimit, &c.Currency, &c.ChangedBy, &c.Note, &c.CreatedAt); err != nil {
+			return nil, err
+		}
+		changes = append(changes, c)
+	}
+	return changes, rows.Err()
+}
+
+// OverdrawnAccounts returns the ids of the accounts that are not closed and
+// may have ended businessDate overdrawn: those with a negative balance and
+// those with entries that take value after the day. The caller checks the
+// end-of-day balance of each.
+func (r *PostgresAccountRepository) OverdrawnAccounts(businessDate time.Time) ([]uuid.UUID, error) {
+	rows, err := r.db.Query(`SELECT id FROM accounts WHERE status <> $1
+		AND (balance < 0 OR id IN (SELECT account_id FROM ledger_entries WHERE value_date > $2)) ORDER BY id`,
+		model.AccountClosed, businessDate.Format(calendar.DateLayout))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ids := []uuid.UUID{}
+	for rows.Next() {
+		var id uuid.UUID
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
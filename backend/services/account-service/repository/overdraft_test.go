@@ -0,0 +1,141 @@
+package repository
+
+import (
+	"testing"
+	"time"
+
+	"go-web-server/services/account-service/model"
+	"go-web-server/services/account-service/money"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInTx_SetOverdraftLimitRecordsChange(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("error opening mock db: %s", err)
+	}
+	defer db.Close()
+
+	repo := NewPostgresAccountRepository(db)
+	accountID := uuid.New()
+	now := time.Now()
+	change := &model.OverdraftLimitChange{ID: uuid.New(), NewLimit: money.MustParseAmount("1000"), ChangedBy: "ops", Note: "Salary account"}
+
+	mock.ExpectBegin()
+	expectLockAccount(mock, accountID, "0", "0", "250.0000")
+	mock.ExpectExec("UPDATE accounts SET overdraft_limit = (.+) WHERE id =").
+		WithArgs("1000.00", accountID).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery("INSERT INTO overdraft_limit_changes").
+		WithArgs(change.ID, accountID, "250.00", "1000.00", money.PLN, "ops", "Salary account").
+		WillReturnRows(sqlmock.NewRows([]string{"created_at"}).AddRow(now))
+	mock.ExpectCommit()
+
+	err = repo.InTx(nil, nil, func(uow UnitOfWork) error {
+		acc, err := uow.LockAccount(accountID.String())
+		if err != nil {
+			return err
+		}
+		if err := uow.SetOverdraftLimit(acc, change); err != nil {
+			return err
+		}
+		assert.Equal(t, money.MustParseAmount("1000"), acc.OverdraftLimit)
+		return nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, accountID, change.AccountID)
+	assert.Equal(t, money.MustParseAmount("250"), change.OldLimit)
+	assert.Equal(t, money.PLN, change.Currency)
+	assert.Equal(t, now, change.CreatedAt)
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestInTx_PostOverdraftInterest(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("error opening mock db: %s", err)
+	}
+	defer db.Close()
+
+	repo := NewPostgresAccountRepository(db)
+	accountID := uuid.New()
+	day := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+
+	mock.ExpectBegin()
+	expectLockAccount(mock, accountID, "-1000.0000", "0", "2000.0000")
+	mock.ExpectQuery("SELECT EXISTS \\(SELECT 1 FROM interest_postings").
+		WithArgs(accountID, "overdraft", "2024-03-01").
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(false))
+	expectChainHead(mock, accountID.String(), 3, time.Now())
+	mock.ExpectExec("UPDATE accounts SET balance").
+		WithArgs("-1000.49", int64(4), sqlmock.AnyArg(), accountID.String()).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec("INSERT INTO ledger_entries").
+		WithArgs(sqlmock.AnyArg(), accountID.String(), model.OverdraftInterest, "-0.49", "-1000.49", nil, "Overdraft interest for 2024-03-01", nil, sqlmock.AnyArg(), int64(4), "previous", sqlmock.AnyArg(), "2024-03-01", nil).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	// Dr customer deposits, Cr interest income
+	mock.ExpectExec("INSERT INTO journal_lines").
+		WithArgs(
+			sqlmock.AnyArg(), model.GLCustomerDeposits, accountID, sqlmock.AnyArg(), money.PLN, "0.49", "0.00",
+			sqlmock.AnyArg(), model.GLInterestIncome, nil, sqlmock.AnyArg(), money.PLN, "0.00", "0.49",
+		).
+		WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectExec("INSERT INTO interest_postings").
+		WithArgs(accountID, "overdraft", "2024-03-01", "-0.49", sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	var entry *model.LedgerEntry
+	err = repo.InTx(nil, nil, func(uow UnitOfWork) error {
+		acc, err := uow.LockAccount(accountID.String())
+		if err != nil {
+			return err
+		}
+		posted, err := uow.InterestPosted(acc, model.OverdraftInterest, day)
+		if err != nil || posted {
+			return err
+		}
+		entry, err = uow.PostInterest(acc, model.OverdraftInterest, money.New(money.MustParseAmount("-0.49"), money.PLN), day, "Overdraft interest for 2024-03-01")
+		return err
+	})
+	require.NoError(t, err)
+	assert.Equal(t, model.OverdraftInterest, entry.Type)
+	assert.Equal(t, money.MustParseAmount("-0.49"), entry.Amount)
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestOverdrawnAccounts_IncludesEntriesValueDatedLater(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("error opening mock db: %s", err)
+	}
+	defer db.Close()
+
+	repo := NewPostgresAccountRepository(db)
+	id := uuid.New()
+	day := time.Date(2024, 3, 14, 0, 0, 0, 0, time.UTC)
+
+	// An account back in credit may still have ended the day overdrawn if
+	// it was credited by entries that take value after it.
+	mock.ExpectQuery("SELECT id FROM accounts WHERE status <> (.+) AND \\(balance < 0 OR id IN \\(SELECT account_id FROM ledger_entries WHERE value_date > (.+)\\)\\)").
+		WithArgs(model.AccountClosed, "2024-03-14").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(id))
+
+	ids, err := repo.OverdrawnAccounts(day)
+	require.NoError(t, err)
+	assert.Equal(t, []uuid.UUID{id}, ids)
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
@@ -1,80 +1,466 @@
 package repository
 
 import (
+	"bytes"
 	"database/sql"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"go-web-server/services/account-service/audit"
+	"go-web-server/services/account-service/calendar"
 	"go-web-server/services/account-service/model"
+	"go-web-server/services/account-service/money"
+	"sort"
+	"time"
 
 	"github.com/google/uuid"
+	"github.com/lib/pq"
 )
 
 type AccountRepository interface {
 	CreateAccount(acc *model.Account) error
 	GetAccount(id string) (*model.Account, error)
-	UpdateBalance(accountID string, amount float64, entryType model.LedgerEntryType, description string) error
+	ListAccounts(filter model.AccountFilter) ([]model.Account, error)
+	GetCustomerByExternalID(externalID string) (*model.Customer, error)
+	InTx(idem *model.IdempotencyKey, out interface{}, fn func(UnitOfWork) error) error
+	GetIdempotencyRecord(owner, key string) (*model.IdempotencyRecord, error)
+	ListLedgerEntries(filter model.LedgerFilter) ([]model.LedgerEntry, error)
+	GetFXRate(base, quote money.Currency) (*model.FXRate, error)
+	ListFXRates() ([]model.FXRate, error)
+	SetFXRate(rate model.FXRate) (*model.FXRate, error)
+	CreateFXQuote(q *model.FXQuote) error
+	GetFXQuote(id string) (*model.FXQuote, error)
+	ListGLAccounts() ([]model.GLAccount, error)
+	TrialBalance(asOf *time.Time) ([]model.TrialBalanceLine, error)
+	LedgerSnapshot() (*model.LedgerSnapshot, error)
+	SaveReconciliationReport(report *model.ReconciliationReport) error
+	GetReconciliationReport(id string) (*model.ReconciliationReport, error)
+	LatestReconciliationReport() (*model.ReconciliationReport, error)
+	SealLegacyLedgerEntries() (int, error)
+	ChainHeads() ([]model.ChainHead, error)
+	LedgerChain(accountID string) (*model.LedgerChain, error)
+	SaveCheckpoint(cp *model.LedgerCheckpoint) error
+	GetCheckpoint(id string) (*model.LedgerCheckpoint, error)
+	LatestCheckpoint() (*model.LedgerCheckpoint, error)
+	ListCheckpoints(limit int) ([]model.LedgerCheckpoint, error)
+	GetHold(id string) (*model.Hold, error)
+	ListHolds(accountID string, status model.HoldStatus, limit int) ([]model.Hold, error)
+	ReleaseHold(holdID string) (*model.Hold, error)
+	ExpireHolds() (int, error)
+	ListOverdraftLimitChanges(accountID string, limit int) ([]model.OverdraftLimitChange, error)
+	OverdrawnAccounts(businessDate time.Time) ([]uuid.UUID, error)
+	SavingsAccounts() ([]uuid.UUID, error)
+	ListProducts() ([]model.Product, error)
+	GetProduct(code string) (*model.Product, error)
+	SaveProduct(p *model.Product) (*model.Product, error)
+	ListFeeSchedules(productCode string) ([]model.FeeSchedule, error)
+	GetFeeSchedule(productCode string, op model.FeeOperation) (*model.FeeSchedule, error)
+	SaveFeeSchedule(s *model.FeeSchedule) (*model.FeeSchedule, error)
+	DeleteFeeSchedule(productCode string, op model.FeeOperation) (bool, error)
+	MaintenanceFeeAccounts(month time.Time) ([]uuid.UUID, error)
+	GetLimitOverride(accountID uuid.UUID) (*model.LimitOverride, error)
+	SaveLimitOverride(o *model.LimitOverride) (*model.LimitOverride, error)
+	LimitUsage(accountID uuid.UUID) (model.LimitUsage, error)
+	GetCustomerLimits(customerID uuid.UUID, currency money.Currency) (*model.CustomerLimits, error)
+	ListCustomerLimits(customerID uuid.UUID) ([]model.CustomerLimits, error)
+	SaveCustomerLimits(l *model.CustomerLimits) (*model.CustomerLimits, error)
+	CustomerLimitUsage(customerID uuid.UUID, currency money.Currency) (model.LimitUsage, error)
+	CreateScheduledTransfer(st *model.ScheduledTransfer, idem *model.IdempotencyKey) (*model.ScheduledTransfer, error)
+	GetScheduledTransfer(id string) (*model.ScheduledTransfer, error)
+	ListScheduledTransfers(accountID string, status model.ScheduledTransferStatus, limit int) ([]model.ScheduledTransfer, error)
+	AmendScheduledTransfer(st *model.ScheduledTransfer) (*model.ScheduledTransfer, error)
+	CancelScheduledTransfer(id string) (*model.ScheduledTransfer, error)
+	DueScheduledTransfers(now time.Time, limit int) ([]uuid.UUID, error)
+	RecordScheduledTransferFailure(st *model.ScheduledTransfer) (*model.ScheduledTransfer, error)
+	CreateStandingOrder(so *model.StandingOrder, idem *model.IdempotencyKey) (*model.StandingOrder, error)
+	GetStandingOrder(id string) (*model.StandingOrder, error)
+	ListStandingOrders(accountID string, limit int) ([]model.StandingOrder, error)
+	ListStandingOrderPayments(standingOrderID string, limit int) ([]model.ScheduledTransfer, error)
+	CancelStandingOrder(id string) (*model.StandingOrder, error)
+	DueStandingOrders(today time.Time, limit int) ([]uuid.UUID, error)
 }
 
+// ErrIdempotencyKeyReused is returned when an Idempotency-Key is presented
+// again with a request that differs from the one it was first used for.
+var ErrIdempotencyKeyReused = errors.New("idempotency key reused with a different request")
+
+var (
+	// ErrAccountNotActive matches every AccountNotActiveError.
+	ErrAccountNotActive = errors.New("account is not active")
+	// ErrInvalidStatusTransition matches every StatusTransitionError.
+	ErrInvalidStatusTransition = errors.New("invalid account status transition")
+	// ErrBalanceNotZero is returned when closing an account that still holds
+	// money and no payout account can take it.
+	ErrBalanceNotZero = errors.New("account balance is not zero")
+	// ErrInsufficientFunds matches every InsufficientFundsError.
+	ErrInsufficientFunds = errors.New("insufficient funds")
+)
+
+// InsufficientFundsError is returned when a debit would take the balance
+// below minus the overdraft limit.
+type InsufficientFundsError struct {
+	Balance        money.Money
+	Requested      money.Money
+	OverdraftLimit money.Money
+}
+
+func (e *InsufficientFundsError) Error() string {
+	if !e.OverdraftLimit.IsZero() {
+		return fmt.Sprintf("%s: current balance %s, overdraft limit %s, requested %s", ErrInsufficientFunds, e.Balance, e.OverdraftLimit, e.Requested)
+	}
+	return fmt.Sprintf("%s: current balance %s, requested %s", ErrInsufficientFunds, e.Balance, e.Requested)
+}
+
+func (e *InsufficientFundsError) Is(target error) bool { return target == ErrInsufficientFunds }
+
+// AccountNotActiveError is returned when a balance change touches a frozen
+// or closed account.
+type AccountNotActiveError struct {
+	AccountID uuid.UUID
+	Status    model.AccountStatus
+}
+
+func (e *AccountNotActiveError) Error() string {
+	return fmt.Sprintf("%s: account %s is %s", ErrAccountNotActive, e.AccountID, e.Status)
+}
+
+func (e *AccountNotActiveError) Is(target error) bool { return target == ErrAccountNotActive }
+
+// StatusTransitionError is returned when the transition table does not allow
+// moving an account from From to To.
+type StatusTransitionError struct {
+	From, To model.AccountStatus
+}
+
+func (e *StatusTransitionError) Error() string {
+	return fmt.Sprintf("%s: %s to %s", ErrInvalidStatusTransition, e.From, e.To)
+}
+
+func (e *StatusTransitionError) Is(target error) bool { return target == ErrInvalidStatusTransition }
+
 type PostgresAccountRepository struct {
 	db *sql.DB
+	// calendar gives the value dates of the ledger entries written.
+	calendar *calendar.Calendar
 }
 
+// NewPostgresAccountRepository returns a repository that dates ledger
+// entries by the calendar of calendar.DefaultCountry.
 func NewPostgresAccountRepository(db *sql.DB) *PostgresAccountRepository {
-	return &PostgresAccountRepository{db: db}
+	cal, _ := calendar.Default().Get(calendar.DefaultCountry)
+	return &PostgresAccountRepository{db: db, calendar: cal}
+}
+
+// WithCalendar makes the repository date ledger entries by cal instead and
+// returns it.
+func (r *PostgresAccountRepository) WithCalendar(cal *calendar.Calendar) *PostgresAccountRepository {
+	r.calendar = cal
+	return r
 }
 
 func (r *PostgresAccountRepository) CreateAccount(acc *model.Account) error {
-	query := `INSERT INTO accounts (id, customer_id, account_number, currency, balance, status) 
-	          VALUES ($1, $2, $3, $4, $5, $6)`
-	_, err := r.db.Exec(query, acc.ID, acc.CustomerID, acc.AccountNumber, acc.Currency, acc.Balance, acc.Status)
+	query := `INSERT INTO accounts (id, customer_id, account_number, currency, balance, status, kind, product_code) 
+	          VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`
+	_, err := r.db.Exec(query, acc.ID, acc.CustomerID, acc.AccountNumber, acc.Currency, acc.Balance, acc.Status, acc.Kind, acc.ProductCode)
 	return err
 }
 
-func (r *PostgresAccountRepository) GetAccount(id string) (*model.Account, error) {
-	query := `SELECT id, customer_id, account_number, currency, balance, status, created_at, updated_at 
-	          FROM accounts WHERE id = $1`
+const accountColumns = `id, customer_id, account_number, currency, balance, status, created_at, updated_at, overdraft_limit, kind, accrued_interest, product_code,
+	(SELECT COALESCE(SUM(h.amount - h.captured), 0) FROM holds h WHERE h.account_id = accounts.id AND h.status = 'active' AND h.expires_at > NOW())`
+
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanAccount(row rowScanner) (*model.Account, error) {
 	var acc model.Account
-	err := r.db.QueryRow(query, id).Scan(
-		&acc.ID, &acc.CustomerID, &acc.AccountNumber, &acc.Currency, &acc.Balance, &acc.Status, &acc.CreatedAt, &acc.UpdatedAt,
+	var held money.Amount
+	err := row.Scan(
+		&acc.ID, &acc.CustomerID, &acc.AccountNumber, &acc.Currency, &acc.Balance, &acc.Status, &acc.CreatedAt, &acc.UpdatedAt, &acc.OverdraftLimit, &acc.Kind, &acc.AccruedInterest, &acc.ProductCode, &held,
 	)
+	if err != nil {
+		return nil, err
+	}
+	if err := acc.SetHeld(held); err != nil {
+		return nil, err
+	}
+	return &acc, nil
+}
+
+func (r *PostgresAccountRepository) GetAccount(id string) (*model.Account, error) {
+	query := `SELECT ` + accountColumns + ` 
+	          FROM accounts WHERE id = $1`
+	acc, err := scanAccount(r.db.QueryRow(query, id))
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return nil, nil
 		}
 		return nil, err
 	}
-	return &acc, nil
+	return acc, nil
+}
+
+func (r *PostgresAccountRepository) GetCustomerByExternalID(externalID string) (*model.Customer, error) {
+	query := `SELECT id, external_id, full_name, created_at FROM customers WHERE external_id = $1`
+	var c model.Customer
+	err := r.db.QueryRow(query, externalID).Scan(&c.ID, &c.ExternalID, &c.FullName, &c.CreatedAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &c, nil
+}
+
+// ListAccounts returns up to filter.Limit accounts of one customer ordered by
+// (created_at, id), starting after filter.After when it is set.
+func (r *PostgresAccountRepository) ListAccounts(filter model.AccountFilter) ([]model.Account, error) {
+	query := `SELECT ` + accountColumns + ` FROM accounts WHERE customer_id = $1`
+	args := []interface{}{filter.CustomerID}
+
+	if filter.Status != "" {
+		args = append(args, filter.Status)
+		query += fmt.Sprintf(" AND status = $%d", len(args))
+	}
+	if filter.Currency != "" {
+		args = append(args, filter.Currency)
+		query += fmt.Sprintf(" AND currency = $%d", len(args))
+	}
+	if filter.After != nil {
+		args = append(args, filter.After.CreatedAt, filter.After.ID)
+		query += fmt.Sprintf(" AND (created_at, id) > ($%d, $%d)", len(args)-1, len(args))
+	}
+	args = append(args, filter.Limit)
+	query += fmt.Sprintf(" ORDER BY created_at, id LIMIT $%d", len(args))
+
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	accounts := []model.Account{}
+	for rows.Next() {
+		acc, err := scanAccount(rows)
+		if err != nil {
+			return nil, err
+		}
+		accounts = append(accounts, *acc)
+	}
+	return accounts, rows.Err()
 }
 
-func (r *PostgresAccountRepository) UpdateBalance(accountID string, amount float64, entryType model.LedgerEntryType, description string) error {
-	tx, err := r.db.Begin()
+// ListLedgerEntries returns up to filter.Limit entries of one account ordered
+// by (created_at, id) descending, starting before filter.Before when set.
+func (r *PostgresAccountRepository) ListLedgerEntries(filter model.LedgerFilter) ([]model.LedgerEntry, error) {
+	query := `SELECT ` + ledgerEntryColumns + ` FROM ledger_entries WHERE account_id = $1`
+	args := []interface{}{filter.AccountID}
+
+	if filter.From != nil {
+		args = append(args, *filter.From)
+		query += fmt.Sprintf(" AND created_at >= $%d", len(args))
+	}
+	if filter.To != nil {
+		args = append(args, *filter.To)
+		query += fmt.Sprintf(" AND created_at < $%d", len(args))
+	}
+	if len(filter.Types) > 0 {
+		types := make([]string, len(filter.Types))
+		for i, t := range filter.Types {
+			types[i] = string(t)
+		}
+		args = append(args, pq.Array(types))
+		query += fmt.Sprintf(" AND type = ANY($%d)", len(args))
+	}
+	if filter.MinAmount != nil {
+		args = append(args, *filter.MinAmount)
+		query += fmt.Sprintf(" AND ABS(amount) >= $%d", len(args))
+	}
+	if filter.MaxAmount != nil {
+		args = append(args, *filter.MaxAmount)
+		query += fmt.Sprintf(" AND ABS(amount) <= $%d", len(args))
+	}
+	if filter.Before != nil {
+		args = append(args, filter.Before.CreatedAt, filter.Before.ID)
+		query += fmt.Sprintf(" AND (created_at, id) < ($%d, $%d)", len(args)-1, len(args))
+	}
+	args = append(args, filter.Limit)
+	query += fmt.Sprintf(" ORDER BY created_at DESC, id DESC LIMIT $%d", len(args))
+
+	rows, err := r.db.Query(query, args...)
 	if err != nil {
-		return err
+		return nil, err
 	}
-	defer tx.Rollback()
+	defer rows.Close()
+
+	entries := []model.LedgerEntry{}
+	for rows.Next() {
+		e, err := scanLedgerEntry(rows)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, *e)
+	}
+	return entries, rows.Err()
+}
 
-	// 1. Lock account for update to ensure ACID
-	var currentBalance float64
-	err = tx.QueryRow(`SELECT balance FROM accounts WHERE id = $1 FOR UPDATE`, accountID).Scan(&currentBalance)
+func (r *PostgresAccountRepository) GetIdempotencyRecord(owner, key string) (*model.IdempotencyRecord, error) {
+	rec := model.IdempotencyRecord{Owner: owner, Key: key}
+	err := r.db.QueryRow(`SELECT fingerprint, response, created_at FROM idempotency_keys WHERE owner = $1 AND key = $2`, owner, key).
+		Scan(&rec.Fingerprint, &rec.Response, &rec.CreatedAt)
 	if err != nil {
-		return fmt.Errorf("could not find or lock account: %w", err)
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &rec, nil
+}
+
+// claimIdempotencyKey records idem inside tx before any balance is touched.
+// If the key already exists the stored response is decoded into out and
+// replayed is true. A concurrent request with the same key blocks on the
+// primary key until the first transaction commits or rolls back.
+func claimIdempotencyKey(tx *sql.Tx, idem *model.IdempotencyKey, out interface{}) (replayed bool, err error) {
+	if idem == nil {
+		return false, nil
 	}
 
-	newBalance := currentBalance + amount
+	var claimed string
+	err = tx.QueryRow(`INSERT INTO idempotency_keys (owner, key, fingerprint) VALUES ($1, $2, $3) 
+	                   ON CONFLICT (owner, key) DO NOTHING RETURNING key`, idem.Owner, idem.Key, idem.Fingerprint).Scan(&claimed)
+	if err == nil {
+		return false, nil
+	}
+	if err != sql.ErrNoRows {
+		return false, fmt.Errorf("could not record idempotency key: %w", err)
+	}
 
-	// 2. Update balance
-	_, err = tx.Exec(`UPDATE accounts SET balance = $1, updated_at = NOW() WHERE id = $2`, newBalance, accountID)
+	var fingerprint string
+	var response []byte
+	err = tx.QueryRow(`SELECT fingerprint, response FROM idempotency_keys WHERE owner = $1 AND key = $2`, idem.Owner, idem.Key).Scan(&fingerprint, &response)
 	if err != nil {
-		return fmt.Errorf("could not update balance: %w", err)
+		return false, fmt.Errorf("could not read idempotency key: %w", err)
+	}
+	if fingerprint != idem.Fingerprint {
+		return false, ErrIdempotencyKeyReused
+	}
+	if err := json.Unmarshal(response, out); err != nil {
+		return false, fmt.Errorf("could not decode stored response: %w", err)
 	}
+	return true, nil
+}
 
-	// 3. Create ledger entry
-	entryID := uuid.New()
-	_, err = tx.Exec(`INSERT INTO ledger_entries (id, account_id, type, amount, balance_after, reference_id, description) 
-	                  VALUES ($1, $2, $3, $4, $5, $6, $7)`,
-		entryID, accountID, entryType, amount, newBalance, nil, description)
+// storeIdempotentResponse saves the result of the operation under the key
+// claimed by claimIdempotencyKey, in the same transaction.
+func storeIdempotentResponse(tx *sql.Tx, idem *model.IdempotencyKey, response interface{}) error {
+	if idem == nil {
+		return nil
+	}
+	body, err := json.Marshal(response)
 	if err != nil {
-		return fmt.Errorf("could not create ledger entry: %w", err)
+		return fmt.Errorf("could not encode response: %w", err)
+	}
+	if _, err := tx.Exec(`UPDATE idempotency_keys SET response = $3 WHERE owner = $1 AND key = $2`, idem.Owner, idem.Key, body); err != nil {
+		return fmt.Errorf("could not store idempotent response: %w", err)
 	}
+	return nil
+}
+
+// sortIDs returns a sorted copy of ids, the order in which accounts are
+// locked.
+func sortIDs(ids []uuid.UUID) []uuid.UUID {
+	sorted := append([]uuid.UUID(nil), ids...)
+	sort.Slice(sorted, func(i, j int) bool { return bytes.Compare(sorted[i][:], sorted[j][:]) < 0 })
+	return sorted
+}
+
+// bookTransfer writes the linked debit and credit entries of a transfer
+// between two locked accounts and posts them to the general ledger.
+// standingOrderID is the order the transfer is a payment of, if any.
+func bookTransfer(tx *sql.Tx, cal *calendar.Calendar, fromID, toID uuid.UUID, amount money.Money, fromAfter, toAfter money.Amount, description string, standingOrderID *uuid.UUID) (*model.Transfer, error) {
+	ref := uuid.New()
+	debit, err := writeEntry(tx, cal, &model.LedgerEntry{
+		AccountID: fromID, Type: model.TransferOut, Amount: amount.Amount.Neg(), BalanceAfter: fromAfter,
+		ReferenceID: &ref, Description: description, StandingOrderID: standingOrderID,
+	})
+	if err != nil {
+		return nil, err
+	}
+	credit, err := writeEntry(tx, cal, &model.LedgerEntry{
+		AccountID: toID, Type: model.TransferIn, Amount: amount.Amount, BalanceAfter: toAfter,
+		ReferenceID: &ref, Description: description, StandingOrderID: standingOrderID,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := postJournal(tx, ref, customerLine(debit, amount.Currency), customerLine(credit, amount.Currency)); err != nil {
+		return nil, err
+	}
+
+	return &model.Transfer{
+		ReferenceID:   ref,
+		FromAccountID: fromID,
+		ToAccountID:   toID,
+		Amount:        amount.Amount,
+		Currency:      amount.Currency,
+		Description:   description,
+		Debit:         *debit,
+		Credit:        *credit,
+		CreatedAt:     debit.CreatedAt,
+	}, nil
+}
 
-	return tx.Commit()
-}
\ No newline at end of file
+// applyEntry stores the new balance of a locked account and appends the
+// matching ledger entry.
+func applyEntry(tx *sql.Tx, cal *calendar.Calendar, accountID string, entryType model.LedgerEntryType, amount, balanceAfter money.Amount, referenceID *uuid.UUID, description string) (*model.LedgerEntry, error) {
+	accID, err := uuid.Parse(accountID)
+	if err != nil {
+		return nil, fmt.Errorf("invalid account id: %w", err)
+	}
+	return writeEntry(tx, cal, &model.LedgerEntry{
+		AccountID:    accID,
+		Type:         entryType,
+		Amount:       amount,
+		BalanceAfter: balanceAfter,
+		ReferenceID:  referenceID,
+		Description:  description,
+	})
+}
+
+// writeEntry sets the balance of the locked account entry.AccountID to
+// entry.BalanceAfter and appends entry to the account's hash chain, filling
+// in its ID, CreatedAt, Sequence and hashes, and its ValueDate by cal
+// unless the caller set one.
+func writeEntry(tx *sql.Tx, cal *calendar.Calendar, entry *model.LedgerEntry) (*model.LedgerEntry, error) {
+	// The caller holds the account's row lock, so the chain head cannot move
+	// until the transaction ends. NOW() is the timestamp the entry would
+	// have got by default; it is read here because the hash covers it.
+	err := tx.QueryRow(`SELECT ledger_seq, COALESCE(ledger_head_hash, ''), NOW() FROM accounts WHERE id = $1`, entry.AccountID.String()).
+		Scan(&entry.Sequence, &entry.PrevHash, &entry.CreatedAt)
+	if err != nil {
+		return nil, fmt.Errorf("could not read ledger chain head: %w", err)
+	}
+	entry.ID = uuid.New()
+	entry.Sequence++
+	if entry.ValueDate == "" {
+		entry.ValueDate = cal.ValueDate(entry.CreatedAt).Format(calendar.DateLayout)
+	}
+	entry.Hash = audit.EntryHash(entry)
+
+	_, err = tx.Exec(`UPDATE accounts SET balance = $1, ledger_seq = $2, ledger_head_hash = $3, updated_at = NOW() WHERE id = $4`,
+		entry.BalanceAfter, entry.Sequence, entry.Hash, entry.AccountID.String())
+	if err != nil {
+		return nil, fmt.Errorf("could not update balance: %w", err)
+	}
+
+	_, err = tx.Exec(`INSERT INTO ledger_entries (id, account_id, type, amount, balance_after, reference_id, description, fx_rate, created_at, seq, prev_hash, entry_hash, value_date, standing_order_id) 
+	                  VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14)`,
+		entry.ID, entry.AccountID.String(), entry.Type, entry.Amount, entry.BalanceAfter, entry.ReferenceID, entry.Description, entry.FXRate,
+		entry.CreatedAt, entry.Sequence, entry.PrevHash, entry.Hash, entry.ValueDate, entry.StandingOrderID)
+	if err != nil {
+		return nil, fmt.Errorf("could not create ledger entry: %w", err)
+	}
+	return entry, nil
+}
@@ -2,11 +2,11 @@ package repository
 
 import (
 	"database/sql"
-	"fmt"
 	"testing"
 	"time"
 
 	"go-web-server/services/account-service/model"
+	"go-web-server/services/account-service/money"
 
 	"github.com/DATA-DOG/go-sqlmock"
 	"github.com/google/uuid"
@@ -25,13 +25,13 @@ func TestCreateAccount(t *testing.T) {
 		ID:            uuid.New(),
 		CustomerID:    uuid.New(),
 		AccountNumber: "PL1234567890",
-		Currency:      "PLN",
-		Balance:       0.0,
+		Currency:      money.PLN,
+		Balance:       money.Amount{},
 		Status:        model.AccountActive,
 	}
 
 	mock.ExpectExec("INSERT INTO accounts").
-		WithArgs(acc.ID, acc.CustomerID, acc.AccountNumber, acc.Currency, acc.Balance, acc.Status).
+		WithArgs(acc.ID, acc.CustomerID, acc.AccountNumber, acc.Currency, acc.Balance, acc.Status, acc.Kind, acc.ProductCode).
 		WillReturnResult(sqlmock.NewResult(1, 1))
 
 	err = repo.CreateAccount(acc)
@@ -53,8 +53,8 @@ func TestGetAccount(t *testing.T) {
 	id := uuid.New()
 
 	now := time.Now()
-	rows := sqlmock.NewRows([]string{"id", "customer_id", "account_number", "currency", "balance", "status", "created_at", "updated_at"}).
-		AddRow(id, uuid.New(), "PL123", "PLN", 100.0, "active", now, now)
+	rows := sqlmock.NewRows([]string{"id", "customer_id", "account_number", "currency", "balance", "status", "created_at", "updated_at", "overdraft_limit", "kind", "accrued_interest", "product_code", "held"}).
+		AddRow(id, uuid.New(), "PL123", "PLN", 100.0, "active", now, now, "500.0000", "savings", "0.1370", "savings", "30.0000")
 
 	mock.ExpectQuery("SELECT (.+) FROM accounts WHERE id =").
 		WithArgs(id).
@@ -64,6 +64,12 @@ func TestGetAccount(t *testing.T) {
 	assert.NoError(t, err)
 	assert.NotNil(t, acc)
 	assert.Equal(t, id, acc.ID)
+	assert.Equal(t, money.MustParseAmount("100"), acc.LedgerBalance)
+	assert.Equal(t, money.MustParseAmount("30"), acc.HeldAmount)
+	assert.Equal(t, money.MustParseAmount("70"), acc.AvailableBalance)
+	assert.Equal(t, money.MustParseAmount("500"), acc.OverdraftLimit)
+	assert.Equal(t, model.AccountSavings, acc.Kind)
+	assert.Equal(t, money.MustParseAmount("0.137"), acc.AccruedInterest)
 
 	if err := mock.ExpectationsWereMet(); err != nil {
 		t.Errorf("there were unfulfilled expectations: %s", err)
@@ -89,7 +95,7 @@ func TestGetAccount_NotFound(t *testing.T) {
 	assert.Nil(t, acc)
 }
 
-func TestUpdateBalance_LockError(t *testing.T) {
+func TestListAccounts_FiltersAndCursor(t *testing.T) {
 	db, mock, err := sqlmock.New()
 	if err != nil {
 		t.Fatalf("error opening mock db: %s", err)
@@ -97,49 +103,192 @@ func TestUpdateBalance_LockError(t *testing.T) {
 	defer db.Close()
 
 	repo := NewPostgresAccountRepository(db)
-	accountID := uuid.New()
+	customerID := uuid.New()
+	after := &model.Cursor{CreatedAt: time.Now(), ID: uuid.New()}
+	now := time.Now()
 
-	mock.ExpectBegin()
-	mock.ExpectQuery("SELECT balance FROM accounts WHERE id = (.+) FOR UPDATE").
-		WithArgs(accountID).
-		WillReturnError(fmt.Errorf("db error"))
-	mock.ExpectRollback()
+	rows := sqlmock.NewRows([]string{"id", "customer_id", "account_number", "currency", "balance", "status", "created_at", "updated_at", "overdraft_limit", "kind", "accrued_interest", "product_code", "held"}).
+		AddRow(uuid.New(), customerID, "PL1", "EUR", "10.0000", "active", now, now, "0", "current", "0", "current", "0").
+		AddRow(uuid.New(), customerID, "PL2", "EUR", "20.0000", "active", now, now, "0", "current", "0", "current", "0")
+
+	mock.ExpectQuery(`SELECT (.+) FROM accounts WHERE customer_id = \$1 AND status = \$2 AND currency = \$3 AND \(created_at, id\) > \(\$4, \$5\) ORDER BY created_at, id LIMIT \$6`).
+		WithArgs(customerID, model.AccountActive, money.EUR, after.CreatedAt, after.ID, 11).
+		WillReturnRows(rows)
 
-	err = repo.UpdateBalance(accountID.String(), 50.0, model.Deposit, "Test")
-	assert.Error(t, err)
+	accounts, err := repo.ListAccounts(model.AccountFilter{
+		CustomerID: customerID, Status: model.AccountActive, Currency: money.EUR, After: after, Limit: 11,
+	})
+	assert.NoError(t, err)
+	assert.Len(t, accounts, 2)
+	assert.Equal(t, money.MustParseAmount("20"), accounts[1].Balance)
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
 }
 
-func TestUpdateBalance_ACID(t *testing.T) {
+func TestListAccounts_NoFilters(t *testing.T) {
 	db, mock, err := sqlmock.New()
 	if err != nil {
-		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+		t.Fatalf("error opening mock db: %s", err)
+	}
+	defer db.Close()
+
+	repo := NewPostgresAccountRepository(db)
+	customerID := uuid.New()
+
+	mock.ExpectQuery(`SELECT (.+) FROM accounts WHERE customer_id = \$1 ORDER BY created_at, id LIMIT \$2`).
+		WithArgs(customerID, 51).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "customer_id", "account_number", "currency", "balance", "status", "created_at", "updated_at", "overdraft_limit", "kind", "accrued_interest", "product_code", "held"}))
+
+	accounts, err := repo.ListAccounts(model.AccountFilter{CustomerID: customerID, Limit: 51})
+	assert.NoError(t, err)
+	assert.Empty(t, accounts)
+	assert.NotNil(t, accounts)
+}
+
+func TestListLedgerEntries_AllFilters(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("error opening mock db: %s", err)
 	}
 	defer db.Close()
 
 	repo := NewPostgresAccountRepository(db)
 	accountID := uuid.New()
-	amount := 50.0
+	ref := uuid.New()
+	from := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	to := time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC)
+	min, max := money.MustParseAmount("5"), money.MustParseAmount("500")
+	before := &model.Cursor{CreatedAt: to, ID: uuid.New()}
 
-	mock.ExpectBegin()
-	// Lock for update
-	mock.ExpectQuery("SELECT balance FROM accounts WHERE id = (.+) FOR UPDATE").
-		WithArgs(accountID).
-		WillReturnRows(sqlmock.NewRows([]string{"balance"}).AddRow(100.0))
+	standingOrderID := uuid.New()
+	rows := sqlmock.NewRows([]string{"id", "account_id", "type", "amount", "balance_after", "reference_id", "description", "fx_rate", "created_at", "seq", "prev_hash", "entry_hash", "value_date", "standing_order_id"}).
+		AddRow(uuid.New(), accountID, "transfer_in", "10.0000", "110.0000", ref, "From savings", nil, from, 8, "ab12", "cd34", from, standingOrderID).
+		AddRow(uuid.New(), accountID, "fx_exchange", "42.6500", "100.0000", ref, "Exchange", "4.26500000", from, 7, "ef56", "ab12", from, nil)
+
+	mock.ExpectQuery(`FROM ledger_entries WHERE account_id = \$1 AND created_at >= \$2 AND created_at < \$3 AND type = ANY\(\$4\) AND ABS\(amount\) >= \$5 AND ABS\(amount\) <= \$6 AND \(created_at, id\) < \(\$7, \$8\) ORDER BY created_at DESC, id DESC LIMIT \$9`).
+		WithArgs(accountID, from, to, sqlmock.AnyArg(), "5.00", "500.00", before.CreatedAt, before.ID, 21).
+		WillReturnRows(rows)
 
-	// Update account balance
+	entries, err := repo.ListLedgerEntries(model.LedgerFilter{
+		AccountID: accountID, From: &from, To: &to,
+		Types:     []model.LedgerEntryType{model.TransferIn, model.FXExchange},
+		MinAmount: &min, MaxAmount: &max, Before: before, Limit: 21,
+	})
+	assert.NoError(t, err)
+	assert.Len(t, entries, 2)
+	assert.Equal(t, model.TransferIn, entries[0].Type)
+	assert.Equal(t, ref, *entries[0].ReferenceID)
+	assert.Equal(t, money.MustParseAmount("110"), entries[0].BalanceAfter)
+	assert.Equal(t, "From savings", entries[0].Description)
+	assert.Nil(t, entries[0].FXRate)
+	assert.Equal(t, standingOrderID, *entries[0].StandingOrderID)
+	assert.Nil(t, entries[1].StandingOrderID)
+	assert.Equal(t, money.MustParseRate("4.265"), *entries[1].FXRate)
+	assert.Equal(t, int64(8), entries[0].Sequence)
+	assert.Equal(t, entries[1].Hash, entries[0].PrevHash)
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestGetCustomerByExternalID(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("error opening mock db: %s", err)
+	}
+	defer db.Close()
+
+	repo := NewPostgresAccountRepository(db)
+	id := uuid.New()
+
+	mock.ExpectQuery("SELECT (.+) FROM customers WHERE external_id =").
+		WithArgs("test_user").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "external_id", "full_name", "created_at"}).
+			AddRow(id, "test_user", "Test User", time.Now()))
+	mock.ExpectQuery("SELECT (.+) FROM customers WHERE external_id =").
+		WithArgs("ghost").
+		WillReturnError(sql.ErrNoRows)
+
+	c, err := repo.GetCustomerByExternalID("test_user")
+	assert.NoError(t, err)
+	assert.Equal(t, id, c.ID)
+
+	c, err = repo.GetCustomerByExternalID("ghost")
+	assert.NoError(t, err)
+	assert.Nil(t, c)
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestInTx_ClosePaysOutBalanceAndReleasesHolds(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("error opening mock db: %s", err)
+	}
+	defer db.Close()
+
+	repo := NewPostgresAccountRepository(db)
+	payout := uuid.MustParse("00000000-0000-0000-0000-000000000001")
+	closing := uuid.MustParse("00000000-0000-0000-0000-000000000002")
+	now := time.Now()
+
+	mock.ExpectBegin()
+	expectLockAccount(mock, payout, "1.0000", "0", "0")
+	expectLockAccount(mock, closing, "12.5000", "2.0000", "0")
+	expectChainHead(mock, closing.String(), 0, now)
 	mock.ExpectExec("UPDATE accounts SET balance = (.+) WHERE id =").
-		WithArgs(150.0, accountID).
+		WithArgs("0.00", int64(1), sqlmock.AnyArg(), closing.String()).
 		WillReturnResult(sqlmock.NewResult(1, 1))
-
-	// Insert ledger entry
 	mock.ExpectExec("INSERT INTO ledger_entries").
-		WithArgs(sqlmock.AnyArg(), accountID, model.Deposit, amount, 150.0, nil, "Test deposit").
+		WithArgs(sqlmock.AnyArg(), closing.String(), model.TransferOut, "-12.50", "0.00", sqlmock.AnyArg(), sqlmock.AnyArg(), nil, now, int64(1), "", sqlmock.AnyArg(), sqlmock.AnyArg(), nil).
 		WillReturnResult(sqlmock.NewResult(1, 1))
-
+	expectChainHead(mock, payout.String(), 0, now)
+	mock.ExpectExec("UPDATE accounts SET balance = (.+) WHERE id =").
+		WithArgs("13.50", int64(1), sqlmock.AnyArg(), payout.String()).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec("INSERT INTO ledger_entries").
+		WithArgs(sqlmock.AnyArg(), payout.String(), model.TransferIn, "12.50", "13.50", sqlmock.AnyArg(), sqlmock.AnyArg(), nil, now, int64(1), "", sqlmock.AnyArg(), sqlmock.AnyArg(), nil).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec("INSERT INTO journal_lines").
+		WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectExec("UPDATE holds SET status").
+		WithArgs(model.HoldReleased, closing, model.HoldActive).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("UPDATE accounts SET status = (.+) WHERE id =").
+		WithArgs(model.AccountClosed, closing).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectQuery("INSERT INTO account_status_changes").
+		WithArgs(sqlmock.AnyArg(), closing, model.AccountActive, model.AccountClosed, model.ReasonCustomerRequest, "", sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"created_at"}).AddRow(now))
 	mock.ExpectCommit()
 
-	err = repo.UpdateBalance(accountID.String(), amount, model.Deposit, "Test deposit")
+	var rec *model.AccountStatusChange
+	err = repo.InTx(nil, nil, func(uow UnitOfWork) error {
+		locked, err := uow.LockAccounts(closing, payout)
+		if err != nil {
+			return err
+		}
+		acc := locked[closing]
+		transfer, err := uow.BookTransfer(acc, locked[payout], acc.BalanceMoney(), "Payout on account closure")
+		if err != nil {
+			return err
+		}
+		rec, err = uow.ChangeStatus(acc, model.StatusChange{AccountID: closing, To: model.AccountClosed, Reason: model.ReasonCustomerRequest}, &transfer.ReferenceID)
+		if err != nil {
+			return err
+		}
+		assert.Equal(t, model.AccountClosed, acc.Status)
+		assert.True(t, acc.HeldAmount.IsZero())
+		return nil
+	})
 	assert.NoError(t, err)
+	assert.Equal(t, model.AccountActive, rec.FromStatus)
+	assert.NotNil(t, rec.PayoutReferenceID)
 
 	if err := mock.ExpectationsWereMet(); err != nil {
 		t.Errorf("there were unfulfilled expectations: %s", err)
@@ -0,0 +1,112 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"go-web-server/services/account-service/model"
+	"go-web-server/services/account-service/money"
+
+	"github.com/lib/pq"
+)
+
+var (
+	// ErrWithdrawalLimitExceeded matches ProductLimitErrors for debits above
+	// the product's withdrawal limit.
+	ErrWithdrawalLimitExceeded = errors.New("withdrawal limit exceeded")
+	// ErrMaxBalanceExceeded matches ProductLimitErrors for credits that would
+	// take the balance above the product's maximum.
+	ErrMaxBalanceExceeded = errors.New("maximum balance exceeded")
+)
+
+// ProductLimitError is returned when a balance change breaks a limit of the
+// account's product. Limit is ErrWithdrawalLimitExceeded or
+// ErrMaxBalanceExceeded; Requested is the debit or the balance after the
+// credit.
+type ProductLimitError struct {
+	Limit     error
+	Product   string
+	Max       money.Money
+	Requested money.Money
+}
+
+func (e *ProductLimitError) Error() string {
+	return fmt.Sprintf("%s: product %s allows %s, requested %s", e.Limit, e.Product, e.Max, e.Requested)
+}
+
+func (e *ProductLimitError) Unwrap() error { return e.Limit }
+
+const productColumns = `code, name, kind, currencies, max_overdraft, interest_rate, max_withdrawal, max_balance,
+	daily_limit, monthly_limit, max_daily_transactions, active, created_at, updated_at`
+
+func scanProduct(row rowScanner) (*model.Product, error) {
+	var p model.Product
+	var currencies []string
+	err := row.Scan(&p.Code, &p.Name, &p.Kind, pq.Array(&currencies), &p.MaxOverdraft, &p.InterestRate,
+		&p.MaxWithdrawal, &p.MaxBalance, &p.DailyLimit, &p.MonthlyLimit, &p.MaxDailyTransactions, &p.Active, &p.CreatedAt, &p.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	p.Currencies = make([]money.Currency, len(currencies))
+	for i, c := range currencies {
+		p.Currencies[i] = money.Currency(c)
+	}
+	return &p, nil
+}
+
+func currencyCodes(currencies []money.Currency) []string {
+	codes := make([]string, len(currencies))
+	for i, c := range currencies {
+		codes[i] = string(c)
+	}
+	return codes
+}
+
+// ListProducts returns the whole catalogue ordered by code.
+func (r *PostgresAccountRepository) ListProducts() ([]model.Product, error) {
+	rows, err := r.db.Query(`SELECT ` + productColumns + ` FROM products ORDER BY code`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	products := []model.Product{}
+	for rows.Next() {
+		p, err := scanProduct(rows)
+		if err != nil {
+			return nil, err
+		}
+		products = append(products, *p)
+	}
+	return products, rows.Err()
+}
+
+// GetProduct returns a product, or nil when there is none with that code.
+func (r *PostgresAccountRepository) GetProduct(code string) (*model.Product, error) {
+	p, err := scanProduct(r.db.QueryRow(`SELECT `+productColumns+` FROM products WHERE code = $1`, code))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return p, err
+}
+
+// SaveProduct creates the product p.Code or replaces its terms, and fills
+// in its timestamps.
+func (r *PostgresAccountRepository) SaveProduct(p *model.Product) (*model.Product, error) {
+	err := r.db.QueryRow(`INSERT INTO products (code, name, kind, currencies, max_overdraft, interest_rate, max_withdrawal, max_balance,
+	                          daily_limit, monthly_limit, max_daily_transactions, active)
+	                      VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)
+	                      ON CONFLICT (code) DO UPDATE SET name = EXCLUDED.name, kind = EXCLUDED.kind, currencies = EXCLUDED.currencies,
+	                          max_overdraft = EXCLUDED.max_overdraft, interest_rate = EXCLUDED.interest_rate,
+	                          max_withdrawal = EXCLUDED.max_withdrawal, max_balance = EXCLUDED.max_balance,
+	                          daily_limit = EXCLUDED.daily_limit, monthly_limit = EXCLUDED.monthly_limit,
+	                          max_daily_transactions = EXCLUDED.max_daily_transactions, active = EXCLUDED.active, updated_at = NOW()
+	                      RETURNING created_at, updated_at`,
+		p.Code, p.Name, p.Kind, pq.Array(currencyCodes(p.Currencies)), p.MaxOverdraft, p.InterestRate,
+		p.MaxWithdrawal, p.MaxBalance, p.DailyLimit, p.MonthlyLimit, p.MaxDailyTransactions, p.Active).Scan(&p.CreatedAt, &p.UpdatedAt)
+	if err != nil {
+		return nil, fmt.Errorf("could not save product: %w", err)
+	}
+	return p, nil
+}
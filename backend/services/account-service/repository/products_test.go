@@ -0,0 +1,94 @@
+package repository
+
+import (
+	"testing"
+	"time"
+
+	"go-web-server/services/account-service/model"
+	"go-web-server/services/account-service/money"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var productRowColumns = []string{
+	"code", "name", "kind", "currencies", "max_overdraft", "interest_rate", "max_withdrawal", "max_balance",
+	"daily_limit", "monthly_limit", "max_daily_transactions", "active", "created_at", "updated_at",
+}
+
+func TestListProducts(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("error opening mock db: %s", err)
+	}
+	defer db.Close()
+
+	repo := NewPostgresAccountRepository(db)
+	now := time.Now()
+
+	mock.ExpectQuery("SELECT (.+) FROM products ORDER BY code").
+		WillReturnRows(sqlmock.NewRows(productRowColumns).
+			AddRow("current", "Current account", "current", "{PLN}", "10000.0000", nil, "0", "0", "5000.0000", "0", 20, true, now, now).
+			AddRow("savings", "Savings account", "savings", "{PLN}", "0", "0.05000000", "20000.0000", "1000000.0000", "0", "0", 0, true, now, now))
+
+	products, err := repo.ListProducts()
+	require.NoError(t, err)
+	require.Len(t, products, 2)
+	assert.Equal(t, []money.Currency{money.PLN}, products[0].Currencies)
+	assert.Nil(t, products[0].InterestRate)
+	assert.Equal(t, money.MustParseAmount("10000"), products[0].MaxOverdraft)
+	assert.Equal(t, money.MustParseAmount("5000"), products[0].DailyLimit)
+	assert.Equal(t, 20, products[0].MaxDailyTransactions)
+	assert.Equal(t, model.AccountSavings, products[1].Kind)
+	assert.Equal(t, money.MustParseRate("0.05"), *products[1].InterestRate)
+	assert.Equal(t, money.MustParseAmount("20000"), products[1].MaxWithdrawal)
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestGetProduct_NotFound(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("error opening mock db: %s", err)
+	}
+	defer db.Close()
+
+	repo := NewPostgresAccountRepository(db)
+	mock.ExpectQuery("SELECT (.+) FROM products WHERE code =").
+		WithArgs("pension").
+		WillReturnRows(sqlmock.NewRows(productRowColumns))
+
+	p, err := repo.GetProduct("pension")
+	assert.NoError(t, err)
+	assert.Nil(t, p)
+}
+
+func TestSaveProduct(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("error opening mock db: %s", err)
+	}
+	defer db.Close()
+
+	repo := NewPostgresAccountRepository(db)
+	now := time.Now()
+	p := &model.Product{
+		Code: "youth", Name: "Youth account", Kind: model.AccountCurrent, Currencies: []money.Currency{money.PLN, money.EUR},
+		MaxWithdrawal: money.MustParseAmount("500"), MonthlyLimit: money.MustParseAmount("3000"), Active: true,
+	}
+
+	mock.ExpectQuery("INSERT INTO products (.+) ON CONFLICT \\(code\\) DO UPDATE").
+		WithArgs("youth", "Youth account", model.AccountCurrent, "{\"PLN\",\"EUR\"}", "0.00", nil, "500.00", "0.00", "0.00", "3000.00", 0, true).
+		WillReturnRows(sqlmock.NewRows([]string{"created_at", "updated_at"}).AddRow(now, now))
+
+	saved, err := repo.SaveProduct(p)
+	require.NoError(t, err)
+	assert.Equal(t, now, saved.UpdatedAt)
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
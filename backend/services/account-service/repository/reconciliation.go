@@ -0,0 +1,111 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+
+	"go-web-server/services/account-service/model"
+)
+
+// LedgerSnapshot reads every account's stored balance together with the
+// totals recomputed from its ledger entries and journal lines, and every
+// entry whose balance_after does not continue the chain of the entries
+// before it. Entries are chained in (created_at, id) order. Both queries run
+// in one read-only repeatable-read transaction, so they see the same
+// committed state even while transfers are booked.
+func (r *PostgresAccountRepository) LedgerSnapshot() (*model.LedgerSnapshot, error) {
+	tx, err := r.db.BeginTx(context.Background(), &sql.TxOptions{Isolation: sql.LevelRepeatableRead, ReadOnly: true})
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.Query(`SELECT a.id, a.currency, a.status, a.balance, COALESCE(l.total, 0), COALESCE(l.entries, 0), l.last_balance, COALESCE(g.net, 0)
+	                       FROM accounts a
+	                       LEFT JOIN (SELECT account_id, SUM(amount) AS total, COUNT(*) AS entries,
+	                                         (ARRAY_AGG(balance_after ORDER BY created_at DESC, id DESC))[1] AS last_balance
+	                                  FROM ledger_entries GROUP BY account_id) l ON l.account_id = a.id
+	                       LEFT JOIN (SELECT account_id, SUM(credit - debit) AS net
+	                                  FROM journal_lines WHERE gl_account = $1 GROUP BY account_id) g ON g.account_id = a.id
+	                       ORDER BY a.id`, model.GLCustomerDeposits)
+	if err != nil {
+		return nil, fmt.Errorf("could not read account totals: %w", err)
+	}
+	defer rows.Close()
+
+	snap := &model.LedgerSnapshot{Accounts: []model.AccountLedgerTotals{}, ChainBreaks: []model.LedgerChainBreak{}}
+	for rows.Next() {
+		var t model.AccountLedgerTotals
+		if err := rows.Scan(&t.AccountID, &t.Currency, &t.Status, &t.Balance, &t.LedgerSum, &t.Entries, &t.LastBalanceAfter, &t.GLBalance); err != nil {
+			return nil, err
+		}
+		snap.Accounts = append(snap.Accounts, t)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	rows.Close()
+
+	rows, err = tx.Query(`SELECT e.account_id, e.id, e.prev_balance, e.amount, e.balance_after
+	                      FROM (SELECT account_id, id, amount, balance_after, created_at,
+	                                   COALESCE(LAG(balance_after) OVER (PARTITION BY account_id ORDER BY created_at, id), 0) AS prev_balance
+	                            FROM ledger_entries) e
+	                      WHERE e.balance_after <> e.prev_balance + e.amount
+	                      ORDER BY e.account_id, e.created_at, e.id`)
+	if err != nil {
+		return nil, fmt.Errorf("could not check balance_after chains: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var b model.LedgerChainBreak
+		if err := rows.Scan(&b.AccountID, &b.LedgerEntryID, &b.PreviousBalance, &b.Amount, &b.BalanceAfter); err != nil {
+			return nil, err
+		}
+		snap.ChainBreaks = append(snap.ChainBreaks, b)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return snap, nil
+}
+
+// SaveReconciliationReport stores a finished report.
+func (r *PostgresAccountRepository) SaveReconciliationReport(report *model.ReconciliationReport) error {
+	body, err := json.Marshal(report)
+	if err != nil {
+		return fmt.Errorf("could not encode reconciliation report: %w", err)
+	}
+	_, err = r.db.Exec(`INSERT INTO reconciliation_reports (id, started_at, finished_at, clean, report) VALUES ($1, $2, $3, $4, $5)`,
+		report.ID, report.StartedAt, report.FinishedAt, report.Clean, body)
+	return err
+}
+
+// GetReconciliationReport returns a stored report, or nil when there is none
+// with that id.
+func (r *PostgresAccountRepository) GetReconciliationReport(id string) (*model.ReconciliationReport, error) {
+	return scanReconciliationReport(r.db.QueryRow(`SELECT report FROM reconciliation_reports WHERE id = $1`, id))
+}
+
+// LatestReconciliationReport returns the most recently started report, or
+// nil before the first run.
+func (r *PostgresAccountRepository) LatestReconciliationReport() (*model.ReconciliationReport, error) {
+	return scanReconciliationReport(r.db.QueryRow(`SELECT report FROM reconciliation_reports ORDER BY started_at DESC LIMIT 1`))
+}
+
+func scanReconciliationReport(row rowScanner) (*model.ReconciliationReport, error) {
+	var body []byte
+	if err := row.Scan(&body); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var report model.ReconciliationReport
+	if err := json.Unmarshal(body, &report); err != nil {
+		return nil, fmt.Errorf("could not decode reconciliation report: %w", err)
+	}
+	return &report, nil
+}
@@ -0,0 +1,67 @@
+package repository
+
+import (
+	"testing"
+
+	"go-web-server/services/account-service/model"
+	"go-web-server/services/account-service/money"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLedgerSnapshot(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("error opening mock db: %s", err)
+	}
+	defer db.Close()
+
+	repo := NewPostgresAccountRepository(db)
+	funded, empty := uuid.New(), uuid.New()
+	entryID := uuid.New()
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT a.id, a.currency, a.status, a.balance, (.+) FROM accounts a LEFT JOIN (.+) FROM ledger_entries (.+) FROM journal_lines WHERE gl_account = \\$1").
+		WithArgs(model.GLCustomerDeposits).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "currency", "status", "balance", "total", "entries", "last_balance", "net"}).
+			AddRow(funded.String(), "PLN", "active", "150.0000", "150.0000", 2, "140.0000", "150.0000").
+			AddRow(empty.String(), "EUR", "frozen", "0.0000", "0", 0, nil, "0"))
+	mock.ExpectQuery("SELECT e.account_id, e.id, e.prev_balance, e.amount, e.balance_after FROM \\(SELECT (.+)LAG\\(balance_after\\) (.+) WHERE e.balance_after <> e.prev_balance \\+ e.amount").
+		WillReturnRows(sqlmock.NewRows([]string{"account_id", "id", "prev_balance", "amount", "balance_after"}).
+			AddRow(funded.String(), entryID.String(), "100.0000", "50.0000", "140.0000"))
+	mock.ExpectRollback()
+
+	snap, err := repo.LedgerSnapshot()
+	assert.NoError(t, err)
+	assert.Len(t, snap.Accounts, 2)
+	assert.Equal(t, money.MustParseAmount("140"), *snap.Accounts[0].LastBalanceAfter)
+	assert.Equal(t, 2, snap.Accounts[0].Entries)
+	assert.Nil(t, snap.Accounts[1].LastBalanceAfter)
+	assert.Equal(t, model.AccountFrozen, snap.Accounts[1].Status)
+	assert.Equal(t, []model.LedgerChainBreak{{
+		AccountID: funded, LedgerEntryID: entryID,
+		PreviousBalance: money.MustParseAmount("100"), Amount: money.MustParseAmount("50"), BalanceAfter: money.MustParseAmount("140"),
+	}}, snap.ChainBreaks)
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestLatestReconciliationReport_None(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("error opening mock db: %s", err)
+	}
+	defer db.Close()
+
+	repo := NewPostgresAccountRepository(db)
+	mock.ExpectQuery("SELECT report FROM reconciliation_reports ORDER BY started_at DESC LIMIT 1").
+		WillReturnRows(sqlmock.NewRows([]string{"report"}))
+
+	report, err := repo.LatestReconciliationReport()
+	assert.NoError(t, err)
+	assert.Nil(t, report)
+}
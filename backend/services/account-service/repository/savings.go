@@ -0,0 +1,31 @@
+package repository
+
+import (
+	"go-web-server/services/account-service/model"
+
+	"github.com/google/uuid"
+)
+
+// interestSavings is the interest_postings kind of capitalised savings
+// interest.
+const interestSavings = "savings"
+
+// SavingsAccounts returns the ids of the savings accounts that are not
+// closed.
+func (r *PostgresAccountRepository) SavingsAccounts() ([]uuid.UUID, error) {
+	rows, err := r.db.Query(`SELECT id FROM accounts WHERE kind = $1 AND status <> $2 ORDER BY id`, model.AccountSavings, model.AccountClosed)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ids := []uuid.UUID{}
+	for rows.Next() {
+		var id uuid.UUID
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
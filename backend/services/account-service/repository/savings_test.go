@@ -0,0 +1,153 @@
+package repository
+
+import (
+	"testing"
+	"time"
+
+	"go-web-server/services/account-service/model"
+	"go-web-server/services/account-service/money"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInTx_RecordAccrual(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("error opening mock db: %s", err)
+	}
+	defer db.Close()
+
+	repo := NewPostgresAccountRepository(db)
+	accountID := uuid.New()
+	day := time.Date(2024, 3, 14, 0, 0, 0, 0, time.UTC)
+	rate := money.MustParseRate("0.05")
+
+	mock.ExpectBegin()
+	expectLockAccount(mock, accountID, "10100.0000", "0", "0")
+	mock.ExpectQuery("SELECT EXISTS \\(SELECT 1 FROM interest_accruals").
+		WithArgs(accountID, "2024-03-14").
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(false))
+	// A deposit that takes value after the day does not earn interest for
+	// it.
+	mock.ExpectQuery("SELECT COALESCE\\(SUM\\(amount\\), 0\\) FROM ledger_entries WHERE account_id = (.+) AND value_date >").
+		WithArgs(accountID, "2024-03-14").
+		WillReturnRows(sqlmock.NewRows([]string{"sum"}).AddRow("100.0000"))
+	mock.ExpectExec("INSERT INTO interest_accruals").
+		WithArgs(accountID, "2024-03-14", "10000.00", sqlmock.AnyArg(), money.Act365, 1, "1.3699").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery("UPDATE accounts SET accrued_interest = accrued_interest \\+").
+		WithArgs("1.3699", accountID).
+		WillReturnRows(sqlmock.NewRows([]string{"accrued_interest"}).AddRow("17.8087"))
+	mock.ExpectCommit()
+
+	accrual := &model.InterestAccrual{BusinessDate: "2024-03-14", Days: 1, Amount: money.MustParseAmount("1.3699")}
+	err = repo.InTx(nil, nil, func(uow UnitOfWork) error {
+		acc, err := uow.LockAccount(accountID.String())
+		if err != nil {
+			return err
+		}
+		accrued, err := uow.InterestAccrued(acc, day)
+		if err != nil || accrued {
+			return err
+		}
+		if accrual.Balance, err = uow.EndOfDayBalance(acc, day); err != nil {
+			return err
+		}
+		if err := uow.RecordAccrual(acc, accrual, rate, money.Act365); err != nil {
+			return err
+		}
+		assert.Equal(t, money.MustParseAmount("17.8087"), acc.AccruedInterest)
+		return nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, money.MustParseAmount("10000"), accrual.Balance)
+	assert.Equal(t, money.MustParseAmount("17.8087"), accrual.Accrued)
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestInTx_CapitaliseInterest(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("error opening mock db: %s", err)
+	}
+	defer db.Close()
+
+	repo := NewPostgresAccountRepository(db)
+	accountID := uuid.New()
+	day := time.Date(2024, 2, 29, 0, 0, 0, 0, time.UTC)
+
+	mock.ExpectBegin()
+	expectLockAccount(mock, accountID, "10000.0000", "0", "0")
+	expectChainHead(mock, accountID.String(), 7, time.Now())
+	mock.ExpectExec("UPDATE accounts SET balance").
+		WithArgs("10039.73", int64(8), sqlmock.AnyArg(), accountID.String()).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec("INSERT INTO ledger_entries").
+		WithArgs(sqlmock.AnyArg(), accountID.String(), model.Interest, "39.73", "10039.73", nil, "Interest for 2024-02", nil, sqlmock.AnyArg(), int64(8), "previous", sqlmock.AnyArg(), "2024-02-29", nil).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	// Dr interest expense, Cr customer deposits
+	mock.ExpectExec("INSERT INTO journal_lines").
+		WithArgs(
+			sqlmock.AnyArg(), model.GLCustomerDeposits, accountID, sqlmock.AnyArg(), money.PLN, "0.00", "39.73",
+			sqlmock.AnyArg(), model.GLInterestExpense, nil, sqlmock.AnyArg(), money.PLN, "39.73", "0.00",
+		).
+		WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectExec("INSERT INTO interest_postings").
+		WithArgs(accountID, "savings", "2024-02-29", "39.73", sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery("UPDATE accounts SET accrued_interest = accrued_interest -").
+		WithArgs("39.73", accountID).
+		WillReturnRows(sqlmock.NewRows([]string{"accrued_interest"}).AddRow("0.0012"))
+	mock.ExpectCommit()
+
+	var entry *model.LedgerEntry
+	err = repo.InTx(nil, nil, func(uow UnitOfWork) error {
+		acc, err := uow.LockAccount(accountID.String())
+		if err != nil {
+			return err
+		}
+		entry, err = uow.PostInterest(acc, model.Interest, money.New(money.MustParseAmount("39.73"), money.PLN), day, "Interest for 2024-02")
+		if err != nil {
+			return err
+		}
+		// The fraction of a grosz is carried into the next month.
+		assert.Equal(t, money.MustParseAmount("0.0012"), acc.AccruedInterest)
+		return nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, model.Interest, entry.Type)
+	assert.Equal(t, money.MustParseAmount("39.73"), entry.Amount)
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestSavingsAccounts(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("error opening mock db: %s", err)
+	}
+	defer db.Close()
+
+	repo := NewPostgresAccountRepository(db)
+	id := uuid.New()
+
+	mock.ExpectQuery("SELECT id FROM accounts WHERE kind = (.+) AND status <> (.+)").
+		WithArgs(model.AccountSavings, model.AccountClosed).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(id))
+
+	ids, err := repo.SavingsAccounts()
+	require.NoError(t, err)
+	assert.Equal(t, []uuid.UUID{id}, ids)
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
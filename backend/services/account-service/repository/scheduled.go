@@ -0,0 +1,224 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"go-web-server/services/account-service/model"
+
+	"github.com/google/uuid"
+)
+
+// ErrScheduledTransferNotPending matches every
+// ScheduledTransferNotPendingError.
+var ErrScheduledTransferNotPending = errors.New("scheduled transfer is not pending")
+
+// ScheduledTransferNotPendingError is returned when amending, cancelling or
+// executing a scheduled transfer that was already executed, failed or was
+// cancelled.
+type ScheduledTransferNotPendingError struct {
+	ID     uuid.UUID
+	Status model.ScheduledTransferStatus
+}
+
+func (e *ScheduledTransferNotPendingError) Error() string {
+	return fmt.Sprintf("%s: scheduled transfer %s is %s", ErrScheduledTransferNotPending, e.ID, e.Status)
+}
+
+func (e *ScheduledTransferNotPendingError) Is(target error) bool {
+	return target == ErrScheduledTransferNotPending
+}
+
+const scheduledTransferColumns = `id, from_account_id, to_account_id, amount, currency, COALESCE(description, ''), execute_on,
+	status, attempts, next_attempt_at, COALESCE(last_error, ''), transfer_reference_id, standing_order_id, created_at, updated_at`
+
+func scanScheduledTransfer(row rowScanner) (*model.ScheduledTransfer, error) {
+	var st model.ScheduledTransfer
+	var executeOn time.Time
+	err := row.Scan(&st.ID, &st.FromAccountID, &st.ToAccountID, &st.Amount, &st.Currency, &st.Description, &executeOn,
+		&st.Status, &st.Attempts, &st.NextAttemptAt, &st.LastError, &st.TransferReferenceID, &st.StandingOrderID, &st.CreatedAt, &st.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	st.ExecuteOn = executeOn.Format("2006-01-02")
+	return &st, nil
+}
+
+// CreateScheduledTransfer stores a pending scheduled transfer and fills in
+// its timestamps. The accounts are checked by the service and again when
+// the transfer is executed.
+func (r *PostgresAccountRepository) CreateScheduledTransfer(st *model.ScheduledTransfer, idem *model.IdempotencyKey) (*model.ScheduledTransfer, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var created *model.ScheduledTransfer
+	if replayed, err := claimIdempotencyKey(tx, idem, &created); err != nil || replayed {
+		return created, err
+	}
+
+	err = tx.QueryRow(`INSERT INTO scheduled_transfers (id, from_account_id, to_account_id, amount, currency, description, execute_on, status, next_attempt_at)
+	                  VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9) RETURNING created_at, updated_at`,
+		st.ID, st.FromAccountID, st.ToAccountID, st.Amount, st.Currency, st.Description, st.ExecuteOn, model.ScheduledPending, st.NextAttemptAt).
+		Scan(&st.CreatedAt, &st.UpdatedAt)
+	if err != nil {
+		return nil, fmt.Errorf("could not create scheduled transfer: %w", err)
+	}
+	st.Status = model.ScheduledPending
+	st.Attempts = 0
+
+	if err := storeIdempotentResponse(tx, idem, st); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return st, nil
+}
+
+// GetScheduledTransfer returns a scheduled transfer, or nil when there is
+// none with that id.
+func (r *PostgresAccountRepository) GetScheduledTransfer(id string) (*model.ScheduledTransfer, error) {
+	st, err := scanScheduledTransfer(r.db.QueryRow(`SELECT `+scheduledTransferColumns+` FROM scheduled_transfers WHERE id = $1`, id))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return st, err
+}
+
+// ListScheduledTransfers returns up to limit scheduled transfers from an
+// account, soonest first, optionally only those in status.
+func (r *PostgresAccountRepository) ListScheduledTransfers(accountID string, status model.ScheduledTransferStatus, limit int) ([]model.ScheduledTransfer, error) {
+	query := `SELECT ` + scheduledTransferColumns + ` FROM scheduled_transfers WHERE from_account_id = $1`
+	args := []interface{}{accountID}
+	if status != "" {
+		args = append(args, status)
+		query += fmt.Sprintf(" AND status = $%d", len(args))
+	}
+	args = append(args, limit)
+	query += fmt.Sprintf(" ORDER BY execute_on DESC, created_at DESC, id DESC LIMIT $%d", len(args))
+
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	transfers := []model.ScheduledTransfer{}
+	for rows.Next() {
+		st, err := scanScheduledTransfer(rows)
+		if err != nil {
+			return nil, err
+		}
+		transfers = append(transfers, *st)
+	}
+	return transfers, rows.Err()
+}
+
+// lockScheduledTransfer locks a scheduled transfer and checks that it is
+// pending.
+func lockScheduledTransfer(tx *sql.Tx, id string) (*model.ScheduledTransfer, error) {
+	st, err := scanScheduledTransfer(tx.QueryRow(`SELECT `+scheduledTransferColumns+` FROM scheduled_transfers WHERE id = $1 FOR UPDATE`, id))
+	if err != nil {
+		return nil, fmt.Errorf("could not find or lock scheduled transfer: %w", err)
+	}
+	if st.Status != model.ScheduledPending {
+		return nil, &ScheduledTransferNotPendingError{ID: st.ID, Status: st.Status}
+	}
+	return st, nil
+}
+
+// AmendScheduledTransfer replaces the amount, description and date of a
+// pending scheduled transfer. An amended transfer starts over: its failed
+// attempts are forgotten and it is next tried at st.NextAttemptAt.
+func (r *PostgresAccountRepository) AmendScheduledTransfer(st *model.ScheduledTransfer) (*model.ScheduledTransfer, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	if _, err := lockScheduledTransfer(tx, st.ID.String()); err != nil {
+		return nil, err
+	}
+	amended, err := scanScheduledTransfer(tx.QueryRow(`UPDATE scheduled_transfers
+	                  SET amount = $1, description = $2, execute_on = $3, next_attempt_at = $4, attempts = 0, last_error = NULL, updated_at = NOW()
+	                  WHERE id = $5 RETURNING `+scheduledTransferColumns,
+		st.Amount, st.Description, st.ExecuteOn, st.NextAttemptAt, st.ID))
+	if err != nil {
+		return nil, fmt.Errorf("could not amend scheduled transfer: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return amended, nil
+}
+
+// CancelScheduledTransfer cancels a pending scheduled transfer.
+func (r *PostgresAccountRepository) CancelScheduledTransfer(id string) (*model.ScheduledTransfer, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	st, err := lockScheduledTransfer(tx, id)
+	if err != nil {
+		return nil, err
+	}
+	st.Status = model.ScheduledCancelled
+	err = tx.QueryRow(`UPDATE scheduled_transfers SET status = $1, updated_at = NOW() WHERE id = $2 RETURNING updated_at`, st.Status, st.ID).Scan(&st.UpdatedAt)
+	if err != nil {
+		return nil, fmt.Errorf("could not cancel scheduled transfer: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return st, nil
+}
+
+// DueScheduledTransfers returns up to limit pending scheduled transfers
+// whose next attempt is due at now, longest overdue first.
+func (r *PostgresAccountRepository) DueScheduledTransfers(now time.Time, limit int) ([]uuid.UUID, error) {
+	rows, err := r.db.Query(`SELECT id FROM scheduled_transfers WHERE status = $1 AND next_attempt_at <= $2
+	                         ORDER BY next_attempt_at, id LIMIT $3`, model.ScheduledPending, now, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []uuid.UUID
+	for rows.Next() {
+		var id uuid.UUID
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// RecordScheduledTransferFailure stores a failed attempt: st.Attempts,
+// st.LastError and either a pending status with the next attempt at
+// st.NextAttemptAt or the failed status. It returns nil when the transfer
+// was amended or cancelled since the attempt started, in which case the
+// attempt no longer counts.
+func (r *PostgresAccountRepository) RecordScheduledTransferFailure(st *model.ScheduledTransfer) (*model.ScheduledTransfer, error) {
+	recorded, err := scanScheduledTransfer(r.db.QueryRow(`UPDATE scheduled_transfers
+	                  SET status = $1, attempts = $2, last_error = $3, next_attempt_at = $4, updated_at = NOW()
+	                  WHERE id = $5 AND status = $6 AND attempts = $7 RETURNING `+scheduledTransferColumns,
+		st.Status, st.Attempts, st.LastError, st.NextAttemptAt, st.ID, model.ScheduledPending, st.Attempts-1))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("could not record scheduled transfer failure: %w", err)
+	}
+	return recorded, nil
+}
@@ -0,0 +1,272 @@
+package repository
+
+import (
+	"testing"
+	"time"
+
+	"go-web-server/services/account-service/model"
+	"go-web-server/services/account-service/money"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var scheduledTransferRowColumns = []string{
+	"id", "from_account_id", "to_account_id", "amount", "currency", "description", "execute_on",
+	"status", "attempts", "next_attempt_at", "last_error", "transfer_reference_id", "standing_order_id", "created_at", "updated_at",
+}
+
+// scheduledTransferRow returns a scheduled transfer of 100.00 PLN due on
+// 2024-03-10 in status after attempts.
+func scheduledTransferRow(id uuid.UUID, status model.ScheduledTransferStatus, attempts int) *sqlmock.Rows {
+	day := time.Date(2024, 3, 10, 0, 0, 0, 0, time.UTC)
+	return sqlmock.NewRows(scheduledTransferRowColumns).
+		AddRow(id, uuid.New(), uuid.New(), "100.0000", "PLN", "Rent", day, status, attempts, day, "", nil, nil, day, day)
+}
+
+func TestCreateScheduledTransfer(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("error opening mock db: %s", err)
+	}
+	defer db.Close()
+
+	repo := NewPostgresAccountRepository(db)
+	now := time.Now()
+	day := time.Date(2024, 3, 10, 0, 0, 0, 0, time.UTC)
+	st := &model.ScheduledTransfer{
+		ID: uuid.New(), FromAccountID: uuid.New(), ToAccountID: uuid.New(), Amount: money.MustParseAmount("100"),
+		Currency: money.PLN, Description: "Rent", ExecuteOn: "2024-03-10", NextAttemptAt: day,
+	}
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("INSERT INTO scheduled_transfers").
+		WithArgs(st.ID, st.FromAccountID, st.ToAccountID, "100.00", money.PLN, "Rent", "2024-03-10", model.ScheduledPending, day).
+		WillReturnRows(sqlmock.NewRows([]string{"created_at", "updated_at"}).AddRow(now, now))
+	mock.ExpectCommit()
+
+	created, err := repo.CreateScheduledTransfer(st, nil)
+	require.NoError(t, err)
+	assert.Equal(t, model.ScheduledPending, created.Status)
+	assert.Equal(t, now, created.CreatedAt)
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestGetScheduledTransfer_FormatsDate(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("error opening mock db: %s", err)
+	}
+	defer db.Close()
+
+	repo := NewPostgresAccountRepository(db)
+	id := uuid.New()
+	mock.ExpectQuery("SELECT (.+) FROM scheduled_transfers WHERE id =").
+		WithArgs(id.String()).
+		WillReturnRows(scheduledTransferRow(id, model.ScheduledPending, 0))
+	mock.ExpectQuery("SELECT (.+) FROM scheduled_transfers WHERE id =").
+		WithArgs(id.String()).
+		WillReturnRows(sqlmock.NewRows(scheduledTransferRowColumns))
+
+	st, err := repo.GetScheduledTransfer(id.String())
+	require.NoError(t, err)
+	assert.Equal(t, "2024-03-10", st.ExecuteOn)
+	assert.Nil(t, st.TransferReferenceID)
+
+	st, err = repo.GetScheduledTransfer(id.String())
+	require.NoError(t, err)
+	assert.Nil(t, st)
+}
+
+func TestCancelScheduledTransfer_NotPending(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("error opening mock db: %s", err)
+	}
+	defer db.Close()
+
+	repo := NewPostgresAccountRepository(db)
+	id := uuid.New()
+	now := time.Now()
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT (.+) FROM scheduled_transfers WHERE id = (.+) FOR UPDATE").
+		WithArgs(id.String()).
+		WillReturnRows(scheduledTransferRow(id, model.ScheduledPending, 1))
+	mock.ExpectQuery("UPDATE scheduled_transfers SET status = (.+) WHERE id =").
+		WithArgs(model.ScheduledCancelled, id).
+		WillReturnRows(sqlmock.NewRows([]string{"updated_at"}).AddRow(now))
+	mock.ExpectCommit()
+	// Cancelling again finds it cancelled.
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT (.+) FROM scheduled_transfers WHERE id = (.+) FOR UPDATE").
+		WithArgs(id.String()).
+		WillReturnRows(scheduledTransferRow(id, model.ScheduledCancelled, 1))
+	mock.ExpectRollback()
+
+	cancelled, err := repo.CancelScheduledTransfer(id.String())
+	require.NoError(t, err)
+	assert.Equal(t, model.ScheduledCancelled, cancelled.Status)
+
+	_, err = repo.CancelScheduledTransfer(id.String())
+	var notPending *ScheduledTransferNotPendingError
+	require.ErrorAs(t, err, &notPending)
+	assert.Equal(t, model.ScheduledCancelled, notPending.Status)
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestInTx_CompleteScheduledTransfer(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("error opening mock db: %s", err)
+	}
+	defer db.Close()
+
+	repo := NewPostgresAccountRepository(db)
+	id, ref := uuid.New(), uuid.New()
+	now := time.Now()
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT (.+) FROM scheduled_transfers WHERE id = (.+) FOR UPDATE").
+		WithArgs(id.String()).
+		WillReturnRows(scheduledTransferRow(id, model.ScheduledPending, 1))
+	mock.ExpectQuery("UPDATE scheduled_transfers\\s+SET status = (.+), attempts = attempts \\+ 1").
+		WithArgs(model.ScheduledExecuted, ref, id).
+		WillReturnRows(sqlmock.NewRows([]string{"attempts", "updated_at"}).AddRow(2, now))
+	mock.ExpectCommit()
+
+	var st *model.ScheduledTransfer
+	err = repo.InTx(nil, nil, func(uow UnitOfWork) error {
+		if st, err = uow.LockScheduledTransfer(id); err != nil {
+			return err
+		}
+		return uow.CompleteScheduledTransfer(st, ref)
+	})
+	require.NoError(t, err)
+	assert.Equal(t, model.ScheduledExecuted, st.Status)
+	assert.Equal(t, 2, st.Attempts)
+	assert.Equal(t, &ref, st.TransferReferenceID)
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestInTx_StandingOrderPaymentEntriesCarryOrder(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("error opening mock db: %s", err)
+	}
+	defer db.Close()
+
+	repo := NewPostgresAccountRepository(db)
+	id, orderID := uuid.New(), uuid.New()
+	from := uuid.MustParse("00000000-0000-0000-0000-000000000001")
+	to := uuid.MustParse("ffffffff-0000-0000-0000-000000000001")
+	day := time.Date(2024, 3, 10, 0, 0, 0, 0, time.UTC)
+	now := time.Now()
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT (.+) FROM scheduled_transfers WHERE id = (.+) FOR UPDATE").
+		WithArgs(id.String()).
+		WillReturnRows(sqlmock.NewRows(scheduledTransferRowColumns).
+			AddRow(id, from, to, "100.0000", "PLN", "Rent", day, model.ScheduledPending, 0, day, "", nil, orderID, day, day))
+	expectLockAccount(mock, from, "500.0000", "0", "0")
+	expectLockAccount(mock, to, "0", "0", "0")
+	// The transfer's entries and its fee carry the order when written, so
+	// that their hashes cover it.
+	expectChainHead(mock, from.String(), 0, now)
+	mock.ExpectExec("UPDATE accounts SET balance = (.+) WHERE id =").
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec("INSERT INTO ledger_entries").
+		WithArgs(sqlmock.AnyArg(), from.String(), model.TransferOut, "-100.00", "400.00", sqlmock.AnyArg(), "Rent", nil, now, int64(1), "", sqlmock.AnyArg(), sqlmock.AnyArg(), &orderID).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	expectChainHead(mock, to.String(), 0, now)
+	mock.ExpectExec("UPDATE accounts SET balance = (.+) WHERE id =").
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec("INSERT INTO ledger_entries").
+		WithArgs(sqlmock.AnyArg(), to.String(), model.TransferIn, "100.00", "100.00", sqlmock.AnyArg(), "Rent", nil, now, int64(1), "", sqlmock.AnyArg(), sqlmock.AnyArg(), &orderID).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec("INSERT INTO journal_lines").
+		WillReturnResult(sqlmock.NewResult(0, 2))
+	expectChainHead(mock, from.String(), 1, now)
+	mock.ExpectExec("UPDATE accounts SET balance = (.+) WHERE id =").
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec("INSERT INTO ledger_entries").
+		WithArgs(sqlmock.AnyArg(), from.String(), model.Fee, "-1.00", "399.00", sqlmock.AnyArg(), "Transfer fee", nil, now, int64(2), "previous", sqlmock.AnyArg(), sqlmock.AnyArg(), &orderID).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec("INSERT INTO journal_lines").
+		WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectQuery("UPDATE scheduled_transfers\\s+SET status = (.+), attempts = attempts \\+ 1").
+		WithArgs(model.ScheduledExecuted, sqlmock.AnyArg(), id).
+		WillReturnRows(sqlmock.NewRows([]string{"attempts", "updated_at"}).AddRow(1, day))
+	mock.ExpectCommit()
+
+	var transfer *model.Transfer
+	err = repo.InTx(nil, nil, func(uow UnitOfWork) error {
+		st, err := uow.LockScheduledTransfer(id)
+		if err != nil {
+			return err
+		}
+		locked, err := uow.LockAccounts(st.FromAccountID, st.ToAccountID)
+		if err != nil {
+			return err
+		}
+		transfer, err = uow.BookTransfer(locked[from], locked[to], money.New(st.Amount, st.Currency), st.Description)
+		if err != nil {
+			return err
+		}
+		if _, err := uow.PostFee(locked[from], money.New(money.MustParseAmount("1"), money.PLN), &transfer.Debit, "Transfer fee"); err != nil {
+			return err
+		}
+		return uow.CompleteScheduledTransfer(st, transfer.ReferenceID)
+	})
+	require.NoError(t, err)
+	assert.Equal(t, orderID, *transfer.Debit.StandingOrderID)
+	assert.Equal(t, orderID, *transfer.Credit.StandingOrderID)
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestRecordScheduledTransferFailure(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("error opening mock db: %s", err)
+	}
+	defer db.Close()
+
+	repo := NewPostgresAccountRepository(db)
+	id := uuid.New()
+	retryAt := time.Now()
+	st := &model.ScheduledTransfer{ID: id, Status: model.ScheduledPending, Attempts: 2, LastError: "insufficient funds", NextAttemptAt: retryAt}
+
+	mock.ExpectQuery("UPDATE scheduled_transfers\\s+SET status = (.+) WHERE id = (.+) AND status = (.+) AND attempts =").
+		WithArgs(model.ScheduledPending, 2, "insufficient funds", retryAt, id, model.ScheduledPending, 1).
+		WillReturnRows(scheduledTransferRow(id, model.ScheduledPending, 2))
+	// The transfer was amended or cancelled while the attempt ran.
+	mock.ExpectQuery("UPDATE scheduled_transfers\\s+SET status = (.+) WHERE id = (.+) AND status = (.+) AND attempts =").
+		WithArgs(model.ScheduledPending, 2, "insufficient funds", retryAt, id, model.ScheduledPending, 1).
+		WillReturnRows(sqlmock.NewRows(scheduledTransferRowColumns))
+
+	recorded, err := repo.RecordScheduledTransferFailure(st)
+	require.NoError(t, err)
+	assert.Equal(t, 2, recorded.Attempts)
+
+	recorded, err = repo.RecordScheduledTransferFailure(st)
+	require.NoError(t, err)
+	assert.Nil(t, recorded)
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
@@ -0,0 +1,224 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"go-web-server/services/account-service/model"
+
+	"github.com/google/uuid"
+)
+
+// ErrStandingOrderNotActive matches every StandingOrderNotActiveError.
+var ErrStandingOrderNotActive = errors.New("standing order is not active")
+
+// StandingOrderNotActiveError is returned when cancelling a standing order
+// that was already completed or cancelled.
+type StandingOrderNotActiveError struct {
+	ID     uuid.UUID
+	Status model.StandingOrderStatus
+}
+
+func (e *StandingOrderNotActiveError) Error() string {
+	return fmt.Sprintf("%s: standing order %s is %s", ErrStandingOrderNotActive, e.ID, e.Status)
+}
+
+func (e *StandingOrderNotActiveError) Is(target error) bool {
+	return target == ErrStandingOrderNotActive
+}
+
+const standingOrderColumns = `id, from_account_id, to_account_id, amount, currency, COALESCE(description, ''),
+	frequency, COALESCE(day_of_month, 0), shift, start_on, end_on, COALESCE(max_occurrences, 0),
+	status, occurrences, next_due_on, next_execute_on, created_at, updated_at`
+
+func scanStandingOrder(row rowScanner) (*model.StandingOrder, error) {
+	var so model.StandingOrder
+	var startOn time.Time
+	var endOn, nextDueOn, nextExecuteOn *time.Time
+	err := row.Scan(&so.ID, &so.FromAccountID, &so.ToAccountID, &so.Amount, &so.Currency, &so.Description,
+		&so.Frequency, &so.DayOfMonth, &so.Shift, &startOn, &endOn, &so.MaxOccurrences,
+		&so.Status, &so.Occurrences, &nextDueOn, &nextExecuteOn, &so.CreatedAt, &so.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	so.StartOn = startOn.Format("2006-01-02")
+	so.EndOn = formatDay(endOn)
+	so.NextDueOn = formatDay(nextDueOn)
+	so.NextExecuteOn = formatDay(nextExecuteOn)
+	return &so, nil
+}
+
+// formatDay formats a nullable DATE column, or returns "" for NULL.
+func formatDay(day *time.Time) string {
+	if day == nil {
+		return ""
+	}
+	return day.Format("2006-01-02")
+}
+
+// nullable stores the zero value of an optional column as NULL.
+func nullable(v interface{}) interface{} {
+	switch v {
+	case "", 0:
+		return nil
+	}
+	return v
+}
+
+// CreateStandingOrder stores an active standing order whose first payment
+// falls due on so.NextDueOn, and fills in its timestamps.
+func (r *PostgresAccountRepository) CreateStandingOrder(so *model.StandingOrder, idem *model.IdempotencyKey) (*model.StandingOrder, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var created *model.StandingOrder
+	if replayed, err := claimIdempotencyKey(tx, idem, &created); err != nil || replayed {
+		return created, err
+	}
+
+	err = tx.QueryRow(`INSERT INTO standing_orders (id, from_account_id, to_account_id, amount, currency, description,
+	                      frequency, day_of_month, shift, start_on, end_on, max_occurrences, status, next_due_on, next_execute_on)
+	                  VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15) RETURNING created_at, updated_at`,
+		so.ID, so.FromAccountID, so.ToAccountID, so.Amount, so.Currency, so.Description,
+		so.Frequency, nullable(so.DayOfMonth), so.Shift, so.StartOn, nullable(so.EndOn), nullable(so.MaxOccurrences),
+		model.StandingOrderActive, so.NextDueOn, so.NextExecuteOn).
+		Scan(&so.CreatedAt, &so.UpdatedAt)
+	if err != nil {
+		return nil, fmt.Errorf("could not create standing order: %w", err)
+	}
+	so.Status = model.StandingOrderActive
+	so.Occurrences = 0
+
+	if err := storeIdempotentResponse(tx, idem, so); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return so, nil
+}
+
+// GetStandingOrder returns a standing order, or nil when there is none with
+// that id.
+func (r *PostgresAccountRepository) GetStandingOrder(id string) (*model.StandingOrder, error) {
+	so, err := scanStandingOrder(r.db.QueryRow(`SELECT `+standingOrderColumns+` FROM standing_orders WHERE id = $1`, id))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return so, err
+}
+
+// ListStandingOrders returns up to limit standing orders from an account,
+// newest first.
+func (r *PostgresAccountRepository) ListStandingOrders(accountID string, limit int) ([]model.StandingOrder, error) {
+	rows, err := r.db.Query(`SELECT `+standingOrderColumns+` FROM standing_orders WHERE from_account_id = $1
+	                         ORDER BY created_at DESC, id DESC LIMIT $2`, accountID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	orders := []model.StandingOrder{}
+	for rows.Next() {
+		so, err := scanStandingOrder(rows)
+		if err != nil {
+			return nil, err
+		}
+		orders = append(orders, *so)
+	}
+	return orders, rows.Err()
+}
+
+// ListStandingOrderPayments returns up to limit payments scheduled by a
+// standing order, latest first.
+func (r *PostgresAccountRepository) ListStandingOrderPayments(standingOrderID string, limit int) ([]model.ScheduledTransfer, error) {
+	rows, err := r.db.Query(`SELECT `+scheduledTransferColumns+` FROM scheduled_transfers WHERE standing_order_id = $1
+	                         ORDER BY execute_on DESC, created_at DESC, id DESC LIMIT $2`, standingOrderID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	payments := []model.ScheduledTransfer{}
+	for rows.Next() {
+		st, err := scanScheduledTransfer(rows)
+		if err != nil {
+			return nil, err
+		}
+		payments = append(payments, *st)
+	}
+	return payments, rows.Err()
+}
+
+// lockStandingOrder locks a standing order and checks that it is active.
+func lockStandingOrder(tx *sql.Tx, id string) (*model.StandingOrder, error) {
+	so, err := scanStandingOrder(tx.QueryRow(`SELECT `+standingOrderColumns+` FROM standing_orders WHERE id = $1 FOR UPDATE`, id))
+	if err != nil {
+		return nil, fmt.Errorf("could not find or lock standing order: %w", err)
+	}
+	if so.Status != model.StandingOrderActive {
+		return nil, &StandingOrderNotActiveError{ID: so.ID, Status: so.Status}
+	}
+	return so, nil
+}
+
+// CancelStandingOrder cancels an active standing order together with its
+// payments that are still pending. Payments already executed or failed
+// keep their status.
+func (r *PostgresAccountRepository) CancelStandingOrder(id string) (*model.StandingOrder, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	so, err := lockStandingOrder(tx, id)
+	if err != nil {
+		return nil, err
+	}
+	so.Status = model.StandingOrderCancelled
+	so.NextDueOn, so.NextExecuteOn = "", ""
+	err = tx.QueryRow(`UPDATE standing_orders SET status = $1, next_due_on = NULL, next_execute_on = NULL, updated_at = NOW()
+	                  WHERE id = $2 RETURNING updated_at`, so.Status, so.ID).Scan(&so.UpdatedAt)
+	if err != nil {
+		return nil, fmt.Errorf("could not cancel standing order: %w", err)
+	}
+	// A payment being executed holds its row lock, so this waits for it
+	// and then leaves it executed.
+	_, err = tx.Exec(`UPDATE scheduled_transfers SET status = $1, updated_at = NOW() WHERE standing_order_id = $2 AND status = $3`,
+		model.ScheduledCancelled, so.ID, model.ScheduledPending)
+	if err != nil {
+		return nil, fmt.Errorf("could not cancel standing order payments: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return so, nil
+}
+
+// DueStandingOrders returns up to limit active standing orders whose next
+// payment is made on or before today, longest overdue first.
+func (r *PostgresAccountRepository) DueStandingOrders(today time.Time, limit int) ([]uuid.UUID, error) {
+	rows, err := r.db.Query(`SELECT id FROM standing_orders WHERE status = $1 AND next_execute_on <= $2
+	                         ORDER BY next_execute_on, id LIMIT $3`, model.StandingOrderActive, today.Format("2006-01-02"), limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []uuid.UUID
+	for rows.Next() {
+		var id uuid.UUID
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
@@ -0,0 +1,177 @@
+package repository
+
+import (
+	"testing"
+	"time"
+
+	"go-web-server/services/account-service/model"
+	"go-web-server/services/account-service/money"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var standingOrderRowColumns = []string{
+	"id", "from_account_id", "to_account_id", "amount", "currency", "description",
+	"frequency", "day_of_month", "shift", "start_on", "end_on", "max_occurrences",
+	"status", "occurrences", "next_due_on", "next_execute_on", "created_at", "updated_at",
+}
+
+// standingOrderRow returns a monthly standing order of 50.00 PLN on the
+// 10th, started on 2024-03-01, in status.
+func standingOrderRow(id uuid.UUID, status model.StandingOrderStatus) *sqlmock.Rows {
+	start := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	due, execute := time.Date(2024, 3, 10, 0, 0, 0, 0, time.UTC), time.Date(2024, 3, 11, 0, 0, 0, 0, time.UTC)
+	return sqlmock.NewRows(standingOrderRowColumns).
+		AddRow(id, uuid.New(), uuid.New(), "50.0000", "PLN", "Rent",
+			model.FrequencyMonthly, 10, model.ShiftNext, start, nil, 0,
+			status, 0, due, execute, start, start)
+}
+
+func TestCreateStandingOrder(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("error opening mock db: %s", err)
+	}
+	defer db.Close()
+
+	repo := NewPostgresAccountRepository(db)
+	now := time.Now()
+	so := &model.StandingOrder{
+		ID: uuid.New(), FromAccountID: uuid.New(), ToAccountID: uuid.New(), Amount: money.MustParseAmount("50"), Currency: money.PLN,
+		StandingOrderSchedule: model.StandingOrderSchedule{Frequency: model.FrequencyWeekly, Shift: model.ShiftNext, StartOn: "2024-03-09", MaxOccurrences: 4},
+		NextDueOn:             "2024-03-09", NextExecuteOn: "2024-03-11",
+	}
+
+	// The weekly order has no day of the month or end date.
+	mock.ExpectBegin()
+	mock.ExpectQuery("INSERT INTO standing_orders").
+		WithArgs(so.ID, so.FromAccountID, so.ToAccountID, "50.00", money.PLN, "",
+			model.FrequencyWeekly, nil, model.ShiftNext, "2024-03-09", nil, 4, model.StandingOrderActive, "2024-03-09", "2024-03-11").
+		WillReturnRows(sqlmock.NewRows([]string{"created_at", "updated_at"}).AddRow(now, now))
+	mock.ExpectCommit()
+
+	created, err := repo.CreateStandingOrder(so, nil)
+	require.NoError(t, err)
+	assert.Equal(t, model.StandingOrderActive, created.Status)
+	assert.Equal(t, now, created.CreatedAt)
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestGetStandingOrder_FormatsDates(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("error opening mock db: %s", err)
+	}
+	defer db.Close()
+
+	repo := NewPostgresAccountRepository(db)
+	id := uuid.New()
+	mock.ExpectQuery("SELECT (.+) FROM standing_orders WHERE id =").
+		WithArgs(id.String()).
+		WillReturnRows(standingOrderRow(id, model.StandingOrderActive))
+
+	so, err := repo.GetStandingOrder(id.String())
+	require.NoError(t, err)
+	assert.Equal(t, "2024-03-01", so.StartOn)
+	assert.Empty(t, so.EndOn)
+	assert.Equal(t, "2024-03-10", so.NextDueOn)
+	assert.Equal(t, "2024-03-11", so.NextExecuteOn)
+	assert.Equal(t, 10, so.DayOfMonth)
+}
+
+func TestCancelStandingOrder_CancelsPendingPayments(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("error opening mock db: %s", err)
+	}
+	defer db.Close()
+
+	repo := NewPostgresAccountRepository(db)
+	id := uuid.New()
+	now := time.Now()
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT (.+) FROM standing_orders WHERE id = (.+) FOR UPDATE").
+		WithArgs(id.String()).
+		WillReturnRows(standingOrderRow(id, model.StandingOrderActive))
+	mock.ExpectQuery("UPDATE standing_orders SET status = (.+), next_due_on = NULL").
+		WithArgs(model.StandingOrderCancelled, id).
+		WillReturnRows(sqlmock.NewRows([]string{"updated_at"}).AddRow(now))
+	mock.ExpectExec("UPDATE scheduled_transfers SET status = (.+) WHERE standing_order_id = (.+) AND status =").
+		WithArgs(model.ScheduledCancelled, id, model.ScheduledPending).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	// Cancelling again finds it cancelled.
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT (.+) FROM standing_orders WHERE id = (.+) FOR UPDATE").
+		WithArgs(id.String()).
+		WillReturnRows(standingOrderRow(id, model.StandingOrderCancelled))
+	mock.ExpectRollback()
+
+	cancelled, err := repo.CancelStandingOrder(id.String())
+	require.NoError(t, err)
+	assert.Equal(t, model.StandingOrderCancelled, cancelled.Status)
+	assert.Empty(t, cancelled.NextExecuteOn)
+
+	_, err = repo.CancelStandingOrder(id.String())
+	var notActive *StandingOrderNotActiveError
+	require.ErrorAs(t, err, &notActive)
+	assert.Equal(t, model.StandingOrderCancelled, notActive.Status)
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestInTx_ScheduleStandingOrderPayment(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("error opening mock db: %s", err)
+	}
+	defer db.Close()
+
+	repo := NewPostgresAccountRepository(db)
+	id := uuid.New()
+	now := time.Now()
+	day := time.Date(2024, 3, 11, 0, 0, 0, 0, time.UTC)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT (.+) FROM standing_orders WHERE id = (.+) FOR UPDATE").
+		WithArgs(id.String()).
+		WillReturnRows(standingOrderRow(id, model.StandingOrderActive))
+	mock.ExpectQuery("INSERT INTO scheduled_transfers (.+) standing_order_id").
+		WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), "50.00", money.PLN, "Rent", "2024-03-11", model.ScheduledPending, day, id).
+		WillReturnRows(sqlmock.NewRows([]string{"created_at", "updated_at"}).AddRow(now, now))
+	// The order completed with this payment.
+	mock.ExpectQuery("UPDATE standing_orders SET occurrences = (.+) WHERE id =").
+		WithArgs(1, nil, nil, model.StandingOrderCompleted, id).
+		WillReturnRows(sqlmock.NewRows([]string{"updated_at"}).AddRow(now))
+	mock.ExpectCommit()
+
+	var payment *model.ScheduledTransfer
+	err = repo.InTx(nil, nil, func(uow UnitOfWork) error {
+		so, err := uow.LockStandingOrder(id)
+		if err != nil {
+			return err
+		}
+		payment = &model.ScheduledTransfer{
+			ID: uuid.New(), FromAccountID: so.FromAccountID, ToAccountID: so.ToAccountID, Amount: so.Amount, Currency: so.Currency,
+			Description: so.Description, ExecuteOn: so.NextExecuteOn, NextAttemptAt: day,
+		}
+		so.Occurrences, so.Status, so.NextDueOn, so.NextExecuteOn = 1, model.StandingOrderCompleted, "", ""
+		return uow.ScheduleStandingOrderPayment(so, payment)
+	})
+	require.NoError(t, err)
+	assert.Equal(t, model.ScheduledPending, payment.Status)
+	assert.Equal(t, &id, payment.StandingOrderID)
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
@@ -0,0 +1,731 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"go-web-server/services/account-service/calendar"
+	"go-web-server/services/account-service/model"
+	"go-web-server/services/account-service/money"
+
+	"github.com/google/uuid"
+)
+
+// UnitOfWork is one database transaction in which a service applies its
+// business rules. Accounts read through it stay locked until the
+// transaction ends, so the balance, holds and overdraft limit the rules
+// were checked against cannot change before the entries written through it
+// are committed.
+type UnitOfWork interface {
+	// LockAccount locks an account and returns it, or nil if it does not
+	// exist.
+	LockAccount(id string) (*model.Account, error)
+	// LockAccounts locks several accounts in ascending id order, so that two
+	// units of work locking the same accounts cannot deadlock. Accounts that
+	// do not exist are missing from the result.
+	LockAccounts(ids ...uuid.UUID) (map[uuid.UUID]*model.Account, error)
+	// PostEntry adds amount to the balance of a locked account, appends the
+	// ledger entry and posts it against the contra account of entryType.
+	PostEntry(acc *model.Account, amount money.Money, entryType model.LedgerEntryType, description string) (*model.LedgerEntry, error)
+	// BookTransfer moves amount between two locked accounts with linked
+	// transfer_out and transfer_in entries.
+	BookTransfer(from, to *model.Account, amount money.Money, description string) (*model.Transfer, error)
+	// Product returns the product of the given code, or nil if it does not
+	// exist.
+	Product(code string) (*model.Product, error)
+	// FeeSchedule returns the schedule of op for a product, or nil if the
+	// product does not charge for it.
+	FeeSchedule(productCode string, op model.FeeOperation) (*model.FeeSchedule, error)
+	// PostFee debits fee from a locked account as a fee entry posted against
+	// fee income. The fee joins the operation of cause: it takes the
+	// entry's reference, or its ID when it has none. A fee charged on its
+	// own has a nil cause and no reference.
+	PostFee(acc *model.Account, fee money.Money, cause *model.LedgerEntry, description string) (*model.LedgerEntry, error)
+	// ConsumeLimits checks a withdrawal or outgoing transfer of amount from
+	// a locked account against the transaction limits of its product p, its
+	// own overrides and its customer's limits in the currency, and counts it
+	// towards the day's usage of the account and the customer. The usage is
+	// written in this transaction, so it is undone if the debit is.
+	ConsumeLimits(acc *model.Account, p *model.Product, amount money.Money) error
+	// LockScheduledTransfer locks a scheduled transfer for execution and
+	// checks that it is still pending. When it is a payment of a standing
+	// order, the transfer and fee entries written afterwards in the unit of
+	// work carry the order, so that the ledger shows which order made them.
+	LockScheduledTransfer(id uuid.UUID) (*model.ScheduledTransfer, error)
+	// CompleteScheduledTransfer marks a scheduled transfer locked in this
+	// unit of work as executed by the transfer whose entries share ref.
+	CompleteScheduledTransfer(st *model.ScheduledTransfer, ref uuid.UUID) error
+	// LockStandingOrder locks a standing order for scheduling its payments
+	// and checks that it is still active.
+	LockStandingOrder(id uuid.UUID) (*model.StandingOrder, error)
+	// ScheduleStandingOrderPayment stores payment as a pending scheduled
+	// transfer of a standing order locked in this unit of work, and saves
+	// the order's count, next payment and status as advanced past it.
+	ScheduleStandingOrderPayment(so *model.StandingOrder, payment *model.ScheduledTransfer) error
+	// LockFXQuote locks a quote for execution and checks that it was not
+	// executed yet and has not expired by the database clock. It returns
+	// nil if the quote does not exist.
+	LockFXQuote(id uuid.UUID) (*model.FXQuote, error)
+	// BookExchange books a quote locked in this unit of work between two
+	// locked accounts as two fx_exchange entries sharing one reference,
+	// posts them against the FX position and marks the quote executed.
+	BookExchange(from, to *model.Account, q *model.FXQuote) (*model.CurrencyExchange, error)
+	// PlaceHold reserves h.Amount on a locked account and fills in the
+	// hold's status and timestamps.
+	PlaceHold(acc *model.Account, h *model.Hold) error
+	// LockHold locks a hold and checks that it is active; a hold past its
+	// expiry is reported as expired even before the sweeper marks it. Lock
+	// the hold's account first, in the same order as closing an account,
+	// which releases its holds.
+	LockHold(id uuid.UUID) (*model.Hold, error)
+	// CaptureHold settles amount of a hold locked in this unit of work with
+	// a hold_capture entry on its locked account, referencing the hold. The
+	// hold becomes captured once nothing remains, or at once when final is
+	// set, which gives up the uncaptured rest.
+	CaptureHold(acc *model.Account, h *model.Hold, amount money.Money, final bool) (*model.LedgerEntry, error)
+	// ChangeStatus moves a locked account to change.To and records the
+	// change, linking the payout transfer payoutRef if there was one.
+	// Closing an account releases its holds.
+	ChangeStatus(acc *model.Account, change model.StatusChange, payoutRef *uuid.UUID) (*model.AccountStatusChange, error)
+	// SetOverdraftLimit sets the overdraft limit of a locked account to
+	// change.NewLimit and records the change with the previous limit,
+	// filling in change.OldLimit, Currency and CreatedAt.
+	SetOverdraftLimit(acc *model.Account, change *model.OverdraftLimitChange) error
+	// InterestPosted reports whether interest of entryType was already
+	// posted to a locked account for businessDate.
+	InterestPosted(acc *model.Account, entryType model.LedgerEntryType, businessDate time.Time) (bool, error)
+	// PostInterest adds amount of interest of entryType to the balance of a
+	// locked account, value dated businessDate, posts it against interest
+	// income or expense and records it as the posting of businessDate.
+	// Capitalised savings interest is taken out of the accrued interest.
+	PostInterest(acc *model.Account, entryType model.LedgerEntryType, amount money.Money, businessDate time.Time, description string) (*model.LedgerEntry, error)
+	// InterestAccrued reports whether a locked account already accrued
+	// interest for businessDate.
+	InterestAccrued(acc *model.Account, businessDate time.Time) (bool, error)
+	// EndOfDayBalance returns the balance a locked account had at the end
+	// of businessDate: its balance less the entries that take value after
+	// it. Value dates follow the calendar's day and cut-off, not UTC.
+	EndOfDayBalance(acc *model.Account, businessDate time.Time) (money.Amount, error)
+	// RecordAccrual records accrual, computed at rate under dayCount, and
+	// adds its amount to the accrued interest of a locked account, filling
+	// in accrual.Accrued.
+	RecordAccrual(acc *model.Account, accrual *model.InterestAccrual, rate money.Rate, dayCount money.DayCount) error
+	// MaintenanceFeeCharged reports whether a locked account was already
+	// charged the maintenance fee of month.
+	MaintenanceFeeCharged(acc *model.Account, month time.Time) (bool, error)
+	// RecordMaintenanceFee records the fee entry as the maintenance fee of
+	// month of its account.
+	RecordMaintenanceFee(entry *model.LedgerEntry, month time.Time) error
+}
+
+// ErrAccountNotLocked is returned when a unit of work is asked to write to
+// an account it did not lock.
+var ErrAccountNotLocked = errors.New("account is not locked in this unit of work")
+
+// InTx runs fn in one transaction and commits it if fn returns nil. With an
+// idempotency key, the key is claimed first: if it was used before, out
+// receives the stored response and fn does not run; otherwise out, which fn
+// fills in, is stored as the key's response in the same transaction.
+func (r *PostgresAccountRepository) InTx(idem *model.IdempotencyKey, out interface{}, fn func(UnitOfWork) error) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if replayed, err := claimIdempotencyKey(tx, idem, out); err != nil || replayed {
+		return err
+	}
+	if err := fn(&unitOfWork{tx: tx, calendar: r.calendar, locked: map[uuid.UUID]*model.Account{}}); err != nil {
+		return err
+	}
+	if err := storeIdempotentResponse(tx, idem, out); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+type unitOfWork struct {
+	tx       *sql.Tx
+	calendar *calendar.Calendar
+	locked   map[uuid.UUID]*model.Account
+	// standingOrderID is the order of the payment locked for execution, if
+	// any.
+	standingOrderID *uuid.UUID
+}
+
+func (u *unitOfWork) LockAccount(id string) (*model.Account, error) {
+	acc, err := scanAccount(u.tx.QueryRow(`SELECT `+accountColumns+` FROM accounts WHERE id = $1 FOR UPDATE`, id))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("could not find or lock account: %w", err)
+	}
+	u.locked[acc.ID] = acc
+	return acc, nil
+}
+
+func (u *unitOfWork) LockAccounts(ids ...uuid.UUID) (map[uuid.UUID]*model.Account, error) {
+	locked := make(map[uuid.UUID]*model.Account, len(ids))
+	for _, id := range sortIDs(ids) {
+		if _, ok := locked[id]; ok {
+			continue
+		}
+		acc, err := u.LockAccount(id.String())
+		if err != nil {
+			return nil, err
+		}
+		if acc != nil {
+			locked[id] = acc
+		}
+	}
+	return locked, nil
+}
+
+func (u *unitOfWork) PostEntry(acc *model.Account, amount money.Money, entryType model.LedgerEntryType, description string) (*model.LedgerEntry, error) {
+	if err := u.requireLocked(acc); err != nil {
+		return nil, err
+	}
+	after, err := acc.BalanceMoney().Add(amount)
+	if err != nil {
+		return nil, err
+	}
+
+	entry, err := applyEntry(u.tx, u.calendar, acc.ID.String(), entryType, amount.Amount, after.Amount, nil, description)
+	if err != nil {
+		return nil, err
+	}
+	line := customerLine(entry, after.Currency)
+	if err := postJournal(u.tx, entry.ID, line, contraLine(line, contraAccount(entryType))); err != nil {
+		return nil, err
+	}
+	if err := u.setBalance(acc, after.Amount); err != nil {
+		return nil, err
+	}
+	return entry, nil
+}
+
+func (u *unitOfWork) BookTransfer(from, to *model.Account, amount money.Money, description string) (*model.Transfer, error) {
+	for _, acc := range []*model.Account{from, to} {
+		if err := u.requireLocked(acc); err != nil {
+			return nil, err
+		}
+	}
+	fromAfter, err := from.BalanceMoney().Sub(amount)
+	if err != nil {
+		return nil, err
+	}
+	toAfter, err := to.BalanceMoney().Add(amount)
+	if err != nil {
+		return nil, err
+	}
+
+	transfer, err := bookTransfer(u.tx, u.calendar, from.ID, to.ID, amount, fromAfter.Amount, toAfter.Amount, description, u.standingOrderID)
+	if err != nil {
+		return nil, err
+	}
+	if err := u.setBalance(from, fromAfter.Amount); err != nil {
+		return nil, err
+	}
+	if err := u.setBalance(to, toAfter.Amount); err != nil {
+		return nil, err
+	}
+	return transfer, nil
+}
+
+func (u *unitOfWork) Product(code string) (*model.Product, error) {
+	p, err := scanProduct(u.tx.QueryRow(`SELECT `+productColumns+` FROM products WHERE code = $1`, code))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("could not read product: %w", err)
+	}
+	return p, nil
+}
+
+func (u *unitOfWork) FeeSchedule(productCode string, op model.FeeOperation) (*model.FeeSchedule, error) {
+	return feeSchedule(u.tx, productCode, op)
+}
+
+func (u *unitOfWork) PostFee(acc *model.Account, fee money.Money, cause *model.LedgerEntry, description string) (*model.LedgerEntry, error) {
+	if err := u.requireLocked(acc); err != nil {
+		return nil, err
+	}
+	after, err := acc.BalanceMoney().Sub(fee)
+	if err != nil {
+		return nil, err
+	}
+
+	var ref *uuid.UUID
+	if cause != nil {
+		ref = &cause.ID
+		if cause.ReferenceID != nil {
+			ref = cause.ReferenceID
+		}
+	}
+	entry, err := writeFee(u.tx, u.calendar, acc.ID, fee, after.Amount, ref, description, u.standingOrderID)
+	if err != nil {
+		return nil, err
+	}
+	if err := u.setBalance(acc, after.Amount); err != nil {
+		return nil, err
+	}
+	return entry, nil
+}
+
+func (u *unitOfWork) ConsumeLimits(acc *model.Account, p *model.Product, amount money.Money) error {
+	if err := u.requireLocked(acc); err != nil {
+		return err
+	}
+	override, err := limitOverride(u.tx, acc.ID)
+	if err != nil {
+		return err
+	}
+	// Usage is counted on the bank's business day, not the database's.
+	day := u.calendar.Today(time.Now())
+	today, _ := usageDays(day)
+	// The account lock serialises debits, so the usage read here cannot
+	// change before it is written back.
+	used, err := limitUsage(u.tx, acc.ID, day)
+	if err != nil {
+		return err
+	}
+	if err := CheckLimits(model.LimitsOf(p, override), used, amount); err != nil {
+		return err
+	}
+	// The customer's accounts are locked one at a time, so their shared
+	// usage is read under the lock of the customer's limits row instead.
+	customer, err := customerLimits(u.tx, acc.CustomerID, acc.Currency, true)
+	if err != nil {
+		return err
+	}
+	if customer != nil {
+		used, err := customerLimitUsage(u.tx, acc.CustomerID, acc.Currency, day)
+		if err != nil {
+			return err
+		}
+		if err := CheckLimits(customer.Limits(), used, amount); err != nil {
+			var limitErr *TransactionLimitError
+			if errors.As(err, &limitErr) {
+				limitErr.Customer = true
+			}
+			return err
+		}
+	}
+
+	_, err = u.tx.Exec(`INSERT INTO limit_usage (account_id, day, amount, transactions) VALUES ($1, $2, $3, 1)
+	                    ON CONFLICT (account_id, day) DO UPDATE SET amount = limit_usage.amount + EXCLUDED.amount,
+	                        transactions = limit_usage.transactions + 1`, acc.ID, today, amount.Amount)
+	if err != nil {
+		return fmt.Errorf("could not record limit usage: %w", err)
+	}
+	// The customer's usage is kept without limits too, so that limits set
+	// during the day or month count what was already spent.
+	_, err = u.tx.Exec(`INSERT INTO customer_limit_usage (customer_id, currency, day, amount, transactions) VALUES ($1, $2, $3, $4, 1)
+	                    ON CONFLICT (customer_id, currency, day) DO UPDATE SET amount = customer_limit_usage.amount + EXCLUDED.amount,
+	                        transactions = customer_limit_usage.transactions + 1`, acc.CustomerID, acc.Currency, today, amount.Amount)
+	if err != nil {
+		return fmt.Errorf("could not record customer limit usage: %w", err)
+	}
+	return nil
+}
+
+func (u *unitOfWork) LockScheduledTransfer(id uuid.UUID) (*model.ScheduledTransfer, error) {
+	st, err := lockScheduledTransfer(u.tx, id.String())
+	if err != nil {
+		return nil, err
+	}
+	u.standingOrderID = st.StandingOrderID
+	return st, nil
+}
+
+func (u *unitOfWork) CompleteScheduledTransfer(st *model.ScheduledTransfer, ref uuid.UUID) error {
+	err := u.tx.QueryRow(`UPDATE scheduled_transfers
+	                      SET status = $1, attempts = attempts + 1, last_error = NULL, transfer_reference_id = $2, updated_at = NOW()
+	                      WHERE id = $3 RETURNING attempts, updated_at`,
+		model.ScheduledExecuted, ref, st.ID).Scan(&st.Attempts, &st.UpdatedAt)
+	if err != nil {
+		return fmt.Errorf("could not complete scheduled transfer: %w", err)
+	}
+	st.Status = model.ScheduledExecuted
+	st.LastError = ""
+	st.TransferReferenceID = &ref
+	return nil
+}
+
+func (u *unitOfWork) LockStandingOrder(id uuid.UUID) (*model.StandingOrder, error) {
+	return lockStandingOrder(u.tx, id.String())
+}
+
+func (u *unitOfWork) ScheduleStandingOrderPayment(so *model.StandingOrder, payment *model.ScheduledTransfer) error {
+	payment.StandingOrderID = &so.ID
+	err := u.tx.QueryRow(`INSERT INTO scheduled_transfers (id, from_account_id, to_account_id, amount, currency, description, execute_on, status, next_attempt_at, standing_order_id)
+	                      VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10) RETURNING created_at, updated_at`,
+		payment.ID, payment.FromAccountID, payment.ToAccountID, payment.Amount, payment.Currency, payment.Description,
+		payment.ExecuteOn, model.ScheduledPending, payment.NextAttemptAt, so.ID).
+		Scan(&payment.CreatedAt, &payment.UpdatedAt)
+	if err != nil {
+		return fmt.Errorf("could not schedule standing order payment: %w", err)
+	}
+	payment.Status = model.ScheduledPending
+
+	err = u.tx.QueryRow(`UPDATE standing_orders SET occurrences = $1, next_due_on = $2, next_execute_on = $3, status = $4, updated_at = NOW()
+	                     WHERE id = $5 RETURNING updated_at`,
+		so.Occurrences, nullable(so.NextDueOn), nullable(so.NextExecuteOn), so.Status, so.ID).Scan(&so.UpdatedAt)
+	if err != nil {
+		return fmt.Errorf("could not advance standing order: %w", err)
+	}
+	return nil
+}
+
+func (u *unitOfWork) LockFXQuote(id uuid.UUID) (*model.FXQuote, error) {
+	var expired bool
+	q, err := scanFXQuote(u.tx.QueryRow(`SELECT `+fxQuoteColumns+`, expires_at <= NOW() FROM fx_quotes WHERE id = $1 FOR UPDATE`, id), &expired)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("could not find or lock quote: %w", err)
+	}
+	if q.ExecutedAt != nil {
+		return nil, ErrQuoteExecuted
+	}
+	if expired {
+		return nil, ErrQuoteExpired
+	}
+	return q, nil
+}
+
+func (u *unitOfWork) BookExchange(from, to *model.Account, q *model.FXQuote) (*model.CurrencyExchange, error) {
+	for _, acc := range []*model.Account{from, to} {
+		if err := u.requireLocked(acc); err != nil {
+			return nil, err
+		}
+	}
+	sell := money.New(q.SellAmount, q.SellCurrency)
+	buy := money.New(q.BuyAmount, q.BuyCurrency)
+	fromAfter, err := from.BalanceMoney().Sub(sell)
+	if err != nil {
+		return nil, err
+	}
+	toAfter, err := to.BalanceMoney().Add(buy)
+	if err != nil {
+		return nil, err
+	}
+
+	ref := uuid.New()
+	description := fmt.Sprintf("Exchange %s to %s at %s", sell, buy, q.Rate)
+	debit, err := writeEntry(u.tx, u.calendar, &model.LedgerEntry{
+		AccountID: from.ID, Type: model.FXExchange, Amount: sell.Amount.Neg(), BalanceAfter: fromAfter.Amount,
+		ReferenceID: &ref, Description: description, FXRate: &q.Rate,
+	})
+	if err != nil {
+		return nil, err
+	}
+	credit, err := writeEntry(u.tx, u.calendar, &model.LedgerEntry{
+		AccountID: to.ID, Type: model.FXExchange, Amount: buy.Amount, BalanceAfter: toAfter.Amount,
+		ReferenceID: &ref, Description: description, FXRate: &q.Rate,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// The bank's FX position takes the sold currency and pays out the
+	// bought one, so the posting balances in each currency on its own.
+	sold, bought := customerLine(debit, sell.Currency), customerLine(credit, buy.Currency)
+	if err := postJournal(u.tx, ref, sold, contraLine(sold, model.GLFXPosition), bought, contraLine(bought, model.GLFXPosition)); err != nil {
+		return nil, err
+	}
+	if _, err := u.tx.Exec(`UPDATE fx_quotes SET executed_at = $2, reference_id = $3 WHERE id = $1`, q.ID, debit.CreatedAt, ref); err != nil {
+		return nil, fmt.Errorf("could not mark quote executed: %w", err)
+	}
+	q.ExecutedAt, q.ReferenceID = &debit.CreatedAt, &ref
+	if err := u.setBalance(from, fromAfter.Amount); err != nil {
+		return nil, err
+	}
+	if err := u.setBalance(to, toAfter.Amount); err != nil {
+		return nil, err
+	}
+
+	return &model.CurrencyExchange{
+		ReferenceID: ref,
+		QuoteID:     q.ID,
+		Rate:        q.Rate,
+		Debit:       *debit,
+		Credit:      *credit,
+		CreatedAt:   debit.CreatedAt,
+	}, nil
+}
+
+func (u *unitOfWork) PlaceHold(acc *model.Account, h *model.Hold) error {
+	if err := u.requireLocked(acc); err != nil {
+		return err
+	}
+	err := u.tx.QueryRow(`INSERT INTO holds (id, account_id, amount, captured, currency, status, description, expires_at)
+	                      VALUES ($1, $2, $3, 0, $4, $5, $6, $7) RETURNING created_at, updated_at`,
+		h.ID, acc.ID, h.Amount, h.Currency, model.HoldActive, h.Description, h.ExpiresAt).Scan(&h.CreatedAt, &h.UpdatedAt)
+	if err != nil {
+		return fmt.Errorf("could not create hold: %w", err)
+	}
+	h.AccountID = acc.ID
+	h.Status = model.HoldActive
+	h.Captured = money.Amount{}
+	held, err := acc.HeldAmount.Add(h.Amount)
+	if err != nil {
+		return err
+	}
+	return acc.SetHeld(held)
+}
+
+func (u *unitOfWork) LockHold(id uuid.UUID) (*model.Hold, error) {
+	return lockHold(u.tx, id.String())
+}
+
+func (u *unitOfWork) CaptureHold(acc *model.Account, h *model.Hold, amount money.Money, final bool) (*model.LedgerEntry, error) {
+	if err := u.requireLocked(acc); err != nil {
+		return nil, err
+	}
+	if h.AccountID != acc.ID {
+		return nil, fmt.Errorf("hold %s does not belong to account %s", h.ID, acc.ID)
+	}
+	after, err := acc.BalanceMoney().Sub(amount)
+	if err != nil {
+		return nil, err
+	}
+
+	ref := h.ID
+	entry, err := writeEntry(u.tx, u.calendar, &model.LedgerEntry{
+		AccountID: acc.ID, Type: model.HoldCapture, Amount: amount.Amount.Neg(), BalanceAfter: after.Amount,
+		ReferenceID: &ref, Description: h.Description,
+	})
+	if err != nil {
+		return nil, err
+	}
+	line := customerLine(entry, amount.Currency)
+	if err := postJournal(u.tx, entry.ID, line, contraLine(line, contraAccount(model.HoldCapture))); err != nil {
+		return nil, err
+	}
+
+	// The captured part stops being held, and so does the rest of a hold
+	// captured for good.
+	released, err := h.Remaining()
+	if err != nil {
+		return nil, err
+	}
+	if h.Captured, err = h.Captured.Add(amount.Amount); err != nil {
+		return nil, err
+	}
+	if final || released.Cmp(amount.Amount) == 0 {
+		h.Status = model.HoldCaptured
+	} else {
+		released = amount.Amount
+	}
+	err = u.tx.QueryRow(`UPDATE holds SET captured = $1, status = $2, updated_at = NOW() WHERE id = $3 RETURNING updated_at`,
+		h.Captured, h.Status, h.ID).Scan(&h.UpdatedAt)
+	if err != nil {
+		return nil, fmt.Errorf("could not update hold: %w", err)
+	}
+	held, err := acc.HeldAmount.Sub(released)
+	if err != nil {
+		return nil, err
+	}
+	acc.Balance = after.Amount
+	if err := acc.SetHeld(held); err != nil {
+		return nil, err
+	}
+	return entry, nil
+}
+
+func (u *unitOfWork) ChangeStatus(acc *model.Account, change model.StatusChange, payoutRef *uuid.UUID) (*model.AccountStatusChange, error) {
+	if err := u.requireLocked(acc); err != nil {
+		return nil, err
+	}
+	// A closed account cannot settle its holds, so they are given up.
+	if change.To == model.AccountClosed {
+		_, err := u.tx.Exec(`UPDATE holds SET status = $1, updated_at = NOW() WHERE account_id = $2 AND status = $3`,
+			model.HoldReleased, acc.ID, model.HoldActive)
+		if err != nil {
+			return nil, fmt.Errorf("could not release holds: %w", err)
+		}
+		if err := acc.SetHeld(money.Amount{}); err != nil {
+			return nil, err
+		}
+	}
+	if _, err := u.tx.Exec(`UPDATE accounts SET status = $1, updated_at = NOW() WHERE id = $2`, change.To, acc.ID); err != nil {
+		return nil, fmt.Errorf("could not update account status: %w", err)
+	}
+
+	rec := &model.AccountStatusChange{
+		ID:                uuid.New(),
+		AccountID:         acc.ID,
+		FromStatus:        acc.Status,
+		ToStatus:          change.To,
+		Reason:            change.Reason,
+		Note:              change.Note,
+		PayoutReferenceID: payoutRef,
+	}
+	err := u.tx.QueryRow(`INSERT INTO account_status_changes (id, account_id, from_status, to_status, reason, note, payout_reference_id)
+	                      VALUES ($1, $2, $3, $4, $5, $6, $7) RETURNING created_at`,
+		rec.ID, rec.AccountID, rec.FromStatus, rec.ToStatus, rec.Reason, rec.Note, rec.PayoutReferenceID).Scan(&rec.CreatedAt)
+	if err != nil {
+		return nil, fmt.Errorf("could not record status change: %w", err)
+	}
+	acc.Status = change.To
+	return rec, nil
+}
+
+func (u *unitOfWork) SetOverdraftLimit(acc *model.Account, change *model.OverdraftLimitChange) error {
+	if err := u.requireLocked(acc); err != nil {
+		return err
+	}
+	if _, err := u.tx.Exec(`UPDATE accounts SET overdraft_limit = $1, updated_at = NOW() WHERE id = $2`, change.NewLimit, acc.ID); err != nil {
+		return fmt.Errorf("could not update overdraft limit: %w", err)
+	}
+	change.AccountID, change.OldLimit, change.Currency = acc.ID, acc.OverdraftLimit, acc.Currency
+	err := u.tx.QueryRow(`INSERT INTO overdraft_limit_changes (id, account_id, old_limit, new_limit, currency, changed_by, note)
+	                      VALUES ($1, $2, $3, $4, $5, $6, $7) RETURNING created_at`,
+		change.ID, change.AccountID, change.OldLimit, change.NewLimit, change.Currency, change.ChangedBy, change.Note).Scan(&change.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("could not record overdraft limit change: %w", err)
+	}
+	acc.OverdraftLimit = change.NewLimit
+	return nil
+}
+
+func (u *unitOfWork) InterestPosted(acc *model.Account, entryType model.LedgerEntryType, businessDate time.Time) (bool, error) {
+	if err := u.requireLocked(acc); err != nil {
+		return false, err
+	}
+	// The account lock serialises postings, so the answer holds until the
+	// transaction ends.
+	var posted bool
+	err := u.tx.QueryRow(`SELECT EXISTS (SELECT 1 FROM interest_postings WHERE account_id = $1 AND kind = $2 AND business_date = $3)`,
+		acc.ID, interestKind(entryType), businessDate.Format(calendar.DateLayout)).Scan(&posted)
+	if err != nil {
+		return false, fmt.Errorf("could not check interest postings: %w", err)
+	}
+	return posted, nil
+}
+
+func (u *unitOfWork) PostInterest(acc *model.Account, entryType model.LedgerEntryType, amount money.Money, businessDate time.Time, description string) (*model.LedgerEntry, error) {
+	if err := u.requireLocked(acc); err != nil {
+		return nil, err
+	}
+	after, err := acc.BalanceMoney().Add(amount)
+	if err != nil {
+		return nil, err
+	}
+
+	day := businessDate.Format(calendar.DateLayout)
+	entry, err := writeEntry(u.tx, u.calendar, &model.LedgerEntry{
+		AccountID: acc.ID, Type: entryType, Amount: amount.Amount, BalanceAfter: after.Amount,
+		Description: description, ValueDate: day,
+	})
+	if err != nil {
+		return nil, err
+	}
+	line := customerLine(entry, amount.Currency)
+	if err := postJournal(u.tx, entry.ID, line, contraLine(line, contraAccount(entryType))); err != nil {
+		return nil, err
+	}
+	_, err = u.tx.Exec(`INSERT INTO interest_postings (account_id, kind, business_date, amount, ledger_entry_id) VALUES ($1, $2, $3, $4, $5)`,
+		acc.ID, interestKind(entryType), day, entry.Amount, entry.ID)
+	if err != nil {
+		return nil, fmt.Errorf("could not record interest posting: %w", err)
+	}
+	if entryType == model.Interest {
+		err = u.tx.QueryRow(`UPDATE accounts SET accrued_interest = accrued_interest - $1 WHERE id = $2 RETURNING accrued_interest`,
+			amount.Amount, acc.ID).Scan(&acc.AccruedInterest)
+		if err != nil {
+			return nil, fmt.Errorf("could not update accrued interest: %w", err)
+		}
+	}
+	if err := u.setBalance(acc, after.Amount); err != nil {
+		return nil, err
+	}
+	return entry, nil
+}
+
+func (u *unitOfWork) InterestAccrued(acc *model.Account, businessDate time.Time) (bool, error) {
+	if err := u.requireLocked(acc); err != nil {
+		return false, err
+	}
+	var accrued bool
+	err := u.tx.QueryRow(`SELECT EXISTS (SELECT 1 FROM interest_accruals WHERE account_id = $1 AND business_date = $2)`,
+		acc.ID, businessDate.Format(calendar.DateLayout)).Scan(&accrued)
+	if err != nil {
+		return false, fmt.Errorf("could not check interest accruals: %w", err)
+	}
+	return accrued, nil
+}
+
+func (u *unitOfWork) EndOfDayBalance(acc *model.Account, businessDate time.Time) (money.Amount, error) {
+	if err := u.requireLocked(acc); err != nil {
+		return money.Amount{}, err
+	}
+	var since money.Amount
+	err := u.tx.QueryRow(`SELECT COALESCE(SUM(amount), 0) FROM ledger_entries WHERE account_id = $1 AND value_date > $2`,
+		acc.ID, businessDate.Format(calendar.DateLayout)).Scan(&since)
+	if err != nil {
+		return money.Amount{}, fmt.Errorf("could not compute end-of-day balance: %w", err)
+	}
+	return acc.Balance.Sub(since)
+}
+
+func (u *unitOfWork) RecordAccrual(acc *model.Account, accrual *model.InterestAccrual, rate money.Rate, dayCount money.DayCount) error {
+	if err := u.requireLocked(acc); err != nil {
+		return err
+	}
+	_, err := u.tx.Exec(`INSERT INTO interest_accruals (account_id, business_date, balance, rate, day_count, days, amount) VALUES ($1, $2, $3, $4, $5, $6, $7)`,
+		acc.ID, accrual.BusinessDate, accrual.Balance, rate, dayCount, accrual.Days, accrual.Amount)
+	if err != nil {
+		return fmt.Errorf("could not record interest accrual: %w", err)
+	}
+	err = u.tx.QueryRow(`UPDATE accounts SET accrued_interest = accrued_interest + $1 WHERE id = $2 RETURNING accrued_interest`,
+		accrual.Amount, acc.ID).Scan(&acc.AccruedInterest)
+	if err != nil {
+		return fmt.Errorf("could not update accrued interest: %w", err)
+	}
+	accrual.Accrued = acc.AccruedInterest
+	return nil
+}
+
+func (u *unitOfWork) MaintenanceFeeCharged(acc *model.Account, month time.Time) (bool, error) {
+	if err := u.requireLocked(acc); err != nil {
+		return false, err
+	}
+	var charged bool
+	err := u.tx.QueryRow(`SELECT EXISTS (SELECT 1 FROM maintenance_fee_charges WHERE account_id = $1 AND month = $2)`,
+		acc.ID, month.Format(calendar.DateLayout)).Scan(&charged)
+	if err != nil {
+		return false, fmt.Errorf("could not check maintenance fee charges: %w", err)
+	}
+	return charged, nil
+}
+
+func (u *unitOfWork) RecordMaintenanceFee(entry *model.LedgerEntry, month time.Time) error {
+	_, err := u.tx.Exec(`INSERT INTO maintenance_fee_charges (account_id, month, amount, ledger_entry_id) VALUES ($1, $2, $3, $4)`,
+		entry.AccountID, month.Format(calendar.DateLayout), entry.Amount.Neg(), entry.ID)
+	if err != nil {
+		return fmt.Errorf("could not record maintenance fee charge: %w", err)
+	}
+	return nil
+}
+
+func (u *unitOfWork) requireLocked(acc *model.Account) error {
+	if acc == nil || u.locked[acc.ID] != acc {
+		return ErrAccountNotLocked
+	}
+	return nil
+}
+
+// setBalance keeps a locked account in step with the entries written to it,
+// so that rules checked after a write see the new balance.
+func (u *unitOfWork) setBalance(acc *model.Account, balance money.Amount) error {
+	acc.Balance = balance
+	return acc.SetHeld(acc.HeldAmount)
+}
@@ -0,0 +1,364 @@
+package repository
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"go-web-server/services/account-service/model"
+	"go-web-server/services/account-service/money"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// expectLockAccount expects a unit of work to lock an account and read its
+// balance, holds and overdraft limit.
+func expectLockAccount(mock sqlmock.Sqlmock, id uuid.UUID, balance, held, limit string) {
+	mock.ExpectQuery("SELECT id, customer_id, (.+) FROM accounts WHERE id = (.+) FOR UPDATE").
+		WithArgs(id.String()).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "customer_id", "account_number", "currency", "balance", "status", "created_at", "updated_at", "overdraft_limit", "kind", "accrued_interest", "product_code", "held"}).
+			AddRow(id, uuid.New(), "PL0000000001", "PLN", balance, "active", time.Now(), time.Now(), limit, "current", "0", "current", held))
+}
+
+func TestInTx_LockAccount(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("error opening mock db: %s", err)
+	}
+	defer db.Close()
+
+	repo := NewPostgresAccountRepository(db)
+	accountID, missing := uuid.New(), uuid.New()
+
+	mock.ExpectBegin()
+	expectLockAccount(mock, accountID, "100.0000", "80.0000", "500.0000")
+	mock.ExpectQuery("SELECT id, customer_id, (.+) FROM accounts WHERE id = (.+) FOR UPDATE").
+		WithArgs(missing.String()).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
+	mock.ExpectCommit()
+
+	err = repo.InTx(nil, nil, func(uow UnitOfWork) error {
+		acc, err := uow.LockAccount(accountID.String())
+		require.NoError(t, err)
+		assert.Equal(t, money.MustParseAmount("20"), acc.AvailableBalance)
+		assert.Equal(t, money.MustParseAmount("500"), acc.OverdraftLimit)
+
+		acc, err = uow.LockAccount(missing.String())
+		assert.NoError(t, err)
+		assert.Nil(t, acc)
+		return nil
+	})
+	assert.NoError(t, err)
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestInTx_LockError(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("error opening mock db: %s", err)
+	}
+	defer db.Close()
+
+	repo := NewPostgresAccountRepository(db)
+	accountID := uuid.New()
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT id, customer_id, (.+) FROM accounts WHERE id = (.+) FOR UPDATE").
+		WithArgs(accountID.String()).
+		WillReturnError(fmt.Errorf("db error"))
+	mock.ExpectRollback()
+
+	err = repo.InTx(nil, nil, func(uow UnitOfWork) error {
+		_, err := uow.LockAccount(accountID.String())
+		return err
+	})
+	assert.Error(t, err)
+}
+
+func TestInTx_PostEntry(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	repo := NewPostgresAccountRepository(db)
+	accountID := uuid.New()
+	amount := money.New(money.MustParseAmount("50.25"), money.PLN)
+
+	mock.ExpectBegin()
+	// Lock for update
+	expectLockAccount(mock, accountID, "100.1000", "0", "0")
+
+	// Update account balance
+	expectChainHead(mock, accountID.String(), 0, time.Now())
+	mock.ExpectExec("UPDATE accounts SET balance = (.+) WHERE id =").
+		WithArgs("150.35", int64(1), sqlmock.AnyArg(), accountID.String()).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+
+	// Insert ledger entry
+	mock.ExpectExec("INSERT INTO ledger_entries").
+		WithArgs(sqlmock.AnyArg(), accountID.String(), model.Deposit, "50.25", "150.35", nil, "Test deposit", nil, sqlmock.AnyArg(), int64(1), "", sqlmock.AnyArg(), sqlmock.AnyArg(), nil).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+
+	// Post to the general ledger: Dr cash, Cr customer deposits
+	mock.ExpectExec("INSERT INTO journal_lines").
+		WithArgs(
+			sqlmock.AnyArg(), model.GLCustomerDeposits, accountID, sqlmock.AnyArg(), money.PLN, "0.00", "50.25",
+			sqlmock.AnyArg(), model.GLCash, nil, sqlmock.AnyArg(), money.PLN, "50.25", "0.00",
+		).
+		WillReturnResult(sqlmock.NewResult(0, 2))
+
+	mock.ExpectCommit()
+
+	var entry *model.LedgerEntry
+	err = repo.InTx(nil, &entry, func(uow UnitOfWork) error {
+		acc, err := uow.LockAccount(accountID.String())
+		if err != nil {
+			return err
+		}
+		entry, err = uow.PostEntry(acc, amount, model.Deposit, "Test deposit")
+		assert.Equal(t, money.MustParseAmount("150.35"), acc.AvailableBalance)
+		return err
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, money.MustParseAmount("150.35"), entry.BalanceAfter)
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestInTx_PostEntryChecks(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("error opening mock db: %s", err)
+	}
+	defer db.Close()
+
+	repo := NewPostgresAccountRepository(db)
+	accountID := uuid.New()
+
+	mock.ExpectBegin()
+	expectLockAccount(mock, accountID, "100.0000", "0", "0")
+	mock.ExpectRollback()
+
+	err = repo.InTx(nil, nil, func(uow UnitOfWork) error {
+		acc, err := uow.LockAccount(accountID.String())
+		require.NoError(t, err)
+		_, err = uow.PostEntry(acc, money.New(money.MustParseAmount("10"), money.EUR), model.Deposit, "Test")
+		assert.ErrorIs(t, err, money.ErrCurrencyMismatch)
+
+		// An account read outside the unit of work is not locked.
+		_, err = uow.PostEntry(&model.Account{ID: accountID, Currency: money.PLN}, money.New(money.MustParseAmount("10"), money.PLN), model.Deposit, "Test")
+		return err
+	})
+	assert.ErrorIs(t, err, ErrAccountNotLocked)
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestInTx_BookTransferLocksInOrder(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("error opening mock db: %s", err)
+	}
+	defer db.Close()
+
+	repo := NewPostgresAccountRepository(db)
+	low := uuid.MustParse("00000000-0000-0000-0000-000000000001")
+	high := uuid.MustParse("ffffffff-0000-0000-0000-000000000001")
+	amount := money.New(money.MustParseAmount("30"), money.PLN)
+	now := time.Now()
+
+	// Money flows from the higher id to the lower one, but the lower id must
+	// still be locked first.
+	mock.ExpectBegin()
+	expectLockAccount(mock, low, "5.0000", "0", "0")
+	expectLockAccount(mock, high, "100.0000", "0", "0")
+
+	expectChainHead(mock, high.String(), 0, now)
+	mock.ExpectExec("UPDATE accounts SET balance = (.+) WHERE id =").
+		WithArgs("70.00", int64(1), sqlmock.AnyArg(), high.String()).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec("INSERT INTO ledger_entries").
+		WithArgs(sqlmock.AnyArg(), high.String(), model.TransferOut, "-30.00", "70.00", sqlmock.AnyArg(), "Rent", nil, now, int64(1), "", sqlmock.AnyArg(), sqlmock.AnyArg(), nil).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	expectChainHead(mock, low.String(), 0, now)
+	mock.ExpectExec("UPDATE accounts SET balance = (.+) WHERE id =").
+		WithArgs("35.00", int64(1), sqlmock.AnyArg(), low.String()).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec("INSERT INTO ledger_entries").
+		WithArgs(sqlmock.AnyArg(), low.String(), model.TransferIn, "30.00", "35.00", sqlmock.AnyArg(), "Rent", nil, now, int64(1), "", sqlmock.AnyArg(), sqlmock.AnyArg(), nil).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec("INSERT INTO journal_lines").
+		WithArgs(
+			sqlmock.AnyArg(), model.GLCustomerDeposits, high, sqlmock.AnyArg(), money.PLN, "30.00", "0.00",
+			sqlmock.AnyArg(), model.GLCustomerDeposits, low, sqlmock.AnyArg(), money.PLN, "0.00", "30.00",
+		).
+		WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectCommit()
+
+	var transfer *model.Transfer
+	err = repo.InTx(nil, &transfer, func(uow UnitOfWork) error {
+		locked, err := uow.LockAccounts(high, low)
+		if err != nil {
+			return err
+		}
+		transfer, err = uow.BookTransfer(locked[high], locked[low], amount, "Rent")
+		return err
+	})
+	require.NoError(t, err)
+	assert.Equal(t, transfer.ReferenceID, *transfer.Debit.ReferenceID)
+	assert.Equal(t, transfer.ReferenceID, *transfer.Credit.ReferenceID)
+	assert.Equal(t, money.MustParseAmount("70"), transfer.Debit.BalanceAfter)
+	assert.Equal(t, money.MustParseAmount("35"), transfer.Credit.BalanceAfter)
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestInTx_RuleFailureRollsBack(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("error opening mock db: %s", err)
+	}
+	defer db.Close()
+
+	repo := NewPostgresAccountRepository(db)
+	accountID := uuid.New()
+	rejected := errors.New("insufficient funds")
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("INSERT INTO idempotency_keys (.+) ON CONFLICT").
+		WithArgs("alice", "retry-1", "abc").
+		WillReturnRows(sqlmock.NewRows([]string{"key"}).AddRow("retry-1"))
+	expectLockAccount(mock, accountID, "10.0000", "0", "0")
+	mock.ExpectRollback()
+
+	// Neither a ledger entry nor the idempotency key outlives the rejection.
+	err = repo.InTx(&model.IdempotencyKey{Owner: "alice", Key: "retry-1", Fingerprint: "abc"}, nil, func(uow UnitOfWork) error {
+		if _, err := uow.LockAccount(accountID.String()); err != nil {
+			return err
+		}
+		return rejected
+	})
+	assert.Same(t, rejected, err)
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestInTx_IdempotencyKeyRecordedInSameTransaction(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("error opening mock db: %s", err)
+	}
+	defer db.Close()
+
+	repo := NewPostgresAccountRepository(db)
+	accountID := uuid.New()
+	idem := &model.IdempotencyKey{Owner: "alice", Key: "retry-1", Fingerprint: "abc"}
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("INSERT INTO idempotency_keys (.+) ON CONFLICT").
+		WithArgs("alice", "retry-1", "abc").
+		WillReturnRows(sqlmock.NewRows([]string{"key"}).AddRow("retry-1"))
+	expectLockAccount(mock, accountID, "10.0000", "0", "0")
+	expectChainHead(mock, accountID.String(), 0, time.Now())
+	mock.ExpectExec("UPDATE accounts SET balance").
+		WithArgs("15.00", int64(1), sqlmock.AnyArg(), accountID.String()).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec("INSERT INTO ledger_entries").
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec("INSERT INTO journal_lines").
+		WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectExec("UPDATE idempotency_keys SET response").
+		WithArgs("alice", "retry-1", sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+
+	var entry *model.LedgerEntry
+	err = repo.InTx(idem, &entry, func(uow UnitOfWork) error {
+		acc, err := uow.LockAccount(accountID.String())
+		if err != nil {
+			return err
+		}
+		entry, err = uow.PostEntry(acc, money.New(money.MustParseAmount("5"), money.PLN), model.Deposit, "Test")
+		return err
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, money.MustParseAmount("15"), entry.BalanceAfter)
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestInTx_IdempotentReplay(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("error opening mock db: %s", err)
+	}
+	defer db.Close()
+
+	repo := NewPostgresAccountRepository(db)
+	accountID := uuid.New()
+	idem := &model.IdempotencyKey{Owner: "alice", Key: "retry-1", Fingerprint: "abc"}
+	stored := fmt.Sprintf(`{"id":"%s","accountId":"%s","type":"deposit","amount":"5.00","balanceAfter":"15.00","description":"Test","createdAt":"2024-01-01T00:00:00Z"}`, uuid.New(), accountID)
+
+	// The key already exists: fn does not run and the original entry comes
+	// back.
+	mock.ExpectBegin()
+	mock.ExpectQuery("INSERT INTO idempotency_keys (.+) ON CONFLICT").
+		WithArgs("alice", "retry-1", "abc").
+		WillReturnRows(sqlmock.NewRows([]string{"key"}))
+	mock.ExpectQuery("SELECT fingerprint, response FROM idempotency_keys").
+		WithArgs("alice", "retry-1").
+		WillReturnRows(sqlmock.NewRows([]string{"fingerprint", "response"}).AddRow("abc", []byte(stored)))
+	mock.ExpectRollback()
+
+	var entry *model.LedgerEntry
+	err = repo.InTx(idem, &entry, func(uow UnitOfWork) error {
+		t.Error("fn must not run for a replayed key")
+		return nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, money.MustParseAmount("15"), entry.BalanceAfter)
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestInTx_IdempotencyKeyReused(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("error opening mock db: %s", err)
+	}
+	defer db.Close()
+
+	repo := NewPostgresAccountRepository(db)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("INSERT INTO idempotency_keys (.+) ON CONFLICT").
+		WillReturnRows(sqlmock.NewRows([]string{"key"}))
+	mock.ExpectQuery("SELECT fingerprint, response FROM idempotency_keys").
+		WillReturnRows(sqlmock.NewRows([]string{"fingerprint", "response"}).AddRow("other", []byte(`{}`)))
+	mock.ExpectRollback()
+
+	var entry *model.LedgerEntry
+	err = repo.InTx(&model.IdempotencyKey{Owner: "alice", Key: "retry-1", Fingerprint: "abc"}, &entry, func(uow UnitOfWork) error { return nil })
+	assert.ErrorIs(t, err, ErrIdempotencyKeyReused)
+}
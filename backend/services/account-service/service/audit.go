@@ -0,0 +1,166 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"go-web-server/services/account-service/audit"
+	"go-web-server/services/account-service/model"
+	"go-web-server/services/account-service/repository"
+
+	"github.com/google/uuid"
+)
+
+var (
+	// ErrCheckpointNotFound is returned when the requested ledger checkpoint
+	// does not exist.
+	ErrCheckpointNotFound = errors.New("ledger checkpoint not found")
+	// ErrCheckpointSigningDisabled is returned when a checkpoint is requested
+	// but no signing key is configured.
+	ErrCheckpointSigningDisabled = errors.New("ledger checkpoint signing is not configured")
+)
+
+// checkpointListLimit caps ListCheckpoints.
+const checkpointListLimit = 100
+
+// AuditService verifies the ledger hash chains and keeps signed checkpoints
+// of their heads.
+type AuditService interface {
+	// VerifyChains walks the hash chain of accountID, or of every account
+	// when accountID is empty, and reports the first broken link of each.
+	VerifyChains(ctx context.Context, accountID string) (*model.HashChainVerification, error)
+	CreateCheckpoint(ctx context.Context) (*model.LedgerCheckpoint, error)
+	GetCheckpoint(ctx context.Context, checkpointID string) (*model.LedgerCheckpoint, error)
+	ListCheckpoints(ctx context.Context) ([]model.LedgerCheckpoint, error)
+}
+
+type auditService struct {
+	repo   repository.AccountRepository
+	signer *audit.Signer
+	now    func() time.Time
+}
+
+// NewAuditService creates the service. signer may be nil, in which case
+// chains can be verified but checkpoints cannot be created or checked.
+func NewAuditService(repo repository.AccountRepository, signer *audit.Signer) AuditService {
+	return &auditService{repo: repo, signer: signer, now: time.Now}
+}
+
+// VerifyChains also compares every chain with the latest checkpoint, which
+// catches a chain rewritten with freshly computed hashes. A checkpoint whose
+// signature does not check out against the configured key fails the
+// verification by itself.
+func (s *auditService) VerifyChains(ctx context.Context, accountID string) (*model.HashChainVerification, error) {
+	var heads []model.ChainHead
+	if accountID != "" {
+		id, err := uuid.Parse(accountID)
+		if err != nil {
+			return nil, &ValidationError{Field: "accountId", Err: err}
+		}
+		heads = []model.ChainHead{{AccountID: id}}
+	} else {
+		var err error
+		if heads, err = s.repo.ChainHeads(); err != nil {
+			return nil, fmt.Errorf("failed to list chain heads: %w", err)
+		}
+	}
+
+	result := &model.HashChainVerification{Valid: true, Breaks: []model.HashChainBreak{}}
+	checkpointed := make(map[uuid.UUID]*model.ChainHead)
+	if s.signer != nil {
+		cp, err := s.repo.LatestCheckpoint()
+		if err != nil {
+			return nil, fmt.Errorf("failed to get latest checkpoint: %w", err)
+		}
+		if cp != nil {
+			result.CheckpointID = &cp.ID
+			if err := audit.VerifyCheckpoint(cp, s.signer.PublicKey()); err != nil {
+				// The checkpoints table itself was edited.
+				result.Valid = false
+				result.CheckpointError = err.Error()
+			} else {
+				for i := range cp.Heads {
+					checkpointed[cp.Heads[i].AccountID] = &cp.Heads[i]
+				}
+			}
+		}
+	}
+
+	seen := make(map[uuid.UUID]bool, len(heads))
+	for _, head := range heads {
+		seen[head.AccountID] = true
+		chain, err := s.repo.LedgerChain(head.AccountID.String())
+		if err != nil {
+			return nil, fmt.Errorf("failed to read ledger chain: %w", err)
+		}
+		if chain == nil {
+			if accountID != "" {
+				return nil, ErrAccountNotFound
+			}
+			// Deleted since ChainHeads; Verify reports it against the
+			// checkpoint, if any.
+			chain = &model.LedgerChain{Head: head}
+		}
+		result.AccountsChecked++
+		result.EntriesChecked += len(chain.Entries)
+		if b := audit.Verify(chain, checkpointed[head.AccountID]); b != nil {
+			result.Breaks = append(result.Breaks, *b)
+			result.Valid = false
+		}
+	}
+	if accountID == "" {
+		// Accounts removed altogether since the checkpoint.
+		for id, head := range checkpointed {
+			if !seen[id] && head.Sequence > 0 {
+				result.Breaks = append(result.Breaks, model.HashChainBreak{
+					AccountID: id, Sequence: head.Sequence, Reason: model.ChainCheckpointMismatch, Expected: head.Hash,
+				})
+				result.Valid = false
+			}
+		}
+	}
+	return result, nil
+}
+
+// CreateCheckpoint signs and stores the current head of every chain.
+func (s *auditService) CreateCheckpoint(ctx context.Context) (*model.LedgerCheckpoint, error) {
+	if s.signer == nil {
+		return nil, ErrCheckpointSigningDisabled
+	}
+	heads, err := s.repo.ChainHeads()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list chain heads: %w", err)
+	}
+
+	cp := &model.LedgerCheckpoint{ID: uuid.New(), CreatedAt: s.now(), Heads: heads}
+	s.signer.Sign(cp)
+	if err := s.repo.SaveCheckpoint(cp); err != nil {
+		return nil, fmt.Errorf("failed to save checkpoint: %w", err)
+	}
+	return cp, nil
+}
+
+func (s *auditService) GetCheckpoint(ctx context.Context, checkpointID string) (*model.LedgerCheckpoint, error) {
+	if _, err := uuid.Parse(checkpointID); err != nil {
+		return nil, &ValidationError{Field: "checkpointId", Err: err}
+	}
+	cp, err := s.repo.GetCheckpoint(checkpointID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get checkpoint: %w", err)
+	}
+	if cp == nil {
+		return nil, ErrCheckpointNotFound
+	}
+	return cp, nil
+}
+
+// ListCheckpoints returns the newest checkpoints without their heads.
+func (s *auditService) ListCheckpoints(ctx context.Context) ([]model.LedgerCheckpoint, error) {
+	checkpoints, err := s.repo.ListCheckpoints(checkpointListLimit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list checkpoints: %w", err)
+	}
+	return checkpoints, nil
+}
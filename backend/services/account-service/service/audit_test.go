@@ -0,0 +1,169 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	"go-web-server/services/account-service/audit"
+	"go-web-server/services/account-service/model"
+	"go-web-server/services/account-service/money"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func testSigner(t *testing.T) *audit.Signer {
+	signer, err := audit.NewSigner(bytes.Repeat([]byte{1}, 32))
+	require.NoError(t, err)
+	return signer
+}
+
+// sealedChain returns a valid chain with one deposit per amount.
+func sealedChain(accountID uuid.UUID, amounts ...string) *model.LedgerChain {
+	chain := &model.LedgerChain{Head: model.ChainHead{AccountID: accountID}}
+	balance := money.Amount{}
+	for i, a := range amounts {
+		amount := money.MustParseAmount(a)
+		balance, _ = balance.Add(amount)
+		e := model.LedgerEntry{
+			ID: uuid.New(), AccountID: accountID, Type: model.Deposit, Amount: amount, BalanceAfter: balance,
+			CreatedAt: time.Date(2024, 3, 1, 10, i, 0, 0, time.UTC), Sequence: int64(i + 1), PrevHash: chain.Head.Hash,
+		}
+		e.Hash = audit.EntryHash(&e)
+		chain.Entries = append(chain.Entries, e)
+		chain.Head.Sequence, chain.Head.Hash = e.Sequence, e.Hash
+	}
+	return chain
+}
+
+func TestVerifyChains_ReportsBrokenLinks(t *testing.T) {
+	mockRepo := new(MockRepository)
+	svc := NewAuditService(mockRepo, nil)
+	good, bad := uuid.New(), uuid.New()
+
+	goodChain := sealedChain(good, "10", "20")
+	badChain := sealedChain(bad, "10", "20", "30")
+	badChain.Entries[1].Amount = money.MustParseAmount("25")
+
+	mockRepo.On("ChainHeads").Return([]model.ChainHead{goodChain.Head, badChain.Head}, nil)
+	mockRepo.On("LedgerChain", good.String()).Return(goodChain, nil)
+	mockRepo.On("LedgerChain", bad.String()).Return(badChain, nil)
+
+	result, err := svc.VerifyChains(context.Background(), "")
+
+	require.NoError(t, err)
+	assert.False(t, result.Valid)
+	assert.Nil(t, result.CheckpointID)
+	assert.Equal(t, 2, result.AccountsChecked)
+	assert.Equal(t, 5, result.EntriesChecked)
+	require.Len(t, result.Breaks, 1)
+	assert.Equal(t, bad, result.Breaks[0].AccountID)
+	assert.Equal(t, int64(2), result.Breaks[0].Sequence)
+	assert.Equal(t, model.ChainHashMismatch, result.Breaks[0].Reason)
+	mockRepo.AssertNotCalled(t, "LatestCheckpoint")
+}
+
+func TestVerifyChains_ComparesWithLatestCheckpoint(t *testing.T) {
+	mockRepo := new(MockRepository)
+	signer := testSigner(t)
+	svc := NewAuditService(mockRepo, signer)
+	accountID := uuid.New()
+
+	original := sealedChain(accountID, "10", "20")
+	cp := &model.LedgerCheckpoint{ID: uuid.New(), CreatedAt: time.Now(), Heads: []model.ChainHead{original.Head}}
+	signer.Sign(cp)
+
+	// Rewritten with consistent hashes, so only the checkpoint catches it.
+	rewritten := sealedChain(accountID, "10", "2000")
+	mockRepo.On("LatestCheckpoint").Return(cp, nil)
+	mockRepo.On("LedgerChain", accountID.String()).Return(rewritten, nil)
+
+	result, err := svc.VerifyChains(context.Background(), accountID.String())
+
+	require.NoError(t, err)
+	assert.False(t, result.Valid)
+	assert.Equal(t, &cp.ID, result.CheckpointID)
+	require.Len(t, result.Breaks, 1)
+	assert.Equal(t, model.ChainCheckpointMismatch, result.Breaks[0].Reason)
+	assert.Equal(t, original.Head.Hash, result.Breaks[0].Expected)
+}
+
+func TestVerifyChains_TamperedCheckpointFails(t *testing.T) {
+	mockRepo := new(MockRepository)
+	signer := testSigner(t)
+	svc := NewAuditService(mockRepo, signer)
+	accountID := uuid.New()
+
+	chain := sealedChain(accountID, "10")
+	cp := &model.LedgerCheckpoint{ID: uuid.New(), CreatedAt: time.Now(), Heads: []model.ChainHead{chain.Head}}
+	signer.Sign(cp)
+	cp.Heads[0].Hash = "forged"
+
+	mockRepo.On("LatestCheckpoint").Return(cp, nil)
+	mockRepo.On("ChainHeads").Return([]model.ChainHead{chain.Head}, nil)
+	mockRepo.On("LedgerChain", accountID.String()).Return(chain, nil)
+
+	result, err := svc.VerifyChains(context.Background(), "")
+
+	require.NoError(t, err)
+	assert.False(t, result.Valid)
+	assert.NotEmpty(t, result.CheckpointError)
+	assert.Empty(t, result.Breaks)
+}
+
+func TestVerifyChains_UnknownAccount(t *testing.T) {
+	mockRepo := new(MockRepository)
+	svc := NewAuditService(mockRepo, nil)
+	accountID := uuid.New()
+	mockRepo.On("LedgerChain", accountID.String()).Return(nil, nil)
+
+	_, err := svc.VerifyChains(context.Background(), accountID.String())
+	assert.ErrorIs(t, err, ErrAccountNotFound)
+
+	_, err = svc.VerifyChains(context.Background(), "not-a-uuid")
+	var verr *ValidationError
+	assert.ErrorAs(t, err, &verr)
+}
+
+func TestCreateCheckpoint_SignsChainHeads(t *testing.T) {
+	mockRepo := new(MockRepository)
+	signer := testSigner(t)
+	svc := NewAuditService(mockRepo, signer)
+	heads := []model.ChainHead{sealedChain(uuid.New(), "10").Head}
+
+	mockRepo.On("ChainHeads").Return(heads, nil)
+	mockRepo.On("SaveCheckpoint", mock.AnythingOfType("*model.LedgerCheckpoint")).Return(nil)
+
+	cp, err := svc.CreateCheckpoint(context.Background())
+
+	require.NoError(t, err)
+	assert.Equal(t, heads, cp.Heads)
+	assert.Equal(t, audit.CheckpointRoot(heads), cp.Root)
+	assert.NoError(t, audit.VerifyCheckpoint(cp, signer.PublicKey()))
+	mockRepo.AssertExpectations(t)
+}
+
+func TestCreateCheckpoint_DisabledWithoutSigner(t *testing.T) {
+	mockRepo := new(MockRepository)
+	svc := NewAuditService(mockRepo, nil)
+
+	_, err := svc.CreateCheckpoint(context.Background())
+
+	assert.ErrorIs(t, err, ErrCheckpointSigningDisabled)
+	mockRepo.AssertNotCalled(t, "ChainHeads")
+}
+
+func TestGetCheckpoint_NotFound(t *testing.T) {
+	mockRepo := new(MockRepository)
+	svc := NewAuditService(mockRepo, nil)
+	id := uuid.New().String()
+	mockRepo.On("GetCheckpoint", id).Return(nil, nil)
+
+	_, err := svc.GetCheckpoint(context.Background(), id)
+
+	assert.ErrorIs(t, err, ErrCheckpointNotFound)
+}
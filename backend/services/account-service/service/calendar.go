@@ -0,0 +1,95 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"go-web-server/services/account-service/calendar"
+	"go-web-server/services/account-service/model"
+)
+
+// ErrCalendarNotFound is returned when no calendar is loaded for the
+// requested country.
+var ErrCalendarNotFound = errors.New("calendar not found")
+
+// CalendarService answers business-day questions from the per-country
+// calendars the service was started with.
+type CalendarService interface {
+	ListCalendars(ctx context.Context) ([]model.Calendar, error)
+	// Calendar returns the calendar of country, for other services to date
+	// their operations by.
+	Calendar(country string) (*calendar.Calendar, error)
+	GetBusinessDay(ctx context.Context, country, date string) (*model.BusinessDay, error)
+	// CheckCutoff tells whether an operation booked at at makes the
+	// country's cut-off, and its value date.
+	CheckCutoff(ctx context.Context, country string, at time.Time) (*model.CutoffCheck, error)
+}
+
+type calendarService struct {
+	calendars calendar.Set
+}
+
+func NewCalendarService(calendars calendar.Set) CalendarService {
+	return &calendarService{calendars: calendars}
+}
+
+func (s *calendarService) ListCalendars(ctx context.Context) ([]model.Calendar, error) {
+	calendars := make([]model.Calendar, 0, len(s.calendars))
+	for _, country := range s.calendars.Countries() {
+		calendars = append(calendars, describeCalendar(s.calendars[country]))
+	}
+	return calendars, nil
+}
+
+func (s *calendarService) Calendar(country string) (*calendar.Calendar, error) {
+	c, ok := s.calendars.Get(country)
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrCalendarNotFound, country)
+	}
+	return c, nil
+}
+
+func (s *calendarService) GetBusinessDay(ctx context.Context, country, date string) (*model.BusinessDay, error) {
+	c, err := s.Calendar(country)
+	if err != nil {
+		return nil, err
+	}
+	day, err := time.Parse(calendar.DateLayout, date)
+	if err != nil {
+		return nil, invalid("date", "%q is not a date such as 2024-05-02", date)
+	}
+
+	holiday, _ := c.Holiday(day)
+	return &model.BusinessDay{
+		Country:             c.Country,
+		Date:                day.Format(calendar.DateLayout),
+		BusinessDay:         c.IsBusinessDay(day),
+		Holiday:             holiday,
+		PreviousBusinessDay: c.AddBusinessDays(day, -1).Format(calendar.DateLayout),
+		NextBusinessDay:     c.AddBusinessDays(day, 1).Format(calendar.DateLayout),
+	}, nil
+}
+
+func (s *calendarService) CheckCutoff(ctx context.Context, country string, at time.Time) (*model.CutoffCheck, error) {
+	c, err := s.Calendar(country)
+	if err != nil {
+		return nil, err
+	}
+	return &model.CutoffCheck{
+		Country:      c.Country,
+		At:           at,
+		Cutoff:       describeCalendar(c).Cutoff,
+		BeforeCutoff: c.BeforeCutoff(at),
+		ValueDate:    c.ValueDate(at).Format(calendar.DateLayout),
+	}, nil
+}
+
+func describeCalendar(c *calendar.Calendar) model.Calendar {
+	m := model.Calendar{Country: c.Country, Timezone: c.Location().String()}
+	if cutoff := c.Cutoff(); cutoff > 0 {
+		m.Cutoff = fmt.Sprintf("%02d:%02d", int(cutoff.Hours()), int(cutoff.Minutes())%60)
+	}
+	return m
+}
@@ -0,0 +1,66 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"go-web-server/services/account-service/calendar"
+	"go-web-server/services/account-service/model"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetBusinessDay(t *testing.T) {
+	svc := NewCalendarService(calendar.Default())
+
+	day, err := svc.GetBusinessDay(context.Background(), "pl", "2024-05-03")
+	require.NoError(t, err)
+	assert.Equal(t, &model.BusinessDay{
+		Country: "PL", Date: "2024-05-03", BusinessDay: false, Holiday: "Constitution Day",
+		PreviousBusinessDay: "2024-05-02", NextBusinessDay: "2024-05-06",
+	}, day)
+
+	// A business day has business days on both sides.
+	day, err = svc.GetBusinessDay(context.Background(), "PL", "2024-05-02")
+	require.NoError(t, err)
+	assert.True(t, day.BusinessDay)
+	assert.Equal(t, "2024-04-30", day.PreviousBusinessDay)
+	assert.Equal(t, "2024-05-06", day.NextBusinessDay)
+}
+
+func TestGetBusinessDay_Invalid(t *testing.T) {
+	svc := NewCalendarService(calendar.Default())
+
+	_, err := svc.GetBusinessDay(context.Background(), "DE", "2024-05-03")
+	assert.ErrorIs(t, err, ErrCalendarNotFound)
+
+	_, err = svc.GetBusinessDay(context.Background(), "PL", "3 May 2024")
+	var validation *ValidationError
+	require.ErrorAs(t, err, &validation)
+	assert.Equal(t, "date", validation.Field)
+}
+
+func TestCheckCutoff(t *testing.T) {
+	svc := NewCalendarService(calendar.Default())
+	warsaw, err := time.LoadLocation("Europe/Warsaw")
+	require.NoError(t, err)
+
+	// 14:30 UTC is 16:30 in Warsaw in summer, past the cut-off of a Friday.
+	at := time.Date(2024, 6, 14, 14, 30, 0, 0, time.UTC)
+	check, err := svc.CheckCutoff(context.Background(), "PL", at)
+	require.NoError(t, err)
+	assert.Equal(t, "16:00", check.Cutoff)
+	assert.False(t, check.BeforeCutoff)
+	assert.Equal(t, "2024-06-17", check.ValueDate)
+
+	check, err = svc.CheckCutoff(context.Background(), "PL", time.Date(2024, 6, 14, 15, 59, 0, 0, warsaw))
+	require.NoError(t, err)
+	assert.True(t, check.BeforeCutoff)
+	assert.Equal(t, "2024-06-14", check.ValueDate)
+
+	calendars, err := svc.ListCalendars(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, []model.Calendar{{Country: "PL", Timezone: "Europe/Warsaw", Cutoff: "16:00"}}, calendars)
+}
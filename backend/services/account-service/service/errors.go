@@ -0,0 +1,97 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+
+	"go-web-server/services/account-service/money"
+	"go-web-server/services/account-service/repository"
+)
+
+// Domain errors returned by AccountService. Callers match them with
+// errors.Is; anything else is an unexpected failure such as a database
+// outage.
+var (
+	// ErrAccountNotFound is returned when the requested account does not exist.
+	ErrAccountNotFound = errors.New("account not found")
+	// ErrCustomerNotFound is returned when no customer has the given id.
+	ErrCustomerNotFound = errors.New("customer not found")
+	// ErrDestinationNotFound is returned when a transfer or payout names an
+	// account that does not exist.
+	ErrDestinationNotFound = errors.New("destination account not found")
+	// ErrValidation matches every ValidationError.
+	ErrValidation = errors.New("validation failed")
+	// ErrInsufficientFunds matches every InsufficientFundsError.
+	ErrInsufficientFunds = repository.ErrInsufficientFunds
+	// ErrCurrencyMismatch is returned when an amount's currency differs from
+	// the account's.
+	ErrCurrencyMismatch = money.ErrCurrencyMismatch
+	// ErrWithdrawalLimitExceeded matches ProductLimitErrors for debits above
+	// the product's withdrawal limit, and TransactionLimitErrors for
+	// withdrawals and transfers above the account's per-transaction limit.
+	ErrWithdrawalLimitExceeded = repository.ErrWithdrawalLimitExceeded
+	// ErrMaxBalanceExceeded matches ProductLimitErrors for credits above the
+	// product's maximum balance.
+	ErrMaxBalanceExceeded = repository.ErrMaxBalanceExceeded
+	// ErrDailyLimitExceeded, ErrMonthlyLimitExceeded and
+	// ErrVelocityLimitExceeded match TransactionLimitErrors for withdrawals
+	// and transfers beyond the account's daily, monthly or daily count
+	// limits.
+	ErrDailyLimitExceeded    = repository.ErrDailyLimitExceeded
+	ErrMonthlyLimitExceeded  = repository.ErrMonthlyLimitExceeded
+	ErrVelocityLimitExceeded = repository.ErrVelocityLimitExceeded
+)
+
+type InsufficientFundsError = repository.InsufficientFundsError
+
+type ProductLimitError = repository.ProductLimitError
+
+type TransactionLimitError = repository.TransactionLimitError
+
+// ValidationError reports an invalid request parameter. Field is the name
+// the API uses for it.
+type ValidationError struct {
+	Field string
+	Err   error
+}
+
+func (e *ValidationError) Error() string {
+	return fmt.Sprintf("invalid %s: %v", e.Field, e.Err)
+}
+
+func (e *ValidationError) Unwrap() error { return e.Err }
+
+func (e *ValidationError) Is(target error) bool { return target == ErrValidation }
+
+func invalid(field, format string, args ...interface{}) error {
+	return &ValidationError{Field: field, Err: fmt.Errorf(format, args...)}
+}
+
+// IsDomainError reports whether err is one of the errors above rather than an
+// unexpected failure.
+func IsDomainError(err error) bool {
+	for _, target := range []error{
+		ErrAccountNotFound, ErrCustomerNotFound, ErrDestinationNotFound, ErrValidation,
+		ErrInsufficientFunds, ErrCurrencyMismatch, ErrAccountNotActive, ErrInvalidStatusTransition,
+		ErrBalanceNotZero, ErrIdempotencyKeyReused, ErrQuoteNotFound, ErrQuoteExpired, ErrQuoteExecuted,
+		ErrRateUnavailable, ErrReportNotFound, ErrCheckpointNotFound, ErrCheckpointSigningDisabled,
+		ErrHoldNotFound, ErrHoldNotActive, ErrCaptureExceedsHold, ErrOverdraftInterestDisabled, ErrSavingsInterestDisabled,
+		ErrProductNotFound, ErrWithdrawalLimitExceeded, ErrMaxBalanceExceeded, ErrFeeScheduleNotFound,
+		ErrDailyLimitExceeded, ErrMonthlyLimitExceeded, ErrVelocityLimitExceeded,
+		ErrScheduledTransferNotFound, ErrScheduledTransferNotPending, ErrStandingOrderNotFound, ErrStandingOrderNotActive, ErrCalendarNotFound,
+	} {
+		if errors.Is(err, target) {
+			return true
+		}
+	}
+	return false
+}
+
+// wrapFailure adds context to an unexpected failure but returns domain errors
+// unchanged, so that their message reaches the client as is.
+func wrapFailure(err error, action string) error {
+	if IsDomainError(err) {
+		return err
+	}
+	return fmt.Errorf("failed to %s: %w", action, err)
+}
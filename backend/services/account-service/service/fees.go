@@ -0,0 +1,229 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"go-web-server/services/account-service/calendar"
+	"go-web-server/services/account-service/model"
+	"go-web-server/services/account-service/money"
+	"go-web-server/services/account-service/repository"
+
+	"github.com/google/uuid"
+)
+
+// ErrFeeScheduleNotFound is returned when a product has no schedule for the
+// requested operation.
+var ErrFeeScheduleNotFound = errors.New("fee schedule not found")
+
+// FeeService manages the fee schedules of products and charges the fees that
+// are not tied to a customer operation.
+type FeeService interface {
+	ListFeeSchedules(ctx context.Context, productCode string) ([]model.FeeSchedule, error)
+	// SaveFeeSchedule creates or replaces the schedule of s.Operation for
+	// s.ProductCode. It applies from the next operation; fees already
+	// charged are not revised.
+	SaveFeeSchedule(ctx context.Context, s *model.FeeSchedule) (*model.FeeSchedule, error)
+	DeleteFeeSchedule(ctx context.Context, productCode string, op model.FeeOperation) error
+	// PreviewFee prices op on amount, in the account's currency, under the
+	// current schedule of the account's product without booking anything.
+	PreviewFee(ctx context.Context, accountID string, op model.FeeOperation, amount money.Amount) (*model.FeePreview, error)
+	// ChargeMaintenance charges every account whose product has a
+	// maintenance fee for month, or for the previous month when it is zero.
+	// Running it again for the same month charges nothing twice.
+	ChargeMaintenance(ctx context.Context, month time.Time) (*model.MaintenanceFeeRun, error)
+}
+
+type feeService struct {
+	*accountService
+	calendar *calendar.Calendar
+	now      func() time.Time
+}
+
+// NewFeeService creates the service. A month ends at midnight in cal's
+// time zone.
+func NewFeeService(repo repository.AccountRepository, cal *calendar.Calendar) FeeService {
+	return &feeService{accountService: &accountService{repo: repo}, calendar: cal, now: time.Now}
+}
+
+func (s *feeService) ListFeeSchedules(ctx context.Context, productCode string) ([]model.FeeSchedule, error) {
+	if err := s.requireProduct(productCode); err != nil {
+		return nil, err
+	}
+	schedules, err := s.repo.ListFeeSchedules(productCode)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list fee schedules: %w", err)
+	}
+	return schedules, nil
+}
+
+func (s *feeService) SaveFeeSchedule(ctx context.Context, fs *model.FeeSchedule) (*model.FeeSchedule, error) {
+	if !fs.Operation.Valid() {
+		return nil, invalid("operation", "unknown fee operation %q", fs.Operation)
+	}
+	for _, a := range []struct {
+		field  string
+		amount money.Amount
+	}{
+		{"flat", fs.Flat}, {"minFee", fs.MinFee}, {"maxFee", fs.MaxFee},
+	} {
+		if a.amount.IsNegative() {
+			return nil, invalid(a.field, "%s must not be negative", a.field)
+		}
+	}
+	if !fs.MaxFee.IsZero() && fs.MaxFee.Cmp(fs.MinFee) < 0 {
+		return nil, invalid("maxFee", "maxFee must not be below minFee")
+	}
+	if fs.Operation == model.FeeMaintenance && fs.Percentage != nil {
+		return nil, invalid("percentage", "maintenance fees are flat")
+	}
+	if err := s.requireProduct(fs.ProductCode); err != nil {
+		return nil, err
+	}
+
+	saved, err := s.repo.SaveFeeSchedule(fs)
+	if err != nil {
+		return nil, fmt.Errorf("failed to save fee schedule: %w", err)
+	}
+	return saved, nil
+}
+
+func (s *feeService) DeleteFeeSchedule(ctx context.Context, productCode string, op model.FeeOperation) error {
+	if !op.Valid() {
+		return invalid("operation", "unknown fee operation %q", op)
+	}
+	deleted, err := s.repo.DeleteFeeSchedule(productCode, op)
+	if err != nil {
+		return fmt.Errorf("failed to delete fee schedule: %w", err)
+	}
+	if !deleted {
+		return ErrFeeScheduleNotFound
+	}
+	return nil
+}
+
+func (s *feeService) PreviewFee(ctx context.Context, accountID string, op model.FeeOperation, amount money.Amount) (*model.FeePreview, error) {
+	if !op.Valid() {
+		return nil, invalid("operation", "unknown fee operation %q", op)
+	}
+	if amount.IsNegative() || (amount.IsZero() && op != model.FeeMaintenance) {
+		return nil, invalid("amount", "amount must be positive")
+	}
+	acc, err := s.GetAccount(ctx, accountID)
+	if err != nil {
+		return nil, err
+	}
+	schedule, err := s.repo.GetFeeSchedule(acc.ProductCode, op)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get fee schedule: %w", err)
+	}
+
+	fee := money.New(money.Amount{}, acc.Currency)
+	if schedule != nil {
+		if fee, err = schedule.Fee(money.New(amount, acc.Currency)); err != nil {
+			return nil, err
+		}
+	}
+	total, err := amount.Add(fee.Amount)
+	if err != nil {
+		return nil, err
+	}
+	return &model.FeePreview{
+		AccountID: acc.ID,
+		Operation: op,
+		Amount:    amount,
+		Fee:       fee.Amount,
+		Total:     total,
+		Currency:  acc.Currency,
+	}, nil
+}
+
+// ChargeMaintenance charges in arrears, so it only runs for a month that has
+// ended.
+func (s *feeService) ChargeMaintenance(ctx context.Context, month time.Time) (*model.MaintenanceFeeRun, error) {
+	today := s.calendar.Today(s.now())
+	current := today.AddDate(0, 0, 1-today.Day())
+	if month.IsZero() {
+		month = current.AddDate(0, -1, 0)
+	}
+	y, m, _ := month.Date()
+	month = time.Date(y, m, 1, 0, 0, 0, 0, time.UTC)
+	if !month.Before(current) {
+		return nil, invalid("month", "maintenance fees can only be charged for a month that has ended")
+	}
+
+	ids, err := s.repo.MaintenanceFeeAccounts(month)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list accounts with a maintenance fee: %w", err)
+	}
+	run := &model.MaintenanceFeeRun{Month: month.Format("2006-01"), Charged: []model.LedgerEntry{}}
+	for _, id := range ids {
+		var entry *model.LedgerEntry
+		err := s.repo.InTx(nil, nil, func(uow repository.UnitOfWork) error {
+			entry, err = chargeMaintenanceFee(uow, id, month)
+			return err
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to charge maintenance fee on account %s: %w", id, err)
+		}
+		if entry == nil {
+			run.Skipped++
+			continue
+		}
+		run.Charged = append(run.Charged, *entry)
+	}
+	return run, nil
+}
+
+// chargeMaintenanceFee charges an account, locked in uow, the maintenance
+// fee of its product for month. It returns nil when the account is closed,
+// did not exist yet in month, was already charged for the month or its
+// product charges no maintenance fee.
+func chargeMaintenanceFee(uow repository.UnitOfWork, accountID uuid.UUID, month time.Time) (*model.LedgerEntry, error) {
+	acc, err := uow.LockAccount(accountID.String())
+	if err != nil || acc == nil {
+		return nil, err
+	}
+	if acc.Status == model.AccountClosed {
+		return nil, nil
+	}
+	// Business Rule: An account opened during a month pays for it, but not
+	// for the months before it was opened
+	if !acc.CreatedAt.Before(month.AddDate(0, 1, 0)) {
+		return nil, nil
+	}
+	charged, err := uow.MaintenanceFeeCharged(acc, month)
+	if err != nil || charged {
+		return nil, err
+	}
+	product, err := lockedProduct(uow, acc)
+	if err != nil {
+		return nil, err
+	}
+	fee, err := operationFee(uow, product, model.FeeMaintenance, money.New(money.Amount{}, acc.Currency))
+	if err != nil || fee.IsZero() {
+		return nil, err
+	}
+
+	// Business Rule: Like interest, the fee is booked even if it overdraws
+	// the account
+	entry, err := uow.PostFee(acc, fee, nil, "Maintenance fee for "+month.Format("2006-01"))
+	if err != nil {
+		return nil, err
+	}
+	return entry, uow.RecordMaintenanceFee(entry, month)
+}
+
+// requireProduct returns ErrProductNotFound unless the product exists.
+func (s *feeService) requireProduct(code string) error {
+	p, err := s.repo.GetProduct(code)
+	if err != nil {
+		return fmt.Errorf("failed to get product: %w", err)
+	}
+	if p == nil {
+		return ErrProductNotFound
+	}
+	return nil
+}
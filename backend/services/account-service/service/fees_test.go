@@ -0,0 +1,212 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"go-web-server/services/account-service/model"
+	"go-web-server/services/account-service/money"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// transferFee charges 1 plus 0.5% per transfer, at most 10.
+var transferFee = &model.FeeSchedule{
+	ProductCode: "plain", Operation: model.FeeTransfer,
+	Flat: money.MustParseAmount("1"), Percentage: rateOf("0.005"), MaxFee: money.MustParseAmount("10"),
+}
+
+func rateOf(s string) *money.Rate {
+	r := money.MustParseRate(s)
+	return &r
+}
+
+func TestOperationFee(t *testing.T) {
+	mockRepo := new(MockRepository)
+	product := &model.Product{Code: "current"}
+	for _, tc := range []struct {
+		name         string
+		schedule     model.FeeSchedule
+		amount, want string
+	}{
+		{"flat", model.FeeSchedule{Flat: money.MustParseAmount("2.5")}, "1000", "2.50"},
+		{"flat plus percentage", model.FeeSchedule{Flat: money.MustParseAmount("1"), Percentage: rateOf("0.005")}, "123.45", "1.62"},
+		{"raised to the minimum", model.FeeSchedule{Percentage: rateOf("0.005"), MinFee: money.MustParseAmount("5")}, "100", "5.00"},
+		{"capped at the maximum", model.FeeSchedule{Percentage: rateOf("0.01"), MaxFee: money.MustParseAmount("20")}, "10000", "20.00"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			mockRepo.On("FeeSchedule", "current", model.FeeTransfer).Return(&tc.schedule, nil).Once()
+			fee, err := operationFee(mockRepo, product, model.FeeTransfer, money.New(money.MustParseAmount(tc.amount), money.PLN))
+			require.NoError(t, err)
+			assert.Equal(t, money.New(money.MustParseAmount(tc.want), money.PLN), fee)
+		})
+	}
+
+	mockRepo.On("FeeSchedule", "current", model.FeeWithdrawal).Return(nil, nil)
+	fee, err := operationFee(mockRepo, product, model.FeeWithdrawal, money.New(money.MustParseAmount("100"), money.EUR))
+	require.NoError(t, err)
+	assert.Equal(t, money.New(money.Amount{}, money.EUR), fee)
+}
+
+func TestTransfer_ChargesFee(t *testing.T) {
+	mockRepo := new(MockRepository)
+	svc := NewAccountService(mockRepo)
+
+	ref := uuid.New()
+	from := &model.Account{ID: uuid.New(), Currency: money.PLN, Status: model.AccountActive, Balance: money.MustParseAmount("100")}
+	to := &model.Account{ID: uuid.New(), Currency: money.PLN, Status: model.AccountActive}
+	amount := money.New(money.MustParseAmount("40"), money.PLN)
+	booked := &model.Transfer{ReferenceID: ref, Debit: model.LedgerEntry{ID: uuid.New(), ReferenceID: &ref}}
+	fee := &model.LedgerEntry{ID: uuid.New(), ReferenceID: &ref}
+
+	mockRepo.On("FeeSchedule", "plain", model.FeeTransfer).Return(transferFee, nil)
+	expectLocked(mockRepo, from, to)
+	mockRepo.On("BookTransfer", from, to, amount, "Rent").Return(booked, nil)
+	mockRepo.On("PostFee", from, money.New(money.MustParseAmount("1.2"), money.PLN), &booked.Debit, "Transfer fee").Return(fee, nil)
+
+	transfer, err := svc.Transfer(context.Background(), from.ID.String(), to.ID.String(), amount, "Rent")
+	require.NoError(t, err)
+	assert.Equal(t, fee, transfer.Fee)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestTransfer_FeeNotCovered(t *testing.T) {
+	mockRepo := new(MockRepository)
+	svc := NewAccountService(mockRepo)
+
+	from := &model.Account{ID: uuid.New(), Currency: money.PLN, Status: model.AccountActive, Balance: money.MustParseAmount("100")}
+	to := &model.Account{ID: uuid.New(), Currency: money.PLN, Status: model.AccountActive}
+
+	mockRepo.On("FeeSchedule", "plain", model.FeeTransfer).Return(transferFee, nil)
+	expectLocked(mockRepo, from, to)
+
+	_, err := svc.Transfer(context.Background(), from.ID.String(), to.ID.String(), money.New(money.MustParseAmount("99"), money.PLN), "Everything")
+	var insufficient *InsufficientFundsError
+	require.ErrorAs(t, err, &insufficient)
+	assert.Equal(t, money.New(money.MustParseAmount("100.5"), money.PLN), insufficient.Requested)
+	mockRepo.AssertNotCalled(t, "BookTransfer", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestSaveFeeSchedule(t *testing.T) {
+	mockRepo := new(MockRepository)
+	svc := NewFeeService(mockRepo, warsawCalendar)
+
+	mockRepo.On("GetProduct", "plain").Return(plainProduct, nil)
+	mockRepo.On("GetProduct", "pension").Return(nil, nil)
+	mockRepo.On("SaveFeeSchedule", transferFee).Return(transferFee, nil)
+
+	saved, err := svc.SaveFeeSchedule(context.Background(), transferFee)
+	require.NoError(t, err)
+	assert.Equal(t, transferFee, saved)
+
+	_, err = svc.SaveFeeSchedule(context.Background(), &model.FeeSchedule{ProductCode: "pension", Operation: model.FeeTransfer})
+	assert.ErrorIs(t, err, ErrProductNotFound)
+
+	for field, invalidSchedule := range map[string]*model.FeeSchedule{
+		"operation":  {ProductCode: "plain", Operation: "deposit"},
+		"flat":       {ProductCode: "plain", Operation: model.FeeTransfer, Flat: money.MustParseAmount("-1")},
+		"maxFee":     {ProductCode: "plain", Operation: model.FeeTransfer, MinFee: money.MustParseAmount("5"), MaxFee: money.MustParseAmount("2")},
+		"percentage": {ProductCode: "plain", Operation: model.FeeMaintenance, Percentage: rateOf("0.01")},
+	} {
+		_, err := svc.SaveFeeSchedule(context.Background(), invalidSchedule)
+		var vErr *ValidationError
+		if assert.ErrorAs(t, err, &vErr, field) {
+			assert.Equal(t, field, vErr.Field)
+		}
+	}
+	mockRepo.AssertNumberOfCalls(t, "SaveFeeSchedule", 1)
+}
+
+func TestDeleteFeeSchedule_NotFound(t *testing.T) {
+	mockRepo := new(MockRepository)
+	svc := NewFeeService(mockRepo, warsawCalendar)
+	mockRepo.On("DeleteFeeSchedule", "plain", model.FeeWithdrawal).Return(false, nil)
+
+	err := svc.DeleteFeeSchedule(context.Background(), "plain", model.FeeWithdrawal)
+	assert.ErrorIs(t, err, ErrFeeScheduleNotFound)
+}
+
+func TestPreviewFee(t *testing.T) {
+	mockRepo := new(MockRepository)
+	svc := NewFeeService(mockRepo, warsawCalendar)
+
+	acc := &model.Account{ID: uuid.New(), Currency: money.PLN, Status: model.AccountActive, ProductCode: "plain"}
+	mockRepo.On("GetAccount", acc.ID.String()).Return(acc, nil)
+	mockRepo.On("GetFeeSchedule", "plain", model.FeeTransfer).Return(transferFee, nil)
+	mockRepo.On("GetFeeSchedule", "plain", model.FeeWithdrawal).Return(nil, nil)
+
+	preview, err := svc.PreviewFee(context.Background(), acc.ID.String(), model.FeeTransfer, money.MustParseAmount("5000"))
+	require.NoError(t, err)
+	assert.Equal(t, money.MustParseAmount("10"), preview.Fee)
+	assert.Equal(t, money.MustParseAmount("5010"), preview.Total)
+	assert.Equal(t, money.PLN, preview.Currency)
+
+	preview, err = svc.PreviewFee(context.Background(), acc.ID.String(), model.FeeWithdrawal, money.MustParseAmount("50"))
+	require.NoError(t, err)
+	assert.True(t, preview.Fee.IsZero())
+
+	_, err = svc.PreviewFee(context.Background(), acc.ID.String(), model.FeeTransfer, money.Amount{})
+	assert.ErrorIs(t, err, ErrValidation)
+}
+
+func TestChargeMaintenance_DefaultsToLastMonth(t *testing.T) {
+	mockRepo := new(MockRepository)
+	svc := NewFeeService(mockRepo, warsawCalendar).(*feeService)
+	svc.now = func() time.Time { return time.Date(2024, 4, 1, 0, 30, 0, 0, time.UTC) }
+	month := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+
+	// An account opened during the month pays for it.
+	charged := &model.Account{ID: uuid.New(), Currency: money.PLN, Status: model.AccountActive, ProductCode: "plain", CreatedAt: time.Date(2024, 3, 31, 23, 0, 0, 0, time.UTC)}
+	skipped := &model.Account{ID: uuid.New(), Currency: money.PLN, Status: model.AccountActive, ProductCode: "plain"}
+	// One opened since the accounts were listed does not.
+	opened := &model.Account{ID: uuid.New(), Currency: money.PLN, Status: model.AccountActive, ProductCode: "plain", CreatedAt: time.Date(2024, 4, 1, 0, 0, 0, 0, time.UTC)}
+	entry := &model.LedgerEntry{AccountID: charged.ID, Amount: money.MustParseAmount("-5")}
+	mockRepo.On("MaintenanceFeeAccounts", month).Return([]uuid.UUID{charged.ID, skipped.ID, opened.ID}, nil)
+	mockRepo.On("InTx", (*model.IdempotencyKey)(nil)).Return(nil)
+	mockRepo.On("LockAccount", charged.ID.String()).Return(charged, nil)
+	mockRepo.On("LockAccount", skipped.ID.String()).Return(skipped, nil)
+	mockRepo.On("LockAccount", opened.ID.String()).Return(opened, nil)
+	mockRepo.On("MaintenanceFeeCharged", charged, month).Return(false, nil)
+	mockRepo.On("MaintenanceFeeCharged", skipped, month).Return(true, nil)
+	mockRepo.On("Product", "plain").Return(plainProduct, nil)
+	mockRepo.On("FeeSchedule", "plain", model.FeeMaintenance).
+		Return(&model.FeeSchedule{ProductCode: "plain", Operation: model.FeeMaintenance, Flat: money.MustParseAmount("5")}, nil)
+	// A maintenance fee is charged on its own, without a cause.
+	mockRepo.On("PostFee", charged, money.New(money.MustParseAmount("5"), money.PLN), (*model.LedgerEntry)(nil), "Maintenance fee for 2024-03").
+		Return(entry, nil)
+	mockRepo.On("RecordMaintenanceFee", entry, month).Return(nil)
+
+	run, err := svc.ChargeMaintenance(context.Background(), time.Time{})
+	require.NoError(t, err)
+	assert.Equal(t, "2024-03", run.Month)
+	assert.Len(t, run.Charged, 1)
+	assert.Equal(t, 2, run.Skipped)
+	mockRepo.AssertNotCalled(t, "MaintenanceFeeCharged", opened, month)
+
+	_, err = svc.ChargeMaintenance(context.Background(), time.Date(2024, 4, 15, 0, 0, 0, 0, time.UTC))
+	assert.ErrorIs(t, err, ErrValidation)
+	mockRepo.AssertNumberOfCalls(t, "MaintenanceFeeAccounts", 1)
+}
+
+func TestChargeMaintenance_MonthEndsByCalendar(t *testing.T) {
+	mockRepo := new(MockRepository)
+	svc := NewFeeService(mockRepo, warsawCalendar).(*feeService)
+	march := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	mockRepo.On("MaintenanceFeeAccounts", march).Return([]uuid.UUID{}, nil)
+
+	// 23:30 on 31 March in Warsaw.
+	svc.now = func() time.Time { return time.Date(2024, 3, 31, 21, 30, 0, 0, time.UTC) }
+	_, err := svc.ChargeMaintenance(context.Background(), march)
+	assert.ErrorIs(t, err, ErrValidation)
+
+	// 00:30 on 1 April in Warsaw, still March in UTC.
+	svc.now = func() time.Time { return time.Date(2024, 3, 31, 22, 30, 0, 0, time.UTC) }
+	run, err := svc.ChargeMaintenance(context.Background(), time.Time{})
+	require.NoError(t, err)
+	assert.Equal(t, "2024-03", run.Month)
+	mockRepo.AssertNumberOfCalls(t, "MaintenanceFeeAccounts", 1)
+}
@@ -0,0 +1,265 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"go-web-server/services/account-service/fx"
+	"go-web-server/services/account-service/model"
+	"go-web-server/services/account-service/money"
+	"go-web-server/services/account-service/repository"
+
+	"github.com/google/uuid"
+)
+
+var (
+	// ErrQuoteNotFound is returned when the requested quote does not exist.
+	ErrQuoteNotFound = errors.New("fx quote not found")
+	// ErrQuoteExpired is returned when a quote is executed after it expired.
+	// Handlers map it to 422; the client asks for a new quote.
+	ErrQuoteExpired = repository.ErrQuoteExpired
+	// ErrQuoteExecuted is returned when a quote is executed a second time
+	// without the original Idempotency-Key. Handlers map it to 409.
+	ErrQuoteExecuted = repository.ErrQuoteExecuted
+	// ErrRateUnavailable is returned when no rate connects the two
+	// currencies of a quote.
+	ErrRateUnavailable = fx.ErrRateUnavailable
+)
+
+// DefaultQuoteTTL is how long a quote can be executed for when
+// NewFXService is given no TTL.
+const DefaultQuoteTTL = 30 * time.Second
+
+// FXService quotes and executes currency exchanges between two accounts of
+// the same customer, and lets admins maintain the rates they are priced
+// from.
+type FXService interface {
+	QuoteExchange(ctx context.Context, fromAccountID, toAccountID string, sell money.Money) (*model.FXQuote, error)
+	GetQuote(ctx context.Context, quoteID string) (*model.FXQuote, error)
+	ExecuteQuote(ctx context.Context, quoteID string) (*model.CurrencyExchange, error)
+	SetRate(ctx context.Context, rate model.FXRate) (*model.FXRate, error)
+	ListRates(ctx context.Context) ([]model.FXRate, error)
+}
+
+type fxService struct {
+	*accountService
+	converter *fx.Converter
+	quoteTTL  time.Duration
+	now       func() time.Time
+}
+
+func NewFXService(repo repository.AccountRepository, converter *fx.Converter, quoteTTL time.Duration) FXService {
+	if quoteTTL <= 0 {
+		quoteTTL = DefaultQuoteTTL
+	}
+	return &fxService{
+		accountService: &accountService{repo: repo},
+		converter:      converter,
+		quoteTTL:       quoteTTL,
+		now:            time.Now,
+	}
+}
+
+// QuoteExchange prices selling sell from fromAccountID into toAccountID's
+// currency. The buy amount is truncated to the target currency's minor
+// units, so rounding never favours the customer.
+func (s *fxService) QuoteExchange(ctx context.Context, fromAccountID, toAccountID string, sell money.Money) (*model.FXQuote, error) {
+	if sell.Sign() <= 0 {
+		return nil, invalid("amount", "exchange amount must be positive")
+	}
+	if _, err := uuid.Parse(toAccountID); err != nil {
+		return nil, &ValidationError{Field: "toAccountId", Err: err}
+	}
+	if fromAccountID == toAccountID {
+		return nil, invalid("toAccountId", "cannot exchange into the same account")
+	}
+
+	from, err := s.repo.GetAccount(fromAccountID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get account: %w", err)
+	}
+	if from == nil {
+		return nil, ErrAccountNotFound
+	}
+	to, err := s.repo.GetAccount(toAccountID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get account: %w", err)
+	}
+	if to == nil || to.CustomerID != from.CustomerID {
+		return nil, ErrDestinationNotFound
+	}
+	for _, acc := range []*model.Account{from, to} {
+		if acc.Status != model.AccountActive {
+			return nil, &AccountNotActiveError{AccountID: acc.ID, Status: acc.Status}
+		}
+	}
+	if sell.Currency != from.Currency {
+		return nil, fmt.Errorf("%w: cannot sell %s from a %s account", ErrCurrencyMismatch, sell.Currency, from.Currency)
+	}
+	if from.Currency == to.Currency {
+		return nil, invalid("toAccountId", "both accounts are in %s; use a transfer instead", from.Currency)
+	}
+
+	rate, err := s.converter.Rate(from.Currency, to.Currency)
+	if err != nil {
+		return nil, wrapFailure(err, "price exchange")
+	}
+	buy := rate.Convert(sell.Amount, to.Currency)
+	if buy.Sign() <= 0 {
+		return nil, invalid("amount", "%s buys less than the smallest %s unit", sell, to.Currency)
+	}
+
+	q := &model.FXQuote{
+		ID:            uuid.New(),
+		FromAccountID: from.ID,
+		ToAccountID:   to.ID,
+		SellAmount:    sell.Amount,
+		SellCurrency:  sell.Currency,
+		BuyAmount:     buy,
+		BuyCurrency:   to.Currency,
+		Rate:          rate,
+		ExpiresAt:     s.now().Add(s.quoteTTL),
+	}
+	if err := s.repo.CreateFXQuote(q); err != nil {
+		return nil, fmt.Errorf("failed to create quote: %w", err)
+	}
+	return q, nil
+}
+
+func (s *fxService) GetQuote(ctx context.Context, quoteID string) (*model.FXQuote, error) {
+	if _, err := uuid.Parse(quoteID); err != nil {
+		return nil, &ValidationError{Field: "quoteId", Err: err}
+	}
+	q, err := s.repo.GetFXQuote(quoteID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get quote: %w", err)
+	}
+	if q == nil {
+		return nil, ErrQuoteNotFound
+	}
+	return q, nil
+}
+
+// ExecuteQuote books the quote at its quoted rate. Repeating the call with
+// the same Idempotency-Key returns the original exchange.
+func (s *fxService) ExecuteQuote(ctx context.Context, quoteID string) (*model.CurrencyExchange, error) {
+	idem := idempotencyKey(ctx, "fx_exchange", quoteID)
+	var previous model.CurrencyExchange
+	replayed, err := s.replay(idem, &previous)
+	if err != nil {
+		return nil, err
+	}
+	if replayed {
+		return &previous, nil
+	}
+
+	q, err := s.GetQuote(ctx, quoteID)
+	if err != nil {
+		return nil, err
+	}
+	var exchange *model.CurrencyExchange
+	err = s.repo.InTx(idem, &exchange, func(uow repository.UnitOfWork) error {
+		exchange, err = bookExchange(uow, q.ID)
+		return err
+	})
+	if err != nil {
+		return nil, wrapFailure(err, "execute exchange")
+	}
+	return exchange, nil
+}
+
+// bookExchange applies the business rules of executing a quote in uow and
+// books it with the FX markup.
+func bookExchange(uow repository.UnitOfWork, quoteID uuid.UUID) (*model.CurrencyExchange, error) {
+	// The quote is locked before its accounts, so that it is executed at
+	// most once and only before it expires
+	q, err := uow.LockFXQuote(quoteID)
+	if err != nil {
+		return nil, err
+	}
+	if q == nil {
+		return nil, ErrQuoteNotFound
+	}
+	locked, err := uow.LockAccounts(q.FromAccountID, q.ToAccountID)
+	if err != nil {
+		return nil, err
+	}
+	from, to := locked[q.FromAccountID], locked[q.ToAccountID]
+	if from == nil {
+		return nil, ErrAccountNotFound
+	}
+	if to == nil {
+		return nil, ErrDestinationNotFound
+	}
+	for _, acc := range []*model.Account{from, to} {
+		if err := requireActive(acc); err != nil {
+			return nil, err
+		}
+	}
+
+	// Business Rule: The sale stays within the source product's withdrawal
+	// limit and the purchase within the destination product's maximum
+	// balance
+	sell := money.New(q.SellAmount, q.SellCurrency)
+	fromProduct, err := lockedProduct(uow, from)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkDebit(fromProduct, sell); err != nil {
+		return nil, err
+	}
+	toProduct, err := lockedProduct(uow, to)
+	if err != nil {
+		return nil, err
+	}
+	toAfter, err := to.BalanceMoney().Add(money.New(q.BuyAmount, q.BuyCurrency))
+	if err != nil {
+		return nil, err
+	}
+	if err := checkCredit(toProduct, toAfter); err != nil {
+		return nil, err
+	}
+
+	// Business Rule: Ensure sufficient available funds on the source
+	// account for the sale and the FX markup
+	fee, err := operationFee(uow, fromProduct, model.FeeFXExchange, sell)
+	if err != nil {
+		return nil, err
+	}
+	debit, err := sell.Add(fee)
+	if err != nil {
+		return nil, err
+	}
+	if err := requireFunds(from, debit); err != nil {
+		return nil, err
+	}
+
+	exchange, err := uow.BookExchange(from, to, q)
+	if err != nil || fee.IsZero() {
+		return exchange, err
+	}
+	exchange.Fee, err = uow.PostFee(from, fee, &exchange.Debit, "FX markup")
+	return exchange, err
+}
+
+// SetRate stores an admin rate, which takes precedence over the rates file.
+func (s *fxService) SetRate(ctx context.Context, rate model.FXRate) (*model.FXRate, error) {
+	if err := fx.Validate(rate); err != nil {
+		return nil, invalid("rate", "%w", err)
+	}
+	stored, err := s.repo.SetFXRate(rate)
+	if err != nil {
+		return nil, fmt.Errorf("failed to set rate: %w", err)
+	}
+	return stored, nil
+}
+
+func (s *fxService) ListRates(ctx context.Context) ([]model.FXRate, error) {
+	rates, err := s.repo.ListFXRates()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list rates: %w", err)
+	}
+	return rates, nil
+}
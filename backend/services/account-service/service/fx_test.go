@@ -0,0 +1,205 @@
+package service
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"go-web-server/services/account-service/fx"
+	"go-web-server/services/account-service/model"
+	"go-web-server/services/account-service/money"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestFXService(t *testing.T, repo *MockRepository) *fxService {
+	t.Helper()
+	rates, err := fx.ReadRates(strings.NewReader(`[{"base": "EUR", "quote": "PLN", "bid": "4.2650", "ask": "4.3510"}]`), time.Now())
+	require.NoError(t, err)
+	svc := NewFXService(repo, fx.NewConverter(rates, money.PLN), time.Minute).(*fxService)
+	svc.now = func() time.Time { return time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC) }
+	return svc
+}
+
+func fxAccounts(repo *MockRepository) (pln, eur *model.Account) {
+	customerID := uuid.New()
+	pln = &model.Account{ID: uuid.New(), CustomerID: customerID, Currency: money.PLN, Balance: money.MustParseAmount("1000"), Status: model.AccountActive}
+	eur = &model.Account{ID: uuid.New(), CustomerID: customerID, Currency: money.EUR, Status: model.AccountActive}
+	repo.On("GetAccount", pln.ID.String()).Return(pln, nil)
+	repo.On("GetAccount", eur.ID.String()).Return(eur, nil)
+	return pln, eur
+}
+
+func TestQuoteExchange(t *testing.T) {
+	mockRepo := new(MockRepository)
+	svc := newTestFXService(t, mockRepo)
+	pln, eur := fxAccounts(mockRepo)
+	mockRepo.On("CreateFXQuote", mock.AnythingOfType("*model.FXQuote")).Return(nil)
+
+	q, err := svc.QuoteExchange(context.Background(), pln.ID.String(), eur.ID.String(), money.New(money.MustParseAmount("100"), money.PLN))
+
+	require.NoError(t, err)
+	// Buying EUR costs the ask; 100 / 4.3510 = 22.9832... is truncated.
+	assert.Equal(t, "22.98", q.BuyAmount.String())
+	assert.Equal(t, money.EUR, q.BuyCurrency)
+	assert.Equal(t, money.MustParseRate("4.3510").Inverse(), q.Rate)
+	assert.Equal(t, time.Date(2024, 3, 1, 12, 1, 0, 0, time.UTC), q.ExpiresAt)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestQuoteExchange_Rejected(t *testing.T) {
+	mockRepo := new(MockRepository)
+	svc := newTestFXService(t, mockRepo)
+	pln, eur := fxAccounts(mockRepo)
+	other := &model.Account{ID: uuid.New(), CustomerID: uuid.New(), Currency: money.EUR, Status: model.AccountActive}
+	mockRepo.On("GetAccount", other.ID.String()).Return(other, nil)
+	usd := &model.Account{ID: uuid.New(), CustomerID: pln.CustomerID, Currency: money.USD, Status: model.AccountActive}
+	mockRepo.On("GetAccount", usd.ID.String()).Return(usd, nil)
+	plnAmount := func(s string) money.Money { return money.New(money.MustParseAmount(s), money.PLN) }
+
+	for _, tc := range []struct {
+		name     string
+		from, to string
+		sell     money.Money
+		want     error
+	}{
+		{"zero amount", pln.ID.String(), eur.ID.String(), plnAmount("0"), ErrValidation},
+		{"same account", pln.ID.String(), pln.ID.String(), plnAmount("1"), ErrValidation},
+		{"other customer", pln.ID.String(), other.ID.String(), plnAmount("1"), ErrDestinationNotFound},
+		{"wrong sell currency", eur.ID.String(), pln.ID.String(), plnAmount("1"), ErrCurrencyMismatch},
+		{"no rate", pln.ID.String(), usd.ID.String(), plnAmount("1"), ErrRateUnavailable},
+		{"below one cent", pln.ID.String(), eur.ID.String(), plnAmount("0.01"), ErrValidation},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := svc.QuoteExchange(context.Background(), tc.from, tc.to, tc.sell)
+			assert.ErrorIs(t, err, tc.want)
+			assert.True(t, IsDomainError(err))
+		})
+	}
+	mockRepo.AssertNotCalled(t, "CreateFXQuote", mock.Anything)
+}
+
+// fxQuote quotes selling 100 PLN from pln for 22.98 EUR on eur and lets a
+// unit of work lock the quote and both accounts.
+func fxQuote(repo *MockRepository, pln, eur *model.Account) *model.FXQuote {
+	q := &model.FXQuote{
+		ID: uuid.New(), FromAccountID: pln.ID, ToAccountID: eur.ID,
+		SellAmount: money.MustParseAmount("100"), SellCurrency: money.PLN,
+		BuyAmount: money.MustParseAmount("22.98"), BuyCurrency: money.EUR,
+		Rate: money.MustParseRate("4.3510").Inverse(),
+	}
+	repo.On("GetFXQuote", q.ID.String()).Return(q, nil)
+	repo.On("LockFXQuote", q.ID).Return(q, nil)
+	repo.On("LockAccounts", []uuid.UUID{pln.ID, eur.ID}).Return(map[uuid.UUID]*model.Account{pln.ID: pln, eur.ID: eur}, nil)
+	return q
+}
+
+func TestExecuteQuote_ReplaysIdempotentRequest(t *testing.T) {
+	mockRepo := new(MockRepository)
+	svc := newTestFXService(t, mockRepo)
+	pln, eur := fxAccounts(mockRepo)
+	q := fxQuote(mockRepo, pln, eur)
+	exchange := &model.CurrencyExchange{ReferenceID: uuid.New(), QuoteID: q.ID, Rate: q.Rate}
+	ctx := WithIdempotencyKey(context.Background(), "alice", "fx-1")
+
+	var idem *model.IdempotencyKey
+	mockRepo.On("GetIdempotencyRecord", "alice", "fx-1").Return(nil, nil).Once()
+	mockRepo.On("InTx", mock.AnythingOfType("*model.IdempotencyKey")).Return(nil).Once().
+		Run(func(args mock.Arguments) { idem = args.Get(0).(*model.IdempotencyKey) })
+	expectProduct(mockRepo, plainProduct)
+	mockRepo.On("BookExchange", pln, eur, q).Return(exchange, nil).Once()
+
+	first, err := svc.ExecuteQuote(ctx, q.ID.String())
+	require.NoError(t, err)
+	assert.Equal(t, exchange, first)
+
+	mockRepo.On("GetIdempotencyRecord", "alice", "fx-1").Return(&model.IdempotencyRecord{
+		Key: "fx-1", Fingerprint: idem.Fingerprint, Response: []byte(`{"referenceId":"` + exchange.ReferenceID.String() + `","rate":"4.2650"}`),
+	}, nil).Once()
+
+	second, err := svc.ExecuteQuote(ctx, q.ID.String())
+	require.NoError(t, err)
+	assert.Equal(t, exchange.ReferenceID, second.ReferenceID)
+	mockRepo.AssertNumberOfCalls(t, "BookExchange", 1)
+}
+
+func TestExecuteQuote_ChargesMarkup(t *testing.T) {
+	mockRepo := new(MockRepository)
+	svc := newTestFXService(t, mockRepo)
+	pln, eur := fxAccounts(mockRepo)
+	q := fxQuote(mockRepo, pln, eur)
+	ref := uuid.New()
+	exchange := &model.CurrencyExchange{ReferenceID: ref, QuoteID: q.ID, Debit: model.LedgerEntry{ID: uuid.New(), ReferenceID: &ref}}
+	fee := &model.LedgerEntry{ID: uuid.New(), ReferenceID: &ref}
+
+	mockRepo.On("InTx", (*model.IdempotencyKey)(nil)).Return(nil)
+	mockRepo.On("FeeSchedule", "plain", model.FeeFXExchange).
+		Return(&model.FeeSchedule{ProductCode: "plain", Operation: model.FeeFXExchange, Percentage: rateOf("0.01")}, nil)
+	expectProduct(mockRepo, plainProduct)
+	mockRepo.On("BookExchange", pln, eur, q).Return(exchange, nil)
+	mockRepo.On("PostFee", pln, money.New(money.MustParseAmount("1"), money.PLN), &exchange.Debit, "FX markup").Return(fee, nil)
+
+	got, err := svc.ExecuteQuote(context.Background(), q.ID.String())
+
+	require.NoError(t, err)
+	assert.Same(t, fee, got.Fee)
+	mockRepo.AssertCalled(t, "PostFee", pln, money.New(money.MustParseAmount("1"), money.PLN), &exchange.Debit, "FX markup")
+}
+
+func TestExecuteQuote_Errors(t *testing.T) {
+	mockRepo := new(MockRepository)
+	svc := newTestFXService(t, mockRepo)
+	expired, executed := uuid.New(), uuid.New()
+	mockRepo.On("InTx", (*model.IdempotencyKey)(nil)).Return(nil)
+	mockRepo.On("GetFXQuote", expired.String()).Return(&model.FXQuote{ID: expired}, nil)
+	mockRepo.On("GetFXQuote", executed.String()).Return(&model.FXQuote{ID: executed}, nil)
+	mockRepo.On("LockFXQuote", expired).Return(nil, ErrQuoteExpired)
+	mockRepo.On("LockFXQuote", executed).Return(nil, ErrQuoteExecuted)
+	mockRepo.On("GetFXQuote", mock.Anything).Return(nil, nil)
+
+	_, err := svc.ExecuteQuote(context.Background(), expired.String())
+	assert.ErrorIs(t, err, ErrQuoteExpired)
+	_, err = svc.ExecuteQuote(context.Background(), executed.String())
+	assert.ErrorIs(t, err, ErrQuoteExecuted)
+	_, err = svc.ExecuteQuote(context.Background(), uuid.NewString())
+	assert.ErrorIs(t, err, ErrQuoteNotFound)
+	_, err = svc.ExecuteQuote(context.Background(), "not-a-uuid")
+	assert.ErrorIs(t, err, ErrValidation)
+}
+
+func TestExecuteQuote_ChecksLockedAccounts(t *testing.T) {
+	mockRepo := new(MockRepository)
+	svc := newTestFXService(t, mockRepo)
+	pln, eur := fxAccounts(mockRepo)
+	q := fxQuote(mockRepo, pln, eur)
+	mockRepo.On("InTx", (*model.IdempotencyKey)(nil)).Return(nil)
+	expectProduct(mockRepo, plainProduct)
+
+	// The funds reserved by holds since the quote was given are not
+	// available for the exchange.
+	pln.SetHeld(money.MustParseAmount("950"))
+	_, err := svc.ExecuteQuote(context.Background(), q.ID.String())
+	assert.ErrorIs(t, err, ErrInsufficientFunds)
+
+	pln.SetHeld(money.Amount{})
+	eur.Status = model.AccountFrozen
+	_, err = svc.ExecuteQuote(context.Background(), q.ID.String())
+	assert.ErrorIs(t, err, ErrAccountNotActive)
+
+	mockRepo.AssertNotCalled(t, "BookExchange", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestSetRate_Validates(t *testing.T) {
+	mockRepo := new(MockRepository)
+	svc := newTestFXService(t, mockRepo)
+
+	_, err := svc.SetRate(context.Background(), model.FXRate{Base: money.EUR, Quote: money.PLN, Bid: money.MustParseRate("4.40"), Ask: money.MustParseRate("4.30")})
+
+	assert.ErrorIs(t, err, ErrValidation)
+	assert.ErrorIs(t, err, fx.ErrInvalidRate)
+	mockRepo.AssertNotCalled(t, "SetFXRate", mock.Anything)
+}
@@ -0,0 +1,78 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"go-web-server/services/account-service/model"
+	"go-web-server/services/account-service/money"
+	"go-web-server/services/account-service/repository"
+)
+
+// GLService reports on the general ledger that every balance change posts
+// to.
+type GLService interface {
+	ListAccounts(ctx context.Context) ([]model.GLAccount, error)
+	TrialBalance(ctx context.Context, asOf *time.Time) (*model.TrialBalance, error)
+}
+
+type glService struct {
+	repo repository.AccountRepository
+}
+
+func NewGLService(repo repository.AccountRepository) GLService {
+	return &glService{repo: repo}
+}
+
+func (s *glService) ListAccounts(ctx context.Context) ([]model.GLAccount, error) {
+	accounts, err := s.repo.ListGLAccounts()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list GL accounts: %w", err)
+	}
+	return accounts, nil
+}
+
+// TrialBalance totals every GL account per currency and checks that debits
+// equal credits in each currency. Currencies are never netted against each
+// other.
+func (s *glService) TrialBalance(ctx context.Context, asOf *time.Time) (*model.TrialBalance, error) {
+	lines, err := s.repo.TrialBalance(asOf)
+	if err != nil {
+		return nil, fmt.Errorf("failed to compute trial balance: %w", err)
+	}
+
+	tb := &model.TrialBalance{AsOf: asOf, Currencies: []model.CurrencyTrialBalance{}, Balanced: true}
+	index := make(map[money.Currency]int)
+	for _, l := range lines {
+		if l.Type.DebitNormal() {
+			l.Balance, err = l.Debit.Sub(l.Credit)
+		} else {
+			l.Balance, err = l.Credit.Sub(l.Debit)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("GL account %s in %s: %w", l.GLAccount, l.Currency, err)
+		}
+
+		i, ok := index[l.Currency]
+		if !ok {
+			i = len(tb.Currencies)
+			index[l.Currency] = i
+			tb.Currencies = append(tb.Currencies, model.CurrencyTrialBalance{Currency: l.Currency})
+		}
+		c := &tb.Currencies[i]
+		c.Accounts = append(c.Accounts, l)
+		if c.TotalDebit, err = c.TotalDebit.Add(l.Debit); err != nil {
+			return nil, fmt.Errorf("total debits in %s: %w", l.Currency, err)
+		}
+		if c.TotalCredit, err = c.TotalCredit.Add(l.Credit); err != nil {
+			return nil, fmt.Errorf("total credits in %s: %w", l.Currency, err)
+		}
+	}
+	for i := range tb.Currencies {
+		c := &tb.Currencies[i]
+		c.Balanced = c.TotalDebit.Cmp(c.TotalCredit) == 0
+		tb.Balanced = tb.Balanced && c.Balanced
+	}
+	return tb, nil
+}
@@ -0,0 +1,53 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"go-web-server/services/account-service/model"
+	"go-web-server/services/account-service/money"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTrialBalance_PerCurrency(t *testing.T) {
+	mockRepo := new(MockRepository)
+	svc := NewGLService(mockRepo)
+	a := money.MustParseAmount
+	mockRepo.On("TrialBalance", (*time.Time)(nil)).Return([]model.TrialBalanceLine{
+		{GLAccount: model.GLFXPosition, Type: model.GLAsset, Currency: money.EUR, Debit: a("22.98")},
+		{GLAccount: model.GLCustomerDeposits, Type: model.GLLiability, Currency: money.EUR, Credit: a("22.98")},
+		{GLAccount: model.GLCash, Type: model.GLAsset, Currency: money.PLN, Debit: a("500"), Credit: a("50")},
+		{GLAccount: model.GLCustomerDeposits, Type: model.GLLiability, Currency: money.PLN, Debit: a("150"), Credit: a("500")},
+		{GLAccount: model.GLFXPosition, Type: model.GLAsset, Currency: money.PLN, Credit: a("100")},
+	}, nil)
+
+	tb, err := svc.TrialBalance(context.Background(), nil)
+
+	require.NoError(t, err)
+	assert.True(t, tb.Balanced)
+	require.Len(t, tb.Currencies, 2)
+	pln := tb.Currencies[1]
+	assert.Equal(t, money.PLN, pln.Currency)
+	assert.Equal(t, a("650"), pln.TotalDebit)
+	assert.Equal(t, a("650"), pln.TotalCredit)
+	assert.Equal(t, a("450"), pln.Accounts[0].Balance)
+	assert.Equal(t, a("350"), pln.Accounts[1].Balance)
+	assert.Equal(t, a("-100"), pln.Accounts[2].Balance)
+}
+
+func TestTrialBalance_ReportsImbalance(t *testing.T) {
+	mockRepo := new(MockRepository)
+	svc := NewGLService(mockRepo)
+	mockRepo.On("TrialBalance", (*time.Time)(nil)).Return([]model.TrialBalanceLine{
+		{GLAccount: model.GLCash, Type: model.GLAsset, Currency: money.PLN, Debit: money.MustParseAmount("10")},
+	}, nil)
+
+	tb, err := svc.TrialBalance(context.Background(), nil)
+
+	require.NoError(t, err)
+	assert.False(t, tb.Balanced)
+	assert.False(t, tb.Currencies[0].Balanced)
+}
@@ -0,0 +1,299 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"go-web-server/services/account-service/model"
+	"go-web-server/services/account-service/money"
+	"go-web-server/services/account-service/repository"
+
+	"github.com/google/uuid"
+)
+
+var (
+	// ErrHoldNotFound is returned when the requested hold does not exist.
+	ErrHoldNotFound = errors.New("hold not found")
+	// ErrHoldNotActive is returned, wrapped in a HoldNotActiveError, when
+	// capturing or releasing a hold that was captured, released or has
+	// expired. Handlers map it to 409.
+	ErrHoldNotActive = repository.ErrHoldNotActive
+	// ErrCaptureExceedsHold is returned when a capture is larger than what
+	// remains of the hold.
+	ErrCaptureExceedsHold = repository.ErrCaptureExceedsHold
+)
+
+type HoldNotActiveError = repository.HoldNotActiveError
+
+const (
+	// DefaultHoldTTL is how long a hold lasts when NewHoldService is given no
+	// TTL and the request names no expiry.
+	DefaultHoldTTL = 7 * 24 * time.Hour
+	// MaxHoldTTL bounds the expiry a request may ask for.
+	MaxHoldTTL = 30 * 24 * time.Hour
+)
+
+// HoldService reserves funds on an account ahead of a card-style payment and
+// later settles or gives up the reservation. Held funds stay in the ledger
+// balance but not in the available balance that debits are checked against.
+type HoldService interface {
+	// PlaceHold reserves amount until expiresAt, or for the default TTL when
+	// expiresAt is nil.
+	PlaceHold(ctx context.Context, accountID string, amount money.Money, description string, expiresAt *time.Time) (*model.Hold, error)
+	GetHold(ctx context.Context, holdID string) (*model.Hold, error)
+	ListHolds(ctx context.Context, accountID string, status model.HoldStatus) ([]model.Hold, error)
+	// CaptureHold settles amount of the hold, or all of it when amount is
+	// nil, and charges the withdrawal fee on it. A partial capture leaves
+	// the rest held unless final is set.
+	CaptureHold(ctx context.Context, holdID string, amount *money.Amount, final bool) (*model.HoldSettlement, error)
+	ReleaseHold(ctx context.Context, holdID string) (*model.Hold, error)
+	// ExpireHolds marks the holds past their expiry as expired.
+	ExpireHolds(ctx context.Context) (int, error)
+}
+
+type holdService struct {
+	*accountService
+	ttl time.Duration
+	now func() time.Time
+}
+
+func NewHoldService(repo repository.AccountRepository, ttl time.Duration) HoldService {
+	if ttl <= 0 {
+		ttl = DefaultHoldTTL
+	}
+	return &holdService{accountService: &accountService{repo: repo}, ttl: ttl, now: time.Now}
+}
+
+func (s *holdService) PlaceHold(ctx context.Context, accountID string, amount money.Money, description string, expiresAt *time.Time) (*model.Hold, error) {
+	if amount.Sign() <= 0 {
+		return nil, invalid("amount", "hold amount must be positive")
+	}
+	now := s.now()
+	expiry := now.Add(s.ttl)
+	if expiresAt != nil {
+		if !expiresAt.After(now) {
+			return nil, invalid("expiresAt", "expiry must be in the future")
+		}
+		if expiresAt.After(now.Add(MaxHoldTTL)) {
+			return nil, invalid("expiresAt", "a hold may last at most %s", MaxHoldTTL)
+		}
+		expiry = *expiresAt
+	}
+
+	// The requested expiry, not the computed one, is fingerprinted: a retry
+	// without expiresAt must match the original however late it comes.
+	var requestedExpiry string
+	if expiresAt != nil {
+		requestedExpiry = expiresAt.UTC().Format(time.RFC3339Nano)
+	}
+	idem := idempotencyKey(ctx, "place_hold", accountID, amount.Amount.String(), string(amount.Currency), description, requestedExpiry)
+	var previous model.Hold
+	replayed, err := s.replay(idem, &previous)
+	if err != nil {
+		return nil, err
+	}
+	if replayed {
+		return &previous, nil
+	}
+
+	var h *model.Hold
+	err = s.repo.InTx(idem, &h, func(uow repository.UnitOfWork) error {
+		acc, err := uow.LockAccount(accountID)
+		if err != nil {
+			return err
+		}
+		if acc == nil {
+			return ErrAccountNotFound
+		}
+		if err := requireActive(acc); err != nil {
+			return err
+		}
+		if acc.Currency != amount.Currency {
+			return fmt.Errorf("%w: cannot hold %s on a %s account", ErrCurrencyMismatch, amount.Currency, acc.Currency)
+		}
+
+		// Business Rule: A hold is a debit waiting to be captured, so it
+		// meets the product's withdrawal limit, needs the available funds
+		// and counts against the transaction limits when it is placed
+		product, err := lockedProduct(uow, acc)
+		if err != nil {
+			return err
+		}
+		if err := checkDebit(product, amount); err != nil {
+			return err
+		}
+		if err := requireFunds(acc, amount); err != nil {
+			return err
+		}
+		if err := uow.ConsumeLimits(acc, product, amount); err != nil {
+			return err
+		}
+
+		h = &model.Hold{
+			ID:          uuid.New(),
+			Amount:      amount.Amount,
+			Currency:    amount.Currency,
+			Description: description,
+			ExpiresAt:   expiry,
+		}
+		return uow.PlaceHold(acc, h)
+	})
+	if err != nil {
+		return nil, wrapFailure(err, "place hold")
+	}
+	return h, nil
+}
+
+func (s *holdService) GetHold(ctx context.Context, holdID string) (*model.Hold, error) {
+	if _, err := uuid.Parse(holdID); err != nil {
+		return nil, &ValidationError{Field: "holdId", Err: err}
+	}
+	h, err := s.repo.GetHold(holdID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get hold: %w", err)
+	}
+	if h == nil {
+		return nil, ErrHoldNotFound
+	}
+	return h, nil
+}
+
+// ListHolds returns the newest holds of an account, at most MaxPageSize.
+func (s *holdService) ListHolds(ctx context.Context, accountID string, status model.HoldStatus) ([]model.Hold, error) {
+	if status != "" && !status.Valid() {
+		return nil, invalid("status", "unknown hold status %q", status)
+	}
+	if _, err := s.GetAccount(ctx, accountID); err != nil {
+		return nil, err
+	}
+	holds, err := s.repo.ListHolds(accountID, status, MaxPageSize)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list holds: %w", err)
+	}
+	return holds, nil
+}
+
+// CaptureHold settles the hold with a hold_capture ledger entry. Repeating
+// the call with the same Idempotency-Key returns the original settlement.
+func (s *holdService) CaptureHold(ctx context.Context, holdID string, amount *money.Amount, final bool) (*model.HoldSettlement, error) {
+	if amount != nil && amount.Sign() <= 0 {
+		return nil, invalid("amount", "capture amount must be positive")
+	}
+
+	params := []string{holdID, fmt.Sprint(final)}
+	if amount != nil {
+		params = append(params, amount.String())
+	}
+	idem := idempotencyKey(ctx, "capture_hold", params...)
+	var previous model.HoldSettlement
+	replayed, err := s.replay(idem, &previous)
+	if err != nil {
+		return nil, err
+	}
+	if replayed {
+		return &previous, nil
+	}
+
+	h, err := s.GetHold(ctx, holdID)
+	if err != nil {
+		return nil, err
+	}
+	var settlement *model.HoldSettlement
+	err = s.repo.InTx(idem, &settlement, func(uow repository.UnitOfWork) error {
+		settlement, err = captureHold(uow, h.AccountID, h.ID, amount, final)
+		return err
+	})
+	if err != nil {
+		return nil, wrapFailure(err, "capture hold")
+	}
+	return settlement, nil
+}
+
+// captureHold applies the business rules of capturing a hold of accountID
+// in uow and books the capture.
+func captureHold(uow repository.UnitOfWork, accountID, holdID uuid.UUID, amount *money.Amount, final bool) (*model.HoldSettlement, error) {
+	// The account is locked before the hold, in the same order as closing
+	// an account, which releases its holds
+	acc, err := uow.LockAccount(accountID.String())
+	if err != nil {
+		return nil, err
+	}
+	if acc == nil {
+		return nil, ErrAccountNotFound
+	}
+	if err := requireActive(acc); err != nil {
+		return nil, err
+	}
+	h, err := uow.LockHold(holdID)
+	if err != nil {
+		return nil, err
+	}
+
+	captured, err := h.Remaining()
+	if err != nil {
+		return nil, err
+	}
+	if amount != nil {
+		if _, err := money.NewExact(*amount, h.Currency); err != nil {
+			return nil, &ValidationError{Field: "amount", Err: err}
+		}
+		if amount.Cmp(captured) > 0 {
+			return nil, fmt.Errorf("%w: %s requested, %s %s remaining", ErrCaptureExceedsHold, amount, captured, h.Currency)
+		}
+		captured = *amount
+	}
+
+	// Business Rule: A capture is the withdrawal the hold reserved. The hold
+	// was placed within the account's funds and limits, so only the
+	// product's withdrawal fee is checked against the available balance
+	// and charged with it
+	capture := money.New(captured, h.Currency)
+	product, err := lockedProduct(uow, acc)
+	if err != nil {
+		return nil, err
+	}
+	fee, err := operationFee(uow, product, model.FeeWithdrawal, capture)
+	if err != nil {
+		return nil, err
+	}
+	if !fee.IsZero() {
+		if err := requireFunds(acc, fee); err != nil {
+			return nil, err
+		}
+	}
+	entry, err := uow.CaptureHold(acc, h, capture, final)
+	if err != nil {
+		return nil, err
+	}
+	settlement := &model.HoldSettlement{Hold: *h, Entry: *entry}
+	if fee.IsZero() {
+		return settlement, nil
+	}
+	if settlement.Fee, err = uow.PostFee(acc, fee, entry, "Withdrawal fee"); err != nil {
+		return nil, err
+	}
+	return settlement, nil
+}
+
+// ReleaseHold gives up the rest of the hold and makes it available again.
+func (s *holdService) ReleaseHold(ctx context.Context, holdID string) (*model.Hold, error) {
+	h, err := s.GetHold(ctx, holdID)
+	if err != nil {
+		return nil, err
+	}
+	released, err := s.repo.ReleaseHold(h.ID.String())
+	if err != nil {
+		return nil, wrapFailure(err, "release hold")
+	}
+	return released, nil
+}
+
+func (s *holdService) ExpireHolds(ctx context.Context) (int, error) {
+	n, err := s.repo.ExpireHolds()
+	if err != nil {
+		return 0, fmt.Errorf("failed to expire holds: %w", err)
+	}
+	return n, nil
+}
@@ -0,0 +1,255 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"go-web-server/services/account-service/model"
+	"go-web-server/services/account-service/money"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPlaceHold_UsesDefaultExpiry(t *testing.T) {
+	mockRepo := new(MockRepository)
+	svc := NewHoldService(mockRepo, time.Hour).(*holdService)
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	svc.now = func() time.Time { return now }
+
+	acc := &model.Account{ID: uuid.New(), Currency: money.PLN, Status: model.AccountActive, Balance: money.MustParseAmount("100")}
+	acc.SetHeld(money.MustParseAmount("60"))
+	mockRepo.On("InTx", (*model.IdempotencyKey)(nil)).Return(nil)
+	mockRepo.On("LockAccount", acc.ID.String()).Return(acc, nil)
+	expectProduct(mockRepo, plainProduct)
+	mockRepo.On("PlaceHold", acc, mock.MatchedBy(func(h *model.Hold) bool {
+		return h.Amount == money.MustParseAmount("40") && h.ExpiresAt.Equal(now.Add(time.Hour))
+	})).Return(nil).Run(func(args mock.Arguments) { args.Get(1).(*model.Hold).Status = model.HoldActive })
+
+	h, err := svc.PlaceHold(context.Background(), acc.ID.String(), money.New(money.MustParseAmount("40"), money.PLN), "Card payment", nil)
+
+	require.NoError(t, err)
+	assert.Equal(t, model.HoldActive, h.Status)
+	mockRepo.AssertExpectations(t)
+	// The hold counts against the limits like the debit it reserves.
+	mockRepo.AssertCalled(t, "ConsumeLimits", acc, plainProduct, money.New(money.MustParseAmount("40"), money.PLN))
+}
+
+func TestPlaceHold_FingerprintCoversExpiry(t *testing.T) {
+	mockRepo := new(MockRepository)
+	svc := NewHoldService(mockRepo, time.Hour).(*holdService)
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	svc.now = func() time.Time { return now }
+	ctx := WithIdempotencyKey(context.Background(), "alice", "hold-1")
+	accountID := uuid.New().String()
+	amount := money.New(money.MustParseAmount("40"), money.PLN)
+
+	var keys []*model.IdempotencyKey
+	mockRepo.On("GetIdempotencyRecord", "alice", "hold-1").Return(nil, nil)
+	mockRepo.On("InTx", mock.AnythingOfType("*model.IdempotencyKey")).
+		Run(func(args mock.Arguments) { keys = append(keys, args.Get(0).(*model.IdempotencyKey)) }).
+		Return(ErrAccountNotFound)
+
+	tomorrow, nextWeek := now.AddDate(0, 0, 1), now.AddDate(0, 0, 7)
+	svc.PlaceHold(ctx, accountID, amount, "Card payment", &tomorrow)
+	svc.PlaceHold(ctx, accountID, amount, "Card payment", &nextWeek)
+	svc.PlaceHold(ctx, accountID, amount, "Card payment", nil)
+	// Without expiresAt the default expiry moves with the clock, but a
+	// retry is still the same request.
+	now = now.Add(time.Minute)
+	svc.PlaceHold(ctx, accountID, amount, "Card payment", nil)
+
+	require.Len(t, keys, 4)
+	assert.NotEqual(t, keys[0].Fingerprint, keys[1].Fingerprint)
+	assert.NotEqual(t, keys[1].Fingerprint, keys[2].Fingerprint)
+	assert.Equal(t, keys[2].Fingerprint, keys[3].Fingerprint)
+}
+
+func TestPlaceHold_ConsumesLimits(t *testing.T) {
+	mockRepo := new(MockRepository)
+	svc := NewHoldService(mockRepo, 0)
+
+	acc := &model.Account{ID: uuid.New(), Currency: money.PLN, Status: model.AccountActive, Balance: money.MustParseAmount("5000")}
+	amount := money.New(money.MustParseAmount("800"), money.PLN)
+	mockRepo.On("InTx", (*model.IdempotencyKey)(nil)).Return(nil)
+	mockRepo.On("LockAccount", acc.ID.String()).Return(acc, nil)
+	mockRepo.On("ConsumeLimits", acc, plainProduct, amount).
+		Return(&TransactionLimitError{Limit: ErrDailyLimitExceeded, Max: money.New(money.MustParseAmount("1000"), money.PLN), Used: money.New(money.MustParseAmount("300"), money.PLN), Requested: amount, Customer: true})
+	expectProduct(mockRepo, plainProduct)
+
+	_, err := svc.PlaceHold(context.Background(), acc.ID.String(), amount, "Card payment", nil)
+
+	assert.ErrorIs(t, err, ErrDailyLimitExceeded)
+	assert.Contains(t, err.Error(), "customer allows")
+	mockRepo.AssertNotCalled(t, "PlaceHold", mock.Anything, mock.Anything)
+}
+
+func TestPlaceHold_Rejected(t *testing.T) {
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	past, far := now.Add(-time.Minute), now.Add(MaxHoldTTL+time.Minute)
+	pln := func(s string) money.Money { return money.New(money.MustParseAmount(s), money.PLN) }
+
+	cases := map[string]struct {
+		amount    money.Money
+		expiresAt *time.Time
+		want      error
+	}{
+		"non-positive amount": {pln("0"), nil, ErrValidation},
+		"expiry in the past":  {pln("10"), &past, ErrValidation},
+		"expiry too far":      {pln("10"), &far, ErrValidation},
+		"other currency":      {money.New(money.MustParseAmount("10"), money.EUR), nil, ErrCurrencyMismatch},
+		"exceeds available":   {pln("40.01"), nil, ErrInsufficientFunds},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			mockRepo := new(MockRepository)
+			svc := NewHoldService(mockRepo, 0).(*holdService)
+			svc.now = func() time.Time { return now }
+			acc := &model.Account{ID: uuid.New(), Currency: money.PLN, Status: model.AccountActive, Balance: money.MustParseAmount("100")}
+			acc.SetHeld(money.MustParseAmount("60"))
+			mockRepo.On("InTx", (*model.IdempotencyKey)(nil)).Return(nil)
+			mockRepo.On("LockAccount", acc.ID.String()).Return(acc, nil)
+			expectProduct(mockRepo, plainProduct)
+
+			_, err := svc.PlaceHold(context.Background(), acc.ID.String(), tc.amount, "", tc.expiresAt)
+
+			assert.ErrorIs(t, err, tc.want)
+			mockRepo.AssertNotCalled(t, "PlaceHold", mock.Anything, mock.Anything)
+		})
+	}
+}
+
+func TestUpdateBalance_WithdrawalChecksAvailableBalance(t *testing.T) {
+	mockRepo := new(MockRepository)
+	svc := NewAccountService(mockRepo)
+
+	acc := &model.Account{ID: uuid.New(), Currency: money.PLN, Status: model.AccountActive, Balance: money.MustParseAmount("100")}
+	acc.SetHeld(money.MustParseAmount("90"))
+	mockRepo.On("InTx", (*model.IdempotencyKey)(nil)).Return(nil)
+	mockRepo.On("LockAccount", acc.ID.String()).Return(acc, nil)
+	expectProduct(mockRepo, plainProduct)
+
+	_, err := svc.UpdateBalance(context.Background(), acc.ID.String(), money.New(money.MustParseAmount("-20"), money.PLN), model.Withdrawal, "ATM")
+
+	var insufficient *InsufficientFundsError
+	require.ErrorAs(t, err, &insufficient)
+	assert.Equal(t, money.New(money.MustParseAmount("10"), money.PLN), insufficient.Balance)
+	mockRepo.AssertNotCalled(t, "PostEntry", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestCaptureHold(t *testing.T) {
+	mockRepo := new(MockRepository)
+	svc := NewHoldService(mockRepo, 0)
+	acc := &model.Account{ID: uuid.New(), Currency: money.PLN, Status: model.AccountActive, Balance: money.MustParseAmount("100")}
+	h := &model.Hold{ID: uuid.New(), AccountID: acc.ID, Amount: money.MustParseAmount("40"), Currency: money.PLN, Status: model.HoldActive}
+	amount := money.MustParseAmount("25")
+	entry := &model.LedgerEntry{ID: uuid.New(), ReferenceID: &h.ID}
+
+	mockRepo.On("GetHold", h.ID.String()).Return(h, nil)
+	mockRepo.On("InTx", (*model.IdempotencyKey)(nil)).Return(nil)
+	mockRepo.On("LockAccount", acc.ID.String()).Return(acc, nil)
+	mockRepo.On("LockHold", h.ID).Return(h, nil)
+	expectProduct(mockRepo, plainProduct)
+	mockRepo.On("CaptureHold", acc, h, money.New(amount, money.PLN), true).Return(entry, nil).
+		Run(func(args mock.Arguments) { args.Get(1).(*model.Hold).Status = model.HoldCaptured })
+
+	settlement, err := svc.CaptureHold(context.Background(), h.ID.String(), &amount, true)
+
+	require.NoError(t, err)
+	assert.Equal(t, model.HoldCaptured, settlement.Hold.Status)
+	assert.Equal(t, entry.ID, settlement.Entry.ID)
+	assert.Nil(t, settlement.Fee)
+	mockRepo.AssertExpectations(t)
+	// The limits were consumed when the hold was placed.
+	mockRepo.AssertNotCalled(t, "ConsumeLimits", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestCaptureHold_ChargesWithdrawalFee(t *testing.T) {
+	withdrawalFee := &model.FeeSchedule{ProductCode: "plain", Operation: model.FeeWithdrawal, Flat: money.MustParseAmount("2")}
+	for _, tc := range []struct {
+		name, balance string
+		want          error
+	}{
+		{"covered", "100", nil},
+		// Only 1 is available besides the held 40.
+		{"not covered", "41", ErrInsufficientFunds},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			mockRepo := new(MockRepository)
+			svc := NewHoldService(mockRepo, 0)
+			acc := &model.Account{ID: uuid.New(), Currency: money.PLN, Status: model.AccountActive, ProductCode: "plain", Balance: money.MustParseAmount(tc.balance)}
+			acc.SetHeld(money.MustParseAmount("40"))
+			h := &model.Hold{ID: uuid.New(), AccountID: acc.ID, Amount: money.MustParseAmount("40"), Currency: money.PLN, Status: model.HoldActive}
+			entry := &model.LedgerEntry{ID: uuid.New(), ReferenceID: &h.ID}
+			fee := &model.LedgerEntry{ID: uuid.New(), ReferenceID: &h.ID}
+
+			mockRepo.On("GetHold", h.ID.String()).Return(h, nil)
+			mockRepo.On("InTx", (*model.IdempotencyKey)(nil)).Return(nil)
+			mockRepo.On("LockAccount", acc.ID.String()).Return(acc, nil)
+			mockRepo.On("LockHold", h.ID).Return(h, nil)
+			mockRepo.On("FeeSchedule", "plain", model.FeeWithdrawal).Return(withdrawalFee, nil)
+			expectProduct(mockRepo, plainProduct)
+			mockRepo.On("CaptureHold", acc, h, money.New(money.MustParseAmount("40"), money.PLN), false).Return(entry, nil)
+			mockRepo.On("PostFee", acc, money.New(money.MustParseAmount("2"), money.PLN), entry, "Withdrawal fee").Return(fee, nil)
+
+			settlement, err := svc.CaptureHold(context.Background(), h.ID.String(), nil, false)
+
+			if tc.want != nil {
+				assert.ErrorIs(t, err, tc.want)
+				mockRepo.AssertNotCalled(t, "CaptureHold", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, fee, settlement.Fee)
+		})
+	}
+}
+
+func TestCaptureHold_Rejected(t *testing.T) {
+	acc := &model.Account{ID: uuid.New(), Currency: money.PLN, Status: model.AccountActive}
+	h := &model.Hold{ID: uuid.New(), AccountID: acc.ID, Amount: money.MustParseAmount("40"), Captured: money.MustParseAmount("30"), Currency: money.PLN, Status: model.HoldActive}
+	tooMuch := money.MustParseAmount("10.01")
+
+	t.Run("exceeds remaining", func(t *testing.T) {
+		mockRepo := new(MockRepository)
+		svc := NewHoldService(mockRepo, 0)
+		mockRepo.On("GetHold", h.ID.String()).Return(h, nil)
+		mockRepo.On("InTx", (*model.IdempotencyKey)(nil)).Return(nil)
+		mockRepo.On("LockAccount", acc.ID.String()).Return(acc, nil)
+		mockRepo.On("LockHold", h.ID).Return(h, nil)
+
+		_, err := svc.CaptureHold(context.Background(), h.ID.String(), &tooMuch, false)
+
+		assert.ErrorIs(t, err, ErrCaptureExceedsHold)
+		mockRepo.AssertNotCalled(t, "CaptureHold", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("hold not active", func(t *testing.T) {
+		mockRepo := new(MockRepository)
+		svc := NewHoldService(mockRepo, 0)
+		mockRepo.On("GetHold", h.ID.String()).Return(h, nil)
+		mockRepo.On("InTx", (*model.IdempotencyKey)(nil)).Return(nil)
+		mockRepo.On("LockAccount", acc.ID.String()).Return(acc, nil)
+		mockRepo.On("LockHold", h.ID).Return(nil, &HoldNotActiveError{HoldID: h.ID, Status: model.HoldExpired})
+
+		_, err := svc.CaptureHold(context.Background(), h.ID.String(), nil, false)
+
+		assert.ErrorIs(t, err, ErrHoldNotActive)
+		assert.NotContains(t, err.Error(), "failed to")
+	})
+}
+
+func TestReleaseHold_NotFound(t *testing.T) {
+	mockRepo := new(MockRepository)
+	svc := NewHoldService(mockRepo, 0)
+	id := uuid.New().String()
+	mockRepo.On("GetHold", id).Return(nil, nil)
+
+	_, err := svc.ReleaseHold(context.Background(), id)
+
+	assert.ErrorIs(t, err, ErrHoldNotFound)
+	mockRepo.AssertNotCalled(t, "ReleaseHold", mock.Anything)
+}
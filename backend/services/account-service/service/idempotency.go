@@ -0,0 +1,79 @@
+package service
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"go-web-server/services/account-service/model"
+	"go-web-server/services/account-service/repository"
+)
+
+// ErrIdempotencyKeyReused is returned when a client reuses an Idempotency-Key
+// for a request with a different payload. Handlers map it to 422.
+var ErrIdempotencyKeyReused = repository.ErrIdempotencyKeyReused
+
+// MaxIdempotencyKeyLength matches the idempotency_keys.key column.
+const MaxIdempotencyKeyLength = 255
+
+type idempotencyKeyCtx struct{}
+
+// scopedKey is an Idempotency-Key together with the caller that sent it.
+type scopedKey struct {
+	owner string
+	key   string
+}
+
+// WithIdempotencyKey attaches the Idempotency-Key sent by owner, the
+// authenticated caller, to ctx. Balance changing service calls made with the
+// returned context are executed at most once per owner and key, so two
+// callers choosing the same key never see each other's results.
+func WithIdempotencyKey(ctx context.Context, owner, key string) context.Context {
+	if key == "" {
+		return ctx
+	}
+	return context.WithValue(ctx, idempotencyKeyCtx{}, scopedKey{owner: owner, key: key})
+}
+
+// IdempotencyKeyFromContext returns the key set by WithIdempotencyKey.
+func IdempotencyKeyFromContext(ctx context.Context) (string, bool) {
+	k, ok := ctx.Value(idempotencyKeyCtx{}).(scopedKey)
+	return k.key, ok
+}
+
+// idempotencyKey builds the key for an operation from ctx, fingerprinting the
+// operation name and its parameters. It returns nil when the caller did not
+// supply a key.
+func idempotencyKey(ctx context.Context, operation string, params ...string) *model.IdempotencyKey {
+	k, ok := ctx.Value(idempotencyKeyCtx{}).(scopedKey)
+	if !ok {
+		return nil
+	}
+	sum := sha256.Sum256([]byte(operation + "\x00" + strings.Join(params, "\x00")))
+	return &model.IdempotencyKey{Owner: k.owner, Key: k.key, Fingerprint: hex.EncodeToString(sum[:])}
+}
+
+// replay looks up a previously completed request with the same key. When one
+// exists its stored response is decoded into out and true is returned.
+func (s *accountService) replay(idem *model.IdempotencyKey, out interface{}) (bool, error) {
+	if idem == nil {
+		return false, nil
+	}
+	rec, err := s.repo.GetIdempotencyRecord(idem.Owner, idem.Key)
+	if err != nil {
+		return false, fmt.Errorf("failed to check idempotency key: %w", err)
+	}
+	if rec == nil {
+		return false, nil
+	}
+	if rec.Fingerprint != idem.Fingerprint {
+		return false, ErrIdempotencyKeyReused
+	}
+	if err := json.Unmarshal(rec.Response, out); err != nil {
+		return false, fmt.Errorf("failed to decode stored response: %w", err)
+	}
+	return true, nil
+}
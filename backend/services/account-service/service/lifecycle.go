@@ -0,0 +1,151 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"go-web-server/services/account-service/model"
+	"go-web-server/services/account-service/repository"
+
+	"github.com/google/uuid"
+)
+
+var (
+	// ErrAccountNotActive is returned, wrapped in an AccountNotActiveError,
+	// when a balance change touches a frozen or closed account. Handlers map
+	// it to 409.
+	ErrAccountNotActive = repository.ErrAccountNotActive
+	// ErrInvalidStatusTransition is returned, wrapped in a
+	// StatusTransitionError, for a lifecycle change the transition table
+	// does not allow.
+	ErrInvalidStatusTransition = repository.ErrInvalidStatusTransition
+	// ErrBalanceNotZero is returned when closing a funded account without a
+	// usable payout account.
+	ErrBalanceNotZero = repository.ErrBalanceNotZero
+	// ErrInvalidStatusReason is returned for an unknown reason code.
+	ErrInvalidStatusReason = errors.New("invalid status reason")
+)
+
+type (
+	AccountNotActiveError = repository.AccountNotActiveError
+	StatusTransitionError = repository.StatusTransitionError
+)
+
+// FreezeAccount blocks all balance changes on an active account.
+func (s *accountService) FreezeAccount(ctx context.Context, accountID string, reason model.StatusReason, note string) (*model.Account, error) {
+	return s.changeStatus(ctx, accountID, model.StatusChange{To: model.AccountFrozen, Reason: reason, Note: note})
+}
+
+// UnfreezeAccount returns a frozen account to active.
+func (s *accountService) UnfreezeAccount(ctx context.Context, accountID string, reason model.StatusReason, note string) (*model.Account, error) {
+	return s.changeStatus(ctx, accountID, model.StatusChange{To: model.AccountActive, Reason: reason, Note: note})
+}
+
+// CloseAccount closes an active or frozen account for good. The balance must
+// be zero, or payoutAccountID must name an active account in the same
+// currency that receives it.
+func (s *accountService) CloseAccount(ctx context.Context, accountID string, reason model.StatusReason, note string, payoutAccountID string) (*model.Account, error) {
+	change := model.StatusChange{To: model.AccountClosed, Reason: reason, Note: note}
+	if payoutAccountID != "" {
+		id, err := uuid.Parse(payoutAccountID)
+		if err != nil {
+			return nil, &ValidationError{Field: "payoutAccountId", Err: err}
+		}
+		if payoutAccountID == accountID {
+			return nil, invalid("payoutAccountId", "cannot pay out to the account being closed")
+		}
+		change.PayoutAccountID = &id
+	}
+	return s.changeStatus(ctx, accountID, change)
+}
+
+func (s *accountService) changeStatus(ctx context.Context, accountID string, change model.StatusChange) (*model.Account, error) {
+	if !change.Reason.Valid() {
+		return nil, invalid("reason", "%w: %q", ErrInvalidStatusReason, change.Reason)
+	}
+
+	acc, err := s.repo.GetAccount(accountID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get account: %w", err)
+	}
+	if acc == nil {
+		return nil, ErrAccountNotFound
+	}
+	change.AccountID = acc.ID
+
+	if change.PayoutAccountID != nil {
+		payout, err := s.repo.GetAccount(change.PayoutAccountID.String())
+		if err != nil {
+			return nil, fmt.Errorf("failed to get account: %w", err)
+		}
+		if payout == nil {
+			return nil, fmt.Errorf("%w: payout account %s", ErrDestinationNotFound, change.PayoutAccountID)
+		}
+		if payout.CustomerID != acc.CustomerID {
+			return nil, invalid("payoutAccountId", "must be an account of the same customer")
+		}
+		if payout.Currency != acc.Currency {
+			return nil, fmt.Errorf("%w: %s payout account cannot receive a %s balance", ErrCurrencyMismatch, payout.Currency, acc.Currency)
+		}
+	}
+
+	err = s.repo.InTx(nil, nil, func(uow repository.UnitOfWork) error {
+		return applyStatusChange(uow, change)
+	})
+	if err != nil {
+		return nil, wrapFailure(err, "change account status")
+	}
+	return s.GetAccount(ctx, accountID)
+}
+
+// applyStatusChange applies the business rules of a lifecycle change in uow
+// and records it, paying the balance out first when it closes a funded
+// account.
+func applyStatusChange(uow repository.UnitOfWork, change model.StatusChange) error {
+	ids := []uuid.UUID{change.AccountID}
+	if change.To == model.AccountClosed && change.PayoutAccountID != nil {
+		ids = append(ids, *change.PayoutAccountID)
+	}
+	locked, err := uow.LockAccounts(ids...)
+	if err != nil {
+		return err
+	}
+	acc := locked[change.AccountID]
+	if acc == nil {
+		return ErrAccountNotFound
+	}
+
+	// Business Rule: The transition table decides from the status found
+	// under the lock
+	if !acc.Status.CanTransitionTo(change.To) {
+		return &StatusTransitionError{From: acc.Status, To: change.To}
+	}
+
+	// Business Rule: A funded account is closed by paying its whole ledger
+	// balance out to an active account; its holds are given up
+	var payoutRef *uuid.UUID
+	if change.To == model.AccountClosed && !acc.Balance.IsZero() {
+		if acc.Balance.IsNegative() {
+			return fmt.Errorf("%w: account is overdrawn by %s", ErrBalanceNotZero, acc.Balance.Neg())
+		}
+		if change.PayoutAccountID == nil {
+			return fmt.Errorf("%w: %s must be paid out to another account", ErrBalanceNotZero, acc.Balance)
+		}
+		payout := locked[*change.PayoutAccountID]
+		if payout == nil {
+			return fmt.Errorf("%w: payout account %s", ErrDestinationNotFound, change.PayoutAccountID)
+		}
+		if err := requireActive(payout); err != nil {
+			return err
+		}
+		transfer, err := uow.BookTransfer(acc, payout, acc.BalanceMoney(), "Payout on account closure")
+		if err != nil {
+			return err
+		}
+		payoutRef = &transfer.ReferenceID
+	}
+
+	_, err = uow.ChangeStatus(acc, change, payoutRef)
+	return err
+}
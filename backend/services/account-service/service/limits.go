@@ -0,0 +1,168 @@
+package service
+
+import (
+	"context"
+	"fmt"
+
+	"go-web-server/services/account-service/model"
+	"go-web-server/services/account-service/money"
+	"go-web-server/services/account-service/repository"
+
+	"github.com/google/uuid"
+)
+
+// LimitService reports and overrides the transaction limits of accounts and
+// sets the limits customers have across their accounts. The limits
+// themselves are enforced by UpdateBalance, Transfer and PlaceHold.
+type LimitService interface {
+	// GetLimits returns an account's limits and what they still allow
+	// today, so that a client can check a withdrawal or transfer first.
+	GetLimits(ctx context.Context, accountID string) (*model.AccountLimits, error)
+	// SetLimitOverride replaces the limits an account overrides; nil fields
+	// return to the product's limits.
+	SetLimitOverride(ctx context.Context, o *model.LimitOverride) (*model.LimitOverride, error)
+	// ListCustomerLimits returns a customer's limits in each currency they
+	// have them in.
+	ListCustomerLimits(ctx context.Context, customerID string) ([]model.CustomerLimits, error)
+	// SetCustomerLimits replaces a customer's limits in l.Currency; limits
+	// that are all zero are removed.
+	SetCustomerLimits(ctx context.Context, l *model.CustomerLimits) (*model.CustomerLimits, error)
+}
+
+type limitService struct {
+	*accountService
+}
+
+func NewLimitService(repo repository.AccountRepository) LimitService {
+	return &limitService{accountService: &accountService{repo: repo}}
+}
+
+func (s *limitService) GetLimits(ctx context.Context, accountID string) (*model.AccountLimits, error) {
+	acc, err := s.GetAccount(ctx, accountID)
+	if err != nil {
+		return nil, err
+	}
+	product, err := s.repo.GetProduct(acc.ProductCode)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get product: %w", err)
+	}
+	if product == nil {
+		return nil, fmt.Errorf("account %s has unknown product %q", acc.ID, acc.ProductCode)
+	}
+	override, err := s.repo.GetLimitOverride(acc.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get limit override: %w", err)
+	}
+	used, err := s.repo.LimitUsage(acc.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get limit usage: %w", err)
+	}
+
+	limits := model.LimitsOf(product, override)
+	headroom, err := limits.Headroom(used)
+	if err != nil {
+		return nil, err
+	}
+	result := &model.AccountLimits{
+		AccountID:  acc.ID,
+		Currency:   acc.Currency,
+		Limits:     limits,
+		Overridden: override != nil,
+		Used:       used,
+		Headroom:   headroom,
+	}
+
+	customer, err := s.repo.GetCustomerLimits(acc.CustomerID, acc.Currency)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get customer limits: %w", err)
+	}
+	if customer == nil {
+		return result, nil
+	}
+	customerUsed, err := s.repo.CustomerLimitUsage(acc.CustomerID, acc.Currency)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get customer limit usage: %w", err)
+	}
+	status := &model.CustomerLimitStatus{Limits: customer.Limits(), Used: customerUsed}
+	if status.Headroom, err = status.Limits.Headroom(customerUsed); err != nil {
+		return nil, err
+	}
+	result.Customer = status
+	if next := status.Headroom.NextTransaction; next != nil {
+		if result.Headroom.NextTransaction == nil || next.Cmp(*result.Headroom.NextTransaction) < 0 {
+			result.Headroom.NextTransaction = next
+		}
+	}
+	return result, nil
+}
+
+func (s *limitService) SetLimitOverride(ctx context.Context, o *model.LimitOverride) (*model.LimitOverride, error) {
+	for _, a := range []struct {
+		field  string
+		amount *money.Amount
+	}{
+		{"perTransaction", o.PerTransaction}, {"daily", o.Daily}, {"monthly", o.Monthly},
+	} {
+		if a.amount != nil && a.amount.IsNegative() {
+			return nil, invalid(a.field, "%s must not be negative", a.field)
+		}
+	}
+	if o.MaxDailyTransactions != nil && *o.MaxDailyTransactions < 0 {
+		return nil, invalid("maxDailyTransactions", "maxDailyTransactions must not be negative")
+	}
+	acc, err := s.GetAccount(ctx, o.AccountID.String())
+	if err != nil {
+		return nil, err
+	}
+	if acc.Status == model.AccountClosed {
+		return nil, &AccountNotActiveError{AccountID: acc.ID, Status: acc.Status}
+	}
+
+	saved, err := s.repo.SaveLimitOverride(o)
+	if err != nil {
+		return nil, fmt.Errorf("failed to save limit override: %w", err)
+	}
+	return saved, nil
+}
+
+func (s *limitService) ListCustomerLimits(ctx context.Context, customerID string) ([]model.CustomerLimits, error) {
+	id, err := uuid.Parse(customerID)
+	if err != nil {
+		return nil, &ValidationError{Field: "customerId", Err: err}
+	}
+	limits, err := s.repo.ListCustomerLimits(id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list customer limits: %w", err)
+	}
+	return limits, nil
+}
+
+func (s *limitService) SetCustomerLimits(ctx context.Context, l *model.CustomerLimits) (*model.CustomerLimits, error) {
+	cur, err := money.ParseCurrency(string(l.Currency))
+	if err != nil {
+		return nil, &ValidationError{Field: "currency", Err: err}
+	}
+	l.Currency = cur
+	for _, a := range []struct {
+		field  string
+		amount money.Amount
+	}{
+		{"daily", l.Daily}, {"monthly", l.Monthly},
+	} {
+		if a.amount.IsNegative() {
+			return nil, invalid(a.field, "%s must not be negative", a.field)
+		}
+	}
+	if l.MaxDailyTransactions < 0 {
+		return nil, invalid("maxDailyTransactions", "maxDailyTransactions must not be negative")
+	}
+
+	saved, err := s.repo.SaveCustomerLimits(l)
+	if err != nil {
+		return nil, fmt.Errorf("failed to save customer limits: %w", err)
+	}
+	if saved == nil {
+		return nil, ErrCustomerNotFound
+	}
+	return saved, nil
+}
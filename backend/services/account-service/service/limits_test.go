@@ -0,0 +1,166 @@
+package service
+
+import (
+	"context"
+	"testing"
+
+	"go-web-server/services/account-service/model"
+	"go-web-server/services/account-service/money"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetLimits(t *testing.T) {
+	mockRepo := new(MockRepository)
+	svc := NewLimitService(mockRepo)
+
+	acc := &model.Account{ID: uuid.New(), Currency: money.PLN, Status: model.AccountActive, ProductCode: "current"}
+	product := &model.Product{
+		Code: "current", MaxWithdrawal: money.MustParseAmount("5000"),
+		DailyLimit: money.MustParseAmount("10000"), MaxDailyTransactions: 5,
+	}
+	monthly := money.MustParseAmount("20000")
+	mockRepo.On("GetAccount", acc.ID.String()).Return(acc, nil)
+	mockRepo.On("GetProduct", "current").Return(product, nil)
+	mockRepo.On("GetLimitOverride", acc.ID).Return(&model.LimitOverride{AccountID: acc.ID, Monthly: &monthly}, nil)
+	mockRepo.On("LimitUsage", acc.ID).Return(model.LimitUsage{
+		Daily: money.MustParseAmount("7500"), Monthly: money.MustParseAmount("18000"), DailyTransactions: 2,
+	}, nil)
+	mockRepo.On("GetCustomerLimits", acc.CustomerID, money.PLN).Return(nil, nil)
+
+	limits, err := svc.GetLimits(context.Background(), acc.ID.String())
+	require.NoError(t, err)
+	assert.True(t, limits.Overridden)
+	assert.Equal(t, monthly, limits.Limits.Monthly)
+	assert.Equal(t, money.MustParseAmount("2500"), *limits.Headroom.Daily)
+	assert.Equal(t, money.MustParseAmount("2000"), *limits.Headroom.Monthly)
+	assert.Equal(t, 3, *limits.Headroom.DailyTransactions)
+	// The smallest of the per-transaction limit and what the periods allow.
+	assert.Equal(t, money.MustParseAmount("2000"), *limits.Headroom.NextTransaction)
+	assert.Nil(t, limits.Customer)
+}
+
+func TestGetLimits_CustomerLimits(t *testing.T) {
+	mockRepo := new(MockRepository)
+	svc := NewLimitService(mockRepo)
+
+	acc := &model.Account{ID: uuid.New(), CustomerID: uuid.New(), Currency: money.PLN, Status: model.AccountActive, ProductCode: "plain"}
+	mockRepo.On("GetAccount", acc.ID.String()).Return(acc, nil)
+	mockRepo.On("GetProduct", "plain").Return(&model.Product{Code: "plain", DailyLimit: money.MustParseAmount("5000")}, nil)
+	mockRepo.On("GetLimitOverride", acc.ID).Return(nil, nil)
+	mockRepo.On("LimitUsage", acc.ID).Return(model.LimitUsage{Daily: money.MustParseAmount("1000")}, nil)
+	mockRepo.On("GetCustomerLimits", acc.CustomerID, money.PLN).
+		Return(&model.CustomerLimits{CustomerID: acc.CustomerID, Currency: money.PLN, Daily: money.MustParseAmount("6000")}, nil)
+	// The customer's other accounts used most of their shared daily limit.
+	mockRepo.On("CustomerLimitUsage", acc.CustomerID, money.PLN).Return(model.LimitUsage{Daily: money.MustParseAmount("4500")}, nil)
+
+	limits, err := svc.GetLimits(context.Background(), acc.ID.String())
+	require.NoError(t, err)
+	assert.Equal(t, money.MustParseAmount("4000"), *limits.Headroom.Daily)
+	require.NotNil(t, limits.Customer)
+	assert.Equal(t, money.MustParseAmount("1500"), *limits.Customer.Headroom.Daily)
+	assert.Equal(t, money.MustParseAmount("1500"), *limits.Headroom.NextTransaction)
+}
+
+func TestGetLimits_Unlimited(t *testing.T) {
+	mockRepo := new(MockRepository)
+	svc := NewLimitService(mockRepo)
+
+	acc := &model.Account{ID: uuid.New(), Currency: money.EUR, Status: model.AccountActive, ProductCode: "plain"}
+	mockRepo.On("GetAccount", acc.ID.String()).Return(acc, nil)
+	mockRepo.On("GetProduct", "plain").Return(plainProduct, nil)
+	mockRepo.On("GetLimitOverride", acc.ID).Return(nil, nil)
+	mockRepo.On("LimitUsage", acc.ID).Return(model.LimitUsage{Daily: money.MustParseAmount("100")}, nil)
+	mockRepo.On("GetCustomerLimits", acc.CustomerID, money.EUR).Return(nil, nil)
+
+	limits, err := svc.GetLimits(context.Background(), acc.ID.String())
+	require.NoError(t, err)
+	assert.False(t, limits.Overridden)
+	assert.Nil(t, limits.Headroom.Daily)
+	assert.Nil(t, limits.Headroom.NextTransaction)
+}
+
+func TestSetLimitOverride(t *testing.T) {
+	mockRepo := new(MockRepository)
+	svc := NewLimitService(mockRepo)
+
+	acc := &model.Account{ID: uuid.New(), Currency: money.PLN, Status: model.AccountActive}
+	daily := money.MustParseAmount("3000")
+	o := &model.LimitOverride{AccountID: acc.ID, Daily: &daily, UpdatedBy: "admin"}
+	mockRepo.On("GetAccount", acc.ID.String()).Return(acc, nil)
+	mockRepo.On("SaveLimitOverride", o).Return(o, nil)
+
+	saved, err := svc.SetLimitOverride(context.Background(), o)
+	require.NoError(t, err)
+	assert.Equal(t, &daily, saved.Daily)
+
+	negative, count := money.MustParseAmount("-1"), -1
+	for field, invalidOverride := range map[string]*model.LimitOverride{
+		"monthly":              {AccountID: acc.ID, Monthly: &negative},
+		"maxDailyTransactions": {AccountID: acc.ID, MaxDailyTransactions: &count},
+	} {
+		_, err := svc.SetLimitOverride(context.Background(), invalidOverride)
+		var vErr *ValidationError
+		if assert.ErrorAs(t, err, &vErr, field) {
+			assert.Equal(t, field, vErr.Field)
+		}
+	}
+
+	closed := &model.Account{ID: uuid.New(), Status: model.AccountClosed}
+	mockRepo.On("GetAccount", closed.ID.String()).Return(closed, nil)
+	_, err = svc.SetLimitOverride(context.Background(), &model.LimitOverride{AccountID: closed.ID, Daily: &daily})
+	assert.ErrorIs(t, err, ErrAccountNotActive)
+	mockRepo.AssertNumberOfCalls(t, "SaveLimitOverride", 1)
+}
+
+func TestSetCustomerLimits(t *testing.T) {
+	mockRepo := new(MockRepository)
+	svc := NewLimitService(mockRepo)
+
+	customerID, unknown := uuid.New(), uuid.New()
+	l := &model.CustomerLimits{CustomerID: customerID, Currency: "pln", Monthly: money.MustParseAmount("50000"), UpdatedBy: "admin"}
+	mockRepo.On("SaveCustomerLimits", mock.MatchedBy(func(l *model.CustomerLimits) bool { return l.CustomerID == customerID })).
+		Return(l, nil)
+	mockRepo.On("SaveCustomerLimits", mock.MatchedBy(func(l *model.CustomerLimits) bool { return l.CustomerID == unknown })).
+		Return(nil, nil)
+
+	saved, err := svc.SetCustomerLimits(context.Background(), l)
+	require.NoError(t, err)
+	assert.Equal(t, money.PLN, saved.Currency)
+
+	_, err = svc.SetCustomerLimits(context.Background(), &model.CustomerLimits{CustomerID: unknown, Currency: money.PLN})
+	assert.ErrorIs(t, err, ErrCustomerNotFound)
+
+	for field, invalidLimits := range map[string]*model.CustomerLimits{
+		"currency":             {CustomerID: customerID, Currency: "XYZ"},
+		"daily":                {CustomerID: customerID, Currency: money.PLN, Daily: money.MustParseAmount("-1")},
+		"maxDailyTransactions": {CustomerID: customerID, Currency: money.PLN, MaxDailyTransactions: -1},
+	} {
+		_, err := svc.SetCustomerLimits(context.Background(), invalidLimits)
+		var vErr *ValidationError
+		if assert.ErrorAs(t, err, &vErr, field) {
+			assert.Equal(t, field, vErr.Field)
+		}
+	}
+	mockRepo.AssertNumberOfCalls(t, "SaveCustomerLimits", 2)
+}
+
+func TestTransfer_ConsumesSourceLimits(t *testing.T) {
+	mockRepo := new(MockRepository)
+	svc := NewAccountService(mockRepo)
+
+	from := &model.Account{ID: uuid.New(), Currency: money.PLN, Status: model.AccountActive, Balance: money.MustParseAmount("5000")}
+	to := &model.Account{ID: uuid.New(), Currency: money.PLN, Status: model.AccountActive}
+	amount := money.New(money.MustParseAmount("800"), money.PLN)
+	mockRepo.On("ConsumeLimits", from, plainProduct, amount).
+		Return(&TransactionLimitError{Limit: ErrVelocityLimitExceeded, Requested: amount, MaxTransactions: 3})
+	expectLocked(mockRepo, from, to)
+
+	_, err := svc.Transfer(context.Background(), from.ID.String(), to.ID.String(), amount, "Fourth today")
+	assert.ErrorIs(t, err, ErrVelocityLimitExceeded)
+	assert.True(t, IsDomainError(err))
+	mockRepo.AssertNotCalled(t, "BookTransfer", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
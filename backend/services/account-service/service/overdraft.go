@@ -0,0 +1,188 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"go-web-server/services/account-service/calendar"
+	"go-web-server/services/account-service/model"
+	"go-web-server/services/account-service/money"
+	"go-web-server/services/account-service/repository"
+
+	"github.com/google/uuid"
+)
+
+// ErrOverdraftInterestDisabled is returned when interest is charged but no
+// overdraft interest rate is configured.
+var ErrOverdraftInterestDisabled = errors.New("overdraft interest is not configured")
+
+const (
+	// overdraftChangesLimit caps ListOverdraftLimitChanges.
+	overdraftChangesLimit = 100
+	// overdraftInterestBasis is the day count overdraft interest accrues
+	// under, daily on an ACT/365 basis.
+	overdraftInterestBasis = 365
+)
+
+// OverdraftService lets admins set how far an account may go below zero and
+// charges daily interest on negative balances.
+type OverdraftService interface {
+	// SetOverdraftLimit sets the limit of an account and records changedBy
+	// and note with the previous limit.
+	SetOverdraftLimit(ctx context.Context, accountID string, limit money.Amount, changedBy, note string) (*model.OverdraftLimitChange, error)
+	ListOverdraftLimitChanges(ctx context.Context, accountID string) ([]model.OverdraftLimitChange, error)
+	// ChargeInterest charges one day of interest on every overdrawn account
+	// for businessDate, or for the day before today when it is zero. Running
+	// it again for the same day charges nobody twice.
+	ChargeInterest(ctx context.Context, businessDate time.Time) (*model.OverdraftInterestRun, error)
+}
+
+type overdraftService struct {
+	*accountService
+	rate     money.Rate
+	calendar *calendar.Calendar
+	now      func() time.Time
+}
+
+// NewOverdraftService creates the service. rate is the annual interest rate
+// on negative balances; with a zero rate ChargeInterest is disabled. Days
+// are those of cal.
+func NewOverdraftService(repo repository.AccountRepository, rate money.Rate, cal *calendar.Calendar) OverdraftService {
+	return &overdraftService{accountService: &accountService{repo: repo}, rate: rate, calendar: cal, now: time.Now}
+}
+
+func (s *overdraftService) SetOverdraftLimit(ctx context.Context, accountID string, limit money.Amount, changedBy, note string) (*model.OverdraftLimitChange, error) {
+	if limit.IsNegative() {
+		return nil, invalid("limit", "overdraft limit must not be negative")
+	}
+	if changedBy == "" {
+		return nil, invalid("changedBy", "the user setting the limit is required")
+	}
+	var change *model.OverdraftLimitChange
+	err := s.repo.InTx(nil, nil, func(uow repository.UnitOfWork) error {
+		acc, err := uow.LockAccount(accountID)
+		if err != nil {
+			return err
+		}
+		if acc == nil {
+			return ErrAccountNotFound
+		}
+		// Business Rule: A closed account keeps its last limit. A limit
+		// below the current overdraft is accepted; it only blocks further
+		// debits
+		if acc.Status == model.AccountClosed {
+			return &AccountNotActiveError{AccountID: acc.ID, Status: acc.Status}
+		}
+		product, err := lockedProduct(uow, acc)
+		if err != nil {
+			return err
+		}
+		if limit.Cmp(product.MaxOverdraft) > 0 {
+			return invalid("limit", "product %s allows an overdraft of at most %s", product.Code, product.MaxOverdraft)
+		}
+
+		change = &model.OverdraftLimitChange{
+			ID:        uuid.New(),
+			NewLimit:  limit,
+			ChangedBy: changedBy,
+			Note:      note,
+		}
+		return uow.SetOverdraftLimit(acc, change)
+	})
+	if err != nil {
+		return nil, wrapFailure(err, "set overdraft limit")
+	}
+	return change, nil
+}
+
+func (s *overdraftService) ListOverdraftLimitChanges(ctx context.Context, accountID string) ([]model.OverdraftLimitChange, error) {
+	acc, err := s.GetAccount(ctx, accountID)
+	if err != nil {
+		return nil, err
+	}
+	changes, err := s.repo.ListOverdraftLimitChanges(acc.ID.String(), overdraftChangesLimit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list overdraft limit changes: %w", err)
+	}
+	return changes, nil
+}
+
+// ChargeInterest charges interest on each account's balance at the end of
+// businessDate, so entries booked after the day ended do not change it.
+func (s *overdraftService) ChargeInterest(ctx context.Context, businessDate time.Time) (*model.OverdraftInterestRun, error) {
+	if s.rate.IsZero() {
+		return nil, ErrOverdraftInterestDisabled
+	}
+	businessDate, err := endedBusinessDate(s.calendar.Today(s.now()), businessDate)
+	if err != nil {
+		return nil, err
+	}
+
+	ids, err := s.repo.OverdrawnAccounts(businessDate)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list overdrawn accounts: %w", err)
+	}
+	run := &model.OverdraftInterestRun{BusinessDate: businessDate.Format("2006-01-02"), Rate: s.rate, Charged: []model.LedgerEntry{}}
+	for _, id := range ids {
+		var entry *model.LedgerEntry
+		err := s.repo.InTx(nil, nil, func(uow repository.UnitOfWork) error {
+			entry, err = chargeOverdraftInterest(uow, id, businessDate, s.rate)
+			return err
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to charge overdraft interest on account %s: %w", id, err)
+		}
+		if entry == nil {
+			run.Skipped++
+			continue
+		}
+		run.Charged = append(run.Charged, *entry)
+	}
+	return run, nil
+}
+
+// chargeOverdraftInterest debits one day of interest at the annual rate on
+// the negative end-of-day balance of an account, locked in uow. It returns
+// nil when the account did not end businessDate overdrawn, the interest
+// rounds to zero or businessDate was already charged.
+func chargeOverdraftInterest(uow repository.UnitOfWork, accountID uuid.UUID, businessDate time.Time, rate money.Rate) (*model.LedgerEntry, error) {
+	acc, err := uow.LockAccount(accountID.String())
+	if err != nil || acc == nil {
+		return nil, err
+	}
+	charged, err := uow.InterestPosted(acc, model.OverdraftInterest, businessDate)
+	if err != nil || charged {
+		return nil, err
+	}
+	// The job may run well after the day ended, so entries that take value
+	// after it are taken back out of the balance
+	balance, err := uow.EndOfDayBalance(acc, businessDate)
+	if err != nil {
+		return nil, err
+	}
+	if !balance.IsNegative() {
+		return nil, nil
+	}
+	interest := rate.Interest(balance, 1, overdraftInterestBasis, acc.Currency)
+	if interest.IsZero() {
+		return nil, nil
+	}
+	return uow.PostInterest(acc, model.OverdraftInterest, money.New(interest, acc.Currency), businessDate,
+		"Overdraft interest for "+businessDate.Format(calendar.DateLayout))
+}
+
+// endedBusinessDate truncates businessDate to a day, defaulting to the day
+// before today, the calendar's current day, and rejects days that have not
+// ended yet.
+func endedBusinessDate(today, businessDate time.Time) (time.Time, error) {
+	if businessDate.IsZero() {
+		businessDate = today.AddDate(0, 0, -1)
+	}
+	businessDate = calendar.Day(businessDate)
+	if !businessDate.Before(today) {
+		return time.Time{}, invalid("businessDate", "interest can only be computed for a day that has ended")
+	}
+	return businessDate, nil
+}
@@ -0,0 +1,120 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"go-web-server/services/account-service/model"
+	"go-web-server/services/account-service/money"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUpdateBalance_WithdrawalUsesOverdraft(t *testing.T) {
+	mockRepo := new(MockRepository)
+	svc := NewAccountService(mockRepo)
+
+	acc := &model.Account{ID: uuid.New(), Currency: money.PLN, Status: model.AccountActive, Balance: money.MustParseAmount("100"), OverdraftLimit: money.MustParseAmount("500")}
+	withdrawal := money.New(money.MustParseAmount("-600"), money.PLN)
+	mockRepo.On("InTx", (*model.IdempotencyKey)(nil)).Return(nil)
+	mockRepo.On("LockAccount", acc.ID.String()).Return(acc, nil)
+	expectProduct(mockRepo, plainProduct)
+	mockRepo.On("PostEntry", acc, withdrawal, model.Withdrawal, "ATM").
+		Return(&model.LedgerEntry{BalanceAfter: money.MustParseAmount("-500")}, nil)
+
+	entry, err := svc.UpdateBalance(context.Background(), acc.ID.String(), withdrawal, model.Withdrawal, "ATM")
+	require.NoError(t, err)
+	assert.Equal(t, money.MustParseAmount("-500"), entry.BalanceAfter)
+
+	_, err = svc.UpdateBalance(context.Background(), acc.ID.String(), money.New(money.MustParseAmount("-600.01"), money.PLN), model.Withdrawal, "ATM")
+	var insufficient *InsufficientFundsError
+	require.ErrorAs(t, err, &insufficient)
+	assert.Equal(t, money.New(money.MustParseAmount("500"), money.PLN), insufficient.OverdraftLimit)
+}
+
+func TestSetOverdraftLimit(t *testing.T) {
+	mockRepo := new(MockRepository)
+	svc := NewOverdraftService(mockRepo, money.Rate{}, warsawCalendar)
+	acc := &model.Account{ID: uuid.New(), Currency: money.PLN, ProductCode: "current", Status: model.AccountActive}
+	mockRepo.On("InTx", (*model.IdempotencyKey)(nil)).Return(nil)
+	mockRepo.On("LockAccount", acc.ID.String()).Return(acc, nil)
+	mockRepo.On("Product", "current").Return(&model.Product{Code: "current", MaxOverdraft: money.MustParseAmount("5000")}, nil)
+	mockRepo.On("SetOverdraftLimit", acc, mock.MatchedBy(func(c *model.OverdraftLimitChange) bool {
+		return c.NewLimit == money.MustParseAmount("1000") && c.ChangedBy == "ops" && c.Note == "raise"
+	})).Return(nil)
+
+	change, err := svc.SetOverdraftLimit(context.Background(), acc.ID.String(), money.MustParseAmount("1000"), "ops", "raise")
+	require.NoError(t, err)
+	assert.Equal(t, money.MustParseAmount("1000"), change.NewLimit)
+
+	_, err = svc.SetOverdraftLimit(context.Background(), acc.ID.String(), money.MustParseAmount("-1"), "ops", "")
+	assert.ErrorIs(t, err, ErrValidation)
+
+	// The product caps the limit.
+	_, err = svc.SetOverdraftLimit(context.Background(), acc.ID.String(), money.MustParseAmount("5000.01"), "ops", "")
+	var vErr *ValidationError
+	require.ErrorAs(t, err, &vErr)
+	assert.Equal(t, "limit", vErr.Field)
+	mockRepo.AssertNumberOfCalls(t, "SetOverdraftLimit", 1)
+
+	// A closed account keeps its last limit.
+	acc.Status = model.AccountClosed
+	_, err = svc.SetOverdraftLimit(context.Background(), acc.ID.String(), money.MustParseAmount("0"), "ops", "")
+	assert.ErrorIs(t, err, ErrAccountNotActive)
+}
+
+func overdrawn(balance money.Amount) *model.Account {
+	return &model.Account{ID: uuid.New(), Currency: money.PLN, Status: model.AccountActive, Balance: balance}
+}
+
+func TestChargeInterest_DefaultsToYesterday(t *testing.T) {
+	mockRepo := new(MockRepository)
+	rate := money.MustParseRate("0.18")
+	svc := NewOverdraftService(mockRepo, rate, warsawCalendar).(*overdraftService)
+	// Already 2 March in Warsaw.
+	svc.now = func() time.Time { return time.Date(2024, 3, 1, 23, 15, 0, 0, time.UTC) }
+	day := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+
+	// charged has been repaid since the day ended and overdrawn went into
+	// its overdraft only today: interest follows the end-of-day balance.
+	charged, posted, overdrawnToday := overdrawn(money.MustParseAmount("200")), overdrawn(money.MustParseAmount("-50")), overdrawn(money.MustParseAmount("-300"))
+	mockRepo.On("OverdrawnAccounts", day).Return([]uuid.UUID{charged.ID, posted.ID, overdrawnToday.ID}, nil)
+	mockRepo.On("InTx", (*model.IdempotencyKey)(nil)).Return(nil)
+	for _, acc := range []*model.Account{charged, posted, overdrawnToday} {
+		mockRepo.On("LockAccount", acc.ID.String()).Return(acc, nil)
+	}
+	mockRepo.On("InterestPosted", charged, model.OverdraftInterest, day).Return(false, nil)
+	mockRepo.On("InterestPosted", posted, model.OverdraftInterest, day).Return(true, nil)
+	mockRepo.On("InterestPosted", overdrawnToday, model.OverdraftInterest, day).Return(false, nil)
+	mockRepo.On("EndOfDayBalance", charged, day).Return(money.MustParseAmount("-1000"), nil)
+	mockRepo.On("EndOfDayBalance", overdrawnToday, day).Return(money.MustParseAmount("100"), nil)
+	// 1000 * 0.18 / 365 = 0.4931..., truncated to the grosz.
+	mockRepo.On("PostInterest", charged, model.OverdraftInterest, money.New(money.MustParseAmount("-0.49"), money.PLN), day, "Overdraft interest for 2024-03-01").
+		Return(&model.LedgerEntry{AccountID: charged.ID, Type: model.OverdraftInterest}, nil)
+
+	run, err := svc.ChargeInterest(context.Background(), time.Time{})
+	require.NoError(t, err)
+	assert.Equal(t, "2024-03-01", run.BusinessDate)
+	assert.Len(t, run.Charged, 1)
+	assert.Equal(t, 2, run.Skipped)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestChargeInterest_Rejected(t *testing.T) {
+	now := time.Date(2024, 3, 2, 12, 0, 0, 0, time.UTC)
+
+	svc := NewOverdraftService(new(MockRepository), money.Rate{}, warsawCalendar)
+	_, err := svc.ChargeInterest(context.Background(), now.AddDate(0, 0, -1))
+	assert.ErrorIs(t, err, ErrOverdraftInterestDisabled)
+
+	mockRepo := new(MockRepository)
+	enabled := NewOverdraftService(mockRepo, money.MustParseRate("0.18"), warsawCalendar).(*overdraftService)
+	enabled.now = func() time.Time { return now }
+	_, err = enabled.ChargeInterest(context.Background(), now)
+	assert.ErrorIs(t, err, ErrValidation)
+	mockRepo.AssertNotCalled(t, "OverdrawnAccounts", mock.Anything)
+}
@@ -0,0 +1,99 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+
+	"go-web-server/services/account-service/model"
+	"go-web-server/services/account-service/money"
+	"go-web-server/services/account-service/repository"
+)
+
+// ErrProductNotFound is returned when no product has the requested code.
+var ErrProductNotFound = errors.New("product not found")
+
+// productCode is the form of product codes, which appear in URLs.
+var productCode = regexp.MustCompile(`^[a-z0-9_]{1,40}$`)
+
+// ProductService manages the account product catalogue.
+type ProductService interface {
+	ListProducts(ctx context.Context) ([]model.Product, error)
+	GetProduct(ctx context.Context, code string) (*model.Product, error)
+	// SaveProduct creates a product or replaces the terms of an existing
+	// one. New terms apply to the product's existing accounts from their
+	// next balance change; a lower overdraft cap does not reduce limits
+	// already given.
+	SaveProduct(ctx context.Context, p *model.Product) (*model.Product, error)
+}
+
+type productService struct {
+	*accountService
+}
+
+func NewProductService(repo repository.AccountRepository) ProductService {
+	return &productService{accountService: &accountService{repo: repo}}
+}
+
+func (s *productService) ListProducts(ctx context.Context) ([]model.Product, error) {
+	products, err := s.repo.ListProducts()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list products: %w", err)
+	}
+	return products, nil
+}
+
+func (s *productService) GetProduct(ctx context.Context, code string) (*model.Product, error) {
+	p, err := s.repo.GetProduct(code)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get product: %w", err)
+	}
+	if p == nil {
+		return nil, ErrProductNotFound
+	}
+	return p, nil
+}
+
+func (s *productService) SaveProduct(ctx context.Context, p *model.Product) (*model.Product, error) {
+	if !productCode.MatchString(p.Code) {
+		return nil, invalid("code", "product code must be 1 to 40 lowercase letters, digits or underscores")
+	}
+	if p.Name == "" {
+		return nil, invalid("name", "product name is required")
+	}
+	if !p.Kind.Valid() {
+		return nil, invalid("kind", "unknown account kind %q", p.Kind)
+	}
+	if len(p.Currencies) == 0 {
+		return nil, invalid("currencies", "at least one currency is required")
+	}
+	for _, c := range p.Currencies {
+		if _, err := money.ParseCurrency(string(c)); err != nil {
+			return nil, &ValidationError{Field: "currencies", Err: err}
+		}
+	}
+	for _, a := range []struct {
+		field  string
+		amount money.Amount
+	}{
+		{"maxOverdraft", p.MaxOverdraft}, {"maxWithdrawal", p.MaxWithdrawal}, {"maxBalance", p.MaxBalance},
+		{"dailyLimit", p.DailyLimit}, {"monthlyLimit", p.MonthlyLimit},
+	} {
+		if a.amount.IsNegative() {
+			return nil, invalid(a.field, "%s must not be negative", a.field)
+		}
+	}
+	if p.MaxDailyTransactions < 0 {
+		return nil, invalid("maxDailyTransactions", "maxDailyTransactions must not be negative")
+	}
+	if p.InterestRate != nil && p.Kind != model.AccountSavings {
+		return nil, invalid("interestRate", "only savings products accrue interest")
+	}
+
+	saved, err := s.repo.SaveProduct(p)
+	if err != nil {
+		return nil, fmt.Errorf("failed to save product: %w", err)
+	}
+	return saved, nil
+}
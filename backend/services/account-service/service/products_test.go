@@ -0,0 +1,198 @@
+package service
+
+import (
+	"context"
+	"testing"
+
+	"go-web-server/services/account-service/model"
+	"go-web-server/services/account-service/money"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// savingsProduct caps a single debit at 1000 and the balance at 10000.
+var savingsProduct = &model.Product{
+	Code: "savings", Kind: model.AccountSavings, Currencies: []money.Currency{money.PLN}, Active: true,
+	MaxWithdrawal: money.MustParseAmount("1000"), MaxBalance: money.MustParseAmount("10000"),
+}
+
+// savingsWithdrawalFee charges 5 per withdrawal from a savings account.
+var savingsWithdrawalFee = &model.FeeSchedule{ProductCode: "savings", Operation: model.FeeWithdrawal, Flat: money.MustParseAmount("5")}
+
+// expectSavingsProduct lets a unit of work read savingsProduct, which
+// charges only for withdrawals. Transaction limits allow any debit unless
+// a test expects a limit check first.
+func expectSavingsProduct(m *MockRepository) {
+	m.On("Product", "savings").Return(savingsProduct, nil)
+	m.On("FeeSchedule", "savings", model.FeeWithdrawal).Return(savingsWithdrawalFee, nil).Maybe()
+	m.On("FeeSchedule", "savings", mock.Anything).Return(nil, nil).Maybe()
+	m.On("ConsumeLimits", mock.Anything, savingsProduct, mock.Anything).Return(nil).Maybe()
+}
+
+func TestUpdateBalance_ChargesWithdrawalFee(t *testing.T) {
+	mockRepo := new(MockRepository)
+	svc := NewAccountService(mockRepo)
+
+	acc := &model.Account{ID: uuid.New(), Currency: money.PLN, Status: model.AccountActive, Balance: money.MustParseAmount("100"), ProductCode: "savings"}
+	amount := money.New(money.MustParseAmount("-50"), money.PLN)
+	withdrawal := &model.LedgerEntry{ID: uuid.New()}
+
+	mockRepo.On("InTx", (*model.IdempotencyKey)(nil)).Return(nil)
+	mockRepo.On("LockAccount", acc.ID.String()).Return(acc, nil)
+	expectSavingsProduct(mockRepo)
+	mockRepo.On("PostEntry", acc, amount, model.Withdrawal, "ATM").Return(withdrawal, nil)
+	mockRepo.On("PostFee", acc, money.New(money.MustParseAmount("5"), money.PLN), withdrawal, "Withdrawal fee").Return(&model.LedgerEntry{}, nil)
+
+	entry, err := svc.UpdateBalance(context.Background(), acc.ID.String(), amount, model.Withdrawal, "ATM")
+	require.NoError(t, err)
+	assert.Equal(t, withdrawal, entry)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestUpdateBalance_TypeMustMatchDirection(t *testing.T) {
+	cases := map[string]struct {
+		amount    string
+		entryType model.LedgerEntryType
+		field     string
+	}{
+		"negative deposit":    {"-50", model.Deposit, "amount"},
+		"zero deposit":        {"0", model.Deposit, "amount"},
+		"positive withdrawal": {"50", model.Withdrawal, "amount"},
+		"negative fee":        {"-50", model.Fee, "type"},
+		"negative interest":   {"-50", model.Interest, "type"},
+		"hold capture":        {"-50", model.HoldCapture, "type"},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			mockRepo := new(MockRepository)
+			svc := NewAccountService(mockRepo)
+
+			// Without the check these would move money with no limits and
+			// no withdrawal fee.
+			_, err := svc.UpdateBalance(context.Background(), uuid.New().String(), money.New(money.MustParseAmount(tc.amount), money.PLN), tc.entryType, "ATM")
+			var vErr *ValidationError
+			require.ErrorAs(t, err, &vErr)
+			assert.Equal(t, tc.field, vErr.Field)
+			mockRepo.AssertNotCalled(t, "InTx", mock.Anything)
+		})
+	}
+}
+
+func TestUpdateBalance_ProductRules(t *testing.T) {
+	cases := map[string]struct {
+		balance, amount string
+		entryType       model.LedgerEntryType
+		// limitErr is what the account's transaction limits make of a
+		// withdrawal.
+		limitErr error
+		err      error
+	}{
+		"over a transaction limit":  {"5000", "-1000.01", model.Withdrawal, &TransactionLimitError{Limit: ErrDailyLimitExceeded}, ErrDailyLimitExceeded},
+		"fee not covered":           {"100", "-96", model.Withdrawal, nil, ErrInsufficientFunds},
+		"deposit above the maximum": {"9999", "1.01", model.Deposit, nil, ErrMaxBalanceExceeded},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			mockRepo := new(MockRepository)
+			svc := NewAccountService(mockRepo)
+			acc := &model.Account{ID: uuid.New(), Currency: money.PLN, Status: model.AccountActive, Balance: money.MustParseAmount(tc.balance), ProductCode: "savings"}
+
+			mockRepo.On("InTx", (*model.IdempotencyKey)(nil)).Return(nil)
+			mockRepo.On("LockAccount", acc.ID.String()).Return(acc, nil)
+			if tc.limitErr != nil {
+				mockRepo.On("ConsumeLimits", acc, savingsProduct, money.New(money.MustParseAmount(tc.amount), money.PLN).Neg()).Return(tc.limitErr)
+			}
+			expectSavingsProduct(mockRepo)
+
+			_, err := svc.UpdateBalance(context.Background(), acc.ID.String(), money.New(money.MustParseAmount(tc.amount), money.PLN), tc.entryType, "Test")
+			assert.ErrorIs(t, err, tc.err)
+			mockRepo.AssertNotCalled(t, "PostEntry", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+		})
+	}
+}
+
+func TestTransfer_ProductRules(t *testing.T) {
+	mockRepo := new(MockRepository)
+	svc := NewAccountService(mockRepo)
+
+	from := &model.Account{ID: uuid.New(), Currency: money.PLN, Status: model.AccountActive, Balance: money.MustParseAmount("5000"), ProductCode: "savings"}
+	to := &model.Account{ID: uuid.New(), Currency: money.PLN, Status: model.AccountActive, Balance: money.MustParseAmount("9500"), ProductCode: "savings"}
+	mockRepo.On("InTx", (*model.IdempotencyKey)(nil)).Return(nil)
+	mockRepo.On("LockAccounts", []uuid.UUID{from.ID, to.ID}).Return(map[uuid.UUID]*model.Account{from.ID: from, to.ID: to}, nil)
+	// The source account's limits are checked before the destination's
+	// maximum balance.
+	tooMuch := money.New(money.MustParseAmount("1500"), money.PLN)
+	mockRepo.On("ConsumeLimits", from, savingsProduct, tooMuch).
+		Return(&TransactionLimitError{Limit: ErrWithdrawalLimitExceeded, Max: money.New(money.MustParseAmount("1000"), money.PLN), Requested: tooMuch})
+	expectSavingsProduct(mockRepo)
+
+	_, err := svc.Transfer(context.Background(), from.ID.String(), to.ID.String(), tooMuch, "Too much at once")
+	var limitErr *TransactionLimitError
+	require.ErrorAs(t, err, &limitErr)
+	assert.Equal(t, ErrWithdrawalLimitExceeded, limitErr.Limit)
+
+	_, err = svc.Transfer(context.Background(), from.ID.String(), to.ID.String(), money.New(money.MustParseAmount("600"), money.PLN), "Over the maximum")
+	assert.ErrorIs(t, err, ErrMaxBalanceExceeded)
+	mockRepo.AssertNotCalled(t, "BookTransfer", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestSaveProduct(t *testing.T) {
+	mockRepo := new(MockRepository)
+	svc := NewProductService(mockRepo)
+
+	p := &model.Product{Code: "youth", Name: "Youth account", Kind: model.AccountCurrent, Currencies: []money.Currency{money.PLN}, MaxWithdrawal: money.MustParseAmount("500")}
+	mockRepo.On("SaveProduct", p).Return(p, nil)
+
+	saved, err := svc.SaveProduct(context.Background(), p)
+	require.NoError(t, err)
+	assert.Equal(t, "youth", saved.Code)
+
+	rate := money.MustParseRate("0.05")
+	for field, invalidProduct := range map[string]*model.Product{
+		"code":         {Code: "Youth Account", Name: "Youth", Kind: model.AccountCurrent, Currencies: []money.Currency{money.PLN}},
+		"name":         {Code: "youth", Kind: model.AccountCurrent, Currencies: []money.Currency{money.PLN}},
+		"kind":         {Code: "youth", Name: "Youth", Kind: "pension", Currencies: []money.Currency{money.PLN}},
+		"currencies":   {Code: "youth", Name: "Youth", Kind: model.AccountCurrent, Currencies: []money.Currency{"XYZ"}},
+		"maxBalance":   {Code: "youth", Name: "Youth", Kind: model.AccountCurrent, Currencies: []money.Currency{money.PLN}, MaxBalance: money.MustParseAmount("-1")},
+		"interestRate": {Code: "youth", Name: "Youth", Kind: model.AccountCurrent, Currencies: []money.Currency{money.PLN}, InterestRate: &rate},
+		"dailyLimit":   {Code: "youth", Name: "Youth", Kind: model.AccountCurrent, Currencies: []money.Currency{money.PLN}, DailyLimit: money.MustParseAmount("-1")},
+	} {
+		_, err := svc.SaveProduct(context.Background(), invalidProduct)
+		var vErr *ValidationError
+		if assert.ErrorAs(t, err, &vErr, field) {
+			assert.Equal(t, field, vErr.Field)
+		}
+	}
+	mockRepo.AssertNumberOfCalls(t, "SaveProduct", 1)
+}
+
+func TestGetProduct_NotFound(t *testing.T) {
+	mockRepo := new(MockRepository)
+	svc := NewProductService(mockRepo)
+	mockRepo.On("GetProduct", "pension").Return(nil, nil)
+
+	_, err := svc.GetProduct(context.Background(), "pension")
+	assert.ErrorIs(t, err, ErrProductNotFound)
+}
+
+func TestCheckDebitAndCredit(t *testing.T) {
+	p := &model.Product{Code: "savings", MaxWithdrawal: money.MustParseAmount("1000"), MaxBalance: money.MustParseAmount("5000")}
+
+	assert.NoError(t, checkDebit(p, money.New(money.MustParseAmount("1000"), money.PLN)))
+	err := checkDebit(p, money.New(money.MustParseAmount("1000.01"), money.PLN))
+	var limitErr *ProductLimitError
+	require.ErrorAs(t, err, &limitErr)
+	assert.ErrorIs(t, err, ErrWithdrawalLimitExceeded)
+	assert.Equal(t, money.New(money.MustParseAmount("1000"), money.PLN), limitErr.Max)
+
+	assert.NoError(t, checkCredit(p, money.New(money.MustParseAmount("5000"), money.PLN)))
+	assert.ErrorIs(t, checkCredit(p, money.New(money.MustParseAmount("5000.01"), money.PLN)), ErrMaxBalanceExceeded)
+
+	// Zero limits mean none.
+	unlimited := &model.Product{Code: "current"}
+	assert.NoError(t, checkDebit(unlimited, money.New(money.MustParseAmount("1000000"), money.PLN)))
+	assert.NoError(t, checkCredit(unlimited, money.New(money.MustParseAmount("1000000"), money.PLN)))
+}
@@ -0,0 +1,162 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"go-web-server/services/account-service/model"
+	"go-web-server/services/account-service/money"
+	"go-web-server/services/account-service/repository"
+
+	"github.com/google/uuid"
+)
+
+// ErrReportNotFound is returned when the requested reconciliation report
+// does not exist.
+var ErrReportNotFound = errors.New("reconciliation report not found")
+
+// ReconciliationService checks that every account's stored balance agrees
+// with its ledger and general-ledger postings.
+type ReconciliationService interface {
+	// Run reconciles all accounts and stores the report. With autoFreeze,
+	// active accounts that have a discrepancy are frozen with reason
+	// ledger_discrepancy.
+	Run(ctx context.Context, autoFreeze bool) (*model.ReconciliationReport, error)
+	GetReport(ctx context.Context, reportID string) (*model.ReconciliationReport, error)
+	LatestReport(ctx context.Context) (*model.ReconciliationReport, error)
+}
+
+type reconciliationService struct {
+	repo repository.AccountRepository
+	now  func() time.Time
+}
+
+func NewReconciliationService(repo repository.AccountRepository) ReconciliationService {
+	return &reconciliationService{repo: repo, now: time.Now}
+}
+
+func (s *reconciliationService) Run(ctx context.Context, autoFreeze bool) (*model.ReconciliationReport, error) {
+	report := &model.ReconciliationReport{
+		ID:               uuid.New(),
+		StartedAt:        s.now(),
+		AffectedAccounts: []uuid.UUID{},
+		AutoFreeze:       autoFreeze,
+		FrozenAccounts:   []uuid.UUID{},
+	}
+
+	snap, err := s.repo.LedgerSnapshot()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read ledger snapshot: %w", err)
+	}
+	report.AccountsChecked = len(snap.Accounts)
+	for _, t := range snap.Accounts {
+		report.EntriesChecked += t.Entries
+	}
+	if report.Discrepancies, err = reconcile(snap); err != nil {
+		return nil, fmt.Errorf("failed to reconcile ledger: %w", err)
+	}
+	report.Clean = len(report.Discrepancies) == 0
+
+	status := make(map[uuid.UUID]model.AccountStatus, len(snap.Accounts))
+	for _, t := range snap.Accounts {
+		status[t.AccountID] = t.Status
+	}
+	seen := make(map[uuid.UUID]bool)
+	for _, d := range report.Discrepancies {
+		if seen[d.AccountID] {
+			continue
+		}
+		seen[d.AccountID] = true
+		report.AffectedAccounts = append(report.AffectedAccounts, d.AccountID)
+
+		if !autoFreeze || status[d.AccountID] != model.AccountActive {
+			continue
+		}
+		change := model.StatusChange{
+			AccountID: d.AccountID,
+			To:        model.AccountFrozen,
+			Reason:    model.ReasonLedgerDiscrepancy,
+			Note:      fmt.Sprintf("Frozen by reconciliation %s", report.ID),
+		}
+		err := s.repo.InTx(nil, nil, func(uow repository.UnitOfWork) error {
+			return applyStatusChange(uow, change)
+		})
+		if errors.Is(err, ErrInvalidStatusTransition) {
+			// Frozen or closed since the snapshot was taken.
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to freeze account %s: %w", d.AccountID, err)
+		}
+		report.FrozenAccounts = append(report.FrozenAccounts, d.AccountID)
+	}
+
+	report.FinishedAt = s.now()
+	if err := s.repo.SaveReconciliationReport(report); err != nil {
+		return nil, fmt.Errorf("failed to save reconciliation report: %w", err)
+	}
+	return report, nil
+}
+
+// reconcile lists the discrepancies in snap, grouped by account in the order
+// of snap.Accounts.
+func reconcile(snap *model.LedgerSnapshot) ([]model.ReconciliationDiscrepancy, error) {
+	breaks := make(map[uuid.UUID][]model.LedgerChainBreak)
+	for _, b := range snap.ChainBreaks {
+		breaks[b.AccountID] = append(breaks[b.AccountID], b)
+	}
+
+	found := []model.ReconciliationDiscrepancy{}
+	for _, t := range snap.Accounts {
+		add := func(kind model.DiscrepancyKind, expected, actual money.Amount, entryID *uuid.UUID) {
+			found = append(found, model.ReconciliationDiscrepancy{
+				AccountID: t.AccountID, Kind: kind, Currency: t.Currency, Expected: expected, Actual: actual, LedgerEntryID: entryID,
+			})
+		}
+		if t.LedgerSum.Cmp(t.Balance) != 0 {
+			add(model.DiscrepancyBalance, t.LedgerSum, t.Balance, nil)
+		}
+		if t.LastBalanceAfter != nil && t.LastBalanceAfter.Cmp(t.Balance) != 0 {
+			add(model.DiscrepancyLastEntry, *t.LastBalanceAfter, t.Balance, nil)
+		}
+		if t.GLBalance.Cmp(t.Balance) != 0 {
+			add(model.DiscrepancyGL, t.GLBalance, t.Balance, nil)
+		}
+		for _, b := range breaks[t.AccountID] {
+			id := b.LedgerEntryID
+			expected, err := b.PreviousBalance.Add(b.Amount)
+			if err != nil {
+				return nil, fmt.Errorf("ledger entry %s: %w", id, err)
+			}
+			add(model.DiscrepancyChainBreak, expected, b.BalanceAfter, &id)
+		}
+	}
+	return found, nil
+}
+
+func (s *reconciliationService) GetReport(ctx context.Context, reportID string) (*model.ReconciliationReport, error) {
+	if _, err := uuid.Parse(reportID); err != nil {
+		return nil, &ValidationError{Field: "reportId", Err: err}
+	}
+	report, err := s.repo.GetReconciliationReport(reportID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get reconciliation report: %w", err)
+	}
+	if report == nil {
+		return nil, ErrReportNotFound
+	}
+	return report, nil
+}
+
+func (s *reconciliationService) LatestReport(ctx context.Context) (*model.ReconciliationReport, error) {
+	report, err := s.repo.LatestReconciliationReport()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get reconciliation report: %w", err)
+	}
+	if report == nil {
+		return nil, ErrReportNotFound
+	}
+	return report, nil
+}
@@ -0,0 +1,110 @@
+package service
+
+import (
+	"context"
+	"testing"
+
+	"go-web-server/services/account-service/model"
+	"go-web-server/services/account-service/money"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReconcile_FindsEachKindOfDiscrepancy(t *testing.T) {
+	a := money.MustParseAmount
+	healthy, drifted, broken := uuid.New(), uuid.New(), uuid.New()
+	entryID := uuid.New()
+	last := a("100")
+
+	found, err := reconcile(&model.LedgerSnapshot{
+		Accounts: []model.AccountLedgerTotals{
+			{AccountID: healthy, Currency: money.PLN, Balance: a("100"), LedgerSum: a("100"), Entries: 2, LastBalanceAfter: &last, GLBalance: a("100")},
+			// The balance was changed without a ledger entry.
+			{AccountID: drifted, Currency: money.PLN, Balance: a("150"), LedgerSum: a("100"), Entries: 1, LastBalanceAfter: &last, GLBalance: a("100")},
+			{AccountID: broken, Currency: money.EUR, Balance: a("100"), LedgerSum: a("100"), Entries: 2, LastBalanceAfter: &last, GLBalance: a("100")},
+		},
+		ChainBreaks: []model.LedgerChainBreak{
+			{AccountID: broken, LedgerEntryID: entryID, PreviousBalance: a("40"), Amount: a("50"), BalanceAfter: a("100")},
+		},
+	})
+	require.NoError(t, err)
+
+	require.Len(t, found, 4)
+	for _, d := range found[:3] {
+		assert.Equal(t, drifted, d.AccountID)
+		assert.Equal(t, a("150"), d.Actual)
+		assert.Equal(t, a("100"), d.Expected)
+	}
+	assert.Equal(t, model.DiscrepancyBalance, found[0].Kind)
+	assert.Equal(t, model.DiscrepancyLastEntry, found[1].Kind)
+	assert.Equal(t, model.DiscrepancyGL, found[2].Kind)
+	assert.Equal(t, model.ReconciliationDiscrepancy{
+		AccountID: broken, Kind: model.DiscrepancyChainBreak, Currency: money.EUR,
+		Expected: a("90"), Actual: a("100"), LedgerEntryID: &entryID,
+	}, found[3])
+}
+
+func TestReconciliationRun_AutoFreezesActiveAccounts(t *testing.T) {
+	mockRepo := new(MockRepository)
+	svc := NewReconciliationService(mockRepo)
+	a := money.MustParseAmount
+	active, frozen := uuid.New(), uuid.New()
+
+	mockRepo.On("LedgerSnapshot").Return(&model.LedgerSnapshot{
+		Accounts: []model.AccountLedgerTotals{
+			{AccountID: active, Currency: money.PLN, Status: model.AccountActive, Balance: a("10"), Entries: 0},
+			{AccountID: frozen, Currency: money.PLN, Status: model.AccountFrozen, Balance: a("10"), LedgerSum: a("5"), Entries: 1, GLBalance: a("10")},
+		},
+	}, nil)
+	activeAcc := &model.Account{ID: active, Currency: money.PLN, Status: model.AccountActive, Balance: a("10")}
+	mockRepo.On("InTx", (*model.IdempotencyKey)(nil)).Return(nil)
+	mockRepo.On("LockAccounts", []uuid.UUID{active}).Return(map[uuid.UUID]*model.Account{active: activeAcc}, nil)
+	mockRepo.On("ChangeStatus", activeAcc, mock.MatchedBy(func(c model.StatusChange) bool {
+		return c.AccountID == active && c.To == model.AccountFrozen && c.Reason == model.ReasonLedgerDiscrepancy
+	}), (*uuid.UUID)(nil)).Return(&model.AccountStatusChange{}, nil)
+	mockRepo.On("SaveReconciliationReport", mock.Anything).Return(nil)
+
+	report, err := svc.Run(context.Background(), true)
+
+	require.NoError(t, err)
+	assert.False(t, report.Clean)
+	assert.Equal(t, 2, report.AccountsChecked)
+	assert.Equal(t, 1, report.EntriesChecked)
+	assert.Equal(t, []uuid.UUID{active, frozen}, report.AffectedAccounts)
+	assert.Equal(t, []uuid.UUID{active}, report.FrozenAccounts)
+	mockRepo.AssertNumberOfCalls(t, "ChangeStatus", 1)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestReconciliationRun_CleanLedgerFreezesNothing(t *testing.T) {
+	mockRepo := new(MockRepository)
+	svc := NewReconciliationService(mockRepo)
+	balance := money.MustParseAmount("10")
+
+	mockRepo.On("LedgerSnapshot").Return(&model.LedgerSnapshot{
+		Accounts: []model.AccountLedgerTotals{
+			{AccountID: uuid.New(), Status: model.AccountActive, Balance: balance, LedgerSum: balance, Entries: 1, LastBalanceAfter: &balance, GLBalance: balance},
+		},
+	}, nil)
+	mockRepo.On("SaveReconciliationReport", mock.Anything).Return(nil)
+
+	report, err := svc.Run(context.Background(), true)
+
+	require.NoError(t, err)
+	assert.True(t, report.Clean)
+	assert.Empty(t, report.Discrepancies)
+	assert.Empty(t, report.FrozenAccounts)
+	mockRepo.AssertNotCalled(t, "InTx", mock.Anything)
+}
+
+func TestLatestReport_NotFound(t *testing.T) {
+	mockRepo := new(MockRepository)
+	svc := NewReconciliationService(mockRepo)
+	mockRepo.On("LatestReconciliationReport").Return(nil, nil)
+
+	_, err := svc.LatestReport(context.Background())
+	assert.ErrorIs(t, err, ErrReportNotFound)
+}
@@ -0,0 +1,144 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"go-web-server/services/account-service/calendar"
+	"go-web-server/services/account-service/model"
+	"go-web-server/services/account-service/money"
+	"go-web-server/services/account-service/repository"
+
+	"github.com/google/uuid"
+)
+
+// ErrSavingsInterestDisabled is returned when interest is accrued but no
+// savings interest rate is configured.
+var ErrSavingsInterestDisabled = errors.New("savings interest is not configured")
+
+// SavingsService accrues interest on savings accounts.
+type SavingsService interface {
+	// AccrueInterest accrues one business day of interest on every savings
+	// account for businessDate, or for the day before today when it is zero,
+	// and capitalises it when the day ends a month. Running it again for the
+	// same day accrues nothing twice.
+	AccrueInterest(ctx context.Context, businessDate time.Time) (*model.InterestAccrualRun, error)
+}
+
+type savingsService struct {
+	*accountService
+	rate     money.Rate
+	dayCount money.DayCount
+	calendar *calendar.Calendar
+	now      func() time.Time
+}
+
+// NewSavingsService creates the service. rate is the annual interest rate on
+// positive savings balances and dayCount the convention interest accrues
+// under; with a zero rate AccrueInterest is disabled. Days are those of cal.
+func NewSavingsService(repo repository.AccountRepository, rate money.Rate, dayCount money.DayCount, cal *calendar.Calendar) SavingsService {
+	return &savingsService{accountService: &accountService{repo: repo}, rate: rate, dayCount: dayCount, calendar: cal, now: time.Now}
+}
+
+// AccrueInterest uses each account's end-of-day balance, so unlike overdraft
+// interest it may run any time after the day ended, including to catch up
+// on missed days in date order.
+func (s *savingsService) AccrueInterest(ctx context.Context, businessDate time.Time) (*model.InterestAccrualRun, error) {
+	if s.rate.IsZero() {
+		return nil, ErrSavingsInterestDisabled
+	}
+	businessDate, err := endedBusinessDate(s.calendar.Today(s.now()), businessDate)
+	if err != nil {
+		return nil, err
+	}
+
+	ids, err := s.repo.SavingsAccounts()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list savings accounts: %w", err)
+	}
+	run := &model.InterestAccrualRun{
+		BusinessDate: businessDate.Format("2006-01-02"),
+		Rate:         s.rate,
+		DayCount:     s.dayCount,
+		Accrued:      []model.InterestAccrual{},
+	}
+	for _, id := range ids {
+		var accrual *model.InterestAccrual
+		err := s.repo.InTx(nil, nil, func(uow repository.UnitOfWork) error {
+			accrual, err = accrueInterest(uow, id, businessDate, s.rate, s.dayCount)
+			return err
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to accrue interest on account %s: %w", id, err)
+		}
+		if accrual == nil {
+			run.Skipped++
+			continue
+		}
+		run.Accrued = append(run.Accrued, *accrual)
+	}
+	return run, nil
+}
+
+// accrueInterest accrues one business day of interest on the end-of-day
+// balance of a savings account, locked in uow, at the annual rate of the
+// account's product or at rate when the product sets none, to four decimal
+// places. It returns nil when businessDate was already accrued.
+func accrueInterest(uow repository.UnitOfWork, accountID uuid.UUID, businessDate time.Time, rate money.Rate, dayCount money.DayCount) (*model.InterestAccrual, error) {
+	acc, err := uow.LockAccount(accountID.String())
+	if err != nil || acc == nil {
+		return nil, err
+	}
+	accrued, err := uow.InterestAccrued(acc, businessDate)
+	if err != nil || accrued {
+		return nil, err
+	}
+	product, err := lockedProduct(uow, acc)
+	if err != nil {
+		return nil, err
+	}
+	if product.InterestRate != nil {
+		rate = *product.InterestRate
+	}
+
+	// The job may run well after the day ended, so entries that take value
+	// after it are taken back out of the balance
+	balance, err := uow.EndOfDayBalance(acc, businessDate)
+	if err != nil {
+		return nil, err
+	}
+	accrual := &model.InterestAccrual{
+		AccountID:    acc.ID,
+		BusinessDate: businessDate.Format(calendar.DateLayout),
+		Balance:      balance,
+		Currency:     acc.Currency,
+		Days:         dayCount.Days(businessDate),
+	}
+	// Business Rule: Interest is only paid on a positive balance, but every
+	// day is recorded
+	if balance.Sign() > 0 {
+		accrual.Amount = rate.Accrue(balance, accrual.Days, dayCount.Basis())
+	}
+	if err := uow.RecordAccrual(acc, accrual, rate, dayCount); err != nil {
+		return nil, err
+	}
+
+	// Business Rule: The last day of a month capitalises the whole minor
+	// units of the accrued interest; the rest is carried into the next month
+	if businessDate.AddDate(0, 0, 1).Day() != 1 {
+		return accrual, nil
+	}
+	interest := acc.AccruedInterest.Truncate(acc.Currency.MinorUnits())
+	if interest.Sign() <= 0 {
+		return accrual, nil
+	}
+	accrual.Capitalised, err = uow.PostInterest(acc, model.Interest, money.New(interest, acc.Currency), businessDate,
+		"Interest for "+businessDate.Format("2006-01"))
+	if err != nil {
+		return nil, err
+	}
+	accrual.Accrued = acc.AccruedInterest
+	return accrual, nil
+}
@@ -0,0 +1,71 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"go-web-server/services/account-service/model"
+	"go-web-server/services/account-service/money"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAccrueSavingsInterest_DefaultsToYesterday(t *testing.T) {
+	mockRepo := new(MockRepository)
+	rate := money.MustParseRate("0.05")
+	svc := NewSavingsService(mockRepo, rate, money.Thirty360, warsawCalendar).(*savingsService)
+	// Already 1 March in Warsaw.
+	svc.now = func() time.Time { return time.Date(2024, 2, 29, 23, 15, 0, 0, time.UTC) }
+	day := time.Date(2024, 2, 29, 0, 0, 0, 0, time.UTC)
+
+	accrued := &model.Account{ID: uuid.New(), Currency: money.PLN, Status: model.AccountActive, ProductCode: "plain", AccruedInterest: money.MustParseAmount("0.5055")}
+	skipped := &model.Account{ID: uuid.New(), Currency: money.PLN, Status: model.AccountActive, ProductCode: "plain"}
+	mockRepo.On("SavingsAccounts").Return([]uuid.UUID{accrued.ID, skipped.ID}, nil)
+	mockRepo.On("InTx", (*model.IdempotencyKey)(nil)).Return(nil)
+	mockRepo.On("LockAccount", accrued.ID.String()).Return(accrued, nil)
+	mockRepo.On("LockAccount", skipped.ID.String()).Return(skipped, nil)
+	mockRepo.On("InterestAccrued", accrued, day).Return(false, nil)
+	mockRepo.On("InterestAccrued", skipped, day).Return(true, nil)
+	expectProduct(mockRepo, plainProduct)
+	mockRepo.On("EndOfDayBalance", accrued, day).Return(money.MustParseAmount("3600"), nil)
+	// 30/360 counts the end of February as two days: 3600 * 0.05 * 2 / 360.
+	mockRepo.On("RecordAccrual", accrued, mock.MatchedBy(func(a *model.InterestAccrual) bool {
+		return a.Days == 2 && a.Amount == money.MustParseAmount("1")
+	}), rate, money.Thirty360).Return(nil).Run(func(args mock.Arguments) {
+		accrued.AccruedInterest, _ = accrued.AccruedInterest.Add(args.Get(1).(*model.InterestAccrual).Amount)
+	})
+	// The month ended, so the whole grosze accrued so far are capitalised.
+	mockRepo.On("PostInterest", accrued, model.Interest, money.New(money.MustParseAmount("1.50"), money.PLN), day, "Interest for 2024-02").
+		Return(&model.LedgerEntry{AccountID: accrued.ID, Type: model.Interest}, nil).Run(func(mock.Arguments) {
+		accrued.AccruedInterest = money.MustParseAmount("0.0055")
+	})
+
+	run, err := svc.AccrueInterest(context.Background(), time.Time{})
+	require.NoError(t, err)
+	assert.Equal(t, "2024-02-29", run.BusinessDate)
+	assert.Equal(t, money.Thirty360, run.DayCount)
+	require.Len(t, run.Accrued, 1)
+	assert.NotNil(t, run.Accrued[0].Capitalised)
+	assert.Equal(t, money.MustParseAmount("0.0055"), run.Accrued[0].Accrued)
+	assert.Equal(t, 1, run.Skipped)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestAccrueSavingsInterest_Rejected(t *testing.T) {
+	now := time.Date(2024, 3, 2, 12, 0, 0, 0, time.UTC)
+
+	svc := NewSavingsService(new(MockRepository), money.Rate{}, money.Act365, warsawCalendar)
+	_, err := svc.AccrueInterest(context.Background(), now.AddDate(0, 0, -1))
+	assert.ErrorIs(t, err, ErrSavingsInterestDisabled)
+
+	mockRepo := new(MockRepository)
+	enabled := NewSavingsService(mockRepo, money.MustParseRate("0.05"), money.Act365, warsawCalendar).(*savingsService)
+	enabled.now = func() time.Time { return now }
+	_, err = enabled.AccrueInterest(context.Background(), now)
+	assert.ErrorIs(t, err, ErrValidation)
+	mockRepo.AssertNotCalled(t, "SavingsAccounts")
+}
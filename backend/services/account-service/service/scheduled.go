@@ -0,0 +1,318 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"go-web-server/services/account-service/calendar"
+	"go-web-server/services/account-service/model"
+	"go-web-server/services/account-service/money"
+	"go-web-server/services/account-service/repository"
+
+	"github.com/google/uuid"
+)
+
+var (
+	// ErrScheduledTransferNotFound is returned when the requested scheduled
+	// transfer does not exist.
+	ErrScheduledTransferNotFound = errors.New("scheduled transfer not found")
+	// ErrScheduledTransferNotPending is returned, wrapped in a
+	// ScheduledTransferNotPendingError, when amending or cancelling a
+	// scheduled transfer that was executed, failed or cancelled. Handlers
+	// map it to 409.
+	ErrScheduledTransferNotPending = repository.ErrScheduledTransferNotPending
+)
+
+type ScheduledTransferNotPendingError = repository.ScheduledTransferNotPendingError
+
+const (
+	// MaxScheduledTransferAttempts is how many times a scheduled transfer
+	// is tried before it fails.
+	MaxScheduledTransferAttempts = 3
+	// ScheduledTransferRetryDelay is how long a scheduled transfer waits
+	// after a failed attempt that may succeed later.
+	ScheduledTransferRetryDelay = time.Hour
+	// scheduledTransferBatch bounds how many transfers one pass of the
+	// worker executes; the rest wait for the next pass.
+	scheduledTransferBatch = 100
+)
+
+// ScheduledTransferService schedules transfers for a future date and
+// executes them when it comes.
+type ScheduledTransferService interface {
+	// ScheduleTransfer sets a transfer for executeOn, a day such as
+	// "2024-03-01" no earlier than today and at most a year ahead. It is
+	// executed on the first business day from executeOn.
+	ScheduleTransfer(ctx context.Context, fromAccountID, toAccountID string, amount money.Money, description, executeOn string) (*model.ScheduledTransfer, error)
+	GetScheduledTransfer(ctx context.Context, id string) (*model.ScheduledTransfer, error)
+	ListScheduledTransfers(ctx context.Context, accountID string, status model.ScheduledTransferStatus) ([]model.ScheduledTransfer, error)
+	// AmendScheduledTransfer changes the amount, description or date of a
+	// pending transfer, which then starts over with no failed attempts.
+	AmendScheduledTransfer(ctx context.Context, id string, amendment model.ScheduledTransferAmendment) (*model.ScheduledTransfer, error)
+	CancelScheduledTransfer(ctx context.Context, id string) (*model.ScheduledTransfer, error)
+	// ExecuteDueTransfers executes the pending transfers whose date or
+	// retry has come.
+	ExecuteDueTransfers(ctx context.Context) (*model.ScheduledTransferRun, error)
+}
+
+type scheduledTransferService struct {
+	*accountService
+	calendar *calendar.Calendar
+	now      func() time.Time
+}
+
+// NewScheduledTransferService creates the service. Transfers set for a day
+// cal does not count as a business day wait for the next one.
+func NewScheduledTransferService(repo repository.AccountRepository, cal *calendar.Calendar) ScheduledTransferService {
+	return &scheduledTransferService{accountService: &accountService{repo: repo}, calendar: cal, now: time.Now}
+}
+
+func (s *scheduledTransferService) ScheduleTransfer(ctx context.Context, fromAccountID, toAccountID string, amount money.Money, description, executeOn string) (*model.ScheduledTransfer, error) {
+	if amount.Sign() <= 0 {
+		return nil, invalid("amount", "transfer amount must be positive")
+	}
+	day, err := s.executionDay(executeOn)
+	if err != nil {
+		return nil, err
+	}
+	toID, err := uuid.Parse(toAccountID)
+	if err != nil {
+		return nil, &ValidationError{Field: "toAccountId", Err: err}
+	}
+	if fromAccountID == toAccountID {
+		return nil, invalid("toAccountId", "cannot transfer to the same account")
+	}
+
+	idem := idempotencyKey(ctx, "schedule_transfer", fromAccountID, toAccountID, amount.Amount.String(), string(amount.Currency), description, executeOn)
+	var previous model.ScheduledTransfer
+	replayed, err := s.replay(idem, &previous)
+	if err != nil {
+		return nil, err
+	}
+	if replayed {
+		return &previous, nil
+	}
+
+	// The accounts are checked again on execution; checking them now
+	// rejects transfers that could never run.
+	from, err := s.GetAccount(ctx, fromAccountID)
+	if err != nil {
+		return nil, err
+	}
+	if err := requireActive(from); err != nil {
+		return nil, err
+	}
+	to, err := s.repo.GetAccount(toAccountID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get destination account: %w", err)
+	}
+	if to == nil {
+		return nil, ErrDestinationNotFound
+	}
+	if from.Currency != amount.Currency || to.Currency != amount.Currency {
+		return nil, fmt.Errorf("%w: transfer in %s between %s and %s accounts", ErrCurrencyMismatch, amount.Currency, from.Currency, to.Currency)
+	}
+
+	st, err := s.repo.CreateScheduledTransfer(&model.ScheduledTransfer{
+		ID:            uuid.New(),
+		FromAccountID: from.ID,
+		ToAccountID:   toID,
+		Amount:        amount.Amount,
+		Currency:      amount.Currency,
+		Description:   description,
+		ExecuteOn:     executeOn,
+		NextAttemptAt: day,
+	}, idem)
+	if err != nil {
+		return nil, wrapFailure(err, "schedule transfer")
+	}
+	return st, nil
+}
+
+// executionDay parses the date a transfer is scheduled for, checks that it
+// is between today and a year ahead and returns the business day it is
+// executed on.
+func (s *scheduledTransferService) executionDay(executeOn string) (time.Time, error) {
+	day, err := time.Parse(calendar.DateLayout, executeOn)
+	if err != nil {
+		return time.Time{}, invalid("executeOn", "execution date must be a date such as 2024-03-01")
+	}
+	today := s.calendar.Today(s.now())
+	if day.Before(today) {
+		return time.Time{}, invalid("executeOn", "execution date must not be in the past")
+	}
+	if day.After(today.AddDate(1, 0, 0)) {
+		return time.Time{}, invalid("executeOn", "a transfer may be scheduled at most a year ahead")
+	}
+	return s.calendar.Next(day), nil
+}
+
+func (s *scheduledTransferService) GetScheduledTransfer(ctx context.Context, id string) (*model.ScheduledTransfer, error) {
+	if _, err := uuid.Parse(id); err != nil {
+		return nil, &ValidationError{Field: "scheduledTransferId", Err: err}
+	}
+	st, err := s.repo.GetScheduledTransfer(id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get scheduled transfer: %w", err)
+	}
+	if st == nil {
+		return nil, ErrScheduledTransferNotFound
+	}
+	return st, nil
+}
+
+// ListScheduledTransfers returns the scheduled transfers from an account,
+// latest date first, at most MaxPageSize.
+func (s *scheduledTransferService) ListScheduledTransfers(ctx context.Context, accountID string, status model.ScheduledTransferStatus) ([]model.ScheduledTransfer, error) {
+	if status != "" && !status.Valid() {
+		return nil, invalid("status", "unknown scheduled transfer status %q", status)
+	}
+	if _, err := s.GetAccount(ctx, accountID); err != nil {
+		return nil, err
+	}
+	transfers, err := s.repo.ListScheduledTransfers(accountID, status, MaxPageSize)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list scheduled transfers: %w", err)
+	}
+	return transfers, nil
+}
+
+func (s *scheduledTransferService) AmendScheduledTransfer(ctx context.Context, id string, amendment model.ScheduledTransferAmendment) (*model.ScheduledTransfer, error) {
+	if amendment.Amount != nil && amendment.Amount.Sign() <= 0 {
+		return nil, invalid("amount", "transfer amount must be positive")
+	}
+	st, err := s.GetScheduledTransfer(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if st.Status != model.ScheduledPending {
+		return nil, &ScheduledTransferNotPendingError{ID: st.ID, Status: st.Status}
+	}
+
+	if amendment.Amount != nil {
+		if _, err := money.NewExact(*amendment.Amount, st.Currency); err != nil {
+			return nil, &ValidationError{Field: "amount", Err: err}
+		}
+		st.Amount = *amendment.Amount
+	}
+	if amendment.Description != nil {
+		st.Description = *amendment.Description
+	}
+	if amendment.ExecuteOn != nil {
+		st.ExecuteOn = *amendment.ExecuteOn
+	}
+	day, err := s.executionDay(st.ExecuteOn)
+	if err != nil {
+		return nil, err
+	}
+	st.NextAttemptAt = day
+
+	amended, err := s.repo.AmendScheduledTransfer(st)
+	if err != nil {
+		return nil, wrapFailure(err, "amend scheduled transfer")
+	}
+	return amended, nil
+}
+
+func (s *scheduledTransferService) CancelScheduledTransfer(ctx context.Context, id string) (*model.ScheduledTransfer, error) {
+	st, err := s.GetScheduledTransfer(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	cancelled, err := s.repo.CancelScheduledTransfer(st.ID.String())
+	if err != nil {
+		return nil, wrapFailure(err, "cancel scheduled transfer")
+	}
+	return cancelled, nil
+}
+
+// ExecuteDueTransfers stops at the first unexpected failure, such as a
+// database outage; the transfers not reached stay due for the next pass.
+func (s *scheduledTransferService) ExecuteDueTransfers(ctx context.Context) (*model.ScheduledTransferRun, error) {
+	ids, err := s.repo.DueScheduledTransfers(s.now(), scheduledTransferBatch)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list due scheduled transfers: %w", err)
+	}
+	run := &model.ScheduledTransferRun{
+		Executed: []model.ScheduledTransfer{},
+		Retrying: []model.ScheduledTransfer{},
+		Failed:   []model.ScheduledTransfer{},
+	}
+	for _, id := range ids {
+		st, err := s.execute(id)
+		if err != nil {
+			return nil, fmt.Errorf("failed to execute scheduled transfer %s: %w", id, err)
+		}
+		if st == nil {
+			continue
+		}
+		switch st.Status {
+		case model.ScheduledExecuted:
+			run.Executed = append(run.Executed, *st)
+		case model.ScheduledFailed:
+			run.Failed = append(run.Failed, *st)
+		default:
+			run.Retrying = append(run.Retrying, *st)
+		}
+	}
+	return run, nil
+}
+
+// execute tries a due scheduled transfer once. The transfer is booked
+// through the same rules as Transfer, in the transaction that marks the
+// scheduled transfer executed, so it cannot run twice. A rejected attempt
+// is recorded with a retry, or as failed once the rejection cannot clear up
+// or the attempts are used up. It returns nil when another pass executed,
+// amended or cancelled the transfer in the meantime.
+func (s *scheduledTransferService) execute(id uuid.UUID) (*model.ScheduledTransfer, error) {
+	now := s.now()
+	var st *model.ScheduledTransfer
+	err := s.repo.InTx(nil, nil, func(uow repository.UnitOfWork) error {
+		locked, err := uow.LockScheduledTransfer(id)
+		if err != nil {
+			return err
+		}
+		if locked.NextAttemptAt.After(now) {
+			return nil
+		}
+		st = locked
+		transfer, err := bookTransfer(uow, st.FromAccountID, st.ToAccountID, money.New(st.Amount, st.Currency), st.Description)
+		if err != nil {
+			return err
+		}
+		return uow.CompleteScheduledTransfer(st, transfer.ReferenceID)
+	})
+	if errors.Is(err, ErrScheduledTransferNotPending) {
+		return nil, nil
+	}
+	if err == nil || st == nil || !IsDomainError(err) {
+		return st, err
+	}
+
+	st.Attempts++
+	st.LastError = err.Error()
+	if retryable(err) && st.Attempts < MaxScheduledTransferAttempts {
+		st.NextAttemptAt = now.Add(ScheduledTransferRetryDelay)
+	} else {
+		st.Status = model.ScheduledFailed
+	}
+	return s.repo.RecordScheduledTransferFailure(st)
+}
+
+// retryable reports whether a rejected transfer may succeed later: the
+// source account may receive funds, be unfrozen or get below its limits
+// again. Other rejections, such as a closed account, are final.
+func retryable(err error) bool {
+	var notActive *AccountNotActiveError
+	if errors.As(err, &notActive) {
+		return notActive.Status == model.AccountFrozen
+	}
+	for _, target := range []error{ErrInsufficientFunds, ErrDailyLimitExceeded, ErrMonthlyLimitExceeded, ErrVelocityLimitExceeded} {
+		if errors.Is(err, target) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,191 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"go-web-server/services/account-service/model"
+	"go-web-server/services/account-service/money"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestScheduleTransfer(t *testing.T) {
+	mockRepo := new(MockRepository)
+	svc := NewScheduledTransferService(mockRepo, warsawCalendar).(*scheduledTransferService)
+	svc.now = func() time.Time { return time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC) }
+
+	from := &model.Account{ID: uuid.New(), Currency: money.PLN, Status: model.AccountActive}
+	to := &model.Account{ID: uuid.New(), Currency: money.PLN, Status: model.AccountActive}
+	mockRepo.On("GetAccount", from.ID.String()).Return(from, nil)
+	mockRepo.On("GetAccount", to.ID.String()).Return(to, nil)
+	// 10 March 2024 is a Sunday, so the transfer waits for Monday.
+	mockRepo.On("CreateScheduledTransfer", mock.MatchedBy(func(st *model.ScheduledTransfer) bool {
+		return st.FromAccountID == from.ID && st.ToAccountID == to.ID && st.ExecuteOn == "2024-03-10" &&
+			st.NextAttemptAt.Equal(time.Date(2024, 3, 11, 0, 0, 0, 0, time.UTC))
+	}), (*model.IdempotencyKey)(nil)).Return(&model.ScheduledTransfer{Status: model.ScheduledPending}, nil)
+
+	st, err := svc.ScheduleTransfer(context.Background(), from.ID.String(), to.ID.String(), money.New(money.MustParseAmount("120"), money.PLN), "Rent", "2024-03-10")
+	require.NoError(t, err)
+	assert.Equal(t, model.ScheduledPending, st.Status)
+
+	pln := money.New(money.MustParseAmount("120"), money.PLN)
+	for _, tc := range []struct {
+		name      string
+		to        string
+		amount    money.Money
+		executeOn string
+		field     string
+	}{
+		{"date in the past", to.ID.String(), pln, "2024-02-29", "executeOn"},
+		{"more than a year ahead", to.ID.String(), pln, "2025-03-02", "executeOn"},
+		{"not a date", to.ID.String(), pln, "next friday", "executeOn"},
+		{"no amount", to.ID.String(), money.New(money.Amount{}, money.PLN), "2024-03-10", "amount"},
+		{"same account", from.ID.String(), pln, "2024-03-10", "toAccountId"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := svc.ScheduleTransfer(context.Background(), from.ID.String(), tc.to, tc.amount, "", tc.executeOn)
+			var vErr *ValidationError
+			require.ErrorAs(t, err, &vErr)
+			assert.Equal(t, tc.field, vErr.Field)
+		})
+	}
+
+	_, err = svc.ScheduleTransfer(context.Background(), from.ID.String(), to.ID.String(), money.New(money.MustParseAmount("120"), money.EUR), "", "2024-03-10")
+	assert.ErrorIs(t, err, ErrCurrencyMismatch)
+	mockRepo.AssertNumberOfCalls(t, "CreateScheduledTransfer", 1)
+}
+
+func TestExecuteDueTransfers(t *testing.T) {
+	mockRepo := new(MockRepository)
+	svc := NewScheduledTransferService(mockRepo, warsawCalendar).(*scheduledTransferService)
+	now := time.Date(2024, 3, 10, 6, 0, 0, 0, time.UTC)
+	svc.now = func() time.Time { return now }
+	mockRepo.On("InTx", (*model.IdempotencyKey)(nil)).Return(nil)
+	expectProduct(mockRepo, plainProduct)
+
+	scheduled := func(from, to *model.Account, attempts int) *model.ScheduledTransfer {
+		return &model.ScheduledTransfer{
+			ID: uuid.New(), FromAccountID: from.ID, ToAccountID: to.ID, Amount: money.MustParseAmount("100"), Currency: money.PLN,
+			Description: "Rent", ExecuteOn: "2024-03-10", Status: model.ScheduledPending, Attempts: attempts,
+			NextAttemptAt: time.Date(2024, 3, 10, 0, 0, 0, 0, time.UTC),
+		}
+	}
+	lock := func(st *model.ScheduledTransfer, from, to *model.Account) {
+		mockRepo.On("LockScheduledTransfer", st.ID).Return(st, nil)
+		mockRepo.On("LockAccounts", []uuid.UUID{from.ID, to.ID}).Return(map[uuid.UUID]*model.Account{from.ID: from, to.ID: to}, nil)
+	}
+	landlord := &model.Account{ID: uuid.New(), Currency: money.PLN, Status: model.AccountActive}
+
+	// Executed through the transfer rules.
+	funded := &model.Account{ID: uuid.New(), Currency: money.PLN, Status: model.AccountActive, Balance: money.MustParseAmount("500")}
+	executed := scheduled(funded, landlord, 0)
+	lock(executed, funded, landlord)
+	ref := uuid.New()
+	mockRepo.On("BookTransfer", funded, landlord, money.New(executed.Amount, money.PLN), "Rent").Return(&model.Transfer{ReferenceID: ref}, nil)
+	mockRepo.On("CompleteScheduledTransfer", executed, ref).Return(nil).Run(func(args mock.Arguments) {
+		args.Get(0).(*model.ScheduledTransfer).Status = model.ScheduledExecuted
+	})
+
+	// Short of funds: tried again in an hour.
+	empty := &model.Account{ID: uuid.New(), Currency: money.PLN, Status: model.AccountActive}
+	retrying := scheduled(empty, landlord, 0)
+	lock(retrying, empty, landlord)
+	mockRepo.On("RecordScheduledTransferFailure", mock.MatchedBy(func(st *model.ScheduledTransfer) bool {
+		return st.ID == retrying.ID && st.Status == model.ScheduledPending && st.Attempts == 1 &&
+			st.NextAttemptAt.Equal(now.Add(ScheduledTransferRetryDelay)) && st.LastError != ""
+	})).Return(retrying, nil)
+
+	// Short of funds on the last attempt.
+	exhausted := scheduled(empty, landlord, MaxScheduledTransferAttempts-1)
+	mockRepo.On("LockScheduledTransfer", exhausted.ID).Return(exhausted, nil)
+	mockRepo.On("RecordScheduledTransferFailure", mock.MatchedBy(func(st *model.ScheduledTransfer) bool {
+		return st.ID == exhausted.ID && st.Status == model.ScheduledFailed && st.Attempts == MaxScheduledTransferAttempts
+	})).Return(exhausted, nil)
+
+	// A closed destination never clears up.
+	closed := &model.Account{ID: uuid.New(), Currency: money.PLN, Status: model.AccountClosed}
+	rejected := scheduled(funded, closed, 0)
+	lock(rejected, funded, closed)
+	mockRepo.On("RecordScheduledTransferFailure", mock.MatchedBy(func(st *model.ScheduledTransfer) bool {
+		return st.ID == rejected.ID && st.Status == model.ScheduledFailed && st.Attempts == 1
+	})).Return(rejected, nil)
+
+	// Cancelled after it was listed.
+	cancelled := uuid.New()
+	mockRepo.On("LockScheduledTransfer", cancelled).Return(nil, &ScheduledTransferNotPendingError{ID: cancelled, Status: model.ScheduledCancelled})
+
+	mockRepo.On("DueScheduledTransfers", now, scheduledTransferBatch).
+		Return([]uuid.UUID{executed.ID, retrying.ID, exhausted.ID, rejected.ID, cancelled}, nil)
+
+	run, err := svc.ExecuteDueTransfers(context.Background())
+	require.NoError(t, err)
+	assert.Len(t, run.Executed, 1)
+	assert.Len(t, run.Retrying, 1)
+	assert.Len(t, run.Failed, 2)
+	mockRepo.AssertNumberOfCalls(t, "BookTransfer", 1)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestExecuteDueTransfers_SkipsTransferRetriedMeanwhile(t *testing.T) {
+	mockRepo := new(MockRepository)
+	svc := NewScheduledTransferService(mockRepo, warsawCalendar).(*scheduledTransferService)
+	now := time.Date(2024, 3, 10, 6, 0, 0, 0, time.UTC)
+	svc.now = func() time.Time { return now }
+
+	st := &model.ScheduledTransfer{ID: uuid.New(), Status: model.ScheduledPending, Attempts: 1, NextAttemptAt: now.Add(time.Hour)}
+	mockRepo.On("DueScheduledTransfers", now, scheduledTransferBatch).Return([]uuid.UUID{st.ID}, nil)
+	mockRepo.On("InTx", (*model.IdempotencyKey)(nil)).Return(nil)
+	mockRepo.On("LockScheduledTransfer", st.ID).Return(st, nil)
+
+	run, err := svc.ExecuteDueTransfers(context.Background())
+	require.NoError(t, err)
+	assert.Empty(t, run.Executed)
+	assert.Empty(t, run.Retrying)
+	mockRepo.AssertNotCalled(t, "LockAccounts", mock.Anything)
+}
+
+func TestAmendScheduledTransfer(t *testing.T) {
+	mockRepo := new(MockRepository)
+	svc := NewScheduledTransferService(mockRepo, warsawCalendar).(*scheduledTransferService)
+	svc.now = func() time.Time { return time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC) }
+
+	pending := &model.ScheduledTransfer{
+		ID: uuid.New(), Amount: money.MustParseAmount("100"), Description: "Rent", ExecuteOn: "2024-03-10",
+		Status: model.ScheduledPending, Attempts: 1, LastError: "insufficient funds",
+	}
+	mockRepo.On("GetScheduledTransfer", pending.ID.String()).Return(pending, nil)
+	mockRepo.On("AmendScheduledTransfer", mock.MatchedBy(func(st *model.ScheduledTransfer) bool {
+		return st.Amount == money.MustParseAmount("80") && st.Description == "Rent" && st.ExecuteOn == "2024-03-12" &&
+			st.NextAttemptAt.Equal(time.Date(2024, 3, 12, 0, 0, 0, 0, time.UTC))
+	})).Return(pending, nil)
+
+	amount, executeOn := money.MustParseAmount("80"), "2024-03-12"
+	_, err := svc.AmendScheduledTransfer(context.Background(), pending.ID.String(), model.ScheduledTransferAmendment{Amount: &amount, ExecuteOn: &executeOn})
+	require.NoError(t, err)
+
+	executed := &model.ScheduledTransfer{ID: uuid.New(), Status: model.ScheduledExecuted}
+	mockRepo.On("GetScheduledTransfer", executed.ID.String()).Return(executed, nil)
+	_, err = svc.AmendScheduledTransfer(context.Background(), executed.ID.String(), model.ScheduledTransferAmendment{Amount: &amount})
+	assert.ErrorIs(t, err, ErrScheduledTransferNotPending)
+	assert.True(t, IsDomainError(err))
+
+	_, err = svc.AmendScheduledTransfer(context.Background(), "not-a-uuid", model.ScheduledTransferAmendment{})
+	assert.ErrorIs(t, err, ErrValidation)
+	mockRepo.AssertNumberOfCalls(t, "AmendScheduledTransfer", 1)
+}
+
+func TestCancelScheduledTransfer_NotFound(t *testing.T) {
+	mockRepo := new(MockRepository)
+	svc := NewScheduledTransferService(mockRepo, warsawCalendar)
+	id := uuid.New()
+	mockRepo.On("GetScheduledTransfer", id.String()).Return(nil, nil)
+
+	_, err := svc.CancelScheduledTransfer(context.Background(), id.String())
+	assert.ErrorIs(t, err, ErrScheduledTransferNotFound)
+	mockRepo.AssertNotCalled(t, "CancelScheduledTransfer", mock.Anything)
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"go-web-server/services/account-service/model"
+	"go-web-server/services/account-service/money"
 	"go-web-server/services/account-service/repository"
 	"math/rand"
 	"time"
@@ -12,11 +13,26 @@ import (
 )
 
 type AccountService interface {
-	CreateAccount(ctx context.Context, customerID string, currency string) (*model.Account, error)
+	// CreateAccount opens an account of the product productCode, which must
+	// be active and offered in currency.
+	CreateAccount(ctx context.Context, customerID string, currency string, productCode string) (*model.Account, error)
 	GetAccount(ctx context.Context, accountID string) (*model.Account, error)
-	UpdateBalance(ctx context.Context, accountID string, amount float64, entryType model.LedgerEntryType, description string) error
+	ListAccounts(ctx context.Context, filter model.AccountFilter) (*model.AccountPage, error)
+	GetCustomerByExternalID(ctx context.Context, externalID string) (*model.Customer, error)
+	GetPrimaryAccount(ctx context.Context, customerID uuid.UUID) (*model.Account, error)
+	ListLedgerEntries(ctx context.Context, filter model.LedgerFilter) (*model.LedgerPage, error)
+	UpdateBalance(ctx context.Context, accountID string, amount money.Money, entryType model.LedgerEntryType, description string) (*model.LedgerEntry, error)
+	Transfer(ctx context.Context, fromAccountID, toAccountID string, amount money.Money, description string) (*model.Transfer, error)
+	FreezeAccount(ctx context.Context, accountID string, reason model.StatusReason, note string) (*model.Account, error)
+	UnfreezeAccount(ctx context.Context, accountID string, reason model.StatusReason, note string) (*model.Account, error)
+	CloseAccount(ctx context.Context, accountID string, reason model.StatusReason, note string, payoutAccountID string) (*model.Account, error)
 }
 
+const (
+	DefaultPageSize = 50
+	MaxPageSize     = 100
+)
+
 type accountService struct {
 	repo repository.AccountRepository
 }
@@ -25,18 +41,42 @@ func NewAccountService(repo repository.AccountRepository) AccountService {
 	return &accountService{repo: repo}
 }
 
-func (s *accountService) CreateAccount(ctx context.Context, customerID string, currency string) (*model.Account, error) {
+func (s *accountService) CreateAccount(ctx context.Context, customerID string, currency string, productCode string) (*model.Account, error) {
 	custUUID, err := uuid.Parse(customerID)
 	if err != nil {
-		return nil, fmt.Errorf("invalid customer id: %w", err)
+		return nil, &ValidationError{Field: "customerId", Err: err}
+	}
+
+	cur, err := money.ParseCurrency(currency)
+	if err != nil {
+		return nil, &ValidationError{Field: "currency", Err: err}
+	}
+
+	if productCode == "" {
+		return nil, invalid("productCode", "product code is required")
+	}
+	product, err := s.repo.GetProduct(productCode)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get product: %w", err)
+	}
+	if product == nil {
+		return nil, invalid("productCode", "unknown product %q", productCode)
+	}
+	if !product.Active {
+		return nil, invalid("productCode", "product %s is no longer offered", productCode)
+	}
+	if !product.Offers(cur) {
+		return nil, invalid("currency", "product %s is not offered in %s", productCode, cur)
 	}
 
 	acc := &model.Account{
 		ID:            uuid.New(),
 		CustomerID:    custUUID,
 		AccountNumber: generateAccountNumber(),
-		Currency:      currency,
-		Balance:       0.0,
+		Currency:      cur,
+		ProductCode:   product.Code,
+		Kind:          product.Kind,
+		Balance:       money.Amount{},
 		Status:        model.AccountActive,
 		CreatedAt:     time.Now(),
 		UpdatedAt:     time.Now(),
@@ -55,29 +95,386 @@ func (s *accountService) GetAccount(ctx context.Context, accountID string) (*mod
 		return nil, fmt.Errorf("failed to get account: %w", err)
 	}
 	if acc == nil {
-		return nil, fmt.Errorf("account not found")
+		return nil, ErrAccountNotFound
 	}
 	return acc, nil
 }
 
-func (s *accountService) UpdateBalance(ctx context.Context, accountID string, amount float64, entryType model.LedgerEntryType, description string) error {
-	acc, err := s.repo.GetAccount(accountID)
+func (s *accountService) ListAccounts(ctx context.Context, filter model.AccountFilter) (*model.AccountPage, error) {
+	if filter.CustomerID == uuid.Nil {
+		return nil, invalid("customerId", "customer id is required")
+	}
+	if filter.Status != "" && !filter.Status.Valid() {
+		return nil, invalid("status", "unknown status %q", filter.Status)
+	}
+	if filter.Limit <= 0 {
+		filter.Limit = DefaultPageSize
+	}
+	if filter.Limit > MaxPageSize {
+		filter.Limit = MaxPageSize
+	}
+
+	// Fetch one extra row to learn whether another page exists
+	pageSize := filter.Limit
+	filter.Limit++
+	accounts, err := s.repo.ListAccounts(filter)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list accounts: %w", err)
+	}
+
+	page := &model.AccountPage{Items: accounts}
+	if len(accounts) > pageSize {
+		page.Items = accounts[:pageSize]
+		last := page.Items[pageSize-1]
+		page.NextCursor = model.Cursor{CreatedAt: last.CreatedAt, ID: last.ID}.Encode()
+	}
+	return page, nil
+}
+
+func (s *accountService) GetCustomerByExternalID(ctx context.Context, externalID string) (*model.Customer, error) {
+	if externalID == "" {
+		return nil, invalid("externalId", "external id is required")
+	}
+	c, err := s.repo.GetCustomerByExternalID(externalID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get customer: %w", err)
+	}
+	if c == nil {
+		return nil, ErrCustomerNotFound
+	}
+	return c, nil
+}
+
+// GetPrimaryAccount returns the account used when a customer does not name
+// one explicitly (e.g. the legacy /api/account endpoints). The primary
+// account is the customer's oldest active account; frozen and closed
+// accounts are never chosen, and ties on the opening time go to the lowest id.
+func (s *accountService) GetPrimaryAccount(ctx context.Context, customerID uuid.UUID) (*model.Account, error) {
+	accounts, err := s.repo.ListAccounts(model.AccountFilter{
+		CustomerID: customerID,
+		Status:     model.AccountActive,
+		Limit:      1,
+	})
 	if err != nil {
-		return fmt.Errorf("failed to get account: %w", err)
+		return nil, fmt.Errorf("failed to list accounts: %w", err)
+	}
+	if len(accounts) == 0 {
+		return nil, fmt.Errorf("%w: customer %s has no active account", ErrAccountNotFound, customerID)
+	}
+	return &accounts[0], nil
+}
+
+func (s *accountService) ListLedgerEntries(ctx context.Context, filter model.LedgerFilter) (*model.LedgerPage, error) {
+	for _, t := range filter.Types {
+		if !t.Valid() {
+			return nil, invalid("type", "unknown entry type %q", t)
+		}
+	}
+	if filter.From != nil && filter.To != nil && !filter.From.Before(*filter.To) {
+		return nil, invalid("from", "from must be before to")
+	}
+	if filter.MinAmount != nil && filter.MaxAmount != nil && filter.MinAmount.Cmp(*filter.MaxAmount) > 0 {
+		return nil, invalid("minAmount", "minAmount must not exceed maxAmount")
+	}
+	if filter.Limit <= 0 {
+		filter.Limit = DefaultPageSize
+	}
+	if filter.Limit > MaxPageSize {
+		filter.Limit = MaxPageSize
+	}
+
+	acc, err := s.repo.GetAccount(filter.AccountID.String())
+	if err != nil {
+		return nil, fmt.Errorf("failed to get account: %w", err)
 	}
 	if acc == nil {
-		return fmt.Errorf("account not found")
+		return nil, ErrAccountNotFound
+	}
+
+	pageSize := filter.Limit
+	filter.Limit++
+	entries, err := s.repo.ListLedgerEntries(filter)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list ledger entries: %w", err)
+	}
+
+	page := &model.LedgerPage{Items: entries}
+	if len(entries) > pageSize {
+		page.Items = entries[:pageSize]
+		last := page.Items[pageSize-1]
+		page.NextCursor = model.Cursor{CreatedAt: last.CreatedAt, ID: last.ID}.Encode()
+	}
+	return page, nil
+}
+
+func (s *accountService) UpdateBalance(ctx context.Context, accountID string, amount money.Money, entryType model.LedgerEntryType, description string) (*model.LedgerEntry, error) {
+	// Business Rule: A balance change is a deposit, which credits the
+	// account, or a withdrawal, which debits it. Other entry types are only
+	// booked by the operations they belong to
+	switch entryType {
+	case model.Deposit:
+		if amount.Sign() <= 0 {
+			return nil, invalid("amount", "a deposit must be positive")
+		}
+	case model.Withdrawal:
+		if !amount.IsNegative() {
+			return nil, invalid("amount", "a withdrawal must be negative")
+		}
+	default:
+		return nil, invalid("type", "entry type must be %s or %s, got %q", model.Deposit, model.Withdrawal, entryType)
+	}
+
+	idem := idempotencyKey(ctx, "update_balance", accountID, amount.Amount.String(), string(amount.Currency), string(entryType), description)
+	var previous model.LedgerEntry
+	replayed, err := s.replay(idem, &previous)
+	if err != nil {
+		return nil, err
+	}
+	if replayed {
+		return &previous, nil
+	}
+
+	// Business rules run against the locked account, so that concurrent
+	// debits cannot both pass the funds check
+	var entry *model.LedgerEntry
+	err = s.repo.InTx(idem, &entry, func(uow repository.UnitOfWork) error {
+		acc, err := uow.LockAccount(accountID)
+		if err != nil {
+			return err
+		}
+		if acc == nil {
+			return ErrAccountNotFound
+		}
+		if err := requireActive(acc); err != nil {
+			return err
+		}
+
+		// Business Rule: Balance changes never convert currencies implicitly
+		if acc.Currency != amount.Currency {
+			return fmt.Errorf("%w: %s amount on a %s account", ErrCurrencyMismatch, amount.Currency, acc.Currency)
+		}
+
+		product, err := lockedProduct(uow, acc)
+		if err != nil {
+			return err
+		}
+
+		// Business Rule: Withdrawals stay within the account's transaction
+		// limits. With the product's withdrawal fee they may not touch
+		// funds reserved by holds or go beyond the overdraft limit
+		fee := money.New(money.Amount{}, acc.Currency)
+		if amount.IsNegative() {
+			if err := uow.ConsumeLimits(acc, product, amount.Neg()); err != nil {
+				return err
+			}
+			if fee, err = operationFee(uow, product, model.FeeWithdrawal, amount.Neg()); err != nil {
+				return err
+			}
+			debit, err := amount.Neg().Add(fee)
+			if err != nil {
+				return err
+			}
+			if err := requireFunds(acc, debit); err != nil {
+				return err
+			}
+		} else {
+			// Business Rule: Credits may not take the balance above the
+			// product's maximum
+			after, err := acc.BalanceMoney().Add(amount)
+			if err != nil {
+				return err
+			}
+			if err := checkCredit(product, after); err != nil {
+				return err
+			}
+		}
+
+		entry, err = uow.PostEntry(acc, amount, entryType, description)
+		if err != nil || fee.IsZero() {
+			return err
+		}
+		_, err = uow.PostFee(acc, fee, entry, "Withdrawal fee")
+		return err
+	})
+	if err != nil {
+		return nil, wrapFailure(err, "update balance in repository")
+	}
+
+	return entry, nil
+}
+
+func (s *accountService) Transfer(ctx context.Context, fromAccountID, toAccountID string, amount money.Money, description string) (*model.Transfer, error) {
+	if amount.Sign() <= 0 {
+		return nil, invalid("amount", "transfer amount must be positive")
+	}
+	fromID, err := uuid.Parse(fromAccountID)
+	if err != nil {
+		return nil, &ValidationError{Field: "accountId", Err: err}
+	}
+	toID, err := uuid.Parse(toAccountID)
+	if err != nil {
+		return nil, &ValidationError{Field: "toAccountId", Err: err}
+	}
+	if fromAccountID == toAccountID {
+		return nil, invalid("toAccountId", "cannot transfer to the same account")
+	}
+
+	idem := idempotencyKey(ctx, "transfer", fromAccountID, toAccountID, amount.Amount.String(), string(amount.Currency), description)
+	var previous model.Transfer
+	replayed, err := s.replay(idem, &previous)
+	if err != nil {
+		return nil, err
+	}
+	if replayed {
+		return &previous, nil
+	}
+
+	var transfer *model.Transfer
+	err = s.repo.InTx(idem, &transfer, func(uow repository.UnitOfWork) error {
+		transfer, err = bookTransfer(uow, fromID, toID, amount, description)
+		return err
+	})
+	if err != nil {
+		return nil, wrapFailure(err, "execute transfer in repository")
+	}
+
+	return transfer, nil
+}
+
+// bookTransfer applies the business rules of a transfer between two
+// accounts in uow and books it with its fee.
+func bookTransfer(uow repository.UnitOfWork, fromID, toID uuid.UUID, amount money.Money, description string) (*model.Transfer, error) {
+	locked, err := uow.LockAccounts(fromID, toID)
+	if err != nil {
+		return nil, err
+	}
+	from, to := locked[fromID], locked[toID]
+	if from == nil {
+		return nil, ErrAccountNotFound
+	}
+	if to == nil {
+		return nil, ErrDestinationNotFound
+	}
+	for _, acc := range []*model.Account{from, to} {
+		if err := requireActive(acc); err != nil {
+			return nil, err
+		}
 	}
 
-	// Business Rule: Ensure sufficient funds for withdrawals
-	if amount < 0 && acc.Balance+amount < 0 {
-		return fmt.Errorf("insufficient funds: current balance %.2f, requested withdrawal %.2f", acc.Balance, -amount)
+	// Business Rule: Transfers never convert currencies implicitly
+	if from.Currency != amount.Currency || to.Currency != amount.Currency {
+		return nil, fmt.Errorf("%w: transfer in %s between %s and %s accounts", ErrCurrencyMismatch, amount.Currency, from.Currency, to.Currency)
 	}
 
-	if err := s.repo.UpdateBalance(accountID, amount, entryType, description); err != nil {
-		return fmt.Errorf("failed to update balance in repository: %w", err)
+	// Business Rule: The transfer stays within the source account's
+	// transaction limits and the destination product's maximum balance
+	fromProduct, err := lockedProduct(uow, from)
+	if err != nil {
+		return nil, err
+	}
+	if err := uow.ConsumeLimits(from, fromProduct, amount); err != nil {
+		return nil, err
+	}
+	toProduct, err := lockedProduct(uow, to)
+	if err != nil {
+		return nil, err
+	}
+	toAfter, err := to.BalanceMoney().Add(amount)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkCredit(toProduct, toAfter); err != nil {
+		return nil, err
 	}
 
+	// Business Rule: Ensure sufficient available funds on the source
+	// account for the transfer and its fee
+	fee, err := operationFee(uow, fromProduct, model.FeeTransfer, amount)
+	if err != nil {
+		return nil, err
+	}
+	debit, err := amount.Add(fee)
+	if err != nil {
+		return nil, err
+	}
+	if err := requireFunds(from, debit); err != nil {
+		return nil, err
+	}
+
+	transfer, err := uow.BookTransfer(from, to, amount, description)
+	if err != nil || fee.IsZero() {
+		return transfer, err
+	}
+	transfer.Fee, err = uow.PostFee(from, fee, &transfer.Debit, "Transfer fee")
+	return transfer, err
+}
+
+// requireActive rejects balance changes on frozen and closed accounts.
+func requireActive(acc *model.Account) error {
+	if acc.Status != model.AccountActive {
+		return &AccountNotActiveError{AccountID: acc.ID, Status: acc.Status}
+	}
+	return nil
+}
+
+// lockedProduct reads the product of an account locked in uow.
+func lockedProduct(uow repository.UnitOfWork, acc *model.Account) (*model.Product, error) {
+	product, err := uow.Product(acc.ProductCode)
+	if err != nil {
+		return nil, err
+	}
+	if product == nil {
+		return nil, fmt.Errorf("account %s has unknown product %q", acc.ID, acc.ProductCode)
+	}
+	return product, nil
+}
+
+// operationFee prices op on amount under the fee schedule of product; the
+// fee is zero when the product does not charge for op.
+func operationFee(uow repository.UnitOfWork, product *model.Product, op model.FeeOperation, amount money.Money) (money.Money, error) {
+	schedule, err := uow.FeeSchedule(product.Code, op)
+	if err != nil || schedule == nil {
+		return money.New(money.Amount{}, amount.Currency), err
+	}
+	return schedule.Fee(amount)
+}
+
+// requireFunds rejects a debit of amount that would take the available
+// balance of acc below minus its overdraft limit.
+func requireFunds(acc *model.Account, amount money.Money) error {
+	headroom, err := acc.Headroom()
+	if err != nil {
+		return err
+	}
+	remaining, err := headroom.Sub(amount)
+	if err != nil {
+		return err
+	}
+	if remaining.IsNegative() {
+		available, err := acc.AvailableMoney()
+		if err != nil {
+			return err
+		}
+		return &InsufficientFundsError{Balance: available, Requested: amount, OverdraftLimit: acc.OverdraftMoney()}
+	}
+	return nil
+}
+
+// checkDebit rejects a single debit of amount above the withdrawal limit of
+// product p.
+func checkDebit(p *model.Product, amount money.Money) error {
+	if !p.MaxWithdrawal.IsZero() && amount.Amount.Cmp(p.MaxWithdrawal) > 0 {
+		return &ProductLimitError{Limit: ErrWithdrawalLimitExceeded, Product: p.Code, Max: money.New(p.MaxWithdrawal, amount.Currency), Requested: amount}
+	}
+	return nil
+}
+
+// checkCredit rejects a credit that leaves balance above the maximum
+// balance of product p.
+func checkCredit(p *model.Product, balance money.Money) error {
+	if !p.MaxBalance.IsZero() && balance.Amount.Cmp(p.MaxBalance) > 0 {
+		return &ProductLimitError{Limit: ErrMaxBalanceExceeded, Product: p.Code, Max: money.New(p.MaxBalance, balance.Currency), Requested: balance}
+	}
 	return nil
 }
 
@@ -2,9 +2,14 @@ package service
 
 import (
 	"context"
+	"errors"
 	"testing"
+	"time"
 
+	"go-web-server/services/account-service/calendar"
 	"go-web-server/services/account-service/model"
+	"go-web-server/services/account-service/money"
+	"go-web-server/services/account-service/repository"
 
 	"github.com/google/uuid"
 	"github.com/stretchr/testify/assert"
@@ -29,105 +34,1324 @@ func (m *MockRepository) GetAccount(id string) (*model.Account, error) {
 	return args.Get(0).(*model.Account), args.Error(1)
 }
 
-func (m *MockRepository) UpdateBalance(accountID string, amount float64, entryType model.LedgerEntryType, description string) error {
-	args := m.Called(accountID, amount, entryType, description)
+func (m *MockRepository) ListAccounts(filter model.AccountFilter) ([]model.Account, error) {
+	args := m.Called(filter)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]model.Account), args.Error(1)
+}
+
+func (m *MockRepository) GetCustomerByExternalID(externalID string) (*model.Customer, error) {
+	args := m.Called(externalID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.Customer), args.Error(1)
+}
+
+func (m *MockRepository) ListLedgerEntries(filter model.LedgerFilter) ([]model.LedgerEntry, error) {
+	args := m.Called(filter)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]model.LedgerEntry), args.Error(1)
+}
+
+// InTx records the idempotency key and runs fn with the mock itself as the
+// unit of work.
+func (m *MockRepository) InTx(idem *model.IdempotencyKey, out interface{}, fn func(repository.UnitOfWork) error) error {
+	args := m.Called(idem)
+	if err := args.Error(0); err != nil {
+		return err
+	}
+	return fn(m)
+}
+
+func (m *MockRepository) LockAccount(id string) (*model.Account, error) {
+	args := m.Called(id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.Account), args.Error(1)
+}
+
+func (m *MockRepository) LockAccounts(ids ...uuid.UUID) (map[uuid.UUID]*model.Account, error) {
+	args := m.Called(ids)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(map[uuid.UUID]*model.Account), args.Error(1)
+}
+
+func (m *MockRepository) PostEntry(acc *model.Account, amount money.Money, entryType model.LedgerEntryType, description string) (*model.LedgerEntry, error) {
+	args := m.Called(acc, amount, entryType, description)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.LedgerEntry), args.Error(1)
+}
+
+func (m *MockRepository) GetIdempotencyRecord(owner, key string) (*model.IdempotencyRecord, error) {
+	args := m.Called(owner, key)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.IdempotencyRecord), args.Error(1)
+}
+
+func (m *MockRepository) ChangeStatus(acc *model.Account, change model.StatusChange, payoutRef *uuid.UUID) (*model.AccountStatusChange, error) {
+	args := m.Called(acc, change, payoutRef)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.AccountStatusChange), args.Error(1)
+}
+
+func (m *MockRepository) BookTransfer(from, to *model.Account, amount money.Money, description string) (*model.Transfer, error) {
+	args := m.Called(from, to, amount, description)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.Transfer), args.Error(1)
+}
+
+func (m *MockRepository) GetFXRate(base, quote money.Currency) (*model.FXRate, error) {
+	args := m.Called(base, quote)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.FXRate), args.Error(1)
+}
+
+func (m *MockRepository) ListFXRates() ([]model.FXRate, error) {
+	args := m.Called()
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]model.FXRate), args.Error(1)
+}
+
+func (m *MockRepository) SetFXRate(rate model.FXRate) (*model.FXRate, error) {
+	args := m.Called(rate)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.FXRate), args.Error(1)
+}
+
+func (m *MockRepository) CreateFXQuote(q *model.FXQuote) error {
+	args := m.Called(q)
+	return args.Error(0)
+}
+
+func (m *MockRepository) GetFXQuote(id string) (*model.FXQuote, error) {
+	args := m.Called(id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.FXQuote), args.Error(1)
+}
+
+func (m *MockRepository) LockFXQuote(id uuid.UUID) (*model.FXQuote, error) {
+	args := m.Called(id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.FXQuote), args.Error(1)
+}
+
+func (m *MockRepository) BookExchange(from, to *model.Account, q *model.FXQuote) (*model.CurrencyExchange, error) {
+	args := m.Called(from, to, q)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.CurrencyExchange), args.Error(1)
+}
+
+func (m *MockRepository) ListGLAccounts() ([]model.GLAccount, error) {
+	args := m.Called()
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]model.GLAccount), args.Error(1)
+}
+
+func (m *MockRepository) TrialBalance(asOf *time.Time) ([]model.TrialBalanceLine, error) {
+	args := m.Called(asOf)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]model.TrialBalanceLine), args.Error(1)
+}
+
+func (m *MockRepository) LedgerSnapshot() (*model.LedgerSnapshot, error) {
+	args := m.Called()
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.LedgerSnapshot), args.Error(1)
+}
+
+func (m *MockRepository) SaveReconciliationReport(report *model.ReconciliationReport) error {
+	args := m.Called(report)
+	return args.Error(0)
+}
+
+func (m *MockRepository) GetReconciliationReport(id string) (*model.ReconciliationReport, error) {
+	args := m.Called(id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.ReconciliationReport), args.Error(1)
+}
+
+func (m *MockRepository) LatestReconciliationReport() (*model.ReconciliationReport, error) {
+	args := m.Called()
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.ReconciliationReport), args.Error(1)
+}
+
+func (m *MockRepository) SealLegacyLedgerEntries() (int, error) {
+	args := m.Called()
+	return args.Int(0), args.Error(1)
+}
+
+func (m *MockRepository) ChainHeads() ([]model.ChainHead, error) {
+	args := m.Called()
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]model.ChainHead), args.Error(1)
+}
+
+func (m *MockRepository) LedgerChain(accountID string) (*model.LedgerChain, error) {
+	args := m.Called(accountID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.LedgerChain), args.Error(1)
+}
+
+func (m *MockRepository) SaveCheckpoint(cp *model.LedgerCheckpoint) error {
+	args := m.Called(cp)
 	return args.Error(0)
 }
 
-func TestCreateAccount(t *testing.T) {
+func (m *MockRepository) GetCheckpoint(id string) (*model.LedgerCheckpoint, error) {
+	args := m.Called(id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.LedgerCheckpoint), args.Error(1)
+}
+
+func (m *MockRepository) LatestCheckpoint() (*model.LedgerCheckpoint, error) {
+	args := m.Called()
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.LedgerCheckpoint), args.Error(1)
+}
+
+func (m *MockRepository) ListCheckpoints(limit int) ([]model.LedgerCheckpoint, error) {
+	args := m.Called(limit)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]model.LedgerCheckpoint), args.Error(1)
+}
+
+func (m *MockRepository) PlaceHold(acc *model.Account, h *model.Hold) error {
+	return m.Called(acc, h).Error(0)
+}
+
+func (m *MockRepository) GetHold(id string) (*model.Hold, error) {
+	args := m.Called(id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.Hold), args.Error(1)
+}
+
+func (m *MockRepository) ListHolds(accountID string, status model.HoldStatus, limit int) ([]model.Hold, error) {
+	args := m.Called(accountID, status, limit)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]model.Hold), args.Error(1)
+}
+
+func (m *MockRepository) LockHold(id uuid.UUID) (*model.Hold, error) {
+	args := m.Called(id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.Hold), args.Error(1)
+}
+
+func (m *MockRepository) CaptureHold(acc *model.Account, h *model.Hold, amount money.Money, final bool) (*model.LedgerEntry, error) {
+	args := m.Called(acc, h, amount, final)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.LedgerEntry), args.Error(1)
+}
+
+func (m *MockRepository) ReleaseHold(holdID string) (*model.Hold, error) {
+	args := m.Called(holdID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.Hold), args.Error(1)
+}
+
+func (m *MockRepository) ExpireHolds() (int, error) {
+	args := m.Called()
+	return args.Int(0), args.Error(1)
+}
+
+func (m *MockRepository) SetOverdraftLimit(acc *model.Account, change *model.OverdraftLimitChange) error {
+	return m.Called(acc, change).Error(0)
+}
+
+func (m *MockRepository) ListOverdraftLimitChanges(accountID string, limit int) ([]model.OverdraftLimitChange, error) {
+	args := m.Called(accountID, limit)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]model.OverdraftLimitChange), args.Error(1)
+}
+
+func (m *MockRepository) OverdrawnAccounts(businessDate time.Time) ([]uuid.UUID, error) {
+	args := m.Called(businessDate)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]uuid.UUID), args.Error(1)
+}
+
+func (m *MockRepository) InterestPosted(acc *model.Account, entryType model.LedgerEntryType, businessDate time.Time) (bool, error) {
+	args := m.Called(acc, entryType, businessDate)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockRepository) PostInterest(acc *model.Account, entryType model.LedgerEntryType, amount money.Money, businessDate time.Time, description string) (*model.LedgerEntry, error) {
+	args := m.Called(acc, entryType, amount, businessDate, description)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.LedgerEntry), args.Error(1)
+}
+
+func (m *MockRepository) SavingsAccounts() ([]uuid.UUID, error) {
+	args := m.Called()
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]uuid.UUID), args.Error(1)
+}
+
+func (m *MockRepository) InterestAccrued(acc *model.Account, businessDate time.Time) (bool, error) {
+	args := m.Called(acc, businessDate)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockRepository) EndOfDayBalance(acc *model.Account, businessDate time.Time) (money.Amount, error) {
+	args := m.Called(acc, businessDate)
+	return args.Get(0).(money.Amount), args.Error(1)
+}
+
+func (m *MockRepository) RecordAccrual(acc *model.Account, accrual *model.InterestAccrual, rate money.Rate, dayCount money.DayCount) error {
+	return m.Called(acc, accrual, rate, dayCount).Error(0)
+}
+
+func (m *MockRepository) ListProducts() ([]model.Product, error) {
+	args := m.Called()
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]model.Product), args.Error(1)
+}
+
+func (m *MockRepository) GetProduct(code string) (*model.Product, error) {
+	args := m.Called(code)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.Product), args.Error(1)
+}
+
+func (m *MockRepository) SaveProduct(p *model.Product) (*model.Product, error) {
+	args := m.Called(p)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.Product), args.Error(1)
+}
+
+func (m *MockRepository) Product(code string) (*model.Product, error) {
+	args := m.Called(code)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.Product), args.Error(1)
+}
+
+func (m *MockRepository) PostFee(acc *model.Account, fee money.Money, cause *model.LedgerEntry, description string) (*model.LedgerEntry, error) {
+	args := m.Called(acc, fee, cause, description)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.LedgerEntry), args.Error(1)
+}
+
+func (m *MockRepository) FeeSchedule(productCode string, op model.FeeOperation) (*model.FeeSchedule, error) {
+	args := m.Called(productCode, op)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.FeeSchedule), args.Error(1)
+}
+
+func (m *MockRepository) ListFeeSchedules(productCode string) ([]model.FeeSchedule, error) {
+	args := m.Called(productCode)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]model.FeeSchedule), args.Error(1)
+}
+
+func (m *MockRepository) GetFeeSchedule(productCode string, op model.FeeOperation) (*model.FeeSchedule, error) {
+	args := m.Called(productCode, op)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.FeeSchedule), args.Error(1)
+}
+
+func (m *MockRepository) SaveFeeSchedule(s *model.FeeSchedule) (*model.FeeSchedule, error) {
+	args := m.Called(s)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.FeeSchedule), args.Error(1)
+}
+
+func (m *MockRepository) DeleteFeeSchedule(productCode string, op model.FeeOperation) (bool, error) {
+	args := m.Called(productCode, op)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockRepository) MaintenanceFeeAccounts(month time.Time) ([]uuid.UUID, error) {
+	args := m.Called(month)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]uuid.UUID), args.Error(1)
+}
+
+func (m *MockRepository) MaintenanceFeeCharged(acc *model.Account, month time.Time) (bool, error) {
+	args := m.Called(acc, month)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockRepository) RecordMaintenanceFee(entry *model.LedgerEntry, month time.Time) error {
+	return m.Called(entry, month).Error(0)
+}
+
+func (m *MockRepository) ConsumeLimits(acc *model.Account, p *model.Product, amount money.Money) error {
+	return m.Called(acc, p, amount).Error(0)
+}
+
+func (m *MockRepository) GetLimitOverride(accountID uuid.UUID) (*model.LimitOverride, error) {
+	args := m.Called(accountID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.LimitOverride), args.Error(1)
+}
+
+func (m *MockRepository) SaveLimitOverride(o *model.LimitOverride) (*model.LimitOverride, error) {
+	args := m.Called(o)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.LimitOverride), args.Error(1)
+}
+
+func (m *MockRepository) LimitUsage(accountID uuid.UUID) (model.LimitUsage, error) {
+	args := m.Called(accountID)
+	return args.Get(0).(model.LimitUsage), args.Error(1)
+}
+
+func (m *MockRepository) GetCustomerLimits(customerID uuid.UUID, currency money.Currency) (*model.CustomerLimits, error) {
+	args := m.Called(customerID, currency)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.CustomerLimits), args.Error(1)
+}
+
+func (m *MockRepository) ListCustomerLimits(customerID uuid.UUID) ([]model.CustomerLimits, error) {
+	args := m.Called(customerID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]model.CustomerLimits), args.Error(1)
+}
+
+func (m *MockRepository) SaveCustomerLimits(l *model.CustomerLimits) (*model.CustomerLimits, error) {
+	args := m.Called(l)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.CustomerLimits), args.Error(1)
+}
+
+func (m *MockRepository) CustomerLimitUsage(customerID uuid.UUID, currency money.Currency) (model.LimitUsage, error) {
+	args := m.Called(customerID, currency)
+	return args.Get(0).(model.LimitUsage), args.Error(1)
+}
+
+func (m *MockRepository) CreateScheduledTransfer(st *model.ScheduledTransfer, idem *model.IdempotencyKey) (*model.ScheduledTransfer, error) {
+	args := m.Called(st, idem)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.ScheduledTransfer), args.Error(1)
+}
+
+func (m *MockRepository) GetScheduledTransfer(id string) (*model.ScheduledTransfer, error) {
+	args := m.Called(id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.ScheduledTransfer), args.Error(1)
+}
+
+func (m *MockRepository) ListScheduledTransfers(accountID string, status model.ScheduledTransferStatus, limit int) ([]model.ScheduledTransfer, error) {
+	args := m.Called(accountID, status, limit)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]model.ScheduledTransfer), args.Error(1)
+}
+
+func (m *MockRepository) AmendScheduledTransfer(st *model.ScheduledTransfer) (*model.ScheduledTransfer, error) {
+	args := m.Called(st)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.ScheduledTransfer), args.Error(1)
+}
+
+func (m *MockRepository) CancelScheduledTransfer(id string) (*model.ScheduledTransfer, error) {
+	args := m.Called(id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.ScheduledTransfer), args.Error(1)
+}
+
+func (m *MockRepository) DueScheduledTransfers(now time.Time, limit int) ([]uuid.UUID, error) {
+	args := m.Called(now, limit)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]uuid.UUID), args.Error(1)
+}
+
+func (m *MockRepository) RecordScheduledTransferFailure(st *model.ScheduledTransfer) (*model.ScheduledTransfer, error) {
+	args := m.Called(st)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.ScheduledTransfer), args.Error(1)
+}
+
+func (m *MockRepository) LockScheduledTransfer(id uuid.UUID) (*model.ScheduledTransfer, error) {
+	args := m.Called(id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.ScheduledTransfer), args.Error(1)
+}
+
+func (m *MockRepository) CompleteScheduledTransfer(st *model.ScheduledTransfer, ref uuid.UUID) error {
+	return m.Called(st, ref).Error(0)
+}
+
+func (m *MockRepository) CreateStandingOrder(so *model.StandingOrder, idem *model.IdempotencyKey) (*model.StandingOrder, error) {
+	args := m.Called(so, idem)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.StandingOrder), args.Error(1)
+}
+
+func (m *MockRepository) GetStandingOrder(id string) (*model.StandingOrder, error) {
+	args := m.Called(id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.StandingOrder), args.Error(1)
+}
+
+func (m *MockRepository) ListStandingOrders(accountID string, limit int) ([]model.StandingOrder, error) {
+	args := m.Called(accountID, limit)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]model.StandingOrder), args.Error(1)
+}
+
+func (m *MockRepository) ListStandingOrderPayments(standingOrderID string, limit int) ([]model.ScheduledTransfer, error) {
+	args := m.Called(standingOrderID, limit)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]model.ScheduledTransfer), args.Error(1)
+}
+
+func (m *MockRepository) CancelStandingOrder(id string) (*model.StandingOrder, error) {
+	args := m.Called(id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.StandingOrder), args.Error(1)
+}
+
+func (m *MockRepository) DueStandingOrders(today time.Time, limit int) ([]uuid.UUID, error) {
+	args := m.Called(today, limit)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]uuid.UUID), args.Error(1)
+}
+
+func (m *MockRepository) LockStandingOrder(id uuid.UUID) (*model.StandingOrder, error) {
+	args := m.Called(id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.StandingOrder), args.Error(1)
+}
+
+func (m *MockRepository) ScheduleStandingOrderPayment(so *model.StandingOrder, payment *model.ScheduledTransfer) error {
+	return m.Called(so, payment).Error(0)
+}
+
+// warsawCalendar is the built-in PL calendar, whose days start an hour or
+// two before those in UTC.
+var warsawCalendar, _ = calendar.Default().Get(calendar.DefaultCountry)
+
+// plainProduct has no limits or fees, so that only the rule under test
+// applies.
+var plainProduct = &model.Product{Code: "plain", Kind: model.AccountCurrent, Currencies: []money.Currency{money.PLN, money.EUR, money.USD}, Active: true}
+
+// expectProduct lets a unit of work read p as the product of any account,
+// charging no fees and allowing any debit unless a test expects a schedule
+// or a limit check first.
+func expectProduct(m *MockRepository, p *model.Product) {
+	m.On("Product", mock.Anything).Return(p, nil).Maybe()
+	m.On("FeeSchedule", mock.Anything, mock.Anything).Return(nil, nil).Maybe()
+	m.On("ConsumeLimits", mock.Anything, mock.Anything, mock.Anything).Return(nil).Maybe()
+}
+
+// expectLocked lets a transfer between from and to lock both accounts.
+func expectLocked(m *MockRepository, from, to *model.Account) {
+	m.On("InTx", (*model.IdempotencyKey)(nil)).Return(nil)
+	m.On("LockAccounts", []uuid.UUID{from.ID, to.ID}).Return(map[uuid.UUID]*model.Account{from.ID: from, to.ID: to}, nil)
+	expectProduct(m, plainProduct)
+}
+
+func TestCreateAccount(t *testing.T) {
+	mockRepo := new(MockRepository)
+	svc := NewAccountService(mockRepo)
+	ctx := context.Background()
+
+	customerID := uuid.New()
+	currency := "USD"
+
+	mockRepo.On("GetProduct", "foreign_currency").Return(&model.Product{Code: "foreign_currency", Kind: model.AccountCurrent, Currencies: []money.Currency{money.EUR, money.USD}, Active: true}, nil)
+	mockRepo.On("CreateAccount", mock.AnythingOfType("*model.Account")).Return(nil)
+
+	acc, err := svc.CreateAccount(ctx, customerID.String(), currency, "foreign_currency")
+
+	assert.NoError(t, err)
+	assert.NotNil(t, acc)
+	assert.Equal(t, money.USD, acc.Currency)
+	assert.Equal(t, customerID, acc.CustomerID)
+	assert.Equal(t, "foreign_currency", acc.ProductCode)
+	assert.Equal(t, model.AccountCurrent, acc.Kind)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestCreateAccount_Product(t *testing.T) {
+	mockRepo := new(MockRepository)
+	svc := NewAccountService(mockRepo)
+
+	mockRepo.On("GetProduct", "savings").Return(&model.Product{Code: "savings", Kind: model.AccountSavings, Currencies: []money.Currency{money.PLN}, Active: true}, nil)
+	mockRepo.On("GetProduct", "retired").Return(&model.Product{Code: "retired", Kind: model.AccountCurrent, Currencies: []money.Currency{money.PLN}}, nil)
+	mockRepo.On("GetProduct", "pension").Return(nil, nil)
+	mockRepo.On("CreateAccount", mock.AnythingOfType("*model.Account")).Return(nil)
+
+	acc, err := svc.CreateAccount(context.Background(), uuid.New().String(), "PLN", "savings")
+	assert.NoError(t, err)
+	assert.Equal(t, model.AccountSavings, acc.Kind)
+
+	for _, tc := range []struct{ currency, product, field string }{
+		{"PLN", "", "productCode"},
+		{"PLN", "pension", "productCode"},
+		{"PLN", "retired", "productCode"},
+		{"EUR", "savings", "currency"},
+	} {
+		_, err = svc.CreateAccount(context.Background(), uuid.New().String(), tc.currency, tc.product)
+		var vErr *ValidationError
+		if assert.ErrorAs(t, err, &vErr, "product %q in %s", tc.product, tc.currency) {
+			assert.Equal(t, tc.field, vErr.Field)
+		}
+	}
+	mockRepo.AssertNumberOfCalls(t, "CreateAccount", 1)
+}
+
+func TestGetAccount(t *testing.T) {
+	mockRepo := new(MockRepository)
+	svc := NewAccountService(mockRepo)
+	ctx := context.Background()
+
+	accountID := uuid.New()
+	expectedAcc := &model.Account{ID: accountID, Currency: "PLN"}
+
+	mockRepo.On("GetAccount", accountID.String()).Return(expectedAcc, nil)
+
+	acc, err := svc.GetAccount(ctx, accountID.String())
+
+	assert.NoError(t, err)
+	assert.Equal(t, expectedAcc, acc)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestCreateAccount_InvalidID(t *testing.T) {
+	mockRepo := new(MockRepository)
+	svc := NewAccountService(mockRepo)
+	ctx := context.Background()
+
+	acc, err := svc.CreateAccount(ctx, "invalid-uuid", "USD", "current")
+
+	var verr *ValidationError
+	assert.ErrorAs(t, err, &verr)
+	assert.Equal(t, "customerId", verr.Field)
+	assert.Nil(t, acc)
+}
+
+func TestGetAccount_NotFound(t *testing.T) {
+	mockRepo := new(MockRepository)
+	svc := NewAccountService(mockRepo)
+	ctx := context.Background()
+
+	accountID := uuid.New()
+	mockRepo.On("GetAccount", accountID.String()).Return(nil, nil)
+
+	acc, err := svc.GetAccount(ctx, accountID.String())
+
+	assert.Error(t, err)
+	assert.Nil(t, acc)
+	assert.Contains(t, err.Error(), "account not found")
+	mockRepo.AssertExpectations(t)
+}
+
+func TestUpdateBalance_WithdrawalInsufficientFunds(t *testing.T) {
+	mockRepo := new(MockRepository)
+	svc := NewAccountService(mockRepo)
+	ctx := context.Background()
+
+	accountID := uuid.New()
+	currentAcc := &model.Account{ID: accountID, Currency: money.PLN, Status: model.AccountActive, Balance: money.MustParseAmount("10")}
+
+	mockRepo.On("InTx", (*model.IdempotencyKey)(nil)).Return(nil)
+	mockRepo.On("LockAccount", accountID.String()).Return(currentAcc, nil)
+	expectProduct(mockRepo, plainProduct)
+
+	_, err := svc.UpdateBalance(ctx, accountID.String(), money.New(money.MustParseAmount("-20"), money.PLN), model.Withdrawal, "Withdrawal more than balance")
+
+	var insufficient *InsufficientFundsError
+	assert.ErrorAs(t, err, &insufficient)
+	assert.Equal(t, money.New(money.MustParseAmount("20"), money.PLN), insufficient.Requested)
+	mockRepo.AssertNotCalled(t, "PostEntry", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestUpdateBalance_Success(t *testing.T) {
 	mockRepo := new(MockRepository)
 	svc := NewAccountService(mockRepo)
 	ctx := context.Background()
 
-	customerID := uuid.New()
-	currency := "USD"
+	accountID := uuid.New()
+	currentAcc := &model.Account{ID: accountID, Currency: money.PLN, Status: model.AccountActive, Balance: money.MustParseAmount("100")}
+	amount := money.New(money.MustParseAmount("50"), money.PLN)
 
-	mockRepo.On("CreateAccount", mock.AnythingOfType("*model.Account")).Return(nil)
+	mockRepo.On("InTx", (*model.IdempotencyKey)(nil)).Return(nil)
+	mockRepo.On("LockAccount", accountID.String()).Return(currentAcc, nil)
+	expectProduct(mockRepo, plainProduct)
+	mockRepo.On("PostEntry", currentAcc, amount, model.Deposit, "Success deposit").Return(&model.LedgerEntry{}, nil)
 
-	acc, err := svc.CreateAccount(ctx, customerID.String(), currency)
+	_, err := svc.UpdateBalance(ctx, accountID.String(), amount, model.Deposit, "Success deposit")
 
 	assert.NoError(t, err)
-	assert.NotNil(t, acc)
-	assert.Equal(t, currency, acc.Currency)
-	assert.Equal(t, customerID, acc.CustomerID)
 	mockRepo.AssertExpectations(t)
 }
 
-func TestGetAccount(t *testing.T) {
+func TestCreateAccount_InvalidCurrency(t *testing.T) {
+	mockRepo := new(MockRepository)
+	svc := NewAccountService(mockRepo)
+
+	acc, err := svc.CreateAccount(context.Background(), uuid.New().String(), "XYZ", "current")
+
+	assert.ErrorIs(t, err, money.ErrUnknownCurrency)
+	assert.Nil(t, acc)
+	mockRepo.AssertNotCalled(t, "CreateAccount", mock.Anything)
+}
+
+func TestUpdateBalance_CurrencyMismatch(t *testing.T) {
 	mockRepo := new(MockRepository)
 	svc := NewAccountService(mockRepo)
-	ctx := context.Background()
 
 	accountID := uuid.New()
-	expectedAcc := &model.Account{ID: accountID, Currency: "PLN"}
+	currentAcc := &model.Account{ID: accountID, Currency: money.PLN, Status: model.AccountActive, Balance: money.MustParseAmount("100")}
+	mockRepo.On("InTx", (*model.IdempotencyKey)(nil)).Return(nil)
+	mockRepo.On("LockAccount", accountID.String()).Return(currentAcc, nil)
+	expectProduct(mockRepo, plainProduct)
 
-	mockRepo.On("GetAccount", accountID.String()).Return(expectedAcc, nil)
+	_, err := svc.UpdateBalance(context.Background(), accountID.String(), money.New(money.MustParseAmount("10"), money.EUR), model.Deposit, "EUR into PLN")
 
-	acc, err := svc.GetAccount(ctx, accountID.String())
+	assert.ErrorIs(t, err, money.ErrCurrencyMismatch)
+	mockRepo.AssertNotCalled(t, "PostEntry", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestUpdateBalance_RepeatedDepositsDoNotDrift(t *testing.T) {
+	mockRepo := new(MockRepository)
+	svc := NewAccountService(mockRepo)
+
+	accountID := uuid.New()
+	acc := &model.Account{ID: accountID, Currency: money.PLN, Status: model.AccountActive}
+	cent := money.New(money.MustParseAmount("0.01"), money.PLN)
+
+	mockRepo.On("InTx", (*model.IdempotencyKey)(nil)).Return(nil)
+	mockRepo.On("LockAccount", accountID.String()).Return(acc, nil)
+	expectProduct(mockRepo, plainProduct)
+	mockRepo.On("PostEntry", acc, cent, model.Deposit, "grosz").
+		Run(func(args mock.Arguments) {
+			acc.Balance, _ = acc.Balance.Add(args.Get(1).(money.Money).Amount)
+		}).Return(&model.LedgerEntry{}, nil)
+
+	for i := 0; i < 1000; i++ {
+		_, err := svc.UpdateBalance(context.Background(), accountID.String(), cent, model.Deposit, "grosz")
+		assert.NoError(t, err)
+	}
+	assert.Equal(t, money.MustParseAmount("10"), acc.Balance)
+}
+
+func TestTransfer_Success(t *testing.T) {
+	mockRepo := new(MockRepository)
+	svc := NewAccountService(mockRepo)
+
+	from := &model.Account{ID: uuid.New(), Currency: money.PLN, Status: model.AccountActive, Balance: money.MustParseAmount("100")}
+	to := &model.Account{ID: uuid.New(), Currency: money.PLN, Status: model.AccountActive}
+	amount := money.New(money.MustParseAmount("40"), money.PLN)
+	expected := &model.Transfer{ReferenceID: uuid.New()}
+
+	expectLocked(mockRepo, from, to)
+	mockRepo.On("BookTransfer", from, to, amount, "Rent").Return(expected, nil)
+
+	transfer, err := svc.Transfer(context.Background(), from.ID.String(), to.ID.String(), amount, "Rent")
 
 	assert.NoError(t, err)
-	assert.Equal(t, expectedAcc, acc)
+	assert.Equal(t, expected, transfer)
 	mockRepo.AssertExpectations(t)
 }
 
-func TestCreateAccount_InvalidID(t *testing.T) {
+func TestTransfer_InsufficientFunds(t *testing.T) {
 	mockRepo := new(MockRepository)
 	svc := NewAccountService(mockRepo)
-	ctx := context.Background()
 
-	acc, err := svc.CreateAccount(ctx, "invalid-uuid", "USD")
+	from := &model.Account{ID: uuid.New(), Currency: money.PLN, Status: model.AccountActive, Balance: money.MustParseAmount("10")}
+	to := &model.Account{ID: uuid.New(), Currency: money.PLN, Status: model.AccountActive}
+
+	expectLocked(mockRepo, from, to)
+
+	_, err := svc.Transfer(context.Background(), from.ID.String(), to.ID.String(), money.New(money.MustParseAmount("40"), money.PLN), "Rent")
 
 	assert.Error(t, err)
-	assert.Nil(t, acc)
-	assert.Contains(t, err.Error(), "invalid customer id")
+	assert.Contains(t, err.Error(), "insufficient funds")
+	mockRepo.AssertNotCalled(t, "BookTransfer", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
 }
 
-func TestGetAccount_NotFound(t *testing.T) {
+func TestTransfer_CurrencyMismatch(t *testing.T) {
+	mockRepo := new(MockRepository)
+	svc := NewAccountService(mockRepo)
+
+	from := &model.Account{ID: uuid.New(), Currency: money.PLN, Status: model.AccountActive, Balance: money.MustParseAmount("100")}
+	to := &model.Account{ID: uuid.New(), Currency: money.EUR, Status: model.AccountActive}
+
+	expectLocked(mockRepo, from, to)
+
+	_, err := svc.Transfer(context.Background(), from.ID.String(), to.ID.String(), money.New(money.MustParseAmount("40"), money.PLN), "Rent")
+
+	assert.ErrorIs(t, err, money.ErrCurrencyMismatch)
+}
+
+func TestTransfer_Validation(t *testing.T) {
+	mockRepo := new(MockRepository)
+	svc := NewAccountService(mockRepo)
+	id := uuid.New().String()
+
+	_, err := svc.Transfer(context.Background(), id, uuid.New().String(), money.New(money.MustParseAmount("0"), money.PLN), "")
+	assert.Error(t, err)
+
+	_, err = svc.Transfer(context.Background(), id, id, money.New(money.MustParseAmount("1"), money.PLN), "")
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "same account")
+}
+
+func TestUpdateBalance_IdempotentReplaySkipsBalanceChecks(t *testing.T) {
+	mockRepo := new(MockRepository)
+	svc := NewAccountService(mockRepo)
+	ctx := WithIdempotencyKey(context.Background(), "alice", "retry-1")
+
+	accountID := uuid.New().String()
+	amount := money.New(money.MustParseAmount("-50"), money.PLN)
+
+	// The first call empties the account...
+	var recorded *model.IdempotencyKey
+	acc := &model.Account{Currency: money.PLN, Status: model.AccountActive, Balance: money.MustParseAmount("50")}
+	entry := &model.LedgerEntry{ID: uuid.New(), Amount: amount.Amount, BalanceAfter: money.Amount{}}
+	mockRepo.On("GetIdempotencyRecord", "alice", "retry-1").Return(nil, nil).Once()
+	mockRepo.On("InTx", mock.AnythingOfType("*model.IdempotencyKey")).
+		Run(func(args mock.Arguments) { recorded = args.Get(0).(*model.IdempotencyKey) }).
+		Return(nil).Once()
+	mockRepo.On("LockAccount", accountID).Return(acc, nil).Once()
+	expectProduct(mockRepo, plainProduct)
+	mockRepo.On("PostEntry", acc, amount, model.Withdrawal, "ATM").Return(entry, nil).Once()
+
+	first, err := svc.UpdateBalance(ctx, accountID, amount, model.Withdrawal, "ATM")
+	assert.NoError(t, err)
+
+	// ...so the retry must be answered from the stored response rather than
+	// failing the insufficient funds check.
+	mockRepo.On("GetIdempotencyRecord", "alice", "retry-1").Return(&model.IdempotencyRecord{
+		Key: "retry-1", Fingerprint: recorded.Fingerprint, Response: []byte(`{"id":"` + entry.ID.String() + `","amount":"-50.00","balanceAfter":"0.00"}`),
+	}, nil).Once()
+
+	second, err := svc.UpdateBalance(ctx, accountID, amount, model.Withdrawal, "ATM")
+	assert.NoError(t, err)
+	assert.Equal(t, first.ID, second.ID)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestUpdateBalance_IdempotencyKeyReusedWithDifferentPayload(t *testing.T) {
+	mockRepo := new(MockRepository)
+	svc := NewAccountService(mockRepo)
+	ctx := WithIdempotencyKey(context.Background(), "alice", "retry-1")
+
+	mockRepo.On("GetIdempotencyRecord", "alice", "retry-1").Return(&model.IdempotencyRecord{Key: "retry-1", Fingerprint: "something-else"}, nil)
+
+	_, err := svc.UpdateBalance(ctx, uuid.New().String(), money.New(money.MustParseAmount("5"), money.PLN), model.Deposit, "desc")
+
+	assert.ErrorIs(t, err, ErrIdempotencyKeyReused)
+	mockRepo.AssertNotCalled(t, "GetAccount", mock.Anything)
+}
+
+func TestIdempotencyKey_FingerprintDependsOnPayload(t *testing.T) {
+	ctx := WithIdempotencyKey(context.Background(), "alice", "k")
+
+	a := idempotencyKey(ctx, "update_balance", "acc", "10.00", "PLN")
+	b := idempotencyKey(ctx, "update_balance", "acc", "10.01", "PLN")
+	c := idempotencyKey(ctx, "update_balance", "acc", "10.00", "PLN")
+
+	assert.NotEqual(t, a.Fingerprint, b.Fingerprint)
+	assert.Equal(t, a.Fingerprint, c.Fingerprint)
+	assert.Nil(t, idempotencyKey(context.Background(), "update_balance"))
+}
+
+func TestIdempotencyKey_ScopedToCaller(t *testing.T) {
+	alice := idempotencyKey(WithIdempotencyKey(context.Background(), "alice", "k"), "update_balance", "acc", "10.00", "PLN")
+	bob := idempotencyKey(WithIdempotencyKey(context.Background(), "bob", "k"), "update_balance", "acc", "10.00", "PLN")
+
+	assert.Equal(t, "alice", alice.Owner)
+	assert.Equal(t, "bob", bob.Owner)
+	assert.Equal(t, alice.Key, bob.Key)
+}
+
+func TestUpdateBalance_IdempotencyKeyOfAnotherCallerNotReplayed(t *testing.T) {
+	mockRepo := new(MockRepository)
+	svc := NewAccountService(mockRepo)
+	ctx := WithIdempotencyKey(context.Background(), "bob", "retry-1")
+
+	accountID := uuid.New().String()
+	amount := money.New(money.MustParseAmount("5"), money.PLN)
+	acc := &model.Account{Currency: money.PLN, Status: model.AccountActive}
+	entry := &model.LedgerEntry{ID: uuid.New(), Amount: amount.Amount, BalanceAfter: amount.Amount}
+
+	// alice used "retry-1" before, but the lookup is scoped to bob and finds
+	// nothing, so bob's deposit is executed rather than answered with
+	// alice's result.
+	mockRepo.On("GetIdempotencyRecord", "bob", "retry-1").Return(nil, nil).Once()
+	mockRepo.On("InTx", mock.MatchedBy(func(idem *model.IdempotencyKey) bool {
+		return idem.Owner == "bob" && idem.Key == "retry-1"
+	})).Return(nil).Once()
+	mockRepo.On("LockAccount", accountID).Return(acc, nil).Once()
+	expectProduct(mockRepo, plainProduct)
+	mockRepo.On("PostEntry", acc, amount, model.Deposit, "Cash").Return(entry, nil).Once()
+
+	got, err := svc.UpdateBalance(ctx, accountID, amount, model.Deposit, "Cash")
+	assert.NoError(t, err)
+	assert.Equal(t, entry.ID, got.ID)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestListAccounts_Pagination(t *testing.T) {
+	mockRepo := new(MockRepository)
+	svc := NewAccountService(mockRepo)
+
+	customerID := uuid.New()
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	accounts := []model.Account{
+		{ID: uuid.New(), CreatedAt: base},
+		{ID: uuid.New(), CreatedAt: base.Add(time.Minute)},
+		{ID: uuid.New(), CreatedAt: base.Add(2 * time.Minute)},
+	}
+
+	// Two per page: the repository is asked for one extra row.
+	mockRepo.On("ListAccounts", model.AccountFilter{CustomerID: customerID, Limit: 3}).Return(accounts, nil)
+
+	page, err := svc.ListAccounts(context.Background(), model.AccountFilter{CustomerID: customerID, Limit: 2})
+
+	assert.NoError(t, err)
+	assert.Len(t, page.Items, 2)
+	cursor, err := model.DecodeCursor(page.NextCursor)
+	assert.NoError(t, err)
+	assert.Equal(t, accounts[1].ID, cursor.ID)
+	assert.True(t, accounts[1].CreatedAt.Equal(cursor.CreatedAt))
+}
+
+func TestListAccounts_LastPage(t *testing.T) {
+	mockRepo := new(MockRepository)
+	svc := NewAccountService(mockRepo)
+
+	customerID := uuid.New()
+	mockRepo.On("ListAccounts", model.AccountFilter{CustomerID: customerID, Limit: DefaultPageSize + 1}).
+		Return([]model.Account{{ID: uuid.New()}}, nil)
+
+	page, err := svc.ListAccounts(context.Background(), model.AccountFilter{CustomerID: customerID})
+
+	assert.NoError(t, err)
+	assert.Len(t, page.Items, 1)
+	assert.Empty(t, page.NextCursor)
+}
+
+func TestListAccounts_Validation(t *testing.T) {
+	mockRepo := new(MockRepository)
+	svc := NewAccountService(mockRepo)
+
+	_, err := svc.ListAccounts(context.Background(), model.AccountFilter{})
+	assert.Error(t, err)
+
+	_, err = svc.ListAccounts(context.Background(), model.AccountFilter{CustomerID: uuid.New(), Status: "dormant"})
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "invalid status")
+
+	mockRepo.On("ListAccounts", mock.MatchedBy(func(f model.AccountFilter) bool { return f.Limit == MaxPageSize+1 })).Return([]model.Account{}, nil)
+	_, err = svc.ListAccounts(context.Background(), model.AccountFilter{CustomerID: uuid.New(), Limit: 10000})
+	assert.NoError(t, err)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestListLedgerEntries_Pagination(t *testing.T) {
+	mockRepo := new(MockRepository)
+	svc := NewAccountService(mockRepo)
+
+	accountID := uuid.New()
+	now := time.Now().UTC()
+	entries := []model.LedgerEntry{
+		{ID: uuid.New(), CreatedAt: now},
+		{ID: uuid.New(), CreatedAt: now.Add(-time.Minute)},
+	}
+
+	mockRepo.On("GetAccount", accountID.String()).Return(&model.Account{ID: accountID}, nil)
+	mockRepo.On("ListLedgerEntries", model.LedgerFilter{AccountID: accountID, Limit: 2}).Return(entries, nil)
+
+	page, err := svc.ListLedgerEntries(context.Background(), model.LedgerFilter{AccountID: accountID, Limit: 1})
+
+	assert.NoError(t, err)
+	assert.Len(t, page.Items, 1)
+	cursor, err := model.DecodeCursor(page.NextCursor)
+	assert.NoError(t, err)
+	assert.Equal(t, entries[0].ID, cursor.ID)
+}
+
+func TestListLedgerEntries_AccountNotFound(t *testing.T) {
 	mockRepo := new(MockRepository)
 	svc := NewAccountService(mockRepo)
-	ctx := context.Background()
 
 	accountID := uuid.New()
 	mockRepo.On("GetAccount", accountID.String()).Return(nil, nil)
 
-	acc, err := svc.GetAccount(ctx, accountID.String())
+	_, err := svc.ListLedgerEntries(context.Background(), model.LedgerFilter{AccountID: accountID})
+
+	assert.ErrorIs(t, err, ErrAccountNotFound)
+}
+
+func TestListLedgerEntries_Validation(t *testing.T) {
+	mockRepo := new(MockRepository)
+	svc := NewAccountService(mockRepo)
+	id := uuid.New()
+	from := time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC)
+	to := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	min, max := money.MustParseAmount("10"), money.MustParseAmount("5")
 
+	_, err := svc.ListLedgerEntries(context.Background(), model.LedgerFilter{AccountID: id, Types: []model.LedgerEntryType{"DEPOSIT"}})
 	assert.Error(t, err)
-	assert.Nil(t, acc)
-	assert.Contains(t, err.Error(), "account not found")
-	mockRepo.AssertExpectations(t)
+	_, err = svc.ListLedgerEntries(context.Background(), model.LedgerFilter{AccountID: id, From: &from, To: &to})
+	assert.Error(t, err)
+	_, err = svc.ListLedgerEntries(context.Background(), model.LedgerFilter{AccountID: id, MinAmount: &min, MaxAmount: &max})
+	assert.Error(t, err)
+	mockRepo.AssertNotCalled(t, "GetAccount", mock.Anything)
 }
 
-func TestUpdateBalance_WithdrawalInsufficientFunds(t *testing.T) {
+func TestGetCustomerByExternalID(t *testing.T) {
 	mockRepo := new(MockRepository)
 	svc := NewAccountService(mockRepo)
 	ctx := context.Background()
 
-	accountID := uuid.New()
-	currentAcc := &model.Account{ID: accountID, Balance: 10.0}
+	customer := &model.Customer{ID: uuid.New(), ExternalID: "test_user"}
+	mockRepo.On("GetCustomerByExternalID", "test_user").Return(customer, nil)
+	mockRepo.On("GetCustomerByExternalID", "ghost").Return(nil, nil)
 
-	mockRepo.On("GetAccount", accountID.String()).Return(currentAcc, nil)
+	c, err := svc.GetCustomerByExternalID(ctx, "test_user")
+	assert.NoError(t, err)
+	assert.Equal(t, customer, c)
 
-	err := svc.UpdateBalance(ctx, accountID.String(), -20.0, model.Withdrawal, "Withdrawal more than balance")
+	_, err = svc.GetCustomerByExternalID(ctx, "ghost")
+	assert.ErrorIs(t, err, ErrCustomerNotFound)
 
+	_, err = svc.GetCustomerByExternalID(ctx, "")
 	assert.Error(t, err)
-	assert.Contains(t, err.Error(), "insufficient funds")
+	mockRepo.AssertExpectations(t)
 }
 
-func TestUpdateBalance_Success(t *testing.T) {
+func TestGetPrimaryAccount_OldestActive(t *testing.T) {
 	mockRepo := new(MockRepository)
 	svc := NewAccountService(mockRepo)
 	ctx := context.Background()
 
-	accountID := uuid.New()
-	currentAcc := &model.Account{ID: accountID, Balance: 100.0}
-	amount := 50.0
+	customerID := uuid.New()
+	oldest := model.Account{ID: uuid.New(), CustomerID: customerID, Status: model.AccountActive}
+	mockRepo.On("ListAccounts", model.AccountFilter{
+		CustomerID: customerID,
+		Status:     model.AccountActive,
+		Limit:      1,
+	}).Return([]model.Account{oldest}, nil)
+
+	acc, err := svc.GetPrimaryAccount(ctx, customerID)
+	assert.NoError(t, err)
+	assert.Equal(t, oldest.ID, acc.ID)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestGetPrimaryAccount_NoActiveAccount(t *testing.T) {
+	mockRepo := new(MockRepository)
+	svc := NewAccountService(mockRepo)
+	ctx := context.Background()
+
+	customerID := uuid.New()
+	mockRepo.On("ListAccounts", mock.Anything).Return([]model.Account{}, nil)
+
+	acc, err := svc.GetPrimaryAccount(ctx, customerID)
+	assert.ErrorIs(t, err, ErrAccountNotFound)
+	assert.Nil(t, acc)
+}
+
+func TestUpdateBalance_FrozenAccount(t *testing.T) {
+	mockRepo := new(MockRepository)
+	svc := NewAccountService(mockRepo)
+
+	acc := &model.Account{ID: uuid.New(), Currency: money.PLN, Status: model.AccountFrozen, Balance: money.MustParseAmount("100")}
+	mockRepo.On("InTx", (*model.IdempotencyKey)(nil)).Return(nil)
+	mockRepo.On("LockAccount", acc.ID.String()).Return(acc, nil)
+	expectProduct(mockRepo, plainProduct)
+
+	_, err := svc.UpdateBalance(context.Background(), acc.ID.String(), money.New(money.MustParseAmount("-10"), money.PLN), model.Withdrawal, "ATM")
+
+	var notActive *AccountNotActiveError
+	assert.ErrorAs(t, err, &notActive)
+	assert.ErrorIs(t, err, ErrAccountNotActive)
+	assert.Equal(t, model.AccountFrozen, notActive.Status)
+	mockRepo.AssertNotCalled(t, "PostEntry", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestTransfer_ToClosedAccount(t *testing.T) {
+	mockRepo := new(MockRepository)
+	svc := NewAccountService(mockRepo)
 
-	mockRepo.On("GetAccount", accountID.String()).Return(currentAcc, nil)
-	mockRepo.On("UpdateBalance", accountID.String(), amount, model.Deposit, "Success deposit").Return(nil)
+	from := &model.Account{ID: uuid.New(), Currency: money.PLN, Status: model.AccountActive, Balance: money.MustParseAmount("100")}
+	to := &model.Account{ID: uuid.New(), Currency: money.PLN, Status: model.AccountClosed}
+	expectLocked(mockRepo, from, to)
 
-	err := svc.UpdateBalance(ctx, accountID.String(), amount, model.Deposit, "Success deposit")
+	_, err := svc.Transfer(context.Background(), from.ID.String(), to.ID.String(), money.New(money.MustParseAmount("10"), money.PLN), "Rent")
+
+	assert.ErrorIs(t, err, ErrAccountNotActive)
+	mockRepo.AssertNotCalled(t, "BookTransfer", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestFreezeAccount(t *testing.T) {
+	mockRepo := new(MockRepository)
+	svc := NewAccountService(mockRepo)
+
+	acc := &model.Account{ID: uuid.New(), Currency: money.PLN, Status: model.AccountActive}
+	frozen := *acc
+	frozen.Status = model.AccountFrozen
+	mockRepo.On("GetAccount", acc.ID.String()).Return(acc, nil).Once()
+	mockRepo.On("InTx", (*model.IdempotencyKey)(nil)).Return(nil)
+	mockRepo.On("LockAccounts", []uuid.UUID{acc.ID}).Return(map[uuid.UUID]*model.Account{acc.ID: acc}, nil)
+	mockRepo.On("ChangeStatus", acc, model.StatusChange{AccountID: acc.ID, To: model.AccountFrozen, Reason: model.ReasonSuspectedFraud, Note: "card skimmed"}, (*uuid.UUID)(nil)).
+		Return(&model.AccountStatusChange{FromStatus: model.AccountActive, ToStatus: model.AccountFrozen}, nil)
+	mockRepo.On("GetAccount", acc.ID.String()).Return(&frozen, nil).Once()
+
+	got, err := svc.FreezeAccount(context.Background(), acc.ID.String(), model.ReasonSuspectedFraud, "card skimmed")
+
+	assert.NoError(t, err)
+	assert.Equal(t, model.AccountFrozen, got.Status)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestChangeStatus_InvalidReason(t *testing.T) {
+	mockRepo := new(MockRepository)
+	svc := NewAccountService(mockRepo)
+
+	_, err := svc.FreezeAccount(context.Background(), uuid.New().String(), "bored", "")
+
+	assert.ErrorIs(t, err, ErrInvalidStatusReason)
+	assert.ErrorIs(t, err, ErrValidation)
+	mockRepo.AssertNotCalled(t, "InTx", mock.Anything)
+}
+
+func TestChangeStatus_TransitionCheckedUnderLock(t *testing.T) {
+	mockRepo := new(MockRepository)
+	svc := NewAccountService(mockRepo)
+
+	// The account was closed after it was read.
+	acc := &model.Account{ID: uuid.New(), Currency: money.PLN, Status: model.AccountFrozen}
+	closed := &model.Account{ID: acc.ID, Currency: money.PLN, Status: model.AccountClosed}
+	mockRepo.On("GetAccount", acc.ID.String()).Return(acc, nil)
+	mockRepo.On("InTx", (*model.IdempotencyKey)(nil)).Return(nil)
+	mockRepo.On("LockAccounts", []uuid.UUID{acc.ID}).Return(map[uuid.UUID]*model.Account{acc.ID: closed}, nil)
+
+	_, err := svc.UnfreezeAccount(context.Background(), acc.ID.String(), model.ReasonReviewCleared, "")
+
+	var transition *StatusTransitionError
+	if assert.ErrorAs(t, err, &transition) {
+		assert.Equal(t, model.AccountClosed, transition.From)
+	}
+	mockRepo.AssertNotCalled(t, "ChangeStatus", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestCloseAccount_PaysOutBalance(t *testing.T) {
+	mockRepo := new(MockRepository)
+	svc := NewAccountService(mockRepo)
+
+	acc := &model.Account{ID: uuid.New(), Currency: money.PLN, Status: model.AccountActive, Balance: money.MustParseAmount("5")}
+	payout := &model.Account{ID: uuid.New(), Currency: money.PLN, Status: model.AccountActive}
+	transfer := &model.Transfer{ReferenceID: uuid.New()}
+	mockRepo.On("GetAccount", acc.ID.String()).Return(acc, nil)
+	mockRepo.On("GetAccount", payout.ID.String()).Return(payout, nil)
+	mockRepo.On("InTx", (*model.IdempotencyKey)(nil)).Return(nil)
+	mockRepo.On("LockAccounts", []uuid.UUID{acc.ID, payout.ID}).Return(map[uuid.UUID]*model.Account{acc.ID: acc, payout.ID: payout}, nil)
+	mockRepo.On("BookTransfer", acc, payout, money.New(money.MustParseAmount("5"), money.PLN), "Payout on account closure").Return(transfer, nil)
+	mockRepo.On("ChangeStatus", acc, mock.AnythingOfType("model.StatusChange"), &transfer.ReferenceID).
+		Return(&model.AccountStatusChange{ToStatus: model.AccountClosed}, nil)
+
+	_, err := svc.CloseAccount(context.Background(), acc.ID.String(), model.ReasonCustomerRequest, "", payout.ID.String())
 
 	assert.NoError(t, err)
 	mockRepo.AssertExpectations(t)
+
+	// An overdrawn account cannot be closed.
+	acc.Balance = money.MustParseAmount("-5")
+	_, err = svc.CloseAccount(context.Background(), acc.ID.String(), model.ReasonCustomerRequest, "", payout.ID.String())
+	assert.ErrorIs(t, err, ErrBalanceNotZero)
+	mockRepo.AssertNumberOfCalls(t, "ChangeStatus", 1)
+}
+
+func TestCloseAccount_PayoutValidation(t *testing.T) {
+	mockRepo := new(MockRepository)
+	svc := NewAccountService(mockRepo)
+
+	acc := &model.Account{ID: uuid.New(), Currency: money.PLN, Status: model.AccountActive, Balance: money.MustParseAmount("5")}
+	eur := &model.Account{ID: uuid.New(), Currency: money.EUR, Status: model.AccountActive}
+	foreign := &model.Account{ID: uuid.New(), CustomerID: uuid.New(), Currency: money.PLN, Status: model.AccountActive}
+	mockRepo.On("GetAccount", acc.ID.String()).Return(acc, nil)
+	mockRepo.On("GetAccount", eur.ID.String()).Return(eur, nil)
+	mockRepo.On("GetAccount", foreign.ID.String()).Return(foreign, nil)
+
+	_, err := svc.CloseAccount(context.Background(), acc.ID.String(), model.ReasonCustomerRequest, "", acc.ID.String())
+	assert.ErrorIs(t, err, ErrValidation)
+
+	_, err = svc.CloseAccount(context.Background(), acc.ID.String(), model.ReasonCustomerRequest, "", "not-a-uuid")
+	assert.ErrorIs(t, err, ErrValidation)
+
+	_, err = svc.CloseAccount(context.Background(), acc.ID.String(), model.ReasonCustomerRequest, "", eur.ID.String())
+	assert.ErrorIs(t, err, ErrCurrencyMismatch)
+
+	_, err = svc.CloseAccount(context.Background(), acc.ID.String(), model.ReasonCustomerRequest, "", foreign.ID.String())
+	assert.ErrorIs(t, err, ErrValidation)
+
+	mockRepo.AssertNotCalled(t, "InTx", mock.Anything)
+}
+
+func TestErrors_UnexpectedFailuresAreNotDomainErrors(t *testing.T) {
+	mockRepo := new(MockRepository)
+	svc := NewAccountService(mockRepo)
+
+	accountID := uuid.New()
+	mockRepo.On("InTx", (*model.IdempotencyKey)(nil)).Return(errors.New("connection refused"))
+
+	_, err := svc.UpdateBalance(context.Background(), accountID.String(), money.New(money.MustParseAmount("1"), money.PLN), model.Deposit, "")
+
+	assert.Error(t, err)
+	assert.False(t, IsDomainError(err))
+}
+
+func TestErrors_RepositoryDomainErrorsPassThrough(t *testing.T) {
+	mockRepo := new(MockRepository)
+	svc := NewAccountService(mockRepo)
+
+	from := &model.Account{ID: uuid.New(), Currency: money.PLN, Status: model.AccountActive, Balance: money.MustParseAmount("100")}
+	to := &model.Account{ID: uuid.New(), Currency: money.PLN, Status: model.AccountActive}
+	amount := money.New(money.MustParseAmount("40"), money.PLN)
+	lockedErr := &InsufficientFundsError{Balance: money.New(money.MustParseAmount("10"), money.PLN), Requested: amount}
+	expectLocked(mockRepo, from, to)
+	mockRepo.On("BookTransfer", from, to, amount, "").Return(nil, lockedErr)
+
+	_, err := svc.Transfer(context.Background(), from.ID.String(), to.ID.String(), amount, "")
+
+	assert.Same(t, lockedErr, err)
+}
+
+func TestTransfer_UnknownDestination(t *testing.T) {
+	mockRepo := new(MockRepository)
+	svc := NewAccountService(mockRepo)
+
+	from := &model.Account{ID: uuid.New(), Currency: money.PLN, Status: model.AccountActive, Balance: money.MustParseAmount("100")}
+	toID := uuid.New()
+	mockRepo.On("InTx", (*model.IdempotencyKey)(nil)).Return(nil)
+	mockRepo.On("LockAccounts", []uuid.UUID{from.ID, toID}).Return(map[uuid.UUID]*model.Account{from.ID: from}, nil)
+
+	_, err := svc.Transfer(context.Background(), from.ID.String(), toID.String(), money.New(money.MustParseAmount("1"), money.PLN), "")
+	assert.ErrorIs(t, err, ErrDestinationNotFound)
+
+	_, err = svc.Transfer(context.Background(), from.ID.String(), "not-a-uuid", money.New(money.MustParseAmount("1"), money.PLN), "")
+	assert.ErrorIs(t, err, ErrValidation)
 }
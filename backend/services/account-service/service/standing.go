@@ -0,0 +1,386 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"go-web-server/services/account-service/calendar"
+	"go-web-server/services/account-service/model"
+	"go-web-server/services/account-service/money"
+	"go-web-server/services/account-service/repository"
+
+	"github.com/google/uuid"
+)
+
+var (
+	// ErrStandingOrderNotFound is returned when the requested standing
+	// order does not exist.
+	ErrStandingOrderNotFound = errors.New("standing order not found")
+	// ErrStandingOrderNotActive is returned, wrapped in a
+	// StandingOrderNotActiveError, when cancelling a standing order that was
+	// completed or cancelled. Handlers map it to 409.
+	ErrStandingOrderNotActive = repository.ErrStandingOrderNotActive
+)
+
+type StandingOrderNotActiveError = repository.StandingOrderNotActiveError
+
+// standingOrderBatch bounds how many standing orders one pass schedules
+// payments for; the rest wait for the next pass.
+const standingOrderBatch = 100
+
+// StandingOrderService manages recurring transfers. Their payments are
+// scheduled as scheduled transfers on the business day they are made, and
+// executed by the ScheduledTransferService.
+type StandingOrderService interface {
+	// CreateStandingOrder starts paying amount by schedule. The first
+	// payment is the first one made on or after schedule.StartOn, which is
+	// no earlier than today and at most a year ahead.
+	CreateStandingOrder(ctx context.Context, fromAccountID, toAccountID string, amount money.Money, description string, schedule model.StandingOrderSchedule) (*model.StandingOrder, error)
+	GetStandingOrder(ctx context.Context, id string) (*model.StandingOrder, error)
+	ListStandingOrders(ctx context.Context, accountID string) ([]model.StandingOrder, error)
+	// ListStandingOrderPayments returns the payments a standing order has
+	// scheduled, latest first, at most MaxPageSize.
+	ListStandingOrderPayments(ctx context.Context, id string) ([]model.ScheduledTransfer, error)
+	// CancelStandingOrder stops a standing order and cancels its pending
+	// payments.
+	CancelStandingOrder(ctx context.Context, id string) (*model.StandingOrder, error)
+	// ScheduleDuePayments schedules the payments of active standing orders
+	// made on or before today, each as a scheduled transfer due today or
+	// earlier that the scheduled transfer worker executes on its next pass.
+	ScheduleDuePayments(ctx context.Context) (*model.StandingOrderRun, error)
+}
+
+type standingOrderService struct {
+	*accountService
+	calendar *calendar.Calendar
+	now      func() time.Time
+}
+
+// NewStandingOrderService creates the service. Payments that fall due on a
+// day cal does not count as a business day are moved by the order's shift.
+func NewStandingOrderService(repo repository.AccountRepository, cal *calendar.Calendar) StandingOrderService {
+	return &standingOrderService{accountService: &accountService{repo: repo}, calendar: cal, now: time.Now}
+}
+
+func (s *standingOrderService) CreateStandingOrder(ctx context.Context, fromAccountID, toAccountID string, amount money.Money, description string, schedule model.StandingOrderSchedule) (*model.StandingOrder, error) {
+	if amount.Sign() <= 0 {
+		return nil, invalid("amount", "transfer amount must be positive")
+	}
+	due, err := s.firstPayment(&schedule)
+	if err != nil {
+		return nil, err
+	}
+	toID, err := uuid.Parse(toAccountID)
+	if err != nil {
+		return nil, &ValidationError{Field: "toAccountId", Err: err}
+	}
+	if fromAccountID == toAccountID {
+		return nil, invalid("toAccountId", "cannot transfer to the same account")
+	}
+
+	idem := idempotencyKey(ctx, "create_standing_order", fromAccountID, toAccountID, amount.Amount.String(), string(amount.Currency), description,
+		string(schedule.Frequency), strconv.Itoa(schedule.DayOfMonth), string(schedule.Shift), schedule.StartOn, schedule.EndOn, strconv.Itoa(schedule.MaxOccurrences))
+	var previous model.StandingOrder
+	replayed, err := s.replay(idem, &previous)
+	if err != nil {
+		return nil, err
+	}
+	if replayed {
+		return &previous, nil
+	}
+
+	// The accounts are checked again on each payment; checking them now
+	// rejects orders that could never pay.
+	from, err := s.GetAccount(ctx, fromAccountID)
+	if err != nil {
+		return nil, err
+	}
+	if err := requireActive(from); err != nil {
+		return nil, err
+	}
+	to, err := s.repo.GetAccount(toAccountID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get destination account: %w", err)
+	}
+	if to == nil {
+		return nil, ErrDestinationNotFound
+	}
+	if from.Currency != amount.Currency || to.Currency != amount.Currency {
+		return nil, fmt.Errorf("%w: transfer in %s between %s and %s accounts", ErrCurrencyMismatch, amount.Currency, from.Currency, to.Currency)
+	}
+
+	so, err := s.repo.CreateStandingOrder(&model.StandingOrder{
+		ID:                    uuid.New(),
+		FromAccountID:         from.ID,
+		ToAccountID:           toID,
+		Amount:                amount.Amount,
+		Currency:              amount.Currency,
+		Description:           description,
+		StandingOrderSchedule: schedule,
+		NextDueOn:             due.Format(calendar.DateLayout),
+		NextExecuteOn:         s.paymentDay(schedule, due).Format(calendar.DateLayout),
+	}, idem)
+	if err != nil {
+		return nil, wrapFailure(err, "create standing order")
+	}
+	return so, nil
+}
+
+// firstPayment validates a schedule, fills in its default shift and
+// returns the day its first payment falls due.
+func (s *standingOrderService) firstPayment(schedule *model.StandingOrderSchedule) (time.Time, error) {
+	switch schedule.Frequency {
+	case model.FrequencyMonthly:
+		if schedule.DayOfMonth < 1 || schedule.DayOfMonth > 31 {
+			return time.Time{}, invalid("dayOfMonth", "a monthly standing order needs a day of the month between 1 and 31")
+		}
+	case model.FrequencyWeekly, model.FrequencyLastBusinessDay:
+		if schedule.DayOfMonth != 0 {
+			return time.Time{}, invalid("dayOfMonth", "only monthly standing orders take a day of the month")
+		}
+	default:
+		return time.Time{}, invalid("frequency", "unknown frequency %q", schedule.Frequency)
+	}
+	switch {
+	case schedule.Frequency == model.FrequencyLastBusinessDay:
+		if schedule.Shift != "" && schedule.Shift != model.ShiftPrevious {
+			return time.Time{}, invalid("shift", "payments on the last business day cannot move to the next one")
+		}
+		schedule.Shift = model.ShiftPrevious
+	case schedule.Shift == "":
+		schedule.Shift = model.ShiftNext
+	case !schedule.Shift.Valid():
+		return time.Time{}, invalid("shift", "unknown shift %q", schedule.Shift)
+	}
+	if schedule.MaxOccurrences < 0 {
+		return time.Time{}, invalid("maxOccurrences", "the number of payments must be positive")
+	}
+
+	start, err := time.Parse(calendar.DateLayout, schedule.StartOn)
+	if err != nil {
+		return time.Time{}, invalid("startOn", "start date must be a date such as 2024-03-01")
+	}
+	today := s.calendar.Today(s.now())
+	if start.Before(today) {
+		return time.Time{}, invalid("startOn", "start date must not be in the past")
+	}
+	if start.After(today.AddDate(1, 0, 0)) {
+		return time.Time{}, invalid("startOn", "a standing order may start at most a year ahead")
+	}
+
+	// No payment is made before the start, even when it falls due on a
+	// weekend that shifts it back.
+	due := firstDue(*schedule, start)
+	for s.paymentDay(*schedule, due).Before(start) {
+		due = nextDue(*schedule, due)
+	}
+
+	if schedule.EndOn != "" {
+		end, err := time.Parse(calendar.DateLayout, schedule.EndOn)
+		if err != nil {
+			return time.Time{}, invalid("endOn", "end date must be a date such as 2024-03-01")
+		}
+		if due.After(end) {
+			return time.Time{}, invalid("endOn", "no payment falls due between the start date and %s", schedule.EndOn)
+		}
+	}
+	return due, nil
+}
+
+// firstDue returns the first day on or after start a schedule falls due,
+// before any shift.
+func firstDue(schedule model.StandingOrderSchedule, start time.Time) time.Time {
+	switch schedule.Frequency {
+	case model.FrequencyMonthly:
+		due := dayOfMonth(start.Year(), start.Month(), schedule.DayOfMonth)
+		if due.Before(start) {
+			due = dayOfMonth(start.Year(), start.Month()+1, schedule.DayOfMonth)
+		}
+		return due
+	case model.FrequencyLastBusinessDay:
+		return dayOfMonth(start.Year(), start.Month(), 31)
+	}
+	return start
+}
+
+// nextDue returns the day a schedule falls due after due.
+func nextDue(schedule model.StandingOrderSchedule, due time.Time) time.Time {
+	switch schedule.Frequency {
+	case model.FrequencyMonthly:
+		return dayOfMonth(due.Year(), due.Month()+1, schedule.DayOfMonth)
+	case model.FrequencyLastBusinessDay:
+		return dayOfMonth(due.Year(), due.Month()+1, 31)
+	}
+	return due.AddDate(0, 0, 7)
+}
+
+// dayOfMonth returns the day of a month, or the month's last day when it is
+// shorter. Months past December roll over into the next year.
+func dayOfMonth(year int, month time.Month, day int) time.Time {
+	if last := time.Date(year, month+1, 0, 0, 0, 0, 0, time.UTC).Day(); day > last {
+		day = last
+	}
+	return time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+}
+
+// paymentDay returns the business day a payment due on due is made.
+func (s *standingOrderService) paymentDay(schedule model.StandingOrderSchedule, due time.Time) time.Time {
+	if schedule.Shift == model.ShiftPrevious {
+		return s.calendar.Previous(due)
+	}
+	return s.calendar.Next(due)
+}
+
+func (s *standingOrderService) GetStandingOrder(ctx context.Context, id string) (*model.StandingOrder, error) {
+	if _, err := uuid.Parse(id); err != nil {
+		return nil, &ValidationError{Field: "standingOrderId", Err: err}
+	}
+	so, err := s.repo.GetStandingOrder(id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get standing order: %w", err)
+	}
+	if so == nil {
+		return nil, ErrStandingOrderNotFound
+	}
+	return so, nil
+}
+
+// ListStandingOrders returns the standing orders from an account, newest
+// first, at most MaxPageSize.
+func (s *standingOrderService) ListStandingOrders(ctx context.Context, accountID string) ([]model.StandingOrder, error) {
+	if _, err := s.GetAccount(ctx, accountID); err != nil {
+		return nil, err
+	}
+	orders, err := s.repo.ListStandingOrders(accountID, MaxPageSize)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list standing orders: %w", err)
+	}
+	return orders, nil
+}
+
+func (s *standingOrderService) ListStandingOrderPayments(ctx context.Context, id string) ([]model.ScheduledTransfer, error) {
+	so, err := s.GetStandingOrder(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	payments, err := s.repo.ListStandingOrderPayments(so.ID.String(), MaxPageSize)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list standing order payments: %w", err)
+	}
+	return payments, nil
+}
+
+func (s *standingOrderService) CancelStandingOrder(ctx context.Context, id string) (*model.StandingOrder, error) {
+	so, err := s.GetStandingOrder(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	cancelled, err := s.repo.CancelStandingOrder(so.ID.String())
+	if err != nil {
+		return nil, wrapFailure(err, "cancel standing order")
+	}
+	return cancelled, nil
+}
+
+// ScheduleDuePayments stops at the first failure; the standing orders not
+// reached stay due for the next pass.
+func (s *standingOrderService) ScheduleDuePayments(ctx context.Context) (*model.StandingOrderRun, error) {
+	today := s.calendar.Today(s.now())
+	ids, err := s.repo.DueStandingOrders(today, standingOrderBatch)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list due standing orders: %w", err)
+	}
+	run := &model.StandingOrderRun{Scheduled: []model.ScheduledTransfer{}, Completed: []model.StandingOrder{}}
+	for _, id := range ids {
+		so, payments, err := s.scheduleDue(id, today)
+		if err != nil {
+			return nil, fmt.Errorf("failed to schedule payments of standing order %s: %w", id, err)
+		}
+		run.Scheduled = append(run.Scheduled, payments...)
+		if so != nil && so.Status == model.StandingOrderCompleted {
+			run.Completed = append(run.Completed, *so)
+		}
+	}
+	return run, nil
+}
+
+// scheduleDue schedules every payment of a standing order made on or before
+// today, more than one if earlier passes were missed, in the transaction
+// that advances the order past them, so that none is scheduled twice. It
+// returns a nil order when the order was cancelled in the meantime.
+func (s *standingOrderService) scheduleDue(id uuid.UUID, today time.Time) (*model.StandingOrder, []model.ScheduledTransfer, error) {
+	var so *model.StandingOrder
+	var payments []model.ScheduledTransfer
+	err := s.repo.InTx(nil, nil, func(uow repository.UnitOfWork) error {
+		locked, err := uow.LockStandingOrder(id)
+		if err != nil {
+			return err
+		}
+		so = locked
+		for so.Status == model.StandingOrderActive {
+			day, err := time.Parse(calendar.DateLayout, so.NextExecuteOn)
+			if err != nil {
+				return fmt.Errorf("invalid next payment day: %w", err)
+			}
+			if day.After(today) {
+				break
+			}
+			payment := &model.ScheduledTransfer{
+				ID:            uuid.New(),
+				FromAccountID: so.FromAccountID,
+				ToAccountID:   so.ToAccountID,
+				Amount:        so.Amount,
+				Currency:      so.Currency,
+				Description:   so.Description,
+				ExecuteOn:     so.NextExecuteOn,
+				NextAttemptAt: day,
+			}
+			if err := s.advance(so); err != nil {
+				return err
+			}
+			if err := uow.ScheduleStandingOrderPayment(so, payment); err != nil {
+				return err
+			}
+			payments = append(payments, *payment)
+		}
+		return nil
+	})
+	if errors.Is(err, ErrStandingOrderNotActive) {
+		return nil, nil, nil
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	return so, payments, nil
+}
+
+// advance counts the payment due on so.NextDueOn and moves the order to
+// its next payment, or completes it when the payment was its last.
+func (s *standingOrderService) advance(so *model.StandingOrder) error {
+	due, err := time.Parse(calendar.DateLayout, so.NextDueOn)
+	if err != nil {
+		return fmt.Errorf("invalid next due day: %w", err)
+	}
+	so.Occurrences++
+	next := nextDue(so.StandingOrderSchedule, due)
+
+	last := so.MaxOccurrences > 0 && so.Occurrences >= so.MaxOccurrences
+	if so.EndOn != "" {
+		end, err := time.Parse(calendar.DateLayout, so.EndOn)
+		if err != nil {
+			return fmt.Errorf("invalid end day: %w", err)
+		}
+		last = last || next.After(end)
+	}
+	if last {
+		so.Status = model.StandingOrderCompleted
+		so.NextDueOn, so.NextExecuteOn = "", ""
+		return nil
+	}
+	so.NextDueOn = next.Format(calendar.DateLayout)
+	so.NextExecuteOn = s.paymentDay(so.StandingOrderSchedule, next).Format(calendar.DateLayout)
+	return nil
+}
@@ -0,0 +1,193 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"go-web-server/services/account-service/calendar"
+	"go-web-server/services/account-service/model"
+	"go-web-server/services/account-service/money"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// easterCalendar closes on Easter Monday 2024, a Monday, besides weekends.
+var easterCalendar = calendar.New(map[time.Time]string{time.Date(2024, 4, 1, 0, 0, 0, 0, time.UTC): "Easter Monday"})
+
+func newTestStandingOrderService(repo *MockRepository, now time.Time) *standingOrderService {
+	svc := NewStandingOrderService(repo, easterCalendar).(*standingOrderService)
+	svc.now = func() time.Time { return now }
+	return svc
+}
+
+func TestCreateStandingOrder_FirstPayment(t *testing.T) {
+	mockRepo := new(MockRepository)
+	svc := newTestStandingOrderService(mockRepo, time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC))
+
+	from := &model.Account{ID: uuid.New(), Currency: money.PLN, Status: model.AccountActive}
+	to := &model.Account{ID: uuid.New(), Currency: money.PLN, Status: model.AccountActive}
+	mockRepo.On("GetAccount", from.ID.String()).Return(from, nil)
+	mockRepo.On("GetAccount", to.ID.String()).Return(to, nil)
+	var created *model.StandingOrder
+	mockRepo.On("CreateStandingOrder", mock.Anything, (*model.IdempotencyKey)(nil)).Return(&model.StandingOrder{}, nil).Run(func(args mock.Arguments) {
+		created = args.Get(0).(*model.StandingOrder)
+	})
+
+	for _, tc := range []struct {
+		name              string
+		schedule          model.StandingOrderSchedule
+		nextDue, nextExec string
+		shift             model.BusinessDayShift
+	}{
+		{"monthly on a day the month lacks", model.StandingOrderSchedule{Frequency: model.FrequencyMonthly, DayOfMonth: 31, StartOn: "2024-04-01"},
+			"2024-04-30", "2024-04-30", model.ShiftNext},
+		{"monthly on a holiday, shifted forward", model.StandingOrderSchedule{Frequency: model.FrequencyMonthly, DayOfMonth: 1, StartOn: "2024-03-28"},
+			"2024-04-01", "2024-04-02", model.ShiftNext},
+		{"monthly on a holiday, shifted back", model.StandingOrderSchedule{Frequency: model.FrequencyMonthly, DayOfMonth: 1, Shift: model.ShiftPrevious, StartOn: "2024-03-28"},
+			"2024-04-01", "2024-03-29", model.ShiftPrevious},
+		{"shifted back before the start", model.StandingOrderSchedule{Frequency: model.FrequencyMonthly, DayOfMonth: 1, Shift: model.ShiftPrevious, StartOn: "2024-06-01"},
+			"2024-07-01", "2024-07-01", model.ShiftPrevious},
+		{"weekly from a Saturday", model.StandingOrderSchedule{Frequency: model.FrequencyWeekly, StartOn: "2024-03-09"},
+			"2024-03-09", "2024-03-11", model.ShiftNext},
+		{"last business day before a weekend", model.StandingOrderSchedule{Frequency: model.FrequencyLastBusinessDay, StartOn: "2024-03-05"},
+			"2024-03-31", "2024-03-29", model.ShiftPrevious},
+		{"last business day already passed", model.StandingOrderSchedule{Frequency: model.FrequencyLastBusinessDay, StartOn: "2024-03-30"},
+			"2024-04-30", "2024-04-30", model.ShiftPrevious},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := svc.CreateStandingOrder(context.Background(), from.ID.String(), to.ID.String(), money.New(money.MustParseAmount("50"), money.PLN), "Rent", tc.schedule)
+			require.NoError(t, err)
+			assert.Equal(t, tc.nextDue, created.NextDueOn)
+			assert.Equal(t, tc.nextExec, created.NextExecuteOn)
+			assert.Equal(t, tc.shift, created.Shift)
+		})
+	}
+}
+
+func TestCreateStandingOrder_InvalidSchedule(t *testing.T) {
+	mockRepo := new(MockRepository)
+	svc := newTestStandingOrderService(mockRepo, time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC))
+	from, to := uuid.New().String(), uuid.New().String()
+
+	for _, tc := range []struct {
+		name     string
+		schedule model.StandingOrderSchedule
+		field    string
+	}{
+		{"unknown frequency", model.StandingOrderSchedule{Frequency: "daily", StartOn: "2024-03-04"}, "frequency"},
+		{"monthly without a day", model.StandingOrderSchedule{Frequency: model.FrequencyMonthly, StartOn: "2024-03-04"}, "dayOfMonth"},
+		{"weekly with a day", model.StandingOrderSchedule{Frequency: model.FrequencyWeekly, DayOfMonth: 5, StartOn: "2024-03-04"}, "dayOfMonth"},
+		{"last business day shifted forward", model.StandingOrderSchedule{Frequency: model.FrequencyLastBusinessDay, Shift: model.ShiftNext, StartOn: "2024-03-04"}, "shift"},
+		{"unknown shift", model.StandingOrderSchedule{Frequency: model.FrequencyWeekly, Shift: "nearest", StartOn: "2024-03-04"}, "shift"},
+		{"start in the past", model.StandingOrderSchedule{Frequency: model.FrequencyWeekly, StartOn: "2024-02-29"}, "startOn"},
+		{"no payment before the end", model.StandingOrderSchedule{Frequency: model.FrequencyMonthly, DayOfMonth: 20, StartOn: "2024-03-04", EndOn: "2024-03-15"}, "endOn"},
+		{"negative count", model.StandingOrderSchedule{Frequency: model.FrequencyWeekly, StartOn: "2024-03-04", MaxOccurrences: -1}, "maxOccurrences"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := svc.CreateStandingOrder(context.Background(), from, to, money.New(money.MustParseAmount("50"), money.PLN), "", tc.schedule)
+			var vErr *ValidationError
+			require.ErrorAs(t, err, &vErr)
+			assert.Equal(t, tc.field, vErr.Field)
+		})
+	}
+	mockRepo.AssertNotCalled(t, "CreateStandingOrder", mock.Anything, mock.Anything)
+}
+
+func TestScheduleDuePayments(t *testing.T) {
+	mockRepo := new(MockRepository)
+	svc := newTestStandingOrderService(mockRepo, time.Date(2024, 4, 15, 6, 0, 0, 0, time.UTC))
+	today := time.Date(2024, 4, 15, 0, 0, 0, 0, time.UTC)
+	mockRepo.On("InTx", (*model.IdempotencyKey)(nil)).Return(nil)
+
+	// Two months missed: the 10th of March was a Sunday, so its payment
+	// was made on Monday. The second payment is the last.
+	monthly := &model.StandingOrder{
+		ID: uuid.New(), FromAccountID: uuid.New(), ToAccountID: uuid.New(), Amount: money.MustParseAmount("50"), Currency: money.PLN,
+		StandingOrderSchedule: model.StandingOrderSchedule{Frequency: model.FrequencyMonthly, DayOfMonth: 10, Shift: model.ShiftNext, StartOn: "2024-03-01", MaxOccurrences: 2},
+		Status:                model.StandingOrderActive, NextDueOn: "2024-03-10", NextExecuteOn: "2024-03-11",
+	}
+	// Due today and running until the end of the month.
+	weekly := &model.StandingOrder{
+		ID: uuid.New(), FromAccountID: uuid.New(), ToAccountID: uuid.New(), Amount: money.MustParseAmount("20"), Currency: money.PLN,
+		StandingOrderSchedule: model.StandingOrderSchedule{Frequency: model.FrequencyWeekly, Shift: model.ShiftNext, StartOn: "2024-04-08", EndOn: "2024-04-30"},
+		Status:                model.StandingOrderActive, Occurrences: 1, NextDueOn: "2024-04-15", NextExecuteOn: "2024-04-15",
+	}
+	// Cancelled after it was listed.
+	cancelled := uuid.New()
+
+	mockRepo.On("DueStandingOrders", today, standingOrderBatch).Return([]uuid.UUID{monthly.ID, weekly.ID, cancelled}, nil)
+	mockRepo.On("LockStandingOrder", monthly.ID).Return(monthly, nil)
+	mockRepo.On("LockStandingOrder", weekly.ID).Return(weekly, nil)
+	mockRepo.On("LockStandingOrder", cancelled).Return(nil, &StandingOrderNotActiveError{ID: cancelled, Status: model.StandingOrderCancelled})
+
+	type step struct {
+		executeOn, nextExecuteOn string
+		occurrences              int
+		status                   model.StandingOrderStatus
+	}
+	var steps []step
+	mockRepo.On("ScheduleStandingOrderPayment", mock.Anything, mock.Anything).Return(nil).Run(func(args mock.Arguments) {
+		so, payment := args.Get(0).(*model.StandingOrder), args.Get(1).(*model.ScheduledTransfer)
+		assert.Equal(t, so.Amount, payment.Amount)
+		assert.Equal(t, payment.ExecuteOn, payment.NextAttemptAt.Format(calendar.DateLayout))
+		steps = append(steps, step{payment.ExecuteOn, so.NextExecuteOn, so.Occurrences, so.Status})
+	})
+
+	run, err := svc.ScheduleDuePayments(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, []step{
+		{"2024-03-11", "2024-04-10", 1, model.StandingOrderActive},
+		{"2024-04-10", "", 2, model.StandingOrderCompleted},
+		{"2024-04-15", "2024-04-22", 2, model.StandingOrderActive},
+	}, steps)
+	assert.Len(t, run.Scheduled, 3)
+	require.Len(t, run.Completed, 1)
+	assert.Equal(t, monthly.ID, run.Completed[0].ID)
+}
+
+func TestScheduleDuePayments_EndDate(t *testing.T) {
+	mockRepo := new(MockRepository)
+	svc := newTestStandingOrderService(mockRepo, time.Date(2024, 3, 29, 6, 0, 0, 0, time.UTC))
+	mockRepo.On("InTx", (*model.IdempotencyKey)(nil)).Return(nil)
+
+	// The March payment, moved back from Easter Sunday, is the last before
+	// the end date.
+	so := &model.StandingOrder{
+		ID:                    uuid.New(),
+		StandingOrderSchedule: model.StandingOrderSchedule{Frequency: model.FrequencyLastBusinessDay, Shift: model.ShiftPrevious, StartOn: "2024-01-01", EndOn: "2024-04-15"},
+		Status:                model.StandingOrderActive, Occurrences: 2, NextDueOn: "2024-03-31", NextExecuteOn: "2024-03-29",
+	}
+	mockRepo.On("DueStandingOrders", time.Date(2024, 3, 29, 0, 0, 0, 0, time.UTC), standingOrderBatch).Return([]uuid.UUID{so.ID}, nil)
+	mockRepo.On("LockStandingOrder", so.ID).Return(so, nil)
+	mockRepo.On("ScheduleStandingOrderPayment", so, mock.MatchedBy(func(p *model.ScheduledTransfer) bool {
+		return p.ExecuteOn == "2024-03-29"
+	})).Return(nil)
+
+	run, err := svc.ScheduleDuePayments(context.Background())
+	require.NoError(t, err)
+	assert.Len(t, run.Scheduled, 1)
+	assert.Equal(t, model.StandingOrderCompleted, so.Status)
+	assert.Equal(t, 3, so.Occurrences)
+	assert.Empty(t, so.NextExecuteOn)
+}
+
+func TestCancelStandingOrder(t *testing.T) {
+	mockRepo := new(MockRepository)
+	svc := NewStandingOrderService(mockRepo, calendar.New(nil))
+	so := &model.StandingOrder{ID: uuid.New(), Status: model.StandingOrderCompleted}
+	mockRepo.On("GetStandingOrder", so.ID.String()).Return(so, nil)
+	mockRepo.On("CancelStandingOrder", so.ID.String()).Return(nil, &StandingOrderNotActiveError{ID: so.ID, Status: so.Status})
+
+	_, err := svc.CancelStandingOrder(context.Background(), so.ID.String())
+	assert.ErrorIs(t, err, ErrStandingOrderNotActive)
+	assert.True(t, IsDomainError(err))
+
+	missing := uuid.New()
+	mockRepo.On("GetStandingOrder", missing.String()).Return(nil, nil)
+	_, err = svc.CancelStandingOrder(context.Background(), missing.String())
+	assert.ErrorIs(t, err, ErrStandingOrderNotFound)
+}
@@ -0,0 +1,172 @@
+package tests
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"go-web-server/services/account-service/model"
+	"go-web-server/services/account-service/money"
+	"go-web-server/services/account-service/repository"
+	"go-web-server/services/account-service/service"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// concurrentRequests is how many requests each test fires at once. The pool
+// is capped below Postgres' default connection limit, so most of them queue
+// for a connection and then for the account lock.
+const (
+	concurrentRequests = 300
+	concurrentConns    = 20
+)
+
+// seedAccount creates a customer with one PLN account holding balance and
+// allowed to go overdraftLimit below zero.
+func seedAccount(t *testing.T, db *sql.DB, balance, overdraftLimit string) uuid.UUID {
+	customerID, accountID := uuid.New(), uuid.New()
+	_, err := db.Exec("INSERT INTO customers (id, external_id, full_name) VALUES ($1, $2, $3)",
+		customerID, "concurrency_"+customerID.String(), "Concurrency Test User")
+	require.NoError(t, err)
+	_, err = db.Exec(`INSERT INTO accounts (id, customer_id, account_number, currency, balance, status, overdraft_limit)
+		VALUES ($1, $2, $3, 'PLN', $4, 'active', $5)`, accountID, customerID, "PL-"+accountID.String()[:8], balance, overdraftLimit)
+	require.NoError(t, err)
+	return accountID
+}
+
+func balanceOf(t *testing.T, db *sql.DB, accountID uuid.UUID) money.Amount {
+	var balance money.Amount
+	require.NoError(t, db.QueryRow("SELECT balance FROM accounts WHERE id = $1", accountID).Scan(&balance))
+	return balance
+}
+
+// ledgerSum returns the sum of the account's ledger entries, which must
+// explain every change to the seeded balance.
+func ledgerSum(t *testing.T, db *sql.DB, accountID uuid.UUID) money.Amount {
+	var sum money.Amount
+	require.NoError(t, db.QueryRow("SELECT COALESCE(SUM(amount), 0) FROM ledger_entries WHERE account_id = $1", accountID).Scan(&sum))
+	return sum
+}
+
+// runConcurrently calls fn concurrentRequests times in parallel and counts
+// the calls that succeeded and those rejected for insufficient funds. Any
+// other error fails the test.
+func runConcurrently(t *testing.T, fn func(i int) error) (succeeded, rejected int) {
+	var ok, insufficient int64
+	var wg sync.WaitGroup
+	start := make(chan struct{})
+	for i := 0; i < concurrentRequests; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			<-start
+			err := fn(i)
+			switch {
+			case err == nil:
+				atomic.AddInt64(&ok, 1)
+			case errors.Is(err, service.ErrInsufficientFunds):
+				atomic.AddInt64(&insufficient, 1)
+			default:
+				t.Errorf("request %d: %v", i, err)
+			}
+		}(i)
+	}
+	close(start)
+	wg.Wait()
+	return int(ok), int(insufficient)
+}
+
+func setupConcurrency(t *testing.T) (*sql.DB, service.AccountService) {
+	_, db := setupIntegration(t)
+	db.SetMaxOpenConns(concurrentConns)
+	t.Cleanup(func() { db.SetMaxOpenConns(0) })
+	return db, service.NewAccountService(repository.NewPostgresAccountRepository(db))
+}
+
+func TestConcurrentWithdrawals_NeverOverdraw(t *testing.T) {
+	db, svc := setupConcurrency(t)
+	accountID := seedAccount(t, db, "100.00", "0")
+	withdrawal := money.New(money.MustParseAmount("-0.50"), money.PLN)
+
+	succeeded, rejected := runConcurrently(t, func(int) error {
+		_, err := svc.UpdateBalance(context.Background(), accountID.String(), withdrawal, model.Withdrawal, "ATM")
+		return err
+	})
+
+	assert.Equal(t, 200, succeeded)
+	assert.Equal(t, concurrentRequests-200, rejected)
+	assert.True(t, balanceOf(t, db, accountID).IsZero())
+	assert.Equal(t, money.MustParseAmount("-100"), ledgerSum(t, db, accountID))
+}
+
+func TestConcurrentWithdrawals_StopAtOverdraftLimit(t *testing.T) {
+	db, svc := setupConcurrency(t)
+	accountID := seedAccount(t, db, "10.00", "40.00")
+	withdrawal := money.New(money.MustParseAmount("-1"), money.PLN)
+
+	succeeded, _ := runConcurrently(t, func(int) error {
+		_, err := svc.UpdateBalance(context.Background(), accountID.String(), withdrawal, model.Withdrawal, "ATM")
+		return err
+	})
+
+	assert.Equal(t, 50, succeeded)
+	assert.Equal(t, money.MustParseAmount("-40"), balanceOf(t, db, accountID))
+}
+
+func TestConcurrentTransfers_ConserveMoney(t *testing.T) {
+	db, svc := setupConcurrency(t)
+	a := seedAccount(t, db, "50.00", "0")
+	b := seedAccount(t, db, "50.00", "0")
+	amount := money.New(money.MustParseAmount("7"), money.PLN)
+
+	// Transfers run in both directions at once: locking in id order keeps
+	// them from deadlocking, and neither account may go below zero.
+	runConcurrently(t, func(i int) error {
+		from, to := a, b
+		if i%2 == 1 {
+			from, to = b, a
+		}
+		_, err := svc.Transfer(context.Background(), from.String(), to.String(), amount, "Ping-pong")
+		return err
+	})
+
+	balanceA, balanceB := balanceOf(t, db, a), balanceOf(t, db, b)
+	assert.False(t, balanceA.IsNegative(), "balance of a: %s", balanceA)
+	assert.False(t, balanceB.IsNegative(), "balance of b: %s", balanceB)
+	sum := func(x, y money.Amount) money.Amount {
+		s, err := x.Add(y)
+		require.NoError(t, err)
+		return s
+	}
+	assert.Equal(t, money.MustParseAmount("100"), sum(balanceA, balanceB))
+	assert.Equal(t, sum(money.MustParseAmount("50"), ledgerSum(t, db, a)), balanceA)
+	assert.Equal(t, sum(money.MustParseAmount("50"), ledgerSum(t, db, b)), balanceB)
+}
+
+func TestConcurrentWithdrawalsAndHolds_StayWithinBalance(t *testing.T) {
+	db, svc := setupConcurrency(t)
+	holds := service.NewHoldService(repository.NewPostgresAccountRepository(db), 0)
+	accountID := seedAccount(t, db, "100.00", "0")
+	amount := money.New(money.MustParseAmount("1"), money.PLN)
+
+	// Holds and withdrawals compete for the same available balance.
+	succeeded, _ := runConcurrently(t, func(i int) error {
+		if i%2 == 0 {
+			_, err := holds.PlaceHold(context.Background(), accountID.String(), amount, "Card payment", nil)
+			return err
+		}
+		_, err := svc.UpdateBalance(context.Background(), accountID.String(), amount.Neg(), model.Withdrawal, "ATM")
+		return err
+	})
+
+	acc, err := svc.GetAccount(context.Background(), accountID.String())
+	require.NoError(t, err)
+	assert.Equal(t, 100, succeeded)
+	assert.True(t, acc.AvailableBalance.IsZero(), "available balance: %s", acc.AvailableBalance)
+	assert.False(t, acc.Balance.IsNegative())
+}
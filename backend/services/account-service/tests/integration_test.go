@@ -12,7 +12,9 @@ import (
 	"time"
 
 	"go-web-server/services/account-service/handler"
+	"go-web-server/services/account-service/handler/middleware"
 	"go-web-server/services/account-service/model"
+	"go-web-server/services/account-service/money"
 	"go-web-server/services/account-service/repository"
 	"go-web-server/services/account-service/service"
 
@@ -26,7 +28,7 @@ import (
 
 var (
 	testDB *sql.DB
-	jwtKey = []byte("my_secret_key_for_testing_only")
+	jwtKey = []byte("test_signing_key_of_at_least_32_bytes")
 )
 
 func createToken(username string) string {
@@ -76,7 +78,7 @@ func setupIntegration(t *testing.T) (chi.Router, *sql.DB) {
 	}
 
 	// Clean up and Migrate
-	_, err := testDB.Exec("DROP TABLE IF EXISTS ledger_entries, accounts, customers CASCADE")
+	_, err := testDB.Exec("DROP TABLE IF EXISTS idempotency_keys, ledger_entries, accounts, customers CASCADE")
 	require.NoError(t, err)
 
 	schema, err := os.ReadFile("../migrations/schema.sql")
@@ -86,7 +88,7 @@ func setupIntegration(t *testing.T) (chi.Router, *sql.DB) {
 
 	repo := repository.NewPostgresAccountRepository(testDB)
 	svc := service.NewAccountService(repo)
-	h := handler.NewAccountHandler(svc)
+	h := handler.NewAccountHandler(svc, middleware.AuthMiddleware(jwtKey, nil), middleware.RequireAdmin())
 
 	r := chi.NewRouter()
 	h.RegisterRoutes(r)
@@ -96,11 +98,10 @@ func setupIntegration(t *testing.T) (chi.Router, *sql.DB) {
 
 func TestAccountLifecycle_Integration(t *testing.T) {
 	r, db := setupIntegration(t)
-	token := createToken("test_user")
-
 	// 1. Create a Customer in DB (Manual step since we don't have Customer API yet)
 	customerID := uuid.New()
 	externalID := "auth_user_123"
+	token := createToken(externalID)
 	_, err := db.Exec("INSERT INTO customers (id, external_id, full_name) VALUES ($1, $2, $3)",
 		customerID, externalID, "Integration Test User")
 	require.NoError(t, err)
@@ -119,8 +120,8 @@ func TestAccountLifecycle_Integration(t *testing.T) {
 	var acc model.Account
 	err = json.Unmarshal(rr.Body.Bytes(), &acc)
 	require.NoError(t, err)
-	assert.Equal(t, "USD", acc.Currency)
-	assert.Equal(t, 0.0, acc.Balance)
+	assert.Equal(t, money.USD, acc.Currency)
+	assert.Equal(t, money.Amount{}, acc.Balance)
 
 	accountID := acc.ID.String()
 
@@ -137,7 +138,8 @@ func TestAccountLifecycle_Integration(t *testing.T) {
 
 	// 4. Update Balance (Deposit)
 	depositBody, _ := json.Marshal(map[string]interface{}{
-		"amount":      100.50,
+		"amount":      "100.50",
+		"currency":    "USD",
 		"type":        "DEPOSIT",
 		"description": "Initial deposit",
 	})
@@ -154,11 +156,12 @@ func TestAccountLifecycle_Integration(t *testing.T) {
 	rr = httptest.NewRecorder()
 	r.ServeHTTP(rr, req)
 	json.Unmarshal(rr.Body.Bytes(), &acc)
-	assert.Equal(t, 100.50, acc.Balance)
+	assert.Equal(t, money.MustParseAmount("100.50"), acc.Balance)
 
 	// 6. Update Balance (Withdraw - Success)
 	withdrawBody, _ := json.Marshal(map[string]interface{}{
-		"amount":      -50.00,
+		"amount":      "-50.00",
+		"currency":    "USD",
 		"type":        "WITHDRAWAL",
 		"description": "ATM withdrawal",
 	})
@@ -170,7 +173,8 @@ func TestAccountLifecycle_Integration(t *testing.T) {
 
 	// 7. Update Balance (Withdraw - Insufficient Funds)
 	withdrawBodyFail, _ := json.Marshal(map[string]interface{}{
-		"amount":      -1000.00,
+		"amount":      "-1000.00",
+		"currency":    "USD",
 		"type":        "WITHDRAWAL",
 		"description": "Too much",
 	})
@@ -178,6 +182,92 @@ func TestAccountLifecycle_Integration(t *testing.T) {
 	req.Header.Set("Authorization", "Bearer "+token)
 	rr = httptest.NewRecorder()
 	r.ServeHTTP(rr, req)
-	assert.Equal(t, http.StatusBadRequest, rr.Code)
-	assert.Contains(t, rr.Body.String(), "insufficient funds")
+	assert.Equal(t, http.StatusUnprocessableEntity, rr.Code)
+	assert.Contains(t, rr.Body.String(), `"code":"insufficient_funds"`)
+}
+
+func TestTransfer_Integration(t *testing.T) {
+	r, db := setupIntegration(t)
+	token := createToken("auth_user_transfer")
+
+	customerID := uuid.New()
+	_, err := db.Exec("INSERT INTO customers (id, external_id, full_name) VALUES ($1, $2, $3)",
+		customerID, "auth_user_transfer", "Transfer Test User")
+	require.NoError(t, err)
+
+	fromID, toID := uuid.New(), uuid.New()
+	_, err = db.Exec(`INSERT INTO accounts (id, customer_id, account_number, currency, balance, status) VALUES
+		($1, $3, 'PL-TRANSFER-FROM', 'PLN', 100.00, 'active'),
+		($2, $3, 'PL-TRANSFER-TO', 'PLN', 0, 'active')`, fromID, toID, customerID)
+	require.NoError(t, err)
+
+	body, _ := json.Marshal(map[string]string{
+		"toAccountId": toID.String(),
+		"amount":      "40.10",
+		"currency":    "PLN",
+		"description": "Rent",
+	})
+	req := httptest.NewRequest("POST", "/accounts/"+fromID.String()+"/transfers", bytes.NewBuffer(body))
+	req.Header.Set("Authorization", "Bearer "+token)
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, req)
+	require.Equal(t, http.StatusCreated, rr.Code, rr.Body.String())
+
+	var transfer model.Transfer
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &transfer))
+
+	var fromBal, toBal money.Amount
+	require.NoError(t, db.QueryRow("SELECT balance FROM accounts WHERE id = $1", fromID).Scan(&fromBal))
+	require.NoError(t, db.QueryRow("SELECT balance FROM accounts WHERE id = $1", toID).Scan(&toBal))
+	assert.Equal(t, money.MustParseAmount("59.90"), fromBal)
+	assert.Equal(t, money.MustParseAmount("40.10"), toBal)
+
+	var linked int
+	require.NoError(t, db.QueryRow("SELECT COUNT(*) FROM ledger_entries WHERE reference_id = $1", transfer.ReferenceID).Scan(&linked))
+	assert.Equal(t, 2, linked)
+
+	// A second transfer larger than the remaining balance must be rejected
+	// without touching either account.
+	body, _ = json.Marshal(map[string]string{"toAccountId": toID.String(), "amount": "60.00", "currency": "PLN"})
+	req = httptest.NewRequest("POST", "/accounts/"+fromID.String()+"/transfers", bytes.NewBuffer(body))
+	req.Header.Set("Authorization", "Bearer "+token)
+	rr = httptest.NewRecorder()
+	r.ServeHTTP(rr, req)
+	assert.Equal(t, http.StatusUnprocessableEntity, rr.Code)
+	assert.Contains(t, rr.Body.String(), `"code":"insufficient_funds"`)
+}
+
+func TestIdempotentDeposit_Integration(t *testing.T) {
+	r, db := setupIntegration(t)
+	token := createToken("auth_user_idem")
+
+	customerID, accountID := uuid.New(), uuid.New()
+	_, err := db.Exec("INSERT INTO customers (id, external_id, full_name) VALUES ($1, $2, $3)",
+		customerID, "auth_user_idem", "Idempotency Test User")
+	require.NoError(t, err)
+	_, err = db.Exec(`INSERT INTO accounts (id, customer_id, account_number, currency, balance, status)
+		VALUES ($1, $2, 'PL-IDEM', 'PLN', 0, 'active')`, accountID, customerID)
+	require.NoError(t, err)
+
+	deposit := func(amount string) int {
+		body, _ := json.Marshal(map[string]string{"amount": amount, "currency": "PLN", "type": "deposit", "description": "Salary"})
+		req := httptest.NewRequest("POST", "/accounts/"+accountID.String()+"/balance", bytes.NewBuffer(body))
+		req.Header.Set("Authorization", "Bearer "+token)
+		req.Header.Set("Idempotency-Key", "salary-2024-01")
+		rr := httptest.NewRecorder()
+		r.ServeHTTP(rr, req)
+		return rr.Code
+	}
+
+	assert.Equal(t, http.StatusNoContent, deposit("100.00"))
+	assert.Equal(t, http.StatusNoContent, deposit("100.00"))
+	assert.Equal(t, http.StatusUnprocessableEntity, deposit("200.00"))
+
+	var balance money.Amount
+	require.NoError(t, db.QueryRow("SELECT balance FROM accounts WHERE id = $1", accountID).Scan(&balance))
+	assert.Equal(t, money.MustParseAmount("100"), balance)
+
+	var entries int
+	require.NoError(t, db.QueryRow("SELECT COUNT(*) FROM ledger_entries WHERE account_id = $1", accountID).Scan(&entries))
+	assert.Equal(t, 1, entries)
 }
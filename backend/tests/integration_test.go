@@ -8,16 +8,43 @@ import (
 	"testing"
 	"time"
 
+	"github.com/golang-jwt/jwt/v5"
 	"github.com/google/uuid"
 	_ "github.com/lib/pq"
 	"go-web-server/internal/model"
+	"go-web-server/services/account-service/money"
 )
 
 const (
 	apiURL = "http://localhost:8080"
 	dbDSN  = "host=localhost port=5432 user=postgres password=secret dbname=fintech_db sslmode=disable"
+
+	// jwtSecret is the docker-compose default JWT_SECRET.
+	jwtSecret = "local_development_signing_key_change_me"
 )
 
+// postTransaction sends req to the legacy transactions endpoint as username,
+// authenticated with an access token signed like the gateway's own.
+func postTransaction(client *http.Client, username string, req model.TransactionRequest) (*http.Response, error) {
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"username": username,
+		"jti":      uuid.New().String(),
+		"exp":      time.Now().Add(time.Minute).Unix(),
+	}).SignedString([]byte(jwtSecret))
+	if err != nil {
+		return nil, err
+	}
+
+	payload, _ := json.Marshal(req)
+	httpReq, err := http.NewRequest(http.MethodPost, apiURL+"/api/transactions", bytes.NewBuffer(payload))
+	if err != nil {
+		return nil, err
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	httpReq.Header.Set("Authorization", "Bearer "+token)
+	return client.Do(httpReq)
+}
+
 // Helper to reset DB state (Truncate all relevant tables)
 func resetDB(t *testing.T) {
 	db, err := sql.Open("postgres", dbDSN)
@@ -28,7 +55,7 @@ func resetDB(t *testing.T) {
 
 	// Truncate tables to ensure clean state
 	// Order matters due to foreign keys: ledger_entries -> accounts -> customers
-	_, err = db.Exec("TRUNCATE TABLE ledger_entries, accounts, customers CASCADE")
+	_, err = db.Exec("TRUNCATE TABLE idempotency_keys, ledger_entries, accounts, customers CASCADE")
 	if err != nil {
 		t.Logf("Warning: Failed to truncate tables: %v", err)
 	}
@@ -48,12 +75,13 @@ func TestTransactionFlow(t *testing.T) {
 
 	// 1. Create Customer and Account directly in DB
 	customerID := uuid.New()
+	externalID := "ext_user_" + customerID.String()
 	accountID := uuid.New()
 	accountNumber := "PL" + uuid.New().String()[0:20] // Fake IBAN-ish
 	
 	// Create Customer
 	_, err = db.Exec("INSERT INTO customers (id, external_id, full_name) VALUES ($1, $2, $3)", 
-		customerID, "ext_user_"+customerID.String(), "Integration Test User")
+		customerID, externalID, "Integration Test User")
 	if err != nil {
 		t.Fatalf("Failed to create customer: %v", err)
 	}
@@ -70,15 +98,15 @@ func TestTransactionFlow(t *testing.T) {
 	client := &http.Client{Timeout: 5 * time.Second}
 
 	// 2. Test Deposit
-	// IMPORTANT: The current Handler acts as a bridge and treats the 'UserID' field in JSON as the AccountID UUID.
+	// The legacy API identifies the user by username (customers.external_id);
+	// the gateway applies the transaction to the customer's primary account.
 	depositReq := model.TransactionRequest{
-		UserID: accountID.String(), 
+		UserID: externalID,
 		Type:   model.Deposit,
-		Amount: 100.0,
+		Amount: money.MustParseAmount("100"),
 	}
-	payload, _ := json.Marshal(depositReq)
 
-	resp, err := client.Post(apiURL+"/api/transactions", "application/json", bytes.NewBuffer(payload))
+	resp, err := postTransaction(client, externalID, depositReq)
 	if err != nil {
 		t.Fatalf("Failed to send deposit request: %v", err)
 	}
@@ -93,25 +121,24 @@ func TestTransactionFlow(t *testing.T) {
 
 	var account model.Account
 	// Re-decode response
-	// Note: The handler returns a mapped legacy model, so balance should be plain float
+	// Note: The handler returns a mapped legacy model with the balance as a decimal string
 	if err := json.NewDecoder(resp.Body).Decode(&account); err != nil {
 		// Try to read body again if decode fails (though body is consumed, usually need to peek)
 		t.Fatalf("Failed to decode response: %v", err)
 	}
 
-	if account.Balance != 100.0 {
-		t.Errorf("Expected balance 100.0 after deposit, got %f", account.Balance)
+	if account.Balance != money.MustParseAmount("100") {
+		t.Errorf("Expected balance 100.00 after deposit, got %s", account.Balance)
 	}
 
 	// 3. Test Withdraw
 	withdrawReq := model.TransactionRequest{
-		UserID: accountID.String(),
+		UserID: externalID,
 		Type:   model.Withdraw,
-		Amount: 40.0,
+		Amount: money.MustParseAmount("40"),
 	}
-	payload, _ = json.Marshal(withdrawReq)
 
-	resp, err = client.Post(apiURL+"/api/transactions", "application/json", bytes.NewBuffer(payload))
+	resp, err = postTransaction(client, externalID, withdrawReq)
 	if err != nil {
 		t.Fatalf("Failed to send withdraw request: %v", err)
 	}
@@ -125,25 +152,35 @@ func TestTransactionFlow(t *testing.T) {
 		t.Fatalf("Failed to decode response: %v", err)
 	}
 
-	if account.Balance != 60.0 {
-		t.Errorf("Expected balance 60.0 after withdrawal, got %f", account.Balance)
+	if account.Balance != money.MustParseAmount("60") {
+		t.Errorf("Expected balance 60.00 after withdrawal, got %s", account.Balance)
 	}
 
 	// 4. Test Overdraft (Withdraw more than balance)
 	overdraftReq := model.TransactionRequest{
-		UserID: accountID.String(),
+		UserID: externalID,
 		Type:   model.Withdraw,
-		Amount: 100.0,
+		Amount: money.MustParseAmount("100"),
 	}
-	payload, _ = json.Marshal(overdraftReq)
 
-	resp, err = client.Post(apiURL+"/api/transactions", "application/json", bytes.NewBuffer(payload))
+	resp, err = postTransaction(client, externalID, overdraftReq)
 	if err != nil {
 		t.Fatalf("Failed to send overdraft request: %v", err)
 	}
 	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusBadRequest {
-		t.Errorf("Expected status 400 for overdraft, got %d", resp.StatusCode)
+	if resp.StatusCode != http.StatusUnprocessableEntity {
+		t.Errorf("Expected status 422 for overdraft, got %d", resp.StatusCode)
+	}
+
+	// 5. Another user must not be able to move money out of this account
+	resp, err = postTransaction(client, "someone_else", withdrawReq)
+	if err != nil {
+		t.Fatalf("Failed to send foreign withdraw request: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusForbidden {
+		t.Errorf("Expected status 403 for another user's account, got %d", resp.StatusCode)
 	}
 }
\ No newline at end of file